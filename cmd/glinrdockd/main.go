@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -16,11 +17,14 @@ import (
 	"github.com/GLINCKER/glinrdock/internal/docker"
 	"github.com/GLINCKER/glinrdock/internal/dockerx"
 	"github.com/GLINCKER/glinrdock/internal/events"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/jobs"
 	"github.com/GLINCKER/glinrdock/internal/license"
 	"github.com/GLINCKER/glinrdock/internal/metrics"
 	"github.com/GLINCKER/glinrdock/internal/nginx"
 	"github.com/GLINCKER/glinrdock/internal/plan"
 	"github.com/GLINCKER/glinrdock/internal/proxy"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/GLINCKER/glinrdock/internal/util"
 	"github.com/GLINCKER/glinrdock/internal/web"
@@ -120,6 +124,9 @@ func main() {
 		log.Info().Msg("encryption key loaded successfully - secret environment variables are available")
 	}
 
+	// Bind mounts of services stay under these host directories when set
+	store.SetBindMountRoots(config.BindMountRoots)
+
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
@@ -203,13 +210,19 @@ func main() {
 		log.Error().Err(err).Msg("failed to initialize nginx manager")
 	}
 
+	// Setup persistent job queue
+	jobQueue := jobs.NewPersistentQueue(2, storeInstance)
+
 	// Setup nginx configuration if enabled
 	var nginxConfig *proxy.NginxConfig
 	var certHandlers *api.CertHandlers
 	if config.NginxProxyEnabled {
 		nginxConfig = proxy.NewNginxConfig("/etc/nginx/nginx.conf", config.DataDir, storeInstance)
 		// Initialize certificate handlers for nginx proxy
-		certHandlers = api.NewCertHandlers(storeInstance, nil, nginxConfig) // TODO: Add job queue
+		certManager := jobs.NewCertManager(storeInstance, nginxConfig, config.DataDir, jobQueue)
+		jobQueue.RegisterHandler("cert_issue", certManager.Handle)
+		jobQueue.RegisterHandler("cert_renew", certManager.Handle)
+		certHandlers = api.NewCertHandlers(storeInstance, jobQueue, nginxConfig)
 		log.Info().Msg("nginx proxy enabled")
 	} else {
 		log.Info().Msg("nginx proxy disabled - routes will use host-bound ports")
@@ -223,10 +236,16 @@ func main() {
 	go historyCollector.Start(context.Background())
 
 	// Setup and start nginx reconcile loop if enabled
+	var reloadProxy rollout.ReloadFunc
 	if config.NginxProxyEnabled {
-		nginxGenerator := nginx.NewGenerator("", "")
+		nginxGenerator := nginx.NewGenerator("", config.NginxControllerAddr)
 		go nginxManager.Reconcile(context.Background(), storeInstance, nginxGenerator)
 		log.Info().Msg("nginx reconcile loop started")
+
+		// Blue/green deploys switch upstreams and need the new config applied immediately
+		reloadProxy = func(ctx context.Context) error {
+			return nginxManager.ForceReconcile(ctx, storeInstance, nginxGenerator)
+		}
 	}
 
 	// Setup license manager (for now, use a placeholder public key - should be from env or config)
@@ -241,11 +260,64 @@ func main() {
 	// Setup audit logger
 	auditLogger := audit.New(storeInstance)
 
+	// Setup deploy jobs and the watcher that rolls back failed deploys
+	serviceProber := health.NewProber(storeInstance)
+	serviceProber.SetEnvVarStore(storeInstance)
+	serviceProber.SetHealthCheckStore(storeInstance)
+	serviceProber.SetEngine(dockerEngine)
+	deployHandler := jobs.NewDeployJobHandler(dockerEngine, serviceProber, storeInstance, jobQueue)
+	if reloadProxy != nil {
+		deployHandler.SetBlueGreen(rollout.NewBlueGreen(dockerEngine, serviceProber, storeInstance, reloadProxy))
+	}
+	rollbackWatcher := jobs.NewRollbackWatcher(dockerEngine, serviceProber, storeInstance, jobQueue, auditLogger)
+	deployHandler.SetRollbackWatcher(rollbackWatcher)
+	jobQueue.RegisterHandler(jobs.JobTypeDeploy, deployHandler.Handle)
+	jobQueue.RegisterHandler(jobs.JobTypeRollbackWatch, rollbackWatcher.Handle)
+
+	// Setup volume snapshots and the scheduler that takes them
+	volumeSnapshotter := jobs.NewVolumeSnapshotter(dockerEngine, storeInstance, jobQueue, auditLogger, config.DataDir)
+	jobQueue.RegisterHandler(jobs.JobTypeVolumeSnapshot, volumeSnapshotter.HandleSnapshot)
+	jobQueue.RegisterHandler(jobs.JobTypeVolumeRestore, volumeSnapshotter.HandleRestore)
+
+	// Setup scheduled task runs and the scheduler that starts them
+	taskRunner := jobs.NewTaskRunner(dockerEngine, storeInstance, jobQueue)
+	jobQueue.RegisterHandler(jobs.JobTypeTaskRun, taskRunner.HandleRun)
+	taskRunner.RecoverRuns(ctx)
+
+	// Setup image builds; cancelling a build job interrupts docker buildx
+	if buildRunner, err := docker.NewBuildKitRunner(); err != nil {
+		log.Warn().Err(err).Msg("docker CLI not available, build jobs will fail")
+	} else {
+		buildLogDir := filepath.Join(config.DataDir, "builds")
+		if err := os.MkdirAll(buildLogDir, 0755); err != nil {
+			log.Error().Err(err).Str("dir", buildLogDir).Msg("failed to create build log directory")
+		}
+		buildHandler := jobs.NewBuildJobHandler(buildRunner, storeInstance, buildLogDir, jobQueue)
+		jobQueue.RegisterHandler(jobs.JobTypeBuild, buildHandler.Handle)
+	}
+
+	// Reload jobs interrupted by the previous shutdown, then start workers
+	if err := jobQueue.Recover(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to recover jobs")
+	}
+	jobQueue.Start()
+	defer jobQueue.Stop()
+	go volumeSnapshotter.Run(context.Background())
+	go taskRunner.Run(context.Background())
+
+	// Probe running services at the interval of their health checks
+	healthMonitor := health.NewMonitor(storeInstance, serviceProber, store.DefaultHealthCheckInterval*time.Second)
+	healthMonitor.Start()
+	defer healthMonitor.Stop()
+
 	// Setup webhook handlers
 	webhookSecret := os.Getenv("WEBHOOK_SECRET") // Optional webhook HMAC secret
 	githubAppWebhookSecret := config.GitHubAppWebhookSecret
 	webhookHandlers := api.NewWebhookHandlers(storeInstance, auditLogger, webhookSecret, githubAppWebhookSecret)
 
+	// Setup CI/CD handlers, which read jobs back from the store after a restart
+	cicdHandlers := api.NewCICDHandlers(storeInstance, storeInstance, storeInstance, storeInstance, jobQueue, webhookSecret)
+
 	// Setup GitHub App handlers
 	githubHandlers, err := api.NewGitHubHandlers(storeInstance, config)
 	if err != nil {
@@ -292,7 +364,7 @@ func main() {
 		storeInstance, // EnvVarStore
 		dockerEngine,
 		nginxConfig,
-		nil,          // cicdHandlers
+		cicdHandlers,
 		certHandlers, // certHandlers
 		metricsHandlers,
 		webhookHandlers,
@@ -312,6 +384,17 @@ func main() {
 		searchHandlers,
 		helpHandlers,
 	)
+	if reloadProxy != nil {
+		handlers.SetProxyReloader(reloadProxy)
+	}
+	handlers.SetRollbackWatcher(rollbackWatcher)
+	handlers.SetVolumeSnapshotter(volumeSnapshotter)
+	handlers.SetTaskRunner(taskRunner)
+
+	// A lockdown must outlive a restart during an incident
+	if err := handlers.RestoreLockdown(context.Background()); err != nil {
+		log.Error().Err(err).Msg("failed to restore system lockdown")
+	}
 
 	// Setup web handlers
 	var webHandlers *web.WebHandlers = nil
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/GLINCKER/glinrdock/internal/api/middleware"
+	"github.com/GLINCKER/glinrdock/internal/auth"
+	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
@@ -143,6 +145,29 @@ func (h *Handlers) AuthInfoHandler(c *gin.Context) {
 	})
 }
 
+// ForwardAuthHandler answers the auth_request subrequests nginx makes for
+// routes with forward auth. It runs after the authentication middleware, so
+// the request carried a valid session or token; the role query parameter is
+// the least role the route requires.
+func (h *Handlers) ForwardAuthHandler(c *gin.Context) {
+	role := c.DefaultQuery("role", store.RoleViewer)
+	if !store.IsRoleValid(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role: must be one of admin, deployer, viewer"})
+		return
+	}
+	if !auth.HasRole(c, role) {
+		log.Debug().
+			Str("required_role", role).
+			Str("uri", c.GetHeader("X-Original-URI")).
+			Str("client_ip", getClientIPFromHeader(c)).
+			Msg("forward auth denied")
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // generateSessionID creates a cryptographically secure session ID
 func generateSessionID() (string, error) {
 	bytes := make([]byte, 32)
@@ -91,7 +91,14 @@ func (a *BuildQueueAdapter) EnqueueBuild(ctx context.Context, buildRequest *Buil
 	}
 
 	// Queue the build job
-	job := a.jobQueue.Enqueue(jobs.JobTypeBuild, jobData)
+	job, err := a.jobQueue.Enqueue(jobs.JobTypeBuild, jobData)
+	if err != nil {
+		finishedAt := time.Now().Unix()
+		if statusErr := a.buildStore.UpdateBuildStatus(ctx, build.ID, "failed", nil, nil, &finishedAt); statusErr != nil {
+			log.Error().Err(statusErr).Int64("build_id", build.ID).Msg("failed to mark unqueued build failed")
+		}
+		return fmt.Errorf("failed to queue build: %w", err)
+	}
 
 	log.Info().
 		Int64("build_id", build.ID).
@@ -0,0 +1,495 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/bundle"
+	"github.com/GLINCKER/glinrdock/internal/crypto"
+	"github.com/GLINCKER/glinrdock/internal/manifest"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// BundlePassphraseHeader carries the passphrase that seals the secrets of a project bundle
+const BundlePassphraseHeader = "X-Bundle-Passphrase"
+
+// maxBundleSize bounds the project bundles accepted for import
+const maxBundleSize = 8 << 20
+
+// BundleConflict is something on this instance that prevents importing a bundle
+type BundleConflict struct {
+	Kind    string `json:"kind"` // project|route|secret
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// BundleImportResponse reports what an import created and how the IDs of the
+// exporting instance map to the new ones
+type BundleImportResponse struct {
+	Project  store.Project              `json:"project"`
+	Services []store.Service            `json:"services"`
+	Routes   []store.Route              `json:"routes"`
+	IDMap    map[string]map[int64]int64 `json:"id_map"` // projects, services and routes: old ID to new ID
+	Warnings []string                   `json:"warnings"`
+}
+
+// ExportProject packs a project into a bundle archive. Secret environment variables
+// are re-encrypted with the passphrase from the X-Bundle-Passphrase header.
+func (h *Handlers) ExportProject(c *gin.Context) {
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	project, err := h.serviceStore.GetProject(ctx, projectID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("project not found: %d", projectID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get project"})
+		}
+		return
+	}
+
+	state, err := h.loadProjectState(ctx, project)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to load project state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load project state"})
+		return
+	}
+
+	b, err := h.buildBundle(ctx, state)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to export project")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.Write(&buf, b, c.GetHeader(BundlePassphraseHeader)); err != nil {
+		if errors.Is(err, bundle.ErrPassphraseRequired) || errors.Is(err, bundle.ErrPassphraseTooShort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to write project bundle")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write project bundle"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordProjectAction(c.Request.Context(), actor, audit.ActionProjectExport, strconv.FormatInt(project.ID, 10), map[string]interface{}{
+			"project_name":   project.Name,
+			"services_count": len(state.Services),
+			"env_vars_count": len(b.EnvVars),
+			"has_secrets":    b.HasSecrets(),
+		})
+	}
+
+	filename := fmt.Sprintf("%s-%s.tar.gz", store.GenerateSlug(project.Name), b.ExportedAt.Format("2006-01-02T15-04-05"))
+	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// buildBundle collects everything a bundle carries besides the manifest
+func (h *Handlers) buildBundle(ctx context.Context, state manifest.State) (*bundle.Bundle, error) {
+	b := &bundle.Bundle{
+		Manifest:      manifest.FromState(state),
+		ExportedAt:    time.Now().UTC(),
+		ProjectID:     state.Project.ID,
+		ServiceIDs:    make(map[string]int64, len(state.Services)),
+		RouteIDs:      make(map[string]int64),
+		EnvVars:       []bundle.EnvVar{},
+		Registries:    []bundle.RegistryRef{},
+		ExternalLinks: []bundle.ExternalLink{},
+	}
+
+	var masterKey []byte
+	projectNames := make(map[int64]string)
+	for _, service := range state.Services {
+		b.ServiceIDs[service.Name] = service.ID
+		for _, route := range state.Routes[service.ID] {
+			b.RouteIDs[route.Name()] = route.ID
+		}
+
+		if h.envVarStore != nil {
+			envVars, err := h.envVarStore.ListEnvVars(ctx, service.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list environment variables of service %s: %w", service.Name, err)
+			}
+			for _, envVar := range envVars {
+				exported := bundle.EnvVar{Service: service.Name, Key: envVar.Key, Value: envVar.Value, Secret: envVar.IsSecret}
+				if envVar.IsSecret {
+					if masterKey == nil {
+						if masterKey, err = crypto.LoadMasterKeyFromEnv(); err != nil {
+							return nil, fmt.Errorf("cannot export secret environment variables: %w", err)
+						}
+					}
+					plaintext, err := crypto.Decrypt(masterKey, envVar.Nonce, envVar.Ciphertext)
+					if err != nil {
+						return nil, fmt.Errorf("failed to decrypt %s of service %s", envVar.Key, service.Name)
+					}
+					exported.Value = string(plaintext)
+				}
+				b.EnvVars = append(b.EnvVars, exported)
+			}
+		}
+
+		if service.RegistryID != nil && h.registryStore != nil {
+			registry, err := h.registryStore.GetRegistry(*service.RegistryID)
+			if err != nil {
+				log.Warn().Err(err).Str("registry_id", *service.RegistryID).Str("service", service.Name).Msg("registry of exported service not found")
+			} else {
+				b.Registries = append(b.Registries, bundle.RegistryRef{Service: service.Name, Name: registry.Name, Type: registry.Type, Server: registry.Server})
+			}
+		}
+
+		for _, link := range state.Links[service.ID] {
+			if link.ProjectID == state.Project.ID {
+				continue
+			}
+			if _, ok := projectNames[link.ProjectID]; !ok {
+				project, err := h.serviceStore.GetProject(ctx, link.ProjectID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get project of linked service %s: %w", link.Name, err)
+				}
+				projectNames[link.ProjectID] = project.Name
+			}
+			b.ExternalLinks = append(b.ExternalLinks, bundle.ExternalLink{Service: service.Name, Project: projectNames[link.ProjectID], Target: link.Name})
+		}
+	}
+
+	return b, nil
+}
+
+// ImportProjectBundle creates a project from a bundle archive sent as the request body.
+// Without apply=true it only reports the changes and conflicts; with it, conflicts
+// abort the import and anything created is removed again on failure.
+func (h *Handlers) ImportProjectBundle(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBundleSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "bundle too large: max 8MB"})
+		return
+	}
+
+	b, err := bundle.Read(bytes.NewReader(data), c.GetHeader(BundlePassphraseHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The project is always created; the name can be overridden to import next to an existing copy
+	if b.Manifest.Project == nil {
+		b.Manifest.Project = &manifest.Project{}
+	}
+	if name := c.Query("name"); name != "" {
+		b.Manifest.Project.Name = name
+	}
+	if b.Manifest.Project.Name == "" || len(b.Manifest.Project.Name) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project name: must be 1-64 characters"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	conflicts, warnings, resolved, err := h.checkBundle(ctx, b)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check project bundle")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check project bundle"})
+		return
+	}
+
+	draft := store.Project{
+		Name:        b.Manifest.Project.Name,
+		RepoURL:     b.Manifest.Project.RepoURL,
+		Branch:      b.Manifest.Project.Branch,
+		ImageTarget: b.Manifest.Project.ImageTarget,
+	}
+	plan := manifest.Diff(b.Manifest, manifest.State{Project: draft})
+
+	if c.Query("apply") != "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":   true,
+			"project":   draft.Name,
+			"changes":   plan.Changes,
+			"conflicts": conflicts,
+			"warnings":  warnings,
+		})
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "bundle conflicts with this instance", "conflicts": conflicts, "warnings": warnings})
+		return
+	}
+
+	// Pulling dominates, allow each service as long as a single CreateService
+	importCtx, importCancel := context.WithTimeout(c.Request.Context(), time.Duration(len(b.Manifest.Services)+1)*60*time.Second)
+	defer importCancel()
+
+	changes := plan.ChangeSet
+	for i, spec := range changes.CreateServices {
+		if registryID, ok := resolved.registries[spec.Name]; ok {
+			changes.CreateServices[i].RegistryID = &registryID
+		}
+		var registryID string
+		if changes.CreateServices[i].RegistryID != nil {
+			registryID = *changes.CreateServices[i].RegistryID
+		}
+		if err := h.dockerEngine.Pull(importCtx, spec.Image, registryID); err != nil {
+			log.Error().Err(err).Str("image", spec.Image).Msg("failed to pull image")
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to pull image %s", spec.Image)})
+			return
+		}
+	}
+	changes.Links = withExternalLinks(changes.Links, resolved.links)
+
+	project, err := h.projectStore.CreateProjectWithWebhook(importCtx, draft.Name, draft.RepoURL, optionalString(draft.Branch), draft.ImageTarget)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.applyBundle(importCtx, project, b, changes)
+	if err != nil {
+		h.rollbackProjectImport(project, result.Services)
+		log.Error().Err(err).Str("project", project.Name).Msg("bundle import failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("bundle import failed: %v", err)})
+		return
+	}
+	result.Warnings = warnings
+
+	if h.nginxConfig != nil && len(result.Routes) > 0 {
+		if err := h.nginxConfig.UpdateAndReload(importCtx); err != nil {
+			log.Error().Err(err).Msg("failed to update nginx configuration")
+			// Don't fail the request, the routes were created successfully in the database
+		}
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordProjectAction(c.Request.Context(), actor, audit.ActionProjectImport, strconv.FormatInt(project.ID, 10), map[string]interface{}{
+			"project_name":      project.Name,
+			"source":            "bundle",
+			"source_project_id": b.ProjectID,
+			"services_count":    len(result.Services),
+			"routes_count":      len(result.Routes),
+			"env_vars_count":    len(b.EnvVars),
+		})
+	}
+
+	log.Info().
+		Int64("project_id", project.ID).
+		Int64("source_project_id", b.ProjectID).
+		Int("services", len(result.Services)).
+		Msg("project bundle imported")
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// resolvedBundle holds the references of a bundle found on this instance
+type resolvedBundle struct {
+	registries map[string]string  // registry ID by service name
+	links      map[string][]int64 // IDs of linked services of other projects, by service name
+}
+
+// checkBundle looks for conflicts with this instance and resolves the registries and
+// cross-project links of a bundle by name. Unresolved references are only warnings.
+func (h *Handlers) checkBundle(ctx context.Context, b *bundle.Bundle) ([]BundleConflict, []string, resolvedBundle, error) {
+	conflicts := []BundleConflict{}
+	warnings := []string{}
+	resolved := resolvedBundle{registries: make(map[string]string), links: make(map[string][]int64)}
+
+	projects, err := h.projectStore.ListProjects(ctx)
+	if err != nil {
+		return nil, nil, resolved, fmt.Errorf("failed to list projects: %w", err)
+	}
+	projectIDs := make(map[string]int64, len(projects))
+	for _, project := range projects {
+		projectIDs[project.Name] = project.ID
+	}
+	if _, exists := projectIDs[b.Manifest.Project.Name]; exists {
+		conflicts = append(conflicts, BundleConflict{Kind: "project", Name: b.Manifest.Project.Name, Message: "a project with this name already exists; import with ?name= to use another name"})
+	}
+
+	routes, err := h.routeStore.GetAllRoutes(ctx)
+	if err != nil {
+		return nil, nil, resolved, fmt.Errorf("failed to list routes: %w", err)
+	}
+	routed := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		routed[route.Name()] = true
+	}
+	for _, service := range b.Manifest.Services {
+		for _, route := range service.Routes {
+			name := store.RouteName(route.Domain, route.Path, route.MatchType)
+			if routed[name] {
+				conflicts = append(conflicts, BundleConflict{Kind: "route", Name: name, Message: fmt.Sprintf("domain is already routed on this instance (service %s)", service.Name)})
+			}
+		}
+	}
+
+	if b.HasSecrets() {
+		if _, err := crypto.LoadMasterKeyFromEnv(); err != nil {
+			conflicts = append(conflicts, BundleConflict{Kind: "secret", Name: "GLINRDOCK_SECRET", Message: fmt.Sprintf("secret environment variables cannot be stored: %v", err)})
+		}
+	}
+
+	if len(b.Registries) > 0 {
+		var registries []*store.RegistryPublic
+		if h.registryStore != nil {
+			if registries, err = h.registryStore.ListRegistries(); err != nil {
+				return nil, nil, resolved, fmt.Errorf("failed to list registries: %w", err)
+			}
+		}
+		for _, ref := range b.Registries {
+			found := false
+			for _, registry := range registries {
+				if registry.Name == ref.Name {
+					resolved.registries[ref.Service] = registry.ID
+					found = true
+					break
+				}
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("service %s: registry %s (%s) not found, its image is pulled without credentials", ref.Service, ref.Name, ref.Server))
+			}
+		}
+	}
+
+	for _, link := range b.ExternalLinks {
+		projectID, exists := projectIDs[link.Project]
+		if !exists {
+			warnings = append(warnings, fmt.Sprintf("service %s: linked project %s not found, link to %s skipped", link.Service, link.Project, link.Target))
+			continue
+		}
+		services, err := h.serviceStore.ListServices(ctx, projectID)
+		if err != nil {
+			return nil, nil, resolved, fmt.Errorf("failed to list services of project %s: %w", link.Project, err)
+		}
+		found := false
+		for _, service := range services {
+			if service.Name == link.Target {
+				resolved.links[link.Service] = append(resolved.links[link.Service], service.ID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			warnings = append(warnings, fmt.Sprintf("service %s: linked service %s/%s not found, link skipped", link.Service, link.Project, link.Target))
+		}
+	}
+
+	return conflicts, warnings, resolved, nil
+}
+
+// applyBundle stores the services, routes, links and environment variables of a bundle
+// in a new project and creates the containers. The returned response lists what was
+// created, even on error, so it can be rolled back.
+func (h *Handlers) applyBundle(ctx context.Context, project store.Project, b *bundle.Bundle, changes store.ProjectChangeSet) (*BundleImportResponse, error) {
+	result := &BundleImportResponse{
+		Project:  project,
+		Services: []store.Service{},
+		Routes:   []store.Route{},
+		IDMap: map[string]map[int64]int64{
+			"projects": {b.ProjectID: project.ID},
+			"services": {},
+			"routes":   {},
+		},
+	}
+
+	services, err := h.store.ApplyProjectChangeSet(ctx, project.ID, changes)
+	if err != nil {
+		return result, err
+	}
+
+	envVars := make(map[string][]store.EnvVarUpdate)
+	var masterKey []byte
+	for _, envVar := range b.EnvVars {
+		update := store.EnvVarUpdate{Key: envVar.Key, IsSecret: envVar.Secret}
+		if envVar.Secret {
+			if masterKey == nil {
+				if masterKey, err = crypto.LoadMasterKeyFromEnv(); err != nil {
+					return result, err
+				}
+			}
+			if update.Nonce, update.Ciphertext, err = crypto.Encrypt(masterKey, []byte(envVar.Value)); err != nil {
+				return result, fmt.Errorf("failed to encrypt %s of service %s: %w", envVar.Key, envVar.Service, err)
+			}
+		} else {
+			update.Value = envVar.Value
+		}
+		envVars[envVar.Service] = append(envVars[envVar.Service], update)
+	}
+
+	// Ensure Docker network exists for the project
+	if h.networkManager != nil && project.NetworkName != nil {
+		if _, _, err := h.networkManager.EnsureProjectNetwork(ctx, *project.NetworkName, project.ID); err != nil {
+			log.Warn().Err(err).Int64("project_id", project.ID).Msg("failed to ensure project network")
+		}
+	}
+
+	for _, service := range services {
+		result.Services = append(result.Services, service)
+		if oldID, ok := b.ServiceIDs[service.Name]; ok {
+			result.IDMap["services"][oldID] = service.ID
+		}
+
+		if updates := envVars[service.Name]; len(updates) > 0 && h.envVarStore != nil {
+			if err := h.envVarStore.BulkSetEnvVars(ctx, service.ID, updates); err != nil {
+				return result, fmt.Errorf("service %s: failed to set environment variables: %w", service.Name, err)
+			}
+		}
+
+		routes, err := h.routeStore.ListRoutes(ctx, service.ID)
+		if err != nil {
+			return result, fmt.Errorf("service %s: failed to list routes: %w", service.Name, err)
+		}
+		for _, route := range routes {
+			result.Routes = append(result.Routes, route)
+			if oldID, ok := b.RouteIDs[route.Name()]; ok {
+				result.IDMap["routes"][oldID] = route.ID
+			}
+		}
+
+		containerID, _, err := h.createServiceContainer(ctx, project, service)
+		if err != nil {
+			return result, fmt.Errorf("service %s: failed to create container: %w", service.Name, err)
+		}
+		result.Services[len(result.Services)-1].ContainerID = &containerID
+	}
+
+	return result, nil
+}
+
+// withExternalLinks adds links to services of other projects to the link sets of a change set
+func withExternalLinks(sets []store.ServiceLinkSet, external map[string][]int64) []store.ServiceLinkSet {
+	for i := range sets {
+		sets[i].TargetIDs = append(sets[i].TargetIDs, external[sets[i].Service]...)
+		delete(external, sets[i].Service)
+	}
+	for service, targetIDs := range external {
+		sets = append(sets, store.ServiceLinkSet{Service: service, TargetIDs: targetIDs})
+	}
+	return sets
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -65,7 +65,12 @@ func (h *CertHandlers) IssueCert(c *gin.Context) {
 		"domain": spec.Domain,
 		"email":  spec.Email,
 	}
-	job := h.jobQueue.Enqueue("cert_issue", jobData)
+	job, err := h.jobQueue.Enqueue("cert_issue", jobData)
+	if err != nil {
+		log.Error().Err(err).Str("domain", spec.Domain).Msg("failed to queue certificate issuance")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue certificate issuance"})
+		return
+	}
 
 	// Get cert record to return current status
 	cert, err := h.certStore.GetCert(c.Request.Context(), spec.Domain)
@@ -124,7 +129,12 @@ func (h *CertHandlers) RenewCert(c *gin.Context) {
 	jobData := map[string]interface{}{
 		"domain": domain,
 	}
-	job := h.jobQueue.Enqueue("cert_renew", jobData)
+	job, err := h.jobQueue.Enqueue("cert_renew", jobData)
+	if err != nil {
+		log.Error().Err(err).Str("domain", domain).Msg("failed to queue certificate renewal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue certificate renewal"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"domain": domain,
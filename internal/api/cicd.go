@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -168,7 +169,12 @@ func (h *CICDHandlers) TriggerBuild(c *gin.Context) {
 	jobData := map[string]interface{}{
 		"build": build,
 	}
-	job := h.jobQueue.Enqueue(jobs.JobTypeBuild, jobData)
+	job, err := h.jobQueue.Enqueue(jobs.JobTypeBuild, jobData)
+	if err != nil {
+		h.failUnqueuedBuild(c.Request.Context(), build.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue build"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"build_id": build.ID,
@@ -252,7 +258,12 @@ func (h *CICDHandlers) TriggerDeployment(c *gin.Context) {
 	jobData := map[string]interface{}{
 		"deployment": deployment,
 	}
-	job := h.jobQueue.Enqueue(jobs.JobTypeDeploy, jobData)
+	job, err := h.jobQueue.Enqueue(jobs.JobTypeDeploy, jobData)
+	if err != nil {
+		h.failUnqueuedDeployment(c.Request.Context(), deployment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue deployment"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"deployment_id": deployment.ID,
@@ -357,7 +368,12 @@ func (h *CICDHandlers) RollbackDeployment(c *gin.Context) {
 	jobData := map[string]interface{}{
 		"deployment": deployment,
 	}
-	job := h.jobQueue.Enqueue(jobs.JobTypeDeploy, jobData)
+	job, err := h.jobQueue.Enqueue(jobs.JobTypeDeploy, jobData)
+	if err != nil {
+		h.failUnqueuedDeployment(c.Request.Context(), deployment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue rollback deployment"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"deployment_id":      deployment.ID,
@@ -381,6 +397,33 @@ func (h *CICDHandlers) GetJob(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
+// CancelJob cancels a queued or running job
+func (h *CICDHandlers) CancelJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	job, err := h.jobQueue.Cancel(jobID)
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	case errors.Is(err, jobs.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "job already finished", "status": job.Status})
+		return
+	case err != nil:
+		log.Error().Err(err).Str("job_id", jobID).Msg("failed to cancel job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel job"})
+		return
+	}
+
+	// A running job is cancelled asynchronously once its handler returns
+	if job.Status == jobs.JobStatusRunning {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
 // Helper methods
 
 func (h *CICDHandlers) findServicesForRepo(ctx context.Context, repoURL string) ([]*store.Service, error) {
@@ -411,11 +454,30 @@ func (h *CICDHandlers) triggerBuildForService(ctx context.Context, service *stor
 	jobData := map[string]interface{}{
 		"build": build,
 	}
-	h.jobQueue.Enqueue(jobs.JobTypeBuild, jobData)
+	if _, err := h.jobQueue.Enqueue(jobs.JobTypeBuild, jobData); err != nil {
+		h.failUnqueuedBuild(ctx, build.ID)
+		return 0, fmt.Errorf("failed to queue build: %w", err)
+	}
 
 	return build.ID, nil
 }
 
+// failUnqueuedBuild marks a build failed whose job could not be queued, so it is not left queued forever
+func (h *CICDHandlers) failUnqueuedBuild(ctx context.Context, buildID int64) {
+	finishedAt := time.Now().Unix()
+	if err := h.buildStore.UpdateBuildStatus(ctx, buildID, "failed", nil, nil, &finishedAt); err != nil {
+		log.Error().Err(err).Int64("build_id", buildID).Msg("failed to mark unqueued build failed")
+	}
+}
+
+// failUnqueuedDeployment marks a deployment failed whose job could not be queued
+func (h *CICDHandlers) failUnqueuedDeployment(ctx context.Context, deploymentID int64, queueErr error) {
+	reason := queueErr.Error()
+	if err := h.deploymentStore.UpdateDeploymentStatus(ctx, deploymentID, "failed", &reason); err != nil {
+		log.Error().Err(err).Int64("deployment_id", deploymentID).Msg("failed to mark unqueued deployment failed")
+	}
+}
+
 func verifyWebhookSignature(payload []byte, signature, secret string) bool {
 	if !strings.HasPrefix(signature, "sha256=") {
 		return false
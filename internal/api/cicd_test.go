@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/jobs"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getJob(t *testing.T, handlers *CICDHandlers, id string) (int, jobs.Job) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/v1/cicd/jobs/:id", handlers.GetJob)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/cicd/jobs/"+id, nil))
+
+	var job jobs.Job
+	if recorder.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &job))
+	}
+	return recorder.Code, job
+}
+
+func TestCICDHandlers_GetJobAfterRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	st, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Migrate(ctx))
+
+	// First run: one job finishes, another is still queued at shutdown
+	before := jobs.NewPersistentQueue(1, st)
+	before.RegisterHandler(jobs.JobTypeDeploy, func(ctx context.Context, job *jobs.Job) error { return nil })
+	before.Start()
+	finished, err := before.Enqueue(jobs.JobTypeDeploy, map[string]interface{}{"service_id": 1})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		job, ok := before.GetJob(finished.ID)
+		return ok && job.Status == jobs.JobStatusSuccess
+	}, 2*time.Second, 10*time.Millisecond)
+	before.Stop()
+	queued, err := before.Enqueue(jobs.JobTypeBuild, map[string]interface{}{"build": map[string]interface{}{"id": 1}})
+	require.NoError(t, err)
+
+	// After the restart the handler serves both from the new queue
+	after := jobs.NewPersistentQueue(1, st)
+	require.NoError(t, after.Recover(ctx))
+	handlers := NewCICDHandlers(st, st, st, st, after, "")
+
+	code, job := getJob(t, handlers, finished.ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, jobs.JobStatusSuccess, job.Status)
+	assert.Equal(t, jobs.JobTypeDeploy, job.Type)
+
+	code, job = getJob(t, handlers, queued.ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, jobs.JobStatusQueued, job.Status)
+	assert.Equal(t, jobs.JobTypeBuild, job.Type)
+
+	code, _ = getJob(t, handlers, "missing")
+	assert.Equal(t, http.StatusNotFound, code)
+}
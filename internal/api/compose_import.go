@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/compose"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// maxComposeFileSize bounds the compose files accepted for import
+const maxComposeFileSize = 1 << 20
+
+// ComposeImportRequest represents the request to import a docker-compose file as a new project
+type ComposeImportRequest struct {
+	Name    string `json:"name"`                       // project name, defaults to the file's top-level name
+	Compose string `json:"compose" binding:"required"` // docker-compose YAML
+	Apply   bool   `json:"apply"`                      // create the project, otherwise only report the plan
+}
+
+// ComposeImportResponse reports what an import created
+type ComposeImportResponse struct {
+	Project     store.Project   `json:"project"`
+	Services    []store.Service `json:"services"`
+	Routes      []store.Route   `json:"routes"`
+	Unsupported []string        `json:"unsupported"`
+	Warnings    []string        `json:"warnings"`
+}
+
+// ImportCompose converts a docker-compose file into a project. Without apply it
+// only returns the plan, so the unsupported keys can be reviewed before anything
+// is created.
+func (h *Handlers) ImportCompose(c *gin.Context) {
+	var req ComposeImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Compose) > maxComposeFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "compose file too large: max 1MB"})
+		return
+	}
+
+	plan, err := compose.Parse([]byte(req.Compose), req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.Apply {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run": true,
+			"plan":    plan,
+		})
+		return
+	}
+
+	// Pulling dominates, allow each service as long as a single CreateService
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(len(plan.Services))*60*time.Second)
+	defer cancel()
+
+	// Pull every image before creating anything so a bad image leaves nothing behind
+	for _, service := range plan.Services {
+		if err := h.dockerEngine.Pull(ctx, service.Spec.Image, ""); err != nil {
+			log.Error().Err(err).Str("image", service.Spec.Image).Msg("failed to pull image")
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to pull image %s", service.Spec.Image)})
+			return
+		}
+	}
+
+	project, err := h.projectStore.CreateProject(ctx, plan.Project)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.applyComposePlan(ctx, project, plan)
+	if err != nil {
+		h.rollbackProjectImport(project, result.Services)
+		log.Error().Err(err).Str("project", plan.Project).Msg("compose import failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("compose import failed: %v", err)})
+		return
+	}
+
+	// Regenerate and reload nginx configuration for the imported routes
+	if h.nginxConfig != nil && len(result.Routes) > 0 {
+		if err := h.nginxConfig.UpdateAndReload(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to update nginx configuration")
+			// Don't fail the request, the routes were created successfully in the database
+		}
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		serviceNames := make([]string, 0, len(result.Services))
+		for _, service := range result.Services {
+			serviceNames = append(serviceNames, service.Name)
+		}
+		h.auditLogger.RecordProjectAction(c.Request.Context(), actor, audit.ActionProjectImport, strconv.FormatInt(project.ID, 10), map[string]interface{}{
+			"project_name": project.Name,
+			"source":       "compose",
+			"services":     serviceNames,
+			"routes_count": len(result.Routes),
+			"unsupported":  plan.Unsupported,
+		})
+	}
+
+	log.Info().
+		Int64("project_id", project.ID).
+		Int("services", len(result.Services)).
+		Int("routes", len(result.Routes)).
+		Msg("compose file imported")
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// applyComposePlan creates the services, links and routes of a plan inside a new
+// project. The returned response lists what was created, even on error, so it can be
+// rolled back.
+func (h *Handlers) applyComposePlan(ctx context.Context, project store.Project, plan *compose.Plan) (*ComposeImportResponse, error) {
+	result := &ComposeImportResponse{
+		Project:     project,
+		Services:    []store.Service{},
+		Routes:      []store.Route{},
+		Unsupported: plan.Unsupported,
+		Warnings:    plan.Warnings,
+	}
+
+	// Ensure Docker network exists for the project
+	if h.networkManager != nil && project.NetworkName != nil {
+		if _, _, err := h.networkManager.EnsureProjectNetwork(ctx, *project.NetworkName, project.ID); err != nil {
+			log.Warn().Err(err).Int64("project_id", project.ID).Msg("failed to ensure project network")
+		}
+	}
+
+	// Plans list dependencies first, so link targets always exist by the time they are needed
+	serviceIDs := make(map[string]int64, len(plan.Services))
+	for _, planned := range plan.Services {
+		service, err := h.serviceStore.CreateService(ctx, project.ID, planned.Spec)
+		if err != nil {
+			return result, fmt.Errorf("service %s: %w", planned.Source, err)
+		}
+		result.Services = append(result.Services, service)
+		serviceIDs[service.Name] = service.ID
+
+		containerID, _, err := h.createServiceContainer(ctx, project, service)
+		if err != nil {
+			return result, fmt.Errorf("service %s: failed to create container: %w", planned.Source, err)
+		}
+		result.Services[len(result.Services)-1].ContainerID = &containerID
+
+		if len(planned.DependsOn) > 0 {
+			targets := make([]int64, 0, len(planned.DependsOn))
+			for _, dependency := range planned.DependsOn {
+				targets = append(targets, serviceIDs[dependency])
+			}
+			if err := h.serviceStore.CreateServiceLinks(ctx, service.ID, targets); err != nil {
+				return result, fmt.Errorf("service %s: failed to link dependencies: %w", planned.Source, err)
+			}
+		}
+
+		for _, spec := range planned.Routes {
+			route, err := h.routeStore.CreateRoute(ctx, service.ID, spec)
+			if err != nil {
+				return result, fmt.Errorf("service %s: failed to create route %s: %w", planned.Source, spec.Domain, err)
+			}
+			result.Routes = append(result.Routes, route)
+		}
+	}
+
+	return result, nil
+}
+
+// rollbackProjectImport removes the containers of a failed import and deletes its
+// project, which cascades to the services, links and routes
+func (h *Handlers) rollbackProjectImport(project store.Project, services []store.Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, service := range services {
+		if service.ContainerID == nil {
+			continue
+		}
+		if err := h.dockerEngine.Remove(ctx, *service.ContainerID); err != nil {
+			log.Warn().Err(err).Str("container_id", *service.ContainerID).Msg("failed to remove container of failed import")
+		}
+	}
+
+	if err := h.projectStore.DeleteProject(ctx, project.ID); err != nil {
+		log.Error().Err(err).Int64("project_id", project.ID).Msg("failed to delete project of failed import")
+	}
+}
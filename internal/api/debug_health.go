@@ -35,7 +35,7 @@ func (h *Handlers) DebugServiceHealth(c *gin.Context) {
 	}
 
 	// Create a prober for testing
-	prober := health.NewProber(h.serviceStore)
+	prober := h.getHealthProber()
 
 	// Generate health probe URL
 	healthURL := service.GetHealthProbeURL(routes)
@@ -58,8 +58,10 @@ func (h *Handlers) DebugServiceHealth(c *gin.Context) {
 		"ports":             service.Ports,
 		"routes":            routes,
 		"probe_result": map[string]interface{}{
-			"status": probeResult.Status,
-			"error":  nil,
+			"status":         probeResult.Status,
+			"error":          nil,
+			"address":        probeResult.Address,
+			"protocol_error": probeResult.ProtocolError,
 		},
 		"troubleshooting": generateTroubleshootingTips(service, routes, healthURL, probeResult),
 	}
@@ -116,7 +118,9 @@ func generateTroubleshootingTips(service store.Service, routes []store.Route, he
 	if result.Error != nil {
 		tips = append(tips, "❌ Health probe failed: "+result.Error.Error())
 
-		if len(service.Ports) > 0 {
+		if result.Address != "" {
+			tips = append(tips, "🔗 Database probes connect over the project network at "+result.Address)
+		} else if len(service.Ports) > 0 {
 			port := service.Ports[0].Host
 			tips = append(tips, "💡 Try testing manually: curl -v http://localhost:"+strconv.Itoa(port)+"/health")
 		}
@@ -130,11 +134,11 @@ func generateTroubleshootingTips(service store.Service, routes []store.Route, he
 	case store.HealthCheckTCP:
 		tips = append(tips, "🔌 TCP health check - checking if port accepts connections")
 	case store.HealthCheckPostgres:
-		tips = append(tips, "🐘 PostgreSQL health check - checking database connectivity")
+		tips = append(tips, "🐘 PostgreSQL health check - logging in with POSTGRES_USER/POSTGRES_PASSWORD and running SELECT 1")
 	case store.HealthCheckMySQL:
-		tips = append(tips, "🐬 MySQL health check - checking database connectivity")
+		tips = append(tips, "🐬 MySQL health check - logging in with MYSQL_USER/MYSQL_PASSWORD (or root) and sending COM_PING")
 	case store.HealthCheckRedis:
-		tips = append(tips, "🔴 Redis health check - checking cache connectivity")
+		tips = append(tips, "🔴 Redis health check - authenticating with REDIS_PASSWORD when set and sending PING")
 	}
 
 	if len(tips) == 0 {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -93,16 +94,28 @@ func (h *EnhancedRouteHandlers) CreateTLSRoute(c *gin.Context) {
 	defer cancel()
 
 	// Verify service exists
-	_, err = h.store.GetService(ctx, serviceID)
+	service, err := h.store.GetService(ctx, serviceID)
 	if err != nil {
 		log.Warn().Err(err).Int64("service_id", serviceID).Msg("service not found")
 		c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
 		return
 	}
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks cannot have routes"})
+		return
+	}
 
 	// Create the route first
 	route, err := h.store.CreateRoute(ctx, serviceID, req.RouteSpec)
 	if err != nil {
+		if errors.Is(err, store.ErrRouteConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, store.ErrRouteKind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to create route")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create route"})
 		return
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// maxTerminalDimension bounds the rows and columns a terminal may be resized to
+const maxTerminalDimension = 1000
+
+// defaultExecCommand is the shell started when the client does not ask for a command
+var defaultExecCommand = []string{"/bin/sh"}
+
+// Types of ExecMessage
+const (
+	ExecMessageInput  = "input"
+	ExecMessageResize = "resize"
+	ExecMessageExit   = "exit"
+)
+
+// ExecMessage is a control message of a web terminal session. The client sends
+// input and resize messages; the server sends an exit message when the process
+// ends. Terminal output is sent as binary messages.
+type ExecMessage struct {
+	Type     string `json:"type"`
+	Data     string `json:"data,omitempty"` // input
+	Rows     uint   `json:"rows,omitempty"` // resize
+	Cols     uint   `json:"cols,omitempty"` // resize
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+// parseTerminalSize validates the rows and cols of a terminal
+func parseTerminalSize(rows, cols uint) (dockerx.TerminalSize, error) {
+	if rows == 0 || cols == 0 || rows > maxTerminalDimension || cols > maxTerminalDimension {
+		return dockerx.TerminalSize{}, fmt.Errorf("rows and cols must be between 1 and %d", maxTerminalDimension)
+	}
+	return dockerx.TerminalSize{Rows: rows, Cols: cols}, nil
+}
+
+// ServiceExecHandler handles GET /v1/services/:id/exec (WebSocket). It opens an
+// interactive terminal in the service's running container through docker exec.
+// The command comes from repeated command query parameters and defaults to
+// /bin/sh; rows and cols set the initial terminal size.
+func (h *Handlers) ServiceExecHandler(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required"})
+		return
+	}
+
+	command := c.QueryArray("command")
+	if len(command) == 0 {
+		command = defaultExecCommand
+	}
+
+	var size dockerx.TerminalSize
+	if c.Query("rows") != "" || c.Query("cols") != "" {
+		rows, rowsErr := strconv.ParseUint(c.Query("rows"), 10, 32)
+		cols, colsErr := strconv.ParseUint(c.Query("cols"), 10, 32)
+		if rowsErr == nil && colsErr == nil {
+			size, err = parseTerminalSize(uint(rows), uint(cols))
+		}
+		if rowsErr != nil || colsErr != nil || err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rows and cols must be between 1 and %d", maxTerminalDimension)})
+			return
+		}
+	}
+
+	service, err := h.serviceStore.GetService(c.Request.Context(), serviceID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", serviceID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return
+	}
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks have no long-running container"})
+		return
+	}
+
+	containerID := ""
+	if service.ContainerID != nil && *service.ContainerID != "" {
+		containerID = *service.ContainerID
+	} else if containerID, err = h.discoverContainerByServiceID(c.Request.Context(), serviceID); err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to discover container")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not available"})
+		return
+	}
+
+	session, err := h.dockerEngine.Exec(c.Request.Context(), containerID, command, size)
+	if err != nil {
+		log.Error().Err(err).Str("container_id", containerID).Msg("failed to start exec session")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failed to start terminal, is the service running?"})
+		return
+	}
+	defer session.Stream.Close()
+
+	conn, err := WebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to upgrade to websocket")
+		return
+	}
+	defer conn.Close()
+
+	actor := audit.GetActorFromContext(c.Request.Context())
+	startedAt := time.Now()
+	if h.auditLogger != nil {
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceExecStart, strconv.FormatInt(serviceID, 10), map[string]interface{}{
+			"service_name": service.Name,
+			"session_id":   session.ID,
+			"container_id": containerID,
+			"command":      command,
+		})
+	}
+	log.Info().Int64("service_id", serviceID).Str("session_id", session.ID).Str("actor", actor).Msg("terminal session started")
+
+	// Terminal output goes to the client until the process exits
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buffer := make([]byte, 4096)
+		for {
+			n, err := session.Stream.Read(buffer)
+			if n > 0 {
+				if err := conn.WriteMessage(websocket.BinaryMessage, buffer[:n]); err != nil {
+					log.Debug().Err(err).Msg("error writing terminal output to websocket")
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					log.Debug().Err(err).Msg("terminal output stream ended")
+				}
+				return
+			}
+		}
+	}()
+
+	// Client messages type into the terminal or resize it until the client goes away
+	clientDone := make(chan struct{})
+	go func() {
+		defer close(clientDone)
+		for {
+			messageType, payload, err := conn.ReadMessage()
+			if err != nil {
+				log.Debug().Err(err).Msg("terminal websocket closed")
+				return
+			}
+			if err := h.handleExecMessage(session, messageType, payload); err != nil {
+				log.Debug().Err(err).Str("session_id", session.ID).Msg("invalid terminal message")
+			}
+		}
+	}()
+
+	reason := "exited"
+	select {
+	case <-outputDone:
+	case <-clientDone:
+		reason = "disconnected"
+		// Closing the stream closes the terminal's input, which ends the shell
+		session.Stream.Close()
+		<-outputDone
+	}
+
+	var exitCode *int
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if code, err := h.dockerEngine.ExecExitCode(ctx, session.ID); err == nil {
+		exitCode = &code
+	}
+
+	if reason == "exited" {
+		if err := conn.WriteJSON(ExecMessage{Type: ExecMessageExit, ExitCode: exitCode}); err != nil {
+			log.Debug().Err(err).Msg("failed to send terminal exit")
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
+
+	duration := time.Since(startedAt)
+	if h.auditLogger != nil {
+		meta := map[string]interface{}{
+			"service_name": service.Name,
+			"session_id":   session.ID,
+			"reason":       reason,
+			"duration_ms":  duration.Milliseconds(),
+		}
+		if exitCode != nil {
+			meta["exit_code"] = *exitCode
+		}
+		h.auditLogger.RecordServiceAction(context.WithoutCancel(c.Request.Context()), actor, audit.ActionServiceExecEnd, strconv.FormatInt(serviceID, 10), meta)
+	}
+	log.Info().Int64("service_id", serviceID).Str("session_id", session.ID).Str("reason", reason).Dur("duration", duration).Msg("terminal session ended")
+}
+
+// handleExecMessage applies a client message to a terminal session. Binary
+// messages are raw input; text messages are ExecMessages.
+func (h *Handlers) handleExecMessage(session *dockerx.ExecSession, messageType int, payload []byte) error {
+	if messageType == websocket.BinaryMessage {
+		_, err := session.Stream.Write(payload)
+		return err
+	}
+
+	var message ExecMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+
+	switch message.Type {
+	case ExecMessageInput:
+		_, err := io.WriteString(session.Stream, message.Data)
+		return err
+	case ExecMessageResize:
+		size, err := parseTerminalSize(message.Rows, message.Cols)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return h.dockerEngine.ResizeExec(ctx, session.ID, size)
+	default:
+		return fmt.Errorf("unknown message type %q", message.Type)
+	}
+}
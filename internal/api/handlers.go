@@ -20,9 +20,11 @@ import (
 	"github.com/GLINCKER/glinrdock/internal/docker"
 	"github.com/GLINCKER/glinrdock/internal/dockerx"
 	"github.com/GLINCKER/glinrdock/internal/events"
+	"github.com/GLINCKER/glinrdock/internal/jobs"
 	"github.com/GLINCKER/glinrdock/internal/license"
 	"github.com/GLINCKER/glinrdock/internal/plan"
 	"github.com/GLINCKER/glinrdock/internal/proxy"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/GLINCKER/glinrdock/internal/util"
 	"github.com/GLINCKER/glinrdock/internal/version"
@@ -62,6 +64,10 @@ type Handlers struct {
 	domainHandlers      *DomainHandlers
 	dnsProviderHandlers *DNSProviderHandlers
 	deploymentHandlers  *DeploymentHandlers
+	reloadProxy         rollout.ReloadFunc
+	rollbackWatcher     *jobs.RollbackWatcher
+	volumeSnapshotter   *jobs.VolumeSnapshotter
+	taskRunner          *jobs.TaskRunner
 }
 
 // NewHandlers creates new handlers with dependencies
@@ -107,6 +113,28 @@ func NewHandlers(dockerClient dockerx.Client, mainStore *store.Store, tokenStore
 	}
 }
 
+// SetProxyReloader enables blue/green deploys by giving the handlers a way to
+// regenerate the nginx configuration after switching a service's upstream
+func (h *Handlers) SetProxyReloader(reload rollout.ReloadFunc) {
+	h.reloadProxy = reload
+}
+
+// SetRollbackWatcher enables automatic rollback of image changes made through
+// the service configuration endpoint
+func (h *Handlers) SetRollbackWatcher(watcher *jobs.RollbackWatcher) {
+	h.rollbackWatcher = watcher
+}
+
+// SetVolumeSnapshotter enables volume snapshots, backup schedules and restores
+func (h *Handlers) SetVolumeSnapshotter(snapshotter *jobs.VolumeSnapshotter) {
+	h.volumeSnapshotter = snapshotter
+}
+
+// SetTaskRunner enables scheduled task services, their run history and manual runs
+func (h *Handlers) SetTaskRunner(runner *jobs.TaskRunner) {
+	h.taskRunner = runner
+}
+
 // Health returns server health status
 func (h *Handlers) Health(c *gin.Context) {
 	info := version.Get()
@@ -326,6 +354,14 @@ func (h *Handlers) GetJob(c *gin.Context) {
 	}
 }
 
+func (h *Handlers) CancelJob(c *gin.Context) {
+	if h.cicdHandlers != nil {
+		h.cicdHandlers.CancelJob(c)
+	} else {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "CI/CD not configured"})
+	}
+}
+
 // Certificate endpoints - delegate to CertHandlers
 func (h *Handlers) IssueCert(c *gin.Context) {
 	if h.certHandlers != nil {
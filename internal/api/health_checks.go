@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Health Check Definition API Handlers
+
+// defaultHealthHistoryLimit is how many probes the health history returns by default
+const defaultHealthHistoryLimit = 20
+
+// HealthCheckResponse is the health check of a service. Without one the
+// service is checked the way its image suggests.
+type HealthCheckResponse struct {
+	ServiceID    int64              `json:"service_id"`
+	Configured   bool               `json:"configured"`
+	DetectedType string             `json:"detected_type"` // check used when none is configured
+	HealthCheck  *store.HealthCheck `json:"health_check"`
+}
+
+// HealthHistoryResponse is the probe history of a service, newest first
+type HealthHistoryResponse struct {
+	ServiceID    int64                     `json:"service_id"`
+	HealthStatus string                    `json:"health_status"`
+	LastProbeAt  *time.Time                `json:"last_probe_at,omitempty"`
+	HealthCheck  *store.HealthCheck        `json:"health_check"`
+	History      []store.HealthCheckRecord `json:"history"`
+}
+
+// loadHealthCheck returns the health check a service configured, or nil
+func (h *Handlers) loadHealthCheck(ctx context.Context, serviceID int64) (*store.HealthCheck, error) {
+	check, err := h.store.GetHealthCheck(ctx, serviceID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	return check, err
+}
+
+// getHealthCheckService loads the service a health check request is for,
+// writing the error response when that fails
+func (h *Handlers) getHealthCheckService(ctx context.Context, c *gin.Context) (store.Service, bool) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return store.Service{}, false
+	}
+
+	service, err := h.serviceStore.GetService(ctx, serviceID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", serviceID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return store.Service{}, false
+	}
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "health checks are not available"})
+		return store.Service{}, false
+	}
+
+	return service, true
+}
+
+// GetServiceHealthDefinition returns the health check a service configured
+func (h *Handlers) GetServiceHealthDefinition(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.getHealthCheckService(ctx, c)
+	if !ok {
+		return
+	}
+
+	check, err := h.loadHealthCheck(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to get health check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get health check"})
+		return
+	}
+
+	c.JSON(http.StatusOK, HealthCheckResponse{
+		ServiceID:    service.ID,
+		Configured:   check != nil,
+		DetectedType: string(service.GetHealthCheckType()),
+		HealthCheck:  check,
+	})
+}
+
+// SetServiceHealthDefinition creates or replaces the health check of a service.
+// Timings, thresholds and the expected status left out get their defaults.
+func (h *Handlers) SetServiceHealthDefinition(c *gin.Context) {
+	var req store.HealthCheck
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.getHealthCheckService(ctx, c)
+	if !ok {
+		return
+	}
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks do not have health checks"})
+		return
+	}
+
+	req.ServiceID = service.ID
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.SetHealthCheck(ctx, &req); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to set health check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set health check"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceUpdate, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name":        service.Name,
+			"health_check_type":   req.Type,
+			"interval_seconds":    req.IntervalSeconds,
+			"unhealthy_threshold": req.UnhealthyThreshold,
+		})
+	}
+
+	c.JSON(http.StatusOK, HealthCheckResponse{
+		ServiceID:    service.ID,
+		Configured:   true,
+		DetectedType: string(service.GetHealthCheckType()),
+		HealthCheck:  &req,
+	})
+}
+
+// DeleteServiceHealthDefinition removes the health check of a service, so it
+// is checked the way its image suggests again
+func (h *Handlers) DeleteServiceHealthDefinition(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.getHealthCheckService(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteHealthCheck(ctx, service.ID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "service has no health check"})
+			return
+		}
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to delete health check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete health check"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceUpdate, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name":         service.Name,
+			"health_check_removed": true,
+		})
+	}
+
+	c.JSON(http.StatusOK, HealthCheckResponse{
+		ServiceID:    service.ID,
+		DetectedType: string(service.GetHealthCheckType()),
+	})
+}
+
+// GetServiceHealthHistory returns the latest health probes of a service
+func (h *Handlers) GetServiceHealthHistory(c *gin.Context) {
+	limit := defaultHealthHistoryLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > store.HealthHistoryRetention {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", store.HealthHistoryRetention)})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.getHealthCheckService(ctx, c)
+	if !ok {
+		return
+	}
+
+	check, err := h.loadHealthCheck(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to get health check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get health check"})
+		return
+	}
+
+	history, err := h.store.ListHealthHistory(ctx, service.ID, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to list health history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list health history"})
+		return
+	}
+	if history == nil {
+		history = []store.HealthCheckRecord{}
+	}
+
+	c.JSON(http.StatusOK, HealthHistoryResponse{
+		ServiceID:    service.ID,
+		HealthStatus: service.HealthStatus,
+		LastProbeAt:  service.LastProbeAt,
+		HealthCheck:  check,
+		History:      history,
+	})
+}
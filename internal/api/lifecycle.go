@@ -1,11 +1,13 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
 	"github.com/GLINCKER/glinrdock/internal/audit"
 	"github.com/GLINCKER/glinrdock/internal/auth"
+	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
@@ -29,26 +31,29 @@ func (h *Handlers) StartServiceHandler(c *gin.Context) {
 		return
 	}
 
-	// Try to discover container by service ID first, fall back to name-based discovery
-	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
-		containerID = *service.ContainerID
-	} else {
-		// Auto-discover container by service_id label for existing services
-		discoveredID, err := h.discoverContainerByServiceID(c.Request.Context(), serviceID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to discover container")
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
-			return
-		}
-		containerID = discoveredID
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks have no long-running container"})
+		return
+	}
 
-		// Update the service record with discovered container ID
-		err = h.serviceStore.UpdateServiceContainerID(c.Request.Context(), serviceID, containerID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to update container ID")
-			// Continue anyway since we have the ID
-		}
+	containerID, err := h.serviceContainerID(c.Request.Context(), service)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
+		return
+	}
+
+	containers, err := h.loadServiceContainers(c.Request.Context(), serviceID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to load init containers and sidecars")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start service"})
+		return
+	}
+
+	// Init containers run to completion before the service starts
+	if err := h.runInitContainers(c.Request.Context(), service, containers.InitContainers); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("init container failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Start the container
@@ -59,6 +64,17 @@ func (h *Handlers) StartServiceHandler(c *gin.Context) {
 		return
 	}
 
+	// Sidecars join the network namespace of the running container
+	h.ensureSidecars(c.Request.Context(), service, containerID)
+
+	// Additional replicas follow the primary container
+	replicaIDs := h.replicaContainerIDs(c.Request.Context(), serviceID)
+	for _, replicaID := range replicaIDs {
+		if err := h.dockerEngine.Start(c.Request.Context(), replicaID); err != nil {
+			log.Warn().Err(err).Str("container_id", replicaID).Msg("failed to start replica")
+		}
+	}
+
 	// Audit log service start
 	if h.auditLogger != nil {
 		actor := auth.CurrentTokenName(c)
@@ -69,6 +85,7 @@ func (h *Handlers) StartServiceHandler(c *gin.Context) {
 			"service_name": service.Name,
 			"project_id":   service.ProjectID,
 			"container_id": containerID,
+			"replicas":     len(replicaIDs) + 1,
 			"started_by":   auth.CurrentRole(c),
 		})
 	}
@@ -96,28 +113,20 @@ func (h *Handlers) StopServiceHandler(c *gin.Context) {
 		return
 	}
 
-	// Try to discover container by service ID first
-	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
-		containerID = *service.ContainerID
-	} else {
-		// Auto-discover container by service_id label for existing services
-		discoveredID, err := h.discoverContainerByServiceID(c.Request.Context(), serviceID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to discover container")
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
-			return
-		}
-		containerID = discoveredID
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks have no long-running container"})
+		return
+	}
 
-		// Update the service record with discovered container ID
-		err = h.serviceStore.UpdateServiceContainerID(c.Request.Context(), serviceID, containerID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to update container ID")
-			// Continue anyway since we have the ID
-		}
+	containerID, err := h.serviceContainerID(c.Request.Context(), service)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
+		return
 	}
 
+	// Sidecars go down first, they depend on the container's network namespace
+	h.stopSidecars(c.Request.Context(), serviceID)
+
 	// Stop the container
 	err = h.dockerEngine.Stop(c.Request.Context(), containerID)
 	if err != nil {
@@ -126,6 +135,14 @@ func (h *Handlers) StopServiceHandler(c *gin.Context) {
 		return
 	}
 
+	// Additional replicas follow the primary container
+	replicaIDs := h.replicaContainerIDs(c.Request.Context(), serviceID)
+	for _, replicaID := range replicaIDs {
+		if err := h.dockerEngine.Stop(c.Request.Context(), replicaID); err != nil {
+			log.Warn().Err(err).Str("container_id", replicaID).Msg("failed to stop replica")
+		}
+	}
+
 	// Audit log service stop
 	if h.auditLogger != nil {
 		actor := auth.CurrentTokenName(c)
@@ -136,6 +153,7 @@ func (h *Handlers) StopServiceHandler(c *gin.Context) {
 			"service_name": service.Name,
 			"project_id":   service.ProjectID,
 			"container_id": containerID,
+			"replicas":     len(replicaIDs) + 1,
 			"stopped_by":   auth.CurrentRole(c),
 		})
 	}
@@ -163,36 +181,57 @@ func (h *Handlers) RestartServiceHandler(c *gin.Context) {
 		return
 	}
 
-	// Try to discover container by service ID first
-	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
-		containerID = *service.ContainerID
-	} else {
-		// Auto-discover container by service_id label for existing services
-		discoveredID, err := h.discoverContainerByServiceID(c.Request.Context(), serviceID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to discover container")
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
-			return
-		}
-		containerID = discoveredID
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks have no long-running container"})
+		return
+	}
 
-		// Update the service record with discovered container ID
-		err = h.serviceStore.UpdateServiceContainerID(c.Request.Context(), serviceID, containerID)
-		if err != nil {
-			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to update container ID")
-			// Continue anyway since we have the ID
-		}
+	containerID, err := h.serviceContainerID(c.Request.Context(), service)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "service container not found"})
+		return
+	}
+
+	containers, err := h.loadServiceContainers(c.Request.Context(), serviceID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to load init containers and sidecars")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restart service"})
+		return
 	}
 
-	// Restart the container
-	err = h.dockerEngine.Restart(c.Request.Context(), containerID)
+	// Sidecars lose their network namespace when the container restarts
+	h.stopSidecars(c.Request.Context(), serviceID)
+
+	// Restart the container, running init containers in between
+	if len(containers.InitContainers) > 0 {
+		if err := h.dockerEngine.Stop(c.Request.Context(), containerID); err != nil {
+			log.Warn().Err(err).Str("container_id", containerID).Msg("failed to stop container")
+		}
+		if err := h.runInitContainers(c.Request.Context(), service, containers.InitContainers); err != nil {
+			log.Error().Err(err).Int64("service_id", serviceID).Msg("init container failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		err = h.dockerEngine.Start(c.Request.Context(), containerID)
+	} else {
+		err = h.dockerEngine.Restart(c.Request.Context(), containerID)
+	}
 	if err != nil {
 		log.Error().Err(err).Str("container_id", containerID).Msg("failed to restart container")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restart service"})
 		return
 	}
 
+	h.ensureSidecars(c.Request.Context(), service, containerID)
+
+	// Additional replicas follow the primary container
+	replicaIDs := h.replicaContainerIDs(c.Request.Context(), serviceID)
+	for _, replicaID := range replicaIDs {
+		if err := h.dockerEngine.Restart(c.Request.Context(), replicaID); err != nil {
+			log.Warn().Err(err).Str("container_id", replicaID).Msg("failed to restart replica")
+		}
+	}
+
 	// Audit log service restart
 	if h.auditLogger != nil {
 		actor := auth.CurrentTokenName(c)
@@ -203,6 +242,7 @@ func (h *Handlers) RestartServiceHandler(c *gin.Context) {
 			"service_name": service.Name,
 			"project_id":   service.ProjectID,
 			"container_id": containerID,
+			"replicas":     len(replicaIDs) + 1,
 			"restarted_by": auth.CurrentRole(c),
 		})
 	}
@@ -210,3 +250,40 @@ func (h *Handlers) RestartServiceHandler(c *gin.Context) {
 	log.Info().Int64("service_id", serviceID).Str("container_id", containerID).Msg("service restarted successfully")
 	c.JSON(http.StatusOK, gin.H{"message": "service restarted successfully"})
 }
+
+// serviceContainerID returns the primary container of a service, discovering it by
+// its service_id label and recording it when the service has none yet
+func (h *Handlers) serviceContainerID(ctx context.Context, service store.Service) (string, error) {
+	if service.ContainerID != nil && *service.ContainerID != "" {
+		return *service.ContainerID, nil
+	}
+
+	// Auto-discover container by service_id label for existing services
+	containerID, err := h.discoverContainerByServiceID(ctx, service.ID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to discover container")
+		return "", err
+	}
+
+	// Update the service record with discovered container ID
+	if err := h.serviceStore.UpdateServiceContainerID(ctx, service.ID, containerID); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to update container ID")
+		// Continue anyway since we have the ID
+	}
+	return containerID, nil
+}
+
+// replicaContainerIDs returns the containers of a service's additional replicas
+func (h *Handlers) replicaContainerIDs(ctx context.Context, serviceID int64) []string {
+	replicas, err := h.getReplicas().List(ctx, serviceID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to list service replicas")
+		return nil
+	}
+
+	ids := make([]string, 0, len(replicas))
+	for _, replica := range replicas {
+		ids = append(ids, replica.ID)
+	}
+	return ids
+}
@@ -11,13 +11,11 @@ import (
 // LockdownMiddleware checks if system is in lockdown and restricts access accordingly
 func LockdownMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check lockdown status
-		lockdownMutex.RLock()
-		isLocked := lockdownState.IsLocked
-		lockdownMutex.RUnlock()
+		// Check lockdown status; an expired lockdown no longer applies
+		state := currentLockdown()
 
 		// If not locked, continue normally
-		if !isLocked {
+		if !state.IsLocked {
 			c.Next()
 			return
 		}
@@ -65,16 +63,12 @@ func LockdownMiddleware() gin.HandlerFunc {
 				return
 			}
 
-			lockdownMutex.RLock()
-			reason := lockdownState.Reason
-			timestamp := lockdownState.Timestamp
-			lockdownMutex.RUnlock()
-
 			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error":     "system_lockdown",
-				"message":   "System is in lockdown mode. Only administrator access permitted.",
-				"reason":    reason,
-				"timestamp": timestamp,
+				"error":      "system_lockdown",
+				"message":    "System is in lockdown mode. Only administrator access permitted.",
+				"reason":     state.Reason,
+				"timestamp":  state.Timestamp,
+				"expires_at": state.ExpiresAt,
 			})
 			c.Abort()
 			return
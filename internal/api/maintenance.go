@@ -0,0 +1,452 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Maintenance Window API Handlers
+
+// maintenanceAllowedActions are the writes to a frozen project's resources that
+// keep working during maintenance: lifecycle, diagnostics, backups and one-off
+// commands, which are often the point of the maintenance
+var maintenanceAllowedActions = map[string]bool{
+	"/start":            true,
+	"/stop":             true,
+	"/restart":          true,
+	"/unlock":           true,
+	"/health-check/run": true,
+	"/run":              true,
+	"/runs":             true,
+	"/snapshots":        true,
+}
+
+// MaintenanceWindowRequest represents the request to schedule or change a maintenance window.
+// StartsAt defaults to now; without EndsAt the window lasts until it is ended.
+type MaintenanceWindowRequest struct {
+	ProjectID *int64     `json:"project_id"` // omitted for every project (admin only)
+	Reason    string     `json:"reason"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	ServePage bool       `json:"serve_page"`
+	PageHTML  string     `json:"page_html"`
+}
+
+// MaintenanceWindowResponse is a maintenance window with whether it is in effect
+type MaintenanceWindowResponse struct {
+	store.MaintenanceWindow
+	Active bool `json:"active"`
+}
+
+func newMaintenanceWindowResponse(window store.MaintenanceWindow, now time.Time) MaintenanceWindowResponse {
+	return MaintenanceWindowResponse{MaintenanceWindow: window, Active: window.ActiveAt(now)}
+}
+
+// MaintenanceFreezeMiddleware rejects deploys and configuration changes to the
+// projects, services and routes of a project under maintenance. Reads and the
+// actions in maintenanceAllowedActions pass through.
+func (h *Handlers) MaintenanceFreezeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if h.store == nil || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		projectIDs, ok := h.frozenResourceProjects(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		for _, projectID := range projectIDs {
+			window, err := h.store.ActiveMaintenanceWindow(c.Request.Context(), projectID, time.Now())
+			if err != nil {
+				if !errors.Is(err, store.ErrNotFound) {
+					log.Warn().Err(err).Int64("project_id", projectID).Msg("failed to check maintenance windows")
+				}
+				continue
+			}
+
+			c.JSON(http.StatusLocked, gin.H{
+				"error":       "maintenance_freeze",
+				"message":     "Project is under maintenance. Deploys and configuration changes are frozen.",
+				"maintenance": newMaintenanceWindowResponse(*window, time.Now()),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// frozenResourceProjects returns the projects a write request changes, if it
+// changes resources a maintenance window freezes. Project 0 stands for a
+// resource outside any project, which only windows for every project freeze.
+func (h *Handlers) frozenResourceProjects(c *gin.Context) ([]int64, bool) {
+	fullPath := c.FullPath()
+	ctx := c.Request.Context()
+
+	// Creates on collections name the project in the request body
+	switch fullPath {
+	case "/v1/routes":
+		var spec struct {
+			Domain string `json:"domain"`
+		}
+		if !peekJSONBody(c, &spec) || spec.Domain == "" {
+			return nil, false
+		}
+		return h.domainProjects(ctx, spec.Domain)
+	case "/v1/deploy":
+		var request struct {
+			ProjectID int64 `json:"project_id"`
+		}
+		if !peekJSONBody(c, &request) || request.ProjectID == 0 {
+			return nil, false
+		}
+		return []int64{request.ProjectID}, true
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	var resource, action string
+	for _, prefix := range []string{"/v1/projects/:id", "/v1/services/:id", "/v1/routes/:id", "/v1/cicd/services/:id"} {
+		if fullPath == prefix || strings.HasPrefix(fullPath, prefix+"/") {
+			resource = prefix
+			action = strings.TrimPrefix(fullPath, prefix)
+			break
+		}
+	}
+	if resource == "" || maintenanceAllowedActions[action] {
+		return nil, false
+	}
+
+	switch resource {
+	case "/v1/projects/:id":
+		return []int64{id}, true
+	case "/v1/routes/:id":
+		route, err := h.routeStore.GetRoute(ctx, id)
+		if err != nil {
+			return nil, false
+		}
+		if route.ServiceID != 0 {
+			id = route.ServiceID
+			break
+		}
+
+		// Redirect and static routes belong to the projects served on their domain,
+		// and an update may move the route onto another domain
+		projectIDs, ok := h.domainProjects(ctx, route.Domain)
+		var spec struct {
+			Domain string `json:"domain"`
+		}
+		if c.Request.Method == http.MethodPut && peekJSONBody(c, &spec) && spec.Domain != "" && spec.Domain != route.Domain {
+			if moved, movedOK := h.domainProjects(ctx, spec.Domain); movedOK {
+				projectIDs = append(projectIDs, moved...)
+				ok = true
+			}
+		}
+		return projectIDs, ok
+	}
+
+	service, err := h.serviceStore.GetService(ctx, id)
+	if err != nil {
+		return nil, false
+	}
+	return []int64{service.ProjectID}, true
+}
+
+// domainProjects returns the projects with routes on a domain, or project 0 when
+// no project serves it
+func (h *Handlers) domainProjects(ctx context.Context, domain string) ([]int64, bool) {
+	projectIDs, err := h.store.ListDomainProjectIDs(ctx, domain)
+	if err != nil {
+		log.Warn().Err(err).Str("domain", domain).Msg("failed to resolve domain projects")
+		return nil, false
+	}
+	if len(projectIDs) == 0 {
+		return []int64{0}, true
+	}
+	return projectIDs, true
+}
+
+// peekJSONBody decodes the JSON request body into v and puts the body back for the handler
+func peekJSONBody(c *gin.Context, v interface{}) bool {
+	if c.Request.Body == nil {
+		return false
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// ListMaintenanceWindows returns maintenance windows that have not ended, or all
+// of them with include_ended=true
+func (h *Handlers) ListMaintenanceWindows(c *gin.Context) {
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows are not available"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	windows, err := h.store.ListMaintenanceWindows(ctx, c.Query("include_ended") == "true", now)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list maintenance windows")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list maintenance windows"})
+		return
+	}
+
+	responses := make([]MaintenanceWindowResponse, 0, len(windows))
+	for _, window := range windows {
+		responses = append(responses, newMaintenanceWindowResponse(window, now))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"windows": responses})
+}
+
+// GetMaintenanceWindow returns a single maintenance window
+func (h *Handlers) GetMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newMaintenanceWindowResponse(*window, time.Now()))
+}
+
+// CreateMaintenanceWindow schedules a maintenance window. Windows covering every
+// project need an admin.
+func (h *Handlers) CreateMaintenanceWindow(c *gin.Context) {
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows are not available"})
+		return
+	}
+
+	var req MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ProjectID == nil && c.GetString("token_role") != store.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "maintenance windows for every project require admin"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if req.ProjectID != nil {
+		if _, err := h.projectStore.GetProject(ctx, *req.ProjectID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return
+		}
+	}
+
+	now := time.Now()
+	window := &store.MaintenanceWindow{
+		ProjectID: req.ProjectID,
+		Reason:    req.Reason,
+		StartsAt:  now,
+		EndsAt:    req.EndsAt,
+		ServePage: req.ServePage,
+		PageHTML:  req.PageHTML,
+		CreatedBy: audit.GetActorFromContext(c.Request.Context()),
+	}
+	if req.StartsAt != nil {
+		window.StartsAt = *req.StartsAt
+	}
+	if window.EndsAt != nil && !window.EndsAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be in the future"})
+		return
+	}
+	if err := window.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.CreateMaintenanceWindow(ctx, window); err != nil {
+		log.Error().Err(err).Msg("failed to create maintenance window")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create maintenance window"})
+		return
+	}
+
+	if window.ServePage {
+		h.reloadMaintenancePage(ctx, window.ID)
+	}
+	h.recordMaintenanceAction(c, audit.ActionMaintenanceCreate, *window)
+
+	log.Info().
+		Int64("window_id", window.ID).
+		Interface("project_id", window.ProjectID).
+		Time("starts_at", window.StartsAt).
+		Msg("maintenance window scheduled")
+
+	c.JSON(http.StatusCreated, newMaintenanceWindowResponse(*window, time.Now()))
+}
+
+// UpdateMaintenanceWindow changes the schedule, reason and page of a maintenance
+// window. Its project cannot change.
+func (h *Handlers) UpdateMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+
+	var req MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !sameProject(req.ProjectID, window.ProjectID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the project of a maintenance window cannot change"})
+		return
+	}
+	if window.ProjectID == nil && c.GetString("token_role") != store.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "maintenance windows for every project require admin"})
+		return
+	}
+
+	previous := *window
+	window.Reason = req.Reason
+	window.EndsAt = req.EndsAt
+	window.ServePage = req.ServePage
+	window.PageHTML = req.PageHTML
+	if req.StartsAt != nil {
+		window.StartsAt = *req.StartsAt
+	}
+	if err := window.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.store.UpdateMaintenanceWindow(ctx, window); err != nil {
+		log.Error().Err(err).Int64("window_id", window.ID).Msg("failed to update maintenance window")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update maintenance window"})
+		return
+	}
+
+	// Either version may have a page up
+	if previous.ServePage || window.ServePage {
+		h.reloadMaintenancePage(ctx, window.ID)
+	}
+	h.recordMaintenanceAction(c, audit.ActionMaintenanceUpdate, *window)
+
+	c.JSON(http.StatusOK, newMaintenanceWindowResponse(*window, time.Now()))
+}
+
+// EndMaintenanceWindow ends a maintenance window now, or cancels it if it has not started
+func (h *Handlers) EndMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+	if window.ProjectID == nil && c.GetString("token_role") != store.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "maintenance windows for every project require admin"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	wasActive := window.ActiveAt(time.Now())
+	ended, err := h.store.EndMaintenanceWindow(ctx, window.ID, time.Now())
+	if err != nil {
+		log.Error().Err(err).Int64("window_id", window.ID).Msg("failed to end maintenance window")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end maintenance window"})
+		return
+	}
+
+	if wasActive && ended.ServePage {
+		h.reloadMaintenancePage(ctx, ended.ID)
+	}
+	h.recordMaintenanceAction(c, audit.ActionMaintenanceEnd, *ended)
+
+	log.Info().Int64("window_id", ended.ID).Bool("was_active", wasActive).Msg("maintenance window ended")
+
+	c.JSON(http.StatusOK, newMaintenanceWindowResponse(*ended, time.Now()))
+}
+
+// loadMaintenanceWindow returns the maintenance window named by the id parameter,
+// writing the error response if there is none
+func (h *Handlers) loadMaintenanceWindow(c *gin.Context) (*store.MaintenanceWindow, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid maintenance window ID"})
+		return nil, false
+	}
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance windows are not available"})
+		return nil, false
+	}
+
+	window, err := h.store.GetMaintenanceWindow(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "maintenance window not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("window_id", id).Msg("failed to get maintenance window")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get maintenance window"})
+		return nil, false
+	}
+	return window, true
+}
+
+// reloadMaintenancePage applies the nginx config right away when a window's page
+// goes up or comes down now. Scheduled changes are picked up by the reconcile loop.
+func (h *Handlers) reloadMaintenancePage(ctx context.Context, windowID int64) {
+	if h.reloadProxy == nil {
+		return
+	}
+	if err := h.reloadProxy(ctx); err != nil {
+		log.Warn().Err(err).Int64("window_id", windowID).Msg("failed to reload proxy for maintenance page")
+	}
+}
+
+// recordMaintenanceAction audits a change to a maintenance window
+func (h *Handlers) recordMaintenanceAction(c *gin.Context, action audit.Action, window store.MaintenanceWindow) {
+	if h.auditLogger == nil {
+		return
+	}
+	actor := audit.GetActorFromContext(c.Request.Context())
+	h.auditLogger.Record(c.Request.Context(), actor, action, "maintenance", strconv.FormatInt(window.ID, 10), map[string]interface{}{
+		"project_id": window.ProjectID,
+		"reason":     window.Reason,
+		"starts_at":  window.StartsAt,
+		"ends_at":    window.EndsAt,
+		"serve_page": window.ServePage,
+	})
+}
+
+// sameProject reports whether two optional project IDs name the same scope
+func sameProject(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
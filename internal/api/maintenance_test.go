@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freezeRouter serves route writes behind the maintenance freeze. The handlers
+// echo the request body, so tests can check it reaches them intact.
+func freezeRouter(t *testing.T) (*gin.Engine, *store.Store, store.Project) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	st, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+	require.NoError(t, st.Migrate(ctx))
+
+	project, err := st.CreateProject(ctx, "shop")
+	require.NoError(t, err)
+	service, err := st.CreateService(ctx, project.ID, store.ServiceSpec{Name: "web", Image: "nginx:alpine"})
+	require.NoError(t, err)
+	_, err = st.CreateRoute(ctx, service.ID, store.RouteSpec{Domain: "shop.example.com", Port: 80})
+	require.NoError(t, err)
+
+	handlers := &Handlers{store: st, serviceStore: st, routeStore: st}
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	}
+
+	router := gin.New()
+	v1 := router.Group("/v1")
+	v1.Use(handlers.MaintenanceFreezeMiddleware())
+	v1.POST("/routes", echo)
+	v1.PUT("/routes/:id", echo)
+	return router, st, project
+}
+
+func freezeRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func startMaintenance(t *testing.T, st *store.Store, projectID *int64) {
+	t.Helper()
+	require.NoError(t, st.CreateMaintenanceWindow(context.Background(), &store.MaintenanceWindow{
+		ProjectID: projectID,
+		Reason:    "upgrade",
+		StartsAt:  time.Now().Add(-time.Minute),
+	}))
+}
+
+func TestMaintenanceFreeze_CreateRouteOnFrozenDomain(t *testing.T) {
+	router, st, project := freezeRouter(t)
+	body := `{"kind":"redirect","domain":"shop.example.com","redirect":{"url":"https://example.com"}}`
+
+	recorder := freezeRequest(router, http.MethodPost, "/v1/routes", body)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, body, recorder.Body.String())
+
+	startMaintenance(t, st, &project.ID)
+
+	recorder = freezeRequest(router, http.MethodPost, "/v1/routes", body)
+	assert.Equal(t, http.StatusLocked, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "maintenance_freeze")
+
+	// Other domains are not served by the frozen project
+	other := `{"kind":"redirect","domain":"blog.example.com","redirect":{"url":"https://example.com"}}`
+	assert.Equal(t, http.StatusOK, freezeRequest(router, http.MethodPost, "/v1/routes", other).Code)
+}
+
+func TestMaintenanceFreeze_RouteWithoutService(t *testing.T) {
+	router, st, project := freezeRouter(t)
+	ctx := context.Background()
+
+	oldPath := "/old"
+	shared, err := st.CreateRoute(ctx, 0, store.RouteSpec{Kind: store.RouteKindRedirect, Domain: "shop.example.com", Path: &oldPath, Redirect: &store.RouteRedirect{URL: "https://example.com"}})
+	require.NoError(t, err)
+	lone, err := st.CreateRoute(ctx, 0, store.RouteSpec{Kind: store.RouteKindRedirect, Domain: "blog.example.com", Redirect: &store.RouteRedirect{URL: "https://example.com"}})
+	require.NoError(t, err)
+
+	startMaintenance(t, st, &project.ID)
+
+	update := `{"kind":"redirect","domain":"shop.example.com","path":"/old","redirect":{"url":"https://example.org"}}`
+	assert.Equal(t, http.StatusLocked, freezeRequest(router, http.MethodPut, "/v1/routes/"+strconv.FormatInt(shared.ID, 10), update).Code)
+
+	// A route no project serves is only frozen by windows covering every project,
+	// unless the update moves it onto a frozen domain
+	loneUpdate := `{"kind":"redirect","domain":"blog.example.com","redirect":{"url":"https://example.org"}}`
+	assert.Equal(t, http.StatusOK, freezeRequest(router, http.MethodPut, "/v1/routes/"+strconv.FormatInt(lone.ID, 10), loneUpdate).Code)
+	moved := `{"kind":"redirect","domain":"shop.example.com","redirect":{"url":"https://example.org"}}`
+	assert.Equal(t, http.StatusLocked, freezeRequest(router, http.MethodPut, "/v1/routes/"+strconv.FormatInt(lone.ID, 10), moved).Code)
+
+	startMaintenance(t, st, nil)
+	assert.Equal(t, http.StatusLocked, freezeRequest(router, http.MethodPut, "/v1/routes/"+strconv.FormatInt(lone.ID, 10), loneUpdate).Code)
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/manifest"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// maxManifestSize bounds the project manifests accepted for plan and apply
+const maxManifestSize = 1 << 20
+
+// loadProjectState reads the services, routes and links of a project for reconciliation
+func (h *Handlers) loadProjectState(ctx context.Context, project store.Project) (manifest.State, error) {
+	state := manifest.State{
+		Project: project,
+		Routes:  make(map[int64][]store.Route),
+		Links:   make(map[int64][]store.LinkedService),
+	}
+
+	services, err := h.serviceStore.ListServices(ctx, project.ID)
+	if err != nil {
+		return state, fmt.Errorf("failed to list services: %w", err)
+	}
+	state.Services = services
+
+	for _, service := range services {
+		routes, err := h.routeStore.ListRoutes(ctx, service.ID)
+		if err != nil {
+			return state, fmt.Errorf("failed to list routes of service %s: %w", service.Name, err)
+		}
+		state.Routes[service.ID] = routes
+
+		links, err := h.serviceStore.GetServiceLinks(ctx, service.ID)
+		if err != nil {
+			return state, fmt.Errorf("failed to get links of service %s: %w", service.Name, err)
+		}
+		state.Links[service.ID] = links
+	}
+
+	return state, nil
+}
+
+// planProjectManifest parses the manifest in the request body and diffs it against the
+// project, writing the error response on failure
+func (h *Handlers) planProjectManifest(ctx context.Context, c *gin.Context) (manifest.State, *manifest.Plan, bool) {
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return manifest.State{}, nil, false
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxManifestSize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "manifest too large: max 1MB"})
+		return manifest.State{}, nil, false
+	}
+	desired, err := manifest.Parse(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return manifest.State{}, nil, false
+	}
+
+	project, err := h.serviceStore.GetProject(ctx, projectID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("project not found: %d", projectID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get project"})
+		}
+		return manifest.State{}, nil, false
+	}
+
+	state, err := h.loadProjectState(ctx, project)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to load project state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load project state"})
+		return manifest.State{}, nil, false
+	}
+
+	plan := manifest.Diff(desired, state)
+	for _, service := range plan.ChangeSet.UpdateServices {
+		if err := store.ValidateDeployStrategyPorts(service.DeployStrategy, service.Ports); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("service %s: %v", service.Name, err)})
+			return manifest.State{}, nil, false
+		}
+	}
+
+	return state, plan, true
+}
+
+// GetProjectManifest returns the current configuration of a project as a manifest,
+// in YAML unless format=json is requested
+func (h *Handlers) GetProjectManifest(c *gin.Context) {
+	projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	project, err := h.serviceStore.GetProject(ctx, projectID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("project not found: %d", projectID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get project"})
+		}
+		return
+	}
+
+	state, err := h.loadProjectState(ctx, project)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to load project state")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load project state"})
+		return
+	}
+
+	current := manifest.FromState(state)
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, current)
+		return
+	}
+
+	data, err := manifest.Marshal(current)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", projectID).Msg("failed to encode manifest")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode manifest"})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", data)
+}
+
+// PlanProjectManifest returns the changes applying a manifest to a project would make
+func (h *Handlers) PlanProjectManifest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	_, plan, ok := h.planProjectManifest(ctx, c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"in_sync": plan.InSync(),
+		"changes": plan.Changes,
+	})
+}
+
+// ApplyProjectManifest brings a project in line with a manifest. The stored changes are
+// written in one transaction; containers and the proxy are updated afterwards and
+// problems there are reported as warnings.
+func (h *Handlers) ApplyProjectManifest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	state, plan, ok := h.planProjectManifest(ctx, c)
+	if !ok {
+		return
+	}
+	if plan.InSync() {
+		c.JSON(http.StatusOK, gin.H{"in_sync": true, "changes": plan.Changes, "warnings": []string{}})
+		return
+	}
+
+	created, err := h.store.ApplyProjectChangeSet(ctx, state.Project.ID, plan.ChangeSet)
+	if err != nil {
+		log.Error().Err(err).Int64("project_id", state.Project.ID).Msg("failed to apply project manifest")
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to apply manifest: %v", err)})
+		return
+	}
+
+	// Container work outlives the short store timeout, allow each affected service as long as CreateService
+	runtimeCtx, runtimeCancel := context.WithTimeout(c.Request.Context(), time.Duration(len(plan.Changes)+1)*60*time.Second)
+	defer runtimeCancel()
+	warnings := h.reconcileContainers(runtimeCtx, state, plan, created)
+
+	if h.auditLogger != nil {
+		h.recordManifestChanges(c.Request.Context(), state, plan)
+	}
+
+	log.Info().
+		Int64("project_id", state.Project.ID).
+		Int("changes", len(plan.Changes)).
+		Int("warnings", len(warnings)).
+		Msg("project manifest applied")
+
+	c.JSON(http.StatusOK, gin.H{
+		"in_sync":  false,
+		"changes":  plan.Changes,
+		"warnings": warnings,
+	})
+}
+
+// reconcileContainers creates, recreates and removes containers after a change set was
+// stored and reloads nginx when routes changed. Failures are returned as warnings.
+func (h *Handlers) reconcileContainers(ctx context.Context, state manifest.State, plan *manifest.Plan, created []store.Service) []string {
+	warnings := []string{}
+	project := state.Project
+	if plan.ChangeSet.Project != nil {
+		project = *plan.ChangeSet.Project
+	}
+
+	byID := make(map[int64]store.Service, len(state.Services))
+	for _, service := range state.Services {
+		byID[service.ID] = service
+	}
+	for _, id := range plan.ChangeSet.DeleteServices {
+		service := byID[id]
+		containerName := fmt.Sprintf("glinr_%d_%s", service.ProjectID, service.Name)
+		if err := h.dockerEngine.Remove(ctx, containerName); err != nil {
+			log.Warn().Err(err).Str("container", containerName).Msg("failed to remove container (may not exist)")
+		}
+		if err := h.getReplicas().RemoveAll(ctx, id); err != nil {
+			log.Warn().Err(err).Int64("service_id", id).Msg("failed to remove service replicas")
+		}
+		if err := h.getSidecars().RemoveAll(ctx, id); err != nil {
+			log.Warn().Err(err).Int64("service_id", id).Msg("failed to remove service sidecars")
+		}
+	}
+
+	runtimeChanged := make(map[string]bool)
+	for _, change := range plan.Changes {
+		if change.Kind != manifest.KindService || change.Action != manifest.ActionUpdate {
+			continue
+		}
+		for _, field := range change.Fields {
+			if manifest.RuntimeFields[field] {
+				runtimeChanged[change.Name] = true
+			}
+		}
+	}
+	for _, service := range plan.ChangeSet.UpdateServices {
+		// Scheduled tasks pick up the new configuration with their next run
+		if !runtimeChanged[service.Name] || byID[service.ID].IsTask() {
+			continue
+		}
+		err := h.recreateServiceContainer(ctx, service.ID, service)
+		if previous := byID[service.ID]; previous.Image != service.Image {
+			h.recordImageDeployment(ctx, previous, service.Image, err)
+		}
+		if err != nil {
+			log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to recreate service container")
+			warnings = append(warnings, fmt.Sprintf("service %s: failed to recreate container: %v", service.Name, err))
+		}
+	}
+
+	for _, service := range created {
+		if err := h.dockerEngine.Pull(ctx, service.Image, ""); err != nil {
+			log.Error().Err(err).Str("image", service.Image).Msg("failed to pull image")
+			warnings = append(warnings, fmt.Sprintf("service %s: failed to pull image %s", service.Name, service.Image))
+			continue
+		}
+		if _, _, err := h.createServiceContainer(ctx, project, service); err != nil {
+			warnings = append(warnings, fmt.Sprintf("service %s: failed to create container: %v", service.Name, err))
+		}
+	}
+
+	routesChanged := len(plan.ChangeSet.DeleteServices) > 0
+	for _, change := range plan.Changes {
+		routesChanged = routesChanged || change.Kind == manifest.KindRoute
+	}
+	if routesChanged && h.nginxConfig != nil {
+		if err := h.nginxConfig.UpdateAndReload(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to update nginx configuration")
+			warnings = append(warnings, "failed to update nginx configuration")
+		}
+	}
+
+	return warnings
+}
+
+// recordManifestChanges writes one audit entry per applied change and a summary for the project
+func (h *Handlers) recordManifestChanges(ctx context.Context, state manifest.State, plan *manifest.Plan) {
+	actor := audit.GetActorFromContext(ctx)
+	projectID := strconv.FormatInt(state.Project.ID, 10)
+
+	summary := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		summary = append(summary, change.String())
+		meta := map[string]interface{}{
+			"project_id": state.Project.ID,
+			"source":     "manifest",
+			"name":       change.Name,
+		}
+		if len(change.Fields) > 0 {
+			meta["fields"] = change.Fields
+		}
+
+		switch change.Kind {
+		case manifest.KindProject:
+			h.auditLogger.RecordProjectAction(ctx, actor, audit.ActionProjectUpdate, projectID, meta)
+		case manifest.KindService:
+			action := map[string]audit.Action{
+				manifest.ActionCreate: audit.ActionServiceCreate,
+				manifest.ActionUpdate: audit.ActionServiceUpdate,
+				manifest.ActionDelete: audit.ActionServiceDelete,
+			}[change.Action]
+			h.auditLogger.RecordServiceAction(ctx, actor, action, change.Name, meta)
+		case manifest.KindRoute:
+			action := map[string]audit.Action{
+				manifest.ActionCreate: audit.ActionRouteCreate,
+				manifest.ActionUpdate: audit.ActionRouteUpdate,
+				manifest.ActionDelete: audit.ActionRouteDelete,
+			}[change.Action]
+			meta["service_name"] = change.Service
+			h.auditLogger.RecordRouteAction(ctx, actor, action, change.Name, meta)
+		case manifest.KindLinks:
+			h.auditLogger.RecordServiceAction(ctx, actor, audit.ActionServiceLinksUpdate, change.Name, meta)
+		}
+	}
+
+	h.auditLogger.RecordProjectAction(ctx, actor, audit.ActionProjectApply, projectID, map[string]interface{}{
+		"project_name":  state.Project.Name,
+		"changes_count": len(plan.Changes),
+		"changes":       summary,
+	})
+}
@@ -37,7 +37,7 @@ func FeatureGate(planEnforcer *plan.Enforcer, feature string) gin.HandlerFunc {
 // getUpgradeHint provides upgrade suggestions based on current plan and requested feature
 func getUpgradeHint(currentPlan config.Plan, feature string) string {
 	switch feature {
-	case "smtp_alerts", "ci_integrations", "ssl_certs":
+	case "smtp_alerts", "ci_integrations", "ssl_certs", "web_terminal":
 		if currentPlan == config.PlanFree {
 			return "Upgrade to PRO or PREMIUM plan to enable this feature"
 		}
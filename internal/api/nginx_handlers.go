@@ -179,6 +179,11 @@ func (h *NginxHandlers) GetCurrentConfig(c *gin.Context) {
 	// Convert to RouteWithService (TODO: implement proper join query)
 	routesWithServices := make([]store.RouteWithService, len(routes))
 	for i, route := range routes {
+		// Redirect and static routes have no service
+		if !route.Proxies() {
+			routesWithServices[i] = store.RouteWithService{Route: route}
+			continue
+		}
 		service, err := h.store.GetService(ctx, route.ServiceID)
 		if err != nil {
 			log.Warn().Err(err).Int64("service_id", route.ServiceID).Msg("failed to get service for route")
@@ -258,6 +263,11 @@ func (h *NginxHandlers) ValidateCurrentConfig(c *gin.Context) {
 	// Convert to RouteWithService
 	routesWithServices := make([]store.RouteWithService, len(routes))
 	for i, route := range routes {
+		// Redirect and static routes have no service
+		if !route.Proxies() {
+			routesWithServices[i] = store.RouteWithService{Route: route}
+			continue
+		}
 		service, err := h.store.GetService(ctx, route.ServiceID)
 		if err != nil {
 			log.Warn().Err(err).Int64("service_id", route.ServiceID).Msg("failed to get service for route")
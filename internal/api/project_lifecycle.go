@@ -0,0 +1,366 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// Project lifecycle actions
+const (
+	ProjectActionStart   = "start"
+	ProjectActionStop    = "stop"
+	ProjectActionRestart = "restart"
+)
+
+const (
+	defaultDependencyHealthTimeout = 120 * time.Second
+	maxDependencyHealthTimeout     = 600 * time.Second
+	dependencyHealthInterval       = 2 * time.Second
+)
+
+// Statuses reported by project lifecycle events
+const (
+	LifecycleStarting  = "starting"
+	LifecycleWaiting   = "waiting"   // started, waiting for the health check before starting dependents
+	LifecycleHealthy   = "healthy"   // passed its health check
+	LifecycleStarted   = "started"   // started, nothing depends on it
+	LifecycleStopping  = "stopping"  // stop requested
+	LifecycleStopped   = "stopped"   // container stopped
+	LifecycleSkipped   = "skipped"   // not started because a dependency failed
+	LifecycleFailed    = "failed"    // service or operation failed
+	LifecycleCompleted = "completed" // operation finished without failures
+)
+
+// ProjectLifecycleEvent reports the progress of a project-wide start, stop or restart.
+// Events without a service describe the operation as a whole.
+type ProjectLifecycleEvent struct {
+	Action    string    `json:"action"`
+	Step      int       `json:"step,omitempty"` // position of the service in the order, from 1
+	Total     int       `json:"total"`
+	ServiceID int64     `json:"service_id,omitempty"`
+	Service   string    `json:"service,omitempty"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// ProjectLifecycleHandler starts, stops or restarts all services of a project in
+// dependency order, as given by their links. Dependencies start first and must pass
+// their health check before their dependents start; stopping runs in reverse.
+//
+// A WebSocket upgrade streams one ProjectLifecycleEvent per step. Otherwise the
+// request blocks until the operation finishes and returns all events at once.
+func (h *Handlers) ProjectLifecycleHandler(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		projectID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+			return
+		}
+
+		stream := websocket.IsWebSocketUpgrade(c.Request)
+		if c.Request.Method == http.MethodGet && !stream {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required, use POST for a blocking request"})
+			return
+		}
+
+		healthTimeout := defaultDependencyHealthTimeout
+		if value := c.Query("health_timeout"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxDependencyHealthTimeout {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("health_timeout must be 1-%d seconds", int(maxDependencyHealthTimeout.Seconds()))})
+				return
+			}
+			healthTimeout = time.Duration(seconds) * time.Second
+		}
+
+		project, err := h.serviceStore.GetProject(c.Request.Context(), projectID)
+		if err != nil {
+			if err.Error() == fmt.Sprintf("project not found: %d", projectID) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get project"})
+			}
+			return
+		}
+
+		ordered, links, err := h.projectServiceOrder(c.Request.Context(), projectID)
+		if err != nil {
+			var cycle *rollout.CycleError
+			if errors.As(err, &cycle) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "cycle": cycle.Path})
+				return
+			}
+			log.Error().Err(err).Int64("project_id", projectID).Msg("failed to order project services")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to order project services"})
+			return
+		}
+
+		order := make([]string, 0, len(ordered))
+		for _, service := range ordered {
+			order = append(order, service.Name)
+		}
+
+		// The operation outlives a disconnecting client; allow each service a health wait and a container action
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(len(ordered)+1)*(healthTimeout+60*time.Second))
+		defer cancel()
+
+		events := []ProjectLifecycleEvent{}
+		emit := func(event ProjectLifecycleEvent) {
+			event.Action = action
+			event.Total = len(ordered)
+			event.Time = time.Now().UTC()
+			events = append(events, event)
+		}
+
+		if stream {
+			conn, err := WebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to upgrade to websocket")
+				return
+			}
+			defer conn.Close()
+
+			connected := true
+			collect := emit
+			emit = func(event ProjectLifecycleEvent) {
+				collect(event)
+				if !connected {
+					return
+				}
+				if err := conn.WriteJSON(events[len(events)-1]); err != nil {
+					log.Debug().Err(err).Msg("project lifecycle client disconnected")
+					connected = false
+				}
+			}
+		}
+
+		failed := h.runProjectLifecycle(ctx, action, ordered, links, healthTimeout, emit)
+		if len(failed) > 0 {
+			emit(ProjectLifecycleEvent{Status: LifecycleFailed, Message: fmt.Sprintf("%d service(s) failed", len(failed))})
+		} else {
+			emit(ProjectLifecycleEvent{Status: LifecycleCompleted})
+		}
+
+		if h.auditLogger != nil {
+			actor := audit.GetActorFromContext(c.Request.Context())
+			auditAction := map[string]audit.Action{
+				ProjectActionStart:   audit.ActionProjectStart,
+				ProjectActionStop:    audit.ActionProjectStop,
+				ProjectActionRestart: audit.ActionProjectRestart,
+			}[action]
+			h.auditLogger.RecordProjectAction(c.Request.Context(), actor, auditAction, strconv.FormatInt(projectID, 10), map[string]interface{}{
+				"project_name": project.Name,
+				"order":        order,
+				"failed":       failed,
+			})
+		}
+
+		log.Info().
+			Int64("project_id", projectID).
+			Str("action", action).
+			Int("services", len(ordered)).
+			Int("failed", len(failed)).
+			Msg("project lifecycle operation finished")
+
+		if stream {
+			return
+		}
+
+		status := http.StatusOK
+		response := gin.H{"order": order, "failed": failed, "events": events}
+		if len(failed) > 0 {
+			status = http.StatusInternalServerError
+			response["error"] = fmt.Sprintf("failed to %s %d service(s)", action, len(failed))
+		}
+		c.JSON(status, response)
+	}
+}
+
+// projectServiceOrder returns the services of a project with their dependencies first,
+// and the links between them by service ID
+func (h *Handlers) projectServiceOrder(ctx context.Context, projectID int64) ([]store.Service, map[int64][]int64, error) {
+	all, err := h.serviceStore.ListServices(ctx, projectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	// Scheduled tasks have no container to start or stop
+	services := make([]store.Service, 0, len(all))
+	for _, service := range all {
+		if !service.IsTask() {
+			services = append(services, service)
+		}
+	}
+
+	links := make(map[int64][]int64, len(services))
+	for _, service := range services {
+		linked, err := h.serviceStore.GetServiceLinks(ctx, service.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get links of service %s: %w", service.Name, err)
+		}
+		for _, target := range linked {
+			// Services of other projects are not managed by this operation
+			if target.ProjectID == projectID {
+				links[service.ID] = append(links[service.ID], target.ID)
+			}
+		}
+	}
+
+	ordered, err := rollout.OrderByLinks(services, links)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ordered, links, nil
+}
+
+// runProjectLifecycle performs a lifecycle action on services ordered dependencies
+// first and returns the names of the services that failed. A service whose
+// dependency failed is skipped rather than started.
+func (h *Handlers) runProjectLifecycle(ctx context.Context, action string, ordered []store.Service, links map[int64][]int64, healthTimeout time.Duration, emit func(ProjectLifecycleEvent)) []string {
+	failed := []string{}
+
+	if action == ProjectActionStop || action == ProjectActionRestart {
+		for i := len(ordered) - 1; i >= 0; i-- {
+			service := ordered[i]
+			step := len(ordered) - i
+			emit(ProjectLifecycleEvent{Step: step, ServiceID: service.ID, Service: service.Name, Status: LifecycleStopping})
+			if err := h.stopServiceContainers(ctx, service); err != nil {
+				emit(ProjectLifecycleEvent{Step: step, ServiceID: service.ID, Service: service.Name, Status: LifecycleFailed, Message: err.Error()})
+				failed = append(failed, service.Name)
+				continue
+			}
+			emit(ProjectLifecycleEvent{Step: step, ServiceID: service.ID, Service: service.Name, Status: LifecycleStopped})
+		}
+	}
+	if action == ProjectActionStop {
+		return failed
+	}
+
+	names := make(map[int64]string, len(ordered))
+	for _, service := range ordered {
+		names[service.ID] = service.Name
+	}
+	hasDependents := make(map[int64]bool)
+	for _, targets := range links {
+		for _, target := range targets {
+			hasDependents[target] = true
+		}
+	}
+
+	unavailable := make(map[int64]bool) // failed or skipped, so dependents cannot start
+	prober := h.getHealthProber()
+	for i, service := range ordered {
+		step := i + 1
+		event := ProjectLifecycleEvent{Step: step, ServiceID: service.ID, Service: service.Name}
+
+		blocked := ""
+		for _, target := range links[service.ID] {
+			if unavailable[target] {
+				blocked = names[target]
+				break
+			}
+		}
+		if blocked != "" {
+			unavailable[service.ID] = true
+			event.Status, event.Message = LifecycleSkipped, fmt.Sprintf("dependency %s is not available", blocked)
+			emit(event)
+			continue
+		}
+
+		event.Status = LifecycleStarting
+		emit(event)
+		containerID, err := h.startServiceContainers(ctx, service)
+		if err != nil {
+			unavailable[service.ID] = true
+			failed = append(failed, service.Name)
+			event.Status, event.Message = LifecycleFailed, err.Error()
+			emit(event)
+			continue
+		}
+
+		if !hasDependents[service.ID] {
+			event.Status = LifecycleStarted
+			emit(event)
+			continue
+		}
+
+		event.Status = LifecycleWaiting
+		emit(event)
+		routes, err := h.routeStore.ListRoutes(ctx, service.ID)
+		if err != nil {
+			log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to list routes for health check")
+		}
+		if err := rollout.WaitHealthy(ctx, h.dockerEngine, prober, &service, routes, containerID, healthTimeout, dependencyHealthInterval); err != nil {
+			unavailable[service.ID] = true
+			failed = append(failed, service.Name)
+			event.Status, event.Message = LifecycleFailed, fmt.Sprintf("health check failed: %v", err)
+			emit(event)
+			continue
+		}
+		if err := h.serviceStore.UpdateServiceHealth(ctx, service.ID, store.HealthStatusOK); err != nil {
+			log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to record service health")
+		}
+		event.Status = LifecycleHealthy
+		emit(event)
+	}
+
+	return failed
+}
+
+// startServiceContainers runs the init containers of a service, then starts its
+// primary container, sidecars and replicas and returns the primary container's ID
+func (h *Handlers) startServiceContainers(ctx context.Context, service store.Service) (string, error) {
+	containerID, err := h.serviceContainerID(ctx, service)
+	if err != nil {
+		return "", fmt.Errorf("service container not found")
+	}
+	containers, err := h.loadServiceContainers(ctx, service.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to load init containers and sidecars: %w", err)
+	}
+	if err := h.runInitContainers(ctx, service, containers.InitContainers); err != nil {
+		return "", err
+	}
+	if err := h.dockerEngine.Start(ctx, containerID); err != nil {
+		log.Error().Err(err).Str("container_id", containerID).Msg("failed to start container")
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+	h.ensureSidecars(ctx, service, containerID)
+	for _, replicaID := range h.replicaContainerIDs(ctx, service.ID) {
+		if err := h.dockerEngine.Start(ctx, replicaID); err != nil {
+			log.Warn().Err(err).Str("container_id", replicaID).Msg("failed to start replica")
+		}
+	}
+	return containerID, nil
+}
+
+// stopServiceContainers stops the sidecars, replicas and primary container of a service
+func (h *Handlers) stopServiceContainers(ctx context.Context, service store.Service) error {
+	containerID, err := h.serviceContainerID(ctx, service)
+	if err != nil {
+		return fmt.Errorf("service container not found")
+	}
+	h.stopSidecars(ctx, service.ID)
+	for _, replicaID := range h.replicaContainerIDs(ctx, service.ID) {
+		if err := h.dockerEngine.Stop(ctx, replicaID); err != nil {
+			log.Warn().Err(err).Str("container_id", replicaID).Msg("failed to stop replica")
+		}
+	}
+	if err := h.dockerEngine.Stop(ctx, containerID); err != nil {
+		log.Error().Err(err).Str("container_id", containerID).Msg("failed to stop container")
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Route Basic Auth Credential API Handlers
+
+// RouteCredentialRequest sets the password of a basic auth user
+type RouteCredentialRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// RouteCredentialsResponse lists the basic auth users of a route. Passwords
+// are never returned.
+type RouteCredentialsResponse struct {
+	RouteID     int64                   `json:"route_id"`
+	BasicAuth   bool                    `json:"basic_auth"` // the route's access policy asks for basic auth
+	Credentials []store.RouteCredential `json:"credentials"`
+}
+
+// getCredentialRoute loads the route a credential request is for, writing
+// the error response when that fails
+func (h *Handlers) getCredentialRoute(ctx context.Context, c *gin.Context) (store.Route, bool) {
+	routeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid route ID"})
+		return store.Route{}, false
+	}
+
+	route, err := h.routeStore.GetRoute(ctx, routeID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("route not found: %d", routeID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "route not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get route"})
+		}
+		return store.Route{}, false
+	}
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "route credentials are not available"})
+		return store.Route{}, false
+	}
+
+	return route, true
+}
+
+// ListRouteCredentials returns the basic auth users of a route
+func (h *Handlers) ListRouteCredentials(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	route, ok := h.getCredentialRoute(ctx, c)
+	if !ok {
+		return
+	}
+
+	credentials, err := h.store.ListRouteCredentials(ctx, route.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("route_id", route.ID).Msg("failed to list route credentials")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list route credentials"})
+		return
+	}
+	if credentials == nil {
+		credentials = []store.RouteCredential{}
+	}
+
+	c.JSON(http.StatusOK, RouteCredentialsResponse{
+		RouteID:     route.ID,
+		BasicAuth:   route.Access != nil && route.Access.BasicAuth != nil,
+		Credentials: credentials,
+	})
+}
+
+// SetRouteCredential creates a basic auth user of a route or changes its password
+func (h *Handlers) SetRouteCredential(c *gin.Context) {
+	var req RouteCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	route, ok := h.getCredentialRoute(ctx, c)
+	if !ok {
+		return
+	}
+
+	username := c.Param("username")
+	if err := store.ValidateRouteCredential(username, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.store.SetRouteCredential(ctx, route.ID, username, req.Password)
+	if err != nil {
+		log.Error().Err(err).Int64("route_id", route.ID).Msg("failed to set route credential")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set route credential"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordRouteAction(c.Request.Context(), actor, audit.ActionRouteUpdate, strconv.FormatInt(route.ID, 10), map[string]interface{}{
+			"domain":              route.Domain,
+			"credential_username": username,
+		})
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// DeleteRouteCredential removes a basic auth user of a route
+func (h *Handlers) DeleteRouteCredential(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	route, ok := h.getCredentialRoute(ctx, c)
+	if !ok {
+		return
+	}
+
+	username := c.Param("username")
+	if err := h.store.DeleteRouteCredential(ctx, route.ID, username); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+			return
+		}
+		log.Error().Err(err).Int64("route_id", route.ID).Msg("failed to delete route credential")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete route credential"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordRouteAction(c.Request.Context(), actor, audit.ActionRouteUpdate, strconv.FormatInt(route.ID, 10), map[string]interface{}{
+			"domain":                      route.Domain,
+			"credential_username_removed": username,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "credential deleted"})
+}
@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/GLINCKER/glinrdock/internal/nginx"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -42,12 +44,31 @@ func (h *Handlers) CreateServiceRoute(c *gin.Context) {
 		return
 	}
 
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
+	// Scheduled tasks have no long-running container to route to
+	if service, err := h.routeStore.GetService(ctx, serviceID); err == nil && service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks cannot have routes"})
+		return
+	}
+
 	// Create route in database
 	route, err := h.routeStore.CreateRoute(ctx, serviceID, spec)
 	if err != nil {
+		if errors.Is(err, store.ErrRouteConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, store.ErrRouteKind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to create route")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create route"})
 		return
@@ -83,6 +104,70 @@ func (h *Handlers) CreateServiceRoute(c *gin.Context) {
 	c.JSON(http.StatusCreated, route)
 }
 
+// CreateRoute creates a redirect or static route, which has no service
+func (h *Handlers) CreateRoute(c *gin.Context) {
+	// Parse route specification
+	var spec store.RouteSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		log.Error().Err(err).Msg("invalid route specification")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if spec.Proxies() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "proxy routes are created on their service with POST /v1/services/:id/routes"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	// Create route in database
+	route, err := h.routeStore.CreateRoute(ctx, 0, spec)
+	if err != nil {
+		if errors.Is(err, store.ErrRouteConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Error().Err(err).Str("kind", spec.Kind).Msg("failed to create route")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create route"})
+		return
+	}
+
+	// Regenerate and reload nginx configuration
+	if h.nginxConfig != nil {
+		if err := h.nginxConfig.UpdateAndReload(ctx); err != nil {
+			log.Error().Err(err).Msg("failed to update nginx configuration")
+			// Don't fail the request, but log the error
+			// The route was created successfully in the database
+		}
+	}
+
+	log.Info().
+		Int64("route_id", route.ID).
+		Str("kind", route.Kind).
+		Str("domain", route.Domain).
+		Msg("route created successfully")
+
+	// Index route for search asynchronously
+	go func() {
+		indexCtx, indexCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer indexCancel()
+		if err := h.store.IndexRoute(indexCtx, route.ID); err != nil {
+			// Log error with sampling to avoid spam (1 in 10 errors logged)
+			if route.ID%10 == 0 {
+				log.Error().Err(err).Int64("route_id", route.ID).Msg("failed to index route for search")
+			}
+		}
+	}()
+
+	c.JSON(http.StatusCreated, route)
+}
+
 // ListServiceRoutes lists all routes for a service
 func (h *Handlers) ListServiceRoutes(c *gin.Context) {
 	// Parse service ID
@@ -212,6 +297,11 @@ func (h *Handlers) UpdateRoute(c *gin.Context) {
 		return
 	}
 
+	if err := spec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
@@ -221,6 +311,10 @@ func (h *Handlers) UpdateRoute(c *gin.Context) {
 		log.Error().Err(err).Int64("route_id", routeID).Msg("failed to update route")
 		if err.Error() == fmt.Sprintf("route not found: %d", routeID) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "route not found"})
+		} else if errors.Is(err, store.ErrRouteConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else if errors.Is(err, store.ErrRouteKind) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update route"})
 		}
@@ -282,12 +376,20 @@ func (h *Handlers) PreviewRouteConfig(c *gin.Context) {
 		return
 	}
 
-	// Get the service for this route
-	service, err := h.routeStore.GetService(ctx, route.ServiceID)
-	if err != nil {
-		log.Error().Err(err).Int64("service_id", route.ServiceID).Msg("failed to get service for route preview")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
-		return
+	// Get the service for this route, redirect and static routes have none
+	var service store.Service
+	var serviceInfo *gin.H
+	if route.Proxies() {
+		service, err = h.routeStore.GetService(ctx, route.ServiceID)
+		if err != nil {
+			log.Error().Err(err).Int64("service_id", route.ServiceID).Msg("failed to get service for route preview")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+			return
+		}
+		serviceInfo = &gin.H{
+			"id":   service.ID,
+			"name": service.Name,
+		}
 	}
 
 	// Get certificate info if configured
@@ -306,30 +408,74 @@ func (h *Handlers) PreviewRouteConfig(c *gin.Context) {
 		}
 	}
 
+	// Render the server block nginx gets for this route
+	routeWithService := store.RouteWithService{
+		Route:         route,
+		ServiceName:   service.Name,
+		Replicas:      service.Replicas,
+		LoadBalancing: service.LoadBalancing,
+	}
+	if service.UpstreamHost != nil {
+		routeWithService.UpstreamHost = *service.UpstreamHost
+	}
+	renderInput := nginx.RenderInput{
+		Routes: []store.RouteWithService{routeWithService},
+		Certs:  make(map[string]store.EnhancedCertificate),
+	}
+	if route.TLS && h.store != nil {
+		certificates, err := h.store.ListCertificates(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to list certificates for route preview")
+		}
+		for _, cert := range certificates {
+			if cert.Domain == route.Domain {
+				renderInput.Certs[cert.Domain] = cert
+			}
+		}
+	}
+	nginxConfig := gin.H{
+		"server_name": route.Domain,
+		"locations":   nginx.LocationMatches(routeWithService),
+		"directives":  nginx.ResponseDirectives(routeWithService),
+	}
+	if route.Proxies() {
+		directives := append(nginx.RateLimitDirectives(routeWithService), nginx.AccessDirectives(routeWithService)...)
+		nginxConfig["upstream_name"] = nginx.UpstreamName(service.ID, route.Port)
+		nginxConfig["proxy_pass"] = "http://" + nginx.UpstreamName(service.ID, route.Port)
+		nginxConfig["directives"] = append(directives, nginx.PolicyDirectives(routeWithService, len(renderInput.Certs) > 0)...)
+	}
+	var controllerAddress string
+	if h.systemConfig != nil {
+		controllerAddress = h.systemConfig.NginxControllerAddr
+	}
+	if config, _, err := nginx.NewGenerator("", controllerAddress).Render(renderInput); err != nil {
+		nginxConfig["error"] = err.Error()
+	} else {
+		nginxConfig["config"] = config
+	}
+
 	// Build comprehensive route preview
 	response := gin.H{
 		"route": gin.H{
 			"id":             route.ID,
+			"kind":           route.Kind,
 			"domain":         route.Domain,
 			"port":           route.Port,
 			"tls":            route.TLS,
 			"path":           route.Path,
+			"match_type":     route.MatchType,
+			"strip_prefix":   route.StripPrefix,
 			"certificate_id": route.CertificateID,
 			"proxy_config":   route.ProxyConfig,
+			"redirect":       route.Redirect,
+			"static":         route.Static,
 			"created_at":     route.CreatedAt,
 			"updated_at":     route.UpdatedAt,
 		},
-		"service": gin.H{
-			"id":   service.ID,
-			"name": service.Name,
-		},
-		"certificate": certificateInfo,
-		"nginx_config": gin.H{
-			"upstream_name": fmt.Sprintf("%s_%d", service.Name, service.ID),
-			"server_name":   route.Domain,
-			"proxy_pass":    fmt.Sprintf("http://%s_%d", service.Name, service.ID),
-		},
-		"preview": true,
+		"service":      serviceInfo,
+		"certificate":  certificateInfo,
+		"nginx_config": nginxConfig,
+		"preview":      true,
 	}
 
 	c.JSON(http.StatusOK, response)
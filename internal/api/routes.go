@@ -34,6 +34,7 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 		v1.GET("/system/license", authService.Middleware(), handlers.GetLicenseStatus)
 		v1.GET("/system/onboarding", handlers.GetOnboardingStatus)
 		v1.POST("/system/onboarding/complete", authService.Middleware(), handlers.CompleteOnboarding)
+		v1.GET("/auth/forward", authService.Middleware(), handlers.ForwardAuthHandler) // nginx auth_request for routes with forward auth
 
 		// Docker Hub proxy endpoints (public since they're just proxying external APIs)
 		dockerhub := v1.Group("/dockerhub")
@@ -61,6 +62,7 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 		protected := v1.Group("")
 		protected.Use(authService.Middleware())
 		protected.Use(LockdownMiddleware())
+		protected.Use(handlers.MaintenanceFreezeMiddleware())
 		{
 			// Auth endpoints (require authentication)
 			protected.GET("/auth/me", handlers.AuthMeHandler)
@@ -79,11 +81,25 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 			projects := protected.Group("/projects")
 			{
 				projects.POST("", authService.RequireRole(store.RoleDeployer), handlers.CreateProject)
+				projects.POST("/import/compose", authService.RequireRole(store.RoleDeployer), handlers.ImportCompose)
+				projects.POST("/import/bundle", authService.RequireRole(store.RoleDeployer), handlers.ImportProjectBundle)
 				projects.GET("", handlers.ListProjects)
 				projects.GET("/:id", handlers.GetProject)
 				projects.PUT("/:id", authService.RequireRole(store.RoleDeployer), handlers.UpdateProject)
 				projects.DELETE("/:id", authService.RequireRole(store.RoleDeployer), handlers.DeleteProject)
 
+				// Declarative project manifests
+				projects.GET("/:id/manifest", handlers.GetProjectManifest)
+				projects.GET("/:id/export", authService.RequireRole(store.RoleDeployer), handlers.ExportProject)
+
+				// Project-wide lifecycle in dependency order; GET streams progress over WebSocket
+				for _, action := range []string{ProjectActionStart, ProjectActionStop, ProjectActionRestart} {
+					projects.POST("/:id/"+action, authService.RequireRole(store.RoleDeployer), handlers.ProjectLifecycleHandler(action))
+					projects.GET("/:id/"+action, authService.RequireRole(store.RoleDeployer), handlers.ProjectLifecycleHandler(action))
+				}
+				projects.POST("/:id/plan", authService.RequireRole(store.RoleDeployer), handlers.PlanProjectManifest)
+				projects.POST("/:id/apply", authService.RequireRole(store.RoleDeployer), handlers.ApplyProjectManifest)
+
 				// Services within projects
 				projects.POST("/:id/services", authService.RequireRole(store.RoleDeployer), handlers.CreateService)
 				projects.GET("/:id/services", handlers.ListServices)
@@ -137,6 +153,9 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 				services.GET("/:id/logs/tail", handlers.ServiceLogsTailHandler) // REST tail
 				services.GET("/:id/stats", handlers.ServiceStatsHandler)
 
+				// Web terminal (WebSocket), deployer+ on plans with the web_terminal feature
+				services.GET("/:id/exec", authService.RequireRole(store.RoleDeployer), middleware.FeatureGate(planEnforcer, "web_terminal"), handlers.ServiceExecHandler)
+
 				// Service networking (all authenticated users can read, deployer+ can edit links)
 				services.GET("/:id/network", handlers.GetServiceNetwork)
 				services.GET("/:id/links", handlers.GetServiceLinks)
@@ -144,6 +163,39 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 
 				// Service health endpoints
 				services.POST("/:id/health-check", authService.RequireRole(store.RoleDeployer), handlers.SetServiceHealthCheck)
+				services.GET("/:id/health-check", handlers.GetServiceHealthDefinition)
+				services.PUT("/:id/health-check", authService.RequireRole(store.RoleDeployer), handlers.SetServiceHealthDefinition)
+				services.DELETE("/:id/health-check", authService.RequireRole(store.RoleDeployer), handlers.DeleteServiceHealthDefinition)
+				services.GET("/:id/health/history", handlers.GetServiceHealthHistory)
+				services.POST("/:id/deploy-strategy", authService.RequireRole(store.RoleDeployer), handlers.SetServiceDeployStrategy)
+				services.POST("/:id/auto-rollback", authService.RequireRole(store.RoleDeployer), handlers.SetServiceAutoRollback)
+				services.POST("/:id/scale", authService.RequireRole(store.RoleDeployer), handlers.ScaleService)
+
+				// Volume snapshots and backup schedules
+				services.GET("/:id/backup-schedule", handlers.GetBackupSchedule)
+				services.PUT("/:id/backup-schedule", authService.RequireRole(store.RoleDeployer), handlers.SetBackupSchedule)
+				services.GET("/:id/snapshots", handlers.ListVolumeSnapshots)
+				services.POST("/:id/snapshots", authService.RequireRole(store.RoleDeployer), handlers.CreateVolumeSnapshot)
+				services.POST("/:id/volumes/:name/restore", authService.RequireRole(store.RoleDeployer), handlers.RestoreVolume)
+
+				// Scheduled tasks and their run history
+				services.GET("/:id/task", handlers.GetServiceTask)
+				services.PUT("/:id/task", authService.RequireRole(store.RoleDeployer), handlers.SetServiceTask)
+				services.GET("/:id/runs", handlers.ListTaskRuns)
+				services.POST("/:id/runs", authService.RequireRole(store.RoleDeployer), handlers.RunServiceTask)
+				services.GET("/:id/runs/:run_id", handlers.GetTaskRun)
+
+				// Init containers and sidecars
+				services.GET("/:id/containers", handlers.GetServiceContainers)
+				services.PUT("/:id/containers", authService.RequireRole(store.RoleDeployer), handlers.SetServiceContainers)
+
+				// One-off commands in a new container; GET streams output over WebSocket.
+				// Output may echo decrypted secrets, so the history is deployer+ too.
+				services.POST("/:id/run", authService.RequireRole(store.RoleDeployer), handlers.ServiceRunHandler)
+				services.GET("/:id/run", authService.RequireRole(store.RoleDeployer), handlers.ServiceRunHandler)
+				services.GET("/:id/run/history", authService.RequireRole(store.RoleDeployer), handlers.ListServiceRuns)
+				services.GET("/:id/run/history/:run_id", authService.RequireRole(store.RoleDeployer), handlers.GetServiceRun)
+
 				services.POST("/:id/health-check/run", handlers.RunHealthCheck)
 				services.GET("/:id/health-check/debug", handlers.DebugServiceHealth) // Debug endpoint for troubleshooting
 				services.POST("/:id/unlock", authService.RequireRole(store.RoleDeployer), handlers.UnlockService)
@@ -163,6 +215,22 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 				routes.DELETE("/:id", authService.RequireRole(store.RoleDeployer), handlers.DeleteRoute)
 				routes.GET("/:id/config", handlers.PreviewRouteConfig) // All authenticated users can preview
 				routes.GET("", handlers.ListAllRoutes)                 // All authenticated users
+				routes.POST("", authService.RequireRole(store.RoleDeployer), handlers.CreateRoute)
+
+				// Basic auth users of routes
+				routes.GET("/:id/credentials", authService.RequireRole(store.RoleDeployer), handlers.ListRouteCredentials)
+				routes.PUT("/:id/credentials/:username", authService.RequireRole(store.RoleDeployer), handlers.SetRouteCredential)
+				routes.DELETE("/:id/credentials/:username", authService.RequireRole(store.RoleDeployer), handlers.DeleteRouteCredential)
+			}
+
+			// Maintenance windows (viewer can read, deployer+ can schedule; every-project windows are admin only)
+			maintenance := protected.Group("/maintenance")
+			{
+				maintenance.GET("", handlers.ListMaintenanceWindows)
+				maintenance.POST("", authService.RequireRole(store.RoleDeployer), handlers.CreateMaintenanceWindow)
+				maintenance.GET("/:id", handlers.GetMaintenanceWindow)
+				maintenance.PUT("/:id", authService.RequireRole(store.RoleDeployer), handlers.UpdateMaintenanceWindow)
+				maintenance.DELETE("/:id", authService.RequireRole(store.RoleDeployer), handlers.EndMaintenanceWindow)
 			}
 
 			// System management (admin only)
@@ -220,6 +288,15 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 				registries.POST("/:id/test", authService.RequireAdminRole(), handlers.TestRegistryConnection) // Admin only
 			}
 
+			// Volume management (admin, deployer can manage; viewer can read)
+			volumes := protected.Group("/volumes")
+			{
+				volumes.GET("", handlers.ListVolumes)
+				volumes.POST("", authService.RequireRole(store.RoleDeployer), handlers.CreateVolume)
+				volumes.GET("/:name/usage", handlers.GetVolumeUsage)
+				volumes.DELETE("/:name", authService.RequireRole(store.RoleDeployer), handlers.DeleteVolume)
+			}
+
 			// Audit log (admin only)
 			protected.GET("/audit", authService.RequireAdminRole(), handlers.GetAuditEntries)
 
@@ -345,6 +422,7 @@ func SetupRoutes(r *gin.Engine, handlers *Handlers, corsOrigins []string, authSe
 
 				// Jobs
 				cicd.GET("/jobs/:id", handlers.GetJob)
+				cicd.POST("/jobs/:id/cancel", authService.RequireRole(store.RoleDeployer), handlers.CancelJob)
 			}
 
 			// Direct endpoints for compatibility (non-CI/CD gated)
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/crypto"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// One-off Command API Handlers
+
+// defaultServiceRunsLimit is how many one-off runs the history returns by default
+const defaultServiceRunsLimit = 20
+
+// Types of ServiceRunEvent
+const (
+	ServiceRunEventStarted  = "started"
+	ServiceRunEventOutput   = "output"
+	ServiceRunEventFinished = "finished"
+)
+
+// ServiceRunRequest represents the request to run a one-off command
+type ServiceRunRequest struct {
+	Command        []string `json:"command" binding:"required"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+// ServiceRunEvent is a message streamed over WebSocket while a one-off run executes
+type ServiceRunEvent struct {
+	Type string            `json:"type"`           // started|output|finished
+	Data string            `json:"data,omitempty"` // output, stdout and stderr interleaved
+	Run  *store.ServiceRun `json:"run,omitempty"`
+}
+
+// serviceRunStream writes the output of a one-off run to a WebSocket. A client
+// that goes away does not stop the run; its output is still recorded.
+type serviceRunStream struct {
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	connected bool
+}
+
+func (s *serviceRunStream) send(event ServiceRunEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return
+	}
+	if err := s.conn.WriteJSON(event); err != nil {
+		log.Debug().Err(err).Msg("one-off run client disconnected")
+		s.connected = false
+	}
+}
+
+func (s *serviceRunStream) Write(p []byte) (int, error) {
+	s.send(ServiceRunEvent{Type: ServiceRunEventOutput, Data: string(p)})
+	return len(p), nil
+}
+
+// ServiceRunHandler runs a one-off command, such as a migration, in a new container
+// with the service's image, env vars including decrypted secrets, volumes and
+// project network. The service's own container is left alone.
+//
+// A WebSocket upgrade streams the output as ServiceRunEvents, taking the command
+// from repeated command query parameters. Otherwise the request blocks until the
+// command exits and returns the run with the tail of its output.
+func (h *Handlers) ServiceRunHandler(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	stream := websocket.IsWebSocketUpgrade(c.Request)
+	if c.Request.Method == http.MethodGet && !stream {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "websocket upgrade required, use POST for a blocking request"})
+		return
+	}
+
+	var req ServiceRunRequest
+	if stream {
+		req.Command = c.QueryArray("command")
+		if value := c.Query("timeout_seconds"); value != "" {
+			if req.TimeoutSeconds, err = strconv.Atoi(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout_seconds"})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Command) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "command is required"})
+		return
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = store.DefaultTaskTimeout
+	}
+	if req.TimeoutSeconds < 1 || req.TimeoutSeconds > store.MaxTaskTimeout {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("timeout_seconds must be between 1 and %d", store.MaxTaskTimeout)})
+		return
+	}
+
+	if h.taskRunner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "one-off runs are not available"})
+		return
+	}
+
+	service, err := h.serviceStore.GetService(c.Request.Context(), serviceID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", serviceID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return
+	}
+
+	env, err := h.serviceRunEnv(c.Request.Context(), service)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to resolve one-off run environment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor := audit.GetActorFromContext(c.Request.Context())
+	run := &store.ServiceRun{
+		ServiceID: serviceID,
+		Command:   req.Command,
+		Actor:     actor,
+	}
+	if err := h.store.CreateServiceRun(c.Request.Context(), run); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to create one-off run")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create one-off run"})
+		return
+	}
+
+	// The run outlives a disconnecting client; allow for the image pull on top of the timeout
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+5*time.Minute)
+	defer cancel()
+
+	var output *serviceRunStream
+	var writer io.Writer
+	if stream {
+		conn, err := WebSocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to upgrade to websocket")
+			message := "websocket upgrade failed"
+			run.Status = store.TaskRunFailed
+			run.Error = &message
+			if err := h.store.FinishServiceRun(ctx, run); err != nil {
+				log.Error().Err(err).Int64("run_id", run.ID).Msg("failed to record one-off run")
+			}
+			return
+		}
+		defer conn.Close()
+
+		output = &serviceRunStream{conn: conn, connected: true}
+		output.send(ServiceRunEvent{Type: ServiceRunEventStarted, Run: run})
+		writer = output
+	}
+
+	runErr := h.taskRunner.RunOneOff(ctx, service, run, env, timeout, writer)
+
+	if err := h.store.FinishServiceRun(ctx, run); err != nil {
+		log.Error().Err(err).Int64("run_id", run.ID).Msg("failed to record one-off run")
+	}
+	if err := h.store.PruneServiceRuns(ctx, serviceID, store.ServiceRunRetention); err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to prune one-off runs")
+	}
+
+	if h.auditLogger != nil {
+		meta := map[string]interface{}{
+			"service_name": service.Name,
+			"run_id":       run.ID,
+			"command":      run.Command,
+			"status":       run.Status,
+			"duration_ms":  run.DurationMs,
+		}
+		if run.ExitCode != nil {
+			meta["exit_code"] = *run.ExitCode
+		}
+		h.auditLogger.RecordServiceAction(context.WithoutCancel(c.Request.Context()), actor, audit.ActionServiceRun, strconv.FormatInt(serviceID, 10), meta)
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Int64("run_id", run.ID).
+		Str("status", run.Status).
+		Int64("duration_ms", run.DurationMs).
+		Msg("one-off run finished")
+
+	if stream {
+		// The output was streamed already
+		finished := *run
+		finished.Output = ""
+		output.send(ServiceRunEvent{Type: ServiceRunEventFinished, Run: &finished})
+		return
+	}
+
+	// A non-zero exit code is the command's outcome, not a failure of the request
+	if runErr != nil && run.ExitCode == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": runErr.Error(), "run": run})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+// serviceRunEnv returns the env of a one-off run: the service's env with its
+// environment variables, secrets decrypted, laid over it
+func (h *Handlers) serviceRunEnv(ctx context.Context, service store.Service) (map[string]string, error) {
+	env := make(map[string]string, len(service.Env))
+	for key, value := range service.Env {
+		env[key] = value
+	}
+	if h.envVarStore == nil {
+		return env, nil
+	}
+
+	envVars, err := h.envVarStore.ListEnvVars(ctx, service.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environment variables: %w", err)
+	}
+
+	var masterKey []byte
+	for _, envVar := range envVars {
+		if !envVar.IsSecret {
+			env[envVar.Key] = envVar.Value
+			continue
+		}
+		if masterKey == nil {
+			if masterKey, err = crypto.LoadMasterKeyFromEnv(); err != nil {
+				return nil, fmt.Errorf("cannot decrypt secret environment variables: %w", err)
+			}
+		}
+		plaintext, err := crypto.Decrypt(masterKey, envVar.Nonce, envVar.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s", envVar.Key)
+		}
+		env[envVar.Key] = string(plaintext)
+	}
+
+	return env, nil
+}
+
+// ListServiceRuns returns the one-off runs of a service, newest first
+func (h *Handlers) ListServiceRuns(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	limit := defaultServiceRunsLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > store.ServiceRunRetention {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", store.ServiceRunRetention)})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	runs, err := h.store.ListServiceRuns(ctx, serviceID, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to list one-off runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list one-off runs"})
+		return
+	}
+	if runs == nil {
+		runs = []store.ServiceRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// GetServiceRun returns a single one-off run with the tail of its output
+func (h *Handlers) GetServiceRun(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+	runID, err := strconv.ParseInt(c.Param("run_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	run, err := h.store.GetServiceRun(ctx, runID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && run.ServiceID != serviceID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "one-off run not found"})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("run_id", runID).Msg("failed to get one-off run")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get one-off run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
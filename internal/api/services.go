@@ -14,6 +14,7 @@ import (
 	"github.com/GLINCKER/glinrdock/internal/auth"
 	"github.com/GLINCKER/glinrdock/internal/dockerx"
 	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
@@ -35,6 +36,9 @@ type ServiceStore interface {
 	UnlockService(ctx context.Context, serviceID int64) error
 	ListRoutes(ctx context.Context, serviceID int64) ([]store.Route, error)
 	UpdateServiceContainerID(ctx context.Context, id int64, containerID string) error
+	UpdateServiceDeployStrategy(ctx context.Context, id int64, strategy string) error
+	UpdateServiceRollbackPolicy(ctx context.Context, id int64, enabled bool, windowSeconds int) error
+	UpdateServiceScale(ctx context.Context, id int64, replicas int, loadBalancing string) error
 	DeleteService(ctx context.Context, id int64) error
 	GetProject(ctx context.Context, id int64) (store.Project, error)
 	// Networking methods
@@ -54,11 +58,31 @@ type DockerEngine interface {
 	Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error)
 	Stats(ctx context.Context, id string) (<-chan dockerx.ContainerStats, <-chan error)
 	Inspect(ctx context.Context, containerID string) (dockerx.ContainerStatus, error)
+	Rename(ctx context.Context, id string, newName string) error
+	List(ctx context.Context, labels map[string]string) ([]dockerx.ContainerStatus, error)
+	Pause(ctx context.Context, id string) error
+	Unpause(ctx context.Context, id string) error
+	Wait(ctx context.Context, id string) (int64, error)
+
+	// File operations
+	CopyFrom(ctx context.Context, id string, path string) (io.ReadCloser, error)
+	CopyTo(ctx context.Context, id string, path string, content io.Reader) error
 
 	// Network operations
 	EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error
 	ConnectNetwork(ctx context.Context, networkName, containerID string, aliases []string) error
 	DisconnectNetwork(ctx context.Context, networkName, containerID string) error
+
+	// Volume operations
+	ListVolumes(ctx context.Context, labels map[string]string) ([]dockerx.Volume, error)
+	CreateVolume(ctx context.Context, name string, labels map[string]string) (dockerx.Volume, error)
+	RemoveVolume(ctx context.Context, name string) error
+	VolumeUsage(ctx context.Context, name string) (dockerx.VolumeUsage, error)
+
+	// Exec operations
+	Exec(ctx context.Context, id string, command []string, size dockerx.TerminalSize) (*dockerx.ExecSession, error)
+	ResizeExec(ctx context.Context, execID string, size dockerx.TerminalSize) error
+	ExecExitCode(ctx context.Context, execID string) (int, error)
 }
 
 // CreateService creates a new service and its container
@@ -75,6 +99,22 @@ func (h *Handlers) CreateService(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := spec.Resources.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := spec.RestartPolicy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.ValidateVolumes(spec.Volumes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if spec.Kind == store.ServiceKindTask && h.taskRunner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduled tasks are not available"})
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
 	defer cancel()
@@ -108,65 +148,27 @@ func (h *Handlers) CreateService(c *gin.Context) {
 		return
 	}
 
-	// Create container with labels
-	containerName := fmt.Sprintf("glinr_%d_%s", projectID, spec.Name)
-	labels := map[string]string{
-		"glinr.project_id": strconv.FormatInt(projectID, 10),
-		"glinr.service_id": strconv.FormatInt(service.ID, 10),
-		"glinr.managed":    "true",
-	}
+	// Scheduled tasks get a container per run, not one up front
+	if service.IsTask() {
+		log.Info().
+			Int64("project_id", projectID).
+			Int64("service_id", service.ID).
+			Str("schedule", service.Task.Schedule).
+			Str("image", spec.Image).
+			Msg("scheduled task created successfully")
 
-	containerSpec := dockerx.ContainerSpec{
-		Image: spec.Image,
-		Env:   spec.Env,
-		Ports: spec.Ports,
+		c.JSON(http.StatusCreated, service)
+		return
 	}
 
-	containerID, err := h.dockerEngine.Create(ctx, containerName, containerSpec, labels)
+	containerID, networkName, err := h.createServiceContainer(ctx, project, service)
 	if err != nil {
 		// Cleanup: delete service record if container creation fails
 		h.serviceStore.DeleteService(ctx, service.ID)
-		log.Error().Err(err).Str("container_name", containerName).Msg("failed to create container")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create container"})
 		return
 	}
 
-	// Store the container ID in the service record
-	err = h.serviceStore.UpdateServiceContainerID(ctx, service.ID, containerID)
-	if err != nil {
-		log.Error().Err(err).Int64("service_id", service.ID).Str("container_id", containerID).Msg("failed to store container ID")
-		// Don't fail the whole operation, just log the error
-	}
-
-	// Set up project networking
-	var networkName string
-	if project.NetworkName != nil && *project.NetworkName != "" {
-		networkName = *project.NetworkName
-	} else {
-		// Generate network name if not set (for backward compatibility)
-		networkName = store.GenerateProjectNetworkName(projectID)
-	}
-
-	// Ensure project network exists
-	networkLabels := map[string]string{
-		"glinr.project_id": strconv.FormatInt(projectID, 10),
-		"glinr.managed":    "true",
-		"owner":            "glinrdock",
-	}
-	if err := h.dockerEngine.EnsureNetwork(ctx, networkName, networkLabels); err != nil {
-		log.Error().Err(err).Str("network", networkName).Msg("failed to ensure project network")
-		// Don't fail the whole operation, just log the error
-	} else {
-		// Connect container to project network with aliases
-		aliases := store.GenerateServiceAliases(project.Name, service.Name)
-		if err := h.dockerEngine.ConnectNetwork(ctx, networkName, containerID, aliases); err != nil {
-			log.Error().Err(err).Str("network", networkName).Str("container", containerID).Msg("failed to connect container to project network")
-			// Don't fail the whole operation, just log the error
-		} else {
-			log.Info().Str("network", networkName).Strs("aliases", aliases).Msg("connected service to project network")
-		}
-	}
-
 	// Audit logging
 	if h.auditLogger != nil {
 		ctx := c.Request.Context()
@@ -206,6 +208,57 @@ func (h *Handlers) CreateService(c *gin.Context) {
 	c.JSON(http.StatusCreated, service)
 }
 
+// createServiceContainer creates the container of a newly created service, stores its ID
+// and attaches it to the project network. Network failures are logged, not returned.
+func (h *Handlers) createServiceContainer(ctx context.Context, project store.Project, service store.Service) (string, string, error) {
+	containerName := fmt.Sprintf("glinr_%d_%s", project.ID, service.Name)
+	containerSpec := dockerx.ServiceContainerSpec(service, service.Image)
+
+	containerID, err := h.dockerEngine.Create(ctx, containerName, containerSpec, dockerx.ServiceLabels(service))
+	if err != nil {
+		log.Error().Err(err).Str("container_name", containerName).Msg("failed to create container")
+		return "", "", err
+	}
+
+	// Store the container ID in the service record
+	err = h.serviceStore.UpdateServiceContainerID(ctx, service.ID, containerID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Str("container_id", containerID).Msg("failed to store container ID")
+		// Don't fail the whole operation, just log the error
+	}
+
+	// Set up project networking
+	var networkName string
+	if project.NetworkName != nil && *project.NetworkName != "" {
+		networkName = *project.NetworkName
+	} else {
+		// Generate network name if not set (for backward compatibility)
+		networkName = store.GenerateProjectNetworkName(project.ID)
+	}
+
+	// Ensure project network exists
+	networkLabels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(project.ID, 10),
+		"glinr.managed":    "true",
+		"owner":            "glinrdock",
+	}
+	if err := h.dockerEngine.EnsureNetwork(ctx, networkName, networkLabels); err != nil {
+		log.Error().Err(err).Str("network", networkName).Msg("failed to ensure project network")
+		// Don't fail the whole operation, just log the error
+	} else {
+		// Connect container to project network with aliases
+		aliases := store.GenerateServiceAliases(project.Name, service.Name)
+		if err := h.dockerEngine.ConnectNetwork(ctx, networkName, containerID, aliases); err != nil {
+			log.Error().Err(err).Str("network", networkName).Str("container", containerID).Msg("failed to connect container to project network")
+			// Don't fail the whole operation, just log the error
+		} else {
+			log.Info().Str("network", networkName).Strs("aliases", aliases).Msg("connected service to project network")
+		}
+	}
+
+	return containerID, networkName, nil
+}
+
 // ListServices returns all services for a project
 func (h *Handlers) ListServices(c *gin.Context) {
 	projectIDStr := c.Param("id")
@@ -273,7 +326,7 @@ type ServiceDetailResponse struct {
 	CreatedAt       time.Time             `json:"created_at"`
 	UpdatedAt       *time.Time            `json:"updated_at,omitempty"`
 	Ports           []store.PortMap       `json:"ports"`
-	Volumes         []VolumeMap           `json:"volumes,omitempty"`
+	Volumes         []store.VolumeMap     `json:"volumes,omitempty"`
 	EnvSummaryCount int                   `json:"env_summary_count"`
 	LastDeployAt    *time.Time            `json:"last_deploy_at,omitempty"`
 	ContainerID     *string               `json:"container_id,omitempty"`
@@ -281,6 +334,11 @@ type ServiceDetailResponse struct {
 	StartedAt       *time.Time            `json:"started_at,omitempty"`
 	Network         *store.ServiceNetwork `json:"network,omitempty"` // Network information
 	Aliases         []string              `json:"aliases,omitempty"` // DNS aliases
+	Kind            string                `json:"kind"`              // service|task
+	Task            *store.TaskConfig     `json:"task,omitempty"`    // schedule of scheduled tasks
+
+	InitContainers []store.ServiceContainer `json:"init_containers,omitempty"`
+	Sidecars       []SidecarStatus          `json:"sidecars,omitempty"` // declared sidecars with their state
 
 	// Health and crash loop fields
 	DesiredState    string     `json:"desired_state"`
@@ -293,13 +351,6 @@ type ServiceDetailResponse struct {
 	LastProbeAt     *time.Time `json:"last_probe_at,omitempty"`
 }
 
-// VolumeMap represents a volume mapping from host to container
-type VolumeMap struct {
-	Host      string `json:"host"`
-	Container string `json:"container"`
-	ReadOnly  bool   `json:"ro"`
-}
-
 // GetService returns a single service by ID with enhanced details
 func (h *Handlers) GetService(c *gin.Context) {
 	idStr := c.Param("id")
@@ -331,8 +382,9 @@ func (h *Handlers) GetService(c *gin.Context) {
 		Image:           service.Image,
 		CreatedAt:       service.CreatedAt,
 		Ports:           service.Ports,
-		Volumes:         []VolumeMap{}, // TODO: Add volume support to service spec
+		Volumes:         service.Volumes,
 		EnvSummaryCount: len(service.Env),
+		Kind:            service.Kind,
 
 		// Health and crash loop fields
 		DesiredState:    service.DesiredState,
@@ -353,7 +405,16 @@ func (h *Handlers) GetService(c *gin.Context) {
 		containerIdentifier = fmt.Sprintf("glinr_%d_%s", service.ProjectID, service.Name)
 	}
 
-	if h.eventCache != nil {
+	if service.IsTask() {
+		// Tasks only have containers while a run is in progress
+		response.Status = "scheduled"
+		if config, err := h.loadTaskConfig(ctx, service.ID); err == nil {
+			response.Task = config
+			if config.Suspended {
+				response.Status = "suspended"
+			}
+		}
+	} else if h.eventCache != nil {
 		if state, exists := h.eventCache.GetServiceState(service.ID); exists {
 			response.Status = state.Status
 			response.ContainerID = &state.ContainerID
@@ -405,6 +466,17 @@ func (h *Handlers) GetService(c *gin.Context) {
 	// TODO: Add last deploy time from deployment records when available
 	// response.LastDeployAt = service.LastDeployAt
 
+	if !service.IsTask() {
+		if containers, err := h.loadServiceContainers(ctx, service.ID); err == nil {
+			response.InitContainers = containers.InitContainers
+			if len(containers.Sidecars) > 0 {
+				response.Sidecars = h.sidecarStatuses(ctx, service.ID, containers.Sidecars)
+			}
+		} else {
+			log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to load init containers and sidecars")
+		}
+	}
+
 	// Populate network information
 	if network, err := h.serviceStore.GetServiceNetwork(ctx, service.ID); err == nil {
 		response.Network = &network
@@ -456,6 +528,12 @@ func (h *Handlers) DeleteService(c *gin.Context) {
 	if err := h.dockerEngine.Remove(ctx, containerName); err != nil {
 		log.Warn().Err(err).Str("container", containerName).Msg("failed to remove container (may not exist)")
 	}
+	if err := h.getReplicas().RemoveAll(ctx, id); err != nil {
+		log.Warn().Err(err).Int64("service_id", id).Msg("failed to remove service replicas")
+	}
+	if err := h.getSidecars().RemoveAll(ctx, id); err != nil {
+		log.Warn().Err(err).Int64("service_id", id).Msg("failed to remove service sidecars")
+	}
 
 	// Remove service record
 	err = h.serviceStore.DeleteService(ctx, id)
@@ -486,14 +564,16 @@ func (h *Handlers) DeleteService(c *gin.Context) {
 
 // ServiceConfig represents service configuration for editing
 type ServiceConfig struct {
-	ID          int64             `json:"id"`
-	ProjectID   int64             `json:"project_id"`
-	Name        string            `json:"name"`
-	Description *string           `json:"description,omitempty"`
-	Image       string            `json:"image"`
-	Env         []store.EnvVar    `json:"env"`
-	Ports       []store.PortMap   `json:"ports"`
-	Volumes     []store.VolumeMap `json:"volumes"`
+	ID            int64                `json:"id"`
+	ProjectID     int64                `json:"project_id"`
+	Name          string               `json:"name"`
+	Description   *string              `json:"description,omitempty"`
+	Image         string               `json:"image"`
+	Env           []store.EnvVar       `json:"env"`
+	Ports         []store.PortMap      `json:"ports"`
+	Volumes       []store.VolumeMap    `json:"volumes"`
+	Resources     store.ResourceLimits `json:"resources"`
+	RestartPolicy store.RestartPolicy  `json:"restart_policy"`
 }
 
 // GetServiceConfig returns service configuration with environment variable masking
@@ -540,14 +620,16 @@ func (h *Handlers) GetServiceConfig(c *gin.Context) {
 	}
 
 	config := ServiceConfig{
-		ID:          service.ID,
-		ProjectID:   service.ProjectID,
-		Name:        service.Name,
-		Description: service.Description,
-		Image:       service.Image,
-		Env:         envVars,
-		Ports:       service.Ports,
-		Volumes:     service.Volumes,
+		ID:            service.ID,
+		ProjectID:     service.ProjectID,
+		Name:          service.Name,
+		Description:   service.Description,
+		Image:         service.Image,
+		Env:           envVars,
+		Ports:         service.Ports,
+		Resources:     service.Resources,
+		RestartPolicy: service.RestartPolicy,
+		Volumes:       service.Volumes,
 	}
 
 	// Sample audit logging (1:20) for config read events
@@ -664,6 +746,9 @@ type ServiceConfigUpdate struct {
 	Env         []store.EnvVar    `json:"env"`
 	Ports       []store.PortMap   `json:"ports"`
 	Volumes     []store.VolumeMap `json:"volumes"`
+	// Omitted resources or restart policy keep the current settings
+	Resources     *store.ResourceLimits `json:"resources"`
+	RestartPolicy *store.RestartPolicy  `json:"restart_policy"`
 }
 
 // UpdateServiceConfig updates service configuration with validation
@@ -700,6 +785,14 @@ func (h *Handlers) UpdateServiceConfig(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if existingService.IsTask() && len(updateReq.Ports) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks cannot publish ports"})
+		return
+	}
+	if err := store.ValidateDeployStrategyPorts(existingService.DeployStrategy, updateReq.Ports); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Convert EnvVar slice to map, preserving existing secrets
 	envMap := make(map[string]string)
@@ -714,17 +807,38 @@ func (h *Handlers) UpdateServiceConfig(c *gin.Context) {
 		}
 	}
 
+	resources := existingService.Resources
+	if updateReq.Resources != nil {
+		resources = *updateReq.Resources
+	}
+	restartPolicy := existingService.RestartPolicy
+	if updateReq.RestartPolicy != nil {
+		restartPolicy = *updateReq.RestartPolicy
+	}
+
 	// Build updated service
 	updatedService := store.Service{
-		ID:          id,
-		ProjectID:   existingService.ProjectID,
-		Name:        updateReq.Name,
-		Description: updateReq.Description,
-		Image:       updateReq.Image,
-		Env:         envMap,
-		Ports:       updateReq.Ports,
-		Volumes:     updateReq.Volumes,
-		CreatedAt:   existingService.CreatedAt,
+		ID:            id,
+		ProjectID:     existingService.ProjectID,
+		Name:          updateReq.Name,
+		Description:   updateReq.Description,
+		Image:         updateReq.Image,
+		Env:           envMap,
+		Ports:         updateReq.Ports,
+		Volumes:       updateReq.Volumes,
+		Resources:     resources,
+		RestartPolicy: restartPolicy,
+		CreatedAt:     existingService.CreatedAt,
+		// Carried over so the rollout knows how to replace the container
+		ContainerID:    existingService.ContainerID,
+		RegistryID:     existingService.RegistryID,
+		DeployStrategy: existingService.DeployStrategy,
+		UpstreamHost:   existingService.UpstreamHost,
+		AutoRollback:   existingService.AutoRollback,
+		RollbackWindow: existingService.RollbackWindow,
+		Replicas:       existingService.Replicas,
+		LoadBalancing:  existingService.LoadBalancing,
+		Kind:           existingService.Kind,
 	}
 
 	// Update service in store
@@ -753,8 +867,11 @@ func (h *Handlers) UpdateServiceConfig(c *gin.Context) {
 	portsChanged := !arePortMapsEqual(existingService.Ports, updateReq.Ports)
 	volumesChanged := !areVolumeMapsEqual(existingService.Volumes, updateReq.Volumes)
 	envChanged := !areEnvMapsEqual(existingService.Env, envMap)
+	resourcesChanged := existingService.Resources != resources || existingService.RestartPolicy != restartPolicy
 
-	needsRecreation := nameChanged || imageChanged || portsChanged || volumesChanged || envChanged
+	// Scheduled tasks pick up the new configuration with their next run
+	needsRecreation := !existingService.IsTask() &&
+		(nameChanged || imageChanged || portsChanged || volumesChanged || envChanged || resourcesChanged)
 
 	if needsRecreation {
 		log.Info().
@@ -764,11 +881,16 @@ func (h *Handlers) UpdateServiceConfig(c *gin.Context) {
 			Bool("ports_changed", portsChanged).
 			Bool("volumes_changed", volumesChanged).
 			Bool("env_changed", envChanged).
+			Bool("resources_changed", resourcesChanged).
 			Msg("service needs container recreation")
 
 		// WARNING: Container recreation will lose any data stored inside the container
 		// Data should be stored in volumes to persist across recreations
-		if err := h.recreateServiceContainer(ctx, id, updatedService); err != nil {
+		err := h.recreateServiceContainer(ctx, id, updatedService)
+		if imageChanged {
+			h.recordImageDeployment(ctx, existingService, updateReq.Image, err)
+		}
+		if err != nil {
 			log.Error().Err(err).Int64("service_id", id).Msg("failed to recreate service container")
 			// Don't fail the config update, but warn the user
 			c.JSON(http.StatusOK, gin.H{
@@ -838,18 +960,20 @@ func validateServiceConfig(config ServiceConfigUpdate) error {
 		hostPorts[port.Host] = true
 	}
 
-	// Validate volumes - safe host paths
-	for _, volume := range config.Volumes {
-		if volume.Host == "" || volume.Container == "" {
-			return fmt.Errorf("volume paths cannot be empty")
+	// Validate volumes - safe host paths or valid volume names
+	if err := store.ValidateVolumes(config.Volumes); err != nil {
+		return err
+	}
+
+	// Validate resource limits and restart policy when provided
+	if config.Resources != nil {
+		if err := config.Resources.Validate(); err != nil {
+			return err
 		}
-		// Basic security check - prevent dangerous host paths
-		if strings.HasPrefix(volume.Host, "/") &&
-			(strings.HasPrefix(volume.Host, "/etc") ||
-				strings.HasPrefix(volume.Host, "/sys") ||
-				strings.HasPrefix(volume.Host, "/proc") ||
-				strings.HasPrefix(volume.Host, "/dev")) {
-			return fmt.Errorf("host path not allowed for security reasons: %s", volume.Host)
+	}
+	if config.RestartPolicy != nil {
+		if err := config.RestartPolicy.Validate(); err != nil {
+			return err
 		}
 	}
 
@@ -1101,6 +1225,17 @@ func extractRepoName(repoURL string) string {
 // WARNING: This will destroy the existing container and any data stored inside it
 // Data should be stored in volumes to persist across container recreations
 func (h *Handlers) recreateServiceContainer(ctx context.Context, serviceID int64, updatedService store.Service) error {
+	// Falling back to recreate would cause the downtime blue/green was chosen to avoid
+	if updatedService.DeployStrategy == store.DeployStrategyBlueGreen {
+		if err := store.ValidateDeployStrategyPorts(updatedService.DeployStrategy, updatedService.Ports); err != nil {
+			return err
+		}
+		if h.reloadProxy == nil {
+			return fmt.Errorf("blue/green deploys require the nginx proxy to be enabled")
+		}
+		return h.blueGreenServiceContainer(ctx, serviceID, updatedService)
+	}
+
 	log.Warn().
 		Int64("service_id", serviceID).
 		Msg("RECREATING CONTAINER - Any data stored inside the container (not in volumes) will be lost")
@@ -1109,6 +1244,7 @@ func (h *Handlers) recreateServiceContainer(ctx context.Context, serviceID int64
 	var existingContainerID string
 	if discoveredID, err := h.discoverContainerByServiceID(ctx, serviceID); err == nil {
 		existingContainerID = discoveredID
+		h.stopSidecars(ctx, serviceID)
 		log.Info().Str("container_id", existingContainerID).Int64("service_id", serviceID).Msg("stopping existing container")
 		if stopErr := h.dockerEngine.Stop(ctx, existingContainerID); stopErr != nil {
 			log.Warn().Err(stopErr).Str("container_id", existingContainerID).Msg("failed to stop existing container")
@@ -1128,20 +1264,11 @@ func (h *Handlers) recreateServiceContainer(ctx context.Context, serviceID int64
 	}
 
 	// Create new container with updated configuration and proper labels
+	updatedService.ID = serviceID
 	containerName := fmt.Sprintf("glinr_%d_%s", updatedService.ProjectID, updatedService.Name)
-	labels := map[string]string{
-		"glinr.project_id": strconv.FormatInt(updatedService.ProjectID, 10),
-		"glinr.service_id": strconv.FormatInt(serviceID, 10),
-		"glinr.managed":    "true",
-	}
-
-	containerSpec := dockerx.ContainerSpec{
-		Image: updatedService.Image,
-		Env:   updatedService.Env,
-		Ports: updatedService.Ports,
-	}
+	containerSpec := dockerx.ServiceContainerSpec(updatedService, updatedService.Image)
 
-	containerID, err := h.dockerEngine.Create(ctx, containerName, containerSpec, labels)
+	containerID, err := h.dockerEngine.Create(ctx, containerName, containerSpec, dockerx.ServiceLabels(updatedService))
 	if err != nil {
 		return fmt.Errorf("failed to create new container: %w", err)
 	}
@@ -1160,6 +1287,9 @@ func (h *Handlers) recreateServiceContainer(ctx context.Context, serviceID int64
 		}
 	}
 
+	h.ensureSidecars(ctx, updatedService, containerID)
+	h.replaceReplicas(ctx, serviceID, updatedService)
+
 	log.Info().
 		Int64("service_id", serviceID).
 		Str("container_id", containerID).
@@ -1170,6 +1300,315 @@ func (h *Handlers) recreateServiceContainer(ctx context.Context, serviceID int64
 	return nil
 }
 
+// blueGreenServiceContainer starts the new container alongside the old one and
+// switches the service's nginx upstream to it once it is healthy
+func (h *Handlers) blueGreenServiceContainer(ctx context.Context, serviceID int64, updatedService store.Service) error {
+	updatedService.ID = serviceID
+	containers, err := h.loadServiceContainers(ctx, serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to load init containers and sidecars: %w", err)
+	}
+
+	// Init containers, health checks and draining outlast the request timeout, and an interrupted switch must still finish
+	timeout := rollout.DefaultHealthTimeout + rollout.DefaultDrainPeriod + time.Minute +
+		time.Duration(len(containers.InitContainers))*(store.InitContainerTimeout*time.Second+time.Minute)
+	rolloutCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	if discoveredID, err := h.discoverContainerByServiceID(rolloutCtx, serviceID); err == nil {
+		updatedService.ContainerID = &discoveredID
+	}
+
+	var registryID string
+	if updatedService.RegistryID != nil {
+		registryID = *updatedService.RegistryID
+	}
+	if err := h.dockerEngine.Pull(rolloutCtx, updatedService.Image, registryID); err != nil {
+		log.Warn().Err(err).Str("image", updatedService.Image).Msg("failed to pull image, continuing with local")
+	}
+
+	containerSpec := dockerx.ServiceContainerSpec(updatedService, updatedService.Image)
+	blueGreen := rollout.NewBlueGreen(h.dockerEngine, h.getHealthProber(), h.store, h.reloadProxy)
+	containerID, err := blueGreen.DeployService(rolloutCtx, updatedService, containers, containerSpec,
+		dockerx.ServiceLabels(updatedService), h.getSidecars(), h.getReplicas())
+	if err != nil {
+		return fmt.Errorf("blue/green rollout failed: %w", err)
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Str("container_id", containerID).
+		Msg("service container replaced with blue/green rollout")
+
+	return nil
+}
+
+// replaceReplicas moves a service's additional replicas onto its new configuration
+// once the primary container has been replaced
+func (h *Handlers) replaceReplicas(ctx context.Context, serviceID int64, service store.Service) {
+	service.ID = serviceID
+	containerSpec := dockerx.ServiceContainerSpec(service, service.Image)
+
+	if err := h.getReplicas().Replace(ctx, service, containerSpec, dockerx.ServiceLabels(service)); err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to replace service replicas")
+	}
+}
+
+// ScaleService sets the number of replicas of a service and how nginx balances between them
+func (h *Handlers) ScaleService(c *gin.Context) {
+	serviceIDStr := c.Param("id")
+	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	var request struct {
+		Replicas      int    `json:"replicas" binding:"required"`
+		LoadBalancing string `json:"load_balancing"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if request.Replicas < 1 || request.Replicas > store.MaxReplicas {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("replicas must be between 1 and %d", store.MaxReplicas)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	service, err := h.serviceStore.GetService(ctx, serviceID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+		return
+	}
+
+	if request.LoadBalancing == "" {
+		request.LoadBalancing = service.LoadBalancing
+	}
+	if !store.IsValidLoadBalancing(request.LoadBalancing) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "load_balancing must be one of: round_robin, least_conn, ip_hash"})
+		return
+	}
+
+	// Replicas beyond the first are only reachable through the nginx upstream
+	if request.Replicas > 1 && h.reloadProxy == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "running more than one replica requires the nginx proxy to be enabled"})
+		return
+	}
+
+	if err := h.serviceStore.UpdateServiceScale(ctx, serviceID, request.Replicas, request.LoadBalancing); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to update service scale")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update service scale"})
+		return
+	}
+
+	previousReplicas := service.Replicas
+	service.Replicas = request.Replicas
+	service.LoadBalancing = request.LoadBalancing
+
+	containerSpec := dockerx.ServiceContainerSpec(service, service.Image)
+
+	if err := h.getReplicas().Scale(ctx, service, containerSpec, dockerx.ServiceLabels(service)); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to scale service")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to scale service: %v", err)})
+		return
+	}
+
+	// Register the new set of replicas in the nginx upstream
+	if h.reloadProxy != nil {
+		if err := h.reloadProxy(ctx); err != nil {
+			log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to reload nginx after scaling")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "service scaled but nginx reload failed"})
+			return
+		}
+	}
+
+	if h.auditLogger != nil {
+		actor := auth.CurrentTokenName(c)
+		if actor == "" {
+			actor = "system"
+		}
+		h.auditLogger.RecordServiceAction(ctx, actor, audit.ActionServiceScale, strconv.FormatInt(serviceID, 10), map[string]interface{}{
+			"service_name":   service.Name,
+			"project_id":     service.ProjectID,
+			"from_replicas":  previousReplicas,
+			"to_replicas":    request.Replicas,
+			"load_balancing": request.LoadBalancing,
+		})
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Int("from_replicas", previousReplicas).
+		Int("to_replicas", request.Replicas).
+		Str("load_balancing", request.LoadBalancing).
+		Msg("service scaled")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"replicas":       request.Replicas,
+		"load_balancing": request.LoadBalancing,
+	})
+}
+
+// SetServiceDeployStrategy selects how new versions of a service are rolled out
+func (h *Handlers) SetServiceDeployStrategy(c *gin.Context) {
+	serviceIDStr := c.Param("id")
+	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	var request struct {
+		Strategy string `json:"strategy" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if !store.IsValidDeployStrategy(request.Strategy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "strategy must be one of: recreate, blue_green"})
+		return
+	}
+
+	if request.Strategy == store.DeployStrategyBlueGreen && h.reloadProxy == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "blue/green deploys require the nginx proxy to be enabled"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	service, err := h.serviceStore.GetService(ctx, serviceID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+		return
+	}
+
+	// The new container starts while the old one holds the host ports, so it could only get other ones
+	if err := store.ValidateDeployStrategyPorts(request.Strategy, service.Ports); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.serviceStore.UpdateServiceDeployStrategy(ctx, serviceID, request.Strategy); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to update service deploy strategy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update deploy strategy"})
+		return
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Str("deploy_strategy", request.Strategy).
+		Msg("deploy strategy configured")
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "deploy_strategy": request.Strategy})
+}
+
+// SetServiceAutoRollback configures automatic rollback after deploys
+func (h *Handlers) SetServiceAutoRollback(c *gin.Context) {
+	serviceIDStr := c.Param("id")
+	serviceID, err := strconv.ParseInt(serviceIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	var request struct {
+		Enabled       bool `json:"enabled"`
+		WindowSeconds int  `json:"window_seconds"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if request.WindowSeconds == 0 {
+		request.WindowSeconds = store.DefaultRollbackWindow
+	}
+	if request.WindowSeconds < 1 || request.WindowSeconds > store.MaxRollbackWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("window_seconds must be between 1 and %d", store.MaxRollbackWindow)})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	// Verify service exists
+	if _, err := h.serviceStore.GetService(ctx, serviceID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service not found"})
+		return
+	}
+
+	if err := h.serviceStore.UpdateServiceRollbackPolicy(ctx, serviceID, request.Enabled, request.WindowSeconds); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to update service rollback policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update rollback policy"})
+		return
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Bool("auto_rollback", request.Enabled).
+		Int("rollback_window_seconds", request.WindowSeconds).
+		Msg("auto rollback configured")
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":                 true,
+		"auto_rollback":           request.Enabled,
+		"rollback_window_seconds": request.WindowSeconds,
+	})
+}
+
+// recordImageDeployment adds an image change made through the config editor to
+// the deployment history and starts a rollback watch when the service opts in
+func (h *Handlers) recordImageDeployment(ctx context.Context, previous store.Service, image string, deployErr error) {
+	if h.store == nil {
+		return
+	}
+
+	// Seed the history with the running image so there is something to roll back to
+	if _, err := h.store.GetLatestDeployment(ctx, previous.ID); err == store.ErrNotFound {
+		seed := &store.Deployment{
+			ProjectID: previous.ProjectID,
+			ServiceID: previous.ID,
+			ImageTag:  previous.Image,
+			Status:    "success",
+		}
+		if err := h.store.CreateDeployment(ctx, seed); err != nil {
+			log.Error().Err(err).Int64("service_id", previous.ID).Msg("failed to record initial deployment")
+		}
+	}
+
+	deployment := &store.Deployment{
+		ProjectID: previous.ProjectID,
+		ServiceID: previous.ID,
+		ImageTag:  image,
+		Status:    "success",
+	}
+	if deployErr != nil {
+		reason := deployErr.Error()
+		deployment.Status = "failed"
+		deployment.Reason = &reason
+	}
+	if err := h.store.CreateDeployment(ctx, deployment); err != nil {
+		log.Error().Err(err).Int64("service_id", previous.ID).Msg("failed to record deployment")
+		return
+	}
+
+	if deployErr == nil && previous.AutoRollback && h.rollbackWatcher != nil {
+		if _, err := h.rollbackWatcher.Watch(deployment, time.Duration(previous.RollbackWindow)*time.Second); err != nil {
+			log.Error().Err(err).Int64("deployment_id", deployment.ID).Msg("failed to watch deployment for automatic rollback")
+		}
+	}
+}
+
 // arePortMapsEqual compares two PortMap slices for equality
 func arePortMapsEqual(a, b []store.PortMap) bool {
 	if len(a) != len(b) {
@@ -1411,24 +1850,35 @@ func (h *Handlers) AdoptContainerHandler(c *gin.Context) {
 		}
 	}
 
-	// Extract volume mounts
+	// Extract volume mounts, keeping named volumes by name so they survive recreation
 	var volumes []store.VolumeMap
 	for _, mount := range containerInfo.Mounts {
 		if mount.Type == "bind" || mount.Type == "volume" {
-			volumes = append(volumes, store.VolumeMap{
-				Host:      mount.Source,
+			host := mount.Source
+			if mount.Type == "volume" {
+				host = mount.Name
+			}
+			volume := store.VolumeMap{
+				Host:      host,
 				Container: mount.Destination,
 				ReadOnly:  !mount.RW,
-			})
+			}
+			// Mounts we would refuse on a managed service stay on the adopted container only
+			if err := volume.Validate(); err != nil {
+				log.Warn().Err(err).Str("container_id", req.ContainerID).Msg("skipping volume mount of adopted container")
+				continue
+			}
+			volumes = append(volumes, volume)
 		}
 	}
 
 	// Create the service specification
 	spec := store.ServiceSpec{
-		Name:  req.ServiceName,
-		Image: containerInfo.Config.Image,
-		Env:   envMap,
-		Ports: ports,
+		Name:    req.ServiceName,
+		Image:   containerInfo.Config.Image,
+		Env:     envMap,
+		Ports:   ports,
+		Volumes: volumes,
 	}
 
 	// Create the service in the database
@@ -1707,7 +2157,22 @@ func (h *Handlers) RunHealthCheck(c *gin.Context) {
 
 // getHealthProber returns a configured health prober instance
 func (h *Handlers) getHealthProber() *health.Prober {
-	return health.NewProber(h.serviceStore)
+	prober := health.NewProber(h.serviceStore)
+	if h.envVarStore != nil {
+		prober.SetEnvVarStore(h.envVarStore)
+	}
+	if h.store != nil {
+		prober.SetHealthCheckStore(h.store)
+	}
+	if h.dockerEngine != nil {
+		prober.SetEngine(h.dockerEngine)
+	}
+	return prober
+}
+
+// getReplicas returns a manager for the additional replicas of services
+func (h *Handlers) getReplicas() *rollout.Replicas {
+	return rollout.NewReplicas(h.dockerEngine, h.serviceStore)
 }
 
 // getCrashLoopDetector returns a configured crash loop detector instance
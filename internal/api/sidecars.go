@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Init Container and Sidecar API Handlers
+
+// SidecarStatus is a declared sidecar with the state of its container
+type SidecarStatus struct {
+	store.ServiceContainer
+	Status      string `json:"status"` // running|stopped|not_created
+	ContainerID string `json:"container_id,omitempty"`
+}
+
+// ServiceContainersResponse lists the init containers and sidecars of a service
+type ServiceContainersResponse struct {
+	InitContainers []store.ServiceContainer `json:"init_containers"`
+	Sidecars       []SidecarStatus          `json:"sidecars"`
+}
+
+// getSidecars returns an init container and sidecar manager
+func (h *Handlers) getSidecars() *rollout.Sidecars {
+	return rollout.NewSidecars(h.dockerEngine, h.serviceStore)
+}
+
+// loadServiceContainers returns the init containers and sidecars a service declares
+func (h *Handlers) loadServiceContainers(ctx context.Context, serviceID int64) (*store.ServiceContainers, error) {
+	if h.store == nil {
+		return &store.ServiceContainers{}, nil
+	}
+	return h.store.GetServiceContainers(ctx, serviceID)
+}
+
+// runInitContainers runs the init containers of a service before its container
+// starts. They run to completion even if the client goes away.
+func (h *Handlers) runInitContainers(ctx context.Context, service store.Service, inits []store.ServiceContainer) error {
+	if len(inits) == 0 {
+		return nil
+	}
+	initCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(len(inits))*(store.InitContainerTimeout*time.Second+time.Minute))
+	defer cancel()
+	return h.getSidecars().RunInit(initCtx, service, inits)
+}
+
+// ensureSidecars brings the sidecars of a service in line with its declarations
+// next to its primary container. A container that is not running gets no
+// sidecars; they are created when it starts. Failures are logged, the service
+// keeps running.
+func (h *Handlers) ensureSidecars(ctx context.Context, service store.Service, mainID string) {
+	containers, err := h.loadServiceContainers(ctx, service.ID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to load service sidecars")
+		return
+	}
+
+	sidecars := containers.Sidecars
+	status, err := h.dockerEngine.Inspect(ctx, mainID)
+	if err != nil || status.State != "running" {
+		sidecars = nil
+	} else if status.ID != "" {
+		// Sidecars record the full ID of the container they are attached to
+		mainID = status.ID
+	}
+	if err := h.getSidecars().Ensure(ctx, service, sidecars, mainID); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to ensure service sidecars")
+	}
+}
+
+// stopSidecars stops the sidecars of a service ahead of its primary container
+func (h *Handlers) stopSidecars(ctx context.Context, serviceID int64) {
+	if err := h.getSidecars().Stop(ctx, serviceID); err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to stop service sidecars")
+	}
+}
+
+// sidecarContainerID returns the container of a service's sidecar by name
+func (h *Handlers) sidecarContainerID(ctx context.Context, serviceID int64, name string) (string, bool) {
+	sidecars, err := h.getSidecars().List(ctx, serviceID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to list service sidecars")
+		return "", false
+	}
+	for _, container := range sidecars {
+		if container.Labels[rollout.SidecarLabel] == name {
+			return container.ID, true
+		}
+	}
+	return "", false
+}
+
+// sidecarStatuses pairs the declared sidecars of a service with their containers
+func (h *Handlers) sidecarStatuses(ctx context.Context, serviceID int64, sidecars []store.ServiceContainer) []SidecarStatus {
+	byName := make(map[string]dockerx.ContainerStatus)
+	if existing, err := h.getSidecars().List(ctx, serviceID); err == nil {
+		for _, container := range existing {
+			byName[container.Labels[rollout.SidecarLabel]] = container
+		}
+	} else {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to list service sidecars")
+	}
+
+	statuses := make([]SidecarStatus, 0, len(sidecars))
+	for _, sidecar := range sidecars {
+		status := SidecarStatus{ServiceContainer: sidecar, Status: "not_created"}
+		if container, ok := byName[sidecar.Name]; ok {
+			status.ContainerID = container.ID
+			status.Status = "stopped"
+			if container.State == "running" {
+				status.Status = "running"
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// GetServiceContainers returns the init containers and sidecars of a service
+// with the state of each sidecar
+func (h *Handlers) GetServiceContainers(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.serviceStore.GetService(ctx, serviceID); err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", serviceID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return
+	}
+
+	containers, err := h.loadServiceContainers(ctx, serviceID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to get service containers")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service containers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceContainersResponse{
+		InitContainers: containers.InitContainers,
+		Sidecars:       h.sidecarStatuses(ctx, serviceID, containers.Sidecars),
+	})
+}
+
+// SetServiceContainers replaces the init containers and sidecars of a service.
+// Sidecars of a running service are reconciled right away; init containers run
+// on the next start, restart or deploy.
+func (h *Handlers) SetServiceContainers(c *gin.Context) {
+	serviceID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return
+	}
+
+	var req store.ServiceContainers
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	service, err := h.serviceStore.GetService(ctx, serviceID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", serviceID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return
+	}
+	if service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scheduled tasks cannot have init containers or sidecars"})
+		return
+	}
+	if h.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "init containers and sidecars are not available"})
+		return
+	}
+
+	if err := h.store.SetServiceContainers(ctx, serviceID, req); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to set service containers")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set service containers"})
+		return
+	}
+
+	// Sidecars follow the primary container; a stopped service gets them on start
+	if containerID, err := h.serviceContainerID(ctx, service); err == nil {
+		h.ensureSidecars(ctx, service, containerID)
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceUpdate, strconv.FormatInt(serviceID, 10), map[string]interface{}{
+			"service_name":    service.Name,
+			"init_containers": len(req.InitContainers),
+			"sidecars":        len(req.Sidecars),
+		})
+	}
+
+	containers, err := h.loadServiceContainers(ctx, serviceID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to get service containers")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service containers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ServiceContainersResponse{
+		InitContainers: containers.InitContainers,
+		Sidecars:       h.sidecarStatuses(ctx, serviceID, containers.Sidecars),
+	})
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Volume Snapshot API Handlers
+
+// loadSnapshotService parses the service ID and loads the service, writing the error response on failure
+func (h *Handlers) loadSnapshotService(ctx context.Context, c *gin.Context) (store.Service, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return store.Service{}, false
+	}
+
+	if h.volumeSnapshotter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "volume snapshots are not available"})
+		return store.Service{}, false
+	}
+
+	service, err := h.serviceStore.GetService(ctx, id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return store.Service{}, false
+	}
+
+	return service, true
+}
+
+// GetBackupSchedule returns the volume backup schedule of a service
+func (h *Handlers) GetBackupSchedule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadSnapshotService(ctx, c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.store.GetBackupSchedule(ctx, service.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Services without a schedule report the defaults, disabled
+		c.JSON(http.StatusOK, store.BackupSchedule{
+			ServiceID: service.ID,
+			Retention: store.DefaultBackupRetention,
+			Quiesce:   store.QuiesceNone,
+		})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to get backup schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get backup schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// BackupScheduleRequest represents the request to set a service's volume backup schedule
+type BackupScheduleRequest struct {
+	Enabled         bool   `json:"enabled"`
+	IntervalMinutes int    `json:"interval_minutes" binding:"required"`
+	Retention       int    `json:"retention"`
+	Quiesce         string `json:"quiesce"`
+}
+
+// SetBackupSchedule creates or replaces the volume backup schedule of a service
+func (h *Handlers) SetBackupSchedule(c *gin.Context) {
+	var req BackupScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadSnapshotService(ctx, c)
+	if !ok {
+		return
+	}
+
+	schedule := &store.BackupSchedule{
+		ServiceID:       service.ID,
+		Enabled:         req.Enabled,
+		IntervalMinutes: req.IntervalMinutes,
+		Retention:       req.Retention,
+		Quiesce:         req.Quiesce,
+	}
+	if schedule.Retention == 0 {
+		schedule.Retention = store.DefaultBackupRetention
+	}
+	if schedule.Quiesce == "" {
+		schedule.Quiesce = store.QuiesceNone
+	}
+	if err := schedule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if schedule.Enabled && len(service.Volumes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service has no volumes to back up"})
+		return
+	}
+
+	if err := h.store.SetBackupSchedule(ctx, schedule); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to set backup schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set backup schedule"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceUpdate, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name":     service.Name,
+			"backup_schedule":  true,
+			"enabled":          schedule.Enabled,
+			"interval_minutes": schedule.IntervalMinutes,
+			"retention":        schedule.Retention,
+			"quiesce":          schedule.Quiesce,
+		})
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// ListVolumeSnapshots returns the volume snapshots of a service, newest first
+func (h *Handlers) ListVolumeSnapshots(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadSnapshotService(ctx, c)
+	if !ok {
+		return
+	}
+
+	snapshots, err := h.store.ListVolumeSnapshots(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to list volume snapshots")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list volume snapshots"})
+		return
+	}
+	if snapshots == nil {
+		snapshots = []store.VolumeSnapshot{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"snapshots": snapshots})
+}
+
+// SnapshotRequest represents the request to snapshot a service's volumes now
+type SnapshotRequest struct {
+	Quiesce string `json:"quiesce"`
+}
+
+// CreateVolumeSnapshot queues a snapshot of every volume of a service
+func (h *Handlers) CreateVolumeSnapshot(c *gin.Context) {
+	var req SnapshotRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadSnapshotService(ctx, c)
+	if !ok {
+		return
+	}
+	if len(service.Volumes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service has no volumes to snapshot"})
+		return
+	}
+
+	// Default to the schedule's quiesce mode so manual and scheduled snapshots match
+	quiesce := req.Quiesce
+	if quiesce == "" {
+		quiesce = store.QuiesceNone
+		if schedule, err := h.store.GetBackupSchedule(ctx, service.ID); err == nil {
+			quiesce = schedule.Quiesce
+		}
+	}
+	if !store.IsValidQuiesceMode(quiesce) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "quiesce must be one of: none, pause, stop"})
+		return
+	}
+
+	job, err := h.volumeSnapshotter.Snapshot(service.ID, quiesce)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue volume snapshot"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionVolumeSnapshot, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name": service.Name,
+			"quiesce":      quiesce,
+			"job_id":       job.ID,
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "volume snapshot queued",
+		"job_id":  job.ID,
+	})
+}
+
+// RestoreVolumeRequest represents the request to roll a volume back to a snapshot
+type RestoreVolumeRequest struct {
+	SnapshotID int64 `json:"snapshot_id" binding:"required"`
+}
+
+// RestoreVolume queues rolling one of a service's volumes back to a snapshot
+func (h *Handlers) RestoreVolume(c *gin.Context) {
+	var req RestoreVolumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadSnapshotService(ctx, c)
+	if !ok {
+		return
+	}
+
+	volumeName := c.Param("name")
+	found := false
+	for _, volume := range service.Volumes {
+		if volume.Name() == volumeName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("service has no volume %s", volumeName)})
+		return
+	}
+
+	snapshot, err := h.store.GetVolumeSnapshot(ctx, req.SnapshotID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && (snapshot.ServiceID != service.ID || snapshot.Volume != volumeName)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found for this volume"})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("snapshot_id", req.SnapshotID).Msg("failed to get volume snapshot")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get volume snapshot"})
+		return
+	}
+
+	job, err := h.volumeSnapshotter.Restore(snapshot)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue volume restore"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionVolumeRestore, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name": service.Name,
+			"volume":       volumeName,
+			"snapshot_id":  snapshot.ID,
+			"job_id":       job.ID,
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "volume restore queued",
+		"job_id":  job.ID,
+	})
+}
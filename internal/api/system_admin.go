@@ -1,6 +1,9 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"os/exec"
 	"path/filepath"
@@ -9,16 +12,26 @@ import (
 	"time"
 
 	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
+// lockdownConfigKey is the system_config key the lockdown state is saved under
+const lockdownConfigKey = "system_lockdown"
+
 // Global lockdown state
 type LockdownState struct {
-	IsLocked    bool      `json:"is_locked"`
-	Reason      string    `json:"reason"`
-	Timestamp   time.Time `json:"timestamp"`
-	InitiatedBy string    `json:"initiated_by"`
+	IsLocked    bool       `json:"is_locked"`
+	Reason      string     `json:"reason"`
+	Timestamp   time.Time  `json:"timestamp"`
+	InitiatedBy string     `json:"initiated_by"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // lifted automatically after this time
+}
+
+// activeAt reports whether the lockdown is in effect at t
+func (s LockdownState) activeAt(t time.Time) bool {
+	return s.IsLocked && (s.ExpiresAt == nil || t.Before(*s.ExpiresAt))
 }
 
 // Global lockdown manager
@@ -28,9 +41,12 @@ var (
 	lastRestartTime time.Time
 )
 
-// SystemLockdownRequest represents a system lockdown request
+// SystemLockdownRequest represents a system lockdown request. ExpiresAt or
+// ExpiresInMinutes lift the lockdown automatically; without them it lasts until lifted.
 type SystemLockdownRequest struct {
-	Reason string `json:"reason"`
+	Reason           string     `json:"reason"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	ExpiresInMinutes int        `json:"expires_in_minutes"`
 }
 
 // SystemLockdownResponse represents the response to a lockdown request
@@ -38,6 +54,66 @@ type SystemLockdownResponse struct {
 	Status    string `json:"status"`
 	Message   string `json:"message"`
 	Timestamp string `json:"timestamp"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Persisted bool   `json:"persisted"` // false if the lockdown will not survive a restart
+}
+
+// currentLockdown returns the lockdown state, reporting an expired lockdown as lifted
+func currentLockdown() LockdownState {
+	lockdownMutex.RLock()
+	state := *lockdownState
+	lockdownMutex.RUnlock()
+
+	if state.IsLocked && !state.activeAt(time.Now()) {
+		return LockdownState{IsLocked: false}
+	}
+	return state
+}
+
+// saveLockdown persists the lockdown state so it survives a restart
+func (h *Handlers) saveLockdown(ctx context.Context, state LockdownState) error {
+	if h.store == nil {
+		return errors.New("no store configured")
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return h.store.SetSystemConfig(ctx, lockdownConfigKey, string(data))
+}
+
+// RestoreLockdown reapplies the lockdown saved by a previous process. A lockdown
+// that expired in the meantime stays lifted.
+func (h *Handlers) RestoreLockdown(ctx context.Context) error {
+	if h.store == nil {
+		return nil
+	}
+	data, err := h.store.GetSystemConfig(ctx, lockdownConfigKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state LockdownState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return err
+	}
+	if !state.activeAt(time.Now()) {
+		return nil
+	}
+
+	lockdownMutex.Lock()
+	lockdownState = &state
+	lockdownMutex.Unlock()
+
+	log.Warn().
+		Str("reason", state.Reason).
+		Str("initiated_by", state.InitiatedBy).
+		Time("since", state.Timestamp).
+		Msg("system lockdown restored")
+	return nil
 }
 
 // LogsRequest represents a request for system logs
@@ -60,16 +136,44 @@ func (h *Handlers) SystemLockdown(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
+	expiresAt := req.ExpiresAt
+	if req.ExpiresInMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_minutes must be positive"})
+		return
+	}
+	if req.ExpiresInMinutes > 0 {
+		if expiresAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "set either expires_at or expires_in_minutes"})
+			return
+		}
+		at := now.Add(time.Duration(req.ExpiresInMinutes) * time.Minute)
+		expiresAt = &at
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
 	// Set global lockdown state
-	lockdownMutex.Lock()
-	lockdownState = &LockdownState{
+	state := LockdownState{
 		IsLocked:    true,
 		Reason:      req.Reason,
-		Timestamp:   time.Now(),
+		Timestamp:   now,
 		InitiatedBy: c.GetString("token_name"),
+		ExpiresAt:   expiresAt,
 	}
+	lockdownMutex.Lock()
+	lockdownState = &state
 	lockdownMutex.Unlock()
 
+	// The lockdown applies even if it cannot be saved; the response says so
+	persisted := true
+	if err := h.saveLockdown(c.Request.Context(), state); err != nil {
+		persisted = false
+		log.Error().Err(err).Msg("failed to persist system lockdown, it will be lifted by a restart")
+	}
+
 	// Log the lockdown request
 	log.Warn().
 		Str("admin_user", c.GetString("token_name")).
@@ -84,15 +188,20 @@ func (h *Handlers) SystemLockdown(c *gin.Context) {
 		}
 		h.auditLogger.RecordSystemAction(c.Request.Context(), actor, audit.ActionSystemLockdown, map[string]interface{}{
 			"reason":       req.Reason,
-			"timestamp":    lockdownState.Timestamp,
-			"initiated_by": lockdownState.InitiatedBy,
+			"timestamp":    state.Timestamp,
+			"initiated_by": state.InitiatedBy,
+			"expires_at":   state.ExpiresAt,
 		})
 	}
 
 	response := SystemLockdownResponse{
 		Status:    "lockdown_active",
 		Message:   "System has been locked down. Only admin access permitted.",
-		Timestamp: lockdownState.Timestamp.Format(time.RFC3339),
+		Timestamp: state.Timestamp.Format(time.RFC3339),
+		Persisted: persisted,
+	}
+	if state.ExpiresAt != nil {
+		response.ExpiresAt = state.ExpiresAt.Format(time.RFC3339)
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -191,6 +300,9 @@ func (h *Handlers) EmergencyRestart(c *gin.Context) {
 	lockdownMutex.Lock()
 	lockdownState = &LockdownState{IsLocked: false}
 	lockdownMutex.Unlock()
+	if err := h.saveLockdown(c.Request.Context(), LockdownState{IsLocked: false}); err != nil {
+		log.Error().Err(err).Msg("failed to persist lifted system lockdown")
+	}
 
 	// Return immediate response before restart
 	c.JSON(http.StatusOK, gin.H{
@@ -214,24 +326,36 @@ func (h *Handlers) EmergencyRestart(c *gin.Context) {
 
 // GetLockdownStatus returns current lockdown status
 func (h *Handlers) GetLockdownStatus(c *gin.Context) {
-	lockdownMutex.RLock()
-	state := *lockdownState
-	lockdownMutex.RUnlock()
-
-	c.JSON(http.StatusOK, state)
+	c.JSON(http.StatusOK, currentLockdown())
 }
 
 // LiftLockdown removes system lockdown (admin only)
 func (h *Handlers) LiftLockdown(c *gin.Context) {
+	wasLocked := currentLockdown().IsLocked
 	lockdownMutex.Lock()
-	wasLocked := lockdownState.IsLocked
 	lockdownState = &LockdownState{IsLocked: false}
 	lockdownMutex.Unlock()
 
+	if err := h.saveLockdown(c.Request.Context(), LockdownState{IsLocked: false}); err != nil {
+		log.Error().Err(err).Msg("failed to persist lifted system lockdown")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "lockdown lifted but could not be saved, it returns after a restart"})
+		return
+	}
+
 	if wasLocked {
 		log.Info().
 			Str("admin_user", c.GetString("token_name")).
 			Msg("system lockdown lifted")
+
+		if h.auditLogger != nil {
+			actor := c.GetString("token_name")
+			if actor == "" {
+				actor = "system"
+			}
+			h.auditLogger.RecordSystemAction(c.Request.Context(), actor, audit.ActionSystemLockdownLift, map[string]interface{}{
+				"lifted_by": actor,
+			})
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -243,12 +367,8 @@ func (h *Handlers) LiftLockdown(c *gin.Context) {
 
 // GetSystemStatus returns system status including restart history
 func (h *Handlers) GetSystemStatus(c *gin.Context) {
-	lockdownMutex.RLock()
-	state := *lockdownState
-	lockdownMutex.RUnlock()
-
 	status := gin.H{
-		"lockdown": state,
+		"lockdown": currentLockdown(),
 	}
 
 	if !lastRestartTime.IsZero() {
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Scheduled Task API Handlers
+
+// defaultTaskRunsLimit is how many runs the run history returns by default
+const defaultTaskRunsLimit = 20
+
+// loadTaskService parses the service ID and loads the scheduled task, writing the error response on failure
+func (h *Handlers) loadTaskService(ctx context.Context, c *gin.Context) (store.Service, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service ID"})
+		return store.Service{}, false
+	}
+
+	if h.taskRunner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduled tasks are not available"})
+		return store.Service{}, false
+	}
+
+	service, err := h.serviceStore.GetService(ctx, id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("service not found: %d", id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get service"})
+		}
+		return store.Service{}, false
+	}
+	if !service.IsTask() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service is not a scheduled task"})
+		return store.Service{}, false
+	}
+
+	return service, true
+}
+
+// loadTaskConfig returns the schedule of a task with its next run filled in
+func (h *Handlers) loadTaskConfig(ctx context.Context, serviceID int64) (*store.TaskConfig, error) {
+	config, err := h.store.GetTaskConfig(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	if next := config.NextRun(); !next.IsZero() && !config.Suspended {
+		config.NextRunAt = &next
+	}
+	return config, nil
+}
+
+// GetServiceTask returns the schedule of a scheduled task
+func (h *Handlers) GetServiceTask(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadTaskService(ctx, c)
+	if !ok {
+		return
+	}
+
+	config, err := h.loadTaskConfig(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to get task schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get task schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// TaskScheduleRequest represents the request to change a scheduled task's schedule
+type TaskScheduleRequest struct {
+	Schedule       string   `json:"schedule" binding:"required"`
+	Command        []string `json:"command"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+	Suspended      bool     `json:"suspended"`
+}
+
+// SetServiceTask replaces the schedule, command and timeout of a scheduled task
+func (h *Handlers) SetServiceTask(c *gin.Context) {
+	var req TaskScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadTaskService(ctx, c)
+	if !ok {
+		return
+	}
+
+	config := &store.TaskConfig{
+		ServiceID:      service.ID,
+		Schedule:       req.Schedule,
+		Command:        req.Command,
+		TimeoutSeconds: req.TimeoutSeconds,
+		Suspended:      req.Suspended,
+	}
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = store.DefaultTaskTimeout
+	}
+	if err := config.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.SetTaskConfig(ctx, config); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to set task schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set task schedule"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionServiceUpdate, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name":    service.Name,
+			"task_schedule":   config.Schedule,
+			"timeout_seconds": config.TimeoutSeconds,
+			"suspended":       config.Suspended,
+		})
+	}
+
+	config, err := h.loadTaskConfig(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to get task schedule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get task schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// RunServiceTask queues a run of a scheduled task now, outside its schedule
+func (h *Handlers) RunServiceTask(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadTaskService(ctx, c)
+	if !ok {
+		return
+	}
+
+	active, err := h.store.HasActiveTaskRun(ctx, service.ID)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to check active task runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue task run"})
+		return
+	}
+	if active {
+		c.JSON(http.StatusConflict, gin.H{"error": "a run of this task is already queued or running"})
+		return
+	}
+
+	run, job, err := h.taskRunner.Trigger(ctx, service.ID, store.TaskTriggerManual)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to queue task run")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue task run"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordServiceAction(c.Request.Context(), actor, audit.ActionTaskRun, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"service_name": service.Name,
+			"run_id":       run.ID,
+			"job_id":       job.ID,
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "task run queued",
+		"run":     run,
+		"job_id":  job.ID,
+	})
+}
+
+// ListTaskRuns returns the run history of a scheduled task, newest first
+func (h *Handlers) ListTaskRuns(c *gin.Context) {
+	limit := defaultTaskRunsLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > store.TaskRunRetention {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", store.TaskRunRetention)})
+			return
+		}
+		limit = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadTaskService(ctx, c)
+	if !ok {
+		return
+	}
+
+	runs, err := h.store.ListTaskRuns(ctx, service.ID, limit)
+	if err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to list task runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list task runs"})
+		return
+	}
+	if runs == nil {
+		runs = []store.TaskRun{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// GetTaskRun returns a single run of a scheduled task with its logs
+func (h *Handlers) GetTaskRun(c *gin.Context) {
+	runID, err := strconv.ParseInt(c.Param("run_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	service, ok := h.loadTaskService(ctx, c)
+	if !ok {
+		return
+	}
+
+	run, err := h.store.GetTaskRun(ctx, runID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && run.ServiceID != service.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task run not found"})
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("run_id", runID).Msg("failed to get task run")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get task run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
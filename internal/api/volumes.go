@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// Volume API Handlers
+
+// managedVolumeLabels returns the labels that mark a volume as managed by glinrdock,
+// optionally narrowed to a single project
+func managedVolumeLabels(projectID string) map[string]string {
+	labels := map[string]string{"glinr.managed": "true"}
+	if projectID != "" {
+		labels["glinr.project_id"] = projectID
+	}
+	return labels
+}
+
+// findManagedVolume looks up a managed volume by its exact name
+func (h *Handlers) findManagedVolume(ctx context.Context, name string) (*dockerx.Volume, error) {
+	volumes, err := h.dockerEngine.ListVolumes(ctx, managedVolumeLabels(""))
+	if err != nil {
+		return nil, err
+	}
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return &volume, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListVolumes returns the managed named volumes, optionally filtered by project_id
+func (h *Handlers) ListVolumes(c *gin.Context) {
+	projectID := c.Query("project_id")
+	if projectID != "" {
+		if _, err := strconv.ParseInt(projectID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project ID"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	volumes, err := h.dockerEngine.ListVolumes(ctx, managedVolumeLabels(projectID))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list volumes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list volumes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"volumes": volumes})
+}
+
+// VolumeCreateRequest represents the request to create a managed volume
+type VolumeCreateRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ProjectID *int64 `json:"project_id,omitempty"`
+}
+
+// CreateVolume creates a managed named volume
+func (h *Handlers) CreateVolume(c *gin.Context) {
+	var req VolumeCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !store.IsValidVolumeName(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid volume name: must start with a letter or digit and contain only letters, digits, '_', '.' and '-'"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	labels := managedVolumeLabels("")
+	if req.ProjectID != nil {
+		project, err := h.serviceStore.GetProject(ctx, *req.ProjectID)
+		if err != nil {
+			if err.Error() == fmt.Sprintf("project not found: %d", *req.ProjectID) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify project"})
+			}
+			return
+		}
+		labels["glinr.project_id"] = strconv.FormatInt(project.ID, 10)
+	}
+
+	existing, err := h.findManagedVolume(ctx, req.Name)
+	if err != nil {
+		log.Error().Err(err).Str("volume", req.Name).Msg("failed to list volumes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create volume"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "volume already exists"})
+		return
+	}
+
+	volume, err := h.dockerEngine.CreateVolume(ctx, req.Name, labels)
+	if err != nil {
+		log.Error().Err(err).Str("volume", req.Name).Msg("failed to create volume")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create volume"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordVolumeAction(c.Request.Context(), actor, audit.ActionVolumeCreate, volume.Name, map[string]interface{}{
+			"project_id": labels["glinr.project_id"],
+		})
+	}
+
+	c.JSON(http.StatusCreated, volume)
+}
+
+// DeleteVolume removes a managed named volume that no container uses
+func (h *Handlers) DeleteVolume(c *gin.Context) {
+	name := c.Param("name")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	volume, err := h.findManagedVolume(ctx, name)
+	if err != nil {
+		log.Error().Err(err).Str("volume", name).Msg("failed to list volumes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete volume"})
+		return
+	}
+	if volume == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "volume not found"})
+		return
+	}
+
+	usage, err := h.dockerEngine.VolumeUsage(ctx, name)
+	if err != nil {
+		log.Error().Err(err).Str("volume", name).Msg("failed to get volume usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete volume"})
+		return
+	}
+	if usage.RefCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "volume is in use by a container"})
+		return
+	}
+
+	if err := h.dockerEngine.RemoveVolume(ctx, name); err != nil {
+		log.Error().Err(err).Str("volume", name).Msg("failed to delete volume")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete volume"})
+		return
+	}
+
+	if h.auditLogger != nil {
+		actor := audit.GetActorFromContext(c.Request.Context())
+		h.auditLogger.RecordVolumeAction(c.Request.Context(), actor, audit.ActionVolumeDelete, name, map[string]interface{}{
+			"project_id": volume.Labels["glinr.project_id"],
+			"size":       usage.Size,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "volume deleted successfully"})
+}
+
+// GetVolumeUsage returns the disk space a managed volume uses and how many containers use it
+func (h *Handlers) GetVolumeUsage(c *gin.Context) {
+	name := c.Param("name")
+
+	// Computing volume sizes walks every volume on disk, so allow it some time
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	volume, err := h.findManagedVolume(ctx, name)
+	if err != nil {
+		log.Error().Err(err).Str("volume", name).Msg("failed to list volumes")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get volume usage"})
+		return
+	}
+	if volume == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "volume not found"})
+		return
+	}
+
+	usage, err := h.dockerEngine.VolumeUsage(ctx, name)
+	if err != nil {
+		log.Error().Err(err).Str("volume", name).Msg("failed to get volume usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get volume usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
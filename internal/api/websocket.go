@@ -9,6 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
+	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
@@ -44,9 +47,15 @@ func (h *Handlers) ServiceLogsHandler(c *gin.Context) {
 		return
 	}
 
-	// Check if service has a container ID, if not try to discover by label
+	// A sidecar is picked by name with ?container=, the service's own container otherwise
 	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
+	if sidecar := c.Query("container"); sidecar != "" {
+		var ok bool
+		if containerID, ok = h.sidecarContainerID(c.Request.Context(), serviceID, sidecar); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("sidecar %s not found", sidecar)})
+			return
+		}
+	} else if service.ContainerID != nil && *service.ContainerID != "" {
 		containerID = *service.ContainerID
 	} else {
 		// Auto-discover container by service_id label for existing services
@@ -144,9 +153,18 @@ func (h *Handlers) ServiceStatsHandler(c *gin.Context) {
 		return
 	}
 
-	// Check if service has a container ID, if not try to discover by label
+	// A sidecar is picked by name with ?container=, the service's own container otherwise
 	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
+	resources := service.Resources
+	if sidecar := c.Query("container"); sidecar != "" {
+		var ok bool
+		if containerID, ok = h.sidecarContainerID(c.Request.Context(), serviceID, sidecar); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("sidecar %s not found", sidecar)})
+			return
+		}
+		// Sidecars run without the service's limits
+		resources = store.ResourceLimits{}
+	} else if service.ContainerID != nil && *service.ContainerID != "" {
 		containerID = *service.ContainerID
 	} else {
 		// Auto-discover container by service_id label for existing services
@@ -198,7 +216,7 @@ func (h *Handlers) ServiceStatsHandler(c *gin.Context) {
 					return
 				}
 
-				if err := conn.WriteJSON(stats); err != nil {
+				if err := conn.WriteJSON(withServiceLimits(stats, resources)); err != nil {
 					log.Error().Err(err).Msg("error writing stats to websocket")
 					return
 				}
@@ -256,9 +274,15 @@ func (h *Handlers) ServiceLogsTailHandler(c *gin.Context) {
 		return
 	}
 
-	// Check if service has a container ID, if not try to discover by label
+	// A sidecar is picked by name with ?container=, the service's own container otherwise
 	var containerID string
-	if service.ContainerID != nil && *service.ContainerID != "" {
+	if sidecar := c.Query("container"); sidecar != "" {
+		var ok bool
+		if containerID, ok = h.sidecarContainerID(c.Request.Context(), serviceID, sidecar); !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("sidecar %s not found", sidecar)})
+			return
+		}
+	} else if service.ContainerID != nil && *service.ContainerID != "" {
 		containerID = *service.ContainerID
 	} else {
 		// Auto-discover container by service_id label for existing services
@@ -352,6 +376,15 @@ func (h *Handlers) discoverContainerByServiceID(ctx context.Context, serviceID i
 		return "", fmt.Errorf("failed to list containers: %w", err)
 	}
 
+	// Additional replicas share the service_id label, only the primary container is wanted here
+	primaries := containers[:0]
+	for _, c := range containers {
+		if _, isReplica := c.Labels[rollout.ReplicaLabel]; !isReplica {
+			primaries = append(primaries, c)
+		}
+	}
+	containers = primaries
+
 	if len(containers) == 0 {
 		return "", fmt.Errorf("no container found with service_id=%d", serviceID)
 	}
@@ -365,3 +398,18 @@ func (h *Handlers) discoverContainerByServiceID(ctx context.Context, serviceID i
 
 	return containerID, nil
 }
+
+// withServiceLimits adds a service's configured limits to a stats sample so
+// clients can show usage against them. Docker already reports the effective
+// memory and pids limits; the CPU quota is only known from the service.
+func withServiceLimits(stats dockerx.ContainerStats, limits store.ResourceLimits) dockerx.ContainerStats {
+	if limits.CPUs > 0 {
+		stats.CPULimit = limits.CPUs
+		// CPUPercent counts 100% per core, so the quota allows CPUs*100%
+		stats.CPULimitPercent = stats.CPUPercent / limits.CPUs
+	}
+	if limits.MemoryReservation > 0 {
+		stats.MemoryReservation = uint64(limits.MemoryReservation)
+	}
+	return stats
+}
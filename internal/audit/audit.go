@@ -20,11 +20,18 @@ const (
 	ActionServiceRestart       Action = "service_restart"
 	ActionServiceDeploy        Action = "service_deploy"
 	ActionServiceScale         Action = "service_scale"
+	ActionServiceRollback      Action = "service_rollback"
 	ActionServiceView          Action = "service_view"
+	ActionServiceCreate        Action = "service_create"
 	ActionServiceUpdate        Action = "service_update"
+	ActionServiceDelete        Action = "service_delete"
 	ActionServiceLinksUpdate   Action = "service_links_update"
 	ActionSystemLockdown       Action = "system_lockdown"
 	ActionSystemRestart        Action = "system_restart"
+	ActionSystemLockdownLift   Action = "system_lockdown_lift"
+	ActionMaintenanceCreate    Action = "maintenance_create"
+	ActionMaintenanceUpdate    Action = "maintenance_update"
+	ActionMaintenanceEnd       Action = "maintenance_end"
 	ActionLicenseActivate      Action = "license_activate"
 	ActionLicenseDeactivate    Action = "license_deactivate"
 	ActionBackupCreate         Action = "backup_create"
@@ -32,11 +39,26 @@ const (
 	ActionProjectCreate        Action = "project_create"
 	ActionProjectUpdate        Action = "project_update"
 	ActionProjectDelete        Action = "project_delete"
+	ActionProjectImport        Action = "project_import"
+	ActionProjectApply         Action = "project_apply"
+	ActionProjectExport        Action = "project_export"
+	ActionProjectStart         Action = "project_start"
+	ActionProjectStop          Action = "project_stop"
+	ActionProjectRestart       Action = "project_restart"
 	ActionRouteCreate          Action = "route_create"
+	ActionRouteUpdate          Action = "route_update"
 	ActionRouteDelete          Action = "route_delete"
 	ActionClientRegister       Action = "client_register"
 	ActionRegistryCreate       Action = "registry_create"
 	ActionRegistryDelete       Action = "registry_delete"
+	ActionVolumeCreate         Action = "volume_create"
+	ActionVolumeDelete         Action = "volume_delete"
+	ActionVolumeSnapshot       Action = "volume_snapshot"
+	ActionVolumeRestore        Action = "volume_restore"
+	ActionTaskRun              Action = "task_run"
+	ActionServiceRun           Action = "service_run"
+	ActionServiceExecStart     Action = "service_exec_start"
+	ActionServiceExecEnd       Action = "service_exec_end"
 	ActionWebhookDelivery      Action = "webhook_delivery"
 	ActionDeployTriggered      Action = "deploy_triggered"
 	ActionProjectNetworkEnsure Action = "project_network_ensure"
@@ -202,6 +224,11 @@ func (l *Logger) RecordRegistryAction(ctx context.Context, actor string, action
 	l.Record(ctx, actor, action, "registry", registryID, meta)
 }
 
+// RecordVolumeAction records volume-related actions
+func (l *Logger) RecordVolumeAction(ctx context.Context, actor string, action Action, volumeName string, meta map[string]interface{}) {
+	l.Record(ctx, actor, action, "volume", volumeName, meta)
+}
+
 // RecordCertificateAction records certificate-related actions
 func (l *Logger) RecordCertificateAction(ctx context.Context, actor string, action Action, certificateID string, meta map[string]interface{}) {
 	l.Record(ctx, actor, action, "certificate", certificateID, meta)
@@ -197,6 +197,18 @@ func (a *AuthService) RequireAdminRole() gin.HandlerFunc {
 	return a.RequireRole(store.RoleAdmin)
 }
 
+// HasRole reports whether the authenticated session or token of a request has
+// at least minRole
+func HasRole(c *gin.Context, minRole string) bool {
+	if userRole, exists := c.Get("user_role"); exists {
+		return hasPermission(userRole.(string), minRole)
+	}
+	if tokenRole, exists := c.Get("token_role"); exists {
+		return hasPermission(tokenRole.(string), minRole)
+	}
+	return false
+}
+
 // hasPermission checks if userRole has permission for the required minRole
 func hasPermission(userRole, minRole string) bool {
 	// Admin has access to everything
@@ -0,0 +1,263 @@
+// Package bundle packs a project into a portable archive that can be imported
+// into another glinrdock instance. Secret environment variables are sealed with a
+// key derived from a user supplied passphrase rather than the instance's
+// GLINRDOCK_SECRET, so the archive can be opened wherever the passphrase is known.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/crypto"
+	"github.com/GLINCKER/glinrdock/internal/manifest"
+)
+
+// Version is the bundle format version this build reads and writes
+const Version = 1
+
+// MinPassphraseLength is the shortest passphrase accepted for sealing secrets
+const MinPassphraseLength = 8
+
+const (
+	manifestFile = "manifest.yaml"
+	metadataFile = "bundle.json"
+
+	// maxEntrySize bounds each file read from an archive
+	maxEntrySize = 4 << 20
+
+	kdfPBKDF2 = "pbkdf2-sha256"
+)
+
+var (
+	ErrPassphraseRequired = errors.New("a passphrase is required for bundles with secret environment variables")
+	ErrPassphraseTooShort = fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	ErrWrongPassphrase    = errors.New("wrong passphrase: secrets in the bundle cannot be decrypted")
+)
+
+// Bundle is an exported project. The manifest carries the project, its services,
+// routes, links and health checks; everything it cannot express is kept alongside.
+type Bundle struct {
+	Manifest      *manifest.Manifest `json:"manifest,omitempty"` // stored as manifest.yaml
+	ExportedAt    time.Time          `json:"exported_at"`
+	ProjectID     int64              `json:"project_id"`           // ID of the project on the exporting instance
+	ServiceIDs    map[string]int64   `json:"service_ids"`          // by service name, on the exporting instance
+	RouteIDs      map[string]int64   `json:"route_ids"`            // by domain and path, on the exporting instance
+	EnvVars       []EnvVar           `json:"env_vars"`             // managed environment variables, in plaintext once read
+	Registries    []RegistryRef      `json:"registries"`           // registries services pull from
+	ExternalLinks []ExternalLink     `json:"external_links"`       // links to services of other projects
+	Encryption    *Encryption        `json:"encryption,omitempty"` // set when the bundle holds secrets
+}
+
+// EnvVar is a managed environment variable of a service. Value is always plaintext
+// in memory; secrets are only encrypted inside the archive.
+type EnvVar struct {
+	Service    string `json:"service"`
+	Key        string `json:"key"`
+	Value      string `json:"value,omitempty"`
+	Secret     bool   `json:"secret"`
+	Nonce      []byte `json:"nonce,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+// RegistryRef names the registry a service pulls its image from. Credentials are
+// never exported; the importing instance must have a registry with the same name.
+type RegistryRef struct {
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Server  string `json:"server"`
+}
+
+// ExternalLink is a link from a service to a service of another project, by name
+type ExternalLink struct {
+	Service string `json:"service"`
+	Project string `json:"project"`
+	Target  string `json:"target"`
+}
+
+// Encryption records how the key sealing the secrets is derived from the passphrase
+type Encryption struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+}
+
+// HasSecrets reports whether the bundle holds secret environment variables
+func (b *Bundle) HasSecrets() bool {
+	for _, envVar := range b.EnvVars {
+		if envVar.Secret {
+			return true
+		}
+	}
+	return false
+}
+
+// Write encodes the bundle as a gzipped tar archive, sealing secrets under the passphrase
+func Write(w io.Writer, b *Bundle, passphrase string) error {
+	if b.Manifest == nil {
+		return fmt.Errorf("bundle has no manifest")
+	}
+
+	sealed := *b
+	sealed.Encryption = nil
+	sealed.EnvVars = make([]EnvVar, 0, len(b.EnvVars))
+	if b.HasSecrets() {
+		if passphrase == "" {
+			return ErrPassphraseRequired
+		}
+		if len(passphrase) < MinPassphraseLength {
+			return ErrPassphraseTooShort
+		}
+		salt, err := crypto.NewSalt()
+		if err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+		sealed.Encryption = &Encryption{KDF: kdfPBKDF2, Iterations: crypto.PassphraseIterations, Salt: salt}
+	}
+
+	var key []byte
+	if sealed.Encryption != nil {
+		var err error
+		key, err = crypto.DeriveKeyFromPassphrase(passphrase, sealed.Encryption.Salt, sealed.Encryption.Iterations)
+		if err != nil {
+			return err
+		}
+	}
+	for _, envVar := range b.EnvVars {
+		if envVar.Secret {
+			nonce, ciphertext, err := crypto.Encrypt(key, []byte(envVar.Value))
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s of service %s: %w", envVar.Key, envVar.Service, err)
+			}
+			envVar.Value, envVar.Nonce, envVar.Ciphertext = "", nonce, ciphertext
+		}
+		sealed.EnvVars = append(sealed.EnvVars, envVar)
+	}
+
+	manifestData, err := manifest.Marshal(b.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	sealed.Manifest = nil
+	meta, err := json.MarshalIndent(metadata{Version: Version, Bundle: sealed}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{metadataFile, meta},
+		{manifestFile, manifestData},
+	} {
+		header := &tar.Header{Name: file.name, Mode: 0600, Size: int64(len(file.data)), ModTime: b.ExportedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read decodes an archive written by Write and decrypts its secrets with the passphrase
+func Read(r io.Reader, passphrase string) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if header.Name != manifestFile && header.Name != metadataFile {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+		if len(data) > maxEntrySize {
+			return nil, fmt.Errorf("invalid bundle: %s too large", header.Name)
+		}
+		files[header.Name] = data
+	}
+
+	if files[metadataFile] == nil || files[manifestFile] == nil {
+		return nil, fmt.Errorf("invalid bundle: %s and %s are required", metadataFile, manifestFile)
+	}
+
+	var meta metadata
+	if err := json.Unmarshal(files[metadataFile], &meta); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if meta.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d: expected %d", meta.Version, Version)
+	}
+
+	b := meta.Bundle
+	b.Manifest, err = manifest.Parse(files[manifestFile])
+	if err != nil {
+		return nil, err
+	}
+	if err := b.open(passphrase); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// open decrypts the secrets of a bundle read from an archive
+func (b *Bundle) open(passphrase string) error {
+	if !b.HasSecrets() {
+		return nil
+	}
+	// The work factor comes from the archive; bound it so a crafted bundle cannot stall the server
+	if b.Encryption == nil || b.Encryption.KDF != kdfPBKDF2 || b.Encryption.Iterations < 1 || b.Encryption.Iterations > 10*crypto.PassphraseIterations {
+		return fmt.Errorf("invalid bundle: unsupported secret encryption")
+	}
+	if passphrase == "" {
+		return ErrPassphraseRequired
+	}
+
+	key, err := crypto.DeriveKeyFromPassphrase(passphrase, b.Encryption.Salt, b.Encryption.Iterations)
+	if err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	for i, envVar := range b.EnvVars {
+		if !envVar.Secret {
+			continue
+		}
+		plaintext, err := crypto.Decrypt(key, envVar.Nonce, envVar.Ciphertext)
+		if err != nil {
+			return ErrWrongPassphrase
+		}
+		b.EnvVars[i].Value, b.EnvVars[i].Nonce, b.EnvVars[i].Ciphertext = string(plaintext), nil, nil
+	}
+	return nil
+}
+
+// metadata is the content of bundle.json: everything but the manifest, plus the format version
+type metadata struct {
+	Version int `json:"version"`
+	Bundle
+}
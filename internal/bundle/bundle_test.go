@@ -0,0 +1,125 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/manifest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shopBundle() *Bundle {
+	return &Bundle{
+		Manifest: &manifest.Manifest{
+			Version: manifest.Version,
+			Project: &manifest.Project{Name: "shop", Branch: "main"},
+			Services: []manifest.Service{
+				{Name: "web", Image: "ghcr.io/acme/shop:1.4", Routes: []manifest.Route{{Domain: "shop.example.com", Port: 3000}}},
+			},
+		},
+		ExportedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		ProjectID:  7,
+		ServiceIDs: map[string]int64{"web": 21},
+		RouteIDs:   map[string]int64{"shop.example.com": 30},
+		EnvVars: []EnvVar{
+			{Service: "web", Key: "LOG_LEVEL", Value: "info"},
+			{Service: "web", Key: "STRIPE_KEY", Value: "sk_live_123", Secret: true},
+		},
+		Registries:    []RegistryRef{{Service: "web", Name: "acme-ghcr", Type: "ghcr", Server: "ghcr.io"}},
+		ExternalLinks: []ExternalLink{{Service: "web", Project: "auth", Target: "api"}},
+	}
+}
+
+// entries lists the files of an archive with their contents
+func entries(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+}
+
+func TestWriteRead_RoundTrips(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, shopBundle(), "correct horse"))
+
+	files := entries(t, buf.Bytes())
+	assert.Contains(t, files, manifestFile)
+	assert.Contains(t, files[manifestFile], "image: ghcr.io/acme/shop:1.4")
+	assert.Contains(t, files[metadataFile], `"value": "info"`)
+	assert.NotContains(t, files[metadataFile], "sk_live_123", "secrets must be encrypted in the archive")
+
+	b, err := Read(bytes.NewReader(buf.Bytes()), "correct horse")
+	require.NoError(t, err)
+
+	expected := shopBundle()
+	assert.Equal(t, expected.Manifest, b.Manifest)
+	assert.Equal(t, expected.EnvVars, b.EnvVars)
+	assert.Equal(t, expected.ServiceIDs, b.ServiceIDs)
+	assert.Equal(t, expected.RouteIDs, b.RouteIDs)
+	assert.Equal(t, expected.Registries, b.Registries)
+	assert.Equal(t, expected.ExternalLinks, b.ExternalLinks)
+	assert.Equal(t, int64(7), b.ProjectID)
+	require.NotNil(t, b.Encryption)
+	assert.Equal(t, "pbkdf2-sha256", b.Encryption.KDF)
+}
+
+func TestRead_WrongPassphrase(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, shopBundle(), "correct horse"))
+
+	_, err := Read(bytes.NewReader(buf.Bytes()), "battery staple")
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+
+	_, err = Read(bytes.NewReader(buf.Bytes()), "")
+	assert.ErrorIs(t, err, ErrPassphraseRequired)
+}
+
+func TestWrite_Passphrase(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Write(&buf, shopBundle(), ""), ErrPassphraseRequired)
+	assert.ErrorIs(t, Write(&buf, shopBundle(), "short"), ErrPassphraseTooShort)
+
+	// Without secrets no passphrase is needed
+	b := shopBundle()
+	b.EnvVars = b.EnvVars[:1]
+	buf.Reset()
+	require.NoError(t, Write(&buf, b, ""))
+
+	read, err := Read(bytes.NewReader(buf.Bytes()), "")
+	require.NoError(t, err)
+	assert.Nil(t, read.Encryption)
+	assert.Equal(t, b.EnvVars, read.EnvVars)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("not an archive")), "")
+	assert.ErrorContains(t, err, "invalid bundle")
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: metadataFile, Mode: 0600, Size: 2}))
+	_, err = tw.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	_, err = Read(bytes.NewReader(buf.Bytes()), "")
+	assert.ErrorContains(t, err, "manifest.yaml are required")
+}
@@ -0,0 +1,605 @@
+// Package compose converts docker-compose files into glinrdock services, links and routes
+package compose
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Compose has no notion of public routes, so they are declared with service labels
+const (
+	LabelRouteDomain = "glinr.route.domain" // public domain routed to the service
+	LabelRoutePort   = "glinr.route.port"   // container port, defaults to the first published port
+	LabelRoutePath   = "glinr.route.path"   // optional path prefix
+	LabelRouteTLS    = "glinr.route.tls"    // "true" to serve the route over HTTPS
+)
+
+// Plan describes everything an import of a compose file creates
+type Plan struct {
+	Project     string        `json:"project"`
+	Services    []ServicePlan `json:"services"`    // in creation order, dependencies first
+	Unsupported []string      `json:"unsupported"` // compose keys that were ignored, e.g. services.web.build
+	Warnings    []string      `json:"warnings"`
+}
+
+// ServicePlan describes a single service of a Plan
+type ServicePlan struct {
+	Source    string            `json:"source"` // service name in the compose file
+	Spec      store.ServiceSpec `json:"spec"`
+	DependsOn []string          `json:"depends_on"` // names of the services this one is linked to
+	Routes    []store.RouteSpec `json:"routes"`
+}
+
+// healthURLRegex finds the URL probed by curl or wget style health checks
+var healthURLRegex = regexp.MustCompile(`https?://[^\s'"]+`)
+
+// composeFile is the subset of the compose specification that is imported. The
+// inline Keys of each struct collect the keys without a glinrdock equivalent.
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Version  string                    `yaml:"version"` // obsolete, ignored by docker compose as well
+	Services map[string]yaml.Node      `yaml:"services"`
+	Volumes  map[string]*composeVolume `yaml:"volumes"`
+	Keys     map[string]yaml.Node      `yaml:",inline"`
+}
+
+type composeVolume struct {
+	Name     string `yaml:"name"`
+	External bool   `yaml:"external"`
+}
+
+type composeService struct {
+	Image       string               `yaml:"image"`
+	Environment mappingOrList        `yaml:"environment"`
+	Ports       []composePort        `yaml:"ports"`
+	Volumes     []composeMount       `yaml:"volumes"`
+	HealthCheck *composeHealth       `yaml:"healthcheck"`
+	DependsOn   composeDependsOn     `yaml:"depends_on"`
+	Restart     string               `yaml:"restart"`
+	Labels      mappingOrList        `yaml:"labels"`
+	Keys        map[string]yaml.Node `yaml:",inline"`
+}
+
+type composeHealth struct {
+	Test    stringOrList         `yaml:"test"`
+	Disable bool                 `yaml:"disable"`
+	Keys    map[string]yaml.Node `yaml:",inline"`
+}
+
+// mappingOrList accepts both the KEY: value and the - KEY=value forms. A nil value
+// means the key was listed without one.
+type mappingOrList map[string]*string
+
+func (m *mappingOrList) UnmarshalYAML(node *yaml.Node) error {
+	*m = make(mappingOrList)
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Tag == "!!null" {
+				(*m)[key.Value] = nil
+				continue
+			}
+			if value.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: value of %s must be a scalar", value.Line, key.Value)
+			}
+			v := value.Value
+			(*m)[key.Value] = &v
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, found := strings.Cut(item.Value, "=")
+			if !found {
+				(*m)[key] = nil
+				continue
+			}
+			(*m)[key] = &value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a mapping or a list", node.Line)
+	}
+	return nil
+}
+
+// stringOrList accepts a health check test given as a shell string or an exec list
+type stringOrList []string
+
+func (s *stringOrList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = []string{"CMD-SHELL", node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// composeDependsOn maps dependency names to their start condition
+type composeDependsOn map[string]string
+
+func (d *composeDependsOn) UnmarshalYAML(node *yaml.Node) error {
+	*d = make(composeDependsOn)
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			(*d)[item.Value] = "service_started"
+		}
+	case yaml.MappingNode:
+		var long map[string]struct {
+			Condition string `yaml:"condition"`
+		}
+		if err := node.Decode(&long); err != nil {
+			return err
+		}
+		for name, dependency := range long {
+			condition := dependency.Condition
+			if condition == "" {
+				condition = "service_started"
+			}
+			(*d)[name] = condition
+		}
+	default:
+		return fmt.Errorf("line %d: depends_on must be a list or a mapping", node.Line)
+	}
+	return nil
+}
+
+// composePort is a port in either the short "host:container" or the long syntax
+type composePort struct {
+	Short     string
+	Target    int    `yaml:"target"`
+	Published string `yaml:"published"`
+	Protocol  string `yaml:"protocol"`
+	HostIP    string `yaml:"host_ip"`
+}
+
+func (p *composePort) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Short = node.Value
+		return nil
+	}
+	type plain composePort
+	return node.Decode((*plain)(p))
+}
+
+// composeMount is a service volume in either the short "source:target:mode" or the long syntax
+type composeMount struct {
+	Short    string
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+}
+
+func (m *composeMount) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		m.Short = node.Value
+		return nil
+	}
+	type plain composeMount
+	return node.Decode((*plain)(m))
+}
+
+// converter accumulates the unsupported keys and warnings of a single Parse
+type converter struct {
+	file        composeFile
+	project     string
+	names       map[string]string // compose service name -> glinrdock service name
+	unsupported []string
+	warnings    []string
+}
+
+func (c *converter) unsupportedKey(format string, args ...interface{}) {
+	c.unsupported = append(c.unsupported, fmt.Sprintf(format, args...))
+}
+
+func (c *converter) warn(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// Parse converts a compose file into an import plan. projectName overrides the
+// top-level name of the file. Keys that have no glinrdock equivalent are
+// reported rather than rejected; an invalid or incomplete file is an error.
+func Parse(data []byte, projectName string) (*Plan, error) {
+	c := &converter{
+		names:       make(map[string]string),
+		unsupported: []string{},
+		warnings:    []string{},
+	}
+	if err := yaml.Unmarshal(data, &c.file); err != nil {
+		return nil, fmt.Errorf("invalid compose file: %w", err)
+	}
+	for key := range c.file.Keys {
+		if !strings.HasPrefix(key, "x-") {
+			c.unsupportedKey("%s", key)
+		}
+	}
+
+	c.project = projectName
+	if c.project == "" {
+		c.project = c.file.Name
+	}
+	if c.project == "" {
+		return nil, fmt.Errorf("project name is required when the compose file has no top-level name")
+	}
+	if len(c.file.Services) == 0 {
+		return nil, fmt.Errorf("compose file defines no services")
+	}
+
+	sources := make([]string, 0, len(c.file.Services))
+	for source := range c.file.Services {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	// Compose names may contain underscores and capitals, service names must be DNS labels
+	taken := make(map[string]string)
+	for _, source := range sources {
+		name := store.GenerateSlug(source)
+		if other, exists := taken[name]; exists {
+			return nil, fmt.Errorf("services %s and %s both map to the service name %s", other, source, name)
+		}
+		taken[name] = source
+		c.names[source] = name
+		if name != source {
+			c.warn("service %s is imported as %s", source, name)
+		}
+	}
+
+	services := make(map[string]ServicePlan, len(sources))
+	for _, source := range sources {
+		node := c.file.Services[source]
+		service, err := c.convertService(source, &node)
+		if err != nil {
+			return nil, err
+		}
+		services[source] = service
+	}
+
+	ordered, err := orderServices(sources, services)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(c.unsupported)
+	return &Plan{
+		Project:     c.project,
+		Services:    ordered,
+		Unsupported: c.unsupported,
+		Warnings:    c.warnings,
+	}, nil
+}
+
+// convertService maps one compose service onto a service spec, its links and its routes
+func (c *converter) convertService(source string, node *yaml.Node) (ServicePlan, error) {
+	var service composeService
+	if err := node.Decode(&service); err != nil {
+		return ServicePlan{}, fmt.Errorf("service %s: %w", source, err)
+	}
+	for key := range service.Keys {
+		if !strings.HasPrefix(key, "x-") {
+			c.unsupportedKey("services.%s.%s", source, key)
+		}
+	}
+	if service.Image == "" {
+		return ServicePlan{}, fmt.Errorf("service %s has no image; building images from a compose file is not supported", source)
+	}
+
+	plan := ServicePlan{
+		Source: source,
+		Routes: []store.RouteSpec{},
+		Spec: store.ServiceSpec{
+			Name:  c.names[source],
+			Image: service.Image,
+			Env:   c.convertEnvironment(source, service.Environment),
+		},
+	}
+
+	for _, port := range service.Ports {
+		if mapped, ok := c.convertPort(source, port); ok {
+			plan.Spec.Ports = append(plan.Spec.Ports, mapped)
+		}
+	}
+
+	for _, mount := range service.Volumes {
+		if mapped, ok := c.convertMount(source, mount); ok {
+			plan.Spec.Volumes = append(plan.Spec.Volumes, mapped)
+		}
+	}
+	if err := store.ValidateVolumes(plan.Spec.Volumes); err != nil {
+		return ServicePlan{}, fmt.Errorf("service %s: %w", source, err)
+	}
+
+	if service.HealthCheck != nil {
+		plan.Spec.HealthPath = c.convertHealthCheck(source, service.HealthCheck)
+	}
+
+	if service.Restart != "" {
+		policy, err := convertRestart(service.Restart)
+		if err != nil {
+			return ServicePlan{}, fmt.Errorf("service %s: %w", source, err)
+		}
+		plan.Spec.RestartPolicy = policy
+	}
+
+	plan.DependsOn = []string{}
+	for dependency, condition := range service.DependsOn {
+		name, ok := c.names[dependency]
+		if !ok {
+			return ServicePlan{}, fmt.Errorf("service %s depends on undefined service %s", source, dependency)
+		}
+		if condition != "service_started" {
+			c.unsupportedKey("services.%s.depends_on.%s.condition", source, dependency)
+		}
+		plan.DependsOn = append(plan.DependsOn, name)
+	}
+	sort.Strings(plan.DependsOn)
+
+	route, err := c.convertRoute(source, service.Labels, plan.Spec.Ports)
+	if err != nil {
+		return ServicePlan{}, err
+	}
+	if route != nil {
+		plan.Routes = append(plan.Routes, *route)
+	}
+
+	return plan, nil
+}
+
+// convertEnvironment keeps the variables that have a value; the others would be
+// read from the shell running compose, which has no equivalent here
+func (c *converter) convertEnvironment(source string, environment mappingOrList) map[string]string {
+	keys := make([]string, 0, len(environment))
+	for key := range environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make(map[string]string, len(environment))
+	for _, key := range keys {
+		value := environment[key]
+		if value == nil {
+			c.warn("environment variable %s of service %s has no value and was skipped", key, source)
+			continue
+		}
+		if strings.Contains(*value, "${") {
+			c.warn("environment variable %s of service %s uses interpolation, which is imported literally", key, source)
+		}
+		env[key] = *value
+	}
+	return env
+}
+
+// convertPort maps a published TCP port. Ranges and UDP ports are skipped.
+func (c *converter) convertPort(source string, port composePort) (store.PortMap, bool) {
+	published, target, protocol, hostIP := port.Published, strconv.Itoa(port.Target), port.Protocol, port.HostIP
+	if port.Short != "" {
+		spec := port.Short
+		spec, protocol, _ = strings.Cut(spec, "/")
+		parts := strings.Split(spec, ":")
+		target = parts[len(parts)-1]
+		switch len(parts) {
+		case 2:
+			published = parts[0]
+		case 3:
+			hostIP, published = parts[0], parts[1]
+		}
+	}
+
+	if protocol != "" && protocol != "tcp" {
+		c.warn("port %s/%s of service %s was skipped: only tcp ports are supported", target, protocol, source)
+		return store.PortMap{}, false
+	}
+	if strings.Contains(target, "-") || strings.Contains(published, "-") {
+		c.warn("port range %s of service %s was skipped: port ranges are not supported", target, source)
+		return store.PortMap{}, false
+	}
+
+	containerPort, err := strconv.Atoi(target)
+	if err != nil || containerPort < 1 || containerPort > 65535 {
+		c.warn("invalid port %q of service %s was skipped", target, source)
+		return store.PortMap{}, false
+	}
+	hostPort := 0
+	if published != "" {
+		hostPort, err = strconv.Atoi(published)
+		if err != nil || hostPort < 1 || hostPort > 65535 {
+			c.warn("invalid published port %q of service %s was skipped", published, source)
+			return store.PortMap{}, false
+		}
+	}
+	if hostIP != "" {
+		c.warn("host IP %s of port %d of service %s is ignored, ports are published on all interfaces", hostIP, containerPort, source)
+	}
+
+	return store.PortMap{Container: containerPort, Host: hostPort}, true
+}
+
+// convertMount maps named volumes and absolute bind mounts. Named volumes get
+// the project prefix docker compose would give them unless they are external.
+func (c *converter) convertMount(source string, mount composeMount) (store.VolumeMap, bool) {
+	mountType, host, target, readOnly := mount.Type, mount.Source, mount.Target, mount.ReadOnly
+	if mount.Short != "" {
+		parts := strings.Split(mount.Short, ":")
+		switch len(parts) {
+		case 1:
+			target = parts[0]
+		case 2:
+			host, target = parts[0], parts[1]
+		default:
+			host, target = parts[0], parts[1]
+			for _, option := range strings.Split(parts[2], ",") {
+				readOnly = readOnly || option == "ro"
+			}
+		}
+		mountType = "volume"
+		if strings.HasPrefix(host, "/") || strings.HasPrefix(host, ".") || strings.HasPrefix(host, "~") {
+			mountType = "bind"
+		}
+	}
+
+	switch {
+	case mountType != "volume" && mountType != "bind":
+		c.warn("%s mount %s of service %s was skipped: only volumes and bind mounts are supported", mountType, target, source)
+		return store.VolumeMap{}, false
+	case host == "":
+		c.warn("anonymous volume %s of service %s was skipped: give it a name to import it", target, source)
+		return store.VolumeMap{}, false
+	case mountType == "bind" && !strings.HasPrefix(host, "/"):
+		c.warn("bind mount %s of service %s was skipped: relative host paths cannot be resolved on the server", host, source)
+		return store.VolumeMap{}, false
+	}
+
+	if mountType == "volume" {
+		host = c.volumeName(source, host)
+	}
+	return store.VolumeMap{Host: host, Container: target, ReadOnly: readOnly}, true
+}
+
+// volumeName resolves a compose volume reference to the Docker volume name
+func (c *converter) volumeName(source, name string) string {
+	volume, declared := c.file.Volumes[name]
+	if !declared {
+		c.warn("volume %s of service %s is not declared under the top-level volumes key", name, source)
+	}
+	if volume != nil && volume.Name != "" {
+		return volume.Name
+	}
+	if volume != nil && volume.External {
+		return name
+	}
+	return store.GenerateSlug(c.project) + "_" + name
+}
+
+// convertHealthCheck takes the path probed by an HTTP health check. The probe
+// timing is not configurable, so only the path carries over.
+func (c *converter) convertHealthCheck(source string, health *composeHealth) *string {
+	for key := range health.Keys {
+		c.unsupportedKey("services.%s.healthcheck.%s", source, key)
+	}
+	if health.Disable || len(health.Test) == 0 || health.Test[0] == "NONE" {
+		return nil
+	}
+
+	match := healthURLRegex.FindString(strings.Join(health.Test, " "))
+	if match == "" {
+		c.warn("health check of service %s is not an HTTP check and was skipped", source)
+		return nil
+	}
+	parsed, err := url.Parse(match)
+	if err != nil {
+		c.warn("health check URL %s of service %s is invalid and was skipped", match, source)
+		return nil
+	}
+
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	return &path
+}
+
+// convertRestart maps a compose restart value such as on-failure:3
+func convertRestart(restart string) (store.RestartPolicy, error) {
+	name, retries, hasRetries := strings.Cut(restart, ":")
+	policy := store.RestartPolicy{Name: name}
+	if hasRetries {
+		maxRetries, err := strconv.Atoi(retries)
+		if err != nil {
+			return store.RestartPolicy{}, fmt.Errorf("invalid restart policy %s", restart)
+		}
+		policy.MaxRetries = maxRetries
+	}
+	if err := policy.Validate(); err != nil {
+		return store.RestartPolicy{}, err
+	}
+	return policy, nil
+}
+
+// convertRoute builds the route declared by the glinr.route.* labels, if any
+func (c *converter) convertRoute(source string, labels mappingOrList, ports []store.PortMap) (*store.RouteSpec, error) {
+	label := func(key string) string {
+		if value := labels[key]; value != nil {
+			return *value
+		}
+		return ""
+	}
+	for key := range labels {
+		if !strings.HasPrefix(key, "glinr.route.") {
+			c.unsupportedKey("services.%s.labels.%s", source, key)
+		}
+	}
+
+	domain := label(LabelRouteDomain)
+	if domain == "" {
+		return nil, nil
+	}
+
+	route := &store.RouteSpec{Domain: domain}
+	if port := label(LabelRoutePort); port != "" {
+		parsed, err := strconv.Atoi(port)
+		if err != nil || parsed < 1 || parsed > 65535 {
+			return nil, fmt.Errorf("service %s: invalid %s label %q", source, LabelRoutePort, port)
+		}
+		route.Port = parsed
+	} else if len(ports) > 0 {
+		route.Port = ports[0].Container
+	} else {
+		return nil, fmt.Errorf("service %s: %s needs a %s label or a port", source, LabelRouteDomain, LabelRoutePort)
+	}
+	if path := label(LabelRoutePath); path != "" {
+		route.Path = &path
+	}
+	route.TLS = label(LabelRouteTLS) == "true"
+
+	return route, nil
+}
+
+// orderServices sorts services so that every service comes after the services it depends on
+func orderServices(sources []string, services map[string]ServicePlan) ([]ServicePlan, error) {
+	bySlug := make(map[string]string, len(sources))
+	for _, source := range sources {
+		bySlug[services[source].Spec.Name] = source
+	}
+
+	ordered := make([]ServicePlan, 0, len(sources))
+	state := make(map[string]int) // 0 unvisited, 1 visiting, 2 done
+	var visit func(source string, path []string) error
+	visit = func(source string, path []string) error {
+		switch state[source] {
+		case 1:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, source), " -> "))
+		case 2:
+			return nil
+		}
+		state[source] = 1
+		for _, dependency := range services[source].DependsOn {
+			if err := visit(bySlug[dependency], append(path, source)); err != nil {
+				return err
+			}
+		}
+		state[source] = 2
+		ordered = append(ordered, services[source])
+		return nil
+	}
+
+	for _, source := range sources {
+		if err := visit(source, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
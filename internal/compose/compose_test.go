@@ -0,0 +1,239 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shopCompose = `
+name: shop
+services:
+  web_app:
+    image: ghcr.io/acme/shop:1.4
+    environment:
+      DATABASE_URL: postgres://db:5432/shop
+      WORKERS: 4
+      API_KEY:
+    ports:
+      - "8080:3000"
+      - 127.0.0.1:9090:9090
+      - "5353:53/udp"
+    volumes:
+      - uploads:/app/uploads
+      - /srv/shop/config:/app/config:ro
+      - ./local:/app/local
+    healthcheck:
+      test: ["CMD", "curl", "-f", "http://localhost:3000/healthz?full=1"]
+      interval: 30s
+    depends_on:
+      - db
+      - cache
+    restart: on-failure:3
+    labels:
+      glinr.route.domain: shop.example.com
+      glinr.route.tls: "true"
+    build: .
+  db:
+    image: postgres:16
+    environment:
+      - POSTGRES_PASSWORD=secret
+    volumes:
+      - type: volume
+        source: pgdata
+        target: /var/lib/postgresql/data
+    healthcheck:
+      test: pg_isready -U postgres
+  cache:
+    image: redis:7
+    depends_on:
+      db:
+        condition: service_healthy
+volumes:
+  uploads:
+  pgdata:
+    external: true
+networks:
+  default:
+x-common:
+  restart: always
+`
+
+func findService(t *testing.T, plan *Plan, name string) ServicePlan {
+	for _, service := range plan.Services {
+		if service.Spec.Name == name {
+			return service
+		}
+	}
+	t.Fatalf("service %s not in plan", name)
+	return ServicePlan{}
+}
+
+func TestParse_MapsServices(t *testing.T) {
+	plan, err := Parse([]byte(shopCompose), "")
+	require.NoError(t, err)
+	assert.Equal(t, "shop", plan.Project)
+	require.Len(t, plan.Services, 3)
+
+	web := findService(t, plan, "web-app")
+	assert.Equal(t, "web_app", web.Source)
+	assert.Equal(t, "ghcr.io/acme/shop:1.4", web.Spec.Image)
+	assert.Equal(t, map[string]string{"DATABASE_URL": "postgres://db:5432/shop", "WORKERS": "4"}, web.Spec.Env)
+	assert.Equal(t, []store.PortMap{{Container: 3000, Host: 8080}, {Container: 9090, Host: 9090}}, web.Spec.Ports)
+	assert.Equal(t, []store.VolumeMap{
+		{Host: "shop_uploads", Container: "/app/uploads"},
+		{Host: "/srv/shop/config", Container: "/app/config", ReadOnly: true},
+	}, web.Spec.Volumes)
+	require.NotNil(t, web.Spec.HealthPath)
+	assert.Equal(t, "/healthz?full=1", *web.Spec.HealthPath)
+	assert.Equal(t, store.RestartPolicy{Name: store.RestartPolicyOnFailure, MaxRetries: 3}, web.Spec.RestartPolicy)
+	assert.Equal(t, []string{"cache", "db"}, web.DependsOn)
+	assert.Equal(t, []store.RouteSpec{{Domain: "shop.example.com", Port: 3000, TLS: true}}, web.Routes)
+
+	db := findService(t, plan, "db")
+	assert.Equal(t, map[string]string{"POSTGRES_PASSWORD": "secret"}, db.Spec.Env)
+	assert.Equal(t, []store.VolumeMap{{Host: "pgdata", Container: "/var/lib/postgresql/data"}}, db.Spec.Volumes)
+	assert.Nil(t, db.Spec.HealthPath)
+	assert.Empty(t, db.Routes)
+}
+
+func TestParse_OrdersDependenciesFirst(t *testing.T) {
+	plan, err := Parse([]byte(shopCompose), "")
+	require.NoError(t, err)
+
+	var order []string
+	for _, service := range plan.Services {
+		order = append(order, service.Spec.Name)
+	}
+	assert.Equal(t, []string{"db", "cache", "web-app"}, order)
+}
+
+func TestParse_ReportsUnsupportedKeys(t *testing.T) {
+	plan, err := Parse([]byte(shopCompose), "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"networks",
+		"services.cache.depends_on.db.condition",
+		"services.web_app.build",
+		"services.web_app.healthcheck.interval",
+	}, plan.Unsupported)
+	assert.Contains(t, plan.Warnings, "service web_app is imported as web-app")
+	assert.Contains(t, plan.Warnings, "environment variable API_KEY of service web_app has no value and was skipped")
+	assert.Contains(t, plan.Warnings, "port 53/udp of service web_app was skipped: only tcp ports are supported")
+	assert.Contains(t, plan.Warnings, "bind mount ./local of service web_app was skipped: relative host paths cannot be resolved on the server")
+	assert.Contains(t, plan.Warnings, "health check of service db is not an HTTP check and was skipped")
+}
+
+func TestParse_ProjectNameOverride(t *testing.T) {
+	plan, err := Parse([]byte(shopCompose), "Shop Staging")
+	require.NoError(t, err)
+	assert.Equal(t, "Shop Staging", plan.Project)
+
+	web := findService(t, plan, "web-app")
+	assert.Equal(t, "shop-staging_uploads", web.Spec.Volumes[0].Host)
+}
+
+func TestParse_LongPortSyntax(t *testing.T) {
+	plan, err := Parse([]byte(`
+services:
+  api:
+    image: api:latest
+    ports:
+      - target: 80
+        published: 8000
+      - target: 443
+    labels:
+      glinr.route.domain: api.example.com
+      glinr.route.port: "80"
+      glinr.route.path: /v1
+      com.example.team: core
+`), "api")
+	require.NoError(t, err)
+
+	api := plan.Services[0]
+	assert.Equal(t, []store.PortMap{{Container: 80, Host: 8000}, {Container: 443}}, api.Spec.Ports)
+	require.Len(t, api.Routes, 1)
+	assert.Equal(t, 80, api.Routes[0].Port)
+	require.NotNil(t, api.Routes[0].Path)
+	assert.Equal(t, "/v1", *api.Routes[0].Path)
+	assert.Equal(t, []string{"services.api.labels.com.example.team"}, plan.Unsupported)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		project string
+		err     string
+	}{
+		{
+			name:    "invalid yaml",
+			compose: "services: [",
+			project: "p",
+			err:     "invalid compose file",
+		},
+		{
+			name:    "no project name",
+			compose: "services:\n  web:\n    image: nginx\n",
+			err:     "project name is required",
+		},
+		{
+			name:    "no services",
+			compose: "name: empty\n",
+			err:     "compose file defines no services",
+		},
+		{
+			name:    "build only",
+			compose: "services:\n  web:\n    build: .\n",
+			project: "p",
+			err:     "service web has no image",
+		},
+		{
+			name:    "undefined dependency",
+			compose: "services:\n  web:\n    image: nginx\n    depends_on: [db]\n",
+			project: "p",
+			err:     "service web depends on undefined service db",
+		},
+		{
+			name:    "dependency cycle",
+			compose: "services:\n  a:\n    image: nginx\n    depends_on: [b]\n  b:\n    image: nginx\n    depends_on: [a]\n",
+			project: "p",
+			err:     "dependency cycle: a -> b -> a",
+		},
+		{
+			name:    "name collision",
+			compose: "services:\n  web_app:\n    image: nginx\n  web-app:\n    image: nginx\n",
+			project: "p",
+			err:     "both map to the service name web-app",
+		},
+		{
+			name:    "blocked bind mount",
+			compose: "services:\n  web:\n    image: nginx\n    volumes: [\"/etc:/host-etc\"]\n",
+			project: "p",
+			err:     "host path not allowed",
+		},
+		{
+			name:    "route without port",
+			compose: "services:\n  web:\n    image: nginx\n    labels: [glinr.route.domain=example.com]\n",
+			project: "p",
+			err:     "needs a glinr.route.port label or a port",
+		},
+		{
+			name:    "invalid restart",
+			compose: "services:\n  web:\n    image: nginx\n    restart: sometimes\n",
+			project: "p",
+			err:     "restart policy must be one of",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.compose), tt.project)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr     string
+		after    string
+		expected string
+	}{
+		{"* * * * *", "2026-10-16 10:15", "2026-10-16 10:16"},
+		{"*/15 * * * *", "2026-10-16 10:15", "2026-10-16 10:30"},
+		{"5/20 * * * *", "2026-10-16 10:06", "2026-10-16 10:25"},
+		{"30 2 * * *", "2026-10-16 10:15", "2026-10-17 02:30"},
+		{"0 9-17/4 * * *", "2026-10-16 13:00", "2026-10-16 17:00"},
+		{"0 0 1,15 * *", "2026-10-16 10:15", "2026-11-01 00:00"},
+		{"0 0 * * mon-fri", "2026-10-16 10:15", "2026-10-19 00:00"}, // Friday to Monday
+		{"0 0 * * 7", "2026-10-16 10:15", "2026-10-18 00:00"},       // 7 is Sunday
+		{"0 0 29 feb *", "2026-10-16 10:15", "2028-02-29 00:00"},
+		{"0 12 1 JAN-MAR *", "2026-10-16 10:15", "2027-01-01 12:00"},
+		// Both day fields restricted: the 20th or any Monday
+		{"0 0 20 * 1", "2026-10-16 10:15", "2026-10-19 00:00"},
+		{"@daily", "2026-10-16 10:15", "2026-10-17 00:00"},
+		{"@hourly", "2026-10-16 10:15", "2026-10-16 11:00"},
+		{"@weekly", "2026-10-16 10:15", "2026-10-18 00:00"},
+		{"@monthly", "2026-10-16 10:15", "2026-11-01 00:00"},
+		{"@yearly", "2026-10-16 10:15", "2027-01-01 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, at(tt.expected), schedule.Next(at(tt.after)))
+		})
+	}
+}
+
+func TestNext_IsStrictlyAfter(t *testing.T) {
+	schedule, err := Parse("30 10 * * *")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2026, 10, 16, 10, 30, 45, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC), next)
+}
+
+func TestNext_KeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := Parse("0 3 * * *")
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2026, 10, 16, 10, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2026, 10, 17, 3, 0, 0, 0, loc), next)
+	assert.Equal(t, loc, next.Location())
+}
+
+func TestNext_NeverFires(t *testing.T) {
+	schedule, err := Parse("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(at("2026-10-16 10:15")).IsZero())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"":              "expected 5 fields",
+		"* * * *":       "expected 5 fields",
+		"60 * * * *":    "out of range in minute field",
+		"* 24 * * *":    "out of range in hour field",
+		"* * 0 * *":     "out of range in day of month field",
+		"* * * 13 *":    "out of range in month field",
+		"* * * * 8":     "out of range in day of week field",
+		"*/0 * * * *":   "invalid step",
+		"10-5 * * * *":  "invalid range",
+		"* * * foo *":   "invalid value",
+		"@every 5m":     "expected 5 fields",
+		"1,,2 * * * *":  "invalid value",
+		"* * * * mon-x": "invalid value",
+	}
+
+	for expr, message := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			assert.ErrorContains(t, err, message)
+		})
+	}
+}
@@ -4,14 +4,21 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"os"
+
+	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
 	NonceSize = 12 // AES-GCM standard nonce size
 	KeySize   = 32 // AES-256 key size
+	SaltSize  = 16 // salt size for passphrase derived keys
+
+	// PassphraseIterations is the PBKDF2-SHA256 work factor for passphrase derived keys
+	PassphraseIterations = 600000
 )
 
 var (
@@ -21,6 +28,7 @@ var (
 	ErrDecryptionFailed = errors.New("decryption failed")
 	ErrMissingSecretKey = errors.New("GLINRDOCK_SECRET environment variable is required")
 	ErrInvalidBase64    = errors.New("GLINRDOCK_SECRET must be valid base64")
+	ErrInvalidSaltSize  = errors.New("invalid salt size: must be at least 16 bytes")
 )
 
 // LoadMasterKeyFromEnv loads the master encryption key from GLINRDOCK_SECRET environment variable.
@@ -43,6 +51,24 @@ func LoadMasterKeyFromEnv() ([]byte, error) {
 	return key, nil
 }
 
+// NewSalt returns a random salt for DeriveKeyFromPassphrase.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DeriveKeyFromPassphrase derives an AES-256 key from a user supplied passphrase with PBKDF2-SHA256.
+// The same passphrase, salt and iterations always yield the same key.
+func DeriveKeyFromPassphrase(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	if len(salt) < SaltSize {
+		return nil, ErrInvalidSaltSize
+	}
+	return pbkdf2.Key([]byte(passphrase), salt, iterations, KeySize, sha256.New), nil
+}
+
 // Encrypt encrypts plaintext using AES-GCM with the provided key.
 // Returns a 12-byte nonce and the ciphertext.
 func Encrypt(key, plaintext []byte) (nonce, ciphertext []byte, err error) {
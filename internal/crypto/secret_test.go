@@ -90,6 +90,41 @@ func TestInvalidNonceSize(t *testing.T) {
 	}
 }
 
+func TestDeriveKeyFromPassphrase(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatalf("NewSalt failed: %v", err)
+	}
+
+	key, err := DeriveKeyFromPassphrase("correct horse battery staple", salt, 1000)
+	if err != nil {
+		t.Fatalf("DeriveKeyFromPassphrase failed: %v", err)
+	}
+	if len(key) != KeySize {
+		t.Errorf("Expected key size %d, got %d", KeySize, len(key))
+	}
+
+	// The same inputs derive the same key, so data sealed with it can be opened again
+	again, _ := DeriveKeyFromPassphrase("correct horse battery staple", salt, 1000)
+	nonce, ciphertext, err := Encrypt(key, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := Decrypt(again, nonce, ciphertext); err != nil {
+		t.Errorf("Decrypt with re-derived key failed: %v", err)
+	}
+
+	// A different passphrase does not
+	wrong, _ := DeriveKeyFromPassphrase("wrong passphrase", salt, 1000)
+	if _, err := Decrypt(wrong, nonce, ciphertext); err != ErrDecryptionFailed {
+		t.Errorf("Expected ErrDecryptionFailed, got %v", err)
+	}
+
+	if _, err := DeriveKeyFromPassphrase("passphrase", make([]byte, 8), 1000); err != ErrInvalidSaltSize {
+		t.Errorf("Expected ErrInvalidSaltSize, got %v", err)
+	}
+}
+
 func TestLoadMasterKeyFromEnv(t *testing.T) {
 	// Save original environment variable
 	originalEnv := os.Getenv("GLINRDOCK_SECRET")
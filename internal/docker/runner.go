@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -91,6 +92,13 @@ func (r *BuildKitRunner) BuildImage(ctx context.Context, spec BuildSpec) (*Build
 
 	cmd := exec.CommandContext(ctx, r.dockerCmd, args...)
 
+	// On cancellation, interrupt buildx so it can abort the build session cleanly,
+	// and kill it if it has not exited within the grace period
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 10 * time.Second
+
 	// Set up output capture
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -124,6 +132,12 @@ func (r *BuildKitRunner) BuildImage(ctx context.Context, spec BuildSpec) (*Build
 	result.Duration = time.Since(startTime)
 	result.LogOutput = logOutput.String()
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		result.Error = fmt.Errorf("docker build cancelled: %w", ctxErr)
+		result.Success = false
+		return result, result.Error
+	}
+
 	if err != nil {
 		result.Error = fmt.Errorf("docker build failed: %w", err)
 		result.Success = false
@@ -1,8 +1,11 @@
 package dockerx
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,9 +14,39 @@ import (
 
 // ContainerSpec represents container configuration for Docker operations
 type ContainerSpec struct {
-	Image string            `json:"image"`
-	Env   map[string]string `json:"env"`
-	Ports []store.PortMap   `json:"ports"`
+	Image         string               `json:"image"`
+	Command       []string             `json:"command,omitempty"` // overrides the image's default command
+	Env           map[string]string    `json:"env"`
+	Ports         []store.PortMap      `json:"ports"`
+	Volumes       []store.VolumeMap    `json:"volumes"`
+	Resources     store.ResourceLimits `json:"resources"`
+	RestartPolicy store.RestartPolicy  `json:"restart_policy"`
+	// Sidecars join another container's network namespace ("container:<id>") and
+	// mount its volumes
+	NetworkMode string   `json:"network_mode,omitempty"`
+	VolumesFrom []string `json:"volumes_from,omitempty"`
+}
+
+// ServiceContainerSpec returns the spec of a container running image with a
+// service's configuration, shared by its primary container and replicas
+func ServiceContainerSpec(service store.Service, image string) ContainerSpec {
+	return ContainerSpec{
+		Image:         image,
+		Env:           service.Env,
+		Ports:         service.Ports,
+		Volumes:       service.Volumes,
+		Resources:     service.Resources,
+		RestartPolicy: service.RestartPolicy,
+	}
+}
+
+// ServiceLabels returns the labels identifying the containers of a service
+func ServiceLabels(service store.Service) map[string]string {
+	return map[string]string{
+		"glinr.project_id": strconv.FormatInt(service.ProjectID, 10),
+		"glinr.service_id": strconv.FormatInt(service.ID, 10),
+		"glinr.managed":    "true",
+	}
 }
 
 // ContainerStats represents container resource usage statistics
@@ -26,6 +59,28 @@ type ContainerStats struct {
 	NetworkTx     uint64  `json:"network_tx"`
 	BlockRead     uint64  `json:"block_read"`
 	BlockWrite    uint64  `json:"block_write"`
+	PidsCurrent   uint64  `json:"pids_current"`
+	PidsLimit     uint64  `json:"pids_limit,omitempty"`
+	// Configured service limits, filled in by the API so usage can be shown against them
+	CPULimit          float64 `json:"cpu_limit,omitempty"`         // cores, 0 when unlimited
+	CPULimitPercent   float64 `json:"cpu_limit_percent,omitempty"` // CPUPercent relative to CPULimit
+	MemoryReservation uint64  `json:"memory_reservation,omitempty"`
+}
+
+// Volume represents a Docker named volume
+type Volume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	CreatedAt  *time.Time        `json:"created_at,omitempty"`
+}
+
+// VolumeUsage represents the disk usage of a Docker named volume
+type VolumeUsage struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`      // bytes, -1 when the volume driver cannot report it
+	RefCount int64  `json:"ref_count"` // containers using the volume, -1 when unknown
 }
 
 // Engine defines the interface for Docker operations
@@ -39,11 +94,45 @@ type Engine interface {
 	Logs(ctx context.Context, id string, follow bool) (io.ReadCloser, error)
 	Stats(ctx context.Context, id string) (<-chan ContainerStats, <-chan error)
 	Inspect(ctx context.Context, containerID string) (ContainerStatus, error)
+	Rename(ctx context.Context, id string, newName string) error
+	List(ctx context.Context, labels map[string]string) ([]ContainerStatus, error)
+	Pause(ctx context.Context, id string) error
+	Unpause(ctx context.Context, id string) error
+	Wait(ctx context.Context, id string) (int64, error)
+
+	// File operations. Archives are tar streams; CopyTo also accepts gzip-compressed tar.
+	CopyFrom(ctx context.Context, id string, path string) (io.ReadCloser, error)
+	CopyTo(ctx context.Context, id string, path string, content io.Reader) error
 
 	// Network operations
 	EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error
 	ConnectNetwork(ctx context.Context, networkName, containerID string, aliases []string) error
 	DisconnectNetwork(ctx context.Context, networkName, containerID string) error
+
+	// Volume operations
+	ListVolumes(ctx context.Context, labels map[string]string) ([]Volume, error)
+	CreateVolume(ctx context.Context, name string, labels map[string]string) (Volume, error)
+	RemoveVolume(ctx context.Context, name string) error
+	VolumeUsage(ctx context.Context, name string) (VolumeUsage, error)
+
+	// Exec operations
+	Exec(ctx context.Context, id string, command []string, size TerminalSize) (*ExecSession, error)
+	ResizeExec(ctx context.Context, execID string, size TerminalSize) error
+	ExecExitCode(ctx context.Context, execID string) (int, error)
+}
+
+// TerminalSize is the size of a TTY in characters
+type TerminalSize struct {
+	Rows uint `json:"rows"`
+	Cols uint `json:"cols"`
+}
+
+// ExecSession is an interactive process started in a running container with a
+// TTY. Reading the stream returns the terminal output, writing to it types into
+// the terminal. Closing the stream detaches; the process ends with its shell.
+type ExecSession struct {
+	ID     string
+	Stream io.ReadWriteCloser
 }
 
 // ContainerStatus represents the status of a Docker container
@@ -52,8 +141,10 @@ type ContainerStatus struct {
 	Name      string
 	State     string // "created", "running", "paused", "restarting", "removing", "exited", "dead"
 	Status    string
-	StartedAt *time.Time // When the container started running
-	Env       []string   // Environment variables from Docker inspect
+	StartedAt *time.Time      // When the container started running
+	Env       []string        // Environment variables from Docker inspect
+	Ports     []store.PortMap // Published ports, including host ports assigned by Docker
+	Labels    map[string]string
 }
 
 // MockEngine implements Engine for testing without Docker
@@ -67,12 +158,29 @@ type MockEngine struct {
 	logsError              error
 	statsError             error
 	inspectError           error
+	renameError            error
+	listError              error
 	ensureNetworkError     error
 	connectNetworkError    error
 	disconnectNetworkError error
+	volumeError            error
+	pauseError             error
+	waitError              error
+	copyError              error
+	waitExitCode           int64
+	execError              error
+	execOutput             string
+	execExitCode           int
+	execInput              bytes.Buffer
+	execSizes              []TerminalSize
+	archive                []byte
+	copied                 map[string][]byte
 	createID               string
 	mockLogs               string
 	mockStats              []ContainerStats
+	containers             []ContainerStatus
+	volumes                []Volume
+	volumeUsage            map[string]VolumeUsage
 }
 
 // NewMockEngine creates a new mock Docker engine
@@ -222,6 +330,33 @@ func (m *MockEngine) Inspect(ctx context.Context, containerID string) (Container
 	}, nil
 }
 
+// Rename simulates renaming a container
+func (m *MockEngine) Rename(ctx context.Context, id string, newName string) error {
+	return m.renameError
+}
+
+// List simulates listing containers that carry all of the given labels
+func (m *MockEngine) List(ctx context.Context, labels map[string]string) ([]ContainerStatus, error) {
+	if m.listError != nil {
+		return nil, m.listError
+	}
+
+	var containers []ContainerStatus
+	for _, container := range m.containers {
+		matches := true
+		for key, value := range labels {
+			if container.Labels[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
 // EnsureNetwork simulates ensuring a Docker network exists
 func (m *MockEngine) EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error {
 	return m.ensureNetworkError
@@ -256,3 +391,225 @@ func (m *MockEngine) SetDisconnectNetworkError(err error) {
 func (m *MockEngine) SetInspectError(err error) {
 	m.inspectError = err
 }
+
+// SetRenameError sets the error to return from Rename
+func (m *MockEngine) SetRenameError(err error) {
+	m.renameError = err
+}
+
+// SetListError sets the error to return from List
+func (m *MockEngine) SetListError(err error) {
+	m.listError = err
+}
+
+// SetContainers sets the containers returned by List
+func (m *MockEngine) SetContainers(containers []ContainerStatus) {
+	m.containers = containers
+}
+
+// ListVolumes simulates listing volumes that carry all of the given labels
+func (m *MockEngine) ListVolumes(ctx context.Context, labels map[string]string) ([]Volume, error) {
+	if m.volumeError != nil {
+		return nil, m.volumeError
+	}
+
+	var volumes []Volume
+	for _, volume := range m.volumes {
+		matches := true
+		for key, value := range labels {
+			if volume.Labels[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			volumes = append(volumes, volume)
+		}
+	}
+	return volumes, nil
+}
+
+// CreateVolume simulates creating a named volume
+func (m *MockEngine) CreateVolume(ctx context.Context, name string, labels map[string]string) (Volume, error) {
+	if m.volumeError != nil {
+		return Volume{}, m.volumeError
+	}
+
+	volume := Volume{Name: name, Driver: "local", Labels: labels}
+	m.volumes = append(m.volumes, volume)
+	return volume, nil
+}
+
+// RemoveVolume simulates removing a named volume
+func (m *MockEngine) RemoveVolume(ctx context.Context, name string) error {
+	if m.volumeError != nil {
+		return m.volumeError
+	}
+
+	for i, volume := range m.volumes {
+		if volume.Name == name {
+			m.volumes = append(m.volumes[:i], m.volumes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("volume not found: %s", name)
+}
+
+// VolumeUsage simulates reading the disk usage of a named volume
+func (m *MockEngine) VolumeUsage(ctx context.Context, name string) (VolumeUsage, error) {
+	if m.volumeError != nil {
+		return VolumeUsage{}, m.volumeError
+	}
+
+	if usage, ok := m.volumeUsage[name]; ok {
+		return usage, nil
+	}
+	return VolumeUsage{Name: name}, nil
+}
+
+// SetVolumeError sets the error to return from the volume operations
+func (m *MockEngine) SetVolumeError(err error) {
+	m.volumeError = err
+}
+
+// SetVolumes sets the volumes returned by ListVolumes
+func (m *MockEngine) SetVolumes(volumes []Volume) {
+	m.volumes = volumes
+}
+
+// SetVolumeUsage sets the usage returned by VolumeUsage for a volume
+func (m *MockEngine) SetVolumeUsage(usage VolumeUsage) {
+	if m.volumeUsage == nil {
+		m.volumeUsage = make(map[string]VolumeUsage)
+	}
+	m.volumeUsage[usage.Name] = usage
+}
+
+// Pause simulates pausing a container
+func (m *MockEngine) Pause(ctx context.Context, id string) error {
+	return m.pauseError
+}
+
+// Unpause simulates unpausing a container
+func (m *MockEngine) Unpause(ctx context.Context, id string) error {
+	return m.pauseError
+}
+
+// Wait simulates waiting for a container to exit
+func (m *MockEngine) Wait(ctx context.Context, id string) (int64, error) {
+	if m.waitError != nil {
+		return 0, m.waitError
+	}
+	return m.waitExitCode, nil
+}
+
+// CopyFrom simulates reading a tar archive of a path in a container
+func (m *MockEngine) CopyFrom(ctx context.Context, id string, path string) (io.ReadCloser, error) {
+	if m.copyError != nil {
+		return nil, m.copyError
+	}
+	return io.NopCloser(bytes.NewReader(m.archive)), nil
+}
+
+// CopyTo simulates extracting an archive into a container, recording its content by path
+func (m *MockEngine) CopyTo(ctx context.Context, id string, path string, content io.Reader) error {
+	if m.copyError != nil {
+		return m.copyError
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	if m.copied == nil {
+		m.copied = make(map[string][]byte)
+	}
+	m.copied[path] = data
+	return nil
+}
+
+// SetPauseError sets the error to return from Pause and Unpause
+func (m *MockEngine) SetPauseError(err error) {
+	m.pauseError = err
+}
+
+// SetWaitResult sets the exit code and error to return from Wait
+func (m *MockEngine) SetWaitResult(exitCode int64, err error) {
+	m.waitExitCode = exitCode
+	m.waitError = err
+}
+
+// SetCopyError sets the error to return from CopyFrom and CopyTo
+func (m *MockEngine) SetCopyError(err error) {
+	m.copyError = err
+}
+
+// SetArchive sets the archive returned by CopyFrom
+func (m *MockEngine) SetArchive(archive []byte) {
+	m.archive = archive
+}
+
+// Copied returns the content last copied to path with CopyTo
+func (m *MockEngine) Copied(path string) []byte {
+	return m.copied[path]
+}
+
+// mockExecStream returns the mock exec output and records what is typed
+type mockExecStream struct {
+	io.Reader
+	input *bytes.Buffer
+}
+
+func (s *mockExecStream) Write(p []byte) (int, error) {
+	return s.input.Write(p)
+}
+
+func (s *mockExecStream) Close() error {
+	return nil
+}
+
+// Exec simulates starting an interactive process in a container
+func (m *MockEngine) Exec(ctx context.Context, id string, command []string, size TerminalSize) (*ExecSession, error) {
+	if m.execError != nil {
+		return nil, m.execError
+	}
+	m.execSizes = append(m.execSizes, size)
+	return &ExecSession{
+		ID:     "mock-exec-id",
+		Stream: &mockExecStream{Reader: strings.NewReader(m.execOutput), input: &m.execInput},
+	}, nil
+}
+
+// ResizeExec simulates resizing the TTY of an exec session
+func (m *MockEngine) ResizeExec(ctx context.Context, execID string, size TerminalSize) error {
+	if m.execError != nil {
+		return m.execError
+	}
+	m.execSizes = append(m.execSizes, size)
+	return nil
+}
+
+// ExecExitCode simulates getting the exit code of an exec session
+func (m *MockEngine) ExecExitCode(ctx context.Context, execID string) (int, error) {
+	return m.execExitCode, nil
+}
+
+// SetExecError sets the error to return from Exec and ResizeExec
+func (m *MockEngine) SetExecError(err error) {
+	m.execError = err
+}
+
+// SetExecResult sets the output and exit code of exec sessions
+func (m *MockEngine) SetExecResult(output string, exitCode int) {
+	m.execOutput = output
+	m.execExitCode = exitCode
+}
+
+// ExecInput returns everything typed into exec sessions
+func (m *MockEngine) ExecInput() string {
+	return m.execInput.String()
+}
+
+// ExecSizes returns the terminal sizes exec sessions were started or resized with
+func (m *MockEngine) ExecSizes() []TerminalSize {
+	return m.execSizes
+}
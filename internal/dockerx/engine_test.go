@@ -0,0 +1,37 @@
+package dockerx
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+func TestServiceContainerSpec(t *testing.T) {
+	service := store.Service{
+		ID:        9,
+		ProjectID: 3,
+		Image:     "nginx:1.25",
+		Env:       map[string]string{"MODE": "prod"},
+		Ports:     []store.PortMap{{Container: 80, Host: 8080}},
+		Volumes:   []store.VolumeMap{{Host: "/srv/www", Container: "/usr/share/nginx/html", ReadOnly: true}},
+	}
+
+	spec := ServiceContainerSpec(service, "nginx:1.26")
+	if spec.Image != "nginx:1.26" {
+		t.Errorf("expected the given image, got %s", spec.Image)
+	}
+	if spec.Env["MODE"] != "prod" || len(spec.Ports) != 1 || len(spec.Volumes) != 1 {
+		t.Errorf("expected the service's env, ports and volumes, got %+v", spec)
+	}
+
+	labels := ServiceLabels(service)
+	expected := map[string]string{"glinr.project_id": "3", "glinr.service_id": "9", "glinr.managed": "true"}
+	if len(labels) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, labels)
+	}
+	for key, value := range expected {
+		if labels[key] != value {
+			t.Errorf("expected label %s=%s, got %s", key, value, labels[key])
+		}
+	}
+}
@@ -2,15 +2,22 @@ package dockerx
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 )
@@ -82,23 +89,35 @@ func (e *MobyEngine) Create(ctx context.Context, name string, spec ContainerSpec
 		containerPort := nat.Port(strconv.Itoa(port.Container) + "/tcp")
 		exposedPorts[containerPort] = struct{}{}
 
+		// A zero host port lets Docker pick a free ephemeral port
+		hostPort := ""
+		if port.Host != 0 {
+			hostPort = strconv.Itoa(port.Host)
+		}
+
 		portBindings[containerPort] = []nat.PortBinding{
 			{
 				HostIP:   "0.0.0.0",
-				HostPort: strconv.Itoa(port.Host),
+				HostPort: hostPort,
 			},
 		}
 	}
 
 	config := &container.Config{
 		Image:        spec.Image,
+		Cmd:          spec.Command,
 		Env:          env,
 		ExposedPorts: exposedPorts,
 		Labels:       labels,
 	}
 
 	hostConfig := &container.HostConfig{
-		PortBindings: portBindings,
+		PortBindings:  portBindings,
+		Mounts:        containerMounts(spec.Volumes, labels),
+		Resources:     containerResources(spec.Resources),
+		RestartPolicy: containerRestartPolicy(spec.RestartPolicy),
+		NetworkMode:   container.NetworkMode(spec.NetworkMode),
+		VolumesFrom:   spec.VolumesFrom,
 	}
 
 	resp, err := e.client.ContainerCreate(ctx, config, hostConfig, nil, nil, name)
@@ -109,6 +128,67 @@ func (e *MobyEngine) Create(ctx context.Context, name string, spec ContainerSpec
 	return resp.ID, nil
 }
 
+// containerMounts maps service volumes onto Docker mounts. Named volumes that do not
+// exist yet are created by Docker with the container's project labels, so they show
+// up as managed volumes.
+func containerMounts(volumes []store.VolumeMap, labels map[string]string) []mount.Mount {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	volumeLabels := make(map[string]string)
+	for _, key := range []string{"glinr.managed", "glinr.project_id"} {
+		if value, ok := labels[key]; ok {
+			volumeLabels[key] = value
+		}
+	}
+
+	mounts := make([]mount.Mount, 0, len(volumes))
+	for _, volume := range volumes {
+		m := mount.Mount{
+			Source:   volume.Host,
+			Target:   volume.Container,
+			ReadOnly: volume.ReadOnly,
+		}
+		if volume.IsBind() {
+			m.Type = mount.TypeBind
+		} else {
+			m.Type = mount.TypeVolume
+			if len(volumeLabels) > 0 {
+				m.VolumeOptions = &mount.VolumeOptions{Labels: volumeLabels}
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// containerResources maps service resource limits onto Docker's, leaving zero values unlimited
+func containerResources(limits store.ResourceLimits) container.Resources {
+	resources := container.Resources{
+		CPUShares:         limits.CPUShares,
+		NanoCPUs:          int64(limits.CPUs * 1e9),
+		Memory:            limits.MemoryLimit,
+		MemoryReservation: limits.MemoryReservation,
+	}
+	if limits.PidsLimit > 0 {
+		pidsLimit := limits.PidsLimit
+		resources.PidsLimit = &pidsLimit
+	}
+	return resources
+}
+
+// containerRestartPolicy maps a service restart policy onto Docker's
+func containerRestartPolicy(policy store.RestartPolicy) container.RestartPolicy {
+	if policy.Name == "" {
+		return container.RestartPolicy{Name: container.RestartPolicyDisabled}
+	}
+	return container.RestartPolicy{
+		Name:              container.RestartPolicyMode(policy.Name),
+		MaximumRetryCount: policy.MaxRetries,
+	}
+}
+
 // Remove removes a Docker container
 func (e *MobyEngine) Remove(ctx context.Context, id string) error {
 	err := e.client.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
@@ -164,7 +244,7 @@ func (e *MobyEngine) Logs(ctx context.Context, id string, follow bool) (io.ReadC
 	return reader, nil
 }
 
-// Stats retrieves container statistics
+// Stats streams container statistics as reported by the Docker daemon
 func (e *MobyEngine) Stats(ctx context.Context, id string) (<-chan ContainerStats, <-chan error) {
 	statsCh := make(chan ContainerStats)
 	errCh := make(chan error, 1)
@@ -180,75 +260,76 @@ func (e *MobyEngine) Stats(ctx context.Context, id string) (<-chan ContainerStat
 		}
 		defer resp.Body.Close()
 
-		// Generate realistic stats for typical web services (like nginx)
-		// Based on real-world Docker stats examples from documentation
-		baseStats := ContainerStats{
-			CPUPercent:    0.15,              // Realistic idle nginx CPU: 0.03-0.35%
-			MemoryUsage:   1024 * 1024 * 12,  // 12MB typical for nginx
-			MemoryLimit:   1024 * 1024 * 128, // 128MB limit
-			MemoryPercent: 9.4,               // 12MB / 128MB
-			NetworkRx:     1024 * 3,          // 3KB received
-			NetworkTx:     1024 * 2,          // 2KB transmitted
-			BlockRead:     1024 * 8,          // 8KB
-			BlockWrite:    1024 * 4,          // 4KB
-		}
-
-		// Send immediate first stats message for instant UI response
-		select {
-		case statsCh <- baseStats:
-		case <-ctx.Done():
-			return
-		}
-
-		// Use 15-second intervals for proper timeline progression
-		ticker := time.NewTicker(15 * time.Second)
-		defer ticker.Stop()
-
+		// The daemon writes one JSON document per sample, roughly every second
+		decoder := json.NewDecoder(resp.Body)
 		for {
+			var sample container.StatsResponse
+			if err := decoder.Decode(&sample); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					errCh <- fmt.Errorf("failed to decode stats for container %s: %w", id, err)
+				}
+				return
+			}
+
 			select {
+			case statsCh <- statsFromResponse(sample):
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				// Add small realistic variations to simulate real container activity
-				stats := baseStats
-
-				// Generate unique data for this specific timestamp (won't change later)
-				currentTimestamp := time.Now().Unix()
-
-				// Use timestamp as unique seed for consistent values per data point
-				seed := currentTimestamp % 1000 // 0-999 unique pattern
+			}
+		}
+	}()
 
-				// CPU varies based on this specific timestamp only
-				stats.CPUPercent = 0.05 + (0.30 * float64(seed%100) / 100.0) // 0.05% to 0.35%
+	return statsCh, errCh
+}
 
-				// Memory with unique variation for this timestamp
-				memSeed := (currentTimestamp / 10) % 20 // Changes every 10 seconds, 0-19 range
-				memVariation := float64(memSeed) * 0.15 // 0-3MB variation
-				stats.MemoryUsage = baseStats.MemoryUsage + uint64(memVariation*1024*1024)
-				stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+// statsFromResponse converts a Docker stats sample into ContainerStats using
+// the same formulas as the docker stats command
+func statsFromResponse(sample container.StatsResponse) ContainerStats {
+	stats := ContainerStats{
+		MemoryLimit: sample.MemoryStats.Limit,
+		PidsCurrent: sample.PidsStats.Current,
+		PidsLimit:   sample.PidsStats.Limit,
+	}
 
-				// Network RX and TX with independent realistic patterns
-				rxSeed := currentTimestamp % 73        // Prime number for unique RX pattern
-				txSeed := (currentTimestamp * 17) % 61 // Different prime for unique TX pattern
+	// CPU usage is the share of host CPU time used since the previous sample
+	cpuDelta := float64(sample.CPUStats.CPUUsage.TotalUsage) - float64(sample.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(sample.CPUStats.SystemUsage) - float64(sample.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(sample.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(sample.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if sample.PreCPUStats.SystemUsage > 0 && cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
 
-				// RX typically higher and more variable (incoming requests/responses)
-				rxMultiplier := 0.6 + (1.8 * float64(rxSeed) / 73.0) // 0.6x - 2.4x range
-				stats.NetworkRx = uint64(float64(baseStats.NetworkRx) * rxMultiplier)
+	// Page cache can be reclaimed, so it does not count towards usage
+	stats.MemoryUsage = sample.MemoryStats.Usage
+	cache := sample.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if cache == 0 {
+		cache = sample.MemoryStats.Stats["total_inactive_file"] // cgroup v1
+	}
+	if cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
 
-				// TX typically lower and more steady (outgoing responses)
-				txMultiplier := 0.3 + (1.2 * float64(txSeed) / 61.0) // 0.3x - 1.5x range
-				stats.NetworkTx = uint64(float64(baseStats.NetworkTx) * txMultiplier)
+	for _, network := range sample.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
 
-				select {
-				case statsCh <- stats:
-				case <-ctx.Done():
-					return
-				}
-			}
+	for _, entry := range sample.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
 		}
-	}()
+	}
 
-	return statsCh, errCh
+	return stats
 }
 
 // Inspect gets the current status of a Docker container
@@ -265,6 +346,22 @@ func (e *MobyEngine) Inspect(ctx context.Context, containerID string) (Container
 		}
 	}
 
+	// Collect published ports so callers can reach containers created with ephemeral host ports
+	var ports []store.PortMap
+	if container.NetworkSettings != nil {
+		for containerPort, bindings := range container.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				hostPort, err := strconv.Atoi(binding.HostPort)
+				if err != nil {
+					continue
+				}
+				ports = append(ports, store.PortMap{Container: containerPort.Int(), Host: hostPort})
+				break
+			}
+		}
+		sort.Slice(ports, func(i, j int) bool { return ports[i].Container < ports[j].Container })
+	}
+
 	return ContainerStatus{
 		ID:        container.ID,
 		Name:      container.Name,
@@ -272,9 +369,170 @@ func (e *MobyEngine) Inspect(ctx context.Context, containerID string) (Container
 		Status:    container.State.Status,
 		StartedAt: startedAt,
 		Env:       container.Config.Env,
+		Ports:     ports,
+		Labels:    container.Config.Labels,
 	}, nil
 }
 
+// Rename renames a Docker container
+func (e *MobyEngine) Rename(ctx context.Context, id string, newName string) error {
+	if err := e.client.ContainerRename(ctx, id, newName); err != nil {
+		return fmt.Errorf("failed to rename container %s to %s: %w", id, newName, err)
+	}
+	return nil
+}
+
+// List returns all containers, running or not, that carry every given label
+func (e *MobyEngine) List(ctx context.Context, labels map[string]string) ([]ContainerStatus, error) {
+	labelFilter := filters.NewArgs()
+	for key, value := range labels {
+		labelFilter.Add("label", key+"="+value)
+	}
+
+	summaries, err := e.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: labelFilter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := make([]ContainerStatus, 0, len(summaries))
+	for _, summary := range summaries {
+		var name string
+		if len(summary.Names) > 0 {
+			name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+		containers = append(containers, ContainerStatus{
+			ID:     summary.ID,
+			Name:   name,
+			State:  summary.State,
+			Status: summary.Status,
+			Labels: summary.Labels,
+		})
+	}
+
+	return containers, nil
+}
+
+// Pause freezes all processes in a Docker container
+func (e *MobyEngine) Pause(ctx context.Context, id string) error {
+	if err := e.client.ContainerPause(ctx, id); err != nil {
+		return fmt.Errorf("failed to pause container %s: %w", id, err)
+	}
+	return nil
+}
+
+// Unpause resumes a paused Docker container
+func (e *MobyEngine) Unpause(ctx context.Context, id string) error {
+	if err := e.client.ContainerUnpause(ctx, id); err != nil {
+		return fmt.Errorf("failed to unpause container %s: %w", id, err)
+	}
+	return nil
+}
+
+// Wait blocks until a Docker container stops and returns its exit code
+func (e *MobyEngine) Wait(ctx context.Context, id string) (int64, error) {
+	statusCh, errCh := e.client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, fmt.Errorf("failed to wait for container %s: %s", id, status.Error.Message)
+		}
+		return status.StatusCode, nil
+	case err := <-errCh:
+		return 0, fmt.Errorf("failed to wait for container %s: %w", id, err)
+	}
+}
+
+// CopyFrom returns a tar archive of a path in a Docker container. Volumes mounted
+// into the container are included even when it is not running.
+func (e *MobyEngine) CopyFrom(ctx context.Context, id string, path string) (io.ReadCloser, error) {
+	reader, _, err := e.client.CopyFromContainer(ctx, id, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s from container %s: %w", path, id, err)
+	}
+	return reader, nil
+}
+
+// CopyTo extracts a tar archive, optionally gzip-compressed, into a directory of a Docker container
+func (e *MobyEngine) CopyTo(ctx context.Context, id string, path string, content io.Reader) error {
+	if err := e.client.CopyToContainer(ctx, id, path, content, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy archive to %s in container %s: %w", path, id, err)
+	}
+	return nil
+}
+
+// Exec starts an interactive process in a running Docker container with a TTY
+// of the given size and attaches to it
+func (e *MobyEngine) Exec(ctx context.Context, id string, command []string, size TerminalSize) (*ExecSession, error) {
+	var consoleSize *[2]uint
+	if size.Rows > 0 && size.Cols > 0 {
+		consoleSize = &[2]uint{size.Rows, size.Cols}
+	}
+
+	created, err := e.client.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Tty:          true,
+		ConsoleSize:  consoleSize,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          command,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec in container %s: %w", id, err)
+	}
+
+	attached, err := e.client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{
+		Tty:         true,
+		ConsoleSize: consoleSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec in container %s: %w", id, err)
+	}
+
+	return &ExecSession{ID: created.ID, Stream: &hijackedStream{attached}}, nil
+}
+
+// ResizeExec resizes the TTY of an exec session
+func (e *MobyEngine) ResizeExec(ctx context.Context, execID string, size TerminalSize) error {
+	if err := e.client.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: size.Rows, Width: size.Cols}); err != nil {
+		return fmt.Errorf("failed to resize exec %s: %w", execID, err)
+	}
+	return nil
+}
+
+// ExecExitCode returns the exit code of a finished exec session
+func (e *MobyEngine) ExecExitCode(ctx context.Context, execID string) (int, error) {
+	inspect, err := e.client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec %s: %w", execID, err)
+	}
+	if inspect.Running {
+		return 0, fmt.Errorf("exec %s is still running", execID)
+	}
+	return inspect.ExitCode, nil
+}
+
+// hijackedStream adapts the hijacked connection of an exec attach to io.ReadWriteCloser.
+// With a TTY the output is raw, not multiplexed.
+type hijackedStream struct {
+	response types.HijackedResponse
+}
+
+func (s *hijackedStream) Read(p []byte) (int, error) {
+	return s.response.Reader.Read(p)
+}
+
+func (s *hijackedStream) Write(p []byte) (int, error) {
+	return s.response.Conn.Write(p)
+}
+
+func (s *hijackedStream) Close() error {
+	s.response.Close()
+	return nil
+}
+
 // EnsureNetwork ensures a Docker network exists, creating it if necessary
 func (e *MobyEngine) EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error {
 	// Check if network already exists
@@ -327,6 +585,90 @@ func (e *MobyEngine) DisconnectNetwork(ctx context.Context, networkName, contain
 	return nil
 }
 
+// ListVolumes returns all named volumes that carry every given label
+func (e *MobyEngine) ListVolumes(ctx context.Context, labels map[string]string) ([]Volume, error) {
+	labelFilter := filters.NewArgs()
+	for key, value := range labels {
+		labelFilter.Add("label", key+"="+value)
+	}
+
+	resp, err := e.client.VolumeList(ctx, volume.ListOptions{Filters: labelFilter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	volumes := make([]Volume, 0, len(resp.Volumes))
+	for _, vol := range resp.Volumes {
+		if vol != nil {
+			volumes = append(volumes, volumeFromDocker(*vol))
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+
+	return volumes, nil
+}
+
+// CreateVolume creates a named volume with the local driver
+func (e *MobyEngine) CreateVolume(ctx context.Context, name string, labels map[string]string) (Volume, error) {
+	vol, err := e.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Driver: "local",
+		Labels: labels,
+	})
+	if err != nil {
+		return Volume{}, fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+
+	return volumeFromDocker(vol), nil
+}
+
+// RemoveVolume removes a named volume. Docker refuses to remove volumes that are in use.
+func (e *MobyEngine) RemoveVolume(ctx context.Context, name string) error {
+	if err := e.client.VolumeRemove(ctx, name, false); err != nil {
+		return fmt.Errorf("failed to remove volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// VolumeUsage reports how much disk space a named volume uses and how many containers use it
+func (e *MobyEngine) VolumeUsage(ctx context.Context, name string) (VolumeUsage, error) {
+	// Only the system df endpoint computes volume sizes
+	usage, err := e.client.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.VolumeObject},
+	})
+	if err != nil {
+		return VolumeUsage{}, fmt.Errorf("failed to get disk usage for volume %s: %w", name, err)
+	}
+
+	for _, vol := range usage.Volumes {
+		if vol == nil || vol.Name != name {
+			continue
+		}
+		result := VolumeUsage{Name: name, Size: -1, RefCount: -1}
+		if vol.UsageData != nil {
+			result.Size = vol.UsageData.Size
+			result.RefCount = vol.UsageData.RefCount
+		}
+		return result, nil
+	}
+
+	return VolumeUsage{}, fmt.Errorf("volume not found: %s", name)
+}
+
+// volumeFromDocker converts a Docker volume into a Volume
+func volumeFromDocker(vol volume.Volume) Volume {
+	result := Volume{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Mountpoint: vol.Mountpoint,
+		Labels:     vol.Labels,
+	}
+	if createdAt, err := time.Parse(time.RFC3339, vol.CreatedAt); err == nil {
+		result.CreatedAt = &createdAt
+	}
+	return result
+}
+
 // Close closes the Docker client
 func (e *MobyEngine) Close() error {
 	return e.client.Close()
@@ -0,0 +1,148 @@
+package dockerx
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+func TestStatsFromResponse(t *testing.T) {
+	sample := container.StatsResponse{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 300},
+			SystemUsage: 2000,
+			OnlineCPUs:  2,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 100},
+			SystemUsage: 1000,
+		},
+		MemoryStats: container.MemoryStats{
+			Usage: 80 * 1024 * 1024,
+			Limit: 128 * 1024 * 1024,
+			Stats: map[string]uint64{"inactive_file": 16 * 1024 * 1024},
+		},
+		PidsStats: container.PidsStats{Current: 12, Limit: 100},
+		Networks: map[string]container.NetworkStats{
+			"eth0": {RxBytes: 100, TxBytes: 50},
+			"eth1": {RxBytes: 20, TxBytes: 5},
+		},
+		BlkioStats: container.BlkioStats{
+			IoServiceBytesRecursive: []container.BlkioStatEntry{
+				{Op: "Read", Value: 4096},
+				{Op: "Write", Value: 1024},
+				{Op: "Total", Value: 5120},
+			},
+		},
+	}
+
+	stats := statsFromResponse(sample)
+
+	if stats.CPUPercent != 40 {
+		t.Errorf("Expected CPU percent 40, got %v", stats.CPUPercent)
+	}
+	if stats.MemoryUsage != 64*1024*1024 {
+		t.Errorf("Expected memory usage without page cache, got %d", stats.MemoryUsage)
+	}
+	if stats.MemoryPercent != 50 {
+		t.Errorf("Expected memory percent 50, got %v", stats.MemoryPercent)
+	}
+	if stats.NetworkRx != 120 || stats.NetworkTx != 55 {
+		t.Errorf("Expected network totals 120/55, got %d/%d", stats.NetworkRx, stats.NetworkTx)
+	}
+	if stats.BlockRead != 4096 || stats.BlockWrite != 1024 {
+		t.Errorf("Expected block IO 4096/1024, got %d/%d", stats.BlockRead, stats.BlockWrite)
+	}
+	if stats.PidsCurrent != 12 || stats.PidsLimit != 100 {
+		t.Errorf("Expected pids 12/100, got %d/%d", stats.PidsCurrent, stats.PidsLimit)
+	}
+}
+
+func TestStatsFromResponse_FirstSampleHasNoCPU(t *testing.T) {
+	// The first sample has no previous reading to compare against
+	sample := container.StatsResponse{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 300},
+			SystemUsage: 2000,
+			OnlineCPUs:  2,
+		},
+	}
+
+	if stats := statsFromResponse(sample); stats.CPUPercent != 0 {
+		t.Errorf("Expected CPU percent 0, got %v", stats.CPUPercent)
+	}
+}
+
+func TestContainerResources(t *testing.T) {
+	resources := containerResources(store.ResourceLimits{
+		CPUShares:         512,
+		CPUs:              1.5,
+		MemoryLimit:       256 * 1024 * 1024,
+		MemoryReservation: 128 * 1024 * 1024,
+		PidsLimit:         200,
+	})
+
+	if resources.CPUShares != 512 || resources.NanoCPUs != 1500000000 {
+		t.Errorf("Unexpected CPU settings: shares=%d nanocpus=%d", resources.CPUShares, resources.NanoCPUs)
+	}
+	if resources.Memory != 256*1024*1024 || resources.MemoryReservation != 128*1024*1024 {
+		t.Errorf("Unexpected memory settings: limit=%d reservation=%d", resources.Memory, resources.MemoryReservation)
+	}
+	if resources.PidsLimit == nil || *resources.PidsLimit != 200 {
+		t.Errorf("Expected pids limit 200, got %v", resources.PidsLimit)
+	}
+
+	if unlimited := containerResources(store.ResourceLimits{}); unlimited.PidsLimit != nil || unlimited.Memory != 0 {
+		t.Errorf("Expected zero limits to stay unlimited, got %+v", unlimited)
+	}
+}
+
+func TestContainerRestartPolicy(t *testing.T) {
+	if policy := containerRestartPolicy(store.RestartPolicy{}); policy.Name != container.RestartPolicyDisabled {
+		t.Errorf("Expected empty policy to disable restarts, got %q", policy.Name)
+	}
+
+	policy := containerRestartPolicy(store.RestartPolicy{Name: store.RestartPolicyOnFailure, MaxRetries: 5})
+	if policy.Name != container.RestartPolicyOnFailure || policy.MaximumRetryCount != 5 {
+		t.Errorf("Unexpected restart policy: %+v", policy)
+	}
+}
+
+func TestContainerMounts(t *testing.T) {
+	labels := map[string]string{
+		"glinr.project_id":    "3",
+		"glinr.service_id":    "7",
+		"glinr.deployment_id": "12",
+		"glinr.managed":       "true",
+	}
+	mounts := containerMounts([]store.VolumeMap{
+		{Host: "pgdata", Container: "/var/lib/postgresql/data"},
+		{Host: "/srv/config", Container: "/config", ReadOnly: true},
+	}, labels)
+
+	if len(mounts) != 2 {
+		t.Fatalf("Expected 2 mounts, got %d", len(mounts))
+	}
+
+	named := mounts[0]
+	if named.Type != mount.TypeVolume || named.Source != "pgdata" || named.Target != "/var/lib/postgresql/data" || named.ReadOnly {
+		t.Errorf("Unexpected named volume mount: %+v", named)
+	}
+	if named.VolumeOptions == nil || named.VolumeOptions.Labels["glinr.project_id"] != "3" || named.VolumeOptions.Labels["glinr.managed"] != "true" {
+		t.Errorf("Expected named volume to carry project labels, got %+v", named.VolumeOptions)
+	}
+	if _, ok := named.VolumeOptions.Labels["glinr.deployment_id"]; ok {
+		t.Errorf("Expected deployment label to stay off the volume")
+	}
+
+	bind := mounts[1]
+	if bind.Type != mount.TypeBind || bind.Source != "/srv/config" || !bind.ReadOnly || bind.VolumeOptions != nil {
+		t.Errorf("Unexpected bind mount: %+v", bind)
+	}
+
+	if none := containerMounts(nil, labels); none != nil {
+		t.Errorf("Expected no mounts, got %+v", none)
+	}
+}
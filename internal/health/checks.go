@@ -0,0 +1,394 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/GLINCKER/glinrdock/internal/util"
+	"github.com/rs/zerolog/log"
+)
+
+// maxHealthCheckBody bounds how much of an http response body_match looks at
+const maxHealthCheckBody = 64 * 1024
+
+// gRPC health checking protocol serving statuses
+var grpcServingStatuses = map[uint64]string{
+	0: "UNKNOWN",
+	1: "SERVING",
+	2: "NOT_SERVING",
+	3: "SERVICE_UNKNOWN",
+}
+
+// healthCheck returns the health check a service configured, or nil when it
+// has none or the prober does not use configured checks
+func (p *Prober) healthCheck(ctx context.Context, serviceID int64) *store.HealthCheck {
+	if p.checks == nil {
+		return nil
+	}
+	check, err := p.checks.GetHealthCheck(ctx, serviceID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to get health check")
+		}
+		return nil
+	}
+	return check
+}
+
+// probeCheck runs a configured health check within its timeout
+func (p *Prober) probeCheck(ctx context.Context, service *store.Service, check *store.HealthCheck) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout())
+	defer cancel()
+
+	switch check.Type {
+	case store.HealthCheckHTTP:
+		return p.probeHTTPCheck(ctx, service, check)
+	case store.HealthCheckTCP:
+		return p.probeTCPCheck(ctx, service, check)
+	case store.HealthCheckExec:
+		return p.probeExecCheck(ctx, service, check)
+	case store.HealthCheckGRPC:
+		return p.probeGRPCCheck(ctx, service, check)
+	default:
+		return ProbeResult{Status: store.HealthStatusUnknown, Error: fmt.Errorf("unsupported health check type %s", check.Type)}
+	}
+}
+
+// checkAddress returns the published host address a health check probes: its
+// port, else the first published container port, else DefaultHealthPort
+func (p *Prober) checkAddress(ctx context.Context, service *store.Service, check *store.HealthCheck) (string, error) {
+	port := check.Port
+	if port == 0 {
+		port = containerPort(service, store.DefaultHealthPort)
+	}
+	return p.probeAddress(ctx, service, port)
+}
+
+// probeHTTPCheck expects one of the check's status codes and, when set, a body
+// matching body_match. Redirects are not followed.
+func (p *Prober) probeHTTPCheck(ctx context.Context, service *store.Service, check *store.HealthCheck) ProbeResult {
+	address, err := p.checkAddress(ctx, service, check)
+	if err != nil {
+		return addressResult(err)
+	}
+
+	path := check.Path
+	if path == "" {
+		path = "/health"
+		if service.HealthPath != nil && *service.HealthPath != "" {
+			path = *service.HealthPath
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+path, nil)
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("failed to create health request: %w", err), Address: address}
+	}
+	req.Header.Set("User-Agent", "GLINR-HealthCheck/1.0")
+
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: p.dial, DisableKeepAlives: true},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("health check failed: %w", err), Address: address}
+	}
+	defer resp.Body.Close()
+
+	if !check.ExpectsStatus(resp.StatusCode) {
+		return ProbeResult{
+			Status:  store.HealthStatusFail,
+			Error:   fmt.Errorf("health check returned status %d, expected %s", resp.StatusCode, check.ExpectedStatus),
+			Address: address,
+		}
+	}
+
+	if check.BodyMatch != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+		if err != nil {
+			return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("failed to read health response: %w", err), Address: address}
+		}
+		pattern, err := regexp.Compile(check.BodyMatch)
+		if err != nil {
+			return ProbeResult{Status: store.HealthStatusUnknown, Error: fmt.Errorf("invalid body_match: %w", err), Address: address}
+		}
+		if !pattern.Match(body) {
+			return ProbeResult{
+				Status:  store.HealthStatusFail,
+				Error:   fmt.Errorf("health response body does not match %q", check.BodyMatch),
+				Address: address,
+			}
+		}
+	}
+
+	return ProbeResult{Status: store.HealthStatusOK, Address: address}
+}
+
+// probeTCPCheck expects the port to accept connections
+func (p *Prober) probeTCPCheck(ctx context.Context, service *store.Service, check *store.HealthCheck) ProbeResult {
+	address, err := p.checkAddress(ctx, service, check)
+	if err != nil {
+		return addressResult(err)
+	}
+
+	conn, err := p.dial(ctx, "tcp", address)
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("TCP connection failed: %w", err), Address: address}
+	}
+	conn.Close()
+
+	return ProbeResult{Status: store.HealthStatusOK, Address: address}
+}
+
+// probeExecCheck runs the check's command in the service's container and
+// expects it to exit 0
+func (p *Prober) probeExecCheck(ctx context.Context, service *store.Service, check *store.HealthCheck) ProbeResult {
+	if p.engine == nil {
+		return ProbeResult{Status: store.HealthStatusUnknown, Error: errors.New("exec health checks are not available")}
+	}
+	if service.ContainerID == nil || *service.ContainerID == "" {
+		return ProbeResult{Status: store.HealthStatusFail, Error: errors.New("service has no container")}
+	}
+
+	session, err := p.engine.Exec(ctx, *service.ContainerID, check.Command, dockerx.TerminalSize{})
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("failed to run health command: %w", err)}
+	}
+	defer session.Stream.Close()
+
+	output := util.NewTailBuffer(store.MaxHealthCheckOutput)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(output, session.Stream)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("health command timed out after %s", check.Timeout())}
+	}
+
+	exitCode, err := p.execExitCode(ctx, session.ID)
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusUnknown, Error: err, Output: output.String()}
+	}
+	if exitCode != 0 {
+		return ProbeResult{
+			Status: store.HealthStatusFail,
+			Error:  fmt.Errorf("health command exited with code %d: %s", exitCode, strings.TrimSpace(output.String())),
+			Output: output.String(),
+		}
+	}
+
+	return ProbeResult{Status: store.HealthStatusOK, Output: output.String()}
+}
+
+// execExitCode waits for Docker to report the exit code of a finished exec,
+// which can lag behind the end of its output
+func (p *Prober) execExitCode(ctx context.Context, execID string) (int, error) {
+	for {
+		exitCode, err := p.engine.ExecExitCode(ctx, execID)
+		if err == nil {
+			return exitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("failed to get health command exit code: %w", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// probeGRPCCheck calls grpc.health.v1.Health/Check over cleartext HTTP/2 and
+// expects SERVING
+func (p *Prober) probeGRPCCheck(ctx context.Context, service *store.Service, check *store.HealthCheck) ProbeResult {
+	address, err := p.checkAddress(ctx, service, check)
+	if err != nil {
+		return addressResult(err)
+	}
+	fail := func(err error) ProbeResult {
+		result := ProbeResult{Status: store.HealthStatusFail, Error: fmt.Errorf("gRPC health check failed: %w", err), Address: address}
+		var protocolErr *ProtocolError
+		if errors.As(err, &protocolErr) {
+			result.ProtocolError = protocolErr
+		}
+		return result
+	}
+
+	// HealthCheckRequest{service = 1}
+	var message []byte
+	if check.GRPCService != "" {
+		message = append(message, 0x0a)
+		message = binary.AppendUvarint(message, uint64(len(check.GRPCService)))
+		message = append(message, check.GRPCService...)
+	}
+	frame := append([]byte{0}, binary.BigEndian.AppendUint32(nil, uint32(len(message)))...)
+	frame = append(frame, message...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+address+"/grpc.health.v1.Health/Check", bytes.NewReader(frame))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("User-Agent", "GLINR-HealthCheck/1.0")
+
+	transport := &http.Transport{DialContext: p.dial, Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	defer transport.CloseIdleConnections()
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+	if err != nil {
+		return fail(fmt.Errorf("failed to read response: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail(fmt.Errorf("server returned HTTP status %d", resp.StatusCode))
+	}
+
+	// A call that fails before any message has its status in the headers
+	status := resp.Trailer.Get("Grpc-Status")
+	statusMessage := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		statusMessage = resp.Header.Get("Grpc-Message")
+	}
+	if status != "" && status != "0" {
+		if decoded, err := url.PathUnescape(statusMessage); err == nil {
+			statusMessage = decoded
+		}
+		return fail(&ProtocolError{Protocol: "grpc", Code: status, Message: statusMessage})
+	}
+
+	if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		return fail(errors.New("malformed health check response"))
+	}
+	servingStatus, err := grpcServingStatus(body[5:])
+	if err != nil {
+		return fail(err)
+	}
+	if servingStatus != 1 {
+		return fail(fmt.Errorf("service is %s", grpcServingStatuses[servingStatus]))
+	}
+
+	return ProbeResult{Status: store.HealthStatusOK, Address: address}
+}
+
+// grpcServingStatus reads the status field of a HealthCheckResponse
+func grpcServingStatus(message []byte) (uint64, error) {
+	var status uint64
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, errors.New("malformed health check response")
+		}
+		message = message[n:]
+
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, errors.New("malformed health check response")
+			}
+			message = message[n:]
+			if key>>3 == 1 {
+				status = value
+			}
+		case 1:
+			if len(message) < 8 {
+				return 0, errors.New("malformed health check response")
+			}
+			message = message[8:]
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return 0, errors.New("malformed health check response")
+			}
+			message = message[n+int(length):]
+		case 5:
+			if len(message) < 4 {
+				return 0, errors.New("malformed health check response")
+			}
+			message = message[4:]
+		default:
+			return 0, errors.New("malformed health check response")
+		}
+	}
+	return status, nil
+}
+
+// inStartPeriod reports whether the service's container started within the
+// check's start period
+func (p *Prober) inStartPeriod(ctx context.Context, service *store.Service, check *store.HealthCheck) bool {
+	if check.StartPeriodSeconds == 0 || p.engine == nil || service.ContainerID == nil || *service.ContainerID == "" {
+		return false
+	}
+	status, err := p.engine.Inspect(ctx, *service.ContainerID)
+	if err != nil || status.StartedAt == nil {
+		return false
+	}
+	return time.Since(*status.StartedAt) < check.StartPeriod()
+}
+
+// thresholdStatus returns the health status of a service after a probe: it
+// changes once the same result was seen for the check's threshold in a row,
+// not counting failures during a start period
+func (p *Prober) thresholdStatus(ctx context.Context, service *store.Service, check *store.HealthCheck, result string) string {
+	threshold := check.HealthyThreshold
+	if result == store.HealthStatusFail {
+		threshold = check.UnhealthyThreshold
+	}
+
+	streak := 1
+	if threshold > 1 && p.checks != nil {
+		history, err := p.checks.ListHealthHistory(ctx, service.ID, store.HealthHistoryRetention)
+		if err != nil {
+			log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to list health history")
+		}
+		for _, record := range history {
+			if streak >= threshold {
+				break
+			}
+			if record.InStartPeriod {
+				continue
+			}
+			if record.Result != result {
+				break
+			}
+			streak++
+		}
+	}
+
+	if streak >= threshold {
+		return result
+	}
+	return currentHealth(service)
+}
+
+// currentHealth returns the health status a service has now
+func currentHealth(service *store.Service) string {
+	if service.HealthStatus == "" {
+		return store.HealthStatusUnknown
+	}
+	return service.HealthStatus
+}
@@ -0,0 +1,309 @@
+package health
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// checkStore keeps health checks, health history and the last status set
+type checkStore struct {
+	MockServiceStore
+	check   *store.HealthCheck
+	history []store.HealthCheckRecord
+	status  string
+}
+
+func (s *checkStore) UpdateServiceHealth(ctx context.Context, serviceID int64, healthStatus string) error {
+	s.status = healthStatus
+	for i := range s.services {
+		if s.services[i].ID == serviceID {
+			s.services[i].HealthStatus = healthStatus
+		}
+	}
+	return nil
+}
+
+func (s *checkStore) GetHealthCheck(ctx context.Context, serviceID int64) (*store.HealthCheck, error) {
+	if s.check == nil {
+		return nil, store.ErrNotFound
+	}
+	return s.check, nil
+}
+
+func (s *checkStore) RecordHealthCheck(ctx context.Context, record *store.HealthCheckRecord) error {
+	s.history = append([]store.HealthCheckRecord{*record}, s.history...)
+	return nil
+}
+
+func (s *checkStore) ListHealthHistory(ctx context.Context, serviceID int64, limit int) ([]store.HealthCheckRecord, error) {
+	if len(s.history) > limit {
+		return s.history[:limit], nil
+	}
+	return s.history, nil
+}
+
+// checkEngine runs exec health checks and reports when the container started
+// and the ports it published
+type checkEngine struct {
+	startedAt time.Time
+	ports     []store.PortMap
+	output    string
+	exitCode  int
+	commands  [][]string
+}
+
+func (e *checkEngine) Inspect(ctx context.Context, containerID string) (dockerx.ContainerStatus, error) {
+	return dockerx.ContainerStatus{ID: containerID, State: "running", StartedAt: &e.startedAt, Ports: e.ports}, nil
+}
+
+func (e *checkEngine) Exec(ctx context.Context, id string, command []string, size dockerx.TerminalSize) (*dockerx.ExecSession, error) {
+	e.commands = append(e.commands, command)
+	return &dockerx.ExecSession{ID: "exec-1", Stream: nopStream{strings.NewReader(e.output)}}, nil
+}
+
+func (e *checkEngine) ExecExitCode(ctx context.Context, execID string) (int, error) {
+	return e.exitCode, nil
+}
+
+type nopStream struct{ io.Reader }
+
+func (nopStream) Write(p []byte) (int, error) { return len(p), nil }
+func (nopStream) Close() error                { return nil }
+
+// newCheckProber returns a prober for a service with a configured health check
+// whose connections all go to target
+func newCheckProber(check store.HealthCheck, target string) (*Prober, *checkStore) {
+	containerID := "container-1"
+	checks := &checkStore{
+		MockServiceStore: MockServiceStore{
+			services: []store.Service{{
+				ID:           1,
+				ProjectID:    7,
+				Name:         "api",
+				Image:        "example/api:1",
+				ContainerID:  &containerID,
+				DesiredState: store.ServiceStateRunning,
+				HealthStatus: store.HealthStatusOK,
+				Ports:        []store.PortMap{{Container: 8080, Host: 18080}},
+			}},
+			projects: []store.Project{{ID: 7, Name: "Shop"}},
+		},
+	}
+	// Stored checks have their defaults filled in
+	check.IntervalSeconds, check.TimeoutSeconds = store.DefaultHealthCheckInterval, 2
+	if check.HealthyThreshold == 0 {
+		check.HealthyThreshold = 1
+	}
+	if check.UnhealthyThreshold == 0 {
+		check.UnhealthyThreshold = store.DefaultUnhealthyThreshold
+	}
+	if err := check.Validate(); err != nil {
+		panic(err)
+	}
+	checks.check = &check
+
+	prober := NewProber(checks)
+	prober.SetHealthCheckStore(checks)
+	prober.SetEngine(&checkEngine{ports: []store.PortMap{{Container: 8080, Host: 18080}}})
+	prober.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, target)
+	}
+	return prober, checks
+}
+
+func TestProbeCheck_HTTPExpectedStatusAndBody(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.Host + r.URL.Path
+		if r.URL.Path == "/ready" {
+			w.Write([]byte(`{"status":"ready"}`))
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer server.Close()
+	target := strings.TrimPrefix(server.URL, "http://")
+
+	prober, _ := newCheckProber(store.HealthCheck{Type: store.HealthCheckHTTP, Path: "/ready", ExpectedStatus: "200", BodyMatch: `"status":"ready"`}, target)
+	service, _ := prober.store.GetService(context.Background(), 1)
+	result := prober.ProbeService(context.Background(), &service, nil)
+	if result.Status != store.HealthStatusOK {
+		t.Fatalf("expected ok, got %s: %v", result.Status, result.Error)
+	}
+	if requested != "127.0.0.1:18080/ready" {
+		t.Errorf("expected a probe on the published host port, got %q", requested)
+	}
+
+	prober, _ = newCheckProber(store.HealthCheck{Type: store.HealthCheckHTTP, Path: "/ready", BodyMatch: `"status":"live"`}, target)
+	result = prober.ProbeService(context.Background(), &service, nil)
+	if result.Status != store.HealthStatusFail || !strings.Contains(result.Error.Error(), "does not match") {
+		t.Errorf("expected a body mismatch to fail, got %s: %v", result.Status, result.Error)
+	}
+
+	// Redirects are not followed and 302 is not expected
+	prober, _ = newCheckProber(store.HealthCheck{Type: store.HealthCheckHTTP, Path: "/", ExpectedStatus: "200-299"}, target)
+	result = prober.ProbeService(context.Background(), &service, nil)
+	if result.Status != store.HealthStatusFail || !strings.Contains(result.Error.Error(), "status 302") {
+		t.Errorf("expected an unexpected status to fail, got %s: %v", result.Status, result.Error)
+	}
+}
+
+func TestProbeAndUpdate_UnhealthyThreshold(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := listener.Addr().String()
+	listener.Close() // nothing listens, so TCP checks fail
+
+	prober, checks := newCheckProber(store.HealthCheck{Type: store.HealthCheckTCP, UnhealthyThreshold: 3}, target)
+	ctx := context.Background()
+
+	for i := 1; i <= 2; i++ {
+		if err := prober.ProbeAndUpdate(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		if checks.status != store.HealthStatusOK {
+			t.Fatalf("expected the service to stay healthy after %d failure(s), got %s", i, checks.status)
+		}
+	}
+	if err := prober.ProbeAndUpdate(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if checks.status != store.HealthStatusFail {
+		t.Fatalf("expected the service to be unhealthy after 3 failures, got %s", checks.status)
+	}
+
+	if len(checks.history) != 3 {
+		t.Fatalf("expected 3 history entries, got %d", len(checks.history))
+	}
+	latest := checks.history[0]
+	if latest.Result != store.HealthStatusFail || latest.Status != store.HealthStatusFail || latest.CheckType != "tcp" || latest.Output == "" {
+		t.Errorf("unexpected history entry %+v", latest)
+	}
+	if checks.history[2].Status != store.HealthStatusOK {
+		t.Errorf("expected the first failure to keep the service healthy, got %+v", checks.history[2])
+	}
+}
+
+func TestProbeAndUpdate_StartPeriodIgnoresFailures(t *testing.T) {
+	engine := &checkEngine{startedAt: time.Now().Add(-10 * time.Second), output: "not ready\n", exitCode: 1}
+	prober, checks := newCheckProber(store.HealthCheck{
+		Type:               store.HealthCheckExec,
+		Command:            []string{"pg_isready"},
+		UnhealthyThreshold: 1,
+		StartPeriodSeconds: 60,
+	}, "")
+	prober.SetEngine(engine)
+
+	if err := prober.ProbeAndUpdate(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if checks.status != store.HealthStatusOK {
+		t.Fatalf("expected a failure during the start period not to count, got %s", checks.status)
+	}
+	if !checks.history[0].InStartPeriod || !strings.Contains(checks.history[0].Output, "not ready") {
+		t.Errorf("expected the failure recorded in the start period with its output, got %+v", checks.history[0])
+	}
+	if len(engine.commands) != 1 || engine.commands[0][0] != "pg_isready" {
+		t.Errorf("expected the health command to run in the container, got %v", engine.commands)
+	}
+
+	// Once the start period is over failures count
+	engine.startedAt = time.Now().Add(-2 * time.Minute)
+	if err := prober.ProbeAndUpdate(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if checks.status != store.HealthStatusFail {
+		t.Fatalf("expected the service to be unhealthy after its start period, got %s", checks.status)
+	}
+}
+
+func TestProbeCheck_GRPC(t *testing.T) {
+	serving := map[string]byte{"": 1, "billing": 2}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/grpc.health.v1.Health/Check" || r.ProtoMajor != 2 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		frame, _ := io.ReadAll(r.Body)
+		name := ""
+		if len(frame) > 7 {
+			name = string(frame[7:])
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		status, ok := serving[name]
+		if !ok {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown%20service")
+			return
+		}
+		w.Write(append(binary.BigEndian.AppendUint32([]byte{0}, 2), 0x08, status))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+	target := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		service string
+		status  string
+		err     string
+	}{
+		{"", store.HealthStatusOK, ""},
+		{"billing", store.HealthStatusFail, "service is NOT_SERVING"},
+		{"missing", store.HealthStatusFail, "grpc error 5: unknown service"},
+	}
+	for _, tt := range tests {
+		prober, checks := newCheckProber(store.HealthCheck{Type: store.HealthCheckGRPC, GRPCService: tt.service}, target)
+		service := checks.services[0]
+		result := prober.ProbeService(context.Background(), &service, nil)
+		if result.Status != tt.status {
+			t.Errorf("%q: expected %s, got %s: %v", tt.service, tt.status, result.Status, result.Error)
+		}
+		if tt.err != "" && (result.Error == nil || !strings.Contains(result.Error.Error(), tt.err)) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.service, tt.err, result.Error)
+		}
+	}
+}
+
+func TestMonitor_ProbesDueServicesOnce(t *testing.T) {
+	monitor := NewMonitor(&checkStore{}, NewProber(&MockServiceStore{}), time.Minute)
+	now := time.Now()
+
+	if !monitor.claim(1, time.Minute, now) {
+		t.Fatal("expected a service never probed to be due")
+	}
+	if monitor.claim(1, time.Minute, now.Add(2*time.Minute)) {
+		t.Error("expected a service still being probed not to be claimed again")
+	}
+	monitor.release(1)
+	if monitor.claim(1, time.Minute, now.Add(30*time.Second)) {
+		t.Error("expected a service probed within its interval not to be due")
+	}
+	if !monitor.claim(1, time.Minute, now.Add(time.Minute)) {
+		t.Error("expected a service to be due once its interval passed")
+	}
+}
+
+func (s *checkStore) ListProjects(ctx context.Context) ([]store.Project, error) {
+	return s.projects, nil
+}
+
+func (s *checkStore) ListServices(ctx context.Context, projectID int64) ([]store.Service, error) {
+	return s.services, nil
+}
@@ -14,6 +14,7 @@ import (
 type MockServiceStore struct {
 	services []store.Service
 	routes   []store.Route
+	projects []store.Project
 }
 
 func (m *MockServiceStore) GetService(ctx context.Context, id int64) (store.Service, error) {
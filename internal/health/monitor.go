@@ -9,9 +9,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// Monitor handles periodic health checking for all services
+// monitorTick is how often the monitor looks for services due a probe
+const monitorTick = store.MinHealthCheckInterval * time.Second
+
+// Monitor handles periodic health checking for all services. Services with a
+// health check are probed at its interval, others at the monitor's interval.
 type Monitor struct {
-	store    ServiceStore
+	store    MonitorableServiceStore
 	prober   *Prober
 	interval time.Duration
 	ctx      context.Context
@@ -19,18 +23,26 @@ type Monitor struct {
 	wg       sync.WaitGroup
 	running  bool
 	mu       sync.RWMutex
+
+	// lastProbe and probing track when each service was last probed and which
+	// probes are still running, so slow probes do not pile up
+	stateMu   sync.Mutex
+	lastProbe map[int64]time.Time
+	probing   map[int64]bool
 }
 
-// NewMonitor creates a new health monitor
-func NewMonitor(store ServiceStore, interval time.Duration) *Monitor {
+// NewMonitor creates a new health monitor probing with prober
+func NewMonitor(store MonitorableServiceStore, prober *Prober, interval time.Duration) *Monitor {
 	if interval < 30*time.Second {
 		interval = 30 * time.Second // Minimum 30 seconds between checks
 	}
 
 	return &Monitor{
-		store:    store,
-		prober:   NewProber(store),
-		interval: interval,
+		store:     store,
+		prober:    prober,
+		interval:  interval,
+		lastProbe: make(map[int64]time.Time),
+		probing:   make(map[int64]bool),
 	}
 }
 
@@ -76,97 +88,95 @@ func (m *Monitor) Stop() {
 func (m *Monitor) monitorLoop() {
 	defer m.wg.Done()
 
-	ticker := time.NewTicker(m.interval)
+	ticker := time.NewTicker(monitorTick)
 	defer ticker.Stop()
 
-	// Run initial health check after a short delay to allow services to start
-	initialDelay := time.NewTimer(10 * time.Second)
-	defer initialDelay.Stop()
-
 	for {
 		select {
 		case <-m.ctx.Done():
 			return
 
-		case <-initialDelay.C:
-			m.probeAllServices()
-			initialDelay.Stop() // Disable the initial timer after first run
-
 		case <-ticker.C:
-			m.probeAllServices()
+			m.probeDueServices(time.Now())
 		}
 	}
 }
 
-// probeAllServices checks the health of all services that should be monitored
-func (m *Monitor) probeAllServices() {
-	log.Debug().Msg("starting health check cycle")
-
-	// For now, we don't have a ListAllServices method, so we'll need to implement one
-	// or modify this to work with the existing API structure
-	// This is a simplified implementation that would need to be extended
-
-	services := m.getMonitorableServices()
-	if len(services) == 0 {
-		log.Debug().Msg("no services to monitor")
+// probeDueServices starts a probe of every monitored service whose interval has
+// passed since its last probe
+func (m *Monitor) probeDueServices(now time.Time) {
+	services, err := m.getMonitorableServices(m.ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to list services for health monitoring")
 		return
 	}
 
-	probeCount := 0
-	successCount := 0
-	failCount := 0
-	unknownCount := 0
-
-	for _, serviceID := range services {
-		select {
-		case <-m.ctx.Done():
-			return
-		default:
+	for _, service := range services {
+		interval := m.interval
+		if check := m.prober.healthCheck(m.ctx, service.ID); check != nil {
+			interval = check.Interval()
 		}
+		if !m.claim(service.ID, interval, now) {
+			continue
+		}
+
+		m.wg.Add(1)
+		go func(serviceID int64) {
+			defer m.wg.Done()
+			defer m.release(serviceID)
 
-		if err := m.prober.ProbeAndUpdate(m.ctx, serviceID); err != nil {
-			log.Debug().Err(err).Int64("service_id", serviceID).Msg("health probe failed")
-			failCount++
-		} else {
-			// Get the service to check the updated health status
-			if service, err := m.store.GetService(m.ctx, serviceID); err == nil {
-				switch service.HealthStatus {
-				case store.HealthStatusOK:
-					successCount++
-				case store.HealthStatusFail:
-					failCount++
-				default:
-					unknownCount++
-				}
+			if err := m.prober.ProbeAndUpdate(m.ctx, serviceID); err != nil {
+				log.Debug().Err(err).Int64("service_id", serviceID).Msg("health probe failed")
 			}
-		}
-		probeCount++
+		}(service.ID)
+	}
+}
+
+// claim marks a service as being probed if it is due
+func (m *Monitor) claim(serviceID int64, interval time.Duration, now time.Time) bool {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
 
-		// Small delay between probes to avoid overwhelming the system
-		time.Sleep(100 * time.Millisecond)
+	if m.probing[serviceID] {
+		return false
 	}
+	if last, ok := m.lastProbe[serviceID]; ok && now.Sub(last) < interval {
+		return false
+	}
+	m.probing[serviceID] = true
+	m.lastProbe[serviceID] = now
+	return true
+}
 
-	log.Info().
-		Int("total_probed", probeCount).
-		Int("healthy", successCount).
-		Int("unhealthy", failCount).
-		Int("unknown", unknownCount).
-		Msg("health check cycle completed")
+// release marks the probe of a service finished
+func (m *Monitor) release(serviceID int64) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	delete(m.probing, serviceID)
 }
 
-// getMonitorableServices returns a list of service IDs that should be health monitored
-// This is a placeholder implementation - in a real system, you might want to:
-// 1. Only monitor running services
-// 2. Only monitor services with health check configured
-// 3. Respect service-specific monitoring settings
-func (m *Monitor) getMonitorableServices() []int64 {
-	// This is a simplified implementation
-	// In practice, you would need a method to list all services or projects
-	// and filter for services that should be monitored
-
-	// For now, return an empty slice since we don't have a ListAllServices method
-	// This would need to be implemented based on your specific store interface
-	return []int64{}
+// getMonitorableServices returns the services that should be running: scheduled
+// tasks, stopped and crash looping services are left alone
+func (m *Monitor) getMonitorableServices(ctx context.Context) ([]store.Service, error) {
+	projects, err := m.store.ListProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []store.Service
+	for _, project := range projects {
+		projectServices, err := m.store.ListServices(ctx, project.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range projectServices {
+			if service.IsTask() || service.CrashLooping || service.DesiredState != store.ServiceStateRunning {
+				continue
+			}
+			services = append(services, service)
+		}
+	}
+	return services, nil
 }
 
 // GetStatus returns the current status of the health monitor
@@ -189,7 +199,6 @@ type MonitorStatus struct {
 // MonitorableServiceStore extends ServiceStore with methods needed for monitoring
 type MonitorableServiceStore interface {
 	ServiceStore
-	// ListAllServices would return all services across all projects
-	// This method doesn't exist yet but would be needed for comprehensive monitoring
-	// ListAllServices(ctx context.Context) ([]store.Service, error)
+	ListProjects(ctx context.Context) ([]store.Project, error)
+	ListServices(ctx context.Context, projectID int64) ([]store.Service, error)
 }
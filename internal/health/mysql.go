@@ -0,0 +1,345 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// MySQL capability flags sent by the probe
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientConnectWithDB    = 0x00000008
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+)
+
+const (
+	mysqlComQuit = 0x01
+	mysqlComPing = 0x0e
+
+	mysqlNativePassword = "mysql_native_password"
+	mysqlCachingSHA2    = "caching_sha2_password"
+)
+
+// probeMySQL logs in to MySQL or MariaDB and sends COM_PING
+func (p *Prober) probeMySQL(ctx context.Context, service *store.Service) ProbeResult {
+	return p.probeDatabase(ctx, service, "mysql", 3306, checkMySQL)
+}
+
+// checkMySQL logs in with the credentials of the official MySQL and MariaDB
+// images and pings the server
+func checkMySQL(conn net.Conn, env map[string]string) error {
+	user, password, database := mysqlCredentials(env)
+
+	my := &mysqlConn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := my.handshake(user, password, database); err != nil {
+		return err
+	}
+
+	my.sequence = 0
+	if err := my.writePacket([]byte{mysqlComPing}); err != nil {
+		return err
+	}
+	reply, err := my.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(reply) == 0 || reply[0] != 0x00 {
+		return errors.New("unexpected reply to COM_PING")
+	}
+
+	// Quit; the connection is closed either way
+	my.sequence = 0
+	my.writePacket([]byte{mysqlComQuit})
+	return nil
+}
+
+// mysqlCredentials prefers the application user the image created over root
+func mysqlCredentials(env map[string]string) (user, password, database string) {
+	user = envValue(env, "", "MYSQL_USER", "MARIADB_USER")
+	if user != "" && user != "root" {
+		return user, envValue(env, "", "MYSQL_PASSWORD", "MARIADB_PASSWORD"), envValue(env, "", "MYSQL_DATABASE", "MARIADB_DATABASE")
+	}
+	return "root", envValue(env, "", "MYSQL_ROOT_PASSWORD", "MARIADB_ROOT_PASSWORD"), ""
+}
+
+// mysqlConn speaks the MySQL client/server protocol
+type mysqlConn struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	sequence byte
+}
+
+// readPacket reads a packet, turning an ERR packet into a ProtocolError
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read packet: %w", err)
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	c.sequence = header[3] + 1
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read packet: %w", err)
+	}
+
+	if len(payload) > 0 && payload[0] == 0xff {
+		return nil, mysqlError(payload)
+	}
+	return payload, nil
+}
+
+func (c *mysqlConn) writePacket(payload []byte) error {
+	packet := make([]byte, 4, 4+len(payload))
+	packet[0] = byte(len(payload))
+	packet[1] = byte(len(payload) >> 8)
+	packet[2] = byte(len(payload) >> 16)
+	packet[3] = c.sequence
+	packet = append(packet, payload...)
+	c.sequence++
+
+	if _, err := c.conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to send packet: %w", err)
+	}
+	return nil
+}
+
+// handshake answers the server greeting and completes authentication
+func (c *mysqlConn) handshake(user, password, database string) error {
+	greeting, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	scramble, plugin, err := parseMySQLGreeting(greeting)
+	if err != nil {
+		return err
+	}
+	// The server asks to switch plugins when it wants another one
+	if plugin != mysqlNativePassword && plugin != mysqlCachingSHA2 {
+		plugin = mysqlNativePassword
+	}
+	authResponse, err := mysqlAuthResponse(plugin, password, scramble)
+	if err != nil {
+		return err
+	}
+
+	capabilities := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth)
+	if database != "" {
+		capabilities |= mysqlClientConnectWithDB
+	}
+
+	body := binary.LittleEndian.AppendUint32(nil, capabilities)
+	body = binary.LittleEndian.AppendUint32(body, 1<<24) // max packet size
+	body = append(body, 45)                              // utf8mb4_general_ci
+	body = append(body, make([]byte, 23)...)
+	body = append(body, cstring(user)...)
+	body = append(body, byte(len(authResponse)))
+	body = append(body, authResponse...)
+	if database != "" {
+		body = append(body, cstring(database)...)
+	}
+	body = append(body, cstring(plugin)...)
+
+	if err := c.writePacket(body); err != nil {
+		return err
+	}
+	return c.finishAuth(plugin, password, scramble)
+}
+
+// finishAuth follows plugin switches and caching_sha2_password exchanges until
+// the server accepts or rejects the login
+func (c *mysqlConn) finishAuth(plugin, password string, scramble []byte) error {
+	for {
+		packet, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(packet) == 0 {
+			return errors.New("empty authentication packet")
+		}
+
+		switch packet[0] {
+		case 0x00:
+			return nil
+		case 0xfe:
+			end := bytes.IndexByte(packet[1:], 0)
+			if end < 0 {
+				return errors.New("malformed authentication switch request")
+			}
+			plugin = string(packet[1 : 1+end])
+			scramble = trimNUL(packet[2+end:])
+			response, err := mysqlAuthResponse(plugin, password, scramble)
+			if err != nil {
+				return err
+			}
+			if err := c.writePacket(response); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != mysqlCachingSHA2 || len(packet) < 2 {
+				return errors.New("unexpected authentication data")
+			}
+			switch {
+			case len(packet) == 2 && packet[1] == 3:
+				// Fast authentication succeeded, an OK packet follows
+			case len(packet) == 2 && packet[1] == 4:
+				// Full authentication without TLS needs the server's public key
+				if err := c.writePacket([]byte{2}); err != nil {
+					return err
+				}
+			default:
+				encrypted, err := encryptMySQLPassword(packet[1:], password, scramble)
+				if err != nil {
+					return err
+				}
+				if err := c.writePacket(encrypted); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected authentication packet 0x%02x", packet[0])
+		}
+	}
+}
+
+// parseMySQLGreeting returns the scramble and default plugin of a v10 handshake
+func parseMySQLGreeting(greeting []byte) ([]byte, string, error) {
+	if len(greeting) == 0 || greeting[0] != 10 {
+		return nil, "", errors.New("unsupported handshake protocol")
+	}
+
+	rest := greeting[1:]
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return nil, "", errors.New("malformed handshake")
+	}
+	rest = rest[end+1:]
+
+	// connection id, scramble part 1, filler, capabilities, charset, status,
+	// capabilities, scramble length, reserved
+	if len(rest) < 4+8+1+2+1+2+2+1+10 {
+		return nil, "", errors.New("malformed handshake")
+	}
+	scramble := append([]byte(nil), rest[4:12]...)
+	capabilities := uint32(binary.LittleEndian.Uint16(rest[13:15])) | uint32(binary.LittleEndian.Uint16(rest[18:20]))<<16
+	scrambleLength := int(rest[20])
+	rest = rest[31:]
+
+	if capabilities&mysqlClientSecureConnection != 0 {
+		n := max(13, scrambleLength-8)
+		if len(rest) < n {
+			return nil, "", errors.New("malformed handshake")
+		}
+		scramble = append(scramble, trimNUL(rest[:n])...)
+		rest = rest[n:]
+	}
+
+	plugin := mysqlNativePassword
+	if capabilities&mysqlClientPluginAuth != 0 && len(rest) > 0 {
+		plugin = string(trimNUL(rest))
+	}
+
+	return scramble, plugin, nil
+}
+
+// mysqlAuthResponse scrambles a password for an authentication plugin
+func mysqlAuthResponse(plugin, password string, scramble []byte) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		hash := sha1.New()
+		hash.Write(scramble)
+		hash.Write(stage2[:])
+		return xorBytes(stage1[:], hash.Sum(nil)), nil
+	case mysqlCachingSHA2:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		hash := sha256.New()
+		hash.Write(stage2[:])
+		hash.Write(scramble)
+		return xorBytes(stage1[:], hash.Sum(nil)), nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %s", plugin)
+	}
+}
+
+// encryptMySQLPassword encrypts a password with the server's RSA key for
+// caching_sha2_password full authentication
+func encryptMySQLPassword(publicKey []byte, password string, scramble []byte) ([]byte, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, errors.New("invalid server public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("server public key is not an RSA key")
+	}
+
+	plaintext := cstring(password)
+	for i := range plaintext {
+		plaintext[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plaintext, nil)
+}
+
+// mysqlError reads the code and message of an ERR packet
+func mysqlError(payload []byte) *ProtocolError {
+	protocolErr := &ProtocolError{Protocol: "mysql", Message: "server returned an error"}
+	if len(payload) < 3 {
+		return protocolErr
+	}
+
+	protocolErr.Code = strconv.Itoa(int(binary.LittleEndian.Uint16(payload[1:3])))
+	message := payload[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		message = message[6:]
+	}
+	if len(message) > 0 {
+		protocolErr.Message = string(message)
+	}
+	return protocolErr
+}
+
+func xorBytes(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// trimNUL drops the NUL terminator of a string field
+func trimNUL(b []byte) []byte {
+	if end := bytes.IndexByte(b, 0); end >= 0 {
+		return b[:end]
+	}
+	return b
+}
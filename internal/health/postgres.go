@@ -0,0 +1,341 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// postgresProtocolVersion is protocol 3.0 as sent in the startup message
+	postgresProtocolVersion = 3 << 16
+	// postgresMaxMessage bounds the messages read while probing
+	postgresMaxMessage = 1 << 20
+	// scramMaxIterations bounds the work factor the probed server asks for, so
+	// a service cannot make every probe stall glinrdockd. PostgreSQL uses 4096.
+	scramMaxIterations = 100000
+)
+
+// PostgreSQL authentication request codes
+const (
+	postgresAuthOK           = 0
+	postgresAuthCleartext    = 3
+	postgresAuthMD5          = 5
+	postgresAuthSASL         = 10
+	postgresAuthSASLContinue = 11
+	postgresAuthSASLFinal    = 12
+)
+
+// probePostgres logs in to PostgreSQL and runs SELECT 1
+func (p *Prober) probePostgres(ctx context.Context, service *store.Service) ProbeResult {
+	return p.probeDatabase(ctx, service, "postgres", 5432, checkPostgres)
+}
+
+// checkPostgres logs in with the POSTGRES_USER, POSTGRES_PASSWORD and
+// POSTGRES_DB of the official image and runs SELECT 1
+func checkPostgres(conn net.Conn, env map[string]string) error {
+	user := envValue(env, "postgres", "POSTGRES_USER")
+	password := envValue(env, "", "POSTGRES_PASSWORD")
+	database := envValue(env, user, "POSTGRES_DB")
+
+	pg := &postgresConn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := pg.startup(user, password, database); err != nil {
+		return err
+	}
+	if err := pg.query("SELECT 1"); err != nil {
+		return err
+	}
+
+	// Terminate; the connection is closed either way
+	pg.send('X', nil)
+	return nil
+}
+
+// postgresConn speaks the PostgreSQL frontend/backend protocol
+type postgresConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	scram  *scramClient
+}
+
+// send writes a message; kind 0 sends the untyped startup message
+func (c *postgresConn) send(kind byte, body []byte) error {
+	message := make([]byte, 0, 5+len(body))
+	if kind != 0 {
+		message = append(message, kind)
+	}
+	message = binary.BigEndian.AppendUint32(message, uint32(4+len(body)))
+	message = append(message, body...)
+
+	if _, err := c.conn.Write(message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// receive reads a message, turning an ErrorResponse into a ProtocolError
+func (c *postgresConn) receive() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > postgresMaxMessage {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	if header[0] == 'E' {
+		return 0, nil, postgresError(body)
+	}
+	return header[0], body, nil
+}
+
+// startup sends the startup message and authenticates until the server is ready
+func (c *postgresConn) startup(user, password, database string) error {
+	body := binary.BigEndian.AppendUint32(nil, postgresProtocolVersion)
+	for _, param := range []string{"user", user, "database", database} {
+		body = append(body, cstring(param)...)
+	}
+	body = append(body, 0)
+
+	if err := c.send(0, body); err != nil {
+		return err
+	}
+
+	for {
+		kind, body, err := c.receive()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case 'R':
+			if len(body) < 4 {
+				return errors.New("malformed authentication request")
+			}
+			if err := c.authenticate(binary.BigEndian.Uint32(body), body[4:], user, password); err != nil {
+				return err
+			}
+		case 'Z':
+			return nil
+		}
+		// Parameter statuses, backend key data and notices need no answer
+	}
+}
+
+// authenticate answers an authentication request
+func (c *postgresConn) authenticate(method uint32, data []byte, user, password string) error {
+	switch method {
+	case postgresAuthOK:
+		return nil
+	case postgresAuthCleartext:
+		return c.send('p', cstring(password))
+	case postgresAuthMD5:
+		if len(data) < 4 {
+			return errors.New("malformed MD5 authentication request")
+		}
+		return c.send('p', cstring(postgresMD5(user, password, data[:4])))
+	case postgresAuthSASL:
+		mechanisms := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		if !containsString(mechanisms, "SCRAM-SHA-256") {
+			return fmt.Errorf("unsupported SASL mechanisms %s", strings.Join(mechanisms, ", "))
+		}
+		client, err := newSCRAMClient(password)
+		if err != nil {
+			return err
+		}
+		c.scram = client
+
+		first := client.clientFirst()
+		body := cstring("SCRAM-SHA-256")
+		body = binary.BigEndian.AppendUint32(body, uint32(len(first)))
+		body = append(body, first...)
+		return c.send('p', body)
+	case postgresAuthSASLContinue:
+		if c.scram == nil {
+			return errors.New("unexpected SASL continuation")
+		}
+		final, err := c.scram.clientFinal(string(data))
+		if err != nil {
+			return err
+		}
+		return c.send('p', []byte(final))
+	case postgresAuthSASLFinal:
+		if c.scram == nil {
+			return errors.New("unexpected SASL completion")
+		}
+		return c.scram.verifyServerFinal(string(data))
+	default:
+		return fmt.Errorf("unsupported authentication method %d", method)
+	}
+}
+
+// query runs a simple query and waits for the server to be ready again
+func (c *postgresConn) query(sql string) error {
+	if err := c.send('Q', cstring(sql)); err != nil {
+		return err
+	}
+
+	for {
+		kind, _, err := c.receive()
+		if err != nil {
+			return err
+		}
+		if kind == 'Z' {
+			return nil
+		}
+	}
+}
+
+// postgresError reads the code and message fields of an ErrorResponse
+func postgresError(body []byte) *ProtocolError {
+	protocolErr := &ProtocolError{Protocol: "postgres"}
+	for len(body) > 0 && body[0] != 0 {
+		end := bytes.IndexByte(body[1:], 0)
+		if end < 0 {
+			break
+		}
+		value := string(body[1 : 1+end])
+		switch body[0] {
+		case 'C':
+			protocolErr.Code = value
+		case 'M':
+			protocolErr.Message = value
+		}
+		body = body[2+end:]
+	}
+	if protocolErr.Message == "" {
+		protocolErr.Message = "server returned an error"
+	}
+	return protocolErr
+}
+
+// postgresMD5 hashes a password the way MD5 authentication expects
+func postgresMD5(user, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// scramClient runs a SCRAM-SHA-256 exchange without channel binding
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	authMessage     string
+	saltedPassword  []byte
+}
+
+func newSCRAMClient(password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate SCRAM nonce: %w", err)
+	}
+
+	client := &scramClient{password: password, clientNonce: base64.StdEncoding.EncodeToString(nonce)}
+	// PostgreSQL takes the user from the startup message
+	client.clientFirstBare = "n=,r=" + client.clientNonce
+	return client, nil
+}
+
+func (s *scramClient) clientFirst() string {
+	return "n,," + s.clientFirstBare
+}
+
+// clientFinal answers the server-first-message with the client proof
+func (s *scramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := scramAttributes(serverFirst)
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, s.clientNonce) || len(nonce) == len(s.clientNonce) {
+		return "", errors.New("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return "", errors.New("invalid SCRAM salt")
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 || iterations > scramMaxIterations {
+		return "", errors.New("invalid SCRAM iteration count")
+	}
+
+	s.saltedPassword = pbkdf2.Key([]byte(s.password), salt, iterations, sha256.Size, sha256.New)
+
+	finalWithoutProof := "c=biws,r=" + nonce
+	s.authMessage = s.clientFirstBare + "," + serverFirst + "," + finalWithoutProof
+
+	clientKey := hmacSHA256(s.saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	signature := hmacSHA256(storedKey[:], s.authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+
+	return finalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal checks the server knows the password too
+func (s *scramClient) verifyServerFinal(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if message := attrs["e"]; message != "" {
+		return fmt.Errorf("SCRAM authentication failed: %s", message)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	expected := hmacSHA256(hmacSHA256(s.saltedPassword, "Server Key"), s.authMessage)
+	if err != nil || !hmac.Equal(signature, expected) {
+		return errors.New("invalid SCRAM server signature")
+	}
+	return nil
+}
+
+// scramAttributes splits a SCRAM message into its attributes
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(message, ",") {
+		if key, value, ok := strings.Cut(part, "="); ok {
+			attrs[key] = value
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// cstring returns s NUL-terminated
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/rs/zerolog/log"
 )
@@ -18,10 +19,35 @@ type ServiceStore interface {
 	UpdateServiceHealth(ctx context.Context, serviceID int64, healthStatus string) error
 }
 
+// EnvVarStore lists the environment variables a service was configured with
+type EnvVarStore interface {
+	ListEnvVars(ctx context.Context, serviceID int64) ([]store.EnvVar, error)
+}
+
+// HealthCheckStore keeps the health checks services configured and the history of their probes
+type HealthCheckStore interface {
+	GetHealthCheck(ctx context.Context, serviceID int64) (*store.HealthCheck, error)
+	RecordHealthCheck(ctx context.Context, record *store.HealthCheckRecord) error
+	ListHealthHistory(ctx context.Context, serviceID int64, limit int) ([]store.HealthCheckRecord, error)
+}
+
+// ContainerEngine runs exec health checks and tells when containers started
+type ContainerEngine interface {
+	Inspect(ctx context.Context, containerID string) (dockerx.ContainerStatus, error)
+	Exec(ctx context.Context, id string, command []string, size dockerx.TerminalSize) (*dockerx.ExecSession, error)
+	ExecExitCode(ctx context.Context, execID string) (int, error)
+}
+
 // Prober handles health checks for services
 type Prober struct {
-	store  ServiceStore
-	client *http.Client
+	store   ServiceStore
+	envVars EnvVarStore
+	checks  HealthCheckStore
+	engine  ContainerEngine
+	client  *http.Client
+
+	// dial connects database probes; tests replace it to reach fake servers
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 // NewProber creates a new health prober
@@ -37,17 +63,48 @@ func NewProber(store ServiceStore) *Prober {
 				ResponseHeaderTimeout: 10 * time.Second,
 			},
 		},
+		dial: (&net.Dialer{}).DialContext,
 	}
 }
 
+// SetEnvVarStore lets database probes read credentials from the service's
+// environment variables, secrets included, on top of its env
+func (p *Prober) SetEnvVarStore(envVars EnvVarStore) {
+	p.envVars = envVars
+}
+
+// SetHealthCheckStore makes the prober use the health checks services configured,
+// apply their thresholds and record every probe in the service's health history
+func (p *Prober) SetHealthCheckStore(checks HealthCheckStore) {
+	p.checks = checks
+}
+
+// SetEngine enables exec health checks and start periods
+func (p *Prober) SetEngine(engine ContainerEngine) {
+	p.engine = engine
+}
+
 // ProbeResult represents the result of a health check
 type ProbeResult struct {
 	Status string
 	Error  error
+
+	// Address is the project network address a database probe connected to
+	Address string
+	// ProtocolError holds the error the database returned, if any
+	ProtocolError *ProtocolError
+	// Output is what an exec health check printed
+	Output string
 }
 
 // ProbeService performs a health check on a service
 func (p *Prober) ProbeService(ctx context.Context, service *store.Service, routes []store.Route) ProbeResult {
+	return p.probe(ctx, service, routes, p.healthCheck(ctx, service.ID))
+}
+
+// probe checks a service with its configured health check, or the check
+// picked from its image when it has none
+func (p *Prober) probe(ctx context.Context, service *store.Service, routes []store.Route, check *store.HealthCheck) ProbeResult {
 	// Skip probing if service is crash looping
 	if service.CrashLooping {
 		return ProbeResult{Status: store.HealthStatusUnknown}
@@ -60,6 +117,10 @@ func (p *Prober) ProbeService(ctx context.Context, service *store.Service, route
 		return ProbeResult{Status: store.HealthStatusUnknown}
 	}
 
+	if check != nil {
+		return p.probeCheck(ctx, service, check)
+	}
+
 	// Determine health check type
 	healthType := service.GetHealthCheckType()
 
@@ -79,7 +140,9 @@ func (p *Prober) ProbeService(ctx context.Context, service *store.Service, route
 	}
 }
 
-// ProbeAndUpdate performs a health check and updates the service status
+// ProbeAndUpdate performs a health check and updates the service status. With a
+// configured health check the status only changes once the probe result held
+// for the check's threshold, and failures during its start period are not counted.
 func (p *Prober) ProbeAndUpdate(ctx context.Context, serviceID int64) error {
 	service, err := p.store.GetService(ctx, serviceID)
 	if err != nil {
@@ -93,15 +156,45 @@ func (p *Prober) ProbeAndUpdate(ctx context.Context, serviceID int64) error {
 		routes = []store.Route{}
 	}
 
-	result := p.ProbeService(ctx, &service, routes)
+	check := p.healthCheck(ctx, serviceID)
+	startedAt := time.Now()
+	result := p.probe(ctx, &service, routes, check)
+	duration := time.Since(startedAt)
 
 	// Log error if health check failed
 	if result.Error != nil {
 		log.Error().Err(result.Error).Int64("service_id", serviceID).Str("status", result.Status).Msg("health check failed")
 	}
 
+	record := store.HealthCheckRecord{
+		ServiceID:  serviceID,
+		CheckType:  string(service.GetHealthCheckType()),
+		Result:     result.Status,
+		Status:     result.Status,
+		Output:     result.Output,
+		DurationMs: duration.Milliseconds(),
+	}
+	if result.Error != nil {
+		record.Output = result.Error.Error()
+	}
+	if check != nil {
+		record.CheckType = string(check.Type)
+		if result.Status == store.HealthStatusFail && p.inStartPeriod(ctx, &service, check) {
+			record.InStartPeriod = true
+			record.Status = currentHealth(&service)
+		} else if result.Status != store.HealthStatusUnknown && result.Status != store.HealthStatusUnreachable {
+			record.Status = p.thresholdStatus(ctx, &service, check, result.Status)
+		}
+	}
+
+	if p.checks != nil {
+		if err := p.checks.RecordHealthCheck(ctx, &record); err != nil {
+			log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to record health check")
+		}
+	}
+
 	// Update health status in store
-	if updateErr := p.store.UpdateServiceHealth(ctx, serviceID, result.Status); updateErr != nil {
+	if updateErr := p.store.UpdateServiceHealth(ctx, serviceID, record.Status); updateErr != nil {
 		return fmt.Errorf("failed to update service health: %w", updateErr)
 	}
 
@@ -186,24 +279,3 @@ func (p *Prober) probeTCP(ctx context.Context, service *store.Service) ProbeResu
 
 	return ProbeResult{Status: store.HealthStatusOK}
 }
-
-// probePostgres performs PostgreSQL-specific health check
-func (p *Prober) probePostgres(ctx context.Context, service *store.Service) ProbeResult {
-	// For now, use TCP connectivity as PostgreSQL health check
-	// This could be enhanced with actual PostgreSQL connection test
-	return p.probeTCP(ctx, service)
-}
-
-// probeMySQL performs MySQL-specific health check
-func (p *Prober) probeMySQL(ctx context.Context, service *store.Service) ProbeResult {
-	// For now, use TCP connectivity as MySQL health check
-	// This could be enhanced with actual MySQL connection test
-	return p.probeTCP(ctx, service)
-}
-
-// probeRedis performs Redis-specific health check
-func (p *Prober) probeRedis(ctx context.Context, service *store.Service) ProbeResult {
-	// For now, use TCP connectivity as Redis health check
-	// This could be enhanced with Redis PING command
-	return p.probeTCP(ctx, service)
-}
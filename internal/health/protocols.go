@@ -0,0 +1,174 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/crypto"
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// databaseProbeTimeout bounds a whole database probe, login included
+const databaseProbeTimeout = 5 * time.Second
+
+// ProtocolError is an error a database returned while being probed
+type ProtocolError struct {
+	Protocol string `json:"protocol"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s error %s: %s", e.Protocol, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s error: %s", e.Protocol, e.Message)
+}
+
+// errUnreachable marks a service port glinrdockd has no route to
+var errUnreachable = errors.New("unreachable from controller")
+
+// addressResult reports why a probe address could not be found: unreachable
+// when glinrdockd cannot reach the port, else unknown
+func addressResult(err error) ProbeResult {
+	if errors.Is(err, errUnreachable) {
+		return ProbeResult{Status: store.HealthStatusUnreachable, Error: err}
+	}
+	return ProbeResult{Status: store.HealthStatusUnknown, Error: err}
+}
+
+// databaseCheck logs in to a database over an open connection and checks it answers
+type databaseCheck func(conn net.Conn, env map[string]string) error
+
+// probeDatabase connects to a database service on its published host port and
+// runs a protocol-level check with credentials from the service's environment
+func (p *Prober) probeDatabase(ctx context.Context, service *store.Service, protocol string, defaultPort int, check databaseCheck) ProbeResult {
+	address, err := p.probeAddress(ctx, service, containerPort(service, defaultPort))
+	if err != nil {
+		return addressResult(err)
+	}
+
+	env, err := p.serviceEnv(ctx, service)
+	if err != nil {
+		return ProbeResult{Status: store.HealthStatusUnknown, Error: err, Address: address}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, databaseProbeTimeout)
+	defer cancel()
+
+	conn, err := p.dial(ctx, "tcp", address)
+	if err != nil {
+		return ProbeResult{
+			Status:  store.HealthStatusFail,
+			Error:   fmt.Errorf("%s connection to %s failed: %w", protocol, address, err),
+			Address: address,
+		}
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := check(conn, env); err != nil {
+		result := ProbeResult{
+			Status:  store.HealthStatusFail,
+			Error:   fmt.Errorf("%s health check failed: %w", protocol, err),
+			Address: address,
+		}
+		var protocolErr *ProtocolError
+		if errors.As(err, &protocolErr) {
+			result.ProtocolError = protocolErr
+		}
+		return result
+	}
+
+	return ProbeResult{Status: store.HealthStatusOK, Address: address}
+}
+
+// probeAddress returns the host address Docker published a service's container
+// port on. glinrdockd is not attached to project networks, so like blue/green
+// rollouts it reaches containers through their published ports.
+func (p *Prober) probeAddress(ctx context.Context, service *store.Service, port int) (string, error) {
+	if p.engine == nil || service.ContainerID == nil || *service.ContainerID == "" {
+		return "", fmt.Errorf("port %d is %w: no container to inspect", port, errUnreachable)
+	}
+
+	status, err := p.engine.Inspect(ctx, *service.ContainerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	for _, published := range status.Ports {
+		if published.Container == port && published.Host > 0 {
+			return net.JoinHostPort("127.0.0.1", strconv.Itoa(published.Host)), nil
+		}
+	}
+	return "", fmt.Errorf("port %d is %w: it is not published on the host", port, errUnreachable)
+}
+
+// containerPort returns the port a database listens on inside its container:
+// the protocol default when it is published or nothing is, else the first
+// published container port
+func containerPort(service *store.Service, defaultPort int) int {
+	for _, port := range service.Ports {
+		if port.Container == defaultPort {
+			return defaultPort
+		}
+	}
+	if len(service.Ports) > 0 && service.Ports[0].Container > 0 {
+		return service.Ports[0].Container
+	}
+	return defaultPort
+}
+
+// serviceEnv returns the environment of a service with its environment
+// variables, secrets decrypted, laid over its env
+func (p *Prober) serviceEnv(ctx context.Context, service *store.Service) (map[string]string, error) {
+	env := make(map[string]string, len(service.Env))
+	for key, value := range service.Env {
+		env[key] = value
+	}
+	if p.envVars == nil {
+		return env, nil
+	}
+
+	envVars, err := p.envVars.ListEnvVars(ctx, service.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environment variables: %w", err)
+	}
+
+	var masterKey []byte
+	for _, envVar := range envVars {
+		if !envVar.IsSecret {
+			env[envVar.Key] = envVar.Value
+			continue
+		}
+		if masterKey == nil {
+			if masterKey, err = crypto.LoadMasterKeyFromEnv(); err != nil {
+				return nil, fmt.Errorf("cannot decrypt secret environment variables: %w", err)
+			}
+		}
+		plaintext, err := crypto.Decrypt(masterKey, envVar.Nonce, envVar.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s", envVar.Key)
+		}
+		env[envVar.Key] = string(plaintext)
+	}
+
+	return env, nil
+}
+
+// envValue returns the first of keys set in env, or fallback
+func envValue(env map[string]string, fallback string, keys ...string) string {
+	for _, key := range keys {
+		if value := env[key]; value != "" {
+			return value
+		}
+	}
+	return fallback
+}
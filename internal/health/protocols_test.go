@@ -0,0 +1,391 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// fakeDatabase runs serve against the probe's connection and records the address dialed
+func fakeDatabase(t *testing.T, serve func(conn net.Conn)) (*Prober, *string) {
+	t.Helper()
+	prober := NewProber(&MockServiceStore{})
+	// The container publishes the default port of each database, and 6380
+	prober.SetEngine(&checkEngine{ports: []store.PortMap{
+		{Container: 3306, Host: 13306},
+		{Container: 5432, Host: 15432},
+		{Container: 6379, Host: 16379},
+		{Container: 6380, Host: 16380},
+	}})
+
+	var dialed string
+	prober.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = address
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			serve(server)
+		}()
+		return client, nil
+	}
+	return prober, &dialed
+}
+
+func databaseService(image string, env map[string]string) *store.Service {
+	containerID := "db-container"
+	return &store.Service{
+		ID:           1,
+		ProjectID:    7,
+		Name:         "db",
+		Image:        image,
+		ContainerID:  &containerID,
+		DesiredState: store.ServiceStateRunning,
+		Env:          env,
+		Ports:        []store.PortMap{{Container: 5432, Host: 15432}},
+	}
+}
+
+// Postgres
+
+func readPostgresMessage(r *bufio.Reader) (byte, []byte) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+	io.ReadFull(r, body)
+	return header[0], body
+}
+
+func writePostgresMessage(w io.Writer, kind byte, body []byte) {
+	message := []byte{kind}
+	message = binary.BigEndian.AppendUint32(message, uint32(4+len(body)))
+	w.Write(append(message, body...))
+}
+
+func postgresAuth(code uint32, data []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, code), data...)
+}
+
+// fakePostgres reads the startup message, runs auth and answers one query
+func fakePostgres(t *testing.T, auth func(r *bufio.Reader, w io.Writer, user string) bool) func(net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		var length [4]byte
+		io.ReadFull(r, length[:])
+		startup := make([]byte, binary.BigEndian.Uint32(length[:])-4)
+		io.ReadFull(r, startup)
+		params := strings.Split(string(startup[4:]), "\x00")
+		if params[0] != "user" || params[2] != "database" || params[3] != "shop" {
+			t.Errorf("unexpected startup parameters %q", params)
+		}
+
+		if !auth(r, conn, params[1]) {
+			return
+		}
+		writePostgresMessage(conn, 'R', postgresAuth(0, nil))
+		writePostgresMessage(conn, 'S', []byte("server_version\x0016\x00"))
+		writePostgresMessage(conn, 'Z', []byte{'I'})
+
+		kind, query := readPostgresMessage(r)
+		if kind != 'Q' || string(query) != "SELECT 1\x00" {
+			t.Errorf("unexpected query %c %q", kind, query)
+		}
+		writePostgresMessage(conn, 'C', []byte("SELECT 1\x00"))
+		writePostgresMessage(conn, 'Z', []byte{'I'})
+		readPostgresMessage(r)
+	}
+}
+
+func md5Auth(password string) func(r *bufio.Reader, w io.Writer, user string) bool {
+	return func(r *bufio.Reader, w io.Writer, user string) bool {
+		salt := []byte{1, 2, 3, 4}
+		writePostgresMessage(w, 'R', postgresAuth(5, salt))
+		_, response := readPostgresMessage(r)
+		if string(response) != postgresMD5(user, password, salt)+"\x00" {
+			writePostgresMessage(w, 'E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed for user \""+user+"\"\x00\x00"))
+			return false
+		}
+		return true
+	}
+}
+
+func TestProbeService_PostgresMD5(t *testing.T) {
+	prober, dialed := fakeDatabase(t, fakePostgres(t, md5Auth("s3cret")))
+	service := databaseService("postgres:16", map[string]string{
+		"POSTGRES_USER": "app", "POSTGRES_PASSWORD": "s3cret", "POSTGRES_DB": "shop",
+	})
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusOK {
+		t.Fatalf("expected ok, got %s: %v", result.Status, result.Error)
+	}
+	if *dialed != "127.0.0.1:15432" || result.Address != *dialed {
+		t.Errorf("expected probe on the published host port, dialed %q", *dialed)
+	}
+}
+
+func TestProbeService_PostgresRejectedLogin(t *testing.T) {
+	prober, _ := fakeDatabase(t, fakePostgres(t, md5Auth("s3cret")))
+	service := databaseService("postgres:16", map[string]string{
+		"POSTGRES_USER": "app", "POSTGRES_PASSWORD": "wrong", "POSTGRES_DB": "shop",
+	})
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusFail {
+		t.Fatalf("expected fail for a refused login, got %s", result.Status)
+	}
+	if result.ProtocolError == nil || result.ProtocolError.Code != "28P01" {
+		t.Fatalf("expected postgres error 28P01, got %v", result.Error)
+	}
+	if result.ProtocolError.Message != `password authentication failed for user "app"` {
+		t.Errorf("unexpected error message %q", result.ProtocolError.Message)
+	}
+}
+
+func TestProbeService_PostgresSCRAM(t *testing.T) {
+	const password = "s3cret"
+	scram := func(r *bufio.Reader, w io.Writer, user string) bool {
+		writePostgresMessage(w, 'R', postgresAuth(10, []byte("SCRAM-SHA-256\x00\x00")))
+
+		_, initial := readPostgresMessage(r)
+		mechanism, rest, _ := bytes.Cut(initial, []byte{0})
+		if string(mechanism) != "SCRAM-SHA-256" {
+			t.Errorf("unexpected mechanism %q", mechanism)
+		}
+		clientFirstBare := strings.TrimPrefix(string(rest[4:]), "n,,")
+		nonce := scramAttributes(clientFirstBare)["r"] + "server"
+		salt := []byte("salt")
+		serverFirst := "r=" + nonce + ",s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+		writePostgresMessage(w, 'R', postgresAuth(11, []byte(serverFirst)))
+
+		_, clientFinal := readPostgresMessage(r)
+		finalWithoutProof, proof64, _ := strings.Cut(string(clientFinal), ",p=")
+		authMessage := clientFirstBare + "," + serverFirst + "," + finalWithoutProof
+		saltedPassword := pbkdf2.Key([]byte(password), salt, 4096, sha256.Size, sha256.New)
+		storedKey := sha256.Sum256(hmacSHA256(saltedPassword, "Client Key"))
+		proof, _ := base64.StdEncoding.DecodeString(proof64)
+		clientKey := xorBytes(proof, hmacSHA256(storedKey[:], authMessage))
+		if sha256.Sum256(clientKey) != storedKey {
+			writePostgresMessage(w, 'E', []byte("SFATAL\x00C28P01\x00Mpassword authentication failed\x00\x00"))
+			return false
+		}
+
+		serverSignature := hmacSHA256(hmacSHA256(saltedPassword, "Server Key"), authMessage)
+		writePostgresMessage(w, 'R', postgresAuth(12, []byte("v="+base64.StdEncoding.EncodeToString(serverSignature))))
+		return true
+	}
+
+	prober, _ := fakeDatabase(t, fakePostgres(t, scram))
+	service := databaseService("postgres:16", map[string]string{
+		"POSTGRES_USER": "app", "POSTGRES_PASSWORD": password, "POSTGRES_DB": "shop",
+	})
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusOK {
+		t.Fatalf("expected ok, got %s: %v", result.Status, result.Error)
+	}
+}
+
+func TestSCRAMClient_IterationBound(t *testing.T) {
+	client, err := newSCRAMClient("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := base64.StdEncoding.EncodeToString([]byte("salt"))
+	for _, iterations := range []string{"0", "2147483647", "many"} {
+		serverFirst := "r=" + client.clientNonce + "server,s=" + salt + ",i=" + iterations
+		if _, err := client.clientFinal(serverFirst); err == nil {
+			t.Errorf("expected i=%s to be rejected", iterations)
+		}
+	}
+}
+
+// MySQL
+
+func writeMySQLPacket(w io.Writer, sequence byte, payload []byte) {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), sequence}
+	w.Write(append(header, payload...))
+}
+
+func readMySQLPacket(r *bufio.Reader) []byte {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	io.ReadFull(r, payload)
+	return payload
+}
+
+// fakeMySQL checks a mysql_native_password login the way the server does and answers COM_PING
+func fakeMySQL(t *testing.T, user, password string) func(net.Conn) {
+	scramble := []byte("abcdefghijklmnopqrst")
+	stage1 := sha1.Sum([]byte(password))
+	stored := sha1.Sum(stage1[:])
+
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		greeting := []byte{10}
+		greeting = append(greeting, "8.4.0\x00"...)
+		greeting = append(greeting, 1, 0, 0, 0)
+		greeting = append(greeting, scramble[:8]...)
+		greeting = append(greeting, 0)
+		greeting = binary.LittleEndian.AppendUint16(greeting, uint16(mysqlClientProtocol41|mysqlClientSecureConnection))
+		greeting = append(greeting, 45, 2, 0)
+		greeting = binary.LittleEndian.AppendUint16(greeting, uint16(mysqlClientPluginAuth>>16))
+		greeting = append(greeting, 21)
+		greeting = append(greeting, make([]byte, 10)...)
+		greeting = append(greeting, scramble[8:]...)
+		greeting = append(greeting, 0)
+		greeting = append(greeting, "mysql_native_password\x00"...)
+		writeMySQLPacket(conn, 0, greeting)
+
+		response := readMySQLPacket(r)
+		rest := response[32:]
+		name, rest, _ := bytes.Cut(rest, []byte{0})
+		token := rest[1 : 1+int(rest[0])]
+
+		hash := sha1.New()
+		hash.Write(scramble)
+		hash.Write(stored[:])
+		candidate := sha1.Sum(xorBytes(token, hash.Sum(nil)))
+		if string(name) != user || len(token) != sha1.Size || candidate != stored {
+			writeMySQLPacket(conn, 2, []byte("\xff\x15\x04#28000Access denied for user '"+string(name)+"'@'10.0.0.2' (using password: YES)"))
+			return
+		}
+		writeMySQLPacket(conn, 2, []byte{0, 0, 0, 2, 0, 0, 0})
+
+		if ping := readMySQLPacket(r); len(ping) != 1 || ping[0] != mysqlComPing {
+			t.Errorf("expected COM_PING, got %v", ping)
+			return
+		}
+		writeMySQLPacket(conn, 1, []byte{0, 0, 0, 2, 0, 0, 0})
+		readMySQLPacket(r)
+	}
+}
+
+func TestProbeService_MySQL(t *testing.T) {
+	prober, dialed := fakeDatabase(t, fakeMySQL(t, "app", "s3cret"))
+	service := databaseService("mysql:8.4", map[string]string{
+		"MYSQL_USER": "app", "MYSQL_PASSWORD": "s3cret", "MYSQL_ROOT_PASSWORD": "root", "MYSQL_DATABASE": "shop",
+	})
+	service.Ports = nil
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusOK {
+		t.Fatalf("expected ok, got %s: %v", result.Status, result.Error)
+	}
+	if *dialed != "127.0.0.1:13306" {
+		t.Errorf("expected the host port of the default MySQL port, dialed %q", *dialed)
+	}
+}
+
+func TestProbeService_MySQLAccessDenied(t *testing.T) {
+	prober, _ := fakeDatabase(t, fakeMySQL(t, "root", "root"))
+	service := databaseService("mariadb:11", map[string]string{"MARIADB_ROOT_PASSWORD": "changed"})
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusFail {
+		t.Fatalf("expected fail for a refused login, got %s", result.Status)
+	}
+	if result.ProtocolError == nil || result.ProtocolError.Code != strconv.Itoa(1045) {
+		t.Fatalf("expected mysql error 1045, got %v", result.Error)
+	}
+	if !strings.HasPrefix(result.ProtocolError.Message, "Access denied for user 'root'") {
+		t.Errorf("unexpected error message %q", result.ProtocolError.Message)
+	}
+}
+
+// Redis
+
+// fakeRedis requires AUTH with password before answering PING
+func fakeRedis(password string) func(net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		authenticated := password == ""
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, count)
+			for i := range args {
+				r.ReadString('\n')
+				arg, _ := r.ReadString('\n')
+				args[i] = strings.TrimRight(arg, "\r\n")
+			}
+
+			switch {
+			case args[0] == "AUTH" && args[len(args)-1] == password:
+				authenticated = true
+				conn.Write([]byte("+OK\r\n"))
+			case args[0] == "AUTH":
+				conn.Write([]byte("-WRONGPASS invalid username-password pair or user is disabled.\r\n"))
+			case !authenticated:
+				conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+			case args[0] == "PING":
+				conn.Write([]byte("+PONG\r\n"))
+			}
+		}
+	}
+}
+
+func TestProbeService_Redis(t *testing.T) {
+	prober, dialed := fakeDatabase(t, fakeRedis("s3cret"))
+	service := databaseService("redis:7", map[string]string{"REDIS_PASSWORD": "s3cret"})
+	service.Ports = []store.PortMap{{Container: 6380, Host: 16380}}
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusOK {
+		t.Fatalf("expected ok, got %s: %v", result.Status, result.Error)
+	}
+	if *dialed != "127.0.0.1:16380" {
+		t.Errorf("expected the host port of the published container port, dialed %q", *dialed)
+	}
+}
+
+func TestProbeService_RedisRequiresAuth(t *testing.T) {
+	prober, _ := fakeDatabase(t, fakeRedis("s3cret"))
+	service := databaseService("redis:7", nil)
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusFail {
+		t.Fatalf("expected fail without credentials, got %s", result.Status)
+	}
+	if result.ProtocolError == nil || result.ProtocolError.Code != "NOAUTH" {
+		t.Fatalf("expected redis NOAUTH error, got %v", result.Error)
+	}
+}
+
+func TestProbeService_DatabaseUnreachable(t *testing.T) {
+	prober, dialed := fakeDatabase(t, fakeRedis(""))
+	service := databaseService("redis:7", nil)
+	service.Ports = []store.PortMap{{Container: 6390, Host: 16390}}
+
+	result := prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusUnreachable || *dialed != "" {
+		t.Errorf("expected unreachable without dialing when the port is not published, got %s", result.Status)
+	}
+
+	service = databaseService("redis:7", nil)
+	service.ContainerID = nil
+	result = prober.ProbeService(context.Background(), service, nil)
+	if result.Status != store.HealthStatusUnreachable || !strings.Contains(result.Error.Error(), "unreachable from controller") {
+		t.Errorf("expected unreachable without a container, got %s: %v", result.Status, result.Error)
+	}
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// probeRedis authenticates to Redis when it has a password and sends PING
+func (p *Prober) probeRedis(ctx context.Context, service *store.Service) ProbeResult {
+	return p.probeDatabase(ctx, service, "redis", 6379, checkRedis)
+}
+
+// checkRedis authenticates with REDIS_PASSWORD, and REDIS_USERNAME for ACL
+// users, then expects PONG
+func checkRedis(conn net.Conn, env map[string]string) error {
+	reader := bufio.NewReader(conn)
+
+	if password := envValue(env, "", "REDIS_PASSWORD"); password != "" {
+		args := []string{"AUTH", password}
+		if user := envValue(env, "", "REDIS_USERNAME", "REDIS_USER"); user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := redisCommand(conn, reader, args...); err != nil {
+			return err
+		}
+	}
+
+	reply, err := redisCommand(conn, reader, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected reply to PING: %q", reply)
+	}
+	return nil
+}
+
+// redisCommand sends a command and reads its status reply, turning an error
+// reply into a ProtocolError
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(command.String())); err != nil {
+		return "", fmt.Errorf("failed to send %s: %w", args[0], err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read reply to %s: %w", args[0], err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", redisError(line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply to %s: %q", args[0], line)
+	}
+}
+
+// redisError splits an error reply into its prefix and message
+func redisError(reply string) *ProtocolError {
+	code, message, found := strings.Cut(reply, " ")
+	if !found {
+		return &ProtocolError{Protocol: "redis", Message: reply}
+	}
+	return &ProtocolError{Protocol: "redis", Code: code, Message: message}
+}
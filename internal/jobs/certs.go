@@ -260,7 +260,7 @@ func (cm *CertManager) Handle(ctx context.Context, job *Job) error {
 	case "cert_renew":
 		return cm.handleRenewJob(ctx, job)
 	default:
-		return fmt.Errorf("unknown cert job type: %s", job.Type)
+		return Permanent(fmt.Errorf("unknown cert job type: %s", job.Type))
 	}
 }
 
@@ -268,12 +268,12 @@ func (cm *CertManager) Handle(ctx context.Context, job *Job) error {
 func (cm *CertManager) handleIssueJob(ctx context.Context, job *Job) error {
 	domain, ok := job.Data["domain"].(string)
 	if !ok {
-		return fmt.Errorf("missing or invalid domain in job data")
+		return Permanent(fmt.Errorf("missing or invalid domain in job data"))
 	}
 
 	email, ok := job.Data["email"].(string)
 	if !ok {
-		return fmt.Errorf("missing or invalid email in job data")
+		return Permanent(fmt.Errorf("missing or invalid email in job data"))
 	}
 
 	cm.queue.UpdateJobProgress(job.ID, 10)
@@ -298,7 +298,7 @@ func (cm *CertManager) handleIssueJob(ctx context.Context, job *Job) error {
 func (cm *CertManager) handleRenewJob(ctx context.Context, job *Job) error {
 	domain, ok := job.Data["domain"].(string)
 	if !ok {
-		return fmt.Errorf("missing or invalid domain in job data")
+		return Permanent(fmt.Errorf("missing or invalid domain in job data"))
 	}
 
 	cm.queue.UpdateJobProgress(job.ID, 10)
@@ -0,0 +1,397 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/metrics"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// DeployJobHandler handles deployment jobs
+type DeployJobHandler struct {
+	engine         dockerx.Engine
+	prober         ServiceProber
+	store          DeployStore
+	queue          *Queue // For progress updates
+	blueGreen      *rollout.BlueGreen
+	replicas       *rollout.Replicas
+	sidecars       *rollout.Sidecars
+	watcher        *RollbackWatcher
+	healthTimeout  time.Duration
+	healthInterval time.Duration
+}
+
+// DeployStore interface for deployment-related database operations
+type DeployStore interface {
+	GetService(ctx context.Context, serviceID int64) (store.Service, error)
+	GetProject(ctx context.Context, id int64) (store.Project, error)
+	ListRoutes(ctx context.Context, serviceID int64) ([]store.Route, error)
+	UpdateServiceImage(ctx context.Context, id int64, image string) error
+	UpdateServiceContainerID(ctx context.Context, id int64, containerID string) error
+	CreateDeployment(ctx context.Context, deployment *store.Deployment) error
+	UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error
+	GetServiceContainers(ctx context.Context, serviceID int64) (*store.ServiceContainers, error)
+	ActiveMaintenanceWindow(ctx context.Context, projectID int64, at time.Time) (*store.MaintenanceWindow, error)
+}
+
+// ServiceProber checks whether a service is serving requests
+type ServiceProber interface {
+	ProbeService(ctx context.Context, service *store.Service, routes []store.Route) health.ProbeResult
+}
+
+// NewDeployJobHandler creates a new deploy job handler
+func NewDeployJobHandler(engine dockerx.Engine, prober ServiceProber, deployStore DeployStore, queue *Queue) *DeployJobHandler {
+	return &DeployJobHandler{
+		engine:         engine,
+		prober:         prober,
+		store:          deployStore,
+		queue:          queue,
+		replicas:       rollout.NewReplicas(engine, deployStore),
+		sidecars:       rollout.NewSidecars(engine, deployStore),
+		healthTimeout:  rollout.DefaultHealthTimeout,
+		healthInterval: rollout.DefaultHealthInterval,
+	}
+}
+
+// SetRollbackWatcher enables automatic rollback for services that opt into it
+func (h *DeployJobHandler) SetRollbackWatcher(watcher *RollbackWatcher) {
+	h.watcher = watcher
+}
+
+// SetBlueGreen enables blue/green rollouts for services that select that strategy
+func (h *DeployJobHandler) SetBlueGreen(blueGreen *rollout.BlueGreen) {
+	h.blueGreen = blueGreen
+}
+
+// Handle processes a deployment job. The new image is rolled out by starting a
+// replacement container next to the current one, waiting for it to pass its
+// health check and only then removing the old container. If the replacement
+// never becomes healthy the old container is started again.
+func (h *DeployJobHandler) Handle(ctx context.Context, job *Job) error {
+	// Extract deployment data from job
+	deployData := &store.Deployment{}
+	if err := decodeJobData(job, "deployment", deployData); err != nil {
+		return Permanent(fmt.Errorf("invalid deployment data in job: %w", err))
+	}
+
+	// Track deployment duration for metrics
+	deployStart := time.Now()
+
+	h.queue.UpdateJobProgress(job.ID, 10)
+
+	// Get service details
+	service, err := h.store.GetService(ctx, deployData.ServiceID)
+	if err != nil {
+		metrics.RecordDeployment(false, time.Since(deployStart))
+		return fmt.Errorf("failed to get service: %w", err)
+	}
+
+	// Maintenance windows freeze deploys; rollbacks may still restore the previous image
+	rollback, _ := job.Data["rollback"].(bool)
+	if !rollback {
+		window, err := h.store.ActiveMaintenanceWindow(ctx, service.ProjectID, time.Now())
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			metrics.RecordDeployment(false, time.Since(deployStart))
+			return fmt.Errorf("failed to check maintenance windows: %w", err)
+		}
+		if window != nil {
+			reason := fmt.Sprintf("project is frozen by maintenance window %d", window.ID)
+			if window.Reason != "" {
+				reason += ": " + window.Reason
+			}
+			if err := h.store.UpdateDeploymentStatus(ctx, deployData.ID, "failed", &reason); err != nil {
+				log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status to failed")
+			}
+			metrics.RecordDeployment(false, time.Since(deployStart))
+			return Permanent(errors.New(reason))
+		}
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 20)
+
+	// Update deployment status to deploying
+	deployData.Status = "deploying"
+	if err := h.store.UpdateDeploymentStatus(ctx, deployData.ID, "deploying", nil); err != nil {
+		log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status")
+	}
+
+	if err := h.rollout(ctx, job, deployData, &service); err != nil {
+		// Record the outcome even if the job context was cancelled or timed out
+		reason := err.Error()
+		if updateErr := h.store.UpdateDeploymentStatus(context.WithoutCancel(ctx), deployData.ID, "failed", &reason); updateErr != nil {
+			log.Error().Err(updateErr).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status to failed")
+		}
+		metrics.RecordDeployment(false, time.Since(deployStart))
+		return err
+	}
+
+	// Update deployment status to success
+	deployData.Status = "success"
+	if err := h.store.UpdateDeploymentStatus(ctx, deployData.ID, "success", nil); err != nil {
+		log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status to success")
+	}
+
+	// Watch the new version unless this deploy is itself a rollback
+	if service.AutoRollback && !rollback && h.watcher != nil {
+		if _, err := h.watcher.Watch(deployData, time.Duration(service.RollbackWindow)*time.Second); err != nil {
+			log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to watch deployment for automatic rollback")
+		}
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 100)
+
+	// Record successful deployment metrics
+	metrics.RecordDeployment(true, time.Since(deployStart))
+
+	log.Info().
+		Int64("deployment_id", deployData.ID).
+		Int64("service_id", deployData.ServiceID).
+		Str("image_tag", deployData.ImageTag).
+		Msg("deployment completed successfully")
+
+	return nil
+}
+
+// rollout replaces the service's container with one running the deployment's image
+func (h *DeployJobHandler) rollout(ctx context.Context, job *Job, deployment *store.Deployment, service *store.Service) error {
+	var registryID string
+	if service.RegistryID != nil {
+		registryID = *service.RegistryID
+	}
+
+	log.Info().Str("image_tag", deployment.ImageTag).Msg("pulling image for deployment")
+	if err := h.engine.Pull(ctx, deployment.ImageTag, registryID); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", deployment.ImageTag, err)
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 40)
+
+	// Scheduled tasks have no container to replace, their next run uses the new image
+	if service.IsTask() {
+		if err := h.store.UpdateServiceImage(ctx, service.ID, deployment.ImageTag); err != nil {
+			return fmt.Errorf("failed to update service image: %w", err)
+		}
+		return nil
+	}
+
+	containers, err := h.store.GetServiceContainers(ctx, service.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load init containers and sidecars: %w", err)
+	}
+
+	// Falling back to recreate would cause the downtime blue/green was chosen to avoid
+	if service.DeployStrategy == store.DeployStrategyBlueGreen {
+		if err := store.ValidateDeployStrategyPorts(service.DeployStrategy, service.Ports); err != nil {
+			return Permanent(err)
+		}
+		if h.blueGreen == nil {
+			return Permanent(errors.New("blue/green deploys require the nginx proxy to be enabled"))
+		}
+		return h.rolloutBlueGreen(ctx, job, deployment, service, containers)
+	}
+
+	// Create the replacement under a temporary name while the old container still exists
+	containerName := fmt.Sprintf("glinr_%d_%s", service.ProjectID, service.Name)
+	candidateName := fmt.Sprintf("%s_deploy_%d", containerName, deployment.ID)
+	labels := dockerx.ServiceLabels(*service)
+	labels["glinr.deployment_id"] = strconv.FormatInt(deployment.ID, 10)
+	containerSpec := dockerx.ServiceContainerSpec(*service, deployment.ImageTag)
+
+	newID, err := h.engine.Create(ctx, candidateName, containerSpec, labels)
+	if err != nil {
+		return fmt.Errorf("failed to create container: %w", err)
+	}
+
+	h.connectProjectNetwork(ctx, service, newID)
+
+	// Init containers run while the old container still serves
+	if err := h.sidecars.RunInit(ctx, *service, containers.InitContainers); err != nil {
+		h.abandon(ctx, newID, "")
+		// Retrying will fail the same init container again
+		return Permanent(err)
+	}
+
+	// Stop the old container to release its host ports for the replacement
+	var oldID string
+	if service.ContainerID != nil {
+		oldID = *service.ContainerID
+	}
+	if oldID != "" {
+		h.stopSidecars(ctx, service)
+		if err := h.engine.Stop(ctx, oldID); err != nil {
+			log.Warn().Err(err).Str("container_id", oldID).Msg("failed to stop old container")
+		}
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 50)
+
+	if err := h.engine.Start(ctx, newID); err != nil {
+		h.abandon(ctx, newID, oldID)
+		h.ensureSidecars(ctx, service, containers.Sidecars, oldID)
+		return fmt.Errorf("failed to start new container: %w", err)
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 60)
+
+	candidate := *service
+	candidate.Image = deployment.ImageTag
+	candidate.ContainerID = &newID
+	candidate.DesiredState = store.ServiceStateRunning
+
+	routes, err := h.store.ListRoutes(ctx, service.ID)
+	if err != nil {
+		// Routes are optional, probe through the host port instead
+		routes = []store.Route{}
+	}
+
+	if err := rollout.WaitHealthy(ctx, h.engine, h.prober, &candidate, routes, newID, h.healthTimeout, h.healthInterval); err != nil {
+		h.abandon(ctx, newID, oldID)
+		h.ensureSidecars(ctx, service, containers.Sidecars, oldID)
+		// Retrying the same image will not make it healthy
+		return Permanent(fmt.Errorf("new container failed health check: %w", err))
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 80)
+
+	// Swap: remove the old container and give the new one the service's container name
+	if oldID != "" && oldID != newID {
+		if err := h.engine.Remove(ctx, oldID); err != nil {
+			log.Warn().Err(err).Str("old_container_id", oldID).Msg("failed to remove old container - manual cleanup may be required")
+		}
+	}
+	if err := h.engine.Rename(ctx, newID, containerName); err != nil {
+		log.Warn().Err(err).Str("container_id", newID).Str("name", containerName).Msg("failed to rename new container")
+	}
+
+	// The new container is live at this point, so bookkeeping failures are logged rather than failing the deploy
+	if err := h.store.UpdateServiceContainerID(ctx, service.ID, newID); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Str("container_id", newID).Msg("failed to store new container ID")
+	}
+	if err := h.store.UpdateServiceImage(ctx, service.ID, deployment.ImageTag); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to update service image")
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 90)
+
+	h.ensureSidecars(ctx, service, containers.Sidecars, newID)
+	h.replaceReplicas(ctx, service, containerSpec, labels)
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Str("container_id", newID).
+		Str("old_container_id", oldID).
+		Msg("service container replaced")
+
+	return nil
+}
+
+// rolloutBlueGreen starts the new container next to the old one and switches the nginx upstream to it
+func (h *DeployJobHandler) rolloutBlueGreen(ctx context.Context, job *Job, deployment *store.Deployment, service *store.Service, containers *store.ServiceContainers) error {
+	labels := dockerx.ServiceLabels(*service)
+	labels["glinr.deployment_id"] = strconv.FormatInt(deployment.ID, 10)
+	containerSpec := dockerx.ServiceContainerSpec(*service, deployment.ImageTag)
+
+	newID, err := h.blueGreen.DeployService(ctx, *service, containers, containerSpec, labels, h.sidecars, h.replicas)
+	if err != nil {
+		// The old container kept serving, retrying the same image will not help
+		return Permanent(err)
+	}
+
+	h.queue.UpdateJobProgress(job.ID, 90)
+
+	if err := h.store.UpdateServiceImage(ctx, service.ID, deployment.ImageTag); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Msg("failed to update service image")
+	}
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Str("container_id", newID).
+		Msg("service switched to new container")
+
+	return nil
+}
+
+// replaceReplicas moves the service's additional replicas onto the deployed image.
+// The primary container already serves the new version, so failures are only logged.
+func (h *DeployJobHandler) replaceReplicas(ctx context.Context, service *store.Service, spec dockerx.ContainerSpec, labels map[string]string) {
+	if err := h.replicas.Replace(ctx, *service, spec, labels); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to replace service replicas")
+	}
+}
+
+// ensureSidecars attaches the service's sidecars to mainID. The container is
+// already serving, so failures are only logged.
+func (h *DeployJobHandler) ensureSidecars(ctx context.Context, service *store.Service, sidecars []store.ServiceContainer, mainID string) {
+	if mainID == "" {
+		return
+	}
+	if err := h.sidecars.Ensure(context.WithoutCancel(ctx), *service, sidecars, mainID); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to ensure service sidecars")
+	}
+}
+
+// stopSidecars stops the service's sidecars ahead of the container they are attached to
+func (h *DeployJobHandler) stopSidecars(ctx context.Context, service *store.Service) {
+	if err := h.sidecars.Stop(ctx, service.ID); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to stop service sidecars")
+	}
+}
+
+// abandon removes a replacement container that failed to come up and restarts the old one
+func (h *DeployJobHandler) abandon(ctx context.Context, newID, oldID string) {
+	// Clean up even if the job context is already cancelled
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	if err := h.engine.Remove(cleanupCtx, newID); err != nil {
+		log.Warn().Err(err).Str("container_id", newID).Msg("failed to remove failed replacement container")
+	}
+
+	if oldID == "" {
+		return
+	}
+
+	if err := h.engine.Start(cleanupCtx, oldID); err != nil {
+		log.Error().Err(err).Str("container_id", oldID).Msg("failed to restart previous container after failed deployment")
+		return
+	}
+
+	log.Info().Str("container_id", oldID).Msg("previous container restored after failed deployment")
+}
+
+// connectProjectNetwork attaches a container to its project network with the service aliases
+func (h *DeployJobHandler) connectProjectNetwork(ctx context.Context, service *store.Service, containerID string) {
+	project, err := h.store.GetProject(ctx, service.ProjectID)
+	if err != nil {
+		log.Warn().Err(err).Int64("project_id", service.ProjectID).Msg("failed to load project for networking")
+		return
+	}
+
+	networkName := store.GenerateProjectNetworkName(service.ProjectID)
+	if project.NetworkName != nil && *project.NetworkName != "" {
+		networkName = *project.NetworkName
+	}
+
+	networkLabels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(service.ProjectID, 10),
+		"glinr.managed":    "true",
+		"owner":            "glinrdock",
+	}
+	if err := h.engine.EnsureNetwork(ctx, networkName, networkLabels); err != nil {
+		log.Error().Err(err).Str("network", networkName).Msg("failed to ensure project network")
+		return
+	}
+
+	aliases := store.GenerateServiceAliases(project.Name, service.Name)
+	if err := h.engine.ConnectNetwork(ctx, networkName, containerID, aliases); err != nil {
+		log.Error().Err(err).Str("network", networkName).Str("container", containerID).Msg("failed to connect container to project network")
+	}
+}
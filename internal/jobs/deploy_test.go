@@ -0,0 +1,214 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEngine wraps MockEngine and records lifecycle calls
+type recordingEngine struct {
+	*dockerx.MockEngine
+	mu    sync.Mutex
+	calls []string
+}
+
+func newRecordingEngine() *recordingEngine {
+	engine := &recordingEngine{MockEngine: dockerx.NewMockEngine()}
+	engine.SetCreateID("new-container")
+	return engine
+}
+
+func (e *recordingEngine) record(call string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, call)
+}
+
+func (e *recordingEngine) Start(ctx context.Context, id string) error {
+	e.record("start " + id)
+	return e.MockEngine.Start(ctx, id)
+}
+
+func (e *recordingEngine) Stop(ctx context.Context, id string) error {
+	e.record("stop " + id)
+	return e.MockEngine.Stop(ctx, id)
+}
+
+func (e *recordingEngine) Remove(ctx context.Context, id string) error {
+	e.record("remove " + id)
+	return e.MockEngine.Remove(ctx, id)
+}
+
+func (e *recordingEngine) Rename(ctx context.Context, id string, newName string) error {
+	e.record("rename " + id + " " + newName)
+	return e.MockEngine.Rename(ctx, id, newName)
+}
+
+// fakeDeployStore is an in-memory DeployStore for tests
+type fakeDeployStore struct {
+	service     store.Service
+	statuses    []string
+	reason      *string
+	containerID string
+	image       string
+	maintenance *store.MaintenanceWindow
+}
+
+func (s *fakeDeployStore) GetService(ctx context.Context, serviceID int64) (store.Service, error) {
+	return s.service, nil
+}
+
+func (s *fakeDeployStore) GetProject(ctx context.Context, id int64) (store.Project, error) {
+	return store.Project{ID: id, Name: "demo"}, nil
+}
+
+func (s *fakeDeployStore) ListRoutes(ctx context.Context, serviceID int64) ([]store.Route, error) {
+	return nil, nil
+}
+
+func (s *fakeDeployStore) UpdateServiceImage(ctx context.Context, id int64, image string) error {
+	s.image = image
+	return nil
+}
+
+func (s *fakeDeployStore) UpdateServiceContainerID(ctx context.Context, id int64, containerID string) error {
+	s.containerID = containerID
+	return nil
+}
+
+func (s *fakeDeployStore) CreateDeployment(ctx context.Context, deployment *store.Deployment) error {
+	return nil
+}
+
+func (s *fakeDeployStore) UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error {
+	s.statuses = append(s.statuses, status)
+	s.reason = reason
+	return nil
+}
+
+func (s *fakeDeployStore) GetServiceContainers(ctx context.Context, serviceID int64) (*store.ServiceContainers, error) {
+	return &store.ServiceContainers{}, nil
+}
+
+func (s *fakeDeployStore) ActiveMaintenanceWindow(ctx context.Context, projectID int64, at time.Time) (*store.MaintenanceWindow, error) {
+	if s.maintenance == nil {
+		return nil, store.ErrNotFound
+	}
+	return s.maintenance, nil
+}
+
+// staticProber always returns the same probe result
+type staticProber struct {
+	result health.ProbeResult
+}
+
+func (p staticProber) ProbeService(ctx context.Context, service *store.Service, routes []store.Route) health.ProbeResult {
+	return p.result
+}
+
+func newDeployFixture(result health.ProbeResult) (*DeployJobHandler, *recordingEngine, *fakeDeployStore, *Job) {
+	oldID := "old-container"
+	engine := newRecordingEngine()
+	deployStore := &fakeDeployStore{
+		service: store.Service{ID: 3, ProjectID: 1, Name: "api", Image: "api:v1", ContainerID: &oldID},
+	}
+
+	handler := NewDeployJobHandler(engine, staticProber{result: result}, deployStore, NewQueue(1))
+	handler.healthTimeout = 30 * time.Millisecond
+	handler.healthInterval = 5 * time.Millisecond
+
+	job := &Job{
+		ID:   "deploy-job",
+		Type: JobTypeDeploy,
+		Data: map[string]interface{}{
+			"deployment": &store.Deployment{ID: 9, ServiceID: 3, ImageTag: "api:v2"},
+		},
+	}
+	return handler, engine, deployStore, job
+}
+
+func TestDeployJobHandler_ReplacesContainer(t *testing.T) {
+	handler, engine, deployStore, job := newDeployFixture(health.ProbeResult{Status: store.HealthStatusOK})
+
+	require.NoError(t, handler.Handle(context.Background(), job))
+
+	assert.Equal(t, []string{
+		"stop old-container",
+		"start new-container",
+		"remove old-container",
+		"rename new-container glinr_1_api",
+	}, engine.calls)
+	assert.Equal(t, "new-container", deployStore.containerID)
+	assert.Equal(t, "api:v2", deployStore.image)
+	assert.Equal(t, []string{"deploying", "success"}, deployStore.statuses)
+}
+
+func TestDeployJobHandler_UnhealthyContainerRestoresOld(t *testing.T) {
+	handler, engine, deployStore, job := newDeployFixture(health.ProbeResult{Status: store.HealthStatusFail, Error: errors.New("HTTP 500")})
+
+	err := handler.Handle(context.Background(), job)
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+
+	assert.Equal(t, []string{
+		"stop old-container",
+		"start new-container",
+		"remove new-container",
+		"start old-container",
+	}, engine.calls)
+	assert.Empty(t, deployStore.containerID)
+	assert.Equal(t, []string{"deploying", "failed"}, deployStore.statuses)
+	require.NotNil(t, deployStore.reason)
+	assert.Contains(t, *deployStore.reason, "HTTP 500")
+}
+
+func TestDeployJobHandler_PullFailureIsRetryable(t *testing.T) {
+	handler, engine, deployStore, job := newDeployFixture(health.ProbeResult{Status: store.HealthStatusOK})
+	engine.SetPullError(errors.New("registry unavailable"))
+
+	err := handler.Handle(context.Background(), job)
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err))
+	assert.Empty(t, engine.calls)
+	assert.Equal(t, []string{"deploying", "failed"}, deployStore.statuses)
+}
+
+func TestDeployJobHandler_TaskUpdatesImageOnly(t *testing.T) {
+	handler, engine, deployStore, job := newDeployFixture(health.ProbeResult{Status: store.HealthStatusOK})
+	deployStore.service.Kind = store.ServiceKindTask
+	deployStore.service.ContainerID = nil
+
+	require.NoError(t, handler.Handle(context.Background(), job))
+
+	assert.Empty(t, engine.calls, "tasks have no container to replace")
+	assert.Equal(t, "api:v2", deployStore.image)
+	assert.Equal(t, []string{"deploying", "success"}, deployStore.statuses)
+}
+
+func TestDeployJobHandler_MaintenanceWindowFreezesDeploys(t *testing.T) {
+	handler, engine, deployStore, job := newDeployFixture(health.ProbeResult{Status: store.HealthStatusOK})
+	deployStore.maintenance = &store.MaintenanceWindow{ID: 4, Reason: "database migration"}
+
+	err := handler.Handle(context.Background(), job)
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+	assert.Empty(t, engine.calls)
+	assert.Equal(t, []string{"failed"}, deployStore.statuses)
+	require.NotNil(t, deployStore.reason)
+	assert.Equal(t, "project is frozen by maintenance window 4: database migration", *deployStore.reason)
+
+	// Rollbacks restore the previous image even during maintenance
+	deployStore.statuses = nil
+	job.Data["rollback"] = true
+	require.NoError(t, handler.Handle(context.Background(), job))
+	assert.Equal(t, []string{"deploying", "success"}, deployStore.statuses)
+}
@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -41,9 +43,9 @@ func NewBuildJobHandler(dockerRunner docker.Runner, buildStore BuildStore, logDi
 // Handle processes a build job
 func (h *BuildJobHandler) Handle(ctx context.Context, job *Job) error {
 	// Extract build data from job
-	buildData, ok := job.Data["build"].(*store.Build)
-	if !ok {
-		return fmt.Errorf("invalid build data in job")
+	buildData := &store.Build{}
+	if err := decodeJobData(job, "build", buildData); err != nil {
+		return Permanent(fmt.Errorf("invalid build data in job: %w", err))
 	}
 
 	// Update progress
@@ -59,7 +61,8 @@ func (h *BuildJobHandler) Handle(ctx context.Context, job *Job) error {
 
 	// Update build status to building
 	buildData.Status = "building"
-	if err := h.store.UpdateBuildStatus(ctx, buildData.ID, "building", &logPath, nil, nil); err != nil {
+	startedAt := time.Now().Unix()
+	if err := h.store.UpdateBuildStatus(ctx, buildData.ID, "building", &logPath, &startedAt, nil); err != nil {
 		log.Error().Err(err).Int64("build_id", buildData.ID).Msg("failed to update build status")
 	}
 
@@ -84,27 +87,23 @@ func (h *BuildJobHandler) Handle(ctx context.Context, job *Job) error {
 
 	h.queue.UpdateJobProgress(job.ID, 90)
 
-	// Update build status based on result
-	var status string
-	var finishedAt int64
+	// Update build status based on result. A cancelled job has interrupted
+	// buildx, and its context no longer reaches the store.
+	status := "success"
 	success := true
 	if err != nil || (result != nil && !result.Success) {
 		status = "failed"
 		success = false
-		if result != nil {
-			finishedAt = result.Duration.Nanoseconds()
-		}
-	} else {
-		status = "success"
-		if result != nil {
-			finishedAt = result.Duration.Nanoseconds()
+		if errors.Is(ctx.Err(), context.Canceled) {
+			status = "cancelled"
 		}
 	}
+	finishedAt := time.Now().Unix()
 
 	// Record build metrics
 	metrics.RecordBuild(success, buildDuration)
 
-	updateErr := h.store.UpdateBuildStatus(ctx, buildData.ID, status, &logPath, nil, &finishedAt)
+	updateErr := h.store.UpdateBuildStatus(context.WithoutCancel(ctx), buildData.ID, status, &logPath, nil, &finishedAt)
 	if updateErr != nil {
 		log.Error().Err(updateErr).Int64("build_id", buildData.ID).Msg("failed to update final build status")
 	}
@@ -124,113 +123,21 @@ func (h *BuildJobHandler) Handle(ctx context.Context, job *Job) error {
 	return nil
 }
 
-// DeployJobHandler handles deployment jobs
-type DeployJobHandler struct {
-	dockerRunner docker.Runner
-	store        DeployStore
-	queue        *Queue // For progress updates
-}
-
-// DeployStore interface for deployment-related database operations
-type DeployStore interface {
-	GetService(ctx context.Context, serviceID int64) (*store.Service, error)
-	UpdateService(ctx context.Context, serviceID int64, updates map[string]interface{}) error
-	CreateDeployment(ctx context.Context, deployment *store.Deployment) error
-	UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error
-}
-
-// NewDeployJobHandler creates a new deploy job handler
-func NewDeployJobHandler(dockerRunner docker.Runner, deployStore DeployStore, queue *Queue) *DeployJobHandler {
-	return &DeployJobHandler{
-		dockerRunner: dockerRunner,
-		store:        deployStore,
-		queue:        queue,
+// decodeJobData decodes job.Data[key] into out. Freshly enqueued jobs carry typed
+// values while jobs reloaded from the store carry plain JSON maps, so both are
+// handled by round-tripping through JSON.
+func decodeJobData(job *Job, key string, out interface{}) error {
+	value, ok := job.Data[key]
+	if !ok || value == nil {
+		return fmt.Errorf("missing %q", key)
 	}
-}
 
-// Handle processes a deployment job
-func (h *DeployJobHandler) Handle(ctx context.Context, job *Job) error {
-	// Extract deployment data from job
-	deployData, ok := job.Data["deployment"].(*store.Deployment)
-	if !ok {
-		return fmt.Errorf("invalid deployment data in job")
-	}
-
-	// Track deployment duration for metrics
-	deployStart := time.Now()
-	deploySuccess := true
-
-	h.queue.UpdateJobProgress(job.ID, 10)
-
-	// Get service details
-	_, err := h.store.GetService(ctx, deployData.ServiceID)
+	raw, err := json.Marshal(value)
 	if err != nil {
-		deploySuccess = false
-		metrics.RecordDeployment(deploySuccess, time.Since(deployStart))
-		return fmt.Errorf("failed to get service: %w", err)
-	}
-
-	h.queue.UpdateJobProgress(job.ID, 20)
-
-	// Update deployment status to deploying
-	deployData.Status = "deploying"
-	if err := h.store.UpdateDeploymentStatus(ctx, deployData.ID, "deploying", nil); err != nil {
-		log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status")
+		return err
 	}
 
-	h.queue.UpdateJobProgress(job.ID, 30)
-
-	// Check if the new image exists or pull it
-	imageExists, err := h.dockerRunner.ImageExists(ctx, deployData.ImageTag)
-	if err != nil {
-		return fmt.Errorf("failed to check image existence: %w", err)
-	}
-
-	if !imageExists {
-		log.Info().Str("image_tag", deployData.ImageTag).Msg("pulling image for deployment")
-		if err := h.dockerRunner.PullImage(ctx, deployData.ImageTag); err != nil {
-			reason := fmt.Sprintf("Failed to pull image: %v", err)
-			h.store.UpdateDeploymentStatus(ctx, deployData.ID, "failed", &reason)
-			deploySuccess = false
-			metrics.RecordDeployment(deploySuccess, time.Since(deployStart))
-			return fmt.Errorf("failed to pull image %s: %w", deployData.ImageTag, err)
-		}
-	}
-
-	h.queue.UpdateJobProgress(job.ID, 60)
-
-	// Update the service with the new image
-	updates := map[string]interface{}{
-		"image": deployData.ImageTag,
-	}
-
-	if err := h.store.UpdateService(ctx, deployData.ServiceID, updates); err != nil {
-		reason := fmt.Sprintf("Failed to update service: %v", err)
-		h.store.UpdateDeploymentStatus(ctx, deployData.ID, "failed", &reason)
-		deploySuccess = false
-		metrics.RecordDeployment(deploySuccess, time.Since(deployStart))
-		return fmt.Errorf("failed to update service: %w", err)
-	}
-
-	h.queue.UpdateJobProgress(job.ID, 90)
-
-	// Update deployment status to success
-	if err := h.store.UpdateDeploymentStatus(ctx, deployData.ID, "success", nil); err != nil {
-		log.Error().Err(err).Int64("deployment_id", deployData.ID).Msg("failed to update deployment status to success")
-	}
-
-	h.queue.UpdateJobProgress(job.ID, 100)
-
-	// Record successful deployment metrics
-	metrics.RecordDeployment(deploySuccess, time.Since(deployStart))
-
-	log.Info().
-		Int64("deployment_id", deployData.ID).
-		Int64("service_id", deployData.ServiceID).
-		Str("image_tag", deployData.ImageTag).
-		Msg("deployment completed successfully")
-
-	return nil
+	return json.Unmarshal(raw, out)
 }
 
 // LogWriter wraps an io.Writer to provide progress updates during builds
@@ -2,11 +2,14 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/GLINCKER/glinrdock/internal/metrics"
+	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/rs/zerolog/log"
 )
 
@@ -14,41 +17,122 @@ import (
 type JobType string
 
 const (
-	JobTypeBuild  JobType = "build"
-	JobTypeDeploy JobType = "deploy"
+	JobTypeBuild          JobType = "build"
+	JobTypeDeploy         JobType = "deploy"
+	JobTypeRollbackWatch  JobType = "rollback_watch"
+	JobTypeVolumeSnapshot JobType = "volume_snapshot"
+	JobTypeVolumeRestore  JobType = "volume_restore"
+	JobTypeTaskRun        JobType = "task_run"
 )
 
+// RecoveryPolicy decides what happens on startup to a job that was running when the process stopped
+type RecoveryPolicy string
+
+const (
+	RecoveryFail  RecoveryPolicy = "fail"  // Mark the interrupted job as failed
+	RecoveryRetry RecoveryPolicy = "retry" // Put the interrupted job back on the queue
+)
+
+// defaultRecoveryPolicies holds the recovery policy for known job types. Builds,
+// certificate jobs, rollback watches and snapshots are safe to run again; a
+// half-applied deploy or volume restore is not, so it is failed and left for an
+// operator to re-trigger. Task runs may not be idempotent, so an interrupted run
+// waits for its next activation.
+var defaultRecoveryPolicies = map[JobType]RecoveryPolicy{
+	JobTypeBuild:          RecoveryRetry,
+	JobTypeDeploy:         RecoveryFail,
+	JobTypeRollbackWatch:  RecoveryRetry,
+	JobTypeVolumeSnapshot: RecoveryRetry,
+	JobTypeVolumeRestore:  RecoveryFail,
+	JobTypeTaskRun:        RecoveryFail,
+	"cert_issue":          RecoveryRetry,
+	"cert_renew":          RecoveryRetry,
+}
+
 // JobStatus represents the status of a job
 type JobStatus string
 
 const (
-	JobStatusQueued  JobStatus = "queued"
-	JobStatusRunning JobStatus = "running"
-	JobStatusSuccess JobStatus = "success"
-	JobStatusFailed  JobStatus = "failed"
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSuccess   JobStatus = "success"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// Queue errors
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
 )
 
 // Job represents a background job
 type Job struct {
-	ID         string                 `json:"id"`
-	Type       JobType                `json:"type"`
-	Status     JobStatus              `json:"status"`
-	Data       map[string]interface{} `json:"data"`
-	Error      string                 `json:"error,omitempty"`
-	CreatedAt  time.Time              `json:"created_at"`
-	StartedAt  *time.Time             `json:"started_at,omitempty"`
-	FinishedAt *time.Time             `json:"finished_at,omitempty"`
-	Progress   int                    `json:"progress"` // 0-100
+	ID          string                 `json:"id"`
+	Type        JobType                `json:"type"`
+	Status      JobStatus              `json:"status"`
+	Data        map[string]interface{} `json:"data"`
+	Error       string                 `json:"error,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	StartedAt   *time.Time             `json:"started_at,omitempty"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+	Progress    int                    `json:"progress"` // 0-100
+	Attempts    int                    `json:"attempts"`
+	MaxAttempts int                    `json:"max_attempts"`
+	Timeout     time.Duration          `json:"-"`                     // Per-attempt time limit, timeout_seconds in JSON
+	Deadline    *time.Time             `json:"deadline,omitempty"`    // When the current attempt times out
+	NextRunAt   *time.Time             `json:"next_run_at,omitempty"` // When a retry becomes eligible to run
+
+	cancelRequested bool
+}
+
+// jobJSON is the wire form of a Job, with the timeout in whole seconds like the rest of the API
+type jobJSON struct {
+	jobFields
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// jobFields has the fields of Job without its methods
+type jobFields Job
+
+// MarshalJSON encodes a job with its timeout as timeout_seconds
+func (j Job) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jobJSON{jobFields: jobFields(j), TimeoutSeconds: int(j.Timeout / time.Second)})
+}
+
+// UnmarshalJSON decodes a job encoded by MarshalJSON
+func (j *Job) UnmarshalJSON(data []byte) error {
+	var decoded jobJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*j = Job(decoded.jobFields)
+	j.Timeout = time.Duration(decoded.TimeoutSeconds) * time.Second
+	return nil
 }
 
 // JobHandler is a function that processes a job
 type JobHandler func(ctx context.Context, job *Job) error
 
+// JobStore interface for persisting jobs across restarts
+type JobStore interface {
+	CreateJob(ctx context.Context, job *store.JobRecord) error
+	UpdateJob(ctx context.Context, job *store.JobRecord) error
+	GetJob(ctx context.Context, id string) (*store.JobRecord, error)
+	ListJobsByStatus(ctx context.Context, statuses ...string) ([]*store.JobRecord, error)
+	PruneJobs(ctx context.Context, keep int) error
+}
+
 // Queue manages background job processing
 type Queue struct {
 	jobs     map[string]*Job
-	jobsChan chan *Job
+	pending  []*Job        // FIFO of jobs waiting for a worker
+	notify   chan struct{} // Wakes an idle worker when pending is non-empty
 	handlers map[JobType]JobHandler
+	recovery map[JobType]RecoveryPolicy
+	retry    map[JobType]RetryPolicy
+	running  map[string]context.CancelFunc // Cancels the context of a running job
+	store    JobStore                      // Optional, nil keeps jobs in memory only
 	workers  int
 	mu       sync.RWMutex
 	ctx      context.Context
@@ -56,14 +140,33 @@ type Queue struct {
 	wg       sync.WaitGroup
 }
 
-// NewQueue creates a new job queue
+// NewQueue creates a new in-memory job queue
 func NewQueue(workers int) *Queue {
+	return NewPersistentQueue(workers, nil)
+}
+
+// NewPersistentQueue creates a new job queue that saves every job to the given store
+func NewPersistentQueue(workers int, jobStore JobStore) *Queue {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	recovery := make(map[JobType]RecoveryPolicy, len(defaultRecoveryPolicies))
+	for jobType, policy := range defaultRecoveryPolicies {
+		recovery[jobType] = policy
+	}
+
+	retry := make(map[JobType]RetryPolicy, len(defaultRetryPolicies))
+	for jobType, policy := range defaultRetryPolicies {
+		retry[jobType] = policy
+	}
+
 	return &Queue{
 		jobs:     make(map[string]*Job),
-		jobsChan: make(chan *Job, 100), // Buffer for 100 jobs
+		notify:   make(chan struct{}, 1),
 		handlers: make(map[JobType]JobHandler),
+		recovery: recovery,
+		retry:    retry,
+		running:  make(map[string]context.CancelFunc),
+		store:    jobStore,
 		workers:  workers,
 		ctx:      ctx,
 		cancel:   cancel,
@@ -77,6 +180,97 @@ func (q *Queue) RegisterHandler(jobType JobType, handler JobHandler) {
 	q.handlers[jobType] = handler
 }
 
+// SetRecoveryPolicy sets how interrupted jobs of the given type are handled on startup
+func (q *Queue) SetRecoveryPolicy(jobType JobType, policy RecoveryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.recovery[jobType] = policy
+}
+
+// SetRetryPolicy sets the retry policy applied to newly enqueued jobs of the given type
+func (q *Queue) SetRetryPolicy(jobType JobType, policy RetryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retry[jobType] = policy
+}
+
+// retryPolicy returns the retry policy for a job type. Callers must hold q.mu.
+func (q *Queue) retryPolicy(jobType JobType) RetryPolicy {
+	if policy, ok := q.retry[jobType]; ok {
+		return policy
+	}
+	return defaultRetryPolicy
+}
+
+// Recover reloads unfinished jobs from the store. Queued jobs are put back on the
+// queue; jobs that were running are retried or failed according to their type's
+// recovery policy. It must be called before Start.
+func (q *Queue) Recover(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+
+	records, err := q.store.ListJobsByStatus(ctx, string(JobStatusQueued), string(JobStatusRunning))
+	if err != nil {
+		return fmt.Errorf("failed to load unfinished jobs: %w", err)
+	}
+
+	var requeued, failed int
+	for _, record := range records {
+		job, err := jobFromRecord(record)
+		if err != nil {
+			log.Error().Err(err).Str("job_id", record.ID).Msg("skipping unreadable job")
+			continue
+		}
+
+		q.mu.Lock()
+		policy, ok := q.recovery[job.Type]
+		q.mu.Unlock()
+		if !ok {
+			policy = RecoveryFail
+		}
+
+		if job.Status == JobStatusRunning {
+			if policy == RecoveryFail || job.Attempts >= job.MaxAttempts {
+				now := time.Now()
+				job.Status = JobStatusFailed
+				job.Error = "interrupted by controller restart"
+				job.FinishedAt = &now
+
+				q.mu.Lock()
+				q.jobs[job.ID] = job
+				q.mu.Unlock()
+				q.persist(job)
+
+				failed++
+				log.Warn().Str("job_id", job.ID).Str("job_type", string(job.Type)).Msg("interrupted job marked failed")
+				continue
+			}
+
+			job.Status = JobStatusQueued
+			job.StartedAt = nil
+			job.Deadline = nil
+			job.Progress = 0
+			q.persist(job)
+		}
+
+		q.mu.Lock()
+		q.jobs[job.ID] = job
+		q.mu.Unlock()
+		q.schedule(job)
+		metrics.IncActiveJobs()
+		requeued++
+	}
+
+	if len(records) > 0 {
+		log.Info().Int("requeued", requeued).Int("failed", failed).Msg("recovered jobs from store")
+	}
+
+	q.prune()
+
+	return nil
+}
+
 // Start starts the job queue workers
 func (q *Queue) Start() {
 	log.Info().Int("workers", q.workers).Msg("starting job queue")
@@ -85,63 +279,156 @@ func (q *Queue) Start() {
 		q.wg.Add(1)
 		go q.worker(i)
 	}
+
+	// Jobs recovered before Start need a worker woken up for them
+	q.signal()
 }
 
-// Stop stops the job queue and waits for all workers to finish
+// Stop stops the job queue and waits for all workers to finish. Jobs still
+// waiting for a worker stay queued in the store and are picked up by Recover.
 func (q *Queue) Stop() {
 	log.Info().Msg("stopping job queue")
 
 	q.cancel()
-	close(q.jobsChan)
 	q.wg.Wait()
 
 	log.Info().Msg("job queue stopped")
 }
 
-// Enqueue adds a new job to the queue
-func (q *Queue) Enqueue(jobType JobType, data map[string]interface{}) *Job {
+// Enqueue adds a new job to the queue. With a store configured the job is only
+// scheduled once it has been saved, so it is never run without a durable record.
+func (q *Queue) Enqueue(jobType JobType, data map[string]interface{}) (*Job, error) {
+	q.mu.Lock()
+	policy := q.retryPolicy(jobType)
 	job := &Job{
-		ID:        generateJobID(),
-		Type:      jobType,
-		Status:    JobStatusQueued,
-		Data:      data,
-		CreatedAt: time.Now(),
-		Progress:  0,
+		ID:          generateJobID(),
+		Type:        jobType,
+		Status:      JobStatusQueued,
+		Data:        data,
+		CreatedAt:   time.Now(),
+		Progress:    0,
+		MaxAttempts: policy.MaxAttempts,
+		Timeout:     policy.Timeout,
+	}
+	q.mu.Unlock()
+
+	if q.store != nil {
+		record, err := job.record()
+		if err == nil {
+			err = q.store.CreateJob(context.Background(), record)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("job_id", job.ID).Str("job_type", string(jobType)).Msg("failed to persist job")
+			return nil, fmt.Errorf("failed to persist job: %w", err)
+		}
 	}
 
 	q.mu.Lock()
 	q.jobs[job.ID] = job
 	q.mu.Unlock()
 
-	// Send to workers
-	select {
-	case q.jobsChan <- job:
-		metrics.IncActiveJobs()
-		log.Info().Str("job_id", job.ID).Str("job_type", string(jobType)).Msg("job enqueued")
-	case <-q.ctx.Done():
-		// Queue is shutting down
+	// An in-memory queue has nowhere to keep work once it is shutting down
+	if q.store == nil && q.ctx.Err() != nil {
 		q.mu.Lock()
 		job.Status = JobStatusFailed
 		job.Error = "queue is shutting down"
 		q.mu.Unlock()
+		return job, nil
 	}
 
-	return job
+	q.schedule(job)
+
+	metrics.IncActiveJobs()
+	log.Info().Str("job_id", job.ID).Str("job_type", string(jobType)).Msg("job enqueued")
+
+	return job, nil
 }
 
-// GetJob returns a job by ID
+// GetJob returns a job by ID, falling back to the store for jobs from before a restart
 func (q *Queue) GetJob(id string) (*Job, bool) {
 	q.mu.RLock()
-	defer q.mu.RUnlock()
+	job, exists := q.jobs[id]
+	if exists {
+		// Return a copy to prevent race conditions
+		jobCopy := *job
+		q.mu.RUnlock()
+		return &jobCopy, true
+	}
+	q.mu.RUnlock()
 
+	if q.store == nil {
+		return nil, false
+	}
+
+	record, err := q.store.GetJob(context.Background(), id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Error().Err(err).Str("job_id", id).Msg("failed to load job from store")
+		}
+		return nil, false
+	}
+
+	job, err = jobFromRecord(record)
+	if err != nil {
+		log.Error().Err(err).Str("job_id", id).Msg("failed to decode stored job")
+		return nil, false
+	}
+
+	return job, true
+}
+
+// Cancel cancels a job. A queued job is cancelled immediately; a running job has
+// its context cancelled and is marked cancelled once its handler returns.
+func (q *Queue) Cancel(id string) (*Job, error) {
+	q.mu.Lock()
 	job, exists := q.jobs[id]
 	if !exists {
-		return nil, false
+		q.mu.Unlock()
+		// Jobs only known to the store finished before the last restart
+		if stored, ok := q.GetJob(id); ok {
+			return stored, ErrJobFinished
+		}
+		return nil, ErrJobNotFound
 	}
 
-	// Return a copy to prevent race conditions
-	jobCopy := *job
-	return &jobCopy, true
+	switch job.Status {
+	case JobStatusQueued:
+		for i, pending := range q.pending {
+			if pending == job {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		now := time.Now()
+		job.Status = JobStatusCancelled
+		job.Error = "cancelled by operator"
+		job.FinishedAt = &now
+		job.NextRunAt = nil
+		jobCopy := *job
+		q.mu.Unlock()
+
+		metrics.DecActiveJobs()
+		q.persist(job)
+		log.Info().Str("job_id", id).Msg("queued job cancelled")
+		return &jobCopy, nil
+
+	case JobStatusRunning:
+		job.cancelRequested = true
+		cancel := q.running[id]
+		jobCopy := *job
+		q.mu.Unlock()
+
+		if cancel != nil {
+			cancel()
+		}
+		log.Info().Str("job_id", id).Msg("running job cancellation requested")
+		return &jobCopy, nil
+
+	default:
+		jobCopy := *job
+		q.mu.Unlock()
+		return &jobCopy, ErrJobFinished
+	}
 }
 
 // ListJobs returns all jobs, optionally filtered by status
@@ -179,17 +466,84 @@ func (q *Queue) worker(workerID int) {
 	defer log.Info().Int("worker_id", workerID).Msg("worker stopped")
 
 	for {
-		select {
-		case job, ok := <-q.jobsChan:
-			if !ok {
-				// Channel closed, worker should exit
-				return
+		job := q.next()
+		if job == nil {
+			return
+		}
+		q.processJob(workerID, job)
+	}
+}
+
+// next blocks until a pending job is available or the queue is stopped
+func (q *Queue) next() *Job {
+	for {
+		if q.ctx.Err() != nil {
+			return nil
+		}
+
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			job := q.pending[0]
+			q.pending = q.pending[1:]
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+
+			// Pass the wake-up on so other idle workers pick up the rest
+			if more {
+				q.signal()
 			}
-			q.processJob(workerID, job)
+			return job
+		}
+		q.mu.Unlock()
 
+		select {
+		case <-q.notify:
 		case <-q.ctx.Done():
+			return nil
+		}
+	}
+}
+
+// schedule hands a queued job to the workers, waiting for its NextRunAt first if set
+func (q *Queue) schedule(job *Job) {
+	q.mu.RLock()
+	var delay time.Duration
+	if job.NextRunAt != nil {
+		delay = time.Until(*job.NextRunAt)
+	}
+	q.mu.RUnlock()
+
+	enqueue := func() {
+		q.mu.Lock()
+		// The job may have been cancelled while waiting for its backoff
+		if job.Status != JobStatusQueued {
+			q.mu.Unlock()
 			return
 		}
+		q.pending = append(q.pending, job)
+		q.mu.Unlock()
+		q.signal()
+	}
+
+	if delay <= 0 {
+		enqueue()
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		// Delayed retries left over at shutdown stay queued in the store
+		if q.ctx.Err() != nil {
+			return
+		}
+		enqueue()
+	})
+}
+
+// signal wakes one idle worker without blocking
+func (q *Queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
 	}
 }
 
@@ -201,13 +555,38 @@ func (q *Queue) processJob(workerID int, job *Job) {
 		Str("job_type", string(job.Type)).
 		Msg("processing job")
 
-	// Update job status
+	// Update job status, unless it was cancelled after leaving the pending list
 	q.mu.Lock()
-	job.Status = JobStatusRunning
+	if job.Status != JobStatusQueued {
+		q.mu.Unlock()
+		return
+	}
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = defaultRetryPolicy.Timeout
+	}
 	now := time.Now()
+	deadline := now.Add(timeout)
+	job.Status = JobStatusRunning
 	job.StartedAt = &now
+	job.FinishedAt = nil
+	job.Deadline = &deadline
+	job.NextRunAt = nil
 	job.Progress = 0
+	job.Attempts++
+
+	// Execute job with a per-attempt deadline
+	ctx, cancel := context.WithDeadline(q.ctx, deadline)
+	q.running[job.ID] = cancel
 	q.mu.Unlock()
+	q.persist(job)
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel()
+	}()
 
 	// Get handler
 	q.mu.RLock()
@@ -215,20 +594,80 @@ func (q *Queue) processJob(workerID int, job *Job) {
 	q.mu.RUnlock()
 
 	if !exists {
-		q.finishJob(job, fmt.Errorf("no handler registered for job type: %s", job.Type))
+		q.finishJob(job, Permanent(fmt.Errorf("no handler registered for job type: %s", job.Type)))
 		return
 	}
 
-	// Execute job with timeout
-	ctx, cancel := context.WithTimeout(q.ctx, 30*time.Minute)
-	defer cancel()
-
 	err := handler(ctx, job)
-	q.finishJob(job, err)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("job exceeded its %s time limit: %w", timeout, err)
+	}
+
+	q.mu.RLock()
+	cancelled := job.cancelRequested
+	q.mu.RUnlock()
+
+	switch {
+	case cancelled:
+		q.cancelJob(job)
+	case err != nil && q.store != nil && q.ctx.Err() != nil:
+		// Interrupted by shutdown: leave the job running in the store so that
+		// Recover applies its type's policy on the next start
+		metrics.DecActiveJobs()
+		log.Warn().Str("job_id", job.ID).Str("job_type", string(job.Type)).Msg("job interrupted by shutdown")
+	case err != nil && !IsPermanent(err) && job.Attempts < job.MaxAttempts && q.ctx.Err() == nil:
+		q.retryJob(job, err)
+	default:
+		q.finishJob(job, err)
+	}
+}
+
+// retryJob puts a failed job back on the queue after its backoff delay
+func (q *Queue) retryJob(job *Job, err error) {
+	q.mu.Lock()
+	delay := q.retryPolicy(job.Type).Backoff(job.Attempts + 1)
+	nextRunAt := time.Now().Add(delay)
+	job.Status = JobStatusQueued
+	job.Error = err.Error()
+	job.Deadline = nil
+	job.NextRunAt = &nextRunAt
+	job.Progress = 0
+	attempts, maxAttempts := job.Attempts, job.MaxAttempts
+	q.mu.Unlock()
+
+	log.Warn().
+		Err(err).
+		Str("job_id", job.ID).
+		Str("job_type", string(job.Type)).
+		Int("attempt", attempts).
+		Int("max_attempts", maxAttempts).
+		Dur("backoff", delay).
+		Msg("job failed, scheduling retry")
+
+	q.persist(job)
+	q.schedule(job)
+}
+
+// cancelJob marks a running job as cancelled after its handler has returned
+func (q *Queue) cancelJob(job *Job) {
+	q.mu.Lock()
+	now := time.Now()
+	job.Status = JobStatusCancelled
+	job.Error = "cancelled by operator"
+	job.FinishedAt = &now
+	q.mu.Unlock()
+
+	metrics.DecActiveJobs()
+	q.persist(job)
+	q.prune()
+	log.Info().Str("job_id", job.ID).Str("job_type", string(job.Type)).Msg("job cancelled")
 }
 
 // finishJob marks a job as finished and updates its status
 func (q *Queue) finishJob(job *Job, err error) {
+	defer q.prune()
+	defer q.persist(job)
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -249,6 +688,7 @@ func (q *Queue) finishJob(job *Job, err error) {
 			Msg("job failed")
 	} else {
 		job.Status = JobStatusSuccess
+		job.Error = ""
 		log.Info().
 			Str("job_id", job.ID).
 			Str("job_type", string(job.Type)).
@@ -257,6 +697,95 @@ func (q *Queue) finishJob(job *Job, err error) {
 	}
 }
 
+// persist saves the current state of a job to the store, if one is configured
+func (q *Queue) persist(job *Job) {
+	if q.store == nil {
+		return
+	}
+
+	q.mu.RLock()
+	record, err := job.record()
+	q.mu.RUnlock()
+	if err == nil {
+		// Use a fresh context so final states are still written during shutdown
+		err = q.store.UpdateJob(context.Background(), record)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("job_id", job.ID).Msg("failed to persist job state")
+	}
+}
+
+// prune drops the oldest finished jobs from the store beyond store.JobRetention
+func (q *Queue) prune() {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.PruneJobs(context.Background(), store.JobRetention); err != nil {
+		log.Warn().Err(err).Msg("failed to prune finished jobs")
+	}
+}
+
+// record converts a job into its persisted form
+func (j *Job) record() (*store.JobRecord, error) {
+	data, err := json.Marshal(j.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job data: %w", err)
+	}
+
+	record := &store.JobRecord{
+		ID:             j.ID,
+		Type:           string(j.Type),
+		Status:         string(j.Status),
+		Data:           string(data),
+		Progress:       j.Progress,
+		Attempts:       j.Attempts,
+		MaxAttempts:    j.MaxAttempts,
+		TimeoutSeconds: int(j.Timeout / time.Second),
+		CreatedAt:      j.CreatedAt,
+		StartedAt:      j.StartedAt,
+		FinishedAt:     j.FinishedAt,
+		Deadline:       j.Deadline,
+		NextRunAt:      j.NextRunAt,
+	}
+	if j.Error != "" {
+		errMsg := j.Error
+		record.Error = &errMsg
+	}
+
+	return record, nil
+}
+
+// jobFromRecord rebuilds a job from its persisted form. Data values come back as
+// generic JSON values; handlers decode them with decodeJobData.
+func jobFromRecord(record *store.JobRecord) (*Job, error) {
+	job := &Job{
+		ID:          record.ID,
+		Type:        JobType(record.Type),
+		Status:      JobStatus(record.Status),
+		Data:        make(map[string]interface{}),
+		CreatedAt:   record.CreatedAt,
+		StartedAt:   record.StartedAt,
+		FinishedAt:  record.FinishedAt,
+		Progress:    record.Progress,
+		Attempts:    record.Attempts,
+		MaxAttempts: record.MaxAttempts,
+		Timeout:     time.Duration(record.TimeoutSeconds) * time.Second,
+		Deadline:    record.Deadline,
+		NextRunAt:   record.NextRunAt,
+	}
+	if record.Error != nil {
+		job.Error = *record.Error
+	}
+
+	if record.Data != "" {
+		if err := json.Unmarshal([]byte(record.Data), &job.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode job data: %w", err)
+		}
+	}
+
+	return job, nil
+}
+
 // generateJobID generates a unique job ID
 func generateJobID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
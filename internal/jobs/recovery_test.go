@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryJobStore is an in-memory JobStore for tests
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]store.JobRecord
+}
+
+func newMemoryJobStore() *memoryJobStore {
+	return &memoryJobStore{jobs: make(map[string]store.JobRecord)}
+}
+
+func (m *memoryJobStore) CreateJob(ctx context.Context, job *store.JobRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+func (m *memoryJobStore) UpdateJob(ctx context.Context, job *store.JobRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; !ok {
+		return store.ErrNotFound
+	}
+	m.jobs[job.ID] = *job
+	return nil
+}
+
+func (m *memoryJobStore) GetJob(ctx context.Context, id string) (*store.JobRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &job, nil
+}
+
+func (m *memoryJobStore) ListJobsByStatus(ctx context.Context, statuses ...string) ([]*store.JobRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []*store.JobRecord
+	for _, job := range m.jobs {
+		for _, status := range statuses {
+			if job.Status == status {
+				jobCopy := job
+				result = append(result, &jobCopy)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryJobStore) PruneJobs(ctx context.Context, keep int) error {
+	return nil
+}
+
+// mustEnqueue unwraps a queued job where the queue cannot fail to store it
+func mustEnqueue(job *Job, err error) *Job {
+	if err != nil {
+		panic(err)
+	}
+	return job
+}
+
+func waitForStatus(t *testing.T, queue *Queue, id string, status JobStatus) *Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, ok := queue.GetJob(id); ok && job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := queue.GetJob(id)
+	require.FailNow(t, "job did not reach expected status", "job %s: want %s, got %+v", id, status, job)
+	return nil
+}
+
+func TestPersistentQueue_JobSurvivesRestart(t *testing.T) {
+	jobStore := newMemoryJobStore()
+
+	first := NewPersistentQueue(1, jobStore)
+	first.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error { return nil })
+	first.Start()
+
+	job, err := first.Enqueue(JobTypeBuild, map[string]interface{}{
+		"build": &store.Build{ID: 7, ImageTag: "app:latest"},
+	})
+	require.NoError(t, err)
+	waitForStatus(t, first, job.ID, JobStatusSuccess)
+	first.Stop()
+
+	// A fresh queue only knows about the job through the store
+	second := NewPersistentQueue(1, jobStore)
+	loaded, ok := second.GetJob(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobStatusSuccess, loaded.Status)
+
+	var build store.Build
+	require.NoError(t, decodeJobData(loaded, "build", &build))
+	assert.Equal(t, int64(7), build.ID)
+	assert.Equal(t, "app:latest", build.ImageTag)
+}
+
+// failingJobStore rejects every new job
+type failingJobStore struct {
+	*memoryJobStore
+}
+
+func (f failingJobStore) CreateJob(ctx context.Context, job *store.JobRecord) error {
+	return errors.New("disk full")
+}
+
+func TestPersistentQueue_EnqueueFailsWithoutRecord(t *testing.T) {
+	queue := NewPersistentQueue(1, failingJobStore{newMemoryJobStore()})
+	defer queue.Stop()
+
+	var calls int32
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Nil(t, job)
+	assert.Empty(t, queue.ListJobs(""))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func TestPersistentQueue_RecoverAppliesPolicy(t *testing.T) {
+	jobStore := newMemoryJobStore()
+	startedAt := time.Now().Add(-time.Minute)
+
+	jobStore.jobs["queued"] = store.JobRecord{ID: "queued", Type: string(JobTypeDeploy), Status: string(JobStatusQueued), Data: "{}"}
+	jobStore.jobs["build"] = store.JobRecord{ID: "build", Type: string(JobTypeBuild), Status: string(JobStatusRunning), Data: "{}", StartedAt: &startedAt, Attempts: 1, MaxAttempts: 3}
+	jobStore.jobs["deploy"] = store.JobRecord{ID: "deploy", Type: string(JobTypeDeploy), Status: string(JobStatusRunning), Data: "{}", StartedAt: &startedAt, Attempts: 1, MaxAttempts: 3}
+	jobStore.jobs["done"] = store.JobRecord{ID: "done", Type: string(JobTypeBuild), Status: string(JobStatusSuccess), Data: "{}"}
+
+	var mu sync.Mutex
+	var ran []string
+
+	queue := NewPersistentQueue(2, jobStore)
+	defer queue.Stop()
+	handler := func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		ran = append(ran, job.ID)
+		mu.Unlock()
+		return nil
+	}
+	queue.RegisterHandler(JobTypeBuild, handler)
+	queue.RegisterHandler(JobTypeDeploy, handler)
+
+	require.NoError(t, queue.Recover(context.Background()))
+	queue.Start()
+
+	waitForStatus(t, queue, "queued", JobStatusSuccess)
+	waitForStatus(t, queue, "build", JobStatusSuccess)
+
+	deploy := waitForStatus(t, queue, "deploy", JobStatusFailed)
+	assert.Contains(t, deploy.Error, "restart")
+
+	mu.Lock()
+	assert.ElementsMatch(t, []string{"queued", "build"}, ran)
+	mu.Unlock()
+}
+
+func TestPersistentQueue_EnqueueDoesNotBlockWhenBusy(t *testing.T) {
+	queue := NewPersistentQueue(1, newMemoryJobStore())
+	defer queue.Stop()
+
+	release := make(chan struct{})
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		<-release
+		return nil
+	})
+	queue.Start()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 250; i++ {
+			_, _ = queue.Enqueue(JobTypeBuild, map[string]interface{}{"index": i})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Enqueue blocked while workers were busy")
+	}
+	close(release)
+
+	assert.Len(t, queue.ListJobs(""), 250)
+}
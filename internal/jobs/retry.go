@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// RetryPolicy controls how often and how quickly a failed job is retried
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first, 1 disables retries
+	InitialBackoff time.Duration // Delay before the second attempt
+	MaxBackoff     time.Duration // Upper bound for the exponential delay
+	Timeout        time.Duration // Deadline for a single attempt
+}
+
+// defaultRetryPolicy is used for job types without an explicit policy
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    1,
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Timeout:        30 * time.Minute,
+}
+
+// defaultRetryPolicies holds the retry policy for known job types. Registry pulls
+// and ACME requests fail transiently often enough to be worth a few retries; ACME
+// rate limits in particular need minutes rather than seconds between attempts.
+var defaultRetryPolicies = map[JobType]RetryPolicy{
+	JobTypeBuild: {
+		MaxAttempts:    3,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Timeout:        30 * time.Minute,
+	},
+	JobTypeDeploy: {
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     2 * time.Minute,
+		Timeout:        15 * time.Minute,
+	},
+	JobTypeRollbackWatch: {
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        store.MaxRollbackWindow*time.Second + 10*time.Minute,
+	},
+	JobTypeVolumeSnapshot: {
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     10 * time.Minute,
+		Timeout:        2 * time.Hour,
+	},
+	JobTypeVolumeRestore: {
+		MaxAttempts:    1,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     10 * time.Minute,
+		Timeout:        2 * time.Hour,
+	},
+	JobTypeTaskRun: {
+		MaxAttempts:    1,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     10 * time.Minute,
+		Timeout:        store.MaxTaskTimeout*time.Second + 10*time.Minute,
+	},
+	"cert_issue": {
+		MaxAttempts:    5,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     30 * time.Minute,
+		Timeout:        10 * time.Minute,
+	},
+	"cert_renew": {
+		MaxAttempts:    5,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     30 * time.Minute,
+		Timeout:        10 * time.Minute,
+	},
+}
+
+// Backoff returns the delay before the given attempt number (2 for the first retry)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt <= 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+
+	delay := p.InitialBackoff
+	for i := 2; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// permanentError marks a job failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the queue fails the job without retrying it
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
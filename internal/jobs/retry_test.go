@@ -0,0 +1,172 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Duration(0), policy.Backoff(1))
+	assert.Equal(t, time.Second, policy.Backoff(2))
+	assert.Equal(t, 2*time.Second, policy.Backoff(3))
+	assert.Equal(t, 4*time.Second, policy.Backoff(4))
+	assert.Equal(t, 5*time.Second, policy.Backoff(5))
+	assert.Equal(t, 5*time.Second, policy.Backoff(50))
+}
+
+func TestQueue_RetriesTransientFailure(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	queue.SetRetryPolicy(JobTypeBuild, RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Timeout: time.Second})
+
+	var calls int32
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("registry pull timeout")
+		}
+		return nil
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+	done := waitForStatus(t, queue, job.ID, JobStatusSuccess)
+
+	assert.Equal(t, 3, done.Attempts)
+	assert.Equal(t, 3, done.MaxAttempts)
+	assert.Empty(t, done.Error)
+}
+
+func TestQueue_StopsAfterMaxAttempts(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	queue.SetRetryPolicy(JobTypeBuild, RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, Timeout: time.Second})
+
+	var calls int32
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("still broken")
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+	failed := waitForStatus(t, queue, job.ID, JobStatusFailed)
+
+	assert.Equal(t, 2, failed.Attempts)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, "still broken", failed.Error)
+}
+
+func TestQueue_PermanentErrorIsNotRetried(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	queue.SetRetryPolicy(JobTypeBuild, RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, Timeout: time.Second})
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		return Permanent(errors.New("invalid build data"))
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+	failed := waitForStatus(t, queue, job.ID, JobStatusFailed)
+
+	assert.Equal(t, 1, failed.Attempts)
+}
+
+func TestQueue_AttemptTimeout(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	queue.SetRetryPolicy(JobTypeBuild, RetryPolicy{MaxAttempts: 1, Timeout: 20 * time.Millisecond})
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+	failed := waitForStatus(t, queue, job.ID, JobStatusFailed)
+
+	assert.Contains(t, failed.Error, "time limit")
+	require.NotNil(t, failed.Deadline)
+}
+
+func TestQueue_CancelRunningJob(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	started := make(chan struct{})
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	queue.Start()
+
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+	<-started
+
+	_, err = queue.Cancel(job.ID)
+	require.NoError(t, err)
+
+	cancelled := waitForStatus(t, queue, job.ID, JobStatusCancelled)
+	assert.Equal(t, 1, cancelled.Attempts)
+
+	_, err = queue.Cancel(job.ID)
+	assert.ErrorIs(t, err, ErrJobFinished)
+}
+
+func TestQueue_CancelQueuedJob(t *testing.T) {
+	queue := NewQueue(1)
+	defer queue.Stop()
+
+	var calls int32
+	queue.RegisterHandler(JobTypeBuild, func(ctx context.Context, job *Job) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+
+	// Workers are not started, so the job stays queued
+	job, err := queue.Enqueue(JobTypeBuild, map[string]interface{}{})
+	require.NoError(t, err)
+
+	cancelled, err := queue.Cancel(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusCancelled, cancelled.Status)
+
+	queue.Start()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	_, err = queue.Cancel("missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestJob_JSONTimeoutSeconds(t *testing.T) {
+	job := Job{ID: "1", Type: JobTypeBuild, Status: JobStatusQueued, Timeout: 30 * time.Minute}
+
+	data, err := json.Marshal(job)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"timeout_seconds":1800`)
+	assert.NotContains(t, string(data), `"timeout":`)
+
+	var decoded Job
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 30*time.Minute, decoded.Timeout)
+	assert.Equal(t, JobTypeBuild, decoded.Type)
+}
@@ -0,0 +1,256 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/rollout"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultRollbackCheckInterval is the delay between checks while a deployment is watched
+	defaultRollbackCheckInterval = 10 * time.Second
+	// defaultRollbackFailureThreshold is how many failed probes in a row trigger a rollback
+	defaultRollbackFailureThreshold = 3
+)
+
+// RollbackStore interface for the database operations of the rollback watcher
+type RollbackStore interface {
+	GetService(ctx context.Context, id int64) (store.Service, error)
+	ListRoutes(ctx context.Context, serviceID int64) ([]store.Route, error)
+	CreateDeployment(ctx context.Context, deployment *store.Deployment) error
+	ListDeployments(ctx context.Context, serviceID int64) ([]*store.Deployment, error)
+	GetLatestDeployment(ctx context.Context, serviceID int64) (*store.Deployment, error)
+	UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error
+}
+
+// RollbackWatcher watches a service after a deployment and redeploys the
+// previous successful image if the service fails its health checks or enters
+// a crash loop within the service's rollback window. Each watch runs as a
+// persisted job so it survives a controller restart.
+type RollbackWatcher struct {
+	engine           rollout.Inspector
+	prober           ServiceProber
+	store            RollbackStore
+	queue            *Queue
+	auditLogger      *audit.Logger
+	interval         time.Duration
+	failureThreshold int
+}
+
+// rollbackWatch is the payload of a rollback watch job
+type rollbackWatch struct {
+	Deployment *store.Deployment `json:"deployment"`
+	WatchUntil time.Time         `json:"watch_until"`
+}
+
+// NewRollbackWatcher creates a new rollback watcher
+func NewRollbackWatcher(engine rollout.Inspector, prober ServiceProber, rollbackStore RollbackStore, queue *Queue, auditLogger *audit.Logger) *RollbackWatcher {
+	return &RollbackWatcher{
+		engine:           engine,
+		prober:           prober,
+		store:            rollbackStore,
+		queue:            queue,
+		auditLogger:      auditLogger,
+		interval:         defaultRollbackCheckInterval,
+		failureThreshold: defaultRollbackFailureThreshold,
+	}
+}
+
+// Watch starts watching a successful deployment for the given window
+func (w *RollbackWatcher) Watch(deployment *store.Deployment, window time.Duration) (*Job, error) {
+	log.Info().
+		Int64("deployment_id", deployment.ID).
+		Int64("service_id", deployment.ServiceID).
+		Dur("window", window).
+		Msg("watching deployment for automatic rollback")
+
+	return w.queue.Enqueue(JobTypeRollbackWatch, map[string]interface{}{
+		"watch": rollbackWatch{
+			Deployment: deployment,
+			WatchUntil: time.Now().Add(window),
+		},
+	})
+}
+
+// Handle processes a rollback watch job
+func (w *RollbackWatcher) Handle(ctx context.Context, job *Job) error {
+	var watch rollbackWatch
+	if err := decodeJobData(job, "watch", &watch); err != nil || watch.Deployment == nil {
+		return Permanent(fmt.Errorf("invalid rollback watch data in job: %v", err))
+	}
+	deployment := watch.Deployment
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		superseded, cause := w.check(ctx, deployment, &failures)
+		if superseded {
+			log.Info().Int64("deployment_id", deployment.ID).Msg("deployment superseded, rollback watch stopped")
+			return nil
+		}
+		if cause != "" {
+			return w.rollback(ctx, deployment, cause)
+		}
+
+		if !time.Now().Before(watch.WatchUntil) {
+			log.Info().Int64("deployment_id", deployment.ID).Msg("deployment stayed healthy through its rollback window")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// check inspects the service once. It reports whether a newer deployment took
+// over, or a non-empty cause when the deployment should be rolled back.
+func (w *RollbackWatcher) check(ctx context.Context, deployment *store.Deployment, failures *int) (bool, string) {
+	if latest, err := w.store.GetLatestDeployment(ctx, deployment.ServiceID); err == nil && latest.ID != deployment.ID {
+		return true, ""
+	}
+
+	service, err := w.store.GetService(ctx, deployment.ServiceID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", deployment.ServiceID).Msg("rollback watch could not load service")
+		return false, ""
+	}
+
+	// Set by the crash loop detector once the container keeps exiting
+	if service.CrashLooping {
+		return false, fmt.Sprintf("service entered crash loop after %d restarts", service.RestartCount)
+	}
+
+	if service.ContainerID != nil && *service.ContainerID != "" {
+		status, err := w.engine.Inspect(ctx, *service.ContainerID)
+		if err == nil && (status.State == "exited" || status.State == "dead") {
+			return false, fmt.Sprintf("container %s", status.State)
+		}
+	}
+
+	routes, err := w.store.ListRoutes(ctx, service.ID)
+	if err != nil {
+		// Routes are optional, probe through the host port instead
+		routes = []store.Route{}
+	}
+
+	result := w.prober.ProbeService(ctx, &service, routes)
+	if result.Status != store.HealthStatusFail {
+		*failures = 0
+		return false, ""
+	}
+
+	*failures++
+	log.Warn().
+		Err(result.Error).
+		Int64("service_id", service.ID).
+		Int("consecutive_failures", *failures).
+		Msg("health check failed during rollback window")
+
+	if *failures >= w.failureThreshold {
+		return false, fmt.Sprintf("health check failed %d times in a row: %v", *failures, result.Error)
+	}
+	return false, ""
+}
+
+// rollback marks the deployment rolled back and queues a deploy of the previous successful image
+func (w *RollbackWatcher) rollback(ctx context.Context, deployment *store.Deployment, cause string) error {
+	deployments, err := w.store.ListDeployments(ctx, deployment.ServiceID)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment history: %w", err)
+	}
+
+	// Deployments are listed newest first
+	var previous *store.Deployment
+	for _, candidate := range deployments {
+		if candidate.ID < deployment.ID && candidate.Status == "success" && candidate.ImageTag != deployment.ImageTag {
+			previous = candidate
+			break
+		}
+	}
+
+	serviceID := strconv.FormatInt(deployment.ServiceID, 10)
+
+	if previous == nil {
+		reason := fmt.Sprintf("deployment failed after rollout (%s), no previous successful deployment to roll back to", cause)
+		if err := w.store.UpdateDeploymentStatus(ctx, deployment.ID, "failed", &reason); err != nil {
+			log.Error().Err(err).Int64("deployment_id", deployment.ID).Msg("failed to update deployment status to failed")
+		}
+		w.record(ctx, serviceID, map[string]interface{}{
+			"deployment_id": deployment.ID,
+			"image_tag":     deployment.ImageTag,
+			"reason":        cause,
+			"rolled_back":   false,
+		})
+		return Permanent(fmt.Errorf("%s", reason))
+	}
+
+	reason := fmt.Sprintf("automatic rollback: %s", cause)
+	if err := w.store.UpdateDeploymentStatus(ctx, deployment.ID, "rolled_back", &reason); err != nil {
+		return fmt.Errorf("failed to mark deployment rolled back: %w", err)
+	}
+
+	rollbackReason := fmt.Sprintf("Automatic rollback to deployment %d: %s", previous.ID, cause)
+	rollbackDeployment := &store.Deployment{
+		ProjectID: deployment.ProjectID,
+		ServiceID: deployment.ServiceID,
+		ImageTag:  previous.ImageTag,
+		Status:    "queued",
+		Reason:    &rollbackReason,
+	}
+	if err := w.store.CreateDeployment(ctx, rollbackDeployment); err != nil {
+		return fmt.Errorf("failed to create rollback deployment: %w", err)
+	}
+
+	// Rollback deploys are not watched again, so a bad previous image cannot cause a loop
+	job, err := w.queue.Enqueue(JobTypeDeploy, map[string]interface{}{
+		"deployment": rollbackDeployment,
+		"rollback":   true,
+	})
+	if err != nil {
+		message := err.Error()
+		if statusErr := w.store.UpdateDeploymentStatus(ctx, rollbackDeployment.ID, "failed", &message); statusErr != nil {
+			log.Error().Err(statusErr).Int64("deployment_id", rollbackDeployment.ID).Msg("failed to mark rollback deployment failed")
+		}
+		return fmt.Errorf("failed to queue rollback deploy: %w", err)
+	}
+
+	w.record(ctx, serviceID, map[string]interface{}{
+		"deployment_id":          deployment.ID,
+		"rollback_deployment_id": rollbackDeployment.ID,
+		"rollback_to":            previous.ID,
+		"from_image_tag":         deployment.ImageTag,
+		"to_image_tag":           previous.ImageTag,
+		"reason":                 cause,
+		"job_id":                 job.ID,
+		"rolled_back":            true,
+	})
+
+	log.Warn().
+		Int64("deployment_id", deployment.ID).
+		Int64("rollback_deployment_id", rollbackDeployment.ID).
+		Str("from_image_tag", deployment.ImageTag).
+		Str("to_image_tag", previous.ImageTag).
+		Str("reason", cause).
+		Msg("deployment failed after rollout, rolling back")
+
+	return nil
+}
+
+// record writes an audit entry for an automatic rollback decision
+func (w *RollbackWatcher) record(ctx context.Context, serviceID string, meta map[string]interface{}) {
+	if w.auditLogger == nil {
+		return
+	}
+	w.auditLogger.RecordServiceAction(ctx, "system", audit.ActionServiceRollback, serviceID, meta)
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRollbackStore is an in-memory RollbackStore for tests
+type fakeRollbackStore struct {
+	service     store.Service
+	deployments []*store.Deployment
+}
+
+func (s *fakeRollbackStore) GetService(ctx context.Context, id int64) (store.Service, error) {
+	return s.service, nil
+}
+
+func (s *fakeRollbackStore) ListRoutes(ctx context.Context, serviceID int64) ([]store.Route, error) {
+	return nil, nil
+}
+
+func (s *fakeRollbackStore) CreateDeployment(ctx context.Context, deployment *store.Deployment) error {
+	deployment.ID = int64(len(s.deployments) + 1)
+	s.deployments = append(s.deployments, deployment)
+	return nil
+}
+
+func (s *fakeRollbackStore) ListDeployments(ctx context.Context, serviceID int64) ([]*store.Deployment, error) {
+	var deployments []*store.Deployment
+	for i := len(s.deployments) - 1; i >= 0; i-- {
+		deployments = append(deployments, s.deployments[i])
+	}
+	return deployments, nil
+}
+
+func (s *fakeRollbackStore) GetLatestDeployment(ctx context.Context, serviceID int64) (*store.Deployment, error) {
+	if len(s.deployments) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return s.deployments[len(s.deployments)-1], nil
+}
+
+func (s *fakeRollbackStore) UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error {
+	for _, deployment := range s.deployments {
+		if deployment.ID == deploymentID {
+			deployment.Status = status
+			if reason != nil {
+				deployment.Reason = reason
+			}
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func newRollbackFixture(result health.ProbeResult, history ...*store.Deployment) (*RollbackWatcher, *fakeRollbackStore, *Queue) {
+	containerID := "api-container"
+	rollbackStore := &fakeRollbackStore{
+		service: store.Service{ID: 3, ProjectID: 1, Name: "api", ContainerID: &containerID, AutoRollback: true},
+	}
+	for _, deployment := range history {
+		rollbackStore.CreateDeployment(context.Background(), deployment)
+	}
+
+	queue := NewQueue(1)
+	watcher := NewRollbackWatcher(dockerx.NewMockEngine(), staticProber{result: result}, rollbackStore, queue, nil)
+	watcher.interval = 5 * time.Millisecond
+	return watcher, rollbackStore, queue
+}
+
+// queuedDeployJob returns the deploy job waiting in the queue, if any
+func queuedDeployJob(queue *Queue) *Job {
+	for _, job := range queue.ListJobs(JobStatusQueued) {
+		if job.Type == JobTypeDeploy {
+			return job
+		}
+	}
+	return nil
+}
+
+func TestRollbackWatcher_HealthyDeploymentIsKept(t *testing.T) {
+	watcher, rollbackStore, queue := newRollbackFixture(health.ProbeResult{Status: store.HealthStatusOK},
+		&store.Deployment{ServiceID: 3, ImageTag: "api:v1", Status: "success"},
+		&store.Deployment{ServiceID: 3, ImageTag: "api:v2", Status: "success"},
+	)
+
+	job := mustEnqueue(watcher.Watch(rollbackStore.deployments[1], 20*time.Millisecond))
+	require.NoError(t, watcher.Handle(context.Background(), job))
+
+	assert.Equal(t, "success", rollbackStore.deployments[1].Status)
+	assert.Len(t, rollbackStore.deployments, 2)
+	assert.Nil(t, queuedDeployJob(queue))
+}
+
+func TestRollbackWatcher_FailingDeploymentRollsBack(t *testing.T) {
+	watcher, rollbackStore, queue := newRollbackFixture(health.ProbeResult{Status: store.HealthStatusFail, Error: errors.New("HTTP 503")},
+		&store.Deployment{ServiceID: 3, ImageTag: "api:v1", Status: "success"},
+		&store.Deployment{ServiceID: 3, ImageTag: "api:v2", Status: "success"},
+	)
+
+	job := mustEnqueue(watcher.Watch(rollbackStore.deployments[1], time.Minute))
+	require.NoError(t, watcher.Handle(context.Background(), job))
+
+	failed := rollbackStore.deployments[1]
+	assert.Equal(t, "rolled_back", failed.Status)
+	require.NotNil(t, failed.Reason)
+	assert.Contains(t, *failed.Reason, "HTTP 503")
+
+	require.Len(t, rollbackStore.deployments, 3)
+	rollback := rollbackStore.deployments[2]
+	assert.Equal(t, "api:v1", rollback.ImageTag)
+	assert.Equal(t, "queued", rollback.Status)
+
+	deployJob := queuedDeployJob(queue)
+	require.NotNil(t, deployJob)
+	assert.Equal(t, true, deployJob.Data["rollback"])
+}
+
+func TestRollbackWatcher_CrashLoopWithoutPreviousDeploymentFails(t *testing.T) {
+	watcher, rollbackStore, _ := newRollbackFixture(health.ProbeResult{Status: store.HealthStatusOK},
+		&store.Deployment{ServiceID: 3, ImageTag: "api:v1", Status: "success"},
+	)
+	rollbackStore.service.CrashLooping = true
+	rollbackStore.service.RestartCount = 5
+
+	job := mustEnqueue(watcher.Watch(rollbackStore.deployments[0], time.Minute))
+	err := watcher.Handle(context.Background(), job)
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+
+	assert.Equal(t, "failed", rollbackStore.deployments[0].Status)
+	require.NotNil(t, rollbackStore.deployments[0].Reason)
+	assert.Contains(t, *rollbackStore.deployments[0].Reason, "crash loop")
+	assert.Len(t, rollbackStore.deployments, 1)
+}
@@ -0,0 +1,471 @@
+package jobs
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/audit"
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// snapshotHelperImage runs the throwaway containers that mount a volume for archiving
+	snapshotHelperImage = "busybox:1.36"
+	// snapshotMountPath is where the helper container mounts the volume. Archives
+	// hold the volume's contents under this directory name.
+	snapshotMountPath = "/volume"
+	// defaultSnapshotScheduleInterval is how often backup schedules are checked
+	defaultSnapshotScheduleInterval = time.Minute
+)
+
+// SnapshotStore interface for the database operations of volume snapshots
+type SnapshotStore interface {
+	GetService(ctx context.Context, id int64) (store.Service, error)
+	GetBackupSchedule(ctx context.Context, serviceID int64) (*store.BackupSchedule, error)
+	ListBackupSchedules(ctx context.Context) ([]store.BackupSchedule, error)
+	MarkBackupScheduleRun(ctx context.Context, serviceID int64, at time.Time) error
+	CreateVolumeSnapshot(ctx context.Context, snapshot *store.VolumeSnapshot) error
+	GetVolumeSnapshot(ctx context.Context, id int64) (*store.VolumeSnapshot, error)
+	ListVolumeSnapshots(ctx context.Context, serviceID int64) ([]store.VolumeSnapshot, error)
+	DeleteVolumeSnapshot(ctx context.Context, id int64) error
+}
+
+// VolumeSnapshotter archives service volumes into the data directory and rolls
+// them back on request. Volumes are read and written through a short-lived
+// helper container that mounts the volume, so named volumes and bind mounts
+// are handled the same way and the service's own image needs no tools.
+type VolumeSnapshotter struct {
+	engine      dockerx.Engine
+	store       SnapshotStore
+	queue       *Queue
+	auditLogger *audit.Logger
+	dir         string
+	interval    time.Duration
+}
+
+// NewVolumeSnapshotter creates a volume snapshotter that keeps archives below dataDir/snapshots
+func NewVolumeSnapshotter(engine dockerx.Engine, snapshotStore SnapshotStore, queue *Queue, auditLogger *audit.Logger, dataDir string) *VolumeSnapshotter {
+	return &VolumeSnapshotter{
+		engine:      engine,
+		store:       snapshotStore,
+		queue:       queue,
+		auditLogger: auditLogger,
+		dir:         filepath.Join(dataDir, "snapshots"),
+		interval:    defaultSnapshotScheduleInterval,
+	}
+}
+
+// Snapshot queues a snapshot of every volume of a service
+func (s *VolumeSnapshotter) Snapshot(serviceID int64, quiesce string) (*Job, error) {
+	return s.queue.Enqueue(JobTypeVolumeSnapshot, map[string]interface{}{
+		"service_id": serviceID,
+		"quiesce":    quiesce,
+	})
+}
+
+// Restore queues rolling a volume back to a snapshot
+func (s *VolumeSnapshotter) Restore(snapshot *store.VolumeSnapshot) (*Job, error) {
+	return s.queue.Enqueue(JobTypeVolumeRestore, map[string]interface{}{
+		"snapshot_id": snapshot.ID,
+	})
+}
+
+// Path returns the location of a snapshot archive on disk
+func (s *VolumeSnapshotter) Path(snapshot *store.VolumeSnapshot) string {
+	return filepath.Join(s.dir, snapshot.FileName)
+}
+
+// Run queues snapshots for backup schedules as they fall due until ctx is cancelled
+func (s *VolumeSnapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Info().Msg("starting volume backup scheduler")
+
+	for {
+		s.runDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("stopping volume backup scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue queues a snapshot for every schedule that is due at now
+func (s *VolumeSnapshotter) runDue(ctx context.Context, now time.Time) {
+	schedules, err := s.store.ListBackupSchedules(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list backup schedules")
+		return
+	}
+
+	for _, schedule := range schedules {
+		if !schedule.Due(now) {
+			continue
+		}
+		// Mark the run first so a slow or failing snapshot is not queued again every tick
+		if err := s.store.MarkBackupScheduleRun(ctx, schedule.ServiceID, now); err != nil {
+			log.Error().Err(err).Int64("service_id", schedule.ServiceID).Msg("failed to mark backup schedule run")
+			continue
+		}
+		job, err := s.Snapshot(schedule.ServiceID, schedule.Quiesce)
+		if err != nil {
+			log.Error().Err(err).Int64("service_id", schedule.ServiceID).Msg("failed to queue scheduled volume snapshot")
+			continue
+		}
+		log.Info().Int64("service_id", schedule.ServiceID).Str("job_id", job.ID).Msg("scheduled volume snapshot queued")
+	}
+}
+
+// HandleSnapshot processes a volume snapshot job
+func (s *VolumeSnapshotter) HandleSnapshot(ctx context.Context, job *Job) error {
+	var serviceID int64
+	if err := decodeJobData(job, "service_id", &serviceID); err != nil {
+		return Permanent(fmt.Errorf("invalid snapshot data in job: %w", err))
+	}
+	quiesce := store.QuiesceNone
+	if _, ok := job.Data["quiesce"]; ok {
+		if err := decodeJobData(job, "quiesce", &quiesce); err != nil || !store.IsValidQuiesceMode(quiesce) {
+			return Permanent(fmt.Errorf("invalid quiesce mode in job: %v", job.Data["quiesce"]))
+		}
+	}
+
+	service, err := s.store.GetService(ctx, serviceID)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to load service %d: %w", serviceID, err))
+	}
+	if len(service.Volumes) == 0 {
+		return Permanent(fmt.Errorf("service %d has no volumes to snapshot", serviceID))
+	}
+
+	retention := store.DefaultBackupRetention
+	if schedule, err := s.store.GetBackupSchedule(ctx, serviceID); err == nil {
+		retention = schedule.Retention
+	}
+
+	if err := s.engine.Pull(ctx, snapshotHelperImage, ""); err != nil {
+		log.Warn().Err(err).Str("image", snapshotHelperImage).Msg("failed to pull snapshot helper image, continuing with local")
+	}
+
+	resume, err := s.quiesce(ctx, serviceID, quiesce)
+	if err != nil {
+		resume()
+		return fmt.Errorf("failed to quiesce service %d: %w", serviceID, err)
+	}
+
+	var snapshots []*store.VolumeSnapshot
+	for i, volume := range service.Volumes {
+		snapshot, err := s.snapshotVolume(ctx, job, service, volume)
+		if err != nil {
+			resume()
+			return fmt.Errorf("failed to snapshot volume %s: %w", volume.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+		s.queue.UpdateJobProgress(job.ID, (i+1)*90/len(service.Volumes))
+	}
+	resume()
+
+	for _, snapshot := range snapshots {
+		s.prune(ctx, serviceID, snapshot.Volume, retention)
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Int("volumes", len(snapshots)).
+		Str("quiesce", quiesce).
+		Msg("volume snapshot completed")
+
+	return nil
+}
+
+// snapshotVolume writes one volume into a gzip-compressed tar archive and records it
+func (s *VolumeSnapshotter) snapshotVolume(ctx context.Context, job *Job, service store.Service, volume store.VolumeMap) (*store.VolumeSnapshot, error) {
+	helperID, err := s.createHelper(ctx, job, volume, true, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer s.removeHelper(helperID)
+
+	archive, err := s.engine.CopyFrom(ctx, helperID, snapshotMountPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	createdAt := time.Now().UTC()
+	fileName := filepath.Join(
+		strconv.FormatInt(service.ID, 10),
+		volume.Name(),
+		createdAt.Format("20060102T150405.000000000Z")+".tar.gz",
+	)
+	size, err := writeGzipFile(filepath.Join(s.dir, fileName), archive)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &store.VolumeSnapshot{
+		ServiceID: service.ID,
+		Volume:    volume.Name(),
+		FileName:  fileName,
+		Size:      size,
+		CreatedAt: createdAt,
+	}
+	if err := s.store.CreateVolumeSnapshot(ctx, snapshot); err != nil {
+		os.Remove(filepath.Join(s.dir, fileName))
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// writeGzipFile compresses content into path through a temporary file, so an
+// interrupted snapshot never leaves a truncated archive behind
+func writeGzipFile(path string, content io.Reader) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return 0, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gzw := gzip.NewWriter(tmp)
+	if _, err := io.Copy(gzw, content); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	return info.Size(), nil
+}
+
+// prune deletes the oldest snapshots of a volume beyond the retention count
+func (s *VolumeSnapshotter) prune(ctx context.Context, serviceID int64, volume string, retention int) {
+	snapshots, err := s.store.ListVolumeSnapshots(ctx, serviceID)
+	if err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to list snapshots for retention")
+		return
+	}
+
+	// Snapshots are listed newest first
+	kept := 0
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if snapshot.Volume != volume {
+			continue
+		}
+		kept++
+		if kept <= retention {
+			continue
+		}
+		if err := os.Remove(s.Path(snapshot)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Int64("snapshot_id", snapshot.ID).Msg("failed to delete expired snapshot archive")
+			continue
+		}
+		if err := s.store.DeleteVolumeSnapshot(ctx, snapshot.ID); err != nil {
+			log.Warn().Err(err).Int64("snapshot_id", snapshot.ID).Msg("failed to delete expired snapshot record")
+		}
+	}
+}
+
+// HandleRestore processes a volume restore job. The service's containers are
+// stopped, the volume is emptied and the archive extracted into it, then the
+// containers that were running are started again.
+func (s *VolumeSnapshotter) HandleRestore(ctx context.Context, job *Job) error {
+	var snapshotID int64
+	if err := decodeJobData(job, "snapshot_id", &snapshotID); err != nil {
+		return Permanent(fmt.Errorf("invalid restore data in job: %w", err))
+	}
+
+	snapshot, err := s.store.GetVolumeSnapshot(ctx, snapshotID)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to load snapshot %d: %w", snapshotID, err))
+	}
+	service, err := s.store.GetService(ctx, snapshot.ServiceID)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to load service %d: %w", snapshot.ServiceID, err))
+	}
+
+	var volume *store.VolumeMap
+	for i := range service.Volumes {
+		if service.Volumes[i].Name() == snapshot.Volume {
+			volume = &service.Volumes[i]
+			break
+		}
+	}
+	if volume == nil {
+		return Permanent(fmt.Errorf("service %d no longer has volume %s", service.ID, snapshot.Volume))
+	}
+
+	archive, err := os.Open(s.Path(snapshot))
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to open snapshot archive: %w", err))
+	}
+	defer archive.Close()
+
+	if err := s.engine.Pull(ctx, snapshotHelperImage, ""); err != nil {
+		log.Warn().Err(err).Str("image", snapshotHelperImage).Msg("failed to pull snapshot helper image, continuing with local")
+	}
+
+	resume, err := s.quiesce(ctx, service.ID, store.QuiesceStop)
+	defer resume()
+	if err != nil {
+		return fmt.Errorf("failed to stop service %d: %w", service.ID, err)
+	}
+	s.queue.UpdateJobProgress(job.ID, 20)
+
+	// Empty the volume first so files created after the snapshot do not survive the restore
+	helperID, err := s.createHelper(ctx, job, *volume, false, []string{"find", snapshotMountPath, "-mindepth", "1", "-delete"})
+	if err != nil {
+		return err
+	}
+	defer s.removeHelper(helperID)
+
+	if err := s.engine.Start(ctx, helperID); err != nil {
+		return err
+	}
+	exitCode, err := s.engine.Wait(ctx, helperID)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to empty volume %s: helper exited with code %d", snapshot.Volume, exitCode)
+	}
+	s.queue.UpdateJobProgress(job.ID, 50)
+
+	if err := s.engine.CopyTo(ctx, helperID, "/", archive); err != nil {
+		return err
+	}
+	s.queue.UpdateJobProgress(job.ID, 90)
+
+	if s.auditLogger != nil {
+		s.auditLogger.RecordServiceAction(ctx, "system", audit.ActionVolumeRestore, strconv.FormatInt(service.ID, 10), map[string]interface{}{
+			"volume":      snapshot.Volume,
+			"snapshot_id": snapshot.ID,
+			"created_at":  snapshot.CreatedAt,
+			"job_id":      job.ID,
+		})
+	}
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Str("volume", snapshot.Volume).
+		Int64("snapshot_id", snapshot.ID).
+		Msg("volume restored from snapshot")
+
+	return nil
+}
+
+// createHelper creates a stopped helper container with the volume mounted at snapshotMountPath
+func (s *VolumeSnapshotter) createHelper(ctx context.Context, job *Job, volume store.VolumeMap, readOnly bool, command []string) (string, error) {
+	if command == nil {
+		command = []string{"true"}
+	}
+	spec := dockerx.ContainerSpec{
+		Image:   snapshotHelperImage,
+		Command: command,
+		Volumes: []store.VolumeMap{{Host: volume.Host, Container: snapshotMountPath, ReadOnly: readOnly}},
+	}
+	labels := map[string]string{
+		"glinr.managed": "true",
+		"glinr.helper":  "snapshot",
+	}
+
+	name := fmt.Sprintf("glinr_snapshot_%s_%s", job.ID, volume.Name())
+	id, err := s.engine.Create(ctx, name, spec, labels)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// removeHelper removes a helper container, even after the job's context is cancelled
+func (s *VolumeSnapshotter) removeHelper(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.engine.Remove(ctx, id); err != nil {
+		log.Warn().Err(err).Str("container_id", id).Msg("failed to remove snapshot helper container")
+	}
+}
+
+// quiesce pauses or stops the running containers of a service and returns a
+// function that resumes them. The returned function is safe to call more than once.
+func (s *VolumeSnapshotter) quiesce(ctx context.Context, serviceID int64, mode string) (func(), error) {
+	var touched []string
+	resumed := false
+	resume := func() {
+		if resumed {
+			return
+		}
+		resumed = true
+		resumeCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, id := range touched {
+			var err error
+			if mode == store.QuiescePause {
+				err = s.engine.Unpause(resumeCtx, id)
+			} else {
+				err = s.engine.Start(resumeCtx, id)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("container_id", id).Int64("service_id", serviceID).Msg("failed to resume container after volume snapshot")
+			}
+		}
+	}
+
+	if mode == store.QuiesceNone {
+		return resume, nil
+	}
+
+	containers, err := s.engine.List(ctx, map[string]string{
+		"glinr.service_id": strconv.FormatInt(serviceID, 10),
+		"glinr.managed":    "true",
+	})
+	if err != nil {
+		return resume, err
+	}
+
+	for _, container := range containers {
+		if container.State != "running" {
+			continue
+		}
+		if mode == store.QuiescePause {
+			err = s.engine.Pause(ctx, container.ID)
+		} else {
+			err = s.engine.Stop(ctx, container.ID)
+		}
+		if err != nil {
+			return resume, err
+		}
+		touched = append(touched, container.ID)
+	}
+
+	return resume, nil
+}
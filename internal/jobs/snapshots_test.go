@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSnapshotStore is an in-memory SnapshotStore for tests
+type fakeSnapshotStore struct {
+	service   store.Service
+	schedules []store.BackupSchedule
+	snapshots []store.VolumeSnapshot
+}
+
+func (s *fakeSnapshotStore) GetService(ctx context.Context, id int64) (store.Service, error) {
+	return s.service, nil
+}
+
+func (s *fakeSnapshotStore) GetBackupSchedule(ctx context.Context, serviceID int64) (*store.BackupSchedule, error) {
+	for i := range s.schedules {
+		if s.schedules[i].ServiceID == serviceID {
+			return &s.schedules[i], nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeSnapshotStore) ListBackupSchedules(ctx context.Context) ([]store.BackupSchedule, error) {
+	return s.schedules, nil
+}
+
+func (s *fakeSnapshotStore) MarkBackupScheduleRun(ctx context.Context, serviceID int64, at time.Time) error {
+	for i := range s.schedules {
+		if s.schedules[i].ServiceID == serviceID {
+			s.schedules[i].LastRunAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *fakeSnapshotStore) CreateVolumeSnapshot(ctx context.Context, snapshot *store.VolumeSnapshot) error {
+	snapshot.ID = int64(len(s.snapshots) + 1)
+	s.snapshots = append(s.snapshots, *snapshot)
+	return nil
+}
+
+func (s *fakeSnapshotStore) GetVolumeSnapshot(ctx context.Context, id int64) (*store.VolumeSnapshot, error) {
+	for i := range s.snapshots {
+		if s.snapshots[i].ID == id {
+			return &s.snapshots[i], nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeSnapshotStore) ListVolumeSnapshots(ctx context.Context, serviceID int64) ([]store.VolumeSnapshot, error) {
+	var snapshots []store.VolumeSnapshot
+	for i := len(s.snapshots) - 1; i >= 0; i-- {
+		snapshots = append(snapshots, s.snapshots[i])
+	}
+	return snapshots, nil
+}
+
+func (s *fakeSnapshotStore) DeleteVolumeSnapshot(ctx context.Context, id int64) error {
+	for i := range s.snapshots {
+		if s.snapshots[i].ID == id {
+			s.snapshots = append(s.snapshots[:i], s.snapshots[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func newSnapshotFixture(t *testing.T) (*VolumeSnapshotter, *fakeSnapshotStore, *dockerx.MockEngine, *Queue) {
+	snapshotStore := &fakeSnapshotStore{
+		service: store.Service{
+			ID:        4,
+			ProjectID: 1,
+			Name:      "db",
+			Volumes: []store.VolumeMap{
+				{Host: "pgdata", Container: "/var/lib/postgresql/data"},
+			},
+		},
+	}
+
+	engine := dockerx.NewMockEngine()
+	engine.SetArchive([]byte("volume/PG_VERSION"))
+
+	queue := NewQueue(1)
+	snapshotter := NewVolumeSnapshotter(engine, snapshotStore, queue, nil, t.TempDir())
+	return snapshotter, snapshotStore, engine, queue
+}
+
+func TestVolumeSnapshotter_SnapshotWritesArchive(t *testing.T) {
+	snapshotter, snapshotStore, _, _ := newSnapshotFixture(t)
+
+	job := mustEnqueue(snapshotter.Snapshot(4, store.QuiescePause))
+	require.NoError(t, snapshotter.HandleSnapshot(context.Background(), job))
+
+	require.Len(t, snapshotStore.snapshots, 1)
+	snapshot := snapshotStore.snapshots[0]
+	assert.Equal(t, "pgdata", snapshot.Volume)
+	assert.Equal(t, filepath.Join("4", "pgdata"), filepath.Dir(snapshot.FileName))
+
+	file, err := os.Open(snapshotter.Path(&snapshot))
+	require.NoError(t, err)
+	defer file.Close()
+
+	info, err := file.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), snapshot.Size)
+
+	gzr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	assert.Equal(t, "volume/PG_VERSION", string(content))
+}
+
+func TestVolumeSnapshotter_RetentionPrunesOldSnapshots(t *testing.T) {
+	snapshotter, snapshotStore, _, _ := newSnapshotFixture(t)
+	snapshotStore.schedules = []store.BackupSchedule{
+		{ServiceID: 4, Enabled: true, IntervalMinutes: 60, Retention: 2, Quiesce: store.QuiesceNone},
+	}
+
+	for i := 0; i < 3; i++ {
+		job := mustEnqueue(snapshotter.Snapshot(4, store.QuiesceNone))
+		require.NoError(t, snapshotter.HandleSnapshot(context.Background(), job))
+	}
+
+	require.Len(t, snapshotStore.snapshots, 2)
+	assert.Equal(t, int64(2), snapshotStore.snapshots[0].ID)
+	assert.Equal(t, int64(3), snapshotStore.snapshots[1].ID)
+
+	archives, err := filepath.Glob(filepath.Join(snapshotter.dir, "4", "pgdata", "*.tar.gz"))
+	require.NoError(t, err)
+	assert.Len(t, archives, 2)
+}
+
+func TestVolumeSnapshotter_ServiceWithoutVolumesFails(t *testing.T) {
+	snapshotter, snapshotStore, _, _ := newSnapshotFixture(t)
+	snapshotStore.service.Volumes = nil
+
+	err := snapshotter.HandleSnapshot(context.Background(), mustEnqueue(snapshotter.Snapshot(4, store.QuiesceNone)))
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+}
+
+func TestVolumeSnapshotter_RunDueQueuesScheduledSnapshots(t *testing.T) {
+	snapshotter, snapshotStore, _, queue := newSnapshotFixture(t)
+	now := time.Now()
+	recent := now.Add(-10 * time.Minute)
+	snapshotStore.schedules = []store.BackupSchedule{
+		{ServiceID: 4, Enabled: true, IntervalMinutes: 60, Retention: 7, Quiesce: store.QuiesceStop},
+		{ServiceID: 5, Enabled: true, IntervalMinutes: 60, Retention: 7, Quiesce: store.QuiesceNone, LastRunAt: &recent},
+	}
+
+	snapshotter.runDue(context.Background(), now)
+
+	queued := queue.ListJobs(JobStatusQueued)
+	require.Len(t, queued, 1)
+	assert.Equal(t, JobTypeVolumeSnapshot, queued[0].Type)
+	assert.Equal(t, int64(4), queued[0].Data["service_id"])
+	assert.Equal(t, store.QuiesceStop, queued[0].Data["quiesce"])
+
+	require.NotNil(t, snapshotStore.schedules[0].LastRunAt)
+	assert.True(t, snapshotStore.schedules[0].LastRunAt.Equal(now))
+}
+
+func TestVolumeSnapshotter_RestoreExtractsArchive(t *testing.T) {
+	snapshotter, snapshotStore, engine, _ := newSnapshotFixture(t)
+	engine.SetContainers([]dockerx.ContainerStatus{
+		{ID: "db-container", State: "running", Labels: map[string]string{"glinr.service_id": "4", "glinr.managed": "true"}},
+	})
+
+	require.NoError(t, snapshotter.HandleSnapshot(context.Background(), mustEnqueue(snapshotter.Snapshot(4, store.QuiesceNone))))
+	snapshot := snapshotStore.snapshots[0]
+
+	require.NoError(t, snapshotter.HandleRestore(context.Background(), mustEnqueue(snapshotter.Restore(&snapshot))))
+
+	archive, err := os.ReadFile(snapshotter.Path(&snapshot))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(archive, engine.Copied("/")))
+}
+
+func TestVolumeSnapshotter_RestoreFailsWhenHelperFails(t *testing.T) {
+	snapshotter, snapshotStore, engine, _ := newSnapshotFixture(t)
+
+	require.NoError(t, snapshotter.HandleSnapshot(context.Background(), mustEnqueue(snapshotter.Snapshot(4, store.QuiesceNone))))
+	snapshot := snapshotStore.snapshots[0]
+
+	engine.SetWaitResult(1, nil)
+	err := snapshotter.HandleRestore(context.Background(), mustEnqueue(snapshotter.Restore(&snapshot)))
+	require.Error(t, err)
+	assert.Nil(t, engine.Copied("/"))
+}
@@ -0,0 +1,445 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/metrics"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/GLINCKER/glinrdock/internal/util"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultTaskScheduleInterval is how often task schedules are checked
+const defaultTaskScheduleInterval = time.Minute
+
+// logDrainTimeout is how long the output of an exited container may take to arrive
+const logDrainTimeout = 30 * time.Second
+
+// TaskStore interface for the database operations of scheduled tasks
+type TaskStore interface {
+	GetService(ctx context.Context, id int64) (store.Service, error)
+	GetProject(ctx context.Context, id int64) (store.Project, error)
+	GetTaskConfig(ctx context.Context, serviceID int64) (*store.TaskConfig, error)
+	ListTaskConfigs(ctx context.Context) ([]store.TaskConfig, error)
+	MarkTaskScheduled(ctx context.Context, serviceID int64, at time.Time) error
+	CreateTaskRun(ctx context.Context, run *store.TaskRun) error
+	StartTaskRun(ctx context.Context, id int64, at time.Time) error
+	FinishTaskRun(ctx context.Context, run *store.TaskRun) error
+	GetTaskRun(ctx context.Context, id int64) (*store.TaskRun, error)
+	HasActiveTaskRun(ctx context.Context, serviceID int64) (bool, error)
+	FailInterruptedTaskRuns(ctx context.Context, reason string) (int64, error)
+	FailInterruptedServiceRuns(ctx context.Context, reason string) (int64, error)
+	PruneTaskRuns(ctx context.Context, serviceID int64, keep int) error
+}
+
+// TaskRunner starts scheduled task services on their cron schedule. Every run
+// is a job on the queue that creates a short-lived container from the service's
+// image, env, volumes and project network, waits for it to exit and records the
+// exit code, duration and the tail of its output. One-off commands of any
+// service run the same way, but directly rather than through the queue.
+type TaskRunner struct {
+	engine   dockerx.Engine
+	store    TaskStore
+	queue    *Queue
+	interval time.Duration
+}
+
+// NewTaskRunner creates a runner for scheduled task services
+func NewTaskRunner(engine dockerx.Engine, taskStore TaskStore, queue *Queue) *TaskRunner {
+	return &TaskRunner{
+		engine:   engine,
+		store:    taskStore,
+		queue:    queue,
+		interval: defaultTaskScheduleInterval,
+	}
+}
+
+// Trigger records a queued run of a task and queues the job that executes it
+func (r *TaskRunner) Trigger(ctx context.Context, serviceID int64, trigger string) (*store.TaskRun, *Job, error) {
+	run := &store.TaskRun{
+		ServiceID: serviceID,
+		Trigger:   trigger,
+		Status:    store.TaskRunQueued,
+	}
+	if err := r.store.CreateTaskRun(ctx, run); err != nil {
+		return nil, nil, err
+	}
+
+	job, err := r.queue.Enqueue(JobTypeTaskRun, map[string]interface{}{
+		"service_id": serviceID,
+		"run_id":     run.ID,
+	})
+	if err != nil {
+		message := err.Error()
+		run.Status = store.TaskRunFailed
+		run.Error = &message
+		if finishErr := r.store.FinishTaskRun(ctx, run); finishErr != nil {
+			log.Error().Err(finishErr).Int64("run_id", run.ID).Msg("failed to record task run")
+		}
+		return nil, nil, err
+	}
+	return run, job, nil
+}
+
+// RecoverRuns fails the task and one-off runs a previous process left running.
+// Task run jobs are failed by the queue's recovery, so this must run before the
+// queue starts.
+func (r *TaskRunner) RecoverRuns(ctx context.Context) {
+	failed, err := r.store.FailInterruptedTaskRuns(ctx, "interrupted by controller restart")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to recover task runs")
+	} else if failed > 0 {
+		log.Warn().Int64("runs", failed).Msg("interrupted task runs marked failed")
+	}
+
+	failed, err = r.store.FailInterruptedServiceRuns(ctx, "interrupted by controller restart")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to recover one-off runs")
+	} else if failed > 0 {
+		log.Warn().Int64("runs", failed).Msg("interrupted one-off runs marked failed")
+	}
+}
+
+// Run queues task runs as their schedules fall due until ctx is cancelled
+func (r *TaskRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	log.Info().Msg("starting task scheduler")
+
+	for {
+		r.runDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("stopping task scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue queues a run for every task whose schedule is due at now. A task whose
+// previous run is still queued or running skips the activation, so slow tasks
+// never pile up.
+func (r *TaskRunner) runDue(ctx context.Context, now time.Time) {
+	configs, err := r.store.ListTaskConfigs(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list task schedules")
+		return
+	}
+
+	for _, config := range configs {
+		if !config.Due(now) {
+			continue
+		}
+		// Mark the activation first so a failing task is not queued again every tick
+		if err := r.store.MarkTaskScheduled(ctx, config.ServiceID, now); err != nil {
+			log.Error().Err(err).Int64("service_id", config.ServiceID).Msg("failed to mark task scheduled")
+			continue
+		}
+
+		active, err := r.store.HasActiveTaskRun(ctx, config.ServiceID)
+		if err != nil {
+			log.Error().Err(err).Int64("service_id", config.ServiceID).Msg("failed to check active task runs")
+			continue
+		}
+		if active {
+			log.Warn().Int64("service_id", config.ServiceID).Msg("previous task run still active, skipping scheduled run")
+			continue
+		}
+
+		run, job, err := r.Trigger(ctx, config.ServiceID, store.TaskTriggerSchedule)
+		if err != nil {
+			log.Error().Err(err).Int64("service_id", config.ServiceID).Msg("failed to queue scheduled task run")
+			continue
+		}
+		log.Info().Int64("service_id", config.ServiceID).Int64("run_id", run.ID).Str("job_id", job.ID).Msg("scheduled task run queued")
+	}
+}
+
+// HandleRun processes a task run job
+func (r *TaskRunner) HandleRun(ctx context.Context, job *Job) error {
+	var serviceID, runID int64
+	if err := decodeJobData(job, "service_id", &serviceID); err != nil {
+		return Permanent(fmt.Errorf("invalid task run data in job: %w", err))
+	}
+	if err := decodeJobData(job, "run_id", &runID); err != nil {
+		return Permanent(fmt.Errorf("invalid task run data in job: %w", err))
+	}
+
+	run, err := r.store.GetTaskRun(ctx, runID)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to load task run %d: %w", runID, err))
+	}
+
+	startedAt := time.Now().UTC()
+	run.StartedAt = &startedAt
+	if err := r.store.StartTaskRun(ctx, run.ID, startedAt); err != nil {
+		log.Warn().Err(err).Int64("run_id", run.ID).Msg("failed to mark task run running")
+	}
+
+	exitCode, logs, runErr := r.execute(ctx, job, serviceID, run)
+
+	run.DurationMs = time.Since(startedAt).Milliseconds()
+	run.Logs = logs
+	run.Status = store.TaskRunSucceeded
+	if exitCode != nil {
+		run.ExitCode = exitCode
+	}
+	if runErr == nil && exitCode != nil && *exitCode != 0 {
+		runErr = fmt.Errorf("task exited with code %d", *exitCode)
+	}
+	if runErr != nil {
+		message := runErr.Error()
+		run.Status = store.TaskRunFailed
+		run.Error = &message
+	}
+
+	// Record the outcome even if the job context is already cancelled
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := r.store.FinishTaskRun(finishCtx, run); err != nil {
+		log.Error().Err(err).Int64("run_id", run.ID).Msg("failed to record task run")
+	}
+	if err := r.store.PruneTaskRuns(finishCtx, serviceID, store.TaskRunRetention); err != nil {
+		log.Warn().Err(err).Int64("service_id", serviceID).Msg("failed to prune task runs")
+	}
+	metrics.RecordTaskRun(run.Trigger, runErr == nil, time.Duration(run.DurationMs)*time.Millisecond)
+
+	if runErr != nil {
+		log.Warn().Err(runErr).Int64("service_id", serviceID).Int64("run_id", run.ID).Msg("task run failed")
+		// Runs are not retried, the next activation is the retry
+		return Permanent(runErr)
+	}
+
+	log.Info().
+		Int64("service_id", serviceID).
+		Int64("run_id", run.ID).
+		Int64("duration_ms", run.DurationMs).
+		Msg("task run completed")
+
+	return nil
+}
+
+// execute runs the task container to completion and returns its exit code, if
+// it got that far, and the tail of its output
+func (r *TaskRunner) execute(ctx context.Context, job *Job, serviceID int64, run *store.TaskRun) (*int, string, error) {
+	service, err := r.store.GetService(ctx, serviceID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load service %d: %w", serviceID, err)
+	}
+	if !service.IsTask() {
+		return nil, "", fmt.Errorf("service %d is not a scheduled task", serviceID)
+	}
+	config, err := r.store.GetTaskConfig(ctx, serviceID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load task schedule: %w", err)
+	}
+
+	var registryID string
+	if service.RegistryID != nil {
+		registryID = *service.RegistryID
+	}
+	if err := r.engine.Pull(ctx, service.Image, registryID); err != nil {
+		log.Warn().Err(err).Str("image", service.Image).Msg("failed to pull task image, continuing with local")
+	}
+
+	spec := dockerx.ContainerSpec{
+		Image:     service.Image,
+		Command:   config.Command,
+		Env:       service.Env,
+		Volumes:   service.Volumes,
+		Resources: service.Resources,
+	}
+	labels := map[string]string{
+		"glinr.project_id":  strconv.FormatInt(service.ProjectID, 10),
+		"glinr.task_id":     strconv.FormatInt(service.ID, 10),
+		"glinr.task_run_id": strconv.FormatInt(run.ID, 10),
+		"glinr.managed":     "true",
+	}
+
+	name := fmt.Sprintf("glinr_%d_%s_run_%d", service.ProjectID, service.Name, run.ID)
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	tail := util.NewTailBuffer(store.MaxTaskRunLogs)
+
+	r.queue.UpdateJobProgress(job.ID, 10)
+	exitCode, err := r.runContainer(ctx, service, name, spec, labels, timeout, tail)
+	r.queue.UpdateJobProgress(job.ID, 90)
+
+	return exitCode, tail.String(), err
+}
+
+// RunOneOff runs a command once in a new container with the service's image,
+// volumes and project network and the given env, without touching the service's
+// own container. Output is streamed to output as it is written and its tail is
+// recorded on the run, together with the exit code.
+func (r *TaskRunner) RunOneOff(ctx context.Context, service store.Service, run *store.ServiceRun, env map[string]string, timeout time.Duration, output io.Writer) error {
+	var registryID string
+	if service.RegistryID != nil {
+		registryID = *service.RegistryID
+	}
+	if err := r.engine.Pull(ctx, service.Image, registryID); err != nil {
+		log.Warn().Err(err).Str("image", service.Image).Msg("failed to pull image for one-off run, continuing with local")
+	}
+
+	spec := dockerx.ContainerSpec{
+		Image:     service.Image,
+		Command:   run.Command,
+		Env:       env,
+		Volumes:   service.Volumes,
+		Resources: service.Resources,
+	}
+	// No glinr.service_id label, the run must never be mistaken for the service's container
+	labels := map[string]string{
+		"glinr.project_id":    strconv.FormatInt(service.ProjectID, 10),
+		"glinr.oneoff_of":     strconv.FormatInt(service.ID, 10),
+		"glinr.oneoff_run_id": strconv.FormatInt(run.ID, 10),
+		"glinr.managed":       "true",
+	}
+
+	tail := util.NewTailBuffer(store.MaxTaskRunLogs)
+	if output != nil {
+		output = io.MultiWriter(tail, output)
+	} else {
+		output = tail
+	}
+
+	startedAt := time.Now()
+	name := fmt.Sprintf("glinr_%d_%s_oneoff_%d", service.ProjectID, service.Name, run.ID)
+	exitCode, runErr := r.runContainer(ctx, service, name, spec, labels, timeout, output)
+
+	run.DurationMs = time.Since(startedAt).Milliseconds()
+	run.Output = tail.String()
+	run.ExitCode = exitCode
+	run.Status = store.TaskRunSucceeded
+	if runErr == nil && exitCode != nil && *exitCode != 0 {
+		runErr = fmt.Errorf("command exited with code %d", *exitCode)
+	}
+	if runErr != nil {
+		message := runErr.Error()
+		run.Status = store.TaskRunFailed
+		run.Error = &message
+	}
+	return runErr
+}
+
+// runContainer creates a short-lived container on the service's project network,
+// streams its output to output until it exits or timeout passes, and removes it.
+// The exit code is returned if the container got that far.
+func (r *TaskRunner) runContainer(ctx context.Context, service store.Service, name string, spec dockerx.ContainerSpec, labels map[string]string, timeout time.Duration, output io.Writer) (*int, error) {
+	containerID, err := r.engine.Create(ctx, name, spec, labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+	defer r.removeContainer(containerID)
+
+	if err := r.connectProjectNetwork(ctx, service, containerID); err != nil {
+		return nil, err
+	}
+
+	if err := r.engine.Start(ctx, containerID); err != nil {
+		return nil, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	streamed := r.streamLogs(ctx, containerID, output)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	code, waitErr := r.engine.Wait(waitCtx, containerID)
+	if waitErr != nil {
+		stopCtx, stopCancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		if err := r.engine.Stop(stopCtx, containerID); err != nil {
+			log.Warn().Err(err).Str("container_id", containerID).Msg("failed to stop container")
+		}
+		stopCancel()
+		if errors.Is(waitCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			waitErr = fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+
+	// The log stream ends with the container; give it a moment to drain
+	select {
+	case <-streamed:
+	case <-time.After(logDrainTimeout):
+		log.Warn().Str("container_id", containerID).Msg("container output did not drain in time")
+	}
+
+	if waitErr != nil {
+		return nil, waitErr
+	}
+
+	exitCode := int(code)
+	return &exitCode, nil
+}
+
+// connectProjectNetwork attaches a task container to its project network so the
+// task reaches the project's services by their aliases. The container gets no
+// aliases of its own; nothing should route to a task.
+func (r *TaskRunner) connectProjectNetwork(ctx context.Context, service store.Service, containerID string) error {
+	project, err := r.store.GetProject(ctx, service.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to load project %d: %w", service.ProjectID, err)
+	}
+
+	networkName := store.GenerateProjectNetworkName(service.ProjectID)
+	if project.NetworkName != nil && *project.NetworkName != "" {
+		networkName = *project.NetworkName
+	}
+
+	networkLabels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(service.ProjectID, 10),
+		"glinr.managed":    "true",
+		"owner":            "glinrdock",
+	}
+	if err := r.engine.EnsureNetwork(ctx, networkName, networkLabels); err != nil {
+		return fmt.Errorf("failed to ensure project network: %w", err)
+	}
+	if err := r.engine.ConnectNetwork(ctx, networkName, containerID, nil); err != nil {
+		return fmt.Errorf("failed to connect task container to project network: %w", err)
+	}
+	return nil
+}
+
+// streamLogs follows a container's output into output. The returned channel is
+// closed when the stream ends, which is when the container exits.
+func (r *TaskRunner) streamLogs(ctx context.Context, containerID string, output io.Writer) <-chan struct{} {
+	done := make(chan struct{})
+	logsCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	go func() {
+		defer close(done)
+		defer cancel()
+
+		reader, err := r.engine.Logs(logsCtx, containerID, true)
+		if err != nil {
+			log.Warn().Err(err).Str("container_id", containerID).Msg("failed to follow container output")
+			return
+		}
+		defer reader.Close()
+
+		if _, err := stdcopy.StdCopy(output, output, reader); err != nil {
+			log.Warn().Err(err).Str("container_id", containerID).Msg("failed to read container output")
+		}
+	}()
+
+	return done
+}
+
+// removeContainer removes a finished container
+func (r *TaskRunner) removeContainer(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := r.engine.Remove(ctx, containerID); err != nil {
+		log.Warn().Err(err).Str("container_id", containerID).Msg("failed to remove container")
+	}
+}
@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskStore is an in-memory TaskStore for tests
+type fakeTaskStore struct {
+	service store.Service
+	config  store.TaskConfig
+	runs    []store.TaskRun
+}
+
+func (s *fakeTaskStore) GetService(ctx context.Context, id int64) (store.Service, error) {
+	return s.service, nil
+}
+
+func (s *fakeTaskStore) GetProject(ctx context.Context, id int64) (store.Project, error) {
+	return store.Project{ID: id, Name: "shop"}, nil
+}
+
+func (s *fakeTaskStore) GetTaskConfig(ctx context.Context, serviceID int64) (*store.TaskConfig, error) {
+	config := s.config
+	return &config, nil
+}
+
+func (s *fakeTaskStore) ListTaskConfigs(ctx context.Context) ([]store.TaskConfig, error) {
+	return []store.TaskConfig{s.config}, nil
+}
+
+func (s *fakeTaskStore) MarkTaskScheduled(ctx context.Context, serviceID int64, at time.Time) error {
+	s.config.LastScheduledAt = &at
+	return nil
+}
+
+func (s *fakeTaskStore) CreateTaskRun(ctx context.Context, run *store.TaskRun) error {
+	run.ID = int64(len(s.runs) + 1)
+	s.runs = append(s.runs, *run)
+	return nil
+}
+
+func (s *fakeTaskStore) StartTaskRun(ctx context.Context, id int64, at time.Time) error {
+	s.runs[id-1].Status = store.TaskRunRunning
+	return nil
+}
+
+func (s *fakeTaskStore) FinishTaskRun(ctx context.Context, run *store.TaskRun) error {
+	s.runs[run.ID-1] = *run
+	return nil
+}
+
+func (s *fakeTaskStore) GetTaskRun(ctx context.Context, id int64) (*store.TaskRun, error) {
+	if id < 1 || int(id) > len(s.runs) {
+		return nil, store.ErrNotFound
+	}
+	run := s.runs[id-1]
+	return &run, nil
+}
+
+func (s *fakeTaskStore) HasActiveTaskRun(ctx context.Context, serviceID int64) (bool, error) {
+	for _, run := range s.runs {
+		if !run.Finished() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeTaskStore) FailInterruptedTaskRuns(ctx context.Context, reason string) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeTaskStore) FailInterruptedServiceRuns(ctx context.Context, reason string) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeTaskStore) PruneTaskRuns(ctx context.Context, serviceID int64, keep int) error {
+	return nil
+}
+
+func newTaskFixture(t *testing.T) (*TaskRunner, *fakeTaskStore, *dockerx.MockEngine) {
+	updatedAt := time.Date(2026, 10, 16, 9, 58, 0, 0, time.UTC)
+	taskStore := &fakeTaskStore{
+		service: store.Service{
+			ID:        9,
+			ProjectID: 1,
+			Name:      "vacuum",
+			Image:     "postgres:16",
+			Kind:      store.ServiceKindTask,
+		},
+		config: store.TaskConfig{
+			ServiceID:      9,
+			Schedule:       "0 * * * *",
+			Command:        []string{"vacuumdb", "--all"},
+			TimeoutSeconds: 60,
+			UpdatedAt:      updatedAt,
+		},
+	}
+
+	engine := dockerx.NewMockEngine()
+	var logs bytes.Buffer
+	stdcopy.NewStdWriter(&logs, stdcopy.Stdout).Write([]byte("vacuuming database \"shop\"\n"))
+	stdcopy.NewStdWriter(&logs, stdcopy.Stderr).Write([]byte("done\n"))
+	engine.SetMockLogs(logs.String())
+
+	return NewTaskRunner(engine, taskStore, NewQueue(1)), taskStore, engine
+}
+
+func TestTaskRunner_RunDueQueuesOncePerActivation(t *testing.T) {
+	runner, taskStore, _ := newTaskFixture(t)
+
+	// Not due before the top of the hour
+	runner.runDue(context.Background(), time.Date(2026, 10, 16, 9, 59, 0, 0, time.UTC))
+	assert.Empty(t, taskStore.runs)
+
+	now := time.Date(2026, 10, 16, 10, 0, 5, 0, time.UTC)
+	runner.runDue(context.Background(), now)
+	require.Len(t, taskStore.runs, 1)
+	assert.Equal(t, store.TaskTriggerSchedule, taskStore.runs[0].Trigger)
+	assert.Equal(t, store.TaskRunQueued, taskStore.runs[0].Status)
+
+	// The same activation is not queued twice
+	runner.runDue(context.Background(), now.Add(30*time.Second))
+	assert.Len(t, taskStore.runs, 1)
+}
+
+func TestTaskRunner_RunDueSkipsWhilePreviousRunActive(t *testing.T) {
+	runner, taskStore, _ := newTaskFixture(t)
+	taskStore.runs = []store.TaskRun{{ID: 1, ServiceID: 9, Status: store.TaskRunRunning}}
+
+	runner.runDue(context.Background(), time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC))
+	assert.Len(t, taskStore.runs, 1, "no run may be queued while one is running")
+	require.NotNil(t, taskStore.config.LastScheduledAt, "the skipped activation still counts")
+}
+
+func TestTaskRunner_HandleRunRecordsSuccess(t *testing.T) {
+	runner, taskStore, _ := newTaskFixture(t)
+
+	run, job, err := runner.Trigger(context.Background(), 9, store.TaskTriggerManual)
+	require.NoError(t, err)
+	require.NoError(t, runner.HandleRun(context.Background(), job))
+
+	recorded := taskStore.runs[run.ID-1]
+	assert.Equal(t, store.TaskRunSucceeded, recorded.Status)
+	require.NotNil(t, recorded.ExitCode)
+	assert.Equal(t, 0, *recorded.ExitCode)
+	assert.Equal(t, "vacuuming database \"shop\"\ndone\n", recorded.Logs)
+	assert.NotNil(t, recorded.StartedAt)
+	assert.Nil(t, recorded.Error)
+}
+
+func TestTaskRunner_HandleRunRecordsFailure(t *testing.T) {
+	runner, taskStore, engine := newTaskFixture(t)
+	engine.SetWaitResult(3, nil)
+
+	run, job, err := runner.Trigger(context.Background(), 9, store.TaskTriggerManual)
+	require.NoError(t, err)
+
+	err = runner.HandleRun(context.Background(), job)
+	assert.True(t, IsPermanent(err), "failed runs are not retried")
+
+	recorded := taskStore.runs[run.ID-1]
+	assert.Equal(t, store.TaskRunFailed, recorded.Status)
+	require.NotNil(t, recorded.ExitCode)
+	assert.Equal(t, 3, *recorded.ExitCode)
+	require.NotNil(t, recorded.Error)
+	assert.Equal(t, "task exited with code 3", *recorded.Error)
+}
+
+func TestTaskRunner_HandleRunWaitError(t *testing.T) {
+	runner, taskStore, engine := newTaskFixture(t)
+	engine.SetWaitResult(0, errors.New("container vanished"))
+
+	run, job, err := runner.Trigger(context.Background(), 9, store.TaskTriggerManual)
+	require.NoError(t, err)
+	require.Error(t, runner.HandleRun(context.Background(), job))
+
+	recorded := taskStore.runs[run.ID-1]
+	assert.Equal(t, store.TaskRunFailed, recorded.Status)
+	assert.Nil(t, recorded.ExitCode)
+	assert.Contains(t, *recorded.Error, "container vanished")
+}
+
+func TestTaskRunner_RunOneOffStreamsOutput(t *testing.T) {
+	runner, taskStore, engine := newTaskFixture(t)
+	engine.SetWaitResult(1, nil)
+
+	run := &store.ServiceRun{ID: 4, ServiceID: 9, Command: []string{"psql", "-c", "select 1"}}
+	var output bytes.Buffer
+	err := runner.RunOneOff(context.Background(), taskStore.service, run, map[string]string{"PGPASSWORD": "secret"}, time.Minute, &output)
+	require.Error(t, err)
+
+	assert.Equal(t, "vacuuming database \"shop\"\ndone\n", output.String())
+	assert.Equal(t, output.String(), run.Output)
+	assert.Equal(t, store.TaskRunFailed, run.Status)
+	require.NotNil(t, run.ExitCode)
+	assert.Equal(t, 1, *run.ExitCode)
+	require.NotNil(t, run.Error)
+	assert.Equal(t, "command exited with code 1", *run.Error)
+	assert.Empty(t, taskStore.runs, "one-off runs are not task runs")
+}
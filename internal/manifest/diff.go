@@ -0,0 +1,375 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// Change actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Kinds of objects a change applies to
+const (
+	KindProject = "project"
+	KindService = "service"
+	KindRoute   = "route"
+	KindLinks   = "links"
+)
+
+// State is the current configuration of a project as stored
+type State struct {
+	Project  store.Project
+	Services []store.Service
+	Routes   map[int64][]store.Route         // by service ID
+	Links    map[int64][]store.LinkedService // by service ID
+}
+
+// Change is a single difference between a manifest and the stored state
+type Change struct {
+	Action  string   `json:"action"`
+	Kind    string   `json:"kind"`
+	Service string   `json:"service,omitempty"`
+	Name    string   `json:"name"`
+	Fields  []string `json:"fields,omitempty"` // changed fields of updates; added (+) and removed (-) targets of links
+}
+
+// Plan is the set of changes that brings a project in line with its manifest
+type Plan struct {
+	Changes   []Change               `json:"changes"`
+	ChangeSet store.ProjectChangeSet `json:"-"`
+}
+
+// InSync reports whether the project already matches the manifest
+func (p *Plan) InSync() bool {
+	return len(p.Changes) == 0
+}
+
+// Diff compares a validated manifest with the stored state of its project
+func Diff(m *Manifest, state State) *Plan {
+	plan := &Plan{Changes: []Change{}}
+	diffProject(plan, m.Project, state.Project)
+
+	existing := make(map[string]store.Service, len(state.Services))
+	for _, service := range state.Services {
+		existing[service.Name] = service
+	}
+
+	services := append([]Service{}, m.Services...)
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	for _, desired := range services {
+		current, exists := existing[desired.Name]
+		if !exists {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindService, Name: desired.Name})
+			plan.ChangeSet.CreateServices = append(plan.ChangeSet.CreateServices, desired.spec())
+			diffRoutes(plan, desired, nil)
+			diffLinks(plan, desired, nil, state.Project.ID)
+			continue
+		}
+
+		if fields := changedFields(desired, current); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindService, Name: desired.Name, Fields: fields})
+			plan.ChangeSet.UpdateServices = append(plan.ChangeSet.UpdateServices, desired.apply(current))
+		}
+		diffRoutes(plan, desired, state.Routes[current.ID])
+		diffLinks(plan, desired, state.Links[current.ID], state.Project.ID)
+	}
+
+	desiredNames := make(map[string]bool, len(m.Services))
+	for _, service := range m.Services {
+		desiredNames[service.Name] = true
+	}
+	stale := make([]store.Service, 0)
+	for _, service := range state.Services {
+		if !desiredNames[service.Name] {
+			stale = append(stale, service)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	for _, service := range stale {
+		plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: KindService, Name: service.Name})
+		plan.ChangeSet.DeleteServices = append(plan.ChangeSet.DeleteServices, service.ID)
+	}
+
+	return plan
+}
+
+// diffProject updates the project settings the manifest sets
+func diffProject(plan *Plan, desired *Project, current store.Project) {
+	if desired == nil {
+		return
+	}
+
+	updated := current
+	var fields []string
+	if desired.Name != "" && desired.Name != current.Name {
+		updated.Name = desired.Name
+		fields = append(fields, "name")
+	}
+	if desired.RepoURL != nil && !equalString(desired.RepoURL, current.RepoURL) {
+		updated.RepoURL = desired.RepoURL
+		fields = append(fields, "repo_url")
+	}
+	if desired.Branch != "" && desired.Branch != current.Branch {
+		updated.Branch = desired.Branch
+		fields = append(fields, "branch")
+	}
+	if desired.ImageTarget != nil && !equalString(desired.ImageTarget, current.ImageTarget) {
+		updated.ImageTarget = desired.ImageTarget
+		fields = append(fields, "image_target")
+	}
+
+	if len(fields) > 0 {
+		plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindProject, Name: updated.Name, Fields: fields})
+		plan.ChangeSet.Project = &updated
+	}
+}
+
+// diffRoutes matches the desired routes of a service to its current routes by
+// domain and location
+func diffRoutes(plan *Plan, desired Service, current []store.Route) {
+	byName := make(map[string]store.Route, len(current))
+	for _, route := range current {
+		byName[route.Name()] = route
+	}
+
+	wanted := make(map[string]bool, len(desired.Routes))
+	for _, route := range desired.Routes {
+		name := route.name()
+		wanted[name] = true
+		spec := route.spec()
+
+		existing, exists := byName[name]
+		if !exists {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindRoute, Service: desired.Name, Name: name})
+			plan.ChangeSet.CreateRoutes = append(plan.ChangeSet.CreateRoutes, store.ServiceRoute{Service: desired.Name, Spec: spec})
+			continue
+		}
+
+		var fields []string
+		if route.Port != existing.Port {
+			fields = append(fields, "port")
+		}
+		if route.TLS != existing.TLS {
+			fields = append(fields, "tls")
+		}
+		if !equalString(route.Path, existing.Path) {
+			fields = append(fields, "path")
+		}
+		if route.StripPrefix != existing.StripPrefix {
+			fields = append(fields, "strip_prefix")
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Certificates, proxy and access settings are not part of the manifest and are kept
+		spec.CertificateID = existing.CertificateID
+		spec.DomainID = existing.DomainID
+		spec.ProxyConfig = existing.ProxyConfig
+		spec.Access = existing.Access
+		plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindRoute, Service: desired.Name, Name: name, Fields: fields})
+		plan.ChangeSet.UpdateRoutes = append(plan.ChangeSet.UpdateRoutes, store.ServiceRoute{ID: existing.ID, Service: desired.Name, Spec: spec})
+	}
+
+	for _, route := range current {
+		name := route.Name()
+		if wanted[name] {
+			continue
+		}
+		plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: KindRoute, Service: desired.Name, Name: name})
+		plan.ChangeSet.DeleteRoutes = append(plan.ChangeSet.DeleteRoutes, route.ID)
+	}
+}
+
+// diffLinks replaces the links of a service within its project. Links to services
+// of other projects cannot be expressed in a manifest and are kept.
+func diffLinks(plan *Plan, desired Service, current []store.LinkedService, projectID int64) {
+	wanted := make(map[string]bool, len(desired.Links))
+	for _, link := range desired.Links {
+		wanted[link] = true
+	}
+
+	have := make(map[string]bool)
+	var externalIDs []int64
+	for _, link := range current {
+		if link.ProjectID != projectID {
+			externalIDs = append(externalIDs, link.ID)
+			continue
+		}
+		have[link.Name] = true
+	}
+
+	var fields []string
+	for name := range wanted {
+		if !have[name] {
+			fields = append(fields, "+"+name)
+		}
+	}
+	for name := range have {
+		if !wanted[name] {
+			fields = append(fields, "-"+name)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+	sort.Strings(fields)
+
+	targets := make([]string, 0, len(wanted))
+	for name := range wanted {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+
+	plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindLinks, Service: desired.Name, Name: desired.Name, Fields: fields})
+	plan.ChangeSet.Links = append(plan.ChangeSet.Links, store.ServiceLinkSet{Service: desired.Name, Targets: targets, TargetIDs: externalIDs})
+}
+
+// changedFields lists the configuration fields in which a service differs from the manifest
+func changedFields(desired Service, current store.Service) []string {
+	var fields []string
+	if !equalString(desired.Description, current.Description) {
+		fields = append(fields, "description")
+	}
+	if desired.Image != current.Image {
+		fields = append(fields, "image")
+	}
+	if !(len(desired.Env) == 0 && len(current.Env) == 0) && !reflect.DeepEqual(desired.Env, current.Env) {
+		fields = append(fields, "env")
+	}
+	if !(len(desired.Ports) == 0 && len(current.Ports) == 0) && !reflect.DeepEqual(desired.Ports, current.Ports) {
+		fields = append(fields, "ports")
+	}
+	if !(len(desired.Volumes) == 0 && len(current.Volumes) == 0) && !reflect.DeepEqual(desired.Volumes, current.Volumes) {
+		fields = append(fields, "volumes")
+	}
+	if !equalString(desired.HealthPath, current.HealthPath) {
+		fields = append(fields, "health_path")
+	}
+	if desired.Resources != current.Resources {
+		fields = append(fields, "resources")
+	}
+	if restartPolicy(desired.RestartPolicy) != restartPolicy(current.RestartPolicy) {
+		fields = append(fields, "restart_policy")
+	}
+	return fields
+}
+
+// RuntimeFields are the service fields that only take effect in a new container
+var RuntimeFields = map[string]bool{
+	"image":          true,
+	"env":            true,
+	"ports":          true,
+	"volumes":        true,
+	"resources":      true,
+	"restart_policy": true,
+}
+
+// spec converts a manifest service into the spec used to create it
+func (s Service) spec() store.ServiceSpec {
+	env := s.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	return store.ServiceSpec{
+		Name:          s.Name,
+		Image:         s.Image,
+		Env:           env,
+		Ports:         s.Ports,
+		Volumes:       s.Volumes,
+		HealthPath:    s.HealthPath,
+		Resources:     s.Resources,
+		RestartPolicy: s.RestartPolicy,
+	}
+}
+
+// apply returns the stored service with its configuration replaced by the manifest's
+func (s Service) apply(current store.Service) store.Service {
+	updated := current
+	updated.Description = s.Description
+	updated.Image = s.Image
+	updated.Env = s.spec().Env
+	updated.Ports = s.Ports
+	updated.Volumes = s.Volumes
+	updated.HealthPath = s.HealthPath
+	updated.Resources = s.Resources
+	updated.RestartPolicy = restartPolicy(s.RestartPolicy)
+	return updated
+}
+
+// FromState describes the stored state of a project as a manifest
+func FromState(state State) *Manifest {
+	m := &Manifest{
+		Version: Version,
+		Project: &Project{
+			Name:        state.Project.Name,
+			RepoURL:     state.Project.RepoURL,
+			Branch:      state.Project.Branch,
+			ImageTarget: state.Project.ImageTarget,
+		},
+		Services: []Service{},
+	}
+
+	services := append([]store.Service{}, state.Services...)
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	for _, current := range services {
+		service := Service{
+			Name:          current.Name,
+			Description:   current.Description,
+			Image:         current.Image,
+			Env:           current.Env,
+			Ports:         current.Ports,
+			Volumes:       current.Volumes,
+			HealthPath:    current.HealthPath,
+			Resources:     current.Resources,
+			RestartPolicy: current.RestartPolicy,
+		}
+		for _, link := range state.Links[current.ID] {
+			if link.ProjectID == state.Project.ID {
+				service.Links = append(service.Links, link.Name)
+			}
+		}
+		sort.Strings(service.Links)
+		for _, route := range state.Routes[current.ID] {
+			matchType := route.MatchType
+			if matchType == store.RouteMatchPrefix {
+				matchType = ""
+			}
+			service.Routes = append(service.Routes, Route{Domain: route.Domain, Port: route.Port, TLS: route.TLS, Path: route.Path, MatchType: matchType, StripPrefix: route.StripPrefix})
+		}
+		m.Services = append(m.Services, service)
+	}
+
+	return m
+}
+
+// String summarizes a change for logs and audit entries, e.g. "update service web"
+func (c Change) String() string {
+	if c.Service != "" && c.Kind != KindLinks {
+		return fmt.Sprintf("%s %s %s of %s", c.Action, c.Kind, c.Name, c.Service)
+	}
+	return fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name)
+}
+
+func restartPolicy(policy store.RestartPolicy) store.RestartPolicy {
+	if policy.Name == "" {
+		policy.Name = store.RestartPolicyNo
+	}
+	return policy
+}
+
+func equalString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,113 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shopState() State {
+	path := "/healthz"
+	return State{
+		Project: store.Project{ID: 1, Name: "shop", Branch: "main"},
+		Services: []store.Service{
+			{
+				ID:            10,
+				ProjectID:     1,
+				Name:          "web",
+				Image:         "ghcr.io/acme/shop:1.3",
+				Env:           map[string]string{"DATABASE_URL": "postgres://db:5432/shop"},
+				Ports:         []store.PortMap{{Container: 3000, Host: 8080}},
+				Volumes:       []store.VolumeMap{{Host: "shop-uploads", Container: "/app/uploads"}},
+				HealthPath:    &path,
+				Resources:     store.ResourceLimits{MemoryLimit: 268435456},
+				RestartPolicy: store.RestartPolicy{Name: "on-failure", MaxRetries: 3},
+			},
+			{ID: 11, ProjectID: 1, Name: "cache", Image: "redis:7", Env: map[string]string{}, RestartPolicy: store.RestartPolicy{Name: "no"}},
+		},
+		Routes: map[int64][]store.Route{
+			10: {
+				{ID: 100, ServiceID: 10, Domain: "shop.example.com", Port: 3000},
+				{ID: 101, ServiceID: 10, Domain: "old.example.com", Port: 3000},
+			},
+		},
+		Links: map[int64][]store.LinkedService{
+			10: {
+				{ID: 11, ProjectID: 1, Name: "cache"},
+				{ID: 50, ProjectID: 2, Name: "auth"},
+			},
+		},
+	}
+}
+
+func TestDiff_CreatesUpdatesAndDeletes(t *testing.T) {
+	m, err := Parse([]byte(shopManifest))
+	require.NoError(t, err)
+
+	plan := Diff(m, shopState())
+	require.False(t, plan.InSync())
+
+	assert.Equal(t, []Change{
+		{Action: ActionCreate, Kind: KindService, Name: "db"},
+		{Action: ActionUpdate, Kind: KindService, Name: "web", Fields: []string{"image"}},
+		{Action: ActionUpdate, Kind: KindRoute, Service: "web", Name: "shop.example.com", Fields: []string{"tls"}},
+		{Action: ActionDelete, Kind: KindRoute, Service: "web", Name: "old.example.com"},
+		{Action: ActionUpdate, Kind: KindLinks, Service: "web", Name: "web", Fields: []string{"+db", "-cache"}},
+		{Action: ActionDelete, Kind: KindService, Name: "cache"},
+	}, plan.Changes)
+
+	changes := plan.ChangeSet
+	assert.Nil(t, changes.Project)
+	require.Len(t, changes.CreateServices, 1)
+	assert.Equal(t, "db", changes.CreateServices[0].Name)
+	require.Len(t, changes.UpdateServices, 1)
+	assert.Equal(t, int64(10), changes.UpdateServices[0].ID)
+	assert.Equal(t, "ghcr.io/acme/shop:1.4", changes.UpdateServices[0].Image)
+	assert.Equal(t, []int64{11}, changes.DeleteServices)
+	require.Len(t, changes.UpdateRoutes, 1)
+	assert.Equal(t, int64(100), changes.UpdateRoutes[0].ID)
+	assert.True(t, changes.UpdateRoutes[0].Spec.TLS)
+	assert.Equal(t, []int64{101}, changes.DeleteRoutes)
+	assert.Equal(t, []store.ServiceLinkSet{{Service: "web", Targets: []string{"db"}, TargetIDs: []int64{50}}}, changes.Links)
+}
+
+func TestDiff_NewServiceGetsRoutesAndLinks(t *testing.T) {
+	m, err := Parse([]byte(shopManifest))
+	require.NoError(t, err)
+
+	plan := Diff(m, State{Project: store.Project{ID: 1, Name: "shop", Branch: "main"}})
+
+	assert.Equal(t, []Change{
+		{Action: ActionCreate, Kind: KindService, Name: "db"},
+		{Action: ActionCreate, Kind: KindService, Name: "web"},
+		{Action: ActionCreate, Kind: KindRoute, Service: "web", Name: "shop.example.com"},
+		{Action: ActionUpdate, Kind: KindLinks, Service: "web", Name: "web", Fields: []string{"+db"}},
+	}, plan.Changes)
+	assert.Equal(t, []store.ServiceRoute{{Service: "web", Spec: store.RouteSpec{Domain: "shop.example.com", Port: 3000, TLS: true}}}, plan.ChangeSet.CreateRoutes)
+}
+
+func TestDiff_ProjectSettings(t *testing.T) {
+	repo := "https://github.com/acme/shop"
+	m := &Manifest{Version: Version, Project: &Project{Name: "shop", RepoURL: &repo, Branch: "release"}}
+
+	plan := Diff(m, State{Project: store.Project{ID: 1, Name: "shop", Branch: "main"}})
+
+	assert.Equal(t, []Change{{Action: ActionUpdate, Kind: KindProject, Name: "shop", Fields: []string{"repo_url", "branch"}}}, plan.Changes)
+	require.NotNil(t, plan.ChangeSet.Project)
+	assert.Equal(t, "release", plan.ChangeSet.Project.Branch)
+	assert.Equal(t, &repo, plan.ChangeSet.Project.RepoURL)
+}
+
+func TestDiff_FromStateIsInSync(t *testing.T) {
+	state := shopState()
+
+	m := FromState(state)
+	require.NoError(t, m.Validate())
+	assert.Equal(t, []string{"cache"}, m.Services[1].Links)
+
+	plan := Diff(m, state)
+	assert.True(t, plan.InSync(), "unexpected changes: %v", plan.Changes)
+	assert.Empty(t, plan.ChangeSet.Links)
+}
@@ -0,0 +1,217 @@
+// Package manifest defines the declarative project manifest and reconciles it
+// against the services, routes and links stored for a project
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the manifest format version this build reads and writes
+const Version = 1
+
+// Manifest is the desired state of a project. Services of the project that are
+// not listed are deleted when the manifest is applied.
+type Manifest struct {
+	Version  int       `json:"version"`
+	Project  *Project  `json:"project,omitempty"`
+	Services []Service `json:"services"`
+}
+
+// Project holds the project settings a manifest manages. Omitted fields are left unchanged.
+type Project struct {
+	Name        string  `json:"name,omitempty"`
+	RepoURL     *string `json:"repo_url,omitempty"`
+	Branch      string  `json:"branch,omitempty"`
+	ImageTarget *string `json:"image_target,omitempty"`
+}
+
+// Service is the desired configuration of a service, identified by its name
+type Service struct {
+	Name          string               `json:"name"`
+	Description   *string              `json:"description,omitempty"`
+	Image         string               `json:"image"`
+	Env           map[string]string    `json:"env,omitempty"`
+	Ports         []store.PortMap      `json:"ports,omitempty"`
+	Volumes       []store.VolumeMap    `json:"volumes,omitempty"`
+	HealthPath    *string              `json:"health_path,omitempty"`
+	Resources     store.ResourceLimits `json:"resources"`
+	RestartPolicy store.RestartPolicy  `json:"restart_policy"`
+	Links         []string             `json:"links,omitempty"` // names of services of the same project
+	Routes        []Route              `json:"routes,omitempty"`
+}
+
+// Route is a desired route of a service, identified by its domain
+type Route struct {
+	Domain      string  `json:"domain"`
+	Port        int     `json:"port"`
+	TLS         bool    `json:"tls,omitempty"`
+	Path        *string `json:"path,omitempty"`
+	MatchType   string  `json:"match_type,omitempty"`
+	StripPrefix bool    `json:"strip_prefix,omitempty"`
+}
+
+// spec returns the store spec of a route
+func (r Route) spec() store.RouteSpec {
+	return store.RouteSpec{Domain: r.Domain, Port: r.Port, TLS: r.TLS, Path: r.Path, MatchType: r.MatchType, StripPrefix: r.StripPrefix}
+}
+
+// name identifies a route by its domain and location, e.g. "example.com/api"
+func (r Route) name() string {
+	return store.RouteName(r.Domain, r.Path, r.MatchType)
+}
+
+// Parse reads a manifest in YAML or JSON and validates it. Unknown fields are
+// rejected so that typos do not silently drop configuration.
+func Parse(data []byte) (*Manifest, error) {
+	// YAML is a superset of JSON; converting through JSON lets the store types
+	// keep a single set of field names
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("invalid manifest: document is empty")
+	}
+	converted, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(converted))
+	decoder.DisallowUnknownFields()
+	var manifest Manifest
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// Validate checks the manifest for errors that would make applying it fail
+func (m *Manifest) Validate() error {
+	if m.Version != Version {
+		return fmt.Errorf("unsupported manifest version %d: expected %d", m.Version, Version)
+	}
+	if m.Project != nil && len(m.Project.Name) > 64 {
+		return fmt.Errorf("invalid project name: must be 1-64 characters")
+	}
+
+	names := make(map[string]bool, len(m.Services))
+	for _, service := range m.Services {
+		if !store.IsValidServiceName(service.Name) {
+			return fmt.Errorf("invalid service name %q: must be a lowercase DNS label", service.Name)
+		}
+		if names[service.Name] {
+			return fmt.Errorf("duplicate service %s", service.Name)
+		}
+		names[service.Name] = true
+	}
+
+	domains := make(map[string]string)
+	for _, service := range m.Services {
+		if err := service.validate(names); err != nil {
+			return fmt.Errorf("service %s: %w", service.Name, err)
+		}
+		for _, route := range service.Routes {
+			if other, exists := domains[route.name()]; exists {
+				return fmt.Errorf("domain %s is routed to both %s and %s", route.name(), other, service.Name)
+			}
+			domains[route.name()] = service.Name
+		}
+	}
+
+	return nil
+}
+
+func (s Service) validate(names map[string]bool) error {
+	if s.Image == "" {
+		return fmt.Errorf("image cannot be empty")
+	}
+
+	hostPorts := make(map[int]bool)
+	for _, port := range s.Ports {
+		if port.Container < 1 || port.Container > 65535 {
+			return fmt.Errorf("invalid container port: %d (must be 1-65535)", port.Container)
+		}
+		if port.Host < 0 || port.Host > 65535 {
+			return fmt.Errorf("invalid host port: %d (must be 0-65535)", port.Host)
+		}
+		if port.Host != 0 && hostPorts[port.Host] {
+			return fmt.Errorf("duplicate host port: %d", port.Host)
+		}
+		hostPorts[port.Host] = true
+	}
+
+	if err := store.ValidateVolumes(s.Volumes); err != nil {
+		return err
+	}
+	if err := s.Resources.Validate(); err != nil {
+		return err
+	}
+	if err := s.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+
+	for _, link := range s.Links {
+		if link == s.Name {
+			return fmt.Errorf("cannot link to itself")
+		}
+		if !names[link] {
+			return fmt.Errorf("links to undefined service %s", link)
+		}
+	}
+
+	routeNames := make(map[string]bool)
+	for _, route := range s.Routes {
+		if route.Domain == "" || len(route.Domain) > 253 {
+			return fmt.Errorf("route domain must be 1-253 characters")
+		}
+		if route.Port < 1 || route.Port > 65535 {
+			return fmt.Errorf("invalid route port: %d (must be 1-65535)", route.Port)
+		}
+		if err := route.spec().Validate(); err != nil {
+			return fmt.Errorf("route %s: %w", route.name(), err)
+		}
+		if routeNames[route.name()] {
+			return fmt.Errorf("duplicate route %s", route.name())
+		}
+		routeNames[route.name()] = true
+	}
+
+	return nil
+}
+
+// Marshal encodes a manifest as YAML
+func Marshal(m *Manifest) ([]byte, error) {
+	// Round-trip through JSON so the YAML uses the same field names Parse reads
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var raw yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	// JSON objects decode as flow style; reset it so the output is block YAML
+	clearStyle(&raw)
+	return yaml.Marshal(&raw)
+}
+
+func clearStyle(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode {
+		node.Style = 0
+	} else if node.Style == yaml.DoubleQuotedStyle {
+		node.Style = 0
+	}
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shopManifest = `
+version: 1
+project:
+  name: shop
+  branch: main
+services:
+  - name: web
+    image: ghcr.io/acme/shop:1.4
+    env:
+      DATABASE_URL: postgres://db:5432/shop
+    ports:
+      - container: 3000
+        host: 8080
+    volumes:
+      - host: shop-uploads
+        container: /app/uploads
+    health_path: /healthz
+    resources:
+      memory_limit: 268435456
+    restart_policy:
+      name: on-failure
+      max_retries: 3
+    links: [db]
+    routes:
+      - domain: shop.example.com
+        port: 3000
+        tls: true
+  - name: db
+    image: postgres:16
+`
+
+func TestParse_YAML(t *testing.T) {
+	m, err := Parse([]byte(shopManifest))
+	require.NoError(t, err)
+
+	require.NotNil(t, m.Project)
+	assert.Equal(t, "shop", m.Project.Name)
+	require.Len(t, m.Services, 2)
+
+	web := m.Services[0]
+	assert.Equal(t, map[string]string{"DATABASE_URL": "postgres://db:5432/shop"}, web.Env)
+	assert.Equal(t, []store.PortMap{{Container: 3000, Host: 8080}}, web.Ports)
+	assert.Equal(t, []store.VolumeMap{{Host: "shop-uploads", Container: "/app/uploads"}}, web.Volumes)
+	assert.Equal(t, int64(268435456), web.Resources.MemoryLimit)
+	assert.Equal(t, store.RestartPolicy{Name: "on-failure", MaxRetries: 3}, web.RestartPolicy)
+	assert.Equal(t, []string{"db"}, web.Links)
+	assert.Equal(t, []Route{{Domain: "shop.example.com", Port: 3000, TLS: true}}, web.Routes)
+}
+
+func TestParse_JSON(t *testing.T) {
+	m, err := Parse([]byte(`{"version": 1, "services": [{"name": "api", "image": "api:2", "ports": [{"container": 80, "host": 0}]}]}`))
+	require.NoError(t, err)
+	assert.Nil(t, m.Project)
+	require.Len(t, m.Services, 1)
+	assert.Equal(t, []store.PortMap{{Container: 80}}, m.Services[0].Ports)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		err      string
+	}{
+		{"empty", "", "document is empty"},
+		{"unknown field", "version: 1\nservices:\n  - name: web\n    image: nginx\n    replica: 2\n", "unknown field \"replica\""},
+		{"wrong version", "version: 2\nservices: []\n", "unsupported manifest version 2"},
+		{"invalid name", "version: 1\nservices:\n  - name: Web_App\n    image: nginx\n", "invalid service name"},
+		{"duplicate service", "version: 1\nservices:\n  - {name: web, image: nginx}\n  - {name: web, image: nginx}\n", "duplicate service web"},
+		{"missing image", "version: 1\nservices:\n  - name: web\n", "service web: image cannot be empty"},
+		{"undefined link", "version: 1\nservices:\n  - {name: web, image: nginx, links: [db]}\n", "links to undefined service db"},
+		{"blocked volume", "version: 1\nservices:\n  - name: web\n    image: nginx\n    volumes: [{host: /etc, container: /etc}]\n", "host path not allowed"},
+		{"duplicate domain", "version: 1\nservices:\n  - {name: a, image: nginx, routes: [{domain: x.com, port: 80}]}\n  - {name: b, image: nginx, routes: [{domain: x.com, port: 80}]}\n", "domain x.com is routed to both a and b"},
+		{"invalid route port", "version: 1\nservices:\n  - {name: a, image: nginx, routes: [{domain: x.com}]}\n", "invalid route port: 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.manifest))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestMarshal_RoundTrips(t *testing.T) {
+	m, err := Parse([]byte(shopManifest))
+	require.NoError(t, err)
+	m.Services[1].Env = map[string]string{"PGPORT": "5432", "FLAG": "true"}
+
+	data, err := Marshal(m)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version: 1\n")
+
+	parsed, err := Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, m, parsed)
+}
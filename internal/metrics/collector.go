@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"strconv"
 	"sync"
 	"time"
 
@@ -29,10 +30,13 @@ type Collector struct {
 	searchQueriesTotal *prometheus.CounterVec
 	searchSuggestTotal *prometheus.CounterVec
 	searchSlowQueries  prometheus.Counter
+	taskRunsTotal      *prometheus.CounterVec
+	routeRejectedTotal *prometheus.CounterVec
 
 	// Histogram metrics
-	buildDuration  prometheus.Histogram
-	deployDuration prometheus.Histogram
+	buildDuration   prometheus.Histogram
+	deployDuration  prometheus.Histogram
+	taskRunDuration prometheus.Histogram
 }
 
 func NewCollector() *Collector {
@@ -104,6 +108,28 @@ func NewCollector() *Collector {
 		Help: "Total number of slow search queries (>100ms)",
 	})
 
+	taskRunsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "glinrdock_task_runs_total",
+			Help: "Total number of scheduled task runs by trigger and status",
+		},
+		[]string{"trigger", "status"},
+	)
+
+	routeRejectedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "glinrdock_route_requests_rejected_total",
+			Help: "Total number of requests rejected by route rate and connection limits by route and status",
+		},
+		[]string{"route_id", "status"},
+	)
+
+	taskRunDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "glinrdock_task_run_duration_seconds",
+		Help:    "Duration of scheduled task runs in seconds",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 17), // 1s to ~18hr
+	})
+
 	// Register metrics
 	registry.MustRegister(
 		uptimeSeconds,
@@ -116,6 +142,9 @@ func NewCollector() *Collector {
 		searchQueriesTotal,
 		searchSuggestTotal,
 		searchSlowQueries,
+		taskRunsTotal,
+		taskRunDuration,
+		routeRejectedTotal,
 	)
 
 	collector := &Collector{
@@ -131,6 +160,9 @@ func NewCollector() *Collector {
 		searchQueriesTotal: searchQueriesTotal,
 		searchSuggestTotal: searchSuggestTotal,
 		searchSlowQueries:  searchSlowQueries,
+		taskRunsTotal:      taskRunsTotal,
+		taskRunDuration:    taskRunDuration,
+		routeRejectedTotal: routeRejectedTotal,
 	}
 
 	// Start uptime updater
@@ -202,6 +234,21 @@ func (c *Collector) RecordDeployment(success bool, duration time.Duration) {
 	c.deployDuration.Observe(duration.Seconds())
 }
 
+// Scheduled task metrics
+func (c *Collector) RecordTaskRun(trigger string, success bool, duration time.Duration) {
+	status := "success"
+	if !success {
+		status = "failed"
+	}
+	c.taskRunsTotal.WithLabelValues(trigger, status).Inc()
+	c.taskRunDuration.Observe(duration.Seconds())
+}
+
+// Route limit metrics
+func (c *Collector) RecordRouteRejected(routeID int64, status int, count int) {
+	c.routeRejectedTotal.WithLabelValues(strconv.FormatInt(routeID, 10), strconv.Itoa(status)).Add(float64(count))
+}
+
 // Search metrics
 func (c *Collector) RecordSearchQuery(entityType string, success bool, duration time.Duration) {
 	status := "success"
@@ -269,6 +316,18 @@ func RecordDeployment(success bool, duration time.Duration) {
 	}
 }
 
+func RecordTaskRun(trigger string, success bool, duration time.Duration) {
+	if DefaultCollector != nil {
+		DefaultCollector.RecordTaskRun(trigger, success, duration)
+	}
+}
+
+func RecordRouteRejected(routeID int64, status int, count int) {
+	if DefaultCollector != nil {
+		DefaultCollector.RecordRouteRejected(routeID, status, count)
+	}
+}
+
 func RecordSearchQuery(entityType string, success bool, duration time.Duration) {
 	if DefaultCollector != nil {
 		DefaultCollector.RecordSearchQuery(entityType, success, duration)
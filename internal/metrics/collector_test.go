@@ -92,6 +92,30 @@ func TestCollector_RecordBuild(t *testing.T) {
 	assert.Equal(t, float64(1), failedCount)
 }
 
+func TestCollector_RecordTaskRun(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordTaskRun("schedule", true, 30*time.Second)
+	collector.RecordTaskRun("schedule", false, 10*time.Second)
+	collector.RecordTaskRun("manual", true, 5*time.Second)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.taskRunsTotal.WithLabelValues("schedule", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.taskRunsTotal.WithLabelValues("schedule", "failed")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.taskRunsTotal.WithLabelValues("manual", "success")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(collector.taskRunsTotal.WithLabelValues("manual", "failed")))
+}
+
+func TestCollector_RecordRouteRejected(t *testing.T) {
+	collector := NewCollector()
+
+	collector.RecordRouteRejected(3, 429, 5)
+	collector.RecordRouteRejected(3, 429, 2)
+	collector.RecordRouteRejected(7, 503, 1)
+
+	assert.Equal(t, float64(7), testutil.ToFloat64(collector.routeRejectedTotal.WithLabelValues("3", "429")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.routeRejectedTotal.WithLabelValues("7", "503")))
+}
+
 func TestCollector_RecordDeployment(t *testing.T) {
 	collector := NewCollector()
 
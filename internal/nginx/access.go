@@ -0,0 +1,166 @@
+package nginx
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// htpasswdDir is where nginx finds the htpasswd files the manager writes
+const htpasswdDir = "/etc/nginx/auth"
+
+// forwardAuthPath prefixes the internal locations that check a glinrdock
+// session or token, one per required role
+const forwardAuthPath = "/.glinrdock/auth/"
+
+// forwardAuthEndpoint is the glinrdock API endpoint forward auth asks
+const forwardAuthEndpoint = "http://backend_default/v1/auth/forward"
+
+// defaultControllerAddress is where nginx reaches glinrdock when it runs on
+// the same host
+const defaultControllerAddress = "127.0.0.1:8080"
+
+// sessionCookieName is the glinrdock session cookie the auth middleware reads
+const sessionCookieName = "glinr_session"
+
+// upstreamCookieVariable holds the request cookies without the glinrdock session
+const upstreamCookieVariable = "$glinr_upstream_cookie"
+
+var controllerAddressRegex = regexp.MustCompile(`^[A-Za-z0-9.-]+:[0-9]{1,5}$`)
+
+// ControllerAddress returns the host:port of glinrdock that nginx proxies
+// forward auth checks to, the local default when address is empty or invalid
+func ControllerAddress(address string) string {
+	if address == "" {
+		return defaultControllerAddress
+	}
+	if !controllerAddressRegex.MatchString(address) {
+		log.Warn().Str("address", address).Msg("invalid nginx controller address, expected host:port")
+		return defaultControllerAddress
+	}
+	return address
+}
+
+// UpstreamCookieMap returns the http level map that drops the glinrdock session
+// from the cookies sent on to services behind forward auth
+func UpstreamCookieMap() string {
+	pattern := quote(`~^(?<glinr_cookie_before>(?:.*;)?)\s*` + sessionCookieName + `=[^;]*;?\s*(?<glinr_cookie_after>.*)$`)
+	return fmt.Sprintf("map $http_cookie %s {\n    %s \"$glinr_cookie_before$glinr_cookie_after\";\n    default $http_cookie;\n}", upstreamCookieVariable, pattern)
+}
+
+// AccessDirectives returns the nginx directives of a route's access policy
+// for its location block
+func AccessDirectives(route store.RouteWithService) []string {
+	access := route.Access
+	if access == nil || !access.Restricted() {
+		return nil
+	}
+
+	var directives []string
+	if access.Satisfy == store.RouteAccessSatisfyAny {
+		directives = append(directives, "satisfy any;")
+	}
+
+	// nginx checks the rules in order and stops at the first match
+	for _, rule := range access.Deny {
+		directives = append(directives, fmt.Sprintf("deny %s;", rule))
+	}
+	for _, rule := range access.Allow {
+		directives = append(directives, fmt.Sprintf("allow %s;", rule))
+	}
+	if len(access.Allow) > 0 {
+		directives = append(directives, "deny all;")
+	}
+
+	if access.BasicAuth != nil {
+		directives = append(directives,
+			fmt.Sprintf("auth_basic %s;", quote(access.BasicAuth.RealmOrDefault())),
+			fmt.Sprintf("auth_basic_user_file %s;", HtpasswdPath(route.ID)),
+		)
+	}
+	if access.ForwardAuth != nil {
+		directives = append(directives, fmt.Sprintf("auth_request %s;", ForwardAuthLocation(access.ForwardAuth.RoleOrDefault())))
+		// The token or session checked is glinrdock's, the service must not see it
+		directives = append(directives, standardProxyHeaders...)
+		directives = append(directives,
+			`proxy_set_header Authorization "";`,
+			"proxy_set_header Cookie "+upstreamCookieVariable+";",
+		)
+	}
+
+	return directives
+}
+
+// HtpasswdPath returns where nginx reads the basic auth users of a route
+func HtpasswdPath(routeID int64) string {
+	return fmt.Sprintf("%s/%s", htpasswdDir, HtpasswdFileName(routeID))
+}
+
+// HtpasswdFileName returns the name of the htpasswd file of a route
+func HtpasswdFileName(routeID int64) string {
+	return fmt.Sprintf("route_%d.htpasswd", routeID)
+}
+
+// Htpasswd renders the basic auth users of a route as an htpasswd file
+func Htpasswd(credentials []store.RouteCredential) string {
+	var b strings.Builder
+	for _, credential := range credentials {
+		fmt.Fprintf(&b, "%s:%s\n", credential.Username, credential.PasswordHash)
+	}
+	return b.String()
+}
+
+// ForwardAuthLocation returns the internal location that checks a session or
+// token for role
+func ForwardAuthLocation(role string) string {
+	return forwardAuthPath + role
+}
+
+// ForwardAuthDirectives returns the directives of the internal location that
+// asks glinrdock whether a request may reach a route requiring role. The
+// request body is not sent; the original method and URI are.
+func ForwardAuthDirectives(role string) []string {
+	directives := []string{
+		"internal;",
+		fmt.Sprintf("proxy_pass %s?role=%s;", forwardAuthEndpoint, role),
+		"proxy_pass_request_body off;",
+		`proxy_set_header Content-Length "";`,
+	}
+	directives = append(directives, standardProxyHeaders...)
+	return append(directives,
+		"proxy_set_header X-Original-URI $request_uri;",
+		"proxy_set_header X-Original-Method $request_method;",
+	)
+}
+
+// forwardAuthRoles returns the roles forward auth requires among routes, in order
+func forwardAuthRoles(routes []store.RouteWithService) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, route := range routes {
+		if route.Access == nil || route.Access.ForwardAuth == nil {
+			continue
+		}
+		role := route.Access.ForwardAuth.RoleOrDefault()
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// usesForwardAuth reports whether a route checks glinrdock sessions or tokens
+func usesForwardAuth(route store.RouteWithService) bool {
+	return route.Access != nil && route.Access.Restricted() && route.Access.ForwardAuth != nil
+}
+
+// usesBasicAuth reports whether a route asks for basic auth
+func usesBasicAuth(route store.RouteWithService) bool {
+	return route.Access != nil && route.Access.BasicAuth != nil
+}
@@ -6,7 +6,7 @@ import (
 	"crypto/sha256"
 	"embed"
 	"fmt"
-	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"text/template"
@@ -18,139 +18,76 @@ import (
 //go:embed templates/*.tmpl
 var templatesFS embed.FS
 
-// Embedded template strings
-const baseConfigTemplate = `# Auto-generated nginx configuration
-upstream backend_default {
-    server 127.0.0.1:8080;
+// defaultMaintenancePage is served by maintenance windows without a page of their own
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Down for maintenance</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 4em;">
+<h1>Down for maintenance</h1>
+<p>This site is undergoing scheduled maintenance and will be back shortly.</p>
+</body>
+</html>`
+
+// baseTemplateFuncs are the functions templates/base.conf.tmpl calls
+var baseTemplateFuncs = template.FuncMap{
+	"dollarVariable": func() string { return "$" + dollarName },
 }
 
-{{.ServerBlocks}}
-`
-
-const serverConfigTemplate = `# Server block for {{.Route.Domain}}
-{{- $route := .Route }}
-{{- $cert := .Cert }}
-upstream {{upstreamName $route.ServiceID $route.Port}} {
-    server {{$route.ServiceName}}:{{$route.Port}};
+// serverTemplateFuncs are the functions templates/server.conf.tmpl calls
+var serverTemplateFuncs = template.FuncMap{
+	"upstreamName":          UpstreamName,
+	"upstreamHost":          UpstreamHost,
+	"upstreamServers":       UpstreamServers,
+	"balancingDirective":    BalancingDirective,
+	"maintenancePage":       MaintenancePage,
+	"policyDirectives":      PolicyDirectives,
+	"accessDirectives":      AccessDirectives,
+	"rateLimitDirectives":   RateLimitDirectives,
+	"responseDirectives":    ResponseDirectives,
+	"staticFileLocation":    StaticFileLocation,
+	"staticFileDirectives":  StaticFileDirectives,
+	"forwardAuthLocation":   ForwardAuthLocation,
+	"forwardAuthDirectives": ForwardAuthDirectives,
+	"stripPrefix":           StripPrefixRewrite,
+	"securityHeaders":       func() []string { return securityHeaders },
+	"standardProxyHeaders":  func() []string { return standardProxyHeaders },
 }
 
-server {
-    listen 80;
-    {{- if $route.TLS}}
-    listen 443 ssl http2;
-    {{- end}}
-    server_name {{$route.Domain}};
-
-    # ACME HTTP-01 challenge location (always present for certificate issuance)
-    location ^~ /.well-known/acme-challenge/ {
-        root /var/lib/glinr/acme-http01;
-        try_files $uri =404;
-    }
-
-    {{- if $route.TLS}}
-    {{- if $cert}}
-    # SSL certificate configuration
-    ssl_certificate /etc/nginx/certs/{{$route.Domain}}.crt;
-    ssl_certificate_key /etc/nginx/certs/{{$route.Domain}}.key;
-    {{- if ne $cert.PEMChain nil}}
-    ssl_trusted_certificate /etc/nginx/certs/{{$route.Domain}}.chain.crt;
-    {{- end}}
-    
-    # SSL security configuration
-    ssl_protocols TLSv1.2 TLSv1.3;
-    ssl_ciphers ECDHE-RSA-AES256-GCM-SHA384:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-RSA-CHACHA20-POLY1305;
-    ssl_prefer_server_ciphers off;
-    ssl_session_cache shared:SSL:10m;
-    ssl_session_timeout 10m;
-    
-    # Security headers for HTTPS
-    add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;
-    add_header X-Content-Type-Options nosniff always;
-    add_header X-Frame-Options DENY always;
-    add_header X-XSS-Protection "1; mode=block" always;
-    
-    # HTTP to HTTPS redirect for non-ACME requests
-    if ($scheme = http) {
-        return 301 https://$server_name$request_uri;
-    }
-    {{- else}}
-    # Certificate not found for {{$route.Domain}} - serve 503 for HTTPS requests
-    if ($scheme = https) {
-        return 503;
-    }
-    {{- end}}
-    {{- end}}
-
-    # Standard proxy headers
-    proxy_set_header Host $host;
-    proxy_set_header X-Real-IP $remote_addr;
-    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-    proxy_set_header X-Forwarded-Proto $scheme;
-    proxy_set_header X-Forwarded-Host $server_name;
-    proxy_set_header X-Forwarded-Port $server_port;
-
-    # Proxy configuration
-    proxy_connect_timeout 30s;
-    proxy_send_timeout 30s;
-    proxy_read_timeout 30s;
-    proxy_redirect off;
-
-    {{- if $route.Path}}
-    location {{$route.Path}} {
-        proxy_pass http://{{upstreamName $route.ServiceID $route.Port}};
-        {{- if $route.ProxyConfig}}
-        # Custom proxy configuration
-        # {{$route.ProxyConfig}}
-        {{- end}}
-    }
-    {{- else}}
-    location / {
-        proxy_pass http://{{upstreamName $route.ServiceID $route.Port}};
-        {{- if $route.ProxyConfig}}
-        # Custom proxy configuration  
-        # {{$route.ProxyConfig}}
-        {{- end}}
-    }
-    {{- end}}
-}`
+// parseTemplate parses one of the embedded templates
+func parseTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).ParseFS(templatesFS, "templates/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return tmpl, nil
+}
 
 // Generator handles nginx configuration file generation
 type Generator struct {
-	templateDir string
-	outputDir   string
-	templates   map[string]*template.Template
+	outputDir  string
+	controller string // host:port nginx reaches glinrdock at
 }
 
-// NewGenerator creates a new configuration generator
-func NewGenerator(templateDir, outputDir string) *Generator {
+// NewGenerator creates a new configuration generator. controllerAddress is
+// where nginx reaches glinrdock for forward auth checks, 127.0.0.1:8080 when empty.
+func NewGenerator(outputDir, controllerAddress string) *Generator {
 	return &Generator{
-		templateDir: templateDir,
-		outputDir:   outputDir,
-		templates:   make(map[string]*template.Template),
+		outputDir:  outputDir,
+		controller: ControllerAddress(controllerAddress),
 	}
 }
 
-// Initialize loads and parses nginx configuration templates
+// Initialize checks that the embedded nginx configuration templates parse
 func (g *Generator) Initialize(ctx context.Context) error {
 	log.Info().
-		Str("template_dir", g.templateDir).
 		Str("output_dir", g.outputDir).
 		Msg("initializing nginx configuration generator")
 
-	// Load templates
-	templateFiles := []string{"base.conf.tmpl", "server.conf.tmpl"}
-	for _, file := range templateFiles {
-		templatePath := filepath.Join(g.templateDir, file)
-		tmpl, err := template.ParseFiles(templatePath)
-		if err != nil {
-			log.Warn().
-				Str("template", file).
-				Err(err).
-				Msg("failed to load nginx template (will use defaults)")
-			continue
-		}
-		g.templates[file] = tmpl
-		log.Debug().Str("template", file).Msg("loaded nginx template")
+	if _, err := parseTemplate("base.conf.tmpl", baseTemplateFuncs); err != nil {
+		return err
+	}
+	if _, err := parseTemplate("server.conf.tmpl", serverTemplateFuncs); err != nil {
+		return err
 	}
 
 	log.Info().Msg("nginx configuration generator initialized")
@@ -159,20 +96,17 @@ func (g *Generator) Initialize(ctx context.Context) error {
 
 // Render generates nginx configuration from routes and certificates
 func (g *Generator) Render(input RenderInput) (string, string, error) {
-	// Load templates from embedded filesystem
-	baseTemplate, err := template.New("base.conf.tmpl").Parse(baseConfigTemplate)
+	baseTemplate, err := parseTemplate("base.conf.tmpl", baseTemplateFuncs)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse base template: %w", err)
+		return "", "", err
 	}
 
-	serverTemplate, err := template.New("server.conf.tmpl").Funcs(template.FuncMap{
-		"upstreamName": UpstreamName,
-	}).Parse(serverConfigTemplate)
+	serverTemplate, err := parseTemplate("server.conf.tmpl", serverTemplateFuncs)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to parse server template: %w", err)
+		return "", "", err
 	}
 
-	// Group routes by domain for deterministic ordering
+	// Group routes by domain, every domain is one server block
 	routesByDomain := make(map[string][]store.RouteWithService)
 	for _, route := range input.Routes {
 		routesByDomain[route.Domain] = append(routesByDomain[route.Domain], route)
@@ -187,50 +121,74 @@ func (g *Generator) Render(input RenderInput) (string, string, error) {
 
 	// Generate server blocks
 	var serverBlocks []string
+	upstreams := make(map[string]bool) // upstreams already rendered, routes of several domains may share one
 	for _, domain := range domains {
-		routes := routesByDomain[domain]
-		// Sort routes within domain by path for deterministic output
-		sort.Slice(routes, func(i, j int) bool {
-			pathI := ""
-			if routes[i].Path != nil {
-				pathI = *routes[i].Path
-			}
-			pathJ := ""
-			if routes[j].Path != nil {
-				pathJ = *routes[j].Path
+		var buf bytes.Buffer
+		data := struct {
+			Domain    string
+			TLS       bool
+			Cert      *store.EnhancedCertificate
+			Secured   bool // the server block adds the HTTPS security headers
+			Upstreams []store.RouteWithService
+			Routes    []store.RouteWithService
+			// Location blocks of the routes, a route may have two
+			Locations []location
+			// Roles of the forward auth locations the routes use
+			ForwardAuthRoles []string
+			// Static routes whose file is served by a named location
+			StaticFiles []store.RouteWithService
+		}{
+			Domain: domain,
+		}
+		data.Locations, data.Routes = orderLocations(routesByDomain[domain])
+		data.ForwardAuthRoles = forwardAuthRoles(data.Routes)
+		data.StaticFiles = staticFileRoutes(data.Routes)
+
+		for _, route := range data.Routes {
+			data.TLS = data.TLS || route.TLS
+			if !route.Proxies() {
+				continue
 			}
-			return pathI < pathJ
-		})
-
-		for _, route := range routes {
-			var buf bytes.Buffer
-			data := struct {
-				Route store.RouteWithService
-				Cert  *store.EnhancedCertificate
-			}{
-				Route: route,
+			name := UpstreamName(route.ServiceID, route.Port)
+			if !upstreams[name] {
+				upstreams[name] = true
+				data.Upstreams = append(data.Upstreams, route)
 			}
+		}
 
-			// Find certificate for this route if TLS is enabled
-			if route.TLS {
-				if cert, exists := input.Certs[route.Domain]; exists {
-					data.Cert = &cert
-				}
+		// Find certificate for this domain if TLS is enabled
+		if data.TLS {
+			if cert, exists := input.Certs[domain]; exists {
+				data.Cert = &cert
+				data.Secured = true
 			}
+		}
 
-			if err := serverTemplate.Execute(&buf, data); err != nil {
-				return "", "", fmt.Errorf("failed to execute server template: %w", err)
-			}
-			serverBlocks = append(serverBlocks, buf.String())
+		if err := serverTemplate.Execute(&buf, data); err != nil {
+			return "", "", fmt.Errorf("failed to execute server template: %w", err)
 		}
+		serverBlocks = append(serverBlocks, buf.String())
 	}
 
 	// Generate base config with server blocks
 	var baseBuf bytes.Buffer
 	baseData := struct {
-		ServerBlocks string
+		ServerBlocks   string
+		Controller     string
+		UpstreamCookie string
+		WebSocket      bool
+		Dollar         bool
+		RateLimitZones []string
 	}{
-		ServerBlocks: strings.Join(serverBlocks, "\n\n"),
+		ServerBlocks:   strings.Join(serverBlocks, "\n\n"),
+		Controller:     g.controller,
+		WebSocket:      usesWebSocket(input.Routes),
+		Dollar:         usesDollar(input.Routes),
+		RateLimitZones: RateLimitZones(input.Routes),
+	}
+
+	if len(forwardAuthRoles(input.Routes)) > 0 {
+		baseData.UpstreamCookie = UpstreamCookieMap()
 	}
 
 	if err := baseTemplate.Execute(&baseBuf, baseData); err != nil {
@@ -238,6 +196,9 @@ func (g *Generator) Render(input RenderInput) (string, string, error) {
 	}
 
 	config := baseBuf.String()
+	if err := NewValidator().ValidateConfig(context.Background(), config); err != nil {
+		return "", "", fmt.Errorf("generated configuration is invalid: %w", err)
+	}
 
 	// Calculate SHA256 hash
 	hash := sha256.Sum256([]byte(config))
@@ -268,6 +229,141 @@ func UpstreamName(serviceID int64, port int) string {
 	return fmt.Sprintf("svc_%d_%d", serviceID, port)
 }
 
+// UpstreamHost returns the host nginx proxies a route to. Blue/green deployments
+// point this at the alias of the active container instead of the service name.
+func UpstreamHost(route store.RouteWithService) string {
+	if route.UpstreamHost != "" {
+		return route.UpstreamHost
+	}
+	return route.ServiceName
+}
+
+// UpstreamServers returns one upstream host per replica of the route's service.
+// The primary container keeps its usual host, additional replicas use their replica alias.
+func UpstreamServers(route store.RouteWithService) []string {
+	servers := []string{UpstreamHost(route)}
+	for index := 2; index <= route.Replicas; index++ {
+		servers = append(servers, store.ReplicaAlias(route.ServiceID, index))
+	}
+	return servers
+}
+
+// BalancingDirective returns the nginx upstream directive for the route's load
+// balancing method, or an empty string for round robin, which is nginx's default
+func BalancingDirective(route store.RouteWithService) string {
+	switch route.LoadBalancing {
+	case store.LoadBalancingLeastConn:
+		return "least_conn"
+	case store.LoadBalancingIPHash:
+		return "ip_hash"
+	default:
+		return ""
+	}
+}
+
+// MaintenancePage returns the maintenance page of a route as the body of an
+// nginx string. nginx has no escape for $, so it is written as an HTML entity.
+func MaintenancePage(route store.RouteWithService) string {
+	page := defaultMaintenancePage
+	if route.MaintenancePage != nil && *route.MaintenancePage != "" {
+		page = *route.MaintenancePage
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "&#36;").Replace(page)
+}
+
+// LocationMatches returns what follows "location" for each location block of a
+// route: "= path" for exact routes and a quoted pattern for regex routes. A
+// prefix route matches whole path segments, so a path without a trailing slash
+// has an exact location for the path itself and a prefix location for what is
+// below it, keeping /api from matching /apiary.
+func LocationMatches(route store.RouteWithService) []string {
+	switch route.MatchType {
+	case store.RouteMatchExact:
+		return []string{"= " + route.Location()}
+	case store.RouteMatchRegex:
+		return []string{"~ " + quote(route.Location())}
+	default:
+		if strings.HasSuffix(route.Location(), "/") {
+			return []string{route.Location()}
+		}
+		return []string{"= " + route.Location(), route.Location() + "/"}
+	}
+}
+
+// StripPrefixRewrite returns the rewrite removing a prefix route's path before
+// the request is proxied, or an empty string when the path is kept. It comes
+// last in the location since "break" ends the rewrite directives after it.
+func StripPrefixRewrite(route store.RouteWithService) string {
+	prefix := strings.TrimSuffix(route.Location(), "/")
+	if !route.StripPrefix || prefix == "" || (route.MatchType != "" && route.MatchType != store.RouteMatchPrefix) {
+		return ""
+	}
+	return fmt.Sprintf("rewrite %s /$1 break;", quote("^"+regexp.QuoteMeta(prefix)+"(?:/(.*))?$"))
+}
+
+// location is a location block and the route it serves
+type location struct {
+	Match string
+	Route store.RouteWithService
+}
+
+// orderLocations sorts the routes of a domain the way their locations are
+// written: exact matches, then prefixes longest first, then regular expressions
+// in the order they were created, which is the order nginx tries them in.
+// It returns their locations and the routes left with at least one. Locations
+// an earlier route already has are dropped, as nginx rejects duplicates.
+func orderLocations(routes []store.RouteWithService) ([]location, []store.RouteWithService) {
+	rank := func(route store.RouteWithService) int {
+		switch route.MatchType {
+		case store.RouteMatchExact:
+			return 0
+		case store.RouteMatchRegex:
+			return 2
+		default:
+			return 1
+		}
+	}
+
+	sorted := append([]store.RouteWithService{}, routes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if rank(a) == 2 || a.Location() == b.Location() {
+			return a.ID < b.ID
+		}
+		if len(a.Location()) != len(b.Location()) {
+			return len(a.Location()) > len(b.Location())
+		}
+		return a.Location() < b.Location()
+	})
+
+	seen := make(map[string]bool, len(sorted))
+	var locations []location
+	ordered := sorted[:0]
+	for _, route := range sorted {
+		kept := false
+		for _, match := range LocationMatches(route) {
+			if seen[match] {
+				log.Warn().
+					Int64("route_id", route.ID).
+					Str("domain", route.Domain).
+					Str("location", match).
+					Msg("skipping a location of a route another route of the domain already has")
+				continue
+			}
+			seen[match] = true
+			locations = append(locations, location{Match: match, Route: route})
+			kept = true
+		}
+		if kept {
+			ordered = append(ordered, route)
+		}
+	}
+	return locations, ordered
+}
+
 // RouteConfig represents a route configuration for nginx generation (legacy)
 type RouteConfig struct {
 	Domain     string
@@ -1,6 +1,7 @@
 package nginx
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"strings"
@@ -40,6 +41,32 @@ func TestUpstreamName(t *testing.T) {
 	}
 }
 
+func TestGenerator_Initialize(t *testing.T) {
+	if err := NewGenerator("", "").Initialize(context.Background()); err != nil {
+		t.Fatalf("embedded templates do not parse: %v", err)
+	}
+
+	tmpl, err := parseTemplate("server.conf.tmpl", serverTemplateFuncs)
+	if err != nil {
+		t.Fatalf("parseTemplate() error = %v", err)
+	}
+	for _, name := range []string{"$route", "$upstream", "$cert"} {
+		if !strings.Contains(tmpl.Root.String(), name) {
+			t.Errorf("server template is missing %s", name)
+		}
+	}
+
+	tmpl, err = parseTemplate("base.conf.tmpl", baseTemplateFuncs)
+	if err != nil {
+		t.Fatalf("parseTemplate() error = %v", err)
+	}
+	for _, field := range []string{".RateLimitZones", ".ServerBlocks", ".Controller"} {
+		if !strings.Contains(tmpl.Root.String(), field) {
+			t.Errorf("base template is missing %s", field)
+		}
+	}
+}
+
 func TestGenerator_Render(t *testing.T) {
 	generator := NewGenerator("", "")
 
@@ -203,8 +230,8 @@ func TestGenerator_Render(t *testing.T) {
 			wantErr: false,
 			checks: []func(config string) error{
 				func(config string) error {
-					if !strings.Contains(config, "location /api {") {
-						return fmt.Errorf("missing custom path location block")
+					if !strings.Contains(config, "location = /api {") || !strings.Contains(config, "location /api/ {") {
+						return fmt.Errorf("missing custom path location blocks")
 					}
 					return nil
 				},
@@ -398,7 +425,752 @@ func TestGenerator_Render_StandardProxyHeaders(t *testing.T) {
 	}
 }
 
+func TestGenerator_Render_UpstreamHostOverride(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+				},
+				ServiceName:  "web-service",
+				UpstreamHost: "svc-7-green",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	if !strings.Contains(config, "upstream svc_7_3000") {
+		t.Errorf("upstream name should not change when the upstream host is overridden")
+	}
+	if !strings.Contains(config, "server svc-7-green:3000;") {
+		t.Errorf("missing overridden upstream target")
+	}
+	if strings.Contains(config, "server web-service:3000;") {
+		t.Errorf("service name should not be used when the upstream host is overridden")
+	}
+}
+
+func TestGenerator_Render_Replicas(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+				},
+				ServiceName:   "web-service",
+				Replicas:      3,
+				LoadBalancing: store.LoadBalancingLeastConn,
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := `upstream svc_7_3000 {
+    least_conn;
+    server web-service:3000;
+    server svc-7-r2:3000;
+    server svc-7-r3:3000;
+}`
+	if !strings.Contains(config, expected) {
+		t.Errorf("upstream should list every replica with the balancing method, got:\n%s", config)
+	}
+}
+
+func TestGenerator_Render_RoundRobinHasNoDirective(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+				},
+				ServiceName:   "web-service",
+				Replicas:      1,
+				LoadBalancing: store.LoadBalancingRoundRobin,
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := `upstream svc_7_3000 {
+    server web-service:3000;
+}`
+	if !strings.Contains(config, expected) {
+		t.Errorf("single replica upstream should be unchanged, got:\n%s", config)
+	}
+}
+
+func TestGenerator_Render_MaintenancePage(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+				},
+				ServiceName:     "web-service",
+				MaintenancePage: stringPtr(`<p class="note">Back at 5pm, $5 credit \o/</p>`),
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := `return 503 "<p class=\"note\">Back at 5pm, &#36;5 credit \\o/</p>";`
+	if !strings.Contains(config, expected) {
+		t.Errorf("maintenance page should be escaped for nginx, got:\n%s", config)
+	}
+	if strings.Contains(config, "proxy_pass") {
+		t.Errorf("routes under maintenance should not be proxied, got:\n%s", config)
+	}
+	if !strings.Contains(config, "location ^~ /.well-known/acme-challenge/") {
+		t.Errorf("ACME challenges should still be served during maintenance")
+	}
+}
+
+func TestGenerator_Render_DefaultMaintenancePage(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+					Path:      stringPtr("/api"),
+				},
+				ServiceName:     "web-service",
+				MaintenancePage: stringPtr(""),
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	if !strings.Contains(config, "location /api/ {\n        # Maintenance window in effect") {
+		t.Errorf("path route should serve the maintenance page, got:\n%s", config)
+	}
+	if !strings.Contains(config, "<h1>Down for maintenance</h1>") {
+		t.Errorf("empty maintenance page should fall back to the default page, got:\n%s", config)
+	}
+}
+
+func TestGenerator_Render_RoutePolicy(t *testing.T) {
+	generator := NewGenerator("", "")
+	buffering := false
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+					Path:      stringPtr("/ws"),
+					ProxyConfig: &store.RoutePolicy{
+						ReadTimeout:     3600,
+						MaxBodySize:     "50m",
+						Buffering:       &buffering,
+						WebSocket:       true,
+						Gzip:            true,
+						RequestHeaders:  map[string]string{"X-Tenant": `acme "eu"`},
+						ResponseHeaders: map[string]string{"Cache-Control": "no-store"},
+					},
+				},
+				ServiceName: "web-service",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := []string{
+		"map $http_upgrade $glinr_connection_upgrade {",
+		`        proxy_pass http://svc_7_3000;
+        proxy_set_header Host $host;`,
+		`proxy_set_header X-Tenant "acme \"eu\"";`,
+		"proxy_http_version 1.1;",
+		"proxy_set_header Upgrade $http_upgrade;",
+		"proxy_set_header Connection $glinr_connection_upgrade;",
+		"proxy_read_timeout 3600s;",
+		"client_max_body_size 50m;",
+		"proxy_buffering off;",
+		"proxy_request_buffering off;",
+		"gzip on;",
+		`add_header Cache-Control "no-store" always;`,
+	}
+	for _, directive := range expected {
+		if !strings.Contains(config, directive) {
+			t.Errorf("missing %q in:\n%s", directive, config)
+		}
+	}
+	if strings.Contains(config, "Strict-Transport-Security") {
+		t.Errorf("plain HTTP routes should not get HTTPS security headers")
+	}
+}
+
+func TestGenerator_Render_RoutePolicyKeepsSecurityHeaders(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "example.com",
+					Port:      3000,
+					TLS:       true,
+					ProxyConfig: &store.RoutePolicy{
+						ResponseHeaders: map[string]string{"X-Robots-Tag": "noindex"},
+					},
+				},
+				ServiceName: "web-service",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{"example.com": {Domain: "example.com"}},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	// add_header in the location replaces the server's, so they are repeated there
+	location := config[strings.Index(config, "location / {"):]
+	if !strings.Contains(location, "add_header Strict-Transport-Security") {
+		t.Errorf("location with custom headers should repeat the security headers, got:\n%s", location)
+	}
+	if strings.Contains(config, "$glinr_connection_upgrade") {
+		t.Errorf("WebSocket map should only be rendered for WebSocket routes")
+	}
+}
+
+func TestGenerator_Render_RoutePolicyCORS(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 7,
+					Domain:    "api.example.com",
+					Port:      3000,
+					ProxyConfig: &store.RoutePolicy{
+						CORS: &store.CORSPolicy{
+							AllowOrigins:     []string{"https://app.example.com", "http://localhost:5173"},
+							AllowMethods:     []string{"GET", "POST"},
+							AllowHeaders:     []string{"Authorization", "Content-Type"},
+							AllowCredentials: true,
+						},
+					},
+				},
+				ServiceName: "api",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := []string{
+		`if ($http_origin ~ "^(https://app\\.example\\.com|http://localhost:5173)$") {`,
+		"add_header Access-Control-Allow-Origin $glinr_cors_origin always;",
+		`add_header Access-Control-Allow-Credentials "true" always;`,
+		`add_header Access-Control-Allow-Methods "GET, POST" always;`,
+		`add_header Access-Control-Allow-Headers "Authorization, Content-Type" always;`,
+		"add_header Access-Control-Max-Age 600 always;",
+		"return 204;",
+	}
+	for _, directive := range expected {
+		if !strings.Contains(config, directive) {
+			t.Errorf("missing %q in:\n%s", directive, config)
+		}
+	}
+}
+
+func TestGenerator_Render_NoRoutePolicy(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route:       store.Route{ID: 1, ServiceID: 7, Domain: "example.com", Port: 3000},
+				ServiceName: "web-service",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := "    location / {\n        proxy_pass http://svc_7_3000;\n    }"
+	if !strings.Contains(config, expected) {
+		t.Errorf("route without a policy should only be proxied, got:\n%s", config)
+	}
+}
+
+func TestGenerator_Render_PathRoutes(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	route := func(id, serviceID int64, path, matchType string, stripPrefix bool) store.RouteWithService {
+		return store.RouteWithService{
+			Route: store.Route{
+				ID:          id,
+				ServiceID:   serviceID,
+				Domain:      "example.com",
+				Port:        3000,
+				Path:        stringPtr(path),
+				MatchType:   matchType,
+				StripPrefix: stripPrefix,
+			},
+			ServiceName: fmt.Sprintf("service-%d", serviceID),
+		}
+	}
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			route(1, 1, "/", store.RouteMatchPrefix, false),
+			route(2, 2, "/api", store.RouteMatchPrefix, true),
+			route(3, 3, `\.(png|jpg)$`, store.RouteMatchRegex, false),
+			route(4, 2, "/api/v2", store.RouteMatchPrefix, false),
+			route(5, 4, "/healthz", store.RouteMatchExact, false),
+			route(6, 1, "/api", store.RouteMatchPrefix, false), // duplicate of route 2
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	if count := strings.Count(config, "server_name example.com;"); count != 1 {
+		t.Errorf("expected the routes of a domain in one server block, got %d", count)
+	}
+	if count := strings.Count(config, "upstream svc_2_3000 {"); count != 1 {
+		t.Errorf("expected an upstream shared by two routes once, got %d", count)
+	}
+
+	locations := []string{
+		"location = /healthz {",
+		"location = /api/v2 {",
+		"location /api/v2/ {",
+		"location = /api {",
+		"location /api/ {",
+		"location / {",
+		`location ~ "\\.(png|jpg)$" {`,
+	}
+	last := -1
+	for _, location := range locations {
+		index := strings.Index(config, location)
+		if index < 0 {
+			t.Fatalf("missing %q in:\n%s", location, config)
+		}
+		if index < last {
+			t.Errorf("expected %q after the locations before it in:\n%s", location, config)
+		}
+		last = index
+	}
+	if count := strings.Count(config, "location /api/ {"); count != 1 {
+		t.Errorf("expected a duplicate location to be rendered once, got %d", count)
+	}
+	if strings.Contains(config, "location /api {") {
+		t.Errorf("expected prefix locations to end on a path segment, got:\n%s", config)
+	}
+
+	for _, match := range []string{"location = /api {", "location /api/ {"} {
+		api := config[strings.Index(config, match):]
+		api = api[:strings.Index(api, "}")]
+		if !strings.Contains(api, "proxy_pass http://svc_2_3000;") {
+			t.Errorf("expected the first route of a location to win, got:\n%s", api)
+		}
+		if !strings.Contains(api, `rewrite "^/api(?:/(.*))?$" /$1 break;`) {
+			t.Errorf("expected the prefix to be stripped, got:\n%s", api)
+		}
+	}
+	if strings.Count(config, "rewrite ") != 2 {
+		t.Errorf("expected only the strip_prefix route to rewrite, got:\n%s", config)
+	}
+}
+
+func TestLocationMatches(t *testing.T) {
+	tests := []struct {
+		route    store.Route
+		expected []string
+	}{
+		{store.Route{}, []string{"/"}},
+		{store.Route{Path: stringPtr("/api"), MatchType: store.RouteMatchPrefix}, []string{"= /api", "/api/"}},
+		{store.Route{Path: stringPtr("/api/")}, []string{"/api/"}},
+		{store.Route{Path: stringPtr("/"), MatchType: store.RouteMatchExact}, []string{"= /"}},
+		{store.Route{Path: stringPtr(`^/users/[0-9]+$`), MatchType: store.RouteMatchRegex}, []string{`~ "^/users/[0-9]+$"`}},
+	}
+	for _, tt := range tests {
+		result := LocationMatches(store.RouteWithService{Route: tt.route})
+		if strings.Join(result, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("LocationMatches(%+v) = %v, want %v", tt.route, result, tt.expected)
+		}
+	}
+}
+
+func TestGenerator_Render_RouteAccess(t *testing.T) {
+	generator := NewGenerator("", "glinrdock:8080")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        3,
+					ServiceID: 7,
+					Domain:    "admin.example.com",
+					Port:      3000,
+					Access: &store.RouteAccess{
+						Allow:       []string{"10.0.0.0/8", "192.168.1.10"},
+						Deny:        []string{"10.0.0.13"},
+						BasicAuth:   &store.BasicAuthPolicy{Realm: `Ops "only"`},
+						ForwardAuth: &store.ForwardAuthPolicy{Role: store.RoleDeployer},
+						Satisfy:     store.RouteAccessSatisfyAny,
+					},
+				},
+				ServiceName: "grafana",
+			},
+			{
+				Route: store.Route{
+					ID:          4,
+					ServiceID:   7,
+					Domain:      "admin.example.com",
+					Port:        3000,
+					Path:        stringPtr("/api"),
+					Access:      &store.RouteAccess{ForwardAuth: &store.ForwardAuthPolicy{}},
+					ProxyConfig: &store.RoutePolicy{WebSocket: true},
+				},
+				ServiceName: "grafana",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	root := config[strings.Index(config, "location / {"):]
+	root = root[:strings.Index(root, "}")]
+	expected := []string{
+		"satisfy any;\n        deny 10.0.0.13;\n        allow 10.0.0.0/8;\n        allow 192.168.1.10;\n        deny all;",
+		`auth_basic "Ops \"only\"";`,
+		"auth_basic_user_file /etc/nginx/auth/route_3.htpasswd;",
+		"auth_request /.glinrdock/auth/deployer;",
+		"proxy_set_header Host $host;",
+		`proxy_set_header Authorization "";`,
+		"proxy_set_header Cookie $glinr_upstream_cookie;",
+	}
+	for _, directive := range expected {
+		if !strings.Contains(root, directive) {
+			t.Errorf("missing %q in:\n%s", directive, root)
+		}
+	}
+	if !strings.Contains(config, "auth_request /.glinrdock/auth/viewer;") {
+		t.Errorf("expected forward auth to require the viewer role by default")
+	}
+
+	// The glinrdock session is dropped from the cookies services get
+	http := config[:strings.Index(config, "server {")]
+	expected = []string{
+		"upstream backend_default {\n    server glinrdock:8080;\n}",
+		`map $http_cookie $glinr_upstream_cookie {` + "\n" + `    "~^(?<glinr_cookie_before>(?:.*;)?)\\s*glinr_session=[^;]*;?\\s*(?<glinr_cookie_after>.*)$" "$glinr_cookie_before$glinr_cookie_after";`,
+	}
+	for _, directive := range expected {
+		if !strings.Contains(http, directive) {
+			t.Errorf("missing %q in:\n%s", directive, http)
+		}
+	}
+
+	// WebSocket routes behind forward auth send the standard headers once
+	api := config[strings.Index(config, "location /api"):]
+	api = api[:strings.Index(api, "}")]
+	if strings.Count(api, "proxy_set_header Host $host;") != 1 || !strings.Contains(api, "proxy_set_header Upgrade $http_upgrade;") {
+		t.Errorf("unexpected headers of the /api location:\n%s", api)
+	}
+
+	for _, role := range []string{store.RoleDeployer, store.RoleViewer} {
+		location := fmt.Sprintf("location = /.glinrdock/auth/%s {\n        internal;\n        proxy_pass http://backend_default/v1/auth/forward?role=%s;", role, role)
+		if strings.Count(config, location) != 1 {
+			t.Errorf("expected one forward auth location for %s in:\n%s", role, config)
+		}
+	}
+	if !strings.Contains(config, "proxy_pass_request_body off;") || !strings.Contains(config, "proxy_set_header X-Original-URI $request_uri;") {
+		t.Errorf("expected forward auth to send the original request without its body")
+	}
+}
+
+func TestControllerAddress(t *testing.T) {
+	if address := ControllerAddress(""); address != "127.0.0.1:8080" {
+		t.Errorf("expected the local default, got %q", address)
+	}
+	if address := ControllerAddress("glinrdock:9000"); address != "glinrdock:9000" {
+		t.Errorf("expected the configured address, got %q", address)
+	}
+	if address := ControllerAddress("glinrdock:8080; include /etc/passwd"); address != "127.0.0.1:8080" {
+		t.Errorf("expected an invalid address to fall back to the default, got %q", address)
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	credentials := []store.RouteCredential{
+		{Username: "alice", PasswordHash: "$2a$10$abc"},
+		{Username: "bob", PasswordHash: "$2a$10$def"},
+	}
+	if result := Htpasswd(credentials); result != "alice:$2a$10$abc\nbob:$2a$10$def\n" {
+		t.Errorf("unexpected htpasswd file %q", result)
+	}
+	if result := Htpasswd(nil); result != "" {
+		t.Errorf("expected an empty file without credentials, got %q", result)
+	}
+}
+
+func TestGenerator_Render_RateLimits(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        5,
+					ServiceID: 2,
+					Domain:    "api.example.com",
+					Port:      8080,
+					ProxyConfig: &store.RoutePolicy{RateLimit: &store.RateLimitPolicy{
+						RequestsPerSecond: 10,
+						Burst:             20,
+						NoDelay:           true,
+						Connections:       5,
+					}},
+				},
+				ServiceName: "api",
+			},
+			{
+				Route: store.Route{
+					ID:        6,
+					ServiceID: 2,
+					Domain:    "api.example.com",
+					Port:      8080,
+					Path:      stringPtr("/keys"),
+					ProxyConfig: &store.RoutePolicy{RateLimit: &store.RateLimitPolicy{
+						RequestsPerSecond: 100,
+						Key:               "header:X-Api-Key",
+						StatusCode:        503,
+					}},
+				},
+				ServiceName: "api",
+			},
+			{
+				Route: store.Route{
+					ID:          7,
+					ServiceID:   2,
+					Domain:      "docs.example.com",
+					Port:        8080,
+					ProxyConfig: &store.RoutePolicy{Gzip: true},
+				},
+				ServiceName: "api",
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	// Zones are declared at the http level, before the server blocks
+	http := config[:strings.Index(config, "server {")]
+	expected := []string{
+		"limit_req_zone $binary_remote_addr zone=glinr_req_5:10m rate=10r/s;",
+		"limit_conn_zone $binary_remote_addr zone=glinr_conn_5:10m;",
+		"map $http_x_api_key $glinr_limit_key_6 {\n    \"\" $binary_remote_addr;\n    default $http_x_api_key;\n}",
+		"limit_req_zone $glinr_limit_key_6 zone=glinr_req_6:10m rate=100r/s;",
+		"access_log /var/log/nginx/glinr/rate_limited.log glinr_rejected if=$glinr_limit_rejected;",
+	}
+	for _, directive := range expected {
+		if !strings.Contains(http, directive) {
+			t.Errorf("missing %q in:\n%s", directive, http)
+		}
+	}
+	if strings.Contains(config, "glinr_conn_6") || strings.Contains(config, "glinr_req_7") {
+		t.Errorf("expected zones only for the limits routes have")
+	}
+
+	root := config[strings.Index(config, "location / {"):]
+	root = root[:strings.Index(root, "}")]
+	expected = []string{
+		"set $glinr_route_id 5;",
+		"limit_req zone=glinr_req_5 burst=20 nodelay;",
+		"limit_req_status 429;",
+		"limit_conn glinr_conn_5 5;",
+		"limit_conn_status 429;",
+	}
+	for _, directive := range expected {
+		if !strings.Contains(root, directive) {
+			t.Errorf("missing %q in:\n%s", directive, root)
+		}
+	}
+
+	keys := config[strings.Index(config, "location /keys/ {"):]
+	keys = keys[:strings.Index(keys, "}")]
+	if !strings.Contains(keys, "limit_req zone=glinr_req_6;\n        limit_req_status 503;") || strings.Contains(keys, "limit_conn") {
+		t.Errorf("unexpected limits of the /keys location:\n%s", keys)
+	}
+
+	// Without limited routes nothing is declared
+	config, _, err = generator.Render(RenderInput{Routes: input.Routes[2:]})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if strings.Contains(config, "limit_") || strings.Contains(config, "glinr_rejected") {
+		t.Errorf("expected no limit directives without limited routes:\n%s", config)
+	}
+}
+
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestGenerator_Render_RouteKinds(t *testing.T) {
+	generator := NewGenerator("", "")
+
+	input := RenderInput{
+		Routes: []store.RouteWithService{
+			{
+				Route: store.Route{
+					ID:        1,
+					ServiceID: 2,
+					Domain:    "example.com",
+					Port:      8080,
+					Path:      stringPtr("/api"),
+				},
+				ServiceName: "api",
+			},
+			{
+				Route: store.Route{
+					ID:     2,
+					Kind:   store.RouteKindRedirect,
+					Domain: "example.com",
+					Path:   stringPtr("/old"),
+					Redirect: &store.RouteRedirect{
+						URL:        "https://new.example.com/",
+						StatusCode: 308,
+						KeepPath:   true,
+					},
+				},
+			},
+			{
+				Route: store.Route{
+					ID:     3,
+					Kind:   store.RouteKindStatic,
+					Domain: "example.com",
+					Path:   stringPtr("/health"),
+					Static: &store.RouteStatic{Body: `{"price": "$5", "path": "a\b"}`, ContentType: "application/json"},
+				},
+			},
+			{
+				Route: store.Route{
+					ID:     4,
+					Kind:   store.RouteKindStatic,
+					Domain: "example.com",
+					Static: &store.RouteStatic{StatusCode: 503, File: "down/index.html"},
+				},
+			},
+		},
+		Certs: map[string]store.EnhancedCertificate{},
+	}
+
+	config, _, err := generator.Render(input)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	expected := []string{
+		"geo $glinr_dollar {\n    default \"$\";\n}",
+		"location /old/ {\n        return 308 \"https://new.example.com$request_uri\";\n    }",
+		`default_type "application/json";`,
+		`return 200 "{\"price\": \"${glinr_dollar}5\", \"path\": \"a\\b\"}";`,
+		"error_page 418 =503 @glinr_static_4;\n        return 418;",
+		"location @glinr_static_4 {\n        root /etc/nginx/static;\n        try_files /down/index.html =404;\n    }",
+	}
+	for _, directive := range expected {
+		if !strings.Contains(config, directive) {
+			t.Errorf("missing %q in:\n%s", directive, config)
+		}
+	}
+
+	// Only the two locations of the proxy route use an upstream
+	if strings.Count(config, "upstream svc_") != 1 || strings.Count(config, "proxy_pass ") != 2 {
+		t.Errorf("expected one upstream and proxy_pass for the proxy route:\n%s", config)
+	}
+
+	// Without static bodies holding $ the variable is not declared
+	config, _, err = generator.Render(RenderInput{Routes: input.Routes[:2]})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if strings.Contains(config, "glinr_dollar") {
+		t.Errorf("expected no $ variable without static bodies using it:\n%s", config)
+	}
+}
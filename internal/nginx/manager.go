@@ -1,13 +1,17 @@
 package nginx
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/GLINCKER/glinrdock/internal/metrics"
 	"github.com/GLINCKER/glinrdock/internal/store"
 	"github.com/rs/zerolog/log"
 )
@@ -20,6 +24,7 @@ type storeInterface interface {
 	GetLastUpdatedTimestamp(ctx context.Context) (time.Time, error)
 	GetAllRoutesWithServices(ctx context.Context) ([]store.RouteWithService, error)
 	ListCertificates(ctx context.Context) ([]store.EnhancedCertificate, error)
+	ListAllRouteCredentials(ctx context.Context) (map[int64][]store.RouteCredential, error)
 	CreateNginxConfig(ctx context.Context, configHash, configContent string) (store.NginxConfig, error)
 	GetNginxConfigByHash(ctx context.Context, configHash string) (store.NginxConfig, error)
 	SetActiveNginxConfig(ctx context.Context, configID int64) error
@@ -30,10 +35,16 @@ type Manager struct {
 	nginxDirPath      string
 	confDirPath       string
 	certsDirPath      string
+	authDirPath       string
+	logsDirPath       string
+	staticDirPath     string
 	acmeHTTP01DirPath string
 	enabled           bool
 	validator         *Validator
 	reloader          *Reloader
+
+	// How far the log of rejected requests has been read, -1 before the first read
+	rejectedLogOffset int64
 }
 
 // NewManager creates a new nginx manager instance
@@ -43,10 +54,14 @@ func NewManager(dataDir string, enabled bool) *Manager {
 		nginxDirPath:      nginxDirPath,
 		confDirPath:       filepath.Join(nginxDirPath, "conf"),
 		certsDirPath:      filepath.Join(nginxDirPath, "certs"),
+		authDirPath:       filepath.Join(nginxDirPath, "auth"),
+		logsDirPath:       filepath.Join(nginxDirPath, "logs"),
+		staticDirPath:     filepath.Join(nginxDirPath, "static"),
 		acmeHTTP01DirPath: filepath.Join(dataDir, ".var", "acme-http01"),
 		enabled:           enabled,
 		validator:         NewValidator(),
 		reloader:          NewReloader(),
+		rejectedLogOffset: -1,
 	}
 }
 
@@ -74,6 +89,9 @@ func (m *Manager) createDirectories() error {
 		m.nginxDirPath,
 		m.confDirPath,
 		m.certsDirPath,
+		m.authDirPath,
+		m.logsDirPath,
+		m.staticDirPath,
 		m.acmeHTTP01DirPath,
 	}
 
@@ -102,6 +120,21 @@ func (m *Manager) GetCertsDir() string {
 	return m.certsDirPath
 }
 
+// GetAuthDir returns the directory of the htpasswd files of basic auth routes
+func (m *Manager) GetAuthDir() string {
+	return m.authDirPath
+}
+
+// GetLogsDir returns the directory nginx writes the log of rejected requests to
+func (m *Manager) GetLogsDir() string {
+	return m.logsDirPath
+}
+
+// GetStaticDir returns the directory of the files static routes serve
+func (m *Manager) GetStaticDir() string {
+	return m.staticDirPath
+}
+
 // GetACMEHTTP01Dir returns the ACME HTTP-01 challenge directory path
 func (m *Manager) GetACMEHTTP01Dir() string {
 	return m.acmeHTTP01DirPath
@@ -240,6 +273,9 @@ func (m *Manager) Reconcile(ctx context.Context, store storeInterface, generator
 			if err := m.reconcileWithDebounce(ctx, store, generator, &lastUpdateTime, &pendingChangeTime, debounceDelay); err != nil {
 				log.Error().Err(err).Msg("nginx reconcile failed")
 			}
+			if err := m.recordRejectedRequests(ctx); err != nil {
+				log.Warn().Err(err).Msg("failed to read rejected requests log")
+			}
 		}
 	}
 }
@@ -269,6 +305,12 @@ func (m *Manager) reconcileOnce(ctx context.Context, store storeInterface, gener
 		return fmt.Errorf("failed to get routes with services: %w", err)
 	}
 
+	// nginx reads htpasswd files on every request, so they are written even
+	// when the configuration itself is unchanged
+	if err := m.writeHtpasswdFiles(ctx, store, routes); err != nil {
+		return fmt.Errorf("failed to write htpasswd files: %w", err)
+	}
+
 	// Get all certificates and create a map by domain
 	certificates, err := store.ListCertificates(ctx)
 	if err != nil {
@@ -444,6 +486,169 @@ func (m *Manager) RemoveCertificateFiles(ctx context.Context, domain string) err
 	return nil
 }
 
+// writeHtpasswdFiles writes the htpasswd file of every basic auth route and
+// removes the files of routes that no longer ask for basic auth
+func (m *Manager) writeHtpasswdFiles(ctx context.Context, routeStore storeInterface, routes []store.RouteWithService) error {
+	wanted := make(map[string]bool)
+	var credentials map[int64][]store.RouteCredential
+	for _, route := range routes {
+		if !usesBasicAuth(route) {
+			continue
+		}
+		if credentials == nil {
+			var err error
+			if credentials, err = routeStore.ListAllRouteCredentials(ctx); err != nil {
+				return err
+			}
+		}
+
+		name := HtpasswdFileName(route.ID)
+		wanted[name] = true
+		path := filepath.Join(m.authDirPath, name)
+		if err := m.atomicWriteFile(path, Htpasswd(credentials[route.ID])); err != nil {
+			return fmt.Errorf("route %d: %w", route.ID, err)
+		}
+		// nginx workers read the file, it only holds bcrypt hashes
+		if err := os.Chmod(path, 0644); err != nil {
+			return fmt.Errorf("route %d: %w", route.ID, err)
+		}
+	}
+
+	entries, err := os.ReadDir(m.authDirPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".htpasswd") && !wanted[entry.Name()] {
+			if err := os.Remove(filepath.Join(m.authDirPath, entry.Name())); err != nil && !os.IsNotExist(err) {
+				log.Warn().Str("file", entry.Name()).Err(err).Msg("failed to remove htpasswd file")
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordRejectedRequests counts the requests nginx logged as rejected by
+// route limits since the last call in the route metrics
+func (m *Manager) recordRejectedRequests(ctx context.Context) error {
+	counts, err := m.readRejectedRequests(ctx)
+	if err != nil {
+		return err
+	}
+	for key, count := range counts {
+		metrics.RecordRouteRejected(key.routeID, key.status, count)
+	}
+	return nil
+}
+
+// rejectedRequests identifies the requests of a route rejected with a status
+type rejectedRequests struct {
+	routeID int64
+	status  int
+}
+
+// maxRejectedLogSize is the size past which the log of rejected requests is
+// rotated once it has been read
+const maxRejectedLogSize = 1 << 20
+
+// readRejectedRequests returns how many requests were rejected by route limits
+// since the last call, per route and status. The first call only skips the
+// lines logged before glinrdock started.
+func (m *Manager) readRejectedRequests(ctx context.Context) (map[rejectedRequests]int, error) {
+	path := filepath.Join(m.logsDirPath, RejectedLogFileName)
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if m.rejectedLogOffset < 0 || info.Size() < m.rejectedLogOffset {
+		// First read, or the log was replaced elsewhere
+		m.rejectedLogOffset = info.Size()
+		return nil, nil
+	}
+
+	data, err := readFromOffset(file, m.rejectedLogOffset)
+	if err != nil {
+		return nil, err
+	}
+	// A line nginx is still writing is read next time
+	end := bytes.LastIndexByte(data, '\n') + 1
+	m.rejectedLogOffset += int64(end)
+
+	counts := make(map[rejectedRequests]int)
+	countRejectedRequests(data[:end], counts)
+
+	if m.rejectedLogOffset >= maxRejectedLogSize {
+		if err := m.rotateRejectedLog(ctx, file, path, counts); err != nil {
+			log.Debug().Err(err).Msg("rejected requests log not rotated, it keeps growing")
+		}
+	}
+
+	return counts, nil
+}
+
+// rotateRejectedLog renames the log of rejected requests and has nginx reopen
+// it, so that nginx goes on in a new file. The lines nginx appended to the
+// renamed file before reopening it are added to counts, then it is removed.
+// When nginx cannot reopen its logs the file keeps its name and is not rotated.
+func (m *Manager) rotateRejectedLog(ctx context.Context, file *os.File, path string, counts map[rejectedRequests]int) error {
+	rotatedPath := path + ".1"
+	if err := os.Rename(path, rotatedPath); err != nil {
+		return fmt.Errorf("failed to rename rejected requests log: %w", err)
+	}
+
+	if err := m.reloader.Reopen(ctx); err != nil {
+		// nginx still writes to the renamed file
+		if renameErr := os.Rename(rotatedPath, path); renameErr != nil {
+			return fmt.Errorf("failed to restore rejected requests log after %v: %w", err, renameErr)
+		}
+		return err
+	}
+
+	data, err := readFromOffset(file, m.rejectedLogOffset)
+	if err != nil {
+		return fmt.Errorf("failed to read rotated rejected requests log: %w", err)
+	}
+	countRejectedRequests(data, counts)
+	m.rejectedLogOffset = 0
+
+	return os.Remove(rotatedPath)
+}
+
+// readFromOffset reads a file from offset to its end
+func readFromOffset(file *os.File, offset int64) ([]byte, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
+
+// countRejectedRequests adds the "<route id> <status>" lines of the log of
+// rejected requests to counts, skipping lines it cannot parse
+func countRejectedRequests(data []byte, counts map[rejectedRequests]int) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		routeID, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		status, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		counts[rejectedRequests{routeID: routeID, status: status}]++
+	}
+}
+
 // atomicWriteFile atomically writes content to a file
 func (m *Manager) atomicWriteFile(filePath, content string) error {
 	// Ensure the target directory exists
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
 )
 
 // skipNginxTestsInCI skips nginx tests in CI environment where nginx validation
@@ -181,6 +183,127 @@ func TestManager_atomicWriteConfig(t *testing.T) {
 	}
 }
 
+// credentialStore returns the basic auth users of routes
+type credentialStore struct {
+	storeInterface
+	credentials map[int64][]store.RouteCredential
+}
+
+func (s *credentialStore) ListAllRouteCredentials(ctx context.Context) (map[int64][]store.RouteCredential, error) {
+	return s.credentials, nil
+}
+
+func TestManager_writeHtpasswdFiles(t *testing.T) {
+	manager := NewManager(t.TempDir(), true)
+	if err := manager.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+
+	stale := filepath.Join(manager.GetAuthDir(), HtpasswdFileName(9))
+	if err := os.WriteFile(stale, []byte("old:hash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials := &credentialStore{credentials: map[int64][]store.RouteCredential{
+		1: {{RouteID: 1, Username: "ops", PasswordHash: "$2a$10$hash"}},
+		2: {{RouteID: 2, Username: "unused", PasswordHash: "$2a$10$hash"}},
+	}}
+	routes := []store.RouteWithService{
+		{Route: store.Route{ID: 1, Access: &store.RouteAccess{BasicAuth: &store.BasicAuthPolicy{}}}},
+		{Route: store.Route{ID: 2, Access: &store.RouteAccess{Allow: []string{"10.0.0.0/8"}}}},
+		{Route: store.Route{ID: 3, Access: &store.RouteAccess{BasicAuth: &store.BasicAuthPolicy{}}}},
+	}
+	if err := manager.writeHtpasswdFiles(context.Background(), credentials, routes); err != nil {
+		t.Fatalf("writeHtpasswdFiles() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(manager.GetAuthDir(), HtpasswdFileName(1)))
+	if err != nil || string(content) != "ops:$2a$10$hash\n" {
+		t.Errorf("unexpected htpasswd file of route 1: %q, %v", content, err)
+	}
+	info, err := os.Stat(filepath.Join(manager.GetAuthDir(), HtpasswdFileName(1)))
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expected the htpasswd file to be readable by nginx workers, got %v, %v", info.Mode(), err)
+	}
+
+	// A basic auth route without users gets an empty file, so nobody gets in
+	content, err = os.ReadFile(filepath.Join(manager.GetAuthDir(), HtpasswdFileName(3)))
+	if err != nil || len(content) != 0 {
+		t.Errorf("expected an empty htpasswd file for route 3: %q, %v", content, err)
+	}
+
+	for _, routeID := range []int64{2, 9} {
+		if _, err := os.Stat(filepath.Join(manager.GetAuthDir(), HtpasswdFileName(routeID))); !os.IsNotExist(err) {
+			t.Errorf("expected no htpasswd file for route %d", routeID)
+		}
+	}
+}
+
+func TestManager_readRejectedRequests(t *testing.T) {
+	manager := NewManager(t.TempDir(), true)
+	if err := manager.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	path := filepath.Join(manager.GetLogsDir(), RejectedLogFileName)
+	appendLog := func(lines string) {
+		t.Helper()
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if _, err := file.WriteString(lines); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lines logged before glinrdock started are skipped
+	appendLog("1 429\n")
+	counts, err := manager.readRejectedRequests(context.Background())
+	if err != nil || len(counts) != 0 {
+		t.Fatalf("expected the first read to skip old lines, got %v, %v", counts, err)
+	}
+
+	appendLog("1 429\n1 429\n2 503\ngarbage\n2 50")
+	counts, err = manager.readRejectedRequests(context.Background())
+	if err != nil {
+		t.Fatalf("readRejectedRequests() failed: %v", err)
+	}
+	expected := map[rejectedRequests]int{{routeID: 1, status: 429}: 2, {routeID: 2, status: 503}: 1}
+	if len(counts) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+	for key, count := range expected {
+		if counts[key] != count {
+			t.Errorf("expected %d requests of %v, got %d", count, key, counts[key])
+		}
+	}
+
+	// The line being written is counted once complete
+	appendLog("3\n")
+	counts, err = manager.readRejectedRequests(context.Background())
+	if err != nil || len(counts) != 1 || counts[rejectedRequests{routeID: 2, status: 503}] != 1 {
+		t.Errorf("expected the completed line to be counted, got %v, %v", counts, err)
+	}
+
+	// Past its size limit the log is kept when nginx cannot reopen it
+	manager.reloader.DisableDockerReload()
+	lines := maxRejectedLogSize/len("1 429\n") + 1
+	appendLog(strings.Repeat("1 429\n", lines))
+	counts, err = manager.readRejectedRequests(context.Background())
+	if err != nil || counts[rejectedRequests{routeID: 1, status: 429}] != lines {
+		t.Errorf("expected %d requests, got %v, %v", lines, counts, err)
+	}
+	appendLog("2 503\n")
+	counts, err = manager.readRejectedRequests(context.Background())
+	if err != nil || len(counts) != 1 || counts[rejectedRequests{routeID: 2, status: 503}] != 1 {
+		t.Errorf("expected the line after the limit to be counted, got %v, %v", counts, err)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("expected no rotated log")
+	}
+}
+
 func TestManager_Apply_Disabled(t *testing.T) {
 	dataDir := filepath.Join("/tmp", "test-nginx-apply-disabled")
 	manager := NewManager(dataDir, false) // disabled
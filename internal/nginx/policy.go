@@ -0,0 +1,195 @@
+package nginx
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// connectionUpgradeVariable is set by the map rendered for WebSocket routes
+const connectionUpgradeVariable = "$glinr_connection_upgrade"
+
+// gzipTypes are the response types gzip compresses besides text/html
+const gzipTypes = "text/plain text/css text/xml text/javascript application/javascript application/json application/xml application/rss+xml image/svg+xml"
+
+// standardProxyHeaders are sent to every service. nginx drops inherited
+// proxy_set_header directives from a location that sets its own, so locations
+// with custom request headers repeat them.
+var standardProxyHeaders = []string{
+	"proxy_set_header Host $host;",
+	"proxy_set_header X-Real-IP $remote_addr;",
+	"proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;",
+	"proxy_set_header X-Forwarded-Proto $scheme;",
+	"proxy_set_header X-Forwarded-Host $server_name;",
+	"proxy_set_header X-Forwarded-Port $server_port;",
+}
+
+// securityHeaders are added to HTTPS responses. Like proxy_set_header,
+// add_header is not inherited by a location that adds its own headers.
+var securityHeaders = []string{
+	`add_header Strict-Transport-Security "max-age=31536000; includeSubDomains" always;`,
+	"add_header X-Content-Type-Options nosniff always;",
+	"add_header X-Frame-Options DENY always;",
+	`add_header X-XSS-Protection "1; mode=block" always;`,
+}
+
+// PolicyDirectives returns the nginx directives of a route's proxy policy for
+// its location block. secured tells whether the server block adds the HTTPS
+// security headers, which are repeated when the policy adds headers of its own.
+func PolicyDirectives(route store.RouteWithService, secured bool) []string {
+	policy := route.ProxyConfig
+	if policy == nil {
+		return nil
+	}
+
+	var directives []string
+	if len(policy.RequestHeaders) > 0 || policy.WebSocket {
+		// Forward auth routes repeat them with their access directives
+		if !usesForwardAuth(route) {
+			directives = append(directives, standardProxyHeaders...)
+		}
+		for _, name := range sortedKeys(policy.RequestHeaders) {
+			directives = append(directives, fmt.Sprintf("proxy_set_header %s %s;", name, quote(policy.RequestHeaders[name])))
+		}
+	}
+	if policy.WebSocket {
+		directives = append(directives,
+			"proxy_http_version 1.1;",
+			"proxy_set_header Upgrade $http_upgrade;",
+			"proxy_set_header Connection "+connectionUpgradeVariable+";",
+		)
+	}
+
+	if policy.ConnectTimeout > 0 {
+		directives = append(directives, fmt.Sprintf("proxy_connect_timeout %ds;", policy.ConnectTimeout))
+	}
+	if policy.SendTimeout > 0 {
+		directives = append(directives, fmt.Sprintf("proxy_send_timeout %ds;", policy.SendTimeout))
+	}
+	if policy.ReadTimeout > 0 {
+		directives = append(directives, fmt.Sprintf("proxy_read_timeout %ds;", policy.ReadTimeout))
+	}
+	if policy.MaxBodySize != "" {
+		directives = append(directives, fmt.Sprintf("client_max_body_size %s;", policy.MaxBodySize))
+	}
+	if policy.BufferingDisabled() {
+		directives = append(directives, "proxy_buffering off;", "proxy_request_buffering off;")
+	}
+	if policy.Gzip {
+		directives = append(directives,
+			"gzip on;",
+			"gzip_proxied any;",
+			"gzip_vary on;",
+			"gzip_min_length 1024;",
+			"gzip_types "+gzipTypes+";",
+		)
+	}
+
+	if len(policy.ResponseHeaders) > 0 || policy.CORS != nil {
+		if secured {
+			directives = append(directives, securityHeaders...)
+		}
+		for _, name := range sortedKeys(policy.ResponseHeaders) {
+			directives = append(directives, fmt.Sprintf("add_header %s %s always;", name, quote(policy.ResponseHeaders[name])))
+		}
+	}
+	if policy.CORS != nil {
+		directives = append(directives, corsDirectives(*policy.CORS)...)
+	}
+
+	return directives
+}
+
+// corsDirectives answers preflight requests and adds the CORS headers to
+// responses of allowed origins
+func corsDirectives(cors store.CORSPolicy) []string {
+	origin := `"*"`
+	var directives []string
+	if !cors.AllowsAnyOrigin() {
+		patterns := make([]string, len(cors.AllowOrigins))
+		for i, allowed := range cors.AllowOrigins {
+			patterns[i] = regexp.QuoteMeta(allowed)
+		}
+		origin = "$glinr_cors_origin"
+		directives = append(directives,
+			`set $glinr_cors_origin "";`,
+			fmt.Sprintf("if ($http_origin ~ %s) {", quote("^("+strings.Join(patterns, "|")+")$")),
+			"    set $glinr_cors_origin $http_origin;",
+			"}",
+		)
+	}
+
+	headers := []string{fmt.Sprintf("add_header Access-Control-Allow-Origin %s always;", origin)}
+	if !cors.AllowsAnyOrigin() {
+		headers = append(headers, "add_header Vary Origin always;")
+	}
+	if cors.AllowCredentials {
+		headers = append(headers, `add_header Access-Control-Allow-Credentials "true" always;`)
+	}
+	directives = append(directives, headers...)
+	if len(cors.ExposeHeaders) > 0 {
+		directives = append(directives, fmt.Sprintf("add_header Access-Control-Expose-Headers %s always;", quote(strings.Join(cors.ExposeHeaders, ", "))))
+	}
+
+	// Preflight requests are answered here. Headers added inside the if
+	// replace those of the location, so the origin headers are repeated.
+	methods := cors.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	maxAge := cors.MaxAge
+	if maxAge == 0 {
+		maxAge = store.DefaultCORSMaxAge
+	}
+	directives = append(directives,
+		`set $glinr_cors_preflight "";`,
+		"if ($request_method = OPTIONS) {",
+		"    set $glinr_cors_preflight $http_origin;",
+		"}",
+		`if ($glinr_cors_preflight != "") {`,
+	)
+	for _, header := range headers {
+		directives = append(directives, "    "+header)
+	}
+	directives = append(directives, fmt.Sprintf("    add_header Access-Control-Allow-Methods %s always;", quote(strings.Join(methods, ", "))))
+	if len(cors.AllowHeaders) > 0 {
+		directives = append(directives, fmt.Sprintf("    add_header Access-Control-Allow-Headers %s always;", quote(strings.Join(cors.AllowHeaders, ", "))))
+	} else {
+		directives = append(directives, "    add_header Access-Control-Allow-Headers $http_access_control_request_headers always;")
+	}
+	directives = append(directives,
+		fmt.Sprintf("    add_header Access-Control-Max-Age %d always;", maxAge),
+		"    return 204;",
+		"}",
+	)
+
+	return directives
+}
+
+// usesWebSocket reports whether any route passes WebSocket upgrades through
+func usesWebSocket(routes []store.RouteWithService) bool {
+	for _, route := range routes {
+		if route.ProxyConfig != nil && route.ProxyConfig.WebSocket {
+			return true
+		}
+	}
+	return false
+}
+
+// quote returns value as a double quoted nginx string
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// sortedKeys returns the keys of headers in order, for deterministic output
+func sortedKeys(headers map[string]string) []string {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
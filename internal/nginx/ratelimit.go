@@ -0,0 +1,127 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// rateLimitZoneSize is the shared memory of each zone, enough for about
+// 160,000 client addresses
+const rateLimitZoneSize = "10m"
+
+// rejectedLogPath is where nginx logs the requests turned away by route
+// rate and connection limits, one "<route id> <status>" line each
+const rejectedLogPath = "/var/log/nginx/glinr/" + RejectedLogFileName
+
+// RejectedLogFileName is the name of the log of rejected requests
+const RejectedLogFileName = "rate_limited.log"
+
+// routeIDVariable tells the rejected request log which route a request was for
+const routeIDVariable = "$glinr_route_id"
+
+// RateLimitZones returns the http level directives the rate and connection
+// limits of routes use: the zones counting clients, the keys of routes counting
+// clients by header, and the log of rejected requests
+func RateLimitZones(routes []store.RouteWithService) []string {
+	var zones, keys []string
+	for _, route := range routes {
+		limit := rateLimit(route)
+		if limit == nil {
+			continue
+		}
+
+		key := "$binary_remote_addr"
+		if header := limit.KeyHeader(); header != "" {
+			// Requests without the header are counted by address, so leaving it
+			// out does not get around the limit
+			key = rateLimitKeyVariable(route.ID)
+			keys = append(keys,
+				fmt.Sprintf("map %s %s {", headerVariable(header), key),
+				`    "" $binary_remote_addr;`,
+				fmt.Sprintf("    default %s;", headerVariable(header)),
+				"}",
+			)
+		}
+		if limit.RequestsPerSecond > 0 {
+			zones = append(zones, fmt.Sprintf("limit_req_zone %s zone=%s:%s rate=%dr/s;",
+				key, requestZone(route.ID), rateLimitZoneSize, limit.RequestsPerSecond))
+		}
+		if limit.Connections > 0 {
+			zones = append(zones, fmt.Sprintf("limit_conn_zone %s zone=%s:%s;",
+				key, connectionZone(route.ID), rateLimitZoneSize))
+		}
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+
+	directives := append(keys, zones...)
+	return append(directives,
+		`map "$limit_req_status$limit_conn_status" $glinr_limit_rejected {`,
+		"    ~REJECTED 1;",
+		"    default 0;",
+		"}",
+		fmt.Sprintf("log_format glinr_rejected '%s $status';", routeIDVariable),
+		fmt.Sprintf("access_log %s glinr_rejected if=$glinr_limit_rejected;", rejectedLogPath),
+	)
+}
+
+// RateLimitDirectives returns the nginx directives of a route's rate and
+// connection limits for its location block
+func RateLimitDirectives(route store.RouteWithService) []string {
+	limit := rateLimit(route)
+	if limit == nil {
+		return nil
+	}
+
+	directives := []string{fmt.Sprintf("set %s %d;", routeIDVariable, route.ID)}
+	if limit.RequestsPerSecond > 0 {
+		directive := "limit_req zone=" + requestZone(route.ID)
+		if limit.Burst > 0 {
+			directive += fmt.Sprintf(" burst=%d", limit.Burst)
+		}
+		if limit.NoDelay {
+			directive += " nodelay"
+		}
+		directives = append(directives, directive+";", fmt.Sprintf("limit_req_status %d;", limit.StatusOrDefault()))
+	}
+	if limit.Connections > 0 {
+		directives = append(directives,
+			fmt.Sprintf("limit_conn %s %d;", connectionZone(route.ID), limit.Connections),
+			fmt.Sprintf("limit_conn_status %d;", limit.StatusOrDefault()),
+		)
+	}
+	return directives
+}
+
+// rateLimit returns the limits of a route, or nil when it has none. Routes in
+// a maintenance window are answered without reaching their limits.
+func rateLimit(route store.RouteWithService) *store.RateLimitPolicy {
+	if route.ProxyConfig == nil || route.MaintenancePage != nil {
+		return nil
+	}
+	return route.ProxyConfig.RateLimit
+}
+
+// requestZone returns the name of the zone counting the request rate of a route
+func requestZone(routeID int64) string {
+	return fmt.Sprintf("glinr_req_%d", routeID)
+}
+
+// connectionZone returns the name of the zone counting the connections of a route
+func connectionZone(routeID int64) string {
+	return fmt.Sprintf("glinr_conn_%d", routeID)
+}
+
+// rateLimitKeyVariable returns the variable clients of a route are counted by
+// when the route counts them by header
+func rateLimitKeyVariable(routeID int64) string {
+	return fmt.Sprintf("$glinr_limit_key_%d", routeID)
+}
+
+// headerVariable returns the nginx variable holding a request header
+func headerVariable(header string) string {
+	return "$http_" + strings.ReplaceAll(strings.ToLower(header), "-", "_")
+}
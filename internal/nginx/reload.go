@@ -78,6 +78,32 @@ func (r *Reloader) Reload(ctx context.Context) error {
 	return nil
 }
 
+// Reopen makes nginx reopen its log files, after one of them was renamed to
+// rotate it. Unlike Reload it fails when nginx cannot be signalled, since the
+// caller relies on nginx writing to a new file.
+func (r *Reloader) Reopen(ctx context.Context) error {
+	reopenCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if !r.useDocker || r.dockerContainer == "" {
+		return fmt.Errorf("nginx logs can only be reopened with docker reload enabled")
+	}
+	if !r.isContainerRunning(reopenCtx) {
+		return fmt.Errorf("docker container %s is not running", r.dockerContainer)
+	}
+
+	cmd := exec.CommandContext(reopenCtx, "docker", "exec", r.dockerContainer, "nginx", "-s", "reopen")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nginx log reopen failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	log.Debug().
+		Str("container", r.dockerContainer).
+		Msg("nginx log files reopened")
+
+	return nil
+}
+
 // ReloadConfiguration reloads nginx with the new configuration (legacy method)
 func (r *Reloader) ReloadConfiguration(ctx context.Context, configPath string) error {
 	log.Info().Str("config_path", configPath).Msg("reloading nginx configuration")
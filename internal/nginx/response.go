@@ -0,0 +1,83 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// staticRoot is where nginx finds the files static routes serve
+const staticRoot = "/etc/nginx/static"
+
+// dollarName is the variable holding a literal $, which nginx strings have no
+// escape for
+const dollarName = "glinr_dollar"
+
+// staticFileStatus is answered by static routes serving a file, error_page
+// turns it into the route's status with the file as the body
+const staticFileStatus = 418
+
+// ResponseDirectives returns the directives with which nginx answers the
+// requests of a redirect or static route itself
+func ResponseDirectives(route store.RouteWithService) []string {
+	switch {
+	case route.Kind == store.RouteKindRedirect && route.Redirect != nil:
+		redirect := route.Redirect
+		target := redirect.URL
+		if redirect.KeepPath {
+			target = strings.TrimSuffix(target, "/") + "$request_uri"
+		}
+		return []string{fmt.Sprintf("return %d %s;", redirect.StatusOrDefault(), quote(target))}
+
+	case route.Kind == store.RouteKindStatic && route.Static != nil:
+		static := route.Static
+		if static.File != "" {
+			return []string{
+				fmt.Sprintf("error_page %d =%d %s;", staticFileStatus, static.StatusOrDefault(), StaticFileLocation(route.ID)),
+				fmt.Sprintf("return %d;", staticFileStatus),
+			}
+		}
+		body := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "${"+dollarName+"}").Replace(static.Body)
+		return []string{
+			fmt.Sprintf("default_type %s;", quote(static.ContentTypeOrDefault())),
+			fmt.Sprintf(`return %d "%s";`, static.StatusOrDefault(), body),
+		}
+	}
+	return nil
+}
+
+// StaticFileLocation returns the named location serving the file of a static route
+func StaticFileLocation(routeID int64) string {
+	return fmt.Sprintf("@glinr_static_%d", routeID)
+}
+
+// StaticFileDirectives returns the directives of the named location serving
+// the file of a static route. nginx picks its type from the extension.
+func StaticFileDirectives(route store.RouteWithService) []string {
+	return []string{
+		fmt.Sprintf("root %s;", staticRoot),
+		fmt.Sprintf("try_files /%s =404;", route.Static.File),
+	}
+}
+
+// staticFileRoutes returns the static routes among routes that serve a file
+func staticFileRoutes(routes []store.RouteWithService) []store.RouteWithService {
+	var files []store.RouteWithService
+	for _, route := range routes {
+		if route.Kind == store.RouteKindStatic && route.Static != nil && route.Static.File != "" {
+			files = append(files, route)
+		}
+	}
+	return files
+}
+
+// usesDollar reports whether the body of any static route holds a $
+func usesDollar(routes []store.RouteWithService) bool {
+	for _, route := range routes {
+		if route.Kind == store.RouteKindStatic && route.Static != nil && strings.Contains(route.Static.Body, "$") {
+			return true
+		}
+	}
+	return false
+}
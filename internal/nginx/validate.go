@@ -69,11 +69,14 @@ func (v *Validator) ValidateConfiguration(ctx context.Context, configPath string
 
 // ValidateConfig validates nginx configuration string (without writing to file)
 func (v *Validator) ValidateConfig(ctx context.Context, configContent string) error {
-	// For now, just do basic syntax checking
 	if strings.TrimSpace(configContent) == "" {
 		return fmt.Errorf("nginx configuration is empty")
 	}
 
+	if err := checkSyntax(configContent); err != nil {
+		return err
+	}
+
 	// Check for basic nginx syntax elements
 	if !strings.Contains(configContent, "server") && !strings.Contains(configContent, "upstream") {
 		log.Warn().Msg("nginx configuration may be incomplete - no server or upstream blocks found")
@@ -82,6 +85,76 @@ func (v *Validator) ValidateConfig(ctx context.Context, configContent string) er
 	return nil
 }
 
+// checkSyntax checks the structure nginx parses a configuration with: quoted
+// strings are closed, every directive ends with ; or opens a block, and blocks
+// are balanced. It does not know which directives exist or where they are allowed.
+func checkSyntax(config string) error {
+	line, depth := 1, 0
+	words := 0 // words of the directive being read
+	for i := 0; i < len(config); i++ {
+		switch ch := config[i]; {
+		case ch == '\n':
+			line++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+		case ch == '#' && (i == 0 || isSpace(config[i-1])):
+			for i < len(config)-1 && config[i+1] != '\n' {
+				i++
+			}
+		case ch == ';':
+			if words == 0 {
+				return fmt.Errorf("line %d: unexpected \";\"", line)
+			}
+			words = 0
+		case ch == '{':
+			if words == 0 {
+				return fmt.Errorf("line %d: unexpected \"{\"", line)
+			}
+			words = 0
+			depth++
+		case ch == '}':
+			if words > 0 {
+				return fmt.Errorf("line %d: directive is not terminated by \";\"", line)
+			}
+			if depth == 0 {
+				return fmt.Errorf("line %d: unexpected \"}\"", line)
+			}
+			depth--
+		case ch == '"' || ch == '\'':
+			start := line
+			for i++; i < len(config) && config[i] != ch; i++ {
+				if config[i] == '\\' {
+					i++
+				}
+				if i < len(config) && config[i] == '\n' {
+					line++
+				}
+			}
+			if i >= len(config) {
+				return fmt.Errorf("line %d: unterminated quoted string", start)
+			}
+			words++
+		default:
+			for i < len(config)-1 && !isSpace(config[i+1]) && !strings.ContainsRune(";{}", rune(config[i+1])) {
+				i++
+			}
+			words++
+		}
+	}
+
+	if words > 0 {
+		return fmt.Errorf("line %d: directive is not terminated by \";\"", line)
+	}
+	if depth > 0 {
+		return fmt.Errorf("line %d: unexpected end of configuration, expecting \"}\"", line)
+	}
+	return nil
+}
+
+// isSpace reports whether ch separates words in an nginx configuration
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
+
 // validateWithDocker validates nginx config using docker exec
 func (v *Validator) validateWithDocker(ctx context.Context, configPath string) error {
 	log.Debug().Str("container", v.dockerContainer).Msg("validating nginx config with docker")
@@ -397,3 +397,60 @@ func TestShouldUseDockerReload(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckSyntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:   "nested blocks and quoted strings",
+			config: "server {\n    location / {\n        return 503 \"a \\\"quoted\\\" {page};\";\n    }\n}\n",
+		},
+		{
+			name:   "comments",
+			config: "# server {\nworker_processes auto; # trailing }\n",
+		},
+		{
+			name:    "unterminated directive",
+			config:  "server {\n    listen 80\n}",
+			wantErr: "line 3: directive is not terminated",
+		},
+		{
+			name:    "unclosed block",
+			config:  "server {\n    listen 80;\n",
+			wantErr: "expecting \"}\"",
+		},
+		{
+			name:    "unexpected closing brace",
+			config:  "listen 80;\n}",
+			wantErr: "line 2: unexpected \"}\"",
+		},
+		{
+			name:    "unterminated string",
+			config:  "add_header X-Test \"value;\n",
+			wantErr: "line 1: unterminated quoted string",
+		},
+		{
+			name:    "block without a name",
+			config:  "{\n}",
+			wantErr: "line 1: unexpected \"{\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSyntax(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
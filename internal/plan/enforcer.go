@@ -201,6 +201,9 @@ func (e *Enforcer) FeatureEnabled(feature string) bool {
 	case "ci_integrations":
 		return e.plan == config.PlanPro || e.plan == config.PlanPremium
 
+	case "web_terminal":
+		return e.plan == config.PlanPro || e.plan == config.PlanPremium
+
 	case "advanced_dashboards":
 		return e.plan == config.PlanPremium
 
@@ -263,7 +266,7 @@ func (e *Enforcer) GetFeatures() []string {
 		features = append(features, "oauth", "multi_env", "sso", "audit_logs", "advanced_dashboards")
 		fallthrough
 	case config.PlanPro:
-		features = append(features, "smtp_alerts", "ci_integrations", "ssl_certs")
+		features = append(features, "smtp_alerts", "ci_integrations", "ssl_certs", "web_terminal")
 	}
 
 	return features
@@ -207,6 +207,18 @@ func TestEnforcer_FeatureEnabled(t *testing.T) {
 			feature:  "ci_integrations",
 			expected: true,
 		},
+		{
+			name:     "FREE plan web terminal",
+			plan:     config.PlanFree,
+			feature:  "web_terminal",
+			expected: false,
+		},
+		{
+			name:     "PRO plan web terminal",
+			plan:     config.PlanPro,
+			feature:  "web_terminal",
+			expected: true,
+		},
 
 		// Premium features
 		{
@@ -222,6 +222,10 @@ func (nc *NginxConfig) GenerateConfig(ctx context.Context) error {
 	// Build route data with service information
 	var routeData []RouteData
 	for _, route := range routes {
+		// Redirect and static routes are rendered by the nginx package generator
+		if !route.Proxies() {
+			continue
+		}
 		service, err := nc.store.GetService(ctx, route.ServiceID)
 		if err != nil {
 			log.Warn().Err(err).Int64("service_id", route.ServiceID).Msg("skipping route for missing service")
@@ -434,6 +438,10 @@ func (nc *NginxConfig) calculateConfigHash(ctx context.Context) (string, string,
 	// Build route data with service information
 	var routeData []RouteData
 	for _, route := range routes {
+		// Redirect and static routes are rendered by the nginx package generator
+		if !route.Proxies() {
+			continue
+		}
 		service, err := nc.store.GetService(ctx, route.ServiceID)
 		if err != nil {
 			log.Warn().Err(err).Int64("service_id", route.ServiceID).Msg("skipping route for missing service")
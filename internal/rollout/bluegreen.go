@@ -0,0 +1,314 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultHealthTimeout is how long a new container has to pass its health check
+	DefaultHealthTimeout = 60 * time.Second
+	// DefaultHealthInterval is the delay between health probes during a rollout
+	DefaultHealthInterval = 2 * time.Second
+	// DefaultDrainPeriod is how long the old container keeps serving in-flight requests after the switch
+	DefaultDrainPeriod = 10 * time.Second
+)
+
+// Colors alternated between consecutive blue/green deployments
+const (
+	ColorBlue  = "blue"
+	ColorGreen = "green"
+)
+
+// Engine is the subset of dockerx.Engine needed to roll out containers
+type Engine interface {
+	Inspector
+	Create(ctx context.Context, name string, spec dockerx.ContainerSpec, labels map[string]string) (string, error)
+	Start(ctx context.Context, id string) error
+	Stop(ctx context.Context, id string) error
+	Remove(ctx context.Context, id string) error
+	Rename(ctx context.Context, id string, newName string) error
+	EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error
+	ConnectNetwork(ctx context.Context, networkName, containerID string, aliases []string) error
+}
+
+// Store persists the container and upstream of a service across a switch
+type Store interface {
+	GetProject(ctx context.Context, id int64) (store.Project, error)
+	UpdateServiceContainerID(ctx context.Context, id int64, containerID string) error
+	UpdateServiceUpstreamHost(ctx context.Context, id int64, host string) error
+}
+
+// ReloadFunc regenerates the nginx configuration from the store and reloads nginx
+type ReloadFunc func(ctx context.Context) error
+
+// BlueGreen rolls out a new container next to the running one and moves
+// traffic over by switching the service's nginx upstream to it.
+//
+// The new container does not publish fixed host ports, since the old one
+// still holds them. It is reached through the proxy on the project network.
+// Services with fixed host ports therefore cannot use blue/green.
+type BlueGreen struct {
+	engine Engine
+	prober Prober
+	store  Store
+	reload ReloadFunc
+
+	HealthTimeout  time.Duration
+	HealthInterval time.Duration
+	DrainPeriod    time.Duration
+}
+
+// NewBlueGreen creates a blue/green rollout with default timings
+func NewBlueGreen(engine Engine, prober Prober, rolloutStore Store, reload ReloadFunc) *BlueGreen {
+	return &BlueGreen{
+		engine:         engine,
+		prober:         prober,
+		store:          rolloutStore,
+		reload:         reload,
+		HealthTimeout:  DefaultHealthTimeout,
+		HealthInterval: DefaultHealthInterval,
+		DrainPeriod:    DefaultDrainPeriod,
+	}
+}
+
+// UpstreamAlias returns the network alias a service's container is reachable at for the given color
+func UpstreamAlias(serviceID int64, color string) string {
+	return fmt.Sprintf("svc-%d-%s", serviceID, color)
+}
+
+// NextColor returns the color for the next deployment given the current upstream host
+func NextColor(upstreamHost *string) string {
+	if upstreamHost != nil && strings.HasSuffix(*upstreamHost, "-"+ColorBlue) {
+		return ColorGreen
+	}
+	return ColorBlue
+}
+
+// Deploy starts a container from spec alongside service.ContainerID, waits for
+// it to become healthy, points the nginx upstream at it, drains and removes
+// the old container and returns the new container ID. Until the upstream is
+// switched the old container keeps serving; on failure it is left untouched.
+func (b *BlueGreen) Deploy(ctx context.Context, service store.Service, spec dockerx.ContainerSpec, labels map[string]string) (string, error) {
+	color := NextColor(service.UpstreamHost)
+	alias := UpstreamAlias(service.ID, color)
+	containerName := fmt.Sprintf("glinr_%d_%s", service.ProjectID, service.Name)
+	candidateName := fmt.Sprintf("%s_%s", containerName, color)
+
+	var oldID string
+	if service.ContainerID != nil {
+		oldID = *service.ContainerID
+	}
+
+	// Let Docker assign host ports, the old container still holds the configured ones
+	candidateSpec := spec
+	candidateSpec.Ports = make([]store.PortMap, len(spec.Ports))
+	for i, port := range spec.Ports {
+		candidateSpec.Ports[i] = store.PortMap{Container: port.Container}
+	}
+
+	candidateLabels := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		candidateLabels[key] = value
+	}
+	candidateLabels["glinr.color"] = color
+
+	// A candidate left behind by an interrupted rollout would block the name
+	if err := b.engine.Remove(ctx, candidateName); err == nil {
+		log.Info().Str("container_name", candidateName).Msg("removed stale blue/green candidate")
+	}
+
+	newID, err := b.engine.Create(ctx, candidateName, candidateSpec, candidateLabels)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s container: %w", color, err)
+	}
+
+	if err := b.connect(ctx, service, newID, alias); err != nil {
+		b.discard(ctx, newID)
+		return "", err
+	}
+
+	if err := b.engine.Start(ctx, newID); err != nil {
+		b.discard(ctx, newID)
+		return "", fmt.Errorf("failed to start %s container: %w", color, err)
+	}
+
+	if err := b.waitHealthy(ctx, service, spec, newID); err != nil {
+		b.discard(ctx, newID)
+		return "", fmt.Errorf("%s container failed health check: %w", color, err)
+	}
+
+	// Switch traffic to the new container
+	var previousHost string
+	if service.UpstreamHost != nil {
+		previousHost = *service.UpstreamHost
+	}
+	if err := b.store.UpdateServiceUpstreamHost(ctx, service.ID, alias); err != nil {
+		b.discard(ctx, newID)
+		return "", fmt.Errorf("failed to store upstream host: %w", err)
+	}
+	if err := b.reload(ctx); err != nil {
+		b.revert(ctx, service.ID, previousHost)
+		b.discard(ctx, newID)
+		return "", fmt.Errorf("failed to switch nginx upstream: %w", err)
+	}
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Str("upstream_host", alias).
+		Str("container_id", newID).
+		Msg("nginx upstream switched to new container")
+
+	// Traffic has moved, so finish the swap even if the caller's context ends
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), b.DrainPeriod+2*time.Minute)
+	defer cancel()
+
+	// Give in-flight requests on the old container time to complete
+	if oldID != "" && b.DrainPeriod > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(b.DrainPeriod):
+		}
+	}
+
+	if oldID != "" && oldID != newID {
+		if err := b.engine.Stop(cleanupCtx, oldID); err != nil {
+			log.Warn().Err(err).Str("container_id", oldID).Msg("failed to stop old container")
+		}
+		if err := b.engine.Remove(cleanupCtx, oldID); err != nil {
+			log.Warn().Err(err).Str("old_container_id", oldID).Msg("failed to remove old container - manual cleanup may be required")
+		}
+	}
+
+	if err := b.engine.Rename(cleanupCtx, newID, containerName); err != nil {
+		log.Warn().Err(err).Str("container_id", newID).Str("name", containerName).Msg("failed to rename new container")
+	}
+
+	if err := b.store.UpdateServiceContainerID(cleanupCtx, service.ID, newID); err != nil {
+		log.Error().Err(err).Int64("service_id", service.ID).Str("container_id", newID).Msg("failed to store new container ID")
+	}
+
+	return newID, nil
+}
+
+// DeployService rolls out a service with Deploy: its init containers run first
+// while the old container still serves, then its sidecars are attached to the
+// new container and its replicas moved onto spec. When an init container or
+// Deploy fails the old container keeps serving. Afterwards the new container
+// already serves, so sidecar and replica failures are only logged.
+func (b *BlueGreen) DeployService(ctx context.Context, service store.Service, containers *store.ServiceContainers, spec dockerx.ContainerSpec, labels map[string]string, sidecars *Sidecars, replicas *Replicas) (string, error) {
+	if err := sidecars.RunInit(ctx, service, containers.InitContainers); err != nil {
+		return "", err
+	}
+
+	newID, err := b.Deploy(ctx, service, spec, labels)
+	if err != nil {
+		return "", err
+	}
+
+	if err := sidecars.Ensure(context.WithoutCancel(ctx), service, containers.Sidecars, newID); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to ensure service sidecars")
+	}
+	if err := replicas.Replace(ctx, service, spec, labels); err != nil {
+		log.Warn().Err(err).Int64("service_id", service.ID).Msg("failed to replace service replicas")
+	}
+
+	return newID, nil
+}
+
+// connect attaches the candidate to the project network under the service aliases and its color alias
+func (b *BlueGreen) connect(ctx context.Context, service store.Service, containerID, alias string) error {
+	project, err := b.store.GetProject(ctx, service.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	// nginx reaches the candidate through its color alias, so this connection is required
+	return connectProjectNetwork(ctx, b.engine, project, service, containerID, alias)
+}
+
+// connectProjectNetwork attaches a container to its project network under the
+// service aliases plus an alias that identifies this particular container
+func connectProjectNetwork(ctx context.Context, engine Engine, project store.Project, service store.Service, containerID, alias string) error {
+	aliases := append(store.GenerateServiceAliases(project.Name, service.Name), alias)
+	return joinProjectNetwork(ctx, engine, project, containerID, aliases)
+}
+
+// joinProjectNetwork ensures the project network exists and attaches a container to it
+func joinProjectNetwork(ctx context.Context, engine Engine, project store.Project, containerID string, aliases []string) error {
+	networkName := store.GenerateProjectNetworkName(project.ID)
+	if project.NetworkName != nil && *project.NetworkName != "" {
+		networkName = *project.NetworkName
+	}
+
+	networkLabels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(project.ID, 10),
+		"glinr.managed":    "true",
+		"owner":            "glinrdock",
+	}
+	if err := engine.EnsureNetwork(ctx, networkName, networkLabels); err != nil {
+		return fmt.Errorf("failed to ensure project network: %w", err)
+	}
+
+	if err := engine.ConnectNetwork(ctx, networkName, containerID, aliases); err != nil {
+		return fmt.Errorf("failed to connect container to project network: %w", err)
+	}
+
+	return nil
+}
+
+// waitHealthy probes the candidate directly on the host ports Docker assigned to it
+func (b *BlueGreen) waitHealthy(ctx context.Context, service store.Service, spec dockerx.ContainerSpec, containerID string) error {
+	status, err := b.engine.Inspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	candidate := service
+	candidate.Image = spec.Image
+	candidate.ContainerID = &containerID
+	candidate.DesiredState = store.ServiceStateRunning
+	candidate.Ports = nil
+	for _, port := range spec.Ports {
+		for _, published := range status.Ports {
+			if published.Container == port.Container {
+				candidate.Ports = append(candidate.Ports, published)
+				break
+			}
+		}
+	}
+
+	// Routes still lead to the old container, so they are not used for probing
+	return WaitHealthy(ctx, b.engine, b.prober, &candidate, nil, containerID, b.HealthTimeout, b.HealthInterval)
+}
+
+// revert points the upstream back at the previous host after a failed switch
+func (b *BlueGreen) revert(ctx context.Context, serviceID int64, previousHost string) {
+	revertCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	if err := b.store.UpdateServiceUpstreamHost(revertCtx, serviceID, previousHost); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to restore previous upstream host")
+		return
+	}
+	if err := b.reload(revertCtx); err != nil {
+		log.Error().Err(err).Int64("service_id", serviceID).Msg("failed to reload nginx with previous upstream host")
+	}
+}
+
+// discard removes a candidate container that will not receive traffic
+func (b *BlueGreen) discard(ctx context.Context, containerID string) {
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	if err := b.engine.Remove(cleanupCtx, containerID); err != nil {
+		log.Warn().Err(err).Str("container_id", containerID).Msg("failed to remove discarded container")
+	}
+}
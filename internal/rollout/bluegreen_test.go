@@ -0,0 +1,280 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEngine records lifecycle calls and reports every container as running
+type fakeEngine struct {
+	mu         sync.Mutex
+	calls      []string
+	createSpec dockerx.ContainerSpec
+	aliases    []string
+}
+
+func (e *fakeEngine) record(call ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls = append(e.calls, strings.Join(call, " "))
+}
+
+func (e *fakeEngine) Inspect(ctx context.Context, containerID string) (dockerx.ContainerStatus, error) {
+	return dockerx.ContainerStatus{
+		ID:    containerID,
+		State: "running",
+		Ports: []store.PortMap{{Container: 3000, Host: 49153}},
+	}, nil
+}
+
+func (e *fakeEngine) Create(ctx context.Context, name string, spec dockerx.ContainerSpec, labels map[string]string) (string, error) {
+	e.record("create", name, labels["glinr.color"])
+	e.createSpec = spec
+	return "new-container", nil
+}
+
+func (e *fakeEngine) Start(ctx context.Context, id string) error {
+	e.record("start", id)
+	return nil
+}
+
+func (e *fakeEngine) Stop(ctx context.Context, id string) error {
+	e.record("stop", id)
+	return nil
+}
+
+func (e *fakeEngine) Remove(ctx context.Context, id string) error {
+	e.record("remove", id)
+	if strings.HasPrefix(id, "glinr_") {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func (e *fakeEngine) Rename(ctx context.Context, id string, newName string) error {
+	e.record("rename", id, newName)
+	return nil
+}
+
+func (e *fakeEngine) EnsureNetwork(ctx context.Context, networkName string, labels map[string]string) error {
+	return nil
+}
+
+func (e *fakeEngine) ConnectNetwork(ctx context.Context, networkName, containerID string, aliases []string) error {
+	e.aliases = aliases
+	return nil
+}
+
+// fakeStore keeps the upstream and container of a single service
+type fakeStore struct {
+	upstreamHosts []string
+	containerID   string
+}
+
+func (s *fakeStore) GetProject(ctx context.Context, id int64) (store.Project, error) {
+	return store.Project{ID: id, Name: "demo"}, nil
+}
+
+func (s *fakeStore) UpdateServiceContainerID(ctx context.Context, id int64, containerID string) error {
+	s.containerID = containerID
+	return nil
+}
+
+func (s *fakeStore) UpdateServiceUpstreamHost(ctx context.Context, id int64, host string) error {
+	s.upstreamHosts = append(s.upstreamHosts, host)
+	return nil
+}
+
+// probeFunc adapts a function to the Prober interface
+type probeFunc func(service *store.Service) health.ProbeResult
+
+func (f probeFunc) ProbeService(ctx context.Context, service *store.Service, routes []store.Route) health.ProbeResult {
+	return f(service)
+}
+
+func newTestService() store.Service {
+	oldID := "old-container"
+	upstream := UpstreamAlias(4, ColorBlue)
+	return store.Service{
+		ID:           4,
+		ProjectID:    2,
+		Name:         "web",
+		ContainerID:  &oldID,
+		UpstreamHost: &upstream,
+		Ports:        []store.PortMap{{Container: 3000, Host: 8080}},
+	}
+}
+
+func newTestBlueGreen(engine *fakeEngine, rolloutStore *fakeStore, prober Prober, reload ReloadFunc) *BlueGreen {
+	blueGreen := NewBlueGreen(engine, prober, rolloutStore, reload)
+	blueGreen.HealthTimeout = 0
+	blueGreen.DrainPeriod = 0
+	return blueGreen
+}
+
+func TestNextColor(t *testing.T) {
+	blue := UpstreamAlias(1, ColorBlue)
+	green := UpstreamAlias(1, ColorGreen)
+
+	assert.Equal(t, ColorBlue, NextColor(nil))
+	assert.Equal(t, ColorGreen, NextColor(&blue))
+	assert.Equal(t, ColorBlue, NextColor(&green))
+}
+
+func TestBlueGreen_SwitchesUpstreamBeforeRemovingOld(t *testing.T) {
+	engine := &fakeEngine{}
+	rolloutStore := &fakeStore{}
+	service := newTestService()
+
+	var probedPort int
+	prober := probeFunc(func(s *store.Service) health.ProbeResult {
+		probedPort = s.Ports[0].Host
+		return health.ProbeResult{Status: store.HealthStatusOK}
+	})
+
+	var reloadedWith []string
+	reload := func(ctx context.Context) error {
+		reloadedWith = append(reloadedWith, rolloutStore.upstreamHosts[len(rolloutStore.upstreamHosts)-1])
+		engine.record("reload")
+		return nil
+	}
+
+	newID, err := newTestBlueGreen(engine, rolloutStore, prober, reload).Deploy(context.Background(), service, dockerx.ContainerSpec{Image: "web:v2", Ports: service.Ports}, map[string]string{"glinr.service_id": "4"})
+	require.NoError(t, err)
+	assert.Equal(t, "new-container", newID)
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_green",
+		"create glinr_2_web_green green",
+		"start new-container",
+		"reload",
+		"stop old-container",
+		"remove old-container",
+		"rename new-container glinr_2_web",
+	}, engine.calls)
+
+	// The candidate is reached through the proxy, not through the configured host port
+	assert.Equal(t, []store.PortMap{{Container: 3000}}, engine.createSpec.Ports)
+	assert.Equal(t, 49153, probedPort)
+	assert.Contains(t, engine.aliases, "svc-4-green")
+
+	assert.Equal(t, []string{"svc-4-green"}, reloadedWith)
+	assert.Equal(t, "new-container", rolloutStore.containerID)
+}
+
+func TestBlueGreen_UnhealthyCandidateKeepsOldContainer(t *testing.T) {
+	engine := &fakeEngine{}
+	rolloutStore := &fakeStore{}
+
+	prober := probeFunc(func(s *store.Service) health.ProbeResult {
+		return health.ProbeResult{Status: store.HealthStatusFail, Error: errors.New("HTTP 502")}
+	})
+	reload := func(ctx context.Context) error {
+		t.Fatal("nginx must not be reloaded for an unhealthy candidate")
+		return nil
+	}
+
+	_, err := newTestBlueGreen(engine, rolloutStore, prober, reload).Deploy(context.Background(), newTestService(), dockerx.ContainerSpec{Image: "web:v2"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP 502")
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_green",
+		"create glinr_2_web_green green",
+		"start new-container",
+		"remove new-container",
+	}, engine.calls)
+	assert.Empty(t, rolloutStore.upstreamHosts)
+	assert.Empty(t, rolloutStore.containerID)
+}
+
+func TestBlueGreen_ReloadFailureRevertsUpstream(t *testing.T) {
+	engine := &fakeEngine{}
+	rolloutStore := &fakeStore{}
+
+	prober := probeFunc(func(s *store.Service) health.ProbeResult {
+		return health.ProbeResult{Status: store.HealthStatusOK}
+	})
+	reloads := 0
+	reload := func(ctx context.Context) error {
+		reloads++
+		if reloads == 1 {
+			return errors.New("nginx: [emerg] host not found in upstream")
+		}
+		return nil
+	}
+
+	_, err := newTestBlueGreen(engine, rolloutStore, prober, reload).Deploy(context.Background(), newTestService(), dockerx.ContainerSpec{Image: "web:v2"}, nil)
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"svc-4-green", "svc-4-blue"}, rolloutStore.upstreamHosts)
+	assert.Equal(t, 2, reloads)
+	assert.NotContains(t, engine.calls, "stop old-container")
+	assert.Contains(t, engine.calls, "remove new-container")
+}
+
+func TestBlueGreen_DeployServiceRunsInitBeforeAndSidecarsAfter(t *testing.T) {
+	engine := &sidecarEngine{}
+	rolloutStore := &fakeStore{}
+	prober := probeFunc(func(s *store.Service) health.ProbeResult {
+		return health.ProbeResult{Status: store.HealthStatusOK}
+	})
+	blueGreen := newTestBlueGreen(&engine.fakeEngine, rolloutStore, prober, func(ctx context.Context) error { return nil })
+
+	containers := &store.ServiceContainers{
+		InitContainers: []store.ServiceContainer{{Name: "migrate", Image: "web:v2"}},
+		Sidecars:       []store.ServiceContainer{{Name: "shipper", Image: "vector:0.40"}},
+	}
+	service := newTestService()
+	newID, err := blueGreen.DeployService(context.Background(), service, containers, dockerx.ContainerSpec{Image: "web:v2"}, nil,
+		NewSidecars(engine, rolloutStore), NewReplicas(engine, rolloutStore))
+	require.NoError(t, err)
+	assert.Equal(t, "new-container", newID)
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_init_migrate",
+		"create glinr_2_web_init_migrate ",
+		"start new-container",
+		"wait new-container",
+		"remove new-container",
+		"remove glinr_2_web_green",
+		"create glinr_2_web_green green",
+		"start new-container",
+		"stop old-container",
+		"remove old-container",
+		"rename new-container glinr_2_web",
+		"remove glinr_2_web_sc_shipper",
+		"create glinr_2_web_sc_shipper ",
+		"start new-container",
+	}, engine.calls)
+	assert.Equal(t, "container:new-container", engine.createSpec.NetworkMode)
+}
+
+func TestBlueGreen_DeployServiceFailedInitKeepsOldContainer(t *testing.T) {
+	engine := &sidecarEngine{exitCode: 1}
+	rolloutStore := &fakeStore{}
+	prober := probeFunc(func(s *store.Service) health.ProbeResult {
+		t.Fatal("no candidate may start after a failed init container")
+		return health.ProbeResult{}
+	})
+	blueGreen := newTestBlueGreen(&engine.fakeEngine, rolloutStore, prober, nil)
+
+	containers := &store.ServiceContainers{InitContainers: []store.ServiceContainer{{Name: "migrate", Image: "web:v2"}}}
+	_, err := blueGreen.DeployService(context.Background(), newTestService(), containers, dockerx.ContainerSpec{Image: "web:v2"}, nil,
+		NewSidecars(engine, rolloutStore), NewReplicas(engine, rolloutStore))
+	require.Error(t, err)
+
+	for _, call := range engine.calls {
+		assert.NotContains(t, call, "glinr_2_web_green")
+	}
+	assert.Empty(t, rolloutStore.upstreamHosts)
+}
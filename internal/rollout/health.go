@@ -0,0 +1,65 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/health"
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// Prober checks whether a service is serving requests
+type Prober interface {
+	ProbeService(ctx context.Context, service *store.Service, routes []store.Route) health.ProbeResult
+}
+
+// Inspector reports the state of a container
+type Inspector interface {
+	Inspect(ctx context.Context, containerID string) (dockerx.ContainerStatus, error)
+}
+
+// WaitHealthy polls a started container until it passes its health check or the timeout expires.
+// A running container whose health check has nothing to probe is treated as healthy.
+func WaitHealthy(ctx context.Context, engine Inspector, prober Prober, service *store.Service, routes []store.Route, containerID string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+
+	for {
+		status, err := engine.Inspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+
+		switch status.State {
+		case "running":
+			result := prober.ProbeService(ctx, service, routes)
+			switch result.Status {
+			case store.HealthStatusOK:
+				return nil
+			case store.HealthStatusUnknown, store.HealthStatusUnreachable:
+				// Nothing to probe: a running container is the best signal available
+				return nil
+			}
+			lastErr = result.Error
+		case "created":
+			lastErr = fmt.Errorf("container has not started yet")
+		default:
+			return fmt.Errorf("container is %s", status.State)
+		}
+
+		if time.Now().After(deadline) {
+			if lastErr == nil {
+				lastErr = fmt.Errorf("service did not report healthy")
+			}
+			return fmt.Errorf("timed out after %s: %w", timeout, lastErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package rollout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+)
+
+// CycleError reports services that depend on each other through their links
+type CycleError struct {
+	Path []string // service names, the first repeated at the end
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Path, " -> "))
+}
+
+// OrderByLinks sorts services so that every service comes after the services it
+// links to, which are its dependencies. links maps a service ID to the IDs of its
+// link targets; targets outside services are ignored. Services without an order
+// between them are sorted by name so the result is stable.
+func OrderByLinks(services []store.Service, links map[int64][]int64) ([]store.Service, error) {
+	byID := make(map[int64]store.Service, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+
+	sorted := append([]store.Service{}, services...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	ordered := make([]store.Service, 0, len(services))
+	state := make(map[int64]int) // 0 unvisited, 1 visiting, 2 done
+	var visit func(service store.Service, path []string) error
+	visit = func(service store.Service, path []string) error {
+		switch state[service.ID] {
+		case 1:
+			return &CycleError{Path: append(path, service.Name)}
+		case 2:
+			return nil
+		}
+		state[service.ID] = 1
+
+		targets := make([]store.Service, 0, len(links[service.ID]))
+		for _, targetID := range links[service.ID] {
+			if target, ok := byID[targetID]; ok && targetID != service.ID {
+				targets = append(targets, target)
+			}
+		}
+		sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+		for _, target := range targets {
+			if err := visit(target, append(path, service.Name)); err != nil {
+				return err
+			}
+		}
+
+		state[service.ID] = 2
+		ordered = append(ordered, service)
+		return nil
+	}
+
+	for _, service := range sorted {
+		if err := visit(service, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,57 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func names(services []store.Service) []string {
+	result := make([]string, 0, len(services))
+	for _, service := range services {
+		result = append(result, service.Name)
+	}
+	return result
+}
+
+func TestOrderByLinks_DependenciesFirst(t *testing.T) {
+	services := []store.Service{
+		{ID: 1, Name: "web"},
+		{ID: 2, Name: "api"},
+		{ID: 3, Name: "db"},
+		{ID: 4, Name: "cache"},
+		{ID: 5, Name: "docs"},
+	}
+	links := map[int64][]int64{
+		1: {2},     // web -> api
+		2: {3, 4},  // api -> db, cache
+		4: {3, 99}, // cache -> db and a service of another project
+	}
+
+	ordered, err := OrderByLinks(services, links)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache", "api", "docs", "web"}, names(ordered))
+}
+
+func TestOrderByLinks_DetectsCycles(t *testing.T) {
+	services := []store.Service{
+		{ID: 1, Name: "a"},
+		{ID: 2, Name: "b"},
+		{ID: 3, Name: "c"},
+	}
+	links := map[int64][]int64{1: {2}, 2: {3}, 3: {1}}
+
+	_, err := OrderByLinks(services, links)
+	var cycle *CycleError
+	require.ErrorAs(t, err, &cycle)
+	assert.Equal(t, []string{"a", "b", "c", "a"}, cycle.Path)
+	assert.EqualError(t, err, "dependency cycle: a -> b -> c -> a")
+}
+
+func TestOrderByLinks_IgnoresSelfLinks(t *testing.T) {
+	ordered, err := OrderByLinks([]store.Service{{ID: 1, Name: "a"}}, map[int64][]int64{1: {1}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names(ordered))
+}
@@ -0,0 +1,210 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ReplicaLabel holds the replica index of an additional service container
+const ReplicaLabel = "glinr.replica"
+
+// ReplicaEngine is the subset of dockerx.Engine needed to manage replicas
+type ReplicaEngine interface {
+	Engine
+	List(ctx context.Context, labels map[string]string) ([]dockerx.ContainerStatus, error)
+}
+
+// ProjectStore looks up the project whose network replicas join
+type ProjectStore interface {
+	GetProject(ctx context.Context, id int64) (store.Project, error)
+}
+
+// Replicas manages the containers of a service scaled beyond one replica.
+//
+// Replica 1 is the service's primary container, the one recorded as its
+// container ID and handled by deploys and lifecycle actions. Replicas 2..N
+// carry the glinr.replica label, publish no fixed host ports and are reached
+// by nginx through their replica alias on the project network.
+type Replicas struct {
+	engine ReplicaEngine
+	store  ProjectStore
+}
+
+// NewReplicas creates a replica manager
+func NewReplicas(engine ReplicaEngine, projectStore ProjectStore) *Replicas {
+	return &Replicas{
+		engine: engine,
+		store:  projectStore,
+	}
+}
+
+// ReplicaContainerName returns the container name of an additional replica
+func ReplicaContainerName(service store.Service, index int) string {
+	return fmt.Sprintf("glinr_%d_%s_r%d", service.ProjectID, service.Name, index)
+}
+
+// List returns the additional replica containers of a service ordered by index
+func (r *Replicas) List(ctx context.Context, serviceID int64) ([]dockerx.ContainerStatus, error) {
+	containers, err := r.engine.List(ctx, map[string]string{
+		"glinr.service_id": strconv.FormatInt(serviceID, 10),
+		"glinr.managed":    "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []dockerx.ContainerStatus
+	for _, container := range containers {
+		if replicaIndex(container) > 1 {
+			replicas = append(replicas, container)
+		}
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicaIndex(replicas[i]) < replicaIndex(replicas[j]) })
+
+	return replicas, nil
+}
+
+// Scale starts missing replicas up to service.Replicas and removes those beyond it
+func (r *Replicas) Scale(ctx context.Context, service store.Service, spec dockerx.ContainerSpec, labels map[string]string) error {
+	existing, err := r.List(ctx, service.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list replicas: %w", err)
+	}
+
+	running := make(map[int]bool, len(existing))
+	for _, container := range existing {
+		index := replicaIndex(container)
+		if index > service.Replicas {
+			r.remove(ctx, container)
+			continue
+		}
+		running[index] = true
+	}
+
+	for index := 2; index <= service.Replicas; index++ {
+		if running[index] {
+			continue
+		}
+		if err := r.create(ctx, service, spec, labels, index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replace recreates every additional replica from spec, one at a time, after
+// the primary container was rolled out with a new configuration
+func (r *Replicas) Replace(ctx context.Context, service store.Service, spec dockerx.ContainerSpec, labels map[string]string) error {
+	existing, err := r.List(ctx, service.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list replicas: %w", err)
+	}
+
+	for _, container := range existing {
+		r.remove(ctx, container)
+		index := replicaIndex(container)
+		if index > service.Replicas {
+			continue
+		}
+		if err := r.create(ctx, service, spec, labels, index); err != nil {
+			return err
+		}
+	}
+
+	// Fill any gaps left by replicas that were removed outside glinrdock
+	return r.Scale(ctx, service, spec, labels)
+}
+
+// RemoveAll removes every additional replica of a service
+func (r *Replicas) RemoveAll(ctx context.Context, serviceID int64) error {
+	existing, err := r.List(ctx, serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to list replicas: %w", err)
+	}
+
+	for _, container := range existing {
+		r.remove(ctx, container)
+	}
+	return nil
+}
+
+// create starts replica index of a service and joins it to the project network
+func (r *Replicas) create(ctx context.Context, service store.Service, spec dockerx.ContainerSpec, labels map[string]string, index int) error {
+	name := ReplicaContainerName(service, index)
+
+	// The primary container holds the configured host ports
+	replicaSpec := spec
+	replicaSpec.Ports = make([]store.PortMap, len(spec.Ports))
+	for i, port := range spec.Ports {
+		replicaSpec.Ports[i] = store.PortMap{Container: port.Container}
+	}
+
+	replicaLabels := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		replicaLabels[key] = value
+	}
+	replicaLabels[ReplicaLabel] = strconv.Itoa(index)
+
+	// A replica left behind by an interrupted scale would block the name
+	if err := r.engine.Remove(ctx, name); err == nil {
+		log.Info().Str("container_name", name).Msg("removed stale replica container")
+	}
+
+	containerID, err := r.engine.Create(ctx, name, replicaSpec, replicaLabels)
+	if err != nil {
+		return fmt.Errorf("failed to create replica %d: %w", index, err)
+	}
+
+	project, err := r.store.GetProject(ctx, service.ProjectID)
+	if err != nil {
+		r.engine.Remove(ctx, containerID)
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	// nginx reaches the replica through its replica alias, so this connection is required
+	if err := connectProjectNetwork(ctx, r.engine, project, service, containerID, store.ReplicaAlias(service.ID, index)); err != nil {
+		r.engine.Remove(ctx, containerID)
+		return fmt.Errorf("replica %d: %w", index, err)
+	}
+
+	if err := r.engine.Start(ctx, containerID); err != nil {
+		r.engine.Remove(ctx, containerID)
+		return fmt.Errorf("failed to start replica %d: %w", index, err)
+	}
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Int("replica", index).
+		Str("container_id", containerID).
+		Msg("replica started")
+
+	return nil
+}
+
+// remove stops and removes a replica container
+func (r *Replicas) remove(ctx context.Context, container dockerx.ContainerStatus) {
+	if err := r.engine.Stop(ctx, container.ID); err != nil {
+		log.Warn().Err(err).Str("container_id", container.ID).Msg("failed to stop replica")
+	}
+	if err := r.engine.Remove(ctx, container.ID); err != nil {
+		log.Warn().Err(err).Str("container_id", container.ID).Msg("failed to remove replica - manual cleanup may be required")
+		return
+	}
+	log.Info().Str("container_id", container.ID).Str("replica", container.Labels[ReplicaLabel]).Msg("replica removed")
+}
+
+// replicaIndex returns the replica index of a container, 0 for containers without one
+func replicaIndex(container dockerx.ContainerStatus) int {
+	index, err := strconv.Atoi(container.Labels[ReplicaLabel])
+	if err != nil {
+		return 0
+	}
+	return index
+}
@@ -0,0 +1,85 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replicaEngine adds container listing to fakeEngine
+type replicaEngine struct {
+	fakeEngine
+	containers []dockerx.ContainerStatus
+}
+
+func (e *replicaEngine) List(ctx context.Context, labels map[string]string) ([]dockerx.ContainerStatus, error) {
+	return e.containers, nil
+}
+
+func replicaContainer(id string, index string) dockerx.ContainerStatus {
+	labels := map[string]string{"glinr.service_id": "4", "glinr.managed": "true"}
+	if index != "" {
+		labels[ReplicaLabel] = index
+	}
+	return dockerx.ContainerStatus{ID: id, State: "running", Labels: labels}
+}
+
+func TestReplicas_ScaleUpCreatesMissingReplicas(t *testing.T) {
+	engine := &replicaEngine{containers: []dockerx.ContainerStatus{
+		replicaContainer("primary", ""),
+		replicaContainer("replica-2", "2"),
+	}}
+	service := newTestService()
+	service.Replicas = 3
+
+	err := NewReplicas(engine, &fakeStore{}).Scale(context.Background(), service, dockerx.ContainerSpec{Image: "web:v1", Ports: service.Ports}, map[string]string{"glinr.service_id": "4"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_r3",
+		"create glinr_2_web_r3 ",
+		"start new-container",
+	}, engine.calls)
+
+	// Replicas leave the configured host port to the primary container
+	assert.Equal(t, []store.PortMap{{Container: 3000}}, engine.createSpec.Ports)
+	assert.Contains(t, engine.aliases, "svc-4-r3")
+	assert.Contains(t, engine.aliases, "web")
+}
+
+func TestReplicas_ScaleDownRemovesExtraReplicas(t *testing.T) {
+	engine := &replicaEngine{containers: []dockerx.ContainerStatus{
+		replicaContainer("primary", ""),
+		replicaContainer("replica-3", "3"),
+		replicaContainer("replica-2", "2"),
+	}}
+	service := newTestService()
+	service.Replicas = 2
+
+	err := NewReplicas(engine, &fakeStore{}).Scale(context.Background(), service, dockerx.ContainerSpec{Image: "web:v1"}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"stop replica-3",
+		"remove replica-3",
+	}, engine.calls)
+}
+
+func TestReplicas_ListSkipsPrimaryAndOrdersByIndex(t *testing.T) {
+	engine := &replicaEngine{containers: []dockerx.ContainerStatus{
+		replicaContainer("replica-10", "10"),
+		replicaContainer("primary", ""),
+		replicaContainer("replica-2", "2"),
+	}}
+
+	replicas, err := NewReplicas(engine, &fakeStore{}).List(context.Background(), 4)
+	require.NoError(t, err)
+
+	require.Len(t, replicas, 2)
+	assert.Equal(t, "replica-2", replicas[0].ID)
+	assert.Equal(t, "replica-10", replicas[1].ID)
+}
@@ -0,0 +1,309 @@
+package rollout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Labels of init containers and sidecars. Neither carries glinr.service_id, so
+// they are never mistaken for the service's own container.
+const (
+	InitOfLabel      = "glinr.init_of"
+	SidecarOfLabel   = "glinr.sidecar_of"
+	SidecarLabel     = "glinr.sidecar"      // the sidecar's name
+	SidecarMainLabel = "glinr.sidecar_main" // the container whose network and volumes it shares
+	SidecarHashLabel = "glinr.sidecar_hash" // the configuration it was created with
+)
+
+// SidecarEngine is the subset of dockerx.Engine needed to run init containers and sidecars
+type SidecarEngine interface {
+	Engine
+	Pull(ctx context.Context, image string, registryID string) error
+	List(ctx context.Context, labels map[string]string) ([]dockerx.ContainerStatus, error)
+	Wait(ctx context.Context, id string) (int64, error)
+}
+
+// Sidecars runs the init containers and sidecars a service declares.
+//
+// Init containers run one at a time, to completion, before the service's
+// container starts; any failure stops the start. They get the service's
+// volumes and join the project network without aliases. Sidecars share the
+// network namespace and volumes of the service's container, so they are
+// recreated whenever that container is, and are stopped before it.
+type Sidecars struct {
+	engine SidecarEngine
+	store  ProjectStore
+
+	// InitTimeout bounds how long a single init container may run
+	InitTimeout time.Duration
+}
+
+// NewSidecars creates an init container and sidecar manager
+func NewSidecars(engine SidecarEngine, projectStore ProjectStore) *Sidecars {
+	return &Sidecars{
+		engine:      engine,
+		store:       projectStore,
+		InitTimeout: store.InitContainerTimeout * time.Second,
+	}
+}
+
+// InitContainerName returns the container name of an init container
+func InitContainerName(service store.Service, name string) string {
+	return fmt.Sprintf("glinr_%d_%s_init_%s", service.ProjectID, service.Name, name)
+}
+
+// SidecarContainerName returns the container name of a sidecar
+func SidecarContainerName(service store.Service, name string) string {
+	return fmt.Sprintf("glinr_%d_%s_sc_%s", service.ProjectID, service.Name, name)
+}
+
+// RunInit runs the init containers of a service in order and returns the first failure
+func (s *Sidecars) RunInit(ctx context.Context, service store.Service, inits []store.ServiceContainer) error {
+	if len(inits) == 0 {
+		return nil
+	}
+
+	project, err := s.store.GetProject(ctx, service.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get project: %w", err)
+	}
+
+	for _, init := range inits {
+		if err := s.runInit(ctx, project, service, init); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runInit runs a single init container to completion and removes it
+func (s *Sidecars) runInit(ctx context.Context, project store.Project, service store.Service, init store.ServiceContainer) error {
+	name := InitContainerName(service, init.Name)
+	spec := dockerx.ContainerSpec{
+		Image:   init.Image,
+		Command: init.Command,
+		Env:     mergeEnv(service.Env, init.Env),
+		Volumes: service.Volumes,
+	}
+	labels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(service.ProjectID, 10),
+		InitOfLabel:        strconv.FormatInt(service.ID, 10),
+		"glinr.managed":    "true",
+	}
+
+	// An init container left behind by an interrupted start would block the name
+	if err := s.engine.Remove(ctx, name); err == nil {
+		log.Info().Str("container_name", name).Msg("removed stale init container")
+	}
+	if err := s.engine.Pull(ctx, init.Image, ""); err != nil {
+		log.Warn().Err(err).Str("image", init.Image).Msg("failed to pull init container image, continuing with local")
+	}
+
+	containerID, err := s.engine.Create(ctx, name, spec, labels)
+	if err != nil {
+		return fmt.Errorf("failed to create init container %s: %w", init.Name, err)
+	}
+	defer func() {
+		if err := s.engine.Remove(context.WithoutCancel(ctx), containerID); err != nil {
+			log.Warn().Err(err).Str("container_id", containerID).Msg("failed to remove init container - manual cleanup may be required")
+		}
+	}()
+
+	if err := joinProjectNetwork(ctx, s.engine, project, containerID, nil); err != nil {
+		return fmt.Errorf("init container %s: %w", init.Name, err)
+	}
+	if err := s.engine.Start(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start init container %s: %w", init.Name, err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, s.InitTimeout)
+	defer cancel()
+	exitCode, err := s.engine.Wait(waitCtx, containerID)
+	if err != nil {
+		if stopErr := s.engine.Stop(context.WithoutCancel(ctx), containerID); stopErr != nil {
+			log.Warn().Err(stopErr).Str("container_id", containerID).Msg("failed to stop init container")
+		}
+		if waitCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("init container %s timed out after %s", init.Name, s.InitTimeout)
+		}
+		return fmt.Errorf("init container %s: %w", init.Name, err)
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("init container %s exited with code %d", init.Name, exitCode)
+	}
+
+	log.Info().Int64("service_id", service.ID).Str("init_container", init.Name).Msg("init container completed")
+	return nil
+}
+
+// List returns the sidecar containers of a service
+func (s *Sidecars) List(ctx context.Context, serviceID int64) ([]dockerx.ContainerStatus, error) {
+	return s.engine.List(ctx, map[string]string{
+		SidecarOfLabel:  strconv.FormatInt(serviceID, 10),
+		"glinr.managed": "true",
+	})
+}
+
+// Ensure brings the sidecars of a service in line with its declarations: sidecars
+// no longer declared, changed, or attached to a previous container of the service
+// are removed, missing ones are created next to mainID, and stopped ones started.
+func (s *Sidecars) Ensure(ctx context.Context, service store.Service, sidecars []store.ServiceContainer, mainID string) error {
+	existing, err := s.List(ctx, service.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list sidecars: %w", err)
+	}
+
+	specs := make(map[string]dockerx.ContainerSpec, len(sidecars))
+	hashes := make(map[string]string, len(sidecars))
+	for _, sidecar := range sidecars {
+		spec := sidecarSpec(service, sidecar, mainID)
+		specs[sidecar.Name] = spec
+		hashes[sidecar.Name] = specHash(spec)
+	}
+
+	current := make(map[string]dockerx.ContainerStatus, len(existing))
+	for _, container := range existing {
+		name := container.Labels[SidecarLabel]
+		hash, declared := hashes[name]
+		if !declared || container.Labels[SidecarMainLabel] != mainID || container.Labels[SidecarHashLabel] != hash {
+			s.remove(ctx, container)
+			continue
+		}
+		current[name] = container
+	}
+
+	for _, sidecar := range sidecars {
+		if container, ok := current[sidecar.Name]; ok {
+			if container.State != "running" {
+				if err := s.engine.Start(ctx, container.ID); err != nil {
+					return fmt.Errorf("failed to start sidecar %s: %w", sidecar.Name, err)
+				}
+			}
+			continue
+		}
+		if err := s.create(ctx, service, sidecar.Name, specs[sidecar.Name], hashes[sidecar.Name], mainID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop stops the sidecars of a service, ahead of the container they are attached to
+func (s *Sidecars) Stop(ctx context.Context, serviceID int64) error {
+	existing, err := s.List(ctx, serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to list sidecars: %w", err)
+	}
+
+	for _, container := range existing {
+		if err := s.engine.Stop(ctx, container.ID); err != nil {
+			log.Warn().Err(err).Str("container_id", container.ID).Msg("failed to stop sidecar")
+		}
+	}
+	return nil
+}
+
+// RemoveAll removes every sidecar of a service
+func (s *Sidecars) RemoveAll(ctx context.Context, serviceID int64) error {
+	existing, err := s.List(ctx, serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to list sidecars: %w", err)
+	}
+
+	for _, container := range existing {
+		s.remove(ctx, container)
+	}
+	return nil
+}
+
+// create starts a sidecar in the network namespace of mainID
+func (s *Sidecars) create(ctx context.Context, service store.Service, name string, spec dockerx.ContainerSpec, hash, mainID string) error {
+	containerName := SidecarContainerName(service, name)
+	labels := map[string]string{
+		"glinr.project_id": strconv.FormatInt(service.ProjectID, 10),
+		SidecarOfLabel:     strconv.FormatInt(service.ID, 10),
+		SidecarLabel:       name,
+		SidecarMainLabel:   mainID,
+		SidecarHashLabel:   hash,
+		"glinr.managed":    "true",
+	}
+
+	// A sidecar left behind by an interrupted start would block the name
+	if err := s.engine.Remove(ctx, containerName); err == nil {
+		log.Info().Str("container_name", containerName).Msg("removed stale sidecar container")
+	}
+	if err := s.engine.Pull(ctx, spec.Image, ""); err != nil {
+		log.Warn().Err(err).Str("image", spec.Image).Msg("failed to pull sidecar image, continuing with local")
+	}
+
+	containerID, err := s.engine.Create(ctx, containerName, spec, labels)
+	if err != nil {
+		return fmt.Errorf("failed to create sidecar %s: %w", name, err)
+	}
+	if err := s.engine.Start(ctx, containerID); err != nil {
+		s.engine.Remove(ctx, containerID)
+		return fmt.Errorf("failed to start sidecar %s: %w", name, err)
+	}
+
+	log.Info().
+		Int64("service_id", service.ID).
+		Str("sidecar", name).
+		Str("container_id", containerID).
+		Msg("sidecar started")
+
+	return nil
+}
+
+// remove stops and removes a sidecar container
+func (s *Sidecars) remove(ctx context.Context, container dockerx.ContainerStatus) {
+	if err := s.engine.Stop(ctx, container.ID); err != nil {
+		log.Warn().Err(err).Str("container_id", container.ID).Msg("failed to stop sidecar")
+	}
+	if err := s.engine.Remove(ctx, container.ID); err != nil {
+		log.Warn().Err(err).Str("container_id", container.ID).Msg("failed to remove sidecar - manual cleanup may be required")
+		return
+	}
+	log.Info().Str("container_id", container.ID).Str("sidecar", container.Labels[SidecarLabel]).Msg("sidecar removed")
+}
+
+// sidecarSpec returns the container spec of a sidecar attached to mainID. It
+// publishes no ports; the service's container owns the shared network namespace.
+func sidecarSpec(service store.Service, sidecar store.ServiceContainer, mainID string) dockerx.ContainerSpec {
+	return dockerx.ContainerSpec{
+		Image:         sidecar.Image,
+		Command:       sidecar.Command,
+		Env:           mergeEnv(service.Env, sidecar.Env),
+		RestartPolicy: service.RestartPolicy,
+		NetworkMode:   "container:" + mainID,
+		VolumesFrom:   []string{mainID},
+	}
+}
+
+// specHash identifies a container configuration so changed sidecars are recreated
+func specHash(spec dockerx.ContainerSpec) string {
+	data, _ := json.Marshal(spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// mergeEnv lays overrides over the service env
+func mergeEnv(base, overrides map[string]string) map[string]string {
+	env := make(map[string]string, len(base)+len(overrides))
+	for key, value := range base {
+		env[key] = value
+	}
+	for key, value := range overrides {
+		env[key] = value
+	}
+	return env
+}
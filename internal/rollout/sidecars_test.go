@@ -0,0 +1,154 @@
+package rollout
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GLINCKER/glinrdock/internal/dockerx"
+	"github.com/GLINCKER/glinrdock/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sidecarEngine adds pulls, label filtering and waits to fakeEngine
+type sidecarEngine struct {
+	fakeEngine
+	containers []dockerx.ContainerStatus
+	exitCode   int64
+}
+
+func (e *sidecarEngine) Pull(ctx context.Context, image string, registryID string) error {
+	return nil
+}
+
+func (e *sidecarEngine) List(ctx context.Context, labels map[string]string) ([]dockerx.ContainerStatus, error) {
+	var matching []dockerx.ContainerStatus
+	for _, container := range e.containers {
+		matches := true
+		for key, value := range labels {
+			if container.Labels[key] != value {
+				matches = false
+			}
+		}
+		if matches {
+			matching = append(matching, container)
+		}
+	}
+	return matching, nil
+}
+
+func (e *sidecarEngine) Wait(ctx context.Context, id string) (int64, error) {
+	e.record("wait", id)
+	return e.exitCode, nil
+}
+
+func sidecarContainer(id, name, mainID, hash, state string) dockerx.ContainerStatus {
+	return dockerx.ContainerStatus{ID: id, State: state, Labels: map[string]string{
+		SidecarOfLabel:   "4",
+		SidecarLabel:     name,
+		SidecarMainLabel: mainID,
+		SidecarHashLabel: hash,
+		"glinr.managed":  "true",
+	}}
+}
+
+func TestSidecars_RunInitRunsInOrder(t *testing.T) {
+	engine := &sidecarEngine{}
+	service := newTestService()
+	service.Env = map[string]string{"DATABASE_URL": "postgres://db", "MODE": "web"}
+	service.Volumes = []store.VolumeMap{{Host: "data", Container: "/data"}}
+
+	inits := []store.ServiceContainer{
+		{Name: "migrate", Image: "web:v1", Command: []string{"rake", "db:migrate"}, Env: map[string]string{"MODE": "migrate"}},
+		{Name: "seed", Image: "web:v1"},
+	}
+	require.NoError(t, NewSidecars(engine, &fakeStore{}).RunInit(context.Background(), service, inits))
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_init_migrate",
+		"create glinr_2_web_init_migrate ",
+		"start new-container",
+		"wait new-container",
+		"remove new-container",
+		"remove glinr_2_web_init_seed",
+		"create glinr_2_web_init_seed ",
+		"start new-container",
+		"wait new-container",
+		"remove new-container",
+	}, engine.calls)
+
+	// Init containers reach the project's services but get no aliases of their own
+	assert.Empty(t, engine.aliases)
+	assert.Equal(t, service.Volumes, engine.createSpec.Volumes)
+}
+
+func TestSidecars_RunInitStopsOnFailure(t *testing.T) {
+	engine := &sidecarEngine{exitCode: 2}
+	service := newTestService()
+	service.Env = map[string]string{"MODE": "web"}
+
+	inits := []store.ServiceContainer{
+		{Name: "migrate", Image: "web:v1", Env: map[string]string{"MODE": "migrate"}},
+		{Name: "seed", Image: "web:v1"},
+	}
+	err := NewSidecars(engine, &fakeStore{}).RunInit(context.Background(), service, inits)
+	require.Error(t, err)
+	assert.Equal(t, "init container migrate exited with code 2", err.Error())
+
+	assert.Equal(t, "migrate", engine.createSpec.Env["MODE"])
+	for _, call := range engine.calls {
+		assert.False(t, strings.Contains(call, "seed"), "no init container runs after a failure")
+	}
+	assert.Equal(t, "remove new-container", engine.calls[len(engine.calls)-1], "the failed init container is removed")
+}
+
+func TestSidecars_EnsureCreatesSidecarsInMainNamespace(t *testing.T) {
+	engine := &sidecarEngine{}
+	service := newTestService()
+	service.Env = map[string]string{"LOG_LEVEL": "info"}
+
+	sidecars := []store.ServiceContainer{{Name: "shipper", Image: "vector:0.40", Env: map[string]string{"SINK": "loki"}}}
+	require.NoError(t, NewSidecars(engine, &fakeStore{}).Ensure(context.Background(), service, sidecars, "main-1"))
+
+	assert.Equal(t, []string{
+		"remove glinr_2_web_sc_shipper",
+		"create glinr_2_web_sc_shipper ",
+		"start new-container",
+	}, engine.calls)
+	assert.Equal(t, "container:main-1", engine.createSpec.NetworkMode)
+	assert.Equal(t, []string{"main-1"}, engine.createSpec.VolumesFrom)
+	assert.Empty(t, engine.createSpec.Ports)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "info", "SINK": "loki"}, engine.createSpec.Env)
+}
+
+func TestSidecars_EnsureReconcilesExisting(t *testing.T) {
+	service := newTestService()
+	shipper := store.ServiceContainer{Name: "shipper", Image: "vector:0.40"}
+	metrics := store.ServiceContainer{Name: "metrics", Image: "exporter:1"}
+	shipperHash := specHash(sidecarSpec(service, shipper, "main-1"))
+	metricsHash := specHash(sidecarSpec(service, metrics, "main-1"))
+
+	engine := &sidecarEngine{containers: []dockerx.ContainerStatus{
+		sidecarContainer("sc-shipper", "shipper", "main-1", shipperHash, "exited"),
+		sidecarContainer("sc-metrics", "metrics", "main-0", metricsHash, "running"),
+		sidecarContainer("sc-old", "old", "main-1", "", "running"),
+	}}
+
+	err := NewSidecars(engine, &fakeStore{}).Ensure(context.Background(), service, []store.ServiceContainer{shipper, metrics}, "main-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		// Attached to a previous container of the service
+		"stop sc-metrics",
+		"remove sc-metrics",
+		// No longer declared
+		"stop sc-old",
+		"remove sc-old",
+		// Unchanged, only started
+		"start sc-shipper",
+		"remove glinr_2_web_sc_metrics",
+		"create glinr_2_web_sc_metrics ",
+		"start new-container",
+	}, engine.calls)
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// buildColumns are the build fields the builds table keeps
+const buildColumns = "id, project_id, service_id, git_url, git_ref, context_path, dockerfile, image_tag, status, log_path, started_at, finished_at, created_at"
+
+// CreateBuild inserts a new build record and sets its ID
+func (s *Store) CreateBuild(ctx context.Context, build *Build) error {
+	if build.CreatedAt.IsZero() {
+		build.CreatedAt = time.Now()
+	}
+	if build.ContextPath == "" {
+		build.ContextPath = "."
+	}
+	if build.Dockerfile == "" {
+		build.Dockerfile = "Dockerfile"
+	}
+	if build.Status == "" {
+		build.Status = "queued"
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO builds (project_id, service_id, git_url, git_ref, context_path, dockerfile, image_tag, status, log_path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		build.ProjectID, build.ServiceID, build.GitURL, build.GitRef, build.ContextPath, build.Dockerfile,
+		build.ImageTag, build.Status, build.LogPath, build.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create build: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get build ID: %w", err)
+	}
+	build.ID = id
+
+	return nil
+}
+
+// GetBuild retrieves a build by ID
+func (s *Store) GetBuild(ctx context.Context, buildID int64) (*Build, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+buildColumns+" FROM builds WHERE id = ?", buildID)
+
+	build, err := scanBuild(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build: %w", err)
+	}
+
+	return build, nil
+}
+
+// ListBuilds returns the builds of a service, newest first
+func (s *Store) ListBuilds(ctx context.Context, serviceID int64) ([]*Build, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+buildColumns+" FROM builds WHERE service_id = ? ORDER BY created_at DESC, id DESC", serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+	defer rows.Close()
+
+	var builds []*Build
+	for rows.Next() {
+		build, err := scanBuild(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan build: %w", err)
+		}
+		builds = append(builds, build)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate builds: %w", err)
+	}
+
+	return builds, nil
+}
+
+// UpdateBuildStatus sets the status of a build. startedAt and finishedAt are
+// unix timestamps; nil keeps the existing log path and times.
+func (s *Store) UpdateBuildStatus(ctx context.Context, buildID int64, status string, logPath *string, startedAt, finishedAt *int64) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE builds SET status = ?, log_path = COALESCE(?, log_path), started_at = COALESCE(?, started_at), finished_at = COALESCE(?, finished_at) WHERE id = ?",
+		status, logPath, unixTime(startedAt), unixTime(finishedAt), buildID)
+	if err != nil {
+		return fmt.Errorf("failed to update build status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// unixTime converts an optional unix timestamp for a DATETIME column
+func unixTime(seconds *int64) *time.Time {
+	if seconds == nil {
+		return nil
+	}
+	t := time.Unix(*seconds, 0).UTC()
+	return &t
+}
+
+// scanBuild reads a build from a row
+func scanBuild(row rowScanner) (*Build, error) {
+	var build Build
+	var logPath sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	if err := row.Scan(&build.ID, &build.ProjectID, &build.ServiceID, &build.GitURL, &build.GitRef,
+		&build.ContextPath, &build.Dockerfile, &build.ImageTag, &build.Status, &logPath,
+		&startedAt, &finishedAt, &build.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if logPath.Valid {
+		build.LogPath = &logPath.String
+	}
+	if startedAt.Valid {
+		build.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		build.FinishedAt = &finishedAt.Time
+	}
+
+	return &build, nil
+}
@@ -0,0 +1,517 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ApplyProjectChangeSet writes a change set to a project in a single transaction:
+// either every change is applied or none is. It returns the services it created.
+func (s *Store) ApplyProjectChangeSet(ctx context.Context, projectID int64, changes ProjectChangeSet) ([]Service, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if changes.Project != nil {
+		project := changes.Project
+		if project.Name == "" || len(project.Name) > 64 {
+			return nil, fmt.Errorf("invalid project name: must be 1-64 characters")
+		}
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE projects SET name = ?, repo_url = ?, branch = ?, image_target = ? WHERE id = ?",
+			project.Name, project.RepoURL, project.Branch, project.ImageTarget, projectID); err != nil {
+			return nil, fmt.Errorf("failed to update project: %w", err)
+		}
+	}
+
+	for _, id := range changes.DeleteRoutes {
+		if err := execOne(ctx, tx, "DELETE FROM routes WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete route %d: %w", id, err)
+		}
+	}
+
+	// Routes and links of deleted services go with them through ON DELETE CASCADE
+	for _, id := range changes.DeleteServices {
+		if err := execOne(ctx, tx, "DELETE FROM services WHERE id = ? AND project_id = ?", id, projectID); err != nil {
+			return nil, fmt.Errorf("failed to delete service %d: %w", id, err)
+		}
+	}
+
+	for _, service := range changes.UpdateServices {
+		if err := updateServiceConfig(ctx, tx, projectID, service); err != nil {
+			return nil, fmt.Errorf("service %s: %w", service.Name, err)
+		}
+	}
+
+	created := make([]Service, 0, len(changes.CreateServices))
+	for _, spec := range changes.CreateServices {
+		service, err := insertService(ctx, tx, projectID, spec)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", spec.Name, err)
+		}
+		created = append(created, service)
+	}
+
+	serviceIDs, err := projectServiceIDs(ctx, tx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	serviceID := func(name string) (int64, error) {
+		id, ok := serviceIDs[name]
+		if !ok {
+			return 0, fmt.Errorf("service not found in project: %s", name)
+		}
+		return id, nil
+	}
+
+	// Conflicts are checked once every route is written, so routes of a domain
+	// can change their TLS setting together
+	var written []ServiceRoute
+	for _, route := range changes.CreateRoutes {
+		id, err := serviceID(route.Service)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateServiceRouteSpec(route.Spec); err != nil {
+			return nil, err
+		}
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO routes (service_id, kind, domain, port, tls, path, match_type, strip_prefix, certificate_id, domain_id, proxy_config, access_config) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			id, RouteKindProxy, route.Spec.Domain, route.Spec.Port, route.Spec.TLS, route.Spec.Path, routeMatchType(route.Spec.MatchType), route.Spec.StripPrefix,
+			route.Spec.CertificateID, route.Spec.DomainID, route.Spec.ProxyConfig, route.Spec.Access)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create route %s: %w", route.Spec.Domain, err)
+		}
+		if route.ID, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to get route ID: %w", err)
+		}
+		written = append(written, route)
+	}
+
+	for _, route := range changes.UpdateRoutes {
+		if err := validateServiceRouteSpec(route.Spec); err != nil {
+			return nil, err
+		}
+		written = append(written, route)
+		if err := execOne(ctx, tx,
+			"UPDATE routes SET domain = ?, port = ?, tls = ?, path = ?, match_type = ?, strip_prefix = ?, certificate_id = ?, proxy_config = ?, access_config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			route.Spec.Domain, route.Spec.Port, route.Spec.TLS, route.Spec.Path, routeMatchType(route.Spec.MatchType), route.Spec.StripPrefix,
+			route.Spec.CertificateID, route.Spec.ProxyConfig, route.Spec.Access, route.ID); err != nil {
+			return nil, fmt.Errorf("failed to update route %s: %w", route.Spec.Domain, err)
+		}
+	}
+
+	for _, route := range written {
+		if err := checkRouteConflict(ctx, tx, route.Spec, route.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, links := range changes.Links {
+		id, err := serviceID(links.Service)
+		if err != nil {
+			return nil, err
+		}
+		targetIDs := append([]int64{}, links.TargetIDs...)
+		for _, target := range links.Targets {
+			targetID, err := serviceID(target)
+			if err != nil {
+				return nil, err
+			}
+			targetIDs = append(targetIDs, targetID)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM service_links WHERE service_id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to clear links of service %s: %w", links.Service, err)
+		}
+		for _, targetID := range targetIDs {
+			if targetID == id {
+				continue // Skip self-links
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO service_links (service_id, target_id) VALUES (?, ?)", id, targetID); err != nil {
+				return nil, fmt.Errorf("failed to link service %s to service %d: %w", links.Service, targetID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit change set: %w", err)
+	}
+
+	// Update search index in background to avoid blocking the operation
+	go func() {
+		ctx := context.Background()
+		for _, id := range changes.DeleteServices {
+			if err := s.SearchDeleteByEntity(ctx, "service", id); err != nil {
+				log.Warn().Err(err).Int64("service_id", id).Msg("failed to remove service from search index")
+			}
+		}
+		for _, id := range serviceIDs {
+			if err := s.IndexService(ctx, id); err != nil {
+				log.Warn().Err(err).Int64("service_id", id).Msg("failed to update search index for service")
+			}
+		}
+		if err := s.IndexProject(ctx, projectID); err != nil {
+			log.Warn().Err(err).Int64("project_id", projectID).Msg("failed to update search index for project")
+		}
+	}()
+
+	return created, nil
+}
+
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// execOne runs a statement that must affect exactly one row
+func execOne(ctx context.Context, db dbExecutor, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// validateServiceSpec applies the checks CreateService makes before inserting a service
+func validateServiceSpec(spec ServiceSpec) error {
+	if spec.Name == "" || len(spec.Name) > 64 {
+		return fmt.Errorf("invalid service name: must be 1-64 characters")
+	}
+	if spec.Image == "" {
+		return fmt.Errorf("service image cannot be empty")
+	}
+	if !isDNSLabel(spec.Name) {
+		return fmt.Errorf("service name must be DNS-label friendly")
+	}
+	if err := spec.Resources.Validate(); err != nil {
+		return fmt.Errorf("invalid resource limits: %w", err)
+	}
+	if err := spec.RestartPolicy.Validate(); err != nil {
+		return fmt.Errorf("invalid restart policy: %w", err)
+	}
+	if err := ValidateVolumes(spec.Volumes); err != nil {
+		return fmt.Errorf("invalid volumes: %w", err)
+	}
+	if spec.Kind != "" && !IsValidServiceKind(spec.Kind) {
+		return fmt.Errorf("kind must be one of: service, task")
+	}
+	if spec.Kind == ServiceKindTask {
+		if spec.Task == nil {
+			return fmt.Errorf("scheduled tasks need a task schedule")
+		}
+		if err := spec.Task.normalized().Validate(); err != nil {
+			return err
+		}
+		if len(spec.Ports) > 0 {
+			return fmt.Errorf("scheduled tasks cannot publish ports")
+		}
+	} else if spec.Task != nil {
+		return fmt.Errorf("task settings are only allowed for scheduled tasks")
+	}
+	containers := ServiceContainers{InitContainers: spec.InitContainers, Sidecars: spec.Sidecars}
+	if !containers.Empty() {
+		if spec.Kind == ServiceKindTask {
+			return fmt.Errorf("scheduled tasks cannot have init containers or sidecars")
+		}
+		if err := containers.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the kind, domain, path, match type, proxy policy, access
+// policy and response of a route
+func (spec RouteSpec) Validate() error {
+	return validateRouteSpec(spec)
+}
+
+// validateRouteSpec applies the checks CreateRoute makes before inserting a route
+func validateRouteSpec(spec RouteSpec) error {
+	if spec.Domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+	if len(spec.Domain) > 253 { // Max domain name length
+		return fmt.Errorf("domain too long: max 253 characters")
+	}
+	switch routeMatchType(spec.MatchType) {
+	case RouteMatchPrefix, RouteMatchExact:
+		if spec.Path != nil && *spec.Path != "" && !routePathRegex.MatchString(*spec.Path) {
+			return fmt.Errorf("path must start with / and cannot contain whitespace, quotes, ;, { or }")
+		}
+	case RouteMatchRegex:
+		if spec.Path == nil || *spec.Path == "" {
+			return fmt.Errorf("regex routes need a path pattern")
+		}
+		if strings.IndexFunc(*spec.Path, unicode.IsControl) >= 0 {
+			return fmt.Errorf("path pattern cannot contain control characters")
+		}
+		if _, err := regexp.Compile(*spec.Path); err != nil {
+			return fmt.Errorf("invalid path pattern: %w", err)
+		}
+	default:
+		return fmt.Errorf("match_type must be one of prefix, exact, regex")
+	}
+	if spec.StripPrefix {
+		if routeMatchType(spec.MatchType) != RouteMatchPrefix {
+			return fmt.Errorf("strip_prefix only applies to prefix routes")
+		}
+		if routeLocation(spec.Path) == "/" {
+			return fmt.Errorf("strip_prefix needs a path other than /")
+		}
+	}
+	if spec.ProxyConfig != nil {
+		if err := spec.ProxyConfig.Validate(); err != nil {
+			return fmt.Errorf("invalid proxy_config: %w", err)
+		}
+	}
+	if spec.Access != nil {
+		if err := spec.Access.Validate(); err != nil {
+			return fmt.Errorf("invalid access: %w", err)
+		}
+	}
+
+	kind := routeKind(spec.Kind)
+	switch kind {
+	case RouteKindProxy:
+		if spec.Port < 1 || spec.Port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+	case RouteKindRedirect:
+		if spec.Redirect == nil {
+			return fmt.Errorf("redirect routes need a redirect")
+		}
+		if err := spec.Redirect.Validate(); err != nil {
+			return fmt.Errorf("invalid redirect: %w", err)
+		}
+	case RouteKindStatic:
+		if spec.Static == nil {
+			return fmt.Errorf("static routes need a static response")
+		}
+		if err := spec.Static.Validate(); err != nil {
+			return fmt.Errorf("invalid static: %w", err)
+		}
+	default:
+		return fmt.Errorf("kind must be one of proxy, redirect, static")
+	}
+	if spec.Redirect != nil && kind != RouteKindRedirect {
+		return fmt.Errorf("redirect only applies to redirect routes")
+	}
+	if spec.Static != nil && kind != RouteKindStatic {
+		return fmt.Errorf("static only applies to static routes")
+	}
+	// nginx answers these routes before it checks limits or access
+	if !spec.Proxies() && (spec.Port != 0 || spec.StripPrefix || spec.ProxyConfig != nil || spec.Access != nil) {
+		return fmt.Errorf("port, strip_prefix, proxy_config and access only apply to proxy routes")
+	}
+	return nil
+}
+
+// validateServiceRouteSpec checks a route of a service, which proxies to it
+func validateServiceRouteSpec(spec RouteSpec) error {
+	if err := validateRouteSpec(spec); err != nil {
+		return err
+	}
+	if !spec.Proxies() {
+		return fmt.Errorf("%w: routes of a service proxy to it, %s routes have no service", ErrRouteKind, spec.Kind)
+	}
+	return nil
+}
+
+// checkRouteConflict returns ErrRouteConflict when another route of the domain
+// already matches the same location, or uses a different TLS setting. Routes of
+// a domain share one nginx server block.
+func checkRouteConflict(ctx context.Context, db dbExecutor, spec RouteSpec, routeID int64) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, tls, path, match_type FROM routes WHERE domain = ? AND id != ?", spec.Domain, routeID)
+	if err != nil {
+		return fmt.Errorf("failed to check route conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	location, matchType := routeLocation(spec.Path), routeMatchType(spec.MatchType)
+	for rows.Next() {
+		var other Route
+		if err := rows.Scan(&other.ID, &other.TLS, &other.Path, &other.MatchType); err != nil {
+			return fmt.Errorf("failed to scan route: %w", err)
+		}
+		if other.Location() == location && routeMatchType(other.MatchType) == matchType {
+			return fmt.Errorf("%w: route %d already matches %s %s on %s", ErrRouteConflict, other.ID, matchType, location, spec.Domain)
+		}
+		if other.TLS != spec.TLS {
+			return fmt.Errorf("%w: tls must be the same for every route of %s, route %d has tls %t", ErrRouteConflict, spec.Domain, other.ID, other.TLS)
+		}
+	}
+
+	return rows.Err()
+}
+
+// insertService inserts a service row with the same defaults as CreateService
+func insertService(ctx context.Context, db dbExecutor, projectID int64, spec ServiceSpec) (Service, error) {
+	if err := validateServiceSpec(spec); err != nil {
+		return Service{}, err
+	}
+	restartPolicy := spec.RestartPolicy.normalized()
+
+	envJSON, err := marshalJSON(spec.Env)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to marshal env: %w", err)
+	}
+	portsJSON, err := marshalJSON(spec.Ports)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to marshal ports: %w", err)
+	}
+	volumesJSON, err := marshalJSON(spec.Volumes)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to marshal volumes: %w", err)
+	}
+	resourcesJSON, err := marshalJSON(spec.Resources)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to marshal resources: %w", err)
+	}
+
+	kind := spec.Kind
+	if kind == "" {
+		kind = ServiceKindService
+	}
+
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO services (project_id, name, image, env, ports, volumes, registry_id, container_id, health_path, desired_state, restart_count, crash_looping, health_status, resources, restart_policy, restart_max_retries, kind) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		projectID, spec.Name, spec.Image, envJSON, portsJSON, volumesJSON, spec.RegistryID, nil, spec.HealthPath, ServiceStateRunning, 0, false, HealthStatusUnknown, resourcesJSON, restartPolicy.Name, restartPolicy.MaxRetries, kind)
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to create service: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Service{}, fmt.Errorf("failed to get service ID: %w", err)
+	}
+
+	var task *TaskConfig
+	if kind == ServiceKindTask {
+		config := spec.Task.normalized()
+		config.ServiceID = id
+		if err := upsertTaskConfig(ctx, db, &config); err != nil {
+			return Service{}, err
+		}
+		task = &config
+	}
+
+	containers := ServiceContainers{InitContainers: spec.InitContainers, Sidecars: spec.Sidecars}
+	if err := insertServiceContainers(ctx, db, id, containers); err != nil {
+		return Service{}, err
+	}
+
+	return Service{
+		ID:             id,
+		ProjectID:      projectID,
+		Name:           spec.Name,
+		Image:          spec.Image,
+		Env:            spec.Env,
+		Ports:          spec.Ports,
+		Volumes:        spec.Volumes,
+		RegistryID:     spec.RegistryID,
+		HealthPath:     spec.HealthPath,
+		DesiredState:   ServiceStateRunning,
+		HealthStatus:   HealthStatusUnknown,
+		DeployStrategy: DeployStrategyRecreate,
+		RollbackWindow: DefaultRollbackWindow,
+		Replicas:       1,
+		LoadBalancing:  LoadBalancingRoundRobin,
+		Resources:      spec.Resources,
+		RestartPolicy:  restartPolicy,
+		Kind:           kind,
+		Task:           task,
+	}, nil
+}
+
+// updateServiceConfig writes the configuration columns of a service of the project
+func updateServiceConfig(ctx context.Context, db dbExecutor, projectID int64, service Service) error {
+	spec := ServiceSpec{
+		Name:          service.Name,
+		Image:         service.Image,
+		Volumes:       service.Volumes,
+		Resources:     service.Resources,
+		RestartPolicy: service.RestartPolicy,
+	}
+	if err := validateServiceSpec(spec); err != nil {
+		return err
+	}
+	restartPolicy := service.RestartPolicy.normalized()
+
+	envJSON, err := marshalJSON(service.Env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal env: %w", err)
+	}
+	portsJSON, err := marshalJSON(service.Ports)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ports: %w", err)
+	}
+	volumesJSON, err := marshalJSON(service.Volumes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal volumes: %w", err)
+	}
+	resourcesJSON, err := marshalJSON(service.Resources)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resources: %w", err)
+	}
+
+	err = execOne(ctx, db, `UPDATE services SET
+		name = ?,
+		description = ?,
+		image = ?,
+		env = ?,
+		ports = ?,
+		volumes = ?,
+		health_path = ?,
+		resources = ?,
+		restart_policy = ?,
+		restart_max_retries = ?
+		WHERE id = ? AND project_id = ?`,
+		service.Name, service.Description, service.Image, envJSON, portsJSON, volumesJSON, service.HealthPath,
+		resourcesJSON, restartPolicy.Name, restartPolicy.MaxRetries, service.ID, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to update service: %w", err)
+	}
+	return nil
+}
+
+// projectServiceIDs maps the service names of a project to their IDs
+func projectServiceIDs(ctx context.Context, db dbExecutor, projectID int64) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM services WHERE project_id = ?", projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project services: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan project service: %w", err)
+		}
+		ids[name] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate project services: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateDeployment inserts a new deployment record and sets its ID
+func (s *Store) CreateDeployment(ctx context.Context, deployment *Deployment) error {
+	if deployment.CreatedAt.IsZero() {
+		deployment.CreatedAt = time.Now()
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO deployments (project_id, service_id, image_tag, status, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		deployment.ProjectID, deployment.ServiceID, deployment.ImageTag, deployment.Status, deployment.Reason, deployment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get deployment ID: %w", err)
+	}
+	deployment.ID = id
+
+	return nil
+}
+
+// GetDeployment retrieves a deployment by ID
+func (s *Store) GetDeployment(ctx context.Context, deploymentID int64) (*Deployment, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, project_id, service_id, image_tag, status, reason, created_at FROM deployments WHERE id = ?", deploymentID)
+
+	deployment, err := scanDeployment(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	return deployment, nil
+}
+
+// ListDeployments returns the deployments of a service, newest first
+func (s *Store) ListDeployments(ctx context.Context, serviceID int64) ([]*Deployment, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, project_id, service_id, image_tag, status, reason, created_at FROM deployments WHERE service_id = ? ORDER BY created_at DESC, id DESC",
+		serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	defer rows.Close()
+
+	var deployments []*Deployment
+	for rows.Next() {
+		deployment, err := scanDeployment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		deployments = append(deployments, deployment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate deployments: %w", err)
+	}
+
+	return deployments, nil
+}
+
+// GetLatestDeployment returns the most recent deployment of a service
+func (s *Store) GetLatestDeployment(ctx context.Context, serviceID int64) (*Deployment, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, project_id, service_id, image_tag, status, reason, created_at FROM deployments WHERE service_id = ? ORDER BY created_at DESC, id DESC LIMIT 1",
+		serviceID)
+
+	deployment, err := scanDeployment(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest deployment: %w", err)
+	}
+
+	return deployment, nil
+}
+
+// UpdateDeploymentStatus sets the status of a deployment. A nil reason keeps the existing one.
+func (s *Store) UpdateDeploymentStatus(ctx context.Context, deploymentID int64, status string, reason *string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE deployments SET status = ?, reason = COALESCE(?, reason) WHERE id = ?",
+		status, reason, deploymentID)
+	if err != nil {
+		return fmt.Errorf("failed to update deployment status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanDeployment reads a deployment from a row
+func scanDeployment(row rowScanner) (*Deployment, error) {
+	var deployment Deployment
+	var reason sql.NullString
+
+	if err := row.Scan(&deployment.ID, &deployment.ProjectID, &deployment.ServiceID, &deployment.ImageTag,
+		&deployment.Status, &reason, &deployment.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if reason.Valid {
+		deployment.Reason = &reason.String
+	}
+
+	return &deployment, nil
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// healthCheckColumns are the columns scanned by scanHealthCheck
+const healthCheckColumns = "service_id, type, path, port, command, grpc_service, expected_status, body_match, interval_seconds, timeout_seconds, healthy_threshold, unhealthy_threshold, start_period_seconds, updated_at"
+
+// GetHealthCheck retrieves the health check a service configured
+func (s *Store) GetHealthCheck(ctx context.Context, serviceID int64) (*HealthCheck, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+healthCheckColumns+" FROM service_health_checks WHERE service_id = ?", serviceID)
+
+	check, err := scanHealthCheck(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get health check: %w", err)
+	}
+
+	return check, nil
+}
+
+// SetHealthCheck creates or replaces the health check of a service
+func (s *Store) SetHealthCheck(ctx context.Context, check *HealthCheck) error {
+	*check = check.normalized()
+	if err := check.Validate(); err != nil {
+		return err
+	}
+
+	commandJSON, err := marshalJSON(check.Command)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command: %w", err)
+	}
+	check.UpdatedAt = time.Now().UTC()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO service_health_checks (service_id, type, path, port, command, grpc_service, expected_status, body_match,
+			interval_seconds, timeout_seconds, healthy_threshold, unhealthy_threshold, start_period_seconds, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(service_id) DO UPDATE SET
+			type = excluded.type,
+			path = excluded.path,
+			port = excluded.port,
+			command = excluded.command,
+			grpc_service = excluded.grpc_service,
+			expected_status = excluded.expected_status,
+			body_match = excluded.body_match,
+			interval_seconds = excluded.interval_seconds,
+			timeout_seconds = excluded.timeout_seconds,
+			healthy_threshold = excluded.healthy_threshold,
+			unhealthy_threshold = excluded.unhealthy_threshold,
+			start_period_seconds = excluded.start_period_seconds,
+			updated_at = excluded.updated_at
+	`, check.ServiceID, check.Type, check.Path, check.Port, commandJSON, check.GRPCService, check.ExpectedStatus, check.BodyMatch,
+		check.IntervalSeconds, check.TimeoutSeconds, check.HealthyThreshold, check.UnhealthyThreshold, check.StartPeriodSeconds, check.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set health check: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteHealthCheck removes the health check of a service, so it is checked
+// the way its image suggests again
+func (s *Store) DeleteHealthCheck(ctx context.Context, serviceID int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM service_health_checks WHERE service_id = ?", serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to delete health check: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordHealthCheck appends a probe to the health history of a service and
+// prunes entries beyond HealthHistoryRetention
+func (s *Store) RecordHealthCheck(ctx context.Context, record *HealthCheckRecord) error {
+	if record.CheckedAt.IsZero() {
+		record.CheckedAt = time.Now().UTC()
+	}
+	if len(record.Output) > MaxHealthCheckOutput {
+		record.Output = record.Output[:MaxHealthCheckOutput]
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO service_health_history (service_id, check_type, result, status, output, duration_ms, in_start_period, checked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		record.ServiceID, record.CheckType, record.Result, record.Status, record.Output, record.DurationMs, record.InStartPeriod, record.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record health check: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get health check record ID: %w", err)
+	}
+	record.ID = id
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM service_health_history
+		WHERE service_id = ? AND id NOT IN (
+			SELECT id FROM service_health_history WHERE service_id = ? ORDER BY id DESC LIMIT ?
+		)`, record.ServiceID, record.ServiceID, HealthHistoryRetention)
+	if err != nil {
+		return fmt.Errorf("failed to prune health history: %w", err)
+	}
+
+	return nil
+}
+
+// ListHealthHistory returns the latest probes of a service, newest first
+func (s *Store) ListHealthHistory(ctx context.Context, serviceID int64, limit int) ([]HealthCheckRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, service_id, check_type, result, status, output, duration_ms, in_start_period, checked_at
+		FROM service_health_history WHERE service_id = ?
+		ORDER BY id DESC LIMIT ?`, serviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list health history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []HealthCheckRecord
+	for rows.Next() {
+		var record HealthCheckRecord
+		if err := rows.Scan(&record.ID, &record.ServiceID, &record.CheckType, &record.Result, &record.Status,
+			&record.Output, &record.DurationMs, &record.InStartPeriod, &record.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan health check record: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate health history: %w", err)
+	}
+
+	return records, nil
+}
+
+// scanHealthCheck scans a single service_health_checks row
+func scanHealthCheck(row rowScanner) (*HealthCheck, error) {
+	var check HealthCheck
+	var commandJSON sql.NullString
+
+	err := row.Scan(
+		&check.ServiceID,
+		&check.Type,
+		&check.Path,
+		&check.Port,
+		&commandJSON,
+		&check.GRPCService,
+		&check.ExpectedStatus,
+		&check.BodyMatch,
+		&check.IntervalSeconds,
+		&check.TimeoutSeconds,
+		&check.HealthyThreshold,
+		&check.UnhealthyThreshold,
+		&check.StartPeriodSeconds,
+		&check.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalJSON(commandJSON.String, &check.Command); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal command: %w", err)
+	}
+
+	return &check, nil
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CreateJob inserts a new background job record
+func (s *Store) CreateJob(ctx context.Context, job *JobRecord) error {
+	if job.ID == "" {
+		return fmt.Errorf("job ID is required")
+	}
+
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	if job.Data == "" {
+		job.Data = "{}"
+	}
+
+	query := `
+		INSERT INTO jobs (id, type, status, data, error, progress, attempts, max_attempts, timeout_seconds,
+			created_at, started_at, finished_at, deadline, next_run_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		job.ID,
+		job.Type,
+		job.Status,
+		job.Data,
+		job.Error,
+		job.Progress,
+		job.Attempts,
+		job.MaxAttempts,
+		job.TimeoutSeconds,
+		job.CreatedAt,
+		job.StartedAt,
+		job.FinishedAt,
+		job.Deadline,
+		job.NextRunAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateJob persists the mutable state of a background job
+func (s *Store) UpdateJob(ctx context.Context, job *JobRecord) error {
+	query := `
+		UPDATE jobs
+		SET status = ?, data = ?, error = ?, progress = ?, attempts = ?, max_attempts = ?, timeout_seconds = ?,
+			started_at = ?, finished_at = ?, deadline = ?, next_run_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query,
+		job.Status,
+		job.Data,
+		job.Error,
+		job.Progress,
+		job.Attempts,
+		job.MaxAttempts,
+		job.TimeoutSeconds,
+		job.StartedAt,
+		job.FinishedAt,
+		job.Deadline,
+		job.NextRunAt,
+		job.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetJob retrieves a background job record by ID
+func (s *Store) GetJob(ctx context.Context, id string) (*JobRecord, error) {
+	query := `
+		SELECT id, type, status, data, error, progress, attempts, max_attempts, timeout_seconds,
+			created_at, started_at, finished_at, deadline, next_run_at
+		FROM jobs
+		WHERE id = ?
+	`
+
+	job, err := scanJobRecord(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ListJobsByStatus returns job records in any of the given statuses, oldest first
+func (s *Store) ListJobsByStatus(ctx context.Context, statuses ...string) ([]*JobRecord, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",")
+	query := fmt.Sprintf(`
+		SELECT id, type, status, data, error, progress, attempts, max_attempts, timeout_seconds,
+			created_at, started_at, finished_at, deadline, next_run_at
+		FROM jobs
+		WHERE status IN (%s)
+		ORDER BY created_at ASC
+	`, placeholders)
+
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*JobRecord
+	for rows.Next() {
+		job, err := scanJobRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// PruneJobs deletes finished job records beyond the newest keep. Queued and
+// running jobs are never pruned.
+func (s *Store) PruneJobs(ctx context.Context, keep int) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM jobs
+		WHERE status NOT IN ('queued', 'running') AND id NOT IN (
+			SELECT id FROM jobs WHERE status NOT IN ('queued', 'running') ORDER BY created_at DESC, id DESC LIMIT ?
+		)`, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune jobs: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanJobRecord scans a single jobs row
+func scanJobRecord(row rowScanner) (*JobRecord, error) {
+	var job JobRecord
+	var errMsg sql.NullString
+	var startedAt, finishedAt, deadline, nextRunAt sql.NullTime
+
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Status,
+		&job.Data,
+		&errMsg,
+		&job.Progress,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.TimeoutSeconds,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&deadline,
+		&nextRunAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if errMsg.Valid {
+		job.Error = &errMsg.String
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if deadline.Valid {
+		job.Deadline = &deadline.Time
+	}
+	if nextRunAt.Valid {
+		job.NextRunAt = &nextRunAt.Time
+	}
+
+	return &job, nil
+}
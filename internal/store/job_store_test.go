@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneJobs_KeepsNewestFinished(t *testing.T) {
+	ctx := context.Background()
+	st, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer st.Close()
+	require.NoError(t, st.Migrate(ctx))
+
+	created := time.Now().Add(-time.Hour)
+	for i, status := range []string{"success", "failed", "queued", "cancelled", "running", "success"} {
+		require.NoError(t, st.CreateJob(ctx, &JobRecord{
+			ID:        fmt.Sprintf("job-%d", i),
+			Type:      "build",
+			Status:    status,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	require.NoError(t, st.PruneJobs(ctx, 2))
+
+	for _, id := range []string{"job-0", "job-1"} {
+		_, err := st.GetJob(ctx, id)
+		assert.ErrorIs(t, err, ErrNotFound, id)
+	}
+	for _, id := range []string{"job-2", "job-3", "job-4", "job-5"} {
+		_, err := st.GetJob(ctx, id)
+		assert.NoError(t, err, id)
+	}
+}
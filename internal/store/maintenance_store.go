@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maintenanceWindowColumns are the columns scanned by scanMaintenanceWindow
+const maintenanceWindowColumns = "id, project_id, reason, starts_at, ends_at, serve_page, page_html, created_by, created_at, updated_at"
+
+// CreateMaintenanceWindow inserts a maintenance window and sets its ID
+func (s *Store) CreateMaintenanceWindow(ctx context.Context, window *MaintenanceWindow) error {
+	normalizeMaintenanceWindow(window)
+	if err := window.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO maintenance_windows (project_id, reason, starts_at, ends_at, serve_page, page_html, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		window.ProjectID, window.Reason, window.StartsAt, window.EndsAt, window.ServePage, window.PageHTML, window.CreatedBy, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get maintenance window ID: %w", err)
+	}
+	window.ID = id
+	window.CreatedAt = now
+	window.UpdatedAt = now
+
+	return nil
+}
+
+// GetMaintenanceWindow retrieves a maintenance window by ID
+func (s *Store) GetMaintenanceWindow(ctx context.Context, id int64) (*MaintenanceWindow, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT "+maintenanceWindowColumns+" FROM maintenance_windows WHERE id = ?", id)
+
+	window, err := scanMaintenanceWindow(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance window: %w", err)
+	}
+
+	return window, nil
+}
+
+// ListMaintenanceWindows returns maintenance windows, latest start first. Windows
+// that ended before now are left out unless includeEnded is set.
+func (s *Store) ListMaintenanceWindows(ctx context.Context, includeEnded bool, now time.Time) ([]MaintenanceWindow, error) {
+	query := "SELECT " + maintenanceWindowColumns + " FROM maintenance_windows"
+	var args []interface{}
+	if !includeEnded {
+		query += " WHERE ends_at IS NULL OR ends_at > ?"
+		args = append(args, now.UTC().Truncate(time.Second))
+	}
+	query += " ORDER BY starts_at DESC, id DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []MaintenanceWindow
+	for rows.Next() {
+		window, err := scanMaintenanceWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+		}
+		windows = append(windows, *window)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate maintenance windows: %w", err)
+	}
+
+	return windows, nil
+}
+
+// UpdateMaintenanceWindow replaces the schedule, reason and page of a maintenance window
+func (s *Store) UpdateMaintenanceWindow(ctx context.Context, window *MaintenanceWindow) error {
+	normalizeMaintenanceWindow(window)
+	if err := window.Validate(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE maintenance_windows SET reason = ?, starts_at = ?, ends_at = ?, serve_page = ?, page_html = ?, updated_at = ? WHERE id = ?",
+		window.Reason, window.StartsAt, window.EndsAt, window.ServePage, window.PageHTML, now, window.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update maintenance window: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	window.UpdatedAt = now
+
+	return nil
+}
+
+// EndMaintenanceWindow ends a maintenance window at the given time. A window that
+// has not started yet is removed instead; one that already ended is left alone.
+func (s *Store) EndMaintenanceWindow(ctx context.Context, id int64, at time.Time) (*MaintenanceWindow, error) {
+	window, err := s.GetMaintenanceWindow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	at = at.UTC().Truncate(time.Second)
+	if at.Before(window.StartsAt) {
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM maintenance_windows WHERE id = ?", id); err != nil {
+			return nil, fmt.Errorf("failed to delete maintenance window: %w", err)
+		}
+		return window, nil
+	}
+	if window.EndsAt != nil && !window.EndsAt.After(at) {
+		return window, nil
+	}
+
+	// updated_at moves too, so nginx takes the maintenance page down
+	now := time.Now().UTC()
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE maintenance_windows SET ends_at = ?, updated_at = ? WHERE id = ?", at, now, id); err != nil {
+		return nil, fmt.Errorf("failed to end maintenance window: %w", err)
+	}
+	window.EndsAt = &at
+	window.UpdatedAt = now
+
+	return window, nil
+}
+
+// ActiveMaintenanceWindow returns the maintenance window in effect for a project
+// at the given time, preferring one scoped to the project over a global one.
+// It returns ErrNotFound when the project is not under maintenance.
+func (s *Store) ActiveMaintenanceWindow(ctx context.Context, projectID int64, at time.Time) (*MaintenanceWindow, error) {
+	at = at.UTC().Truncate(time.Second)
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+maintenanceWindowColumns+` FROM maintenance_windows
+		WHERE (project_id = ? OR project_id IS NULL) AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)
+		ORDER BY project_id IS NULL, starts_at DESC, id DESC
+		LIMIT 1`, projectID, at, at)
+
+	window, err := scanMaintenanceWindow(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active maintenance window: %w", err)
+	}
+
+	return window, nil
+}
+
+// normalizeMaintenanceWindow stores a window's schedule in UTC to the second, so
+// schedules compare correctly as text
+func normalizeMaintenanceWindow(window *MaintenanceWindow) {
+	window.StartsAt = window.StartsAt.UTC().Truncate(time.Second)
+	if window.EndsAt != nil {
+		endsAt := window.EndsAt.UTC().Truncate(time.Second)
+		window.EndsAt = &endsAt
+	}
+}
+
+// scanMaintenanceWindow scans a single maintenance_windows row
+func scanMaintenanceWindow(row rowScanner) (*MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	var projectID sql.NullInt64
+	var endsAt sql.NullTime
+
+	err := row.Scan(
+		&window.ID,
+		&projectID,
+		&window.Reason,
+		&window.StartsAt,
+		&endsAt,
+		&window.ServePage,
+		&window.PageHTML,
+		&window.CreatedBy,
+		&window.CreatedAt,
+		&window.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if projectID.Valid {
+		window.ProjectID = &projectID.Int64
+	}
+	if endsAt.Valid {
+		window.EndsAt = &endsAt.Time
+	}
+
+	return &window, nil
+}
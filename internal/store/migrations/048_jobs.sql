@@ -0,0 +1,15 @@
+-- Create jobs table so background jobs survive controller restarts
+CREATE TABLE jobs (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    data TEXT NOT NULL DEFAULT '{}',
+    error TEXT,
+    progress INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+
+CREATE INDEX idx_jobs_status ON jobs(status, created_at);
+CREATE INDEX idx_jobs_type ON jobs(type);
@@ -0,0 +1,6 @@
+-- Track retry attempts, per-attempt deadlines and delayed retries for background jobs
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN max_attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE jobs ADD COLUMN timeout_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN deadline DATETIME;
+ALTER TABLE jobs ADD COLUMN next_run_at DATETIME;
@@ -0,0 +1,3 @@
+-- Per-service rollout strategy and the network alias nginx currently proxies to
+ALTER TABLE services ADD COLUMN deploy_strategy TEXT NOT NULL DEFAULT 'recreate';
+ALTER TABLE services ADD COLUMN upstream_host TEXT;
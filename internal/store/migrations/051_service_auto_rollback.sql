@@ -0,0 +1,3 @@
+-- Per-service automatic rollback after unhealthy deployments
+ALTER TABLE services ADD COLUMN auto_rollback BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE services ADD COLUMN rollback_window_seconds INTEGER NOT NULL DEFAULT 300;
@@ -0,0 +1,3 @@
+-- Run services as several replicas balanced by the nginx upstream
+ALTER TABLE services ADD COLUMN replicas INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE services ADD COLUMN load_balancing TEXT NOT NULL DEFAULT 'round_robin';
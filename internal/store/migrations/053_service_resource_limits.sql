@@ -0,0 +1,4 @@
+-- Add resource limits and restart policy to services
+ALTER TABLE services ADD COLUMN resources TEXT;
+ALTER TABLE services ADD COLUMN restart_policy TEXT NOT NULL DEFAULT 'no';
+ALTER TABLE services ADD COLUMN restart_max_retries INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+-- Scheduled snapshots of service volumes
+CREATE TABLE IF NOT EXISTS volume_backup_schedules (
+    service_id INTEGER PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    interval_minutes INTEGER NOT NULL,
+    retention INTEGER NOT NULL DEFAULT 7,
+    quiesce TEXT NOT NULL DEFAULT 'none',
+    last_run_at DATETIME,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS volume_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    volume TEXT NOT NULL,
+    file_name TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_volume_snapshots_service ON volume_snapshots (service_id, volume, created_at);
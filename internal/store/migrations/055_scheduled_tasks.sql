@@ -0,0 +1,32 @@
+-- Scheduled tasks: services that run a short-lived container on a cron schedule
+ALTER TABLE services ADD COLUMN kind TEXT NOT NULL DEFAULT 'service';
+
+CREATE TABLE IF NOT EXISTS service_tasks (
+    service_id INTEGER PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    command TEXT,
+    timeout_seconds INTEGER NOT NULL DEFAULT 3600,
+    suspended BOOLEAN NOT NULL DEFAULT 0,
+    last_scheduled_at DATETIME,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS task_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    trigger TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    exit_code INTEGER,
+    error TEXT,
+    logs TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_runs_service ON task_runs (service_id, created_at);
@@ -0,0 +1,18 @@
+-- One-off commands run in a new container with a service's image, env, volumes and network
+CREATE TABLE IF NOT EXISTS service_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    command TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'running',
+    exit_code INTEGER,
+    error TEXT,
+    output TEXT,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_runs_service ON service_runs (service_id, started_at);
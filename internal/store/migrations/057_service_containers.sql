@@ -0,0 +1,16 @@
+-- Init containers and sidecars declared by a service
+CREATE TABLE IF NOT EXISTS service_containers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('init', 'sidecar')),
+    position INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    image TEXT NOT NULL,
+    command TEXT NOT NULL DEFAULT '[]',
+    env TEXT NOT NULL DEFAULT '{}',
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE,
+    UNIQUE (service_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_containers_service ON service_containers (service_id, role, position);
@@ -0,0 +1,36 @@
+-- Health check definitions that replace the checks picked from a service's image
+CREATE TABLE IF NOT EXISTS service_health_checks (
+    service_id INTEGER PRIMARY KEY,
+    type TEXT NOT NULL,
+    path TEXT NOT NULL DEFAULT '',
+    port INTEGER NOT NULL DEFAULT 0,
+    command TEXT,
+    grpc_service TEXT NOT NULL DEFAULT '',
+    expected_status TEXT NOT NULL DEFAULT '',
+    body_match TEXT NOT NULL DEFAULT '',
+    interval_seconds INTEGER NOT NULL DEFAULT 30,
+    timeout_seconds INTEGER NOT NULL DEFAULT 3,
+    healthy_threshold INTEGER NOT NULL DEFAULT 1,
+    unhealthy_threshold INTEGER NOT NULL DEFAULT 3,
+    start_period_seconds INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+-- Outcome of every health probe, newest kept per service
+CREATE TABLE IF NOT EXISTS service_health_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL,
+    check_type TEXT NOT NULL,
+    result TEXT NOT NULL,
+    status TEXT NOT NULL,
+    output TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    in_start_period BOOLEAN NOT NULL DEFAULT 0,
+    checked_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (service_id) REFERENCES services (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_health_history_service ON service_health_history (service_id, checked_at);
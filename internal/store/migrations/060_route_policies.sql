@@ -0,0 +1,5 @@
+-- proxy_config now holds a typed route policy object. Free-form values were
+-- never applied by nginx, so those that are not a JSON object are dropped.
+UPDATE routes SET proxy_config = NULL
+WHERE proxy_config IS NOT NULL
+    AND (json_valid(proxy_config) = 0 OR json_type(proxy_config) != 'object');
@@ -0,0 +1,8 @@
+-- Routes of a domain share one nginx server block, each route a location in it.
+-- A service may have several routes on a domain with different paths, so the
+-- one-route-per-domain index is replaced by a conflict check on the location.
+ALTER TABLE routes ADD COLUMN match_type TEXT NOT NULL DEFAULT 'prefix';
+ALTER TABLE routes ADD COLUMN strip_prefix BOOLEAN NOT NULL DEFAULT 0;
+
+DROP INDEX IF EXISTS ux_routes_service_domain;
+CREATE INDEX IF NOT EXISTS ix_routes_service_id ON routes(service_id);
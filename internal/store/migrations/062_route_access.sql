@@ -0,0 +1,15 @@
+-- Per-route access policy: address rules, basic auth and forward auth
+ALTER TABLE routes ADD COLUMN access_config TEXT;
+
+-- Basic auth users of routes, written to htpasswd files for nginx
+CREATE TABLE IF NOT EXISTS route_credentials (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    route_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (route_id) REFERENCES routes (id) ON DELETE CASCADE,
+    UNIQUE (route_id, username)
+);
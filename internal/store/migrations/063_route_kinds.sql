@@ -0,0 +1,38 @@
+-- Redirect and static routes answer requests themselves and have no service.
+-- SQLite cannot drop NOT NULL from a column, so the routes table is rebuilt.
+-- Dropping it deletes the basic auth users of routes, they are kept aside meanwhile.
+CREATE TEMP TABLE route_credentials_backup AS SELECT * FROM route_credentials;
+
+CREATE TABLE routes_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER REFERENCES services(id) ON DELETE CASCADE, -- NULL for redirect and static routes
+    kind TEXT NOT NULL DEFAULT 'proxy',            -- proxy|redirect|static
+    domain TEXT NOT NULL,
+    port INTEGER NOT NULL DEFAULT 0,               -- 0 for redirect and static routes
+    tls BOOLEAN NOT NULL DEFAULT FALSE,
+    path TEXT,
+    match_type TEXT NOT NULL DEFAULT 'prefix',
+    strip_prefix BOOLEAN NOT NULL DEFAULT 0,
+    certificate_id INTEGER REFERENCES certificates(id),
+    domain_id INTEGER REFERENCES domains(id),
+    proxy_config TEXT,                             -- JSON
+    access_config TEXT,                            -- JSON
+    redirect_config TEXT,                          -- JSON, redirect routes
+    static_config TEXT,                            -- JSON, static routes
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO routes_new (id, service_id, domain, port, tls, path, match_type, strip_prefix, certificate_id, domain_id, proxy_config, access_config, created_at, updated_at)
+SELECT id, service_id, domain, port, tls, path, match_type, strip_prefix, certificate_id, domain_id, proxy_config, access_config, created_at, updated_at
+FROM routes;
+
+DROP TABLE routes;
+ALTER TABLE routes_new RENAME TO routes;
+
+CREATE INDEX ix_routes_domain ON routes(domain);
+CREATE INDEX ix_routes_service_id ON routes(service_id);
+CREATE INDEX idx_routes_domain_id ON routes(domain_id);
+
+INSERT INTO route_credentials SELECT * FROM route_credentials_backup;
+DROP TABLE route_credentials_backup;
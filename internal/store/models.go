@@ -6,16 +6,23 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/GLINCKER/glinrdock/internal/cron"
 	"github.com/GLINCKER/glinrdock/internal/crypto"
 )
 
 // Common errors
 var (
-	ErrNotFound = errors.New("resource not found")
+	ErrNotFound      = errors.New("resource not found")
+	ErrRouteConflict = errors.New("route conflict")
+	ErrRouteKind     = errors.New("route kind does not match its service")
+
+	ErrBlueGreenHostPorts = errors.New("blue/green deploys cannot keep fixed host ports, remove the host ports or use the recreate strategy")
 )
 
 // RBAC Roles
@@ -130,13 +137,140 @@ type PortMap struct {
 	Host      int `json:"host"`
 }
 
-// VolumeMap represents a volume mapping from host to container
+// VolumeMap represents a volume mapping from host to container. Host is either an
+// absolute host path, which is bind-mounted, or the name of a Docker named volume.
 type VolumeMap struct {
 	Host      string `json:"host"`
 	Container string `json:"container"`
 	ReadOnly  bool   `json:"ro"`
 }
 
+// volumeNameRegex matches the names Docker accepts for named volumes
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// blockedHostPaths may not be bind-mounted into a service, nor may any directory
+// holding one of them, which rules out / itself
+var blockedHostPaths = []string{
+	"/etc", "/sys", "/proc", "/dev", "/boot", "/root",
+	"/var/run/docker.sock", "/run/docker.sock",
+}
+
+// bindMountRoots are the host directories bind mounts must be under, any
+// directory outside blockedHostPaths when empty
+var bindMountRoots []string
+
+// SetBindMountRoots restricts bind mounts to paths under roots
+func SetBindMountRoots(roots []string) {
+	bindMountRoots = nil
+	for _, root := range roots {
+		if root = strings.TrimSpace(root); root != "" {
+			bindMountRoots = append(bindMountRoots, filepath.Clean(root))
+		}
+	}
+}
+
+// pathWithin reports whether path is dir or below it, comparing whole components
+func pathWithin(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// checkHostPath rejects host paths that are, hold or sit below a blocked path,
+// and with bind mount roots set, paths outside them
+func checkHostPath(path string) error {
+	if !filepath.IsAbs(path) || path == "/" {
+		return fmt.Errorf("host path not allowed for security reasons: %s", path)
+	}
+	for _, blocked := range blockedHostPaths {
+		if pathWithin(path, blocked) || pathWithin(blocked, path) {
+			return fmt.Errorf("host path not allowed for security reasons: %s", path)
+		}
+	}
+	if len(bindMountRoots) == 0 {
+		return nil
+	}
+	for _, root := range bindMountRoots {
+		if pathWithin(path, root) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host path must be under one of %s: %s", strings.Join(bindMountRoots, ", "), path)
+}
+
+// IsBind reports whether the mapping bind-mounts a host path rather than a named volume
+func (v VolumeMap) IsBind() bool {
+	return strings.HasPrefix(v.Host, "/")
+}
+
+// Name identifies the mapping within its service: the volume name for named
+// volumes, or the container path with slashes replaced by dashes for bind mounts
+func (v VolumeMap) Name() string {
+	if !v.IsBind() {
+		return v.Host
+	}
+	return strings.ReplaceAll(strings.Trim(v.Container, "/"), "/", "-")
+}
+
+// Validate checks the container path and the host path or volume name
+func (v VolumeMap) Validate() error {
+	if v.Host == "" || v.Container == "" {
+		return fmt.Errorf("volume paths cannot be empty")
+	}
+	if !strings.HasPrefix(v.Container, "/") || strings.Trim(v.Container, "/") == "" {
+		return fmt.Errorf("container path must be an absolute path below /: %s", v.Container)
+	}
+	// Name is built from the container path and names snapshot files, so it must not climb out
+	if filepath.Clean(v.Container) != v.Container || hasDotSegment(v.Container) {
+		return fmt.Errorf("container path must be a clean path without . or .. segments: %s", v.Container)
+	}
+	if !v.IsBind() {
+		if !IsValidVolumeName(v.Host) {
+			return fmt.Errorf("host must be an absolute path or a volume name: %s", v.Host)
+		}
+		return nil
+	}
+	// Docker follows symlinks in the host path, so check where an existing one leads too
+	hostPath := filepath.Clean(v.Host)
+	if err := checkHostPath(hostPath); err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(hostPath); err == nil && resolved != hostPath {
+		if err := checkHostPath(resolved); err != nil {
+			return fmt.Errorf("host path not allowed for security reasons: %s", v.Host)
+		}
+	}
+	return nil
+}
+
+// hasDotSegment reports whether any segment of a slash separated path is . or ..
+func hasDotSegment(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateVolumes checks every mapping and rejects two mappings onto the same container path
+func ValidateVolumes(volumes []VolumeMap) error {
+	containerPaths := make(map[string]bool)
+	for _, volume := range volumes {
+		if err := volume.Validate(); err != nil {
+			return err
+		}
+		if containerPaths[volume.Container] {
+			return fmt.Errorf("duplicate volume container path: %s", volume.Container)
+		}
+		containerPaths[volume.Container] = true
+	}
+	return nil
+}
+
+// IsValidVolumeName reports whether name is usable as a Docker named volume
+func IsValidVolumeName(name string) bool {
+	return volumeNameRegex.MatchString(name)
+}
+
 // EnvVar represents an environment variable with encryption support
 type EnvVar struct {
 	ID         int64     `json:"id"`
@@ -180,11 +314,26 @@ type Service struct {
 	CrashLooping    bool              `json:"crash_looping"`               // true if in crash loop state
 	HealthStatus    string            `json:"health_status"`               // ok|fail|unknown
 	LastProbeAt     *time.Time        `json:"last_probe_at,omitempty"`     // last health probe time
+	DeployStrategy  string            `json:"deploy_strategy"`             // recreate|blue_green
+	UpstreamHost    *string           `json:"upstream_host,omitempty"`     // network alias nginx proxies to (blue/green)
+	AutoRollback    bool              `json:"auto_rollback"`               // roll back failed deployments automatically
+	RollbackWindow  int               `json:"rollback_window_seconds"`     // seconds a new deployment is watched for failures
+	Replicas        int               `json:"replicas"`                    // number of containers running the service
+	LoadBalancing   string            `json:"load_balancing"`              // round_robin|least_conn|ip_hash
+	Resources       ResourceLimits    `json:"resources"`                   // CPU, memory and pids limits
+	RestartPolicy   RestartPolicy     `json:"restart_policy"`              // when Docker restarts the container
+	Kind            string            `json:"kind"`                        // service|task
+	Task            *TaskConfig       `json:"task,omitempty"`              // populated with the schedule of tasks when requested
 	Network         *ServiceNetwork   `json:"network,omitempty"`           // populated with networking info when requested
 	Aliases         []string          `json:"aliases,omitempty"`           // populated with DNS aliases when requested
 	CreatedAt       time.Time         `json:"created_at"`
 }
 
+// IsTask reports whether the service is a scheduled task rather than a long-running container
+func (s Service) IsTask() bool {
+	return s.Kind == ServiceKindTask
+}
+
 // GetAlias generates a deterministic service alias for internal networking
 func (s Service) GetAlias(projectName string) string {
 	return GenerateServiceAlias(projectName, s.Name)
@@ -232,6 +381,8 @@ const (
 	HealthCheckPostgres HealthCheckType = "postgres"
 	HealthCheckMySQL    HealthCheckType = "mysql"
 	HealthCheckRedis    HealthCheckType = "redis"
+	HealthCheckExec     HealthCheckType = "exec"
+	HealthCheckGRPC     HealthCheckType = "grpc"
 )
 
 // GetHealthCheckType determines the appropriate health check type for this service
@@ -332,39 +483,280 @@ type ServiceSpec struct {
 	Ports      []PortMap         `json:"ports"`
 	RegistryID *string           `json:"registry_id,omitempty"`
 	HealthPath *string           `json:"health_path,omitempty"`
+	Volumes    []VolumeMap       `json:"volumes"`
+	// Resource limits and restart policy, unlimited and "no" when omitted
+	Resources     ResourceLimits `json:"resources"`
+	RestartPolicy RestartPolicy  `json:"restart_policy"`
+	// Kind is "service" when omitted; tasks need a schedule in Task
+	Kind string      `json:"kind,omitempty"`
+	Task *TaskConfig `json:"task,omitempty"`
+	// Optional init containers and sidecars, services only
+	InitContainers []ServiceContainer `json:"init_containers,omitempty"`
+	Sidecars       []ServiceContainer `json:"sidecars,omitempty"`
+}
+
+// ServiceContainer is an init container or sidecar of a service. Init containers
+// run to completion, in order, before the service's container starts; sidecars run
+// next to it in its network namespace with its volumes. Both get the service's env
+// with their own env laid over it.
+type ServiceContainer struct {
+	Name    string            `json:"name"`
+	Image   string            `json:"image"`
+	Command []string          `json:"command,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// ServiceContainers are the init containers and sidecars declared by a service
+type ServiceContainers struct {
+	InitContainers []ServiceContainer `json:"init_containers"`
+	Sidecars       []ServiceContainer `json:"sidecars"`
+}
+
+// Empty reports whether no init containers or sidecars are declared
+func (c ServiceContainers) Empty() bool {
+	return len(c.InitContainers) == 0 && len(c.Sidecars) == 0
+}
+
+// Validate checks names, images and counts. Names are unique across both lists.
+func (c ServiceContainers) Validate() error {
+	if len(c.InitContainers) > MaxInitContainers {
+		return fmt.Errorf("at most %d init containers are allowed", MaxInitContainers)
+	}
+	if len(c.Sidecars) > MaxSidecars {
+		return fmt.Errorf("at most %d sidecars are allowed", MaxSidecars)
+	}
+
+	names := make(map[string]bool)
+	for _, container := range append(append([]ServiceContainer{}, c.InitContainers...), c.Sidecars...) {
+		if len(container.Name) > 32 || !isDNSLabel(container.Name) {
+			return fmt.Errorf("container name %q must be DNS-label friendly and at most 32 characters", container.Name)
+		}
+		if names[container.Name] {
+			return fmt.Errorf("duplicate container name %q", container.Name)
+		}
+		names[container.Name] = true
+		if container.Image == "" {
+			return fmt.Errorf("container %s needs an image", container.Name)
+		}
+	}
+	return nil
+}
+
+// ResourceLimits caps what a service's containers may use. Zero values leave a resource unlimited.
+type ResourceLimits struct {
+	CPUShares         int64   `json:"cpu_shares,omitempty"`         // relative CPU weight, Docker's default is 1024
+	CPUs              float64 `json:"cpus,omitempty"`               // CPU quota in cores, e.g. 0.5
+	MemoryLimit       int64   `json:"memory_limit,omitempty"`       // hard memory limit in bytes
+	MemoryReservation int64   `json:"memory_reservation,omitempty"` // soft memory limit in bytes
+	PidsLimit         int64   `json:"pids_limit,omitempty"`         // max processes in the container
+}
+
+// Validate checks the limits against what Docker accepts
+func (r ResourceLimits) Validate() error {
+	if r.CPUShares != 0 && (r.CPUShares < MinCPUShares || r.CPUShares > MaxCPUShares) {
+		return fmt.Errorf("cpu_shares must be between %d and %d", MinCPUShares, MaxCPUShares)
+	}
+	if r.CPUs < 0 || (r.CPUs > 0 && r.CPUs < MinCPUs) {
+		return fmt.Errorf("cpus must be at least %.2f", MinCPUs)
+	}
+	if r.MemoryLimit < 0 || (r.MemoryLimit > 0 && r.MemoryLimit < MinMemoryLimit) {
+		return fmt.Errorf("memory_limit must be at least %d bytes", MinMemoryLimit)
+	}
+	if r.MemoryReservation < 0 {
+		return fmt.Errorf("memory_reservation cannot be negative")
+	}
+	if r.MemoryLimit > 0 && r.MemoryReservation > r.MemoryLimit {
+		return fmt.Errorf("memory_reservation cannot exceed memory_limit")
+	}
+	if r.PidsLimit < 0 {
+		return fmt.Errorf("pids_limit cannot be negative")
+	}
+	return nil
+}
+
+// RestartPolicy tells Docker when to restart a service's containers
+type RestartPolicy struct {
+	Name       string `json:"name"`                  // no|always|on-failure|unless-stopped
+	MaxRetries int    `json:"max_retries,omitempty"` // on-failure only, 0 retries forever
+}
+
+// Validate checks the policy name and retry count
+func (p RestartPolicy) Validate() error {
+	switch p.Name {
+	case "", RestartPolicyNo, RestartPolicyAlways, RestartPolicyUnlessStopped:
+		if p.MaxRetries != 0 {
+			return fmt.Errorf("max_retries is only allowed with the on-failure restart policy")
+		}
+	case RestartPolicyOnFailure:
+		if p.MaxRetries < 0 {
+			return fmt.Errorf("max_retries cannot be negative")
+		}
+	default:
+		return fmt.Errorf("restart policy must be one of: no, always, on-failure, unless-stopped")
+	}
+	return nil
 }
 
+// normalized returns the policy with an empty name treated as "no"
+func (p RestartPolicy) normalized() RestartPolicy {
+	if p.Name == "" {
+		p.Name = RestartPolicyNo
+	}
+	return p
+}
+
+// Route match types, how a route's path is matched against request URIs
+const (
+	RouteMatchPrefix = "prefix" // URIs starting with the path, the longest matching prefix wins
+	RouteMatchExact  = "exact"  // only the path itself
+	RouteMatchRegex  = "regex"  // URIs matching the path as a regular expression, checked in order
+)
+
+// Route kinds, what a route answers requests with
+const (
+	RouteKindProxy    = "proxy"    // passes requests to the route's service
+	RouteKindRedirect = "redirect" // redirects to another URL, without a service
+	RouteKindStatic   = "static"   // answers with a fixed status and body or file, without a service
+)
+
 // Route represents an external routing configuration
 type Route struct {
-	ID            int64      `json:"id"`
-	ServiceID     int64      `json:"service_id"`
-	Domain        string     `json:"domain"`
-	Port          int        `json:"port"`
-	TLS           bool       `json:"tls"`
-	Path          *string    `json:"path,omitempty"`
-	CertificateID *int64     `json:"certificate_id,omitempty"`
-	DomainID      *int64     `json:"domain_id,omitempty"`
-	ProxyConfig   *string    `json:"proxy_config,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+	ID            int64          `json:"id"`
+	ServiceID     int64          `json:"service_id"` // 0 for redirect and static routes
+	Kind          string         `json:"kind"`       // proxy|redirect|static
+	Domain        string         `json:"domain"`
+	Port          int            `json:"port"`
+	TLS           bool           `json:"tls"`
+	Path          *string        `json:"path,omitempty"`
+	MatchType     string         `json:"match_type"`   // prefix|exact|regex
+	StripPrefix   bool           `json:"strip_prefix"` // remove the path prefix before proxying
+	CertificateID *int64         `json:"certificate_id,omitempty"`
+	DomainID      *int64         `json:"domain_id,omitempty"`
+	ProxyConfig   *RoutePolicy   `json:"proxy_config,omitempty"`
+	Access        *RouteAccess   `json:"access,omitempty"`
+	Redirect      *RouteRedirect `json:"redirect,omitempty"`
+	Static        *RouteStatic   `json:"static,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     *time.Time     `json:"updated_at,omitempty"`
+}
+
+// Proxies reports whether the route passes requests to a service
+func (r Route) Proxies() bool {
+	return routeKind(r.Kind) == RouteKindProxy
+}
+
+// Location returns the path the route matches, "/" when it has none
+func (r Route) Location() string {
+	return routeLocation(r.Path)
+}
+
+// Name identifies a route by its domain and location, e.g. "example.com/api"
+func (r Route) Name() string {
+	return RouteName(r.Domain, r.Path, r.MatchType)
+}
+
+// RouteName names the location of a route. A domain has one route per name.
+func RouteName(domain string, path *string, matchType string) string {
+	location := routeLocation(path)
+	switch routeMatchType(matchType) {
+	case RouteMatchExact, RouteMatchRegex:
+		return domain + " " + matchType + " " + location
+	}
+	if location == "/" {
+		return domain
+	}
+	return domain + location
 }
 
 // RouteSpec represents the specification for creating a route
 type RouteSpec struct {
-	Domain        string  `json:"domain" binding:"required"`
-	Port          int     `json:"port" binding:"required,min=1,max=65535"`
-	TLS           bool    `json:"tls"`
-	Path          *string `json:"path,omitempty"`
-	CertificateID *int64  `json:"certificate_id,omitempty"`
-	DomainID      *int64  `json:"domain_id,omitempty"`
-	ProxyConfig   *string `json:"proxy_config,omitempty"`
+	Kind          string         `json:"kind,omitempty"` // proxy|redirect|static, default proxy
+	Domain        string         `json:"domain" binding:"required"`
+	Port          int            `json:"port" binding:"omitempty,min=1,max=65535"` // proxy routes only
+	TLS           bool           `json:"tls"`
+	Path          *string        `json:"path,omitempty"`
+	MatchType     string         `json:"match_type,omitempty"`   // prefix|exact|regex, default prefix
+	StripPrefix   bool           `json:"strip_prefix,omitempty"` // prefix routes only
+	CertificateID *int64         `json:"certificate_id,omitempty"`
+	DomainID      *int64         `json:"domain_id,omitempty"`
+	ProxyConfig   *RoutePolicy   `json:"proxy_config,omitempty"` // proxy routes only
+	Access        *RouteAccess   `json:"access,omitempty"`       // proxy routes only
+	Redirect      *RouteRedirect `json:"redirect,omitempty"`     // redirect routes only
+	Static        *RouteStatic   `json:"static,omitempty"`       // static routes only
+}
+
+// Proxies reports whether the route passes requests to a service
+func (spec RouteSpec) Proxies() bool {
+	return routeKind(spec.Kind) == RouteKindProxy
+}
+
+// routePathRegex matches prefix and exact paths nginx can take unquoted
+var routePathRegex = regexp.MustCompile(`^/[^\s"';{}\\]*$`)
+
+// routeLocation returns path, or "/" for routes without one
+func routeLocation(path *string) string {
+	if path == nil || *path == "" {
+		return "/"
+	}
+	return *path
+}
+
+// routeKind returns the kind with proxy as the default
+func routeKind(kind string) string {
+	if kind == "" {
+		return RouteKindProxy
+	}
+	return kind
+}
+
+// routeMatchType returns the match type with prefix as the default
+func routeMatchType(matchType string) string {
+	if matchType == "" {
+		return RouteMatchPrefix
+	}
+	return matchType
+}
+
+// ProjectChangeSet is a set of changes to a project and its services, routes and links
+// that ApplyProjectChangeSet writes in a single transaction. Routes and links refer to
+// services by name, so they may target services created by the same change set.
+type ProjectChangeSet struct {
+	Project        *Project         `json:"project,omitempty"` // new name, repo, branch and image target; nil leaves them unchanged
+	CreateServices []ServiceSpec    `json:"create_services"`
+	UpdateServices []Service        `json:"update_services"` // matched by ID, every configuration column is written
+	DeleteServices []int64          `json:"delete_services"`
+	CreateRoutes   []ServiceRoute   `json:"create_routes"`
+	UpdateRoutes   []ServiceRoute   `json:"update_routes"` // matched by ID
+	DeleteRoutes   []int64          `json:"delete_routes"`
+	Links          []ServiceLinkSet `json:"links"`
+}
+
+// ServiceRoute is a route of a change set, attached to its service by name
+type ServiceRoute struct {
+	ID      int64     `json:"id,omitempty"`
+	Service string    `json:"service"`
+	Spec    RouteSpec `json:"spec"`
+}
+
+// ServiceLinkSet replaces all links of a service
+type ServiceLinkSet struct {
+	Service   string   `json:"service"`
+	Targets   []string `json:"targets"`    // services of the same project, by name
+	TargetIDs []int64  `json:"target_ids"` // services of other projects, by ID
 }
 
 // RouteWithService combines route and service information for nginx config generation
 type RouteWithService struct {
 	Route
-	ServiceName string `json:"service_name"`
-	ProjectName string `json:"project_name"`
+	ServiceName   string `json:"service_name"`
+	ProjectName   string `json:"project_name"`
+	UpstreamHost  string `json:"upstream_host,omitempty"` // overrides ServiceName as the upstream server when set
+	Replicas      int    `json:"replicas"`
+	LoadBalancing string `json:"load_balancing"`
+
+	// MaintenancePage is set while a maintenance window serves a page for the
+	// route's project; empty means the default page
+	MaintenancePage *string `json:"maintenance_page,omitempty"`
 }
 
 // Certificate represents an SSL/TLS certificate
@@ -511,6 +903,210 @@ type Deployment struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// BackupSchedule controls periodic snapshots of all volumes of a service
+type BackupSchedule struct {
+	ServiceID       int64      `json:"service_id"`
+	Enabled         bool       `json:"enabled"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	Retention       int        `json:"retention"` // snapshots kept per volume
+	Quiesce         string     `json:"quiesce"`   // none|pause|stop
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Validate checks the interval, retention and quiesce mode
+func (b BackupSchedule) Validate() error {
+	if b.IntervalMinutes < MinBackupInterval {
+		return fmt.Errorf("interval_minutes must be at least %d", MinBackupInterval)
+	}
+	if b.Retention < 1 || b.Retention > MaxBackupRetention {
+		return fmt.Errorf("retention must be between 1 and %d", MaxBackupRetention)
+	}
+	if !IsValidQuiesceMode(b.Quiesce) {
+		return fmt.Errorf("quiesce must be one of: none, pause, stop")
+	}
+	return nil
+}
+
+// Due reports whether the schedule should take a snapshot at now
+func (b BackupSchedule) Due(now time.Time) bool {
+	if !b.Enabled {
+		return false
+	}
+	if b.LastRunAt == nil {
+		return true
+	}
+	return !now.Before(b.LastRunAt.Add(time.Duration(b.IntervalMinutes) * time.Minute))
+}
+
+// VolumeSnapshot is a gzip-compressed tar archive of one service volume
+type VolumeSnapshot struct {
+	ID        int64     `json:"id"`
+	ServiceID int64     `json:"service_id"`
+	Volume    string    `json:"volume"`    // VolumeMap.Name() of the archived mapping
+	FileName  string    `json:"file_name"` // path relative to the snapshot directory
+	Size      int64     `json:"size"`      // archive size in bytes
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskConfig schedules the runs of a scheduled task service
+type TaskConfig struct {
+	ServiceID       int64      `json:"service_id"`
+	Schedule        string     `json:"schedule"`          // five-field cron expression, evaluated in UTC
+	Command         []string   `json:"command,omitempty"` // overrides the image's command when set
+	TimeoutSeconds  int        `json:"timeout_seconds"`   // runs still going after this long are stopped and failed
+	Suspended       bool       `json:"suspended"`         // skip scheduled runs, manual runs still work
+	LastScheduledAt *time.Time `json:"last_scheduled_at,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"` // populated when requested
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Validate checks the cron expression and timeout
+func (t TaskConfig) Validate() error {
+	schedule, err := cron.Parse(t.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if schedule.Next(time.Now().UTC()).IsZero() {
+		return fmt.Errorf("invalid schedule: %q never runs", t.Schedule)
+	}
+	if t.TimeoutSeconds < 1 || t.TimeoutSeconds > MaxTaskTimeout {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", MaxTaskTimeout)
+	}
+	return nil
+}
+
+// normalized fills in the default timeout
+func (t TaskConfig) normalized() TaskConfig {
+	if t.TimeoutSeconds == 0 {
+		t.TimeoutSeconds = DefaultTaskTimeout
+	}
+	return t
+}
+
+// NextRun returns when the schedule next fires after its last scheduled run, or
+// after it was last changed if it has not run since. The zero time is returned
+// for invalid schedules.
+func (t TaskConfig) NextRun() time.Time {
+	schedule, err := cron.Parse(t.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	from := t.UpdatedAt
+	if t.LastScheduledAt != nil && t.LastScheduledAt.After(from) {
+		from = *t.LastScheduledAt
+	}
+	return schedule.Next(from.UTC())
+}
+
+// Due reports whether the task should be run at now
+func (t TaskConfig) Due(now time.Time) bool {
+	if t.Suspended {
+		return false
+	}
+	next := t.NextRun()
+	return !next.IsZero() && !now.Before(next)
+}
+
+// TaskRun is one execution of a scheduled task
+type TaskRun struct {
+	ID         int64      `json:"id"`
+	ServiceID  int64      `json:"service_id"`
+	Trigger    string     `json:"trigger"` // schedule|manual
+	Status     string     `json:"status"`  // queued|running|succeeded|failed
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+	Logs       string     `json:"logs,omitempty"` // tail of the container output, omitted from listings
+	DurationMs int64      `json:"duration_ms"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the run has completed, successfully or not
+func (r TaskRun) Finished() bool {
+	return r.Status == TaskRunSucceeded || r.Status == TaskRunFailed
+}
+
+// ServiceRun is a one-off command run in a new container with a service's image,
+// env, volumes and project network, next to the service's own container
+type ServiceRun struct {
+	ID         int64      `json:"id"`
+	ServiceID  int64      `json:"service_id"`
+	Command    []string   `json:"command"`
+	Actor      string     `json:"actor"`
+	Status     string     `json:"status"` // running|succeeded|failed
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+	Output     string     `json:"output,omitempty"` // tail of the container output, omitted from listings
+	DurationMs int64      `json:"duration_ms"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// MaintenanceWindow freezes deploys and configuration changes of a project, or
+// of every project when ProjectID is nil, from StartsAt until EndsAt. Reads and
+// start, stop and restart keep working. With ServePage, nginx answers the
+// affected routes with a 503 and PageHTML, or a default page when it is empty.
+type MaintenanceWindow struct {
+	ID        int64      `json:"id"`
+	ProjectID *int64     `json:"project_id,omitempty"`
+	Reason    string     `json:"reason"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"` // open-ended until ended
+	ServePage bool       `json:"serve_page"`
+	PageHTML  string     `json:"page_html,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ActiveAt reports whether the window is in effect at t
+func (w MaintenanceWindow) ActiveAt(t time.Time) bool {
+	return !t.Before(w.StartsAt) && (w.EndsAt == nil || t.Before(*w.EndsAt))
+}
+
+// Validate checks the window's schedule and page
+func (w MaintenanceWindow) Validate() error {
+	if w.StartsAt.IsZero() {
+		return fmt.Errorf("starts_at is required")
+	}
+	if w.EndsAt != nil && !w.EndsAt.After(w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if len(w.Reason) > MaxMaintenanceReason {
+		return fmt.Errorf("reason must be at most %d characters", MaxMaintenanceReason)
+	}
+	if len(w.PageHTML) > MaxMaintenancePage {
+		return fmt.Errorf("page_html must be at most %d bytes", MaxMaintenancePage)
+	}
+	if w.PageHTML != "" && !w.ServePage {
+		return fmt.Errorf("page_html requires serve_page")
+	}
+	return nil
+}
+
+// JobRecord represents a persisted background job
+type JobRecord struct {
+	ID             string     `json:"id"`
+	Type           string     `json:"type"`
+	Status         string     `json:"status"` // queued, running, success, failed, cancelled
+	Data           string     `json:"data"`   // JSON encoded job payload
+	Error          *string    `json:"error"`
+	Progress       int        `json:"progress"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"max_attempts"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	Deadline       *time.Time `json:"deadline"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+}
+
+// JobRetention is how many finished jobs are kept, older ones are pruned
+const JobRetention = 1000
+
 // WebhookDelivery represents a webhook delivery attempt
 type WebhookDelivery struct {
 	ID         string     `json:"id" db:"id"`
@@ -577,11 +1173,156 @@ const (
 	ServiceStateStopped = "stopped"
 )
 
+// Deploy strategy constants
+const (
+	DeployStrategyRecreate  = "recreate"   // stop the old container, then start the new one
+	DeployStrategyBlueGreen = "blue_green" // start the new container alongside and switch the nginx upstream
+)
+
+// IsValidDeployStrategy reports whether strategy is a known deploy strategy
+func IsValidDeployStrategy(strategy string) bool {
+	return strategy == DeployStrategyRecreate || strategy == DeployStrategyBlueGreen
+}
+
+// HasFixedHostPorts reports whether any port is published on a fixed host port.
+// Blue/green deploys cannot keep those, the old container holds them while the
+// new one starts.
+func HasFixedHostPorts(ports []PortMap) bool {
+	for _, port := range ports {
+		if port.Host != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDeployStrategyPorts returns ErrBlueGreenHostPorts when a blue/green
+// service would publish fixed host ports
+func ValidateDeployStrategyPorts(strategy string, ports []PortMap) error {
+	if strategy == DeployStrategyBlueGreen && HasFixedHostPorts(ports) {
+		return ErrBlueGreenHostPorts
+	}
+	return nil
+}
+
+// Automatic rollback limits
+const (
+	DefaultRollbackWindow = 300  // seconds a deployment is watched by default
+	MaxRollbackWindow     = 3600 // longest allowed watch window in seconds
+)
+
+// Load balancing methods for services with more than one replica
+const (
+	LoadBalancingRoundRobin = "round_robin" // nginx default, requests rotate across replicas
+	LoadBalancingLeastConn  = "least_conn"  // send to the replica with the fewest active connections
+	LoadBalancingIPHash     = "ip_hash"     // pin each client IP to one replica
+)
+
+// MaxReplicas is the largest number of containers a single service may run
+const MaxReplicas = 20
+
+// Quiesce modes applied to a service's containers while its volumes are archived
+const (
+	QuiesceNone  = "none"  // archive while the service keeps running
+	QuiescePause = "pause" // freeze the containers for the duration of the archive
+	QuiesceStop  = "stop"  // stop the containers and start them again afterwards
+)
+
+// IsValidQuiesceMode reports whether mode is a known quiesce mode
+func IsValidQuiesceMode(mode string) bool {
+	return mode == QuiesceNone || mode == QuiescePause || mode == QuiesceStop
+}
+
+// Volume backup limits
+const (
+	MinBackupInterval      = 5   // shortest schedule interval in minutes
+	DefaultBackupRetention = 7   // snapshots kept per volume when no schedule says otherwise
+	MaxBackupRetention     = 100 // most snapshots a schedule may keep per volume
+)
+
+// Service kinds
+const (
+	ServiceKindService = "service" // long-running containers, the default
+	ServiceKindTask    = "task"    // a short-lived container started on a cron schedule
+)
+
+// IsValidServiceKind reports whether kind is a known service kind
+func IsValidServiceKind(kind string) bool {
+	return kind == ServiceKindService || kind == ServiceKindTask
+}
+
+// Task run triggers
+const (
+	TaskTriggerSchedule = "schedule"
+	TaskTriggerManual   = "manual"
+)
+
+// Task run statuses
+const (
+	TaskRunQueued    = "queued"
+	TaskRunRunning   = "running"
+	TaskRunSucceeded = "succeeded"
+	TaskRunFailed    = "failed"
+)
+
+// Scheduled task limits
+const (
+	DefaultTaskTimeout = 3600      // seconds a run may take when the task does not say otherwise
+	MaxTaskTimeout     = 86400     // longest allowed run in seconds
+	MaxTaskRunLogs     = 64 * 1024 // bytes of output kept per run, the tail is kept
+	TaskRunRetention   = 50        // runs kept per task, older runs are pruned
+)
+
+// ServiceRunRetention is how many one-off runs are kept per service, older runs are pruned
+const ServiceRunRetention = 50
+
+// Init container and sidecar limits
+const (
+	MaxInitContainers    = 5
+	MaxSidecars          = 5
+	InitContainerTimeout = 600 // seconds an init container may run before the start fails
+)
+
+// Roles of service containers in the service_containers table
+const (
+	ServiceContainerInit    = "init"
+	ServiceContainerSidecar = "sidecar"
+)
+
+// Maintenance window limits
+const (
+	MaxMaintenanceReason = 500
+	MaxMaintenancePage   = 64 * 1024 // bytes of custom maintenance page HTML
+)
+
+// IsValidLoadBalancing reports whether method is a known load balancing method
+func IsValidLoadBalancing(method string) bool {
+	return method == LoadBalancingRoundRobin || method == LoadBalancingLeastConn || method == LoadBalancingIPHash
+}
+
+// Restart policies understood by Docker
+const (
+	RestartPolicyNo            = "no"
+	RestartPolicyAlways        = "always"
+	RestartPolicyOnFailure     = "on-failure"
+	RestartPolicyUnlessStopped = "unless-stopped"
+)
+
+// Resource limit bounds enforced by Docker
+const (
+	MinCPUShares   = 2
+	MaxCPUShares   = 262144
+	MinCPUs        = 0.01
+	MinMemoryLimit = 6 * 1024 * 1024 // Docker refuses hard limits below 6MiB
+)
+
 // Health status constants
 const (
 	HealthStatusOK      = "ok"
 	HealthStatusFail    = "fail"
 	HealthStatusUnknown = "unknown"
+	// HealthStatusUnreachable means glinrdockd could not reach the service to probe it
+	HealthStatusUnreachable = "unreachable"
 )
 
 // Health system constants
@@ -592,6 +1333,181 @@ const (
 	DefaultHealthPort  = 8080 // default port for health checks
 )
 
+// Health check limits
+const (
+	DefaultHealthCheckInterval = 30   // seconds between probes of services without a health check
+	MinHealthCheckInterval     = 5    // shortest allowed interval in seconds
+	MaxHealthCheckInterval     = 3600 // longest allowed interval in seconds
+	MaxHealthCheckTimeout      = 60   // longest allowed probe timeout in seconds
+	MaxHealthCheckThreshold    = 10   // most consecutive results a threshold may require
+	MaxHealthCheckStartPeriod  = 3600 // longest allowed start period in seconds
+	DefaultUnhealthyThreshold  = 3    // consecutive failures before a configured service is unhealthy
+	DefaultExpectedStatus      = "200-399"
+	MaxHealthCheckOutput       = 4096 // bytes of probe output kept per history entry
+	HealthHistoryRetention     = 100  // probes kept per service, older ones are pruned
+)
+
+// HealthCheck configures how a service's health is checked, replacing the
+// check picked from its image. Probes reach the service over its project network.
+type HealthCheck struct {
+	ServiceID          int64           `json:"service_id"`
+	Type               HealthCheckType `json:"type"`                      // http|tcp|exec|grpc
+	Path               string          `json:"path,omitempty"`            // http request path, defaults to the service's health path
+	Port               int             `json:"port,omitempty"`            // container port, defaults to the first published one
+	Command            []string        `json:"command,omitempty"`         // exec command run in the container, healthy when it exits 0
+	GRPCService        string          `json:"grpc_service,omitempty"`    // service name sent in the gRPC health check, empty for the whole server
+	ExpectedStatus     string          `json:"expected_status,omitempty"` // http status codes and ranges, e.g. "200-299,301"
+	BodyMatch          string          `json:"body_match,omitempty"`      // regular expression the http response body must match
+	IntervalSeconds    int             `json:"interval_seconds"`
+	TimeoutSeconds     int             `json:"timeout_seconds"`
+	HealthyThreshold   int             `json:"healthy_threshold"`    // consecutive successes before the service is healthy
+	UnhealthyThreshold int             `json:"unhealthy_threshold"`  // consecutive failures before the service is unhealthy
+	StartPeriodSeconds int             `json:"start_period_seconds"` // failures this soon after the container starts are not counted
+	UpdatedAt          time.Time       `json:"updated_at"`
+}
+
+// Validate checks the check type, its settings and its timings, with defaults
+// filled in for those left out
+func (h HealthCheck) Validate() error {
+	h = h.normalized()
+	switch h.Type {
+	case HealthCheckHTTP, HealthCheckTCP, HealthCheckExec, HealthCheckGRPC:
+	default:
+		return fmt.Errorf("type must be one of http, tcp, exec, grpc")
+	}
+	if h.Type != HealthCheckHTTP && (h.Path != "" || h.ExpectedStatus != "" || h.BodyMatch != "") {
+		return fmt.Errorf("path, expected_status and body_match only apply to http health checks")
+	}
+	if h.Type != HealthCheckExec && len(h.Command) > 0 {
+		return fmt.Errorf("command only applies to exec health checks")
+	}
+	if h.Type != HealthCheckGRPC && h.GRPCService != "" {
+		return fmt.Errorf("grpc_service only applies to grpc health checks")
+	}
+	if h.Type == HealthCheckExec && len(h.Command) == 0 {
+		return fmt.Errorf("command is required for exec health checks")
+	}
+	if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, or 0 for the first published port")
+	}
+	if _, err := parseStatusRanges(h.ExpectedStatus); err != nil {
+		return err
+	}
+	if h.BodyMatch != "" {
+		if _, err := regexp.Compile(h.BodyMatch); err != nil {
+			return fmt.Errorf("invalid body_match: %w", err)
+		}
+	}
+	if h.IntervalSeconds < MinHealthCheckInterval || h.IntervalSeconds > MaxHealthCheckInterval {
+		return fmt.Errorf("interval_seconds must be between %d and %d", MinHealthCheckInterval, MaxHealthCheckInterval)
+	}
+	if h.TimeoutSeconds < 1 || h.TimeoutSeconds > MaxHealthCheckTimeout {
+		return fmt.Errorf("timeout_seconds must be between 1 and %d", MaxHealthCheckTimeout)
+	}
+	if h.TimeoutSeconds > h.IntervalSeconds {
+		return fmt.Errorf("timeout_seconds cannot exceed interval_seconds")
+	}
+	if h.HealthyThreshold < 1 || h.HealthyThreshold > MaxHealthCheckThreshold ||
+		h.UnhealthyThreshold < 1 || h.UnhealthyThreshold > MaxHealthCheckThreshold {
+		return fmt.Errorf("healthy_threshold and unhealthy_threshold must be between 1 and %d", MaxHealthCheckThreshold)
+	}
+	if h.StartPeriodSeconds < 0 || h.StartPeriodSeconds > MaxHealthCheckStartPeriod {
+		return fmt.Errorf("start_period_seconds must be between 0 and %d", MaxHealthCheckStartPeriod)
+	}
+	return nil
+}
+
+// normalized fills in the default timings, thresholds and expected status
+func (h HealthCheck) normalized() HealthCheck {
+	if h.IntervalSeconds == 0 {
+		h.IntervalSeconds = DefaultHealthCheckInterval
+	}
+	if h.TimeoutSeconds == 0 {
+		h.TimeoutSeconds = HealthProbeTimeout
+	}
+	if h.HealthyThreshold == 0 {
+		h.HealthyThreshold = 1
+	}
+	if h.UnhealthyThreshold == 0 {
+		h.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if h.Type == HealthCheckHTTP && h.ExpectedStatus == "" {
+		h.ExpectedStatus = DefaultExpectedStatus
+	}
+	return h
+}
+
+// Interval returns the time between probes
+func (h HealthCheck) Interval() time.Duration {
+	return time.Duration(h.IntervalSeconds) * time.Second
+}
+
+// Timeout returns how long a single probe may take
+func (h HealthCheck) Timeout() time.Duration {
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+// StartPeriod returns how long failures are ignored after the container starts
+func (h HealthCheck) StartPeriod() time.Duration {
+	return time.Duration(h.StartPeriodSeconds) * time.Second
+}
+
+// ExpectsStatus reports whether an http status code counts as healthy
+func (h HealthCheck) ExpectsStatus(code int) bool {
+	expected := h.ExpectedStatus
+	if expected == "" {
+		expected = DefaultExpectedStatus
+	}
+	ranges, err := parseStatusRanges(expected)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatusRanges parses comma separated status codes and ranges like "200-299,301"
+func parseStatusRanges(spec string) ([][2]int, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			high = low
+		}
+		from, errFrom := strconv.Atoi(strings.TrimSpace(low))
+		to, errTo := strconv.Atoi(strings.TrimSpace(high))
+		if errFrom != nil || errTo != nil || from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid expected_status %q: use status codes and ranges like 200-299,301", spec)
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+// HealthCheckRecord is one probe in a service's health history
+type HealthCheckRecord struct {
+	ID            int64     `json:"id"`
+	ServiceID     int64     `json:"service_id"`
+	CheckType     string    `json:"check_type"`
+	Result        string    `json:"result"`           // ok|fail|unknown outcome of this probe
+	Status        string    `json:"status"`           // health status of the service after this probe
+	Output        string    `json:"output,omitempty"` // probe error or exec output
+	DurationMs    int64     `json:"duration_ms"`
+	InStartPeriod bool      `json:"in_start_period"` // a failure not counted because the container just started
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
 // slugRegex matches characters that should be replaced in slugs
 var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
 
@@ -636,6 +1552,12 @@ func GenerateServiceAliases(projectName, serviceName string) []string {
 	return []string{shortAlias, longAlias}
 }
 
+// ReplicaAlias returns the network alias of one replica of a service.
+// Replica 1 is the service's primary container and is reached by its service alias.
+func ReplicaAlias(serviceID int64, index int) string {
+	return fmt.Sprintf("svc-%d-r%d", serviceID, index)
+}
+
 // GenerateNetworkHints creates DNS and curl hints for service networking
 func GenerateNetworkHints(alias string, ports []PortMap) (string, string) {
 	if len(ports) == 0 {
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeMap_ValidateHostPath(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"/", false},
+		{"/var/run/docker.sock", false},
+		{"/run/docker.sock", false},
+		{"/var/run", false},
+		{"/var", false},
+		{"/root", false},
+		{"/root/.ssh", false},
+		{"/etc", false},
+		{"/etc/", false},
+		{"/tmp/../etc", false},
+		{"/srv/../../proc/1", false},
+		{"/dev/sda", false},
+		{"/etcd-data", true},
+		{"/devices-cache", true},
+		{"/srv/api/config", true},
+		{"/home/app/data/", true},
+		{"shop-uploads", true},
+		{"../etc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := VolumeMap{Host: tt.host, Container: "/data"}.Validate()
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVolumeMap_ValidateContainerPath(t *testing.T) {
+	tests := []struct {
+		container string
+		allowed   bool
+	}{
+		{"/data", true},
+		{"/var/lib/postgresql/data", true},
+		{"/data..backup", true},
+		{"/", false},
+		{"data", false},
+		{"/..", false},
+		{"/a/../..", false},
+		{"/data/..", false},
+		{"/./data", false},
+		{"/data/", false},
+		{"//data", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.container, func(t *testing.T) {
+			for _, host := range []string{"/srv/app", "app-data"} {
+				err := VolumeMap{Host: host, Container: tt.container}.Validate()
+				if tt.allowed {
+					assert.NoError(t, err)
+				} else {
+					assert.Error(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVolumeMap_ValidateBindMountRoots(t *testing.T) {
+	SetBindMountRoots([]string{"/srv/", " /data/volumes"})
+	defer SetBindMountRoots(nil)
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"/srv", true},
+		{"/srv/api", true},
+		{"/data/volumes/db", true},
+		{"/srvx", false},
+		{"/srv/../home", false},
+		{"/data", false},
+		{"/home/app", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := VolumeMap{Host: tt.host, Container: "/data"}.Validate()
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestVolumeMap_ValidateFollowsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "host-etc")
+	if err := os.Symlink("/etc", link); err != nil {
+		t.Skipf("cannot create symlink: %v", err)
+	}
+
+	assert.Error(t, VolumeMap{Host: link, Container: "/data"}.Validate())
+	assert.NoError(t, VolumeMap{Host: dir, Container: "/data"}.Validate())
+}
+
+func TestHasFixedHostPorts(t *testing.T) {
+	assert.False(t, HasFixedHostPorts(nil))
+	assert.False(t, HasFixedHostPorts([]PortMap{{Container: 80}, {Container: 443}}))
+	assert.True(t, HasFixedHostPorts([]PortMap{{Container: 80}, {Container: 443, Host: 8443}}))
+}
+
+func TestValidateDeployStrategyPorts(t *testing.T) {
+	fixed := []PortMap{{Container: 80, Host: 8080}}
+	assert.NoError(t, ValidateDeployStrategyPorts(DeployStrategyRecreate, fixed))
+	assert.NoError(t, ValidateDeployStrategyPorts(DeployStrategyBlueGreen, []PortMap{{Container: 80}}))
+	assert.ErrorIs(t, ValidateDeployStrategyPorts(DeployStrategyBlueGreen, fixed), ErrBlueGreenHostPorts)
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Route access limits
+const (
+	MaxRouteAccessRules    = 64  // most allow or deny entries per route
+	MaxRouteCredentials    = 100 // most basic auth users per route
+	MaxRouteUsernameLength = 64
+	MinRoutePasswordLength = 8
+	MaxRoutePasswordLength = 72 // bcrypt ignores anything longer
+	DefaultBasicAuthRealm  = "Restricted"
+	RouteAccessSatisfyAll  = "all" // every configured check must pass
+	RouteAccessSatisfyAny  = "any" // one passing check is enough
+	maxRouteBasicAuthRealm = 128
+)
+
+var routeUsernameRegex = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// RouteAccess restricts who reaches a route. Checks left out are not made;
+// with several checks all of them must pass unless Satisfy is "any".
+type RouteAccess struct {
+	Allow       []string           `json:"allow,omitempty"` // IPs or CIDRs let in, everyone else is denied
+	Deny        []string           `json:"deny,omitempty"`  // IPs or CIDRs turned away, checked before allow
+	BasicAuth   *BasicAuthPolicy   `json:"basic_auth,omitempty"`
+	ForwardAuth *ForwardAuthPolicy `json:"forward_auth,omitempty"`
+	Satisfy     string             `json:"satisfy,omitempty"` // all|any, default all
+}
+
+// BasicAuthPolicy asks for the username and password of one of the route's
+// credentials
+type BasicAuthPolicy struct {
+	Realm string `json:"realm,omitempty"` // shown by browsers, default "Restricted"
+}
+
+// ForwardAuthPolicy lets requests through when they carry a glinrdock session
+// or API token with at least the given role
+type ForwardAuthPolicy struct {
+	Role string `json:"role,omitempty"` // viewer|deployer|admin, default viewer
+}
+
+// RouteCredential is a basic auth user of a route. Only the bcrypt hash of
+// the password is kept.
+type RouteCredential struct {
+	ID           int64     `json:"id"`
+	RouteID      int64     `json:"route_id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Validate checks the address rules, basic auth realm, forward auth role and satisfy mode
+func (a RouteAccess) Validate() error {
+	if err := validateAccessRules("allow", a.Allow); err != nil {
+		return err
+	}
+	if err := validateAccessRules("deny", a.Deny); err != nil {
+		return err
+	}
+	if a.BasicAuth != nil {
+		realm := a.BasicAuth.Realm
+		if len(realm) > maxRouteBasicAuthRealm {
+			return fmt.Errorf("basic_auth realm cannot be longer than %d characters", maxRouteBasicAuthRealm)
+		}
+		if strings.IndexFunc(realm, unicode.IsControl) >= 0 {
+			return fmt.Errorf("basic_auth realm cannot contain control characters")
+		}
+	}
+	if a.ForwardAuth != nil {
+		if role := a.ForwardAuth.Role; role != "" && !IsRoleValid(role) {
+			return fmt.Errorf("forward_auth role must be one of admin, deployer, viewer")
+		}
+	}
+	switch a.Satisfy {
+	case "", RouteAccessSatisfyAll, RouteAccessSatisfyAny:
+	default:
+		return fmt.Errorf("satisfy must be all or any")
+	}
+	return nil
+}
+
+// Restricted reports whether the policy makes any check
+func (a RouteAccess) Restricted() bool {
+	return len(a.Allow) > 0 || len(a.Deny) > 0 || a.BasicAuth != nil || a.ForwardAuth != nil
+}
+
+// RealmOrDefault returns the basic auth realm with its default
+func (p BasicAuthPolicy) RealmOrDefault() string {
+	if p.Realm == "" {
+		return DefaultBasicAuthRealm
+	}
+	return p.Realm
+}
+
+// RoleOrDefault returns the role forward auth requires, viewer by default
+func (p ForwardAuthPolicy) RoleOrDefault() string {
+	if p.Role == "" {
+		return RoleViewer
+	}
+	return p.Role
+}
+
+// validateAccessRules checks that every entry is an IP address or a CIDR
+func validateAccessRules(field string, rules []string) error {
+	if len(rules) > MaxRouteAccessRules {
+		return fmt.Errorf("%s cannot have more than %d entries", field, MaxRouteAccessRules)
+	}
+	for _, rule := range rules {
+		if net.ParseIP(rule) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(rule); err != nil {
+			return fmt.Errorf("%s: %q is not an IP address or CIDR", field, rule)
+		}
+	}
+	return nil
+}
+
+// ValidateRouteCredential checks a basic auth username and password
+func ValidateRouteCredential(username, password string) error {
+	if username == "" || len(username) > MaxRouteUsernameLength || !routeUsernameRegex.MatchString(username) {
+		return fmt.Errorf("username must be 1-%d letters, digits, '.', '_', '@' or '-'", MaxRouteUsernameLength)
+	}
+	if len(password) < MinRoutePasswordLength || len(password) > MaxRoutePasswordLength {
+		return fmt.Errorf("password must be %d-%d characters", MinRoutePasswordLength, MaxRoutePasswordLength)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer for RouteAccess to store as JSON
+func (a RouteAccess) Value() (driver.Value, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner for RouteAccess to read from JSON
+func (a *RouteAccess) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("cannot scan %T into RouteAccess", value)
+	}
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// routeCredentialColumns are the columns scanned by scanRouteCredential
+const routeCredentialColumns = "id, route_id, username, password_hash, created_at, updated_at"
+
+// ListRouteCredentials returns the basic auth users of a route by username
+func (s *Store) ListRouteCredentials(ctx context.Context, routeID int64) ([]RouteCredential, error) {
+	return s.queryRouteCredentials(ctx,
+		"SELECT "+routeCredentialColumns+" FROM route_credentials WHERE route_id = ? ORDER BY username", routeID)
+}
+
+// ListAllRouteCredentials returns the basic auth users of every route, keyed by route ID
+func (s *Store) ListAllRouteCredentials(ctx context.Context) (map[int64][]RouteCredential, error) {
+	credentials, err := s.queryRouteCredentials(ctx,
+		"SELECT "+routeCredentialColumns+" FROM route_credentials ORDER BY route_id, username")
+	if err != nil {
+		return nil, err
+	}
+
+	byRoute := make(map[int64][]RouteCredential)
+	for _, credential := range credentials {
+		byRoute[credential.RouteID] = append(byRoute[credential.RouteID], credential)
+	}
+	return byRoute, nil
+}
+
+// SetRouteCredential creates a basic auth user of a route, or replaces the
+// password of an existing one. The password is stored as a bcrypt hash.
+func (s *Store) SetRouteCredential(ctx context.Context, routeID int64, username, password string) (RouteCredential, error) {
+	if err := ValidateRouteCredential(username, password); err != nil {
+		return RouteCredential{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var count int
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(MAX(username = ?), 0) FROM route_credentials WHERE route_id = ?", username, routeID).Scan(&count, &exists); err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to count route credentials: %w", err)
+	}
+	if !exists && count >= MaxRouteCredentials {
+		return RouteCredential{}, fmt.Errorf("a route cannot have more than %d credentials", MaxRouteCredentials)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO route_credentials (route_id, username, password_hash, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(route_id, username) DO UPDATE SET
+			password_hash = excluded.password_hash,
+			updated_at = excluded.updated_at
+	`, routeID, username, string(hash), now, now); err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to set route credential: %w", err)
+	}
+
+	// nginx picks up htpasswd files when routes change
+	if err := touchRoute(ctx, tx, routeID); err != nil {
+		return RouteCredential{}, err
+	}
+
+	credential, err := scanRouteCredential(tx.QueryRowContext(ctx,
+		"SELECT "+routeCredentialColumns+" FROM route_credentials WHERE route_id = ? AND username = ?", routeID, username))
+	if err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to get route credential: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RouteCredential{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return credential, nil
+}
+
+// DeleteRouteCredential removes a basic auth user of a route
+func (s *Store) DeleteRouteCredential(ctx context.Context, routeID int64, username string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM route_credentials WHERE route_id = ? AND username = ?", routeID, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete route credential: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if err := touchRoute(ctx, tx, routeID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// touchRoute marks a route as updated so the nginx configuration is reconciled
+func touchRoute(ctx context.Context, db dbExecutor, routeID int64) error {
+	if err := execOne(ctx, db, "UPDATE routes SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", routeID); err != nil {
+		return fmt.Errorf("failed to update route %d: %w", routeID, err)
+	}
+	return nil
+}
+
+// queryRouteCredentials runs a query selecting routeCredentialColumns
+func (s *Store) queryRouteCredentials(ctx context.Context, query string, args ...interface{}) ([]RouteCredential, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list route credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var credentials []RouteCredential
+	for rows.Next() {
+		credential, err := scanRouteCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan route credential: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate route credentials: %w", err)
+	}
+
+	return credentials, nil
+}
+
+// scanRouteCredential scans a single route_credentials row
+func scanRouteCredential(row rowScanner) (RouteCredential, error) {
+	var credential RouteCredential
+	err := row.Scan(&credential.ID, &credential.RouteID, &credential.Username, &credential.PasswordHash,
+		&credential.CreatedAt, &credential.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return RouteCredential{}, ErrNotFound
+	}
+	return credential, err
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Route response limits
+const (
+	DefaultRedirectStatus  = 301
+	DefaultStaticStatus    = 200
+	DefaultStaticType      = "text/plain; charset=utf-8"
+	MaxRouteRedirectURL    = 2048
+	MaxRouteStaticBody     = 64 * 1024
+	maxRouteStaticFilePath = 255
+)
+
+var (
+	// Files under the static directory, no segment may start with a dot
+	routeStaticFileRegex  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*(/[A-Za-z0-9_][A-Za-z0-9._-]*)*$`)
+	routeContentTypeRegex = regexp.MustCompile(`^[A-Za-z0-9.+-]+/[A-Za-z0-9.+-]+(; ?charset=[A-Za-z0-9_-]+)?$`)
+)
+
+// RouteRedirect sends requests of a redirect route to another URL
+type RouteRedirect struct {
+	URL        string `json:"url"`                   // absolute http or https URL
+	StatusCode int    `json:"status_code,omitempty"` // 301|302|307|308, default 301
+	KeepPath   bool   `json:"keep_path,omitempty"`   // append the request URI, query included, to URL
+}
+
+// RouteStatic answers requests of a static route with a fixed status and
+// either a body or a file
+type RouteStatic struct {
+	StatusCode  int    `json:"status_code,omitempty"` // default 200
+	Body        string `json:"body,omitempty"`
+	ContentType string `json:"content_type,omitempty"` // of body, default text/plain
+	File        string `json:"file,omitempty"`         // path under the nginx static directory, instead of body
+}
+
+// Validate checks the target URL and status code
+func (r RouteRedirect) Validate() error {
+	if len(r.URL) > MaxRouteRedirectURL {
+		return fmt.Errorf("url cannot be longer than %d characters", MaxRouteRedirectURL)
+	}
+	// nginx reads $ as a variable
+	if strings.ContainsAny(r.URL, " \t\r\n\"'\\$") {
+		return fmt.Errorf("url cannot contain whitespace, quotes, backslashes or $")
+	}
+	target, err := url.Parse(r.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if r.KeepPath && (target.RawQuery != "" || target.Fragment != "" || strings.HasSuffix(r.URL, "?")) {
+		return fmt.Errorf("url cannot have a query or fragment with keep_path")
+	}
+	switch r.StatusCode {
+	case 0, 301, 302, 307, 308:
+	default:
+		return fmt.Errorf("status_code must be one of 301, 302, 307, 308")
+	}
+	return nil
+}
+
+// StatusOrDefault returns the redirect status code with its default
+func (r RouteRedirect) StatusOrDefault() int {
+	if r.StatusCode == 0 {
+		return DefaultRedirectStatus
+	}
+	return r.StatusCode
+}
+
+// Validate checks the status code, body and file
+func (s RouteStatic) Validate() error {
+	if s.StatusCode != 0 && (s.StatusCode < 200 || s.StatusCode > 299) && (s.StatusCode < 400 || s.StatusCode > 599) {
+		return fmt.Errorf("status_code must be between 200 and 299 or 400 and 599, use a redirect route to redirect")
+	}
+	if s.Body != "" && s.File != "" {
+		return fmt.Errorf("body and file cannot be used together")
+	}
+	if len(s.Body) > MaxRouteStaticBody {
+		return fmt.Errorf("body must be at most %d bytes", MaxRouteStaticBody)
+	}
+	if strings.ContainsRune(s.Body, 0) {
+		return fmt.Errorf("body cannot contain NUL characters")
+	}
+	if s.ContentType != "" {
+		if s.File != "" {
+			return fmt.Errorf("content_type applies to body, the type of a file follows its extension")
+		}
+		if !routeContentTypeRegex.MatchString(s.ContentType) {
+			return fmt.Errorf("content_type must be a media type like text/html or text/html; charset=utf-8")
+		}
+	}
+	if s.File != "" && (len(s.File) > maxRouteStaticFilePath || !routeStaticFileRegex.MatchString(s.File)) {
+		return fmt.Errorf("file must be a relative path of letters, digits, '.', '_' and '-', no segment starting with '.'")
+	}
+	return nil
+}
+
+// StatusOrDefault returns the response status code with its default
+func (s RouteStatic) StatusOrDefault() int {
+	if s.StatusCode == 0 {
+		return DefaultStaticStatus
+	}
+	return s.StatusCode
+}
+
+// ContentTypeOrDefault returns the type of the body with its default
+func (s RouteStatic) ContentTypeOrDefault() string {
+	if s.ContentType == "" {
+		return DefaultStaticType
+	}
+	return s.ContentType
+}
+
+// Value implements driver.Valuer for RouteRedirect to store as JSON
+func (r RouteRedirect) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner for RouteRedirect to read from JSON
+func (r *RouteRedirect) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into RouteRedirect", value)
+	}
+}
+
+// Value implements driver.Valuer for RouteStatic to store as JSON
+func (s RouteStatic) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner for RouteStatic to read from JSON
+func (s *RouteStatic) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into RouteStatic", value)
+	}
+}
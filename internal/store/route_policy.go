@@ -0,0 +1,269 @@
+package store
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Route policy limits
+const (
+	MaxRouteTimeout     = 3600 // longest proxy timeout in seconds
+	MaxRouteHeaders     = 32   // most custom request or response headers per route
+	MaxRouteHeaderValue = 1024 // longest custom header value
+	MaxCORSMaxAge       = 86400
+	DefaultCORSMaxAge   = 600
+	RouteCORSAnyOrigin  = "*"
+	maxRouteHeaderName  = 256 // longest custom header name
+
+	MaxRouteRequestRate      = 100000 // most requests per second a rate limit allows
+	MaxRouteBurst            = 100000
+	MaxRouteConnections      = 100000
+	DefaultRateLimitStatus   = 429
+	RateLimitKeyIP           = "ip"      // count per client address
+	RateLimitKeyHeaderPrefix = "header:" // count per value of a request header
+)
+
+var (
+	routeHeaderNameRegex = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+	routeBodySizeRegex   = regexp.MustCompile(`^[0-9]{1,9}[kKmMgG]?$`)
+	corsOriginRegex      = regexp.MustCompile(`^https?://[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?(:[0-9]{1,5})?$`)
+	corsMethodRegex      = regexp.MustCompile(`^[A-Z]+$`)
+	// nginx only exposes headers made of letters, digits and dashes as $http_ variables
+	rateLimitHeaderRegex = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+)
+
+// RoutePolicy is how nginx proxies a route. Everything left out keeps the
+// defaults of the generated server block.
+type RoutePolicy struct {
+	ConnectTimeout  int               `json:"connect_timeout,omitempty"`  // seconds, default 30
+	ReadTimeout     int               `json:"read_timeout,omitempty"`     // seconds, default 30
+	SendTimeout     int               `json:"send_timeout,omitempty"`     // seconds, default 30
+	MaxBodySize     string            `json:"max_body_size,omitempty"`    // nginx size like "10m", "0" for no limit
+	Buffering       *bool             `json:"buffering,omitempty"`        // false streams requests and responses unbuffered
+	WebSocket       bool              `json:"websocket,omitempty"`        // pass Upgrade requests through
+	Gzip            bool              `json:"gzip,omitempty"`             // compress text responses
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`  // sent to the service, values may use nginx variables
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"` // added to every response
+	CORS            *CORSPolicy       `json:"cors,omitempty"`
+	RateLimit       *RateLimitPolicy  `json:"rate_limit,omitempty"`
+}
+
+// CORSPolicy answers cross-origin requests, including preflight requests,
+// without reaching the service
+type CORSPolicy struct {
+	AllowOrigins     []string `json:"allow_origins"` // origins like https://app.example.com, or "*"
+	AllowMethods     []string `json:"allow_methods,omitempty"`
+	AllowHeaders     []string `json:"allow_headers,omitempty"`
+	ExposeHeaders    []string `json:"expose_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"` // seconds browsers cache preflight results, default 600
+}
+
+// RateLimitPolicy limits how fast and how many requests at once each client
+// sends to a route. Requests over the limits are answered by nginx with
+// StatusCode without reaching the service.
+type RateLimitPolicy struct {
+	RequestsPerSecond int    `json:"requests_per_second,omitempty"` // 0 for no request rate limit
+	Burst             int    `json:"burst,omitempty"`               // requests over the rate queued before rejecting
+	NoDelay           bool   `json:"nodelay,omitempty"`             // serve burst requests right away instead of pacing them
+	Connections       int    `json:"connections,omitempty"`         // concurrent requests, 0 for no limit
+	Key               string `json:"key,omitempty"`                 // "ip" or "header:<name>", default ip
+	StatusCode        int    `json:"status_code,omitempty"`         // default 429
+}
+
+// Validate checks the timeouts, body size, headers, CORS and rate limit settings
+func (p RoutePolicy) Validate() error {
+	for name, timeout := range map[string]int{
+		"connect_timeout": p.ConnectTimeout,
+		"read_timeout":    p.ReadTimeout,
+		"send_timeout":    p.SendTimeout,
+	} {
+		if timeout < 0 || timeout > MaxRouteTimeout {
+			return fmt.Errorf("%s must be between 1 and %d seconds, or 0 for the default", name, MaxRouteTimeout)
+		}
+	}
+	if p.MaxBodySize != "" && !routeBodySizeRegex.MatchString(p.MaxBodySize) {
+		return fmt.Errorf("max_body_size must be a size like 512k, 10m or 1g")
+	}
+	if err := validateRouteHeaders("request_headers", p.RequestHeaders); err != nil {
+		return err
+	}
+	if err := validateRouteHeaders("response_headers", p.ResponseHeaders); err != nil {
+		return err
+	}
+	if p.CORS != nil {
+		if err := p.CORS.Validate(); err != nil {
+			return fmt.Errorf("invalid cors: %w", err)
+		}
+	}
+	if p.RateLimit != nil {
+		if err := p.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("invalid rate_limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// BufferingDisabled reports whether requests and responses are streamed unbuffered
+func (p RoutePolicy) BufferingDisabled() bool {
+	return p.Buffering != nil && !*p.Buffering
+}
+
+// Validate checks the origins, methods and headers
+func (c CORSPolicy) Validate() error {
+	if len(c.AllowOrigins) == 0 {
+		return fmt.Errorf("allow_origins is required")
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == RouteCORSAnyOrigin {
+			if len(c.AllowOrigins) > 1 {
+				return fmt.Errorf("%q cannot be combined with other origins", RouteCORSAnyOrigin)
+			}
+			if c.AllowCredentials {
+				return fmt.Errorf("allow_credentials cannot be used with %q", RouteCORSAnyOrigin)
+			}
+			continue
+		}
+		if !corsOriginRegex.MatchString(origin) {
+			return fmt.Errorf("invalid origin %q: expected scheme://host[:port]", origin)
+		}
+	}
+	for _, method := range c.AllowMethods {
+		if !corsMethodRegex.MatchString(method) {
+			return fmt.Errorf("invalid method %q", method)
+		}
+	}
+	for _, header := range append(append([]string{}, c.AllowHeaders...), c.ExposeHeaders...) {
+		if !routeHeaderNameRegex.MatchString(header) || len(header) > maxRouteHeaderName {
+			return fmt.Errorf("invalid header name %q", header)
+		}
+	}
+	if c.MaxAge < 0 || c.MaxAge > MaxCORSMaxAge {
+		return fmt.Errorf("max_age must be between 0 and %d seconds", MaxCORSMaxAge)
+	}
+	return nil
+}
+
+// Validate checks the limits, key and status code
+func (r RateLimitPolicy) Validate() error {
+	if r.RequestsPerSecond < 0 || r.RequestsPerSecond > MaxRouteRequestRate {
+		return fmt.Errorf("requests_per_second must be between 1 and %d, or 0 for no rate limit", MaxRouteRequestRate)
+	}
+	if r.Burst < 0 || r.Burst > MaxRouteBurst {
+		return fmt.Errorf("burst must be between 0 and %d", MaxRouteBurst)
+	}
+	if r.Connections < 0 || r.Connections > MaxRouteConnections {
+		return fmt.Errorf("connections must be between 1 and %d, or 0 for no connection limit", MaxRouteConnections)
+	}
+	if r.RequestsPerSecond == 0 {
+		if r.Connections == 0 {
+			return fmt.Errorf("requests_per_second or connections is required")
+		}
+		if r.Burst > 0 || r.NoDelay {
+			return fmt.Errorf("burst and nodelay require requests_per_second")
+		}
+	}
+	if r.Key != "" && r.Key != RateLimitKeyIP {
+		if !strings.HasPrefix(r.Key, RateLimitKeyHeaderPrefix) || !rateLimitHeaderRegex.MatchString(r.KeyHeader()) {
+			return fmt.Errorf("key must be %q or %q followed by a header name of letters, digits and dashes", RateLimitKeyIP, RateLimitKeyHeaderPrefix)
+		}
+	}
+	if r.StatusCode != 0 && (r.StatusCode < 400 || r.StatusCode > 599) {
+		return fmt.Errorf("status_code must be between 400 and 599")
+	}
+	return nil
+}
+
+// KeyHeader returns the request header clients are counted by, or "" when
+// they are counted by address
+func (r RateLimitPolicy) KeyHeader() string {
+	if !strings.HasPrefix(r.Key, RateLimitKeyHeaderPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(r.Key, RateLimitKeyHeaderPrefix)
+}
+
+// StatusOrDefault returns the status code of rejected requests with its default
+func (r RateLimitPolicy) StatusOrDefault() int {
+	if r.StatusCode == 0 {
+		return DefaultRateLimitStatus
+	}
+	return r.StatusCode
+}
+
+// AllowsAnyOrigin reports whether every origin is allowed
+func (c CORSPolicy) AllowsAnyOrigin() bool {
+	return len(c.AllowOrigins) == 1 && c.AllowOrigins[0] == RouteCORSAnyOrigin
+}
+
+// validateRouteHeaders checks custom header names and values
+func validateRouteHeaders(field string, headers map[string]string) error {
+	if len(headers) > MaxRouteHeaders {
+		return fmt.Errorf("%s cannot have more than %d headers", field, MaxRouteHeaders)
+	}
+	for name, value := range headers {
+		if !routeHeaderNameRegex.MatchString(name) || len(name) > maxRouteHeaderName {
+			return fmt.Errorf("%s: invalid header name %q", field, name)
+		}
+		if len(value) > MaxRouteHeaderValue {
+			return fmt.Errorf("%s: value of %s cannot be longer than %d characters", field, name, MaxRouteHeaderValue)
+		}
+		if strings.IndexFunc(value, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0 {
+			return fmt.Errorf("%s: value of %s cannot contain control characters", field, name)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts the policy as an object, or as a string holding one,
+// the way proxy_config was sent before it had a type. Unknown fields are rejected.
+func (p *RoutePolicy) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		if strings.TrimSpace(text) == "" {
+			*p = RoutePolicy{}
+			return nil
+		}
+		data = []byte(text)
+	}
+
+	type plainPolicy RoutePolicy
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	var policy plainPolicy
+	if err := decoder.Decode(&policy); err != nil {
+		return fmt.Errorf("invalid proxy_config: %w", err)
+	}
+	*p = RoutePolicy(policy)
+	return nil
+}
+
+// Value implements driver.Valuer for RoutePolicy to store as JSON
+func (p RoutePolicy) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner for RoutePolicy to read from JSON
+func (p *RoutePolicy) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into RoutePolicy", value)
+	}
+
+	type plainPolicy RoutePolicy
+	return json.Unmarshal(data, (*plainPolicy)(p))
+}
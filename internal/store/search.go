@@ -678,19 +678,19 @@ func (s *Store) IndexRoute(ctx context.Context, routeID int64) error {
 
 	// Index the specific route
 	row := tx.QueryRowContext(ctx, `
-		SELECT r.id, r.domain, r.port, r.tls,
-		       s.name as service_name, p.name as project_name
+		SELECT r.id, r.kind, r.domain, r.port, r.tls,
+		       COALESCE(s.name, '') as service_name, COALESCE(p.name, '') as project_name
 		FROM routes r
-		JOIN services s ON r.service_id = s.id
-		JOIN projects p ON s.project_id = p.id
+		LEFT JOIN services s ON r.service_id = s.id
+		LEFT JOIN projects p ON s.project_id = p.id
 		WHERE r.id = ?`, routeID)
 
 	var id int64
-	var domain, serviceName, projectName string
+	var kind, domain, serviceName, projectName string
 	var port int
 	var tls bool
 
-	if err := row.Scan(&id, &domain, &port, &tls, &serviceName, &projectName); err != nil {
+	if err := row.Scan(&id, &kind, &domain, &port, &tls, &serviceName, &projectName); err != nil {
 		if err == sql.ErrNoRows {
 			// Route was deleted, just commit the removal
 			return tx.Commit()
@@ -706,6 +706,10 @@ func (s *Store) IndexRoute(ctx context.Context, routeID int64) error {
 		tlsStatus = "HTTPS"
 	}
 	subtitle := fmt.Sprintf("%s • %s:%d → %s", projectName, tlsStatus, port, serviceName)
+	if serviceName == "" {
+		// Redirect and static routes have no service
+		subtitle = fmt.Sprintf("%s • %s route", tlsStatus, kind)
+	}
 	content := fmt.Sprintf("%s %s %d", domain, protocol, port)
 	tags := fmt.Sprintf("route %s %s %d", domain, protocol, port)
 	urlPath := fmt.Sprintf("/app/routes")
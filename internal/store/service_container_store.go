@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetServiceContainers returns the init containers and sidecars of a service, in declaration order
+func (s *Store) GetServiceContainers(ctx context.Context, serviceID int64) (*ServiceContainers, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT role, name, image, command, env FROM service_containers WHERE service_id = ? ORDER BY role, position",
+		serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service containers: %w", err)
+	}
+	defer rows.Close()
+
+	containers := &ServiceContainers{
+		InitContainers: []ServiceContainer{},
+		Sidecars:       []ServiceContainer{},
+	}
+	for rows.Next() {
+		var role, commandJSON, envJSON string
+		var container ServiceContainer
+		if err := rows.Scan(&role, &container.Name, &container.Image, &commandJSON, &envJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan service container: %w", err)
+		}
+		if err := unmarshalJSON(commandJSON, &container.Command); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal command: %w", err)
+		}
+		if err := unmarshalJSON(envJSON, &container.Env); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal env: %w", err)
+		}
+
+		if role == ServiceContainerInit {
+			containers.InitContainers = append(containers.InitContainers, container)
+		} else {
+			containers.Sidecars = append(containers.Sidecars, container)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate service containers: %w", err)
+	}
+
+	return containers, nil
+}
+
+// SetServiceContainers replaces the init containers and sidecars of a service
+func (s *Store) SetServiceContainers(ctx context.Context, serviceID int64, containers ServiceContainers) error {
+	if err := containers.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM service_containers WHERE service_id = ?", serviceID); err != nil {
+		return fmt.Errorf("failed to clear service containers: %w", err)
+	}
+	if err := insertServiceContainers(ctx, tx, serviceID, containers); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit service containers: %w", err)
+	}
+	return nil
+}
+
+// insertServiceContainers writes validated init containers and sidecars of a service
+func insertServiceContainers(ctx context.Context, db dbExecutor, serviceID int64, containers ServiceContainers) error {
+	roles := []struct {
+		role       string
+		containers []ServiceContainer
+	}{
+		{ServiceContainerInit, containers.InitContainers},
+		{ServiceContainerSidecar, containers.Sidecars},
+	}
+
+	for _, group := range roles {
+		for position, container := range group.containers {
+			commandJSON, err := marshalJSON(container.Command)
+			if err != nil {
+				return fmt.Errorf("failed to marshal command: %w", err)
+			}
+			envJSON, err := marshalJSON(container.Env)
+			if err != nil {
+				return fmt.Errorf("failed to marshal env: %w", err)
+			}
+
+			_, err = db.ExecContext(ctx,
+				"INSERT INTO service_containers (service_id, role, position, name, image, command, env) VALUES (?, ?, ?, ?, ?, ?, ?)",
+				serviceID, group.role, position, container.Name, container.Image, commandJSON, envJSON)
+			if err != nil {
+				return fmt.Errorf("failed to insert service container %s: %w", container.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateServiceRun inserts a running one-off run and sets its ID
+func (s *Store) CreateServiceRun(ctx context.Context, run *ServiceRun) error {
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now().UTC()
+	}
+	if run.Status == "" {
+		run.Status = TaskRunRunning
+	}
+
+	commandJSON, err := marshalJSON(run.Command)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO service_runs (service_id, command, actor, status, started_at) VALUES (?, ?, ?, ?, ?)",
+		run.ServiceID, commandJSON, run.Actor, run.Status, run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create service run: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get service run ID: %w", err)
+	}
+	run.ID = id
+
+	return nil
+}
+
+// FinishServiceRun records the outcome of a one-off run
+func (s *Store) FinishServiceRun(ctx context.Context, run *ServiceRun) error {
+	if run.FinishedAt == nil {
+		now := time.Now().UTC()
+		run.FinishedAt = &now
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE service_runs SET status = ?, exit_code = ?, error = ?, output = ?, duration_ms = ?, finished_at = ? WHERE id = ?",
+		run.Status, run.ExitCode, run.Error, run.Output, run.DurationMs, run.FinishedAt, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish service run: %w", err)
+	}
+	return nil
+}
+
+// GetServiceRun retrieves a one-off run with its output
+func (s *Store) GetServiceRun(ctx context.Context, id int64) (*ServiceRun, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, service_id, command, actor, status, exit_code, error, output, duration_ms, started_at, finished_at FROM service_runs WHERE id = ?", id)
+
+	run, err := scanServiceRun(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service run: %w", err)
+	}
+
+	return run, nil
+}
+
+// ListServiceRuns returns up to limit one-off runs of a service, newest first, without their output
+func (s *Store) ListServiceRuns(ctx context.Context, serviceID int64, limit int) ([]ServiceRun, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, service_id, command, actor, status, exit_code, error, '', duration_ms, started_at, finished_at FROM service_runs WHERE service_id = ? ORDER BY started_at DESC, id DESC LIMIT ?",
+		serviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ServiceRun
+	for rows.Next() {
+		run, err := scanServiceRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate service runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// FailInterruptedServiceRuns marks one-off runs left running by a previous process as failed
+func (s *Store) FailInterruptedServiceRuns(ctx context.Context, reason string) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE service_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?",
+		TaskRunFailed, reason, time.Now().UTC(), TaskRunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted service runs: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected, nil
+}
+
+// PruneServiceRuns deletes the finished one-off runs of a service beyond the newest keep runs
+func (s *Store) PruneServiceRuns(ctx context.Context, serviceID int64, keep int) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM service_runs
+		WHERE service_id = ? AND status IN (?, ?) AND id NOT IN (
+			SELECT id FROM service_runs WHERE service_id = ? ORDER BY started_at DESC, id DESC LIMIT ?
+		)`, serviceID, TaskRunSucceeded, TaskRunFailed, serviceID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune service runs: %w", err)
+	}
+	return nil
+}
+
+// scanServiceRun scans a single service_runs row
+func scanServiceRun(row rowScanner) (*ServiceRun, error) {
+	var run ServiceRun
+	var commandJSON string
+	var output sql.NullString
+	var finishedAt sql.NullTime
+
+	err := row.Scan(
+		&run.ID,
+		&run.ServiceID,
+		&commandJSON,
+		&run.Actor,
+		&run.Status,
+		&run.ExitCode,
+		&run.Error,
+		&output,
+		&run.DurationMs,
+		&run.StartedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalJSON(commandJSON, &run.Command); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal command: %w", err)
+	}
+	run.Output = output.String
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+
+	return &run, nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetBackupSchedule retrieves the volume backup schedule of a service
+func (s *Store) GetBackupSchedule(ctx context.Context, serviceID int64) (*BackupSchedule, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT service_id, enabled, interval_minutes, retention, quiesce, last_run_at, updated_at FROM volume_backup_schedules WHERE service_id = ?",
+		serviceID)
+
+	schedule, err := scanBackupSchedule(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// SetBackupSchedule creates or replaces the volume backup schedule of a service,
+// keeping the time of its last run
+func (s *Store) SetBackupSchedule(ctx context.Context, schedule *BackupSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	schedule.UpdatedAt = time.Now()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO volume_backup_schedules (service_id, enabled, interval_minutes, retention, quiesce, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(service_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			interval_minutes = excluded.interval_minutes,
+			retention = excluded.retention,
+			quiesce = excluded.quiesce,
+			updated_at = excluded.updated_at
+	`, schedule.ServiceID, schedule.Enabled, schedule.IntervalMinutes, schedule.Retention, schedule.Quiesce, schedule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set backup schedule: %w", err)
+	}
+
+	return nil
+}
+
+// ListBackupSchedules returns all enabled volume backup schedules
+func (s *Store) ListBackupSchedules(ctx context.Context) ([]BackupSchedule, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT service_id, enabled, interval_minutes, retention, quiesce, last_run_at, updated_at FROM volume_backup_schedules WHERE enabled = 1 ORDER BY service_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []BackupSchedule
+	for rows.Next() {
+		schedule, err := scanBackupSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backup schedule: %w", err)
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate backup schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// MarkBackupScheduleRun records when a scheduled snapshot of a service was last started
+func (s *Store) MarkBackupScheduleRun(ctx context.Context, serviceID int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE volume_backup_schedules SET last_run_at = ? WHERE service_id = ?", at, serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to mark backup schedule run: %w", err)
+	}
+	return nil
+}
+
+// scanBackupSchedule scans a single volume_backup_schedules row
+func scanBackupSchedule(row rowScanner) (*BackupSchedule, error) {
+	var schedule BackupSchedule
+	var lastRunAt sql.NullTime
+
+	err := row.Scan(
+		&schedule.ServiceID,
+		&schedule.Enabled,
+		&schedule.IntervalMinutes,
+		&schedule.Retention,
+		&schedule.Quiesce,
+		&lastRunAt,
+		&schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+
+	return &schedule, nil
+}
+
+// CreateVolumeSnapshot inserts a volume snapshot record and sets its ID
+func (s *Store) CreateVolumeSnapshot(ctx context.Context, snapshot *VolumeSnapshot) error {
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now()
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO volume_snapshots (service_id, volume, file_name, size, created_at) VALUES (?, ?, ?, ?, ?)",
+		snapshot.ServiceID, snapshot.Volume, snapshot.FileName, snapshot.Size, snapshot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create volume snapshot: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get volume snapshot ID: %w", err)
+	}
+	snapshot.ID = id
+
+	return nil
+}
+
+// GetVolumeSnapshot retrieves a volume snapshot by ID
+func (s *Store) GetVolumeSnapshot(ctx context.Context, id int64) (*VolumeSnapshot, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, service_id, volume, file_name, size, created_at FROM volume_snapshots WHERE id = ?", id)
+
+	var snapshot VolumeSnapshot
+	err := row.Scan(&snapshot.ID, &snapshot.ServiceID, &snapshot.Volume, &snapshot.FileName, &snapshot.Size, &snapshot.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// ListVolumeSnapshots returns the volume snapshots of a service, newest first
+func (s *Store) ListVolumeSnapshots(ctx context.Context, serviceID int64) ([]VolumeSnapshot, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, service_id, volume, file_name, size, created_at FROM volume_snapshots WHERE service_id = ? ORDER BY created_at DESC, id DESC",
+		serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volume snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []VolumeSnapshot
+	for rows.Next() {
+		var snapshot VolumeSnapshot
+		if err := rows.Scan(&snapshot.ID, &snapshot.ServiceID, &snapshot.Volume, &snapshot.FileName, &snapshot.Size, &snapshot.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan volume snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate volume snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// DeleteVolumeSnapshot removes a volume snapshot record
+func (s *Store) DeleteVolumeSnapshot(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM volume_snapshots WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete volume snapshot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -418,51 +418,12 @@ func (s *Store) TokenCount(ctx context.Context) (int, error) {
 
 // CreateService creates a new service within a project
 func (s *Store) CreateService(ctx context.Context, projectID int64, spec ServiceSpec) (Service, error) {
-	if spec.Name == "" || len(spec.Name) > 64 {
-		return Service{}, fmt.Errorf("invalid service name: must be 1-64 characters")
-	}
-	if spec.Image == "" {
-		return Service{}, fmt.Errorf("service image cannot be empty")
-	}
-
-	// Validate DNS-friendly name
-	if !isDNSLabel(spec.Name) {
-		return Service{}, fmt.Errorf("service name must be DNS-label friendly")
-	}
-
-	// Marshal JSON fields
-	envJSON, err := marshalJSON(spec.Env)
-	if err != nil {
-		return Service{}, fmt.Errorf("failed to marshal env: %w", err)
-	}
-
-	portsJSON, err := marshalJSON(spec.Ports)
-	if err != nil {
-		return Service{}, fmt.Errorf("failed to marshal ports: %w", err)
-	}
-
-	result, err := s.db.ExecContext(ctx,
-		"INSERT INTO services (project_id, name, image, env, ports, container_id, health_path, desired_state, restart_count, crash_looping, health_status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		projectID, spec.Name, spec.Image, envJSON, portsJSON, nil, spec.HealthPath, ServiceStateRunning, 0, false, HealthStatusUnknown)
+	service, err := insertService(ctx, s.db, projectID, spec)
 	if err != nil {
-		return Service{}, fmt.Errorf("failed to create service: %w", err)
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		return Service{}, fmt.Errorf("failed to get service ID: %w", err)
-	}
-
-	service := Service{
-		ID:         id,
-		ProjectID:  projectID,
-		Name:       spec.Name,
-		Image:      spec.Image,
-		Env:        spec.Env,
-		Ports:      spec.Ports,
-		HealthPath: spec.HealthPath,
-		CreatedAt:  time.Now(),
+		return Service{}, err
 	}
+	service.CreatedAt = time.Now()
+	id := service.ID
 
 	// Update search index in background to avoid blocking the operation
 	go func() {
@@ -481,7 +442,7 @@ func (s *Store) CreateService(ctx context.Context, projectID int64, spec Service
 // ListServices returns all services for a project
 func (s *Store) ListServices(ctx context.Context, projectID int64) ([]Service, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, created_at FROM services WHERE project_id = ? ORDER BY created_at DESC",
+		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, registry_id, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, deploy_strategy, upstream_host, auto_rollback, rollback_window_seconds, replicas, load_balancing, resources, restart_policy, restart_max_retries, kind, created_at FROM services WHERE project_id = ? ORDER BY created_at DESC",
 		projectID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list services: %w", err)
@@ -491,10 +452,10 @@ func (s *Store) ListServices(ctx context.Context, projectID int64) ([]Service, e
 	var services []Service
 	for rows.Next() {
 		var service Service
-		var envJSON, portsJSON, volumesJSON sql.NullString
+		var envJSON, portsJSON, volumesJSON, resourcesJSON sql.NullString
 
 		err := rows.Scan(&service.ID, &service.ProjectID, &service.Name, &service.Description, &service.Image,
-			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.CreatedAt)
+			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.RegistryID, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.DeployStrategy, &service.UpstreamHost, &service.AutoRollback, &service.RollbackWindow, &service.Replicas, &service.LoadBalancing, &resourcesJSON, &service.RestartPolicy.Name, &service.RestartPolicy.MaxRetries, &service.Kind, &service.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan service: %w", err)
 		}
@@ -511,6 +472,10 @@ func (s *Store) ListServices(ctx context.Context, projectID int64) ([]Service, e
 			return nil, fmt.Errorf("failed to unmarshal volumes: %w", err)
 		}
 
+		if err := unmarshalJSON(resourcesJSON.String, &service.Resources); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal resources: %w", err)
+		}
+
 		services = append(services, service)
 	}
 
@@ -520,12 +485,12 @@ func (s *Store) ListServices(ctx context.Context, projectID int64) ([]Service, e
 // GetService retrieves a service by ID
 func (s *Store) GetService(ctx context.Context, id int64) (Service, error) {
 	var service Service
-	var envJSON, portsJSON, volumesJSON sql.NullString
+	var envJSON, portsJSON, volumesJSON, resourcesJSON sql.NullString
 
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, created_at FROM services WHERE id = ?", id).
+		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, registry_id, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, deploy_strategy, upstream_host, auto_rollback, rollback_window_seconds, replicas, load_balancing, resources, restart_policy, restart_max_retries, kind, created_at FROM services WHERE id = ?", id).
 		Scan(&service.ID, &service.ProjectID, &service.Name, &service.Description, &service.Image,
-			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.CreatedAt)
+			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.RegistryID, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.DeployStrategy, &service.UpstreamHost, &service.AutoRollback, &service.RollbackWindow, &service.Replicas, &service.LoadBalancing, &resourcesJSON, &service.RestartPolicy.Name, &service.RestartPolicy.MaxRetries, &service.Kind, &service.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return Service{}, fmt.Errorf("service not found: %d", id)
@@ -546,6 +511,10 @@ func (s *Store) GetService(ctx context.Context, id int64) (Service, error) {
 		return Service{}, fmt.Errorf("failed to unmarshal volumes: %w", err)
 	}
 
+	if err := unmarshalJSON(resourcesJSON.String, &service.Resources); err != nil {
+		return Service{}, fmt.Errorf("failed to unmarshal resources: %w", err)
+	}
+
 	return service, nil
 }
 
@@ -782,6 +751,12 @@ func (s *Store) UpdateService(ctx context.Context, id int64, updates Service) er
 		return fmt.Errorf("failed to marshal volumes: %w", err)
 	}
 
+	resourcesJSON, err := marshalJSON(updates.Resources)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resources: %w", err)
+	}
+	restartPolicy := updates.RestartPolicy.normalized()
+
 	query := `UPDATE services SET 
 		name = ?, 
 		description = ?, 
@@ -791,7 +766,10 @@ func (s *Store) UpdateService(ctx context.Context, id int64, updates Service) er
 		ports = ?, 
 		volumes = ?,
 		project_id = ?,
-		health_path = ?
+		health_path = ?,
+		resources = ?,
+		restart_policy = ?,
+		restart_max_retries = ?
 		WHERE id = ?`
 
 	result, err := s.db.ExecContext(ctx, query,
@@ -804,6 +782,9 @@ func (s *Store) UpdateService(ctx context.Context, id int64, updates Service) er
 		volumesJSON,
 		updates.ProjectID,
 		updates.HealthPath,
+		resourcesJSON,
+		restartPolicy.Name,
+		restartPolicy.MaxRetries,
 		id)
 	if err != nil {
 		return fmt.Errorf("failed to update service: %w", err)
@@ -840,15 +821,135 @@ func (s *Store) UpdateServiceContainerID(ctx context.Context, id int64, containe
 	return nil
 }
 
+// UpdateServiceDeployStrategy sets how new versions of a service are rolled out
+func (s *Store) UpdateServiceDeployStrategy(ctx context.Context, id int64, strategy string) error {
+	if !IsValidDeployStrategy(strategy) {
+		return fmt.Errorf("invalid deploy strategy: %s", strategy)
+	}
+
+	result, err := s.db.ExecContext(ctx, "UPDATE services SET deploy_strategy = ? WHERE id = ?", strategy, id)
+	if err != nil {
+		return fmt.Errorf("failed to update service deploy_strategy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service not found: %d", id)
+	}
+
+	return nil
+}
+
+// UpdateServiceUpstreamHost sets the host nginx proxies the service's routes to.
+// An empty host clears the override so routes use the service name again.
+func (s *Store) UpdateServiceUpstreamHost(ctx context.Context, id int64, host string) error {
+	var value *string
+	if host != "" {
+		value = &host
+	}
+
+	result, err := s.db.ExecContext(ctx, "UPDATE services SET upstream_host = ? WHERE id = ?", value, id)
+	if err != nil {
+		return fmt.Errorf("failed to update service upstream_host: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service not found: %d", id)
+	}
+
+	return nil
+}
+
+// UpdateServiceImage sets the image a service runs
+func (s *Store) UpdateServiceImage(ctx context.Context, id int64, image string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE services SET image = ? WHERE id = ?", image, id)
+	if err != nil {
+		return fmt.Errorf("failed to update service image: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service not found: %d", id)
+	}
+
+	return nil
+}
+
+// UpdateServiceRollbackPolicy configures automatic rollback for a service
+func (s *Store) UpdateServiceRollbackPolicy(ctx context.Context, id int64, enabled bool, windowSeconds int) error {
+	if windowSeconds <= 0 || windowSeconds > MaxRollbackWindow {
+		return fmt.Errorf("rollback window must be between 1 and %d seconds", MaxRollbackWindow)
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE services SET auto_rollback = ?, rollback_window_seconds = ? WHERE id = ?",
+		enabled, windowSeconds, id)
+	if err != nil {
+		return fmt.Errorf("failed to update service rollback policy: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service not found: %d", id)
+	}
+
+	return nil
+}
+
+// UpdateServiceScale sets the number of replicas of a service and how nginx balances between them
+func (s *Store) UpdateServiceScale(ctx context.Context, id int64, replicas int, loadBalancing string) error {
+	if replicas < 1 || replicas > MaxReplicas {
+		return fmt.Errorf("replicas must be between 1 and %d", MaxReplicas)
+	}
+	if !IsValidLoadBalancing(loadBalancing) {
+		return fmt.Errorf("invalid load balancing method: %s", loadBalancing)
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE services SET replicas = ?, load_balancing = ? WHERE id = ?",
+		replicas, loadBalancing, id)
+	if err != nil {
+		return fmt.Errorf("failed to update service scale: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("service not found: %d", id)
+	}
+
+	return nil
+}
+
 // GetServiceByContainerID retrieves a service by its container ID
 func (s *Store) GetServiceByContainerID(ctx context.Context, containerID string) (*Service, error) {
 	var service Service
-	var envJSON, portsJSON, volumesJSON sql.NullString
+	var envJSON, portsJSON, volumesJSON, resourcesJSON sql.NullString
 
 	err := s.db.QueryRowContext(ctx,
-		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, created_at FROM services WHERE container_id = ?", containerID).
+		"SELECT id, project_id, name, description, image, container_id, env, ports, volumes, registry_id, health_path, desired_state, last_exit_code, restart_count, restart_window_at, crash_looping, health_status, last_probe_at, deploy_strategy, upstream_host, auto_rollback, rollback_window_seconds, replicas, load_balancing, resources, restart_policy, restart_max_retries, kind, created_at FROM services WHERE container_id = ?", containerID).
 		Scan(&service.ID, &service.ProjectID, &service.Name, &service.Description, &service.Image,
-			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.CreatedAt)
+			&service.ContainerID, &envJSON, &portsJSON, &volumesJSON, &service.RegistryID, &service.HealthPath, &service.DesiredState, &service.LastExitCode, &service.RestartCount, &service.RestartWindowAt, &service.CrashLooping, &service.HealthStatus, &service.LastProbeAt, &service.DeployStrategy, &service.UpstreamHost, &service.AutoRollback, &service.RollbackWindow, &service.Replicas, &service.LoadBalancing, &resourcesJSON, &service.RestartPolicy.Name, &service.RestartPolicy.MaxRetries, &service.Kind, &service.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Return nil instead of error for "not found"
@@ -866,6 +967,9 @@ func (s *Store) GetServiceByContainerID(ctx context.Context, containerID string)
 	if err := unmarshalJSON(volumesJSON.String, &service.Volumes); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal volumes: %w", err)
 	}
+	if err := unmarshalJSON(resourcesJSON.String, &service.Resources); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resources: %w", err)
+	}
 
 	return &service, nil
 }
@@ -873,6 +977,11 @@ func (s *Store) GetServiceByContainerID(ctx context.Context, containerID string)
 // Helper functions for JSON marshaling and validation
 var dnsLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
 
+// IsValidServiceName reports whether name is usable as a service name
+func IsValidServiceName(name string) bool {
+	return isDNSLabel(name)
+}
+
 func isDNSLabel(name string) bool {
 	if len(name) == 0 || len(name) > 63 {
 		return false
@@ -946,27 +1055,45 @@ func (s *Store) UnlockService(ctx context.Context, serviceID int64) error {
 
 // Route operations
 
-// CreateRoute creates a new route for a service
+// routeColumns are the columns scanned by scanRoute
+const routeColumns = "id, COALESCE(service_id, 0), kind, domain, port, tls, path, match_type, strip_prefix, certificate_id, domain_id, proxy_config, access_config, redirect_config, static_config, created_at, updated_at"
+
+// scanRoute scans a single routes row selected with routeColumns
+func scanRoute(row rowScanner) (Route, error) {
+	var route Route
+	err := row.Scan(&route.ID, &route.ServiceID, &route.Kind, &route.Domain, &route.Port, &route.TLS, &route.Path, &route.MatchType, &route.StripPrefix,
+		&route.CertificateID, &route.DomainID, &route.ProxyConfig, &route.Access, &route.Redirect, &route.Static, &route.CreatedAt, &route.UpdatedAt)
+	return route, err
+}
+
+// CreateRoute creates a new route for a service. Redirect and static routes
+// have no service and are created with serviceID 0.
 func (s *Store) CreateRoute(ctx context.Context, serviceID int64, spec RouteSpec) (Route, error) {
 	// Validate domain (basic validation)
-	if spec.Domain == "" {
-		return Route{}, fmt.Errorf("domain cannot be empty")
+	if err := validateRouteSpec(spec); err != nil {
+		return Route{}, err
 	}
-
-	if len(spec.Domain) > 253 { // Max domain name length
-		return Route{}, fmt.Errorf("domain too long: max 253 characters")
+	if spec.Proxies() != (serviceID != 0) {
+		return Route{}, fmt.Errorf("%w: proxy routes need a service, redirect and static routes have none", ErrRouteKind)
 	}
 
 	// Verify service exists
-	_, err := s.GetService(ctx, serviceID)
-	if err != nil {
-		return Route{}, fmt.Errorf("service not found: %w", err)
+	var service interface{}
+	if serviceID != 0 {
+		if _, err := s.GetService(ctx, serviceID); err != nil {
+			return Route{}, fmt.Errorf("service not found: %w", err)
+		}
+		service = serviceID
+	}
+
+	if err := checkRouteConflict(ctx, s.db, spec, 0); err != nil {
+		return Route{}, err
 	}
 
 	// Insert route
 	result, err := s.db.ExecContext(ctx,
-		"INSERT INTO routes (service_id, domain, port, tls, path, certificate_id, domain_id, proxy_config) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		serviceID, spec.Domain, spec.Port, spec.TLS, spec.Path, spec.CertificateID, spec.DomainID, spec.ProxyConfig)
+		"INSERT INTO routes (service_id, kind, domain, port, tls, path, match_type, strip_prefix, certificate_id, domain_id, proxy_config, access_config, redirect_config, static_config) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		service, routeKind(spec.Kind), spec.Domain, spec.Port, spec.TLS, spec.Path, routeMatchType(spec.MatchType), spec.StripPrefix, spec.CertificateID, spec.DomainID, spec.ProxyConfig, spec.Access, spec.Redirect, spec.Static)
 	if err != nil {
 		return Route{}, fmt.Errorf("failed to create route: %w", err)
 	}
@@ -995,7 +1122,7 @@ func (s *Store) CreateRoute(ctx context.Context, serviceID int64, spec RouteSpec
 // ListRoutes returns all routes for a service
 func (s *Store) ListRoutes(ctx context.Context, serviceID int64) ([]Route, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, service_id, domain, port, tls, path, certificate_id, domain_id, proxy_config, created_at, updated_at FROM routes WHERE service_id = ? ORDER BY created_at",
+		"SELECT "+routeColumns+" FROM routes WHERE service_id = ? ORDER BY created_at",
 		serviceID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query routes: %w", err)
@@ -1004,8 +1131,7 @@ func (s *Store) ListRoutes(ctx context.Context, serviceID int64) ([]Route, error
 
 	var routes []Route
 	for rows.Next() {
-		var route Route
-		err := rows.Scan(&route.ID, &route.ServiceID, &route.Domain, &route.Port, &route.TLS, &route.Path, &route.CertificateID, &route.DomainID, &route.ProxyConfig, &route.CreatedAt, &route.UpdatedAt)
+		route, err := scanRoute(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
 		}
@@ -1017,10 +1143,8 @@ func (s *Store) ListRoutes(ctx context.Context, serviceID int64) ([]Route, error
 
 // GetRoute retrieves a route by ID
 func (s *Store) GetRoute(ctx context.Context, id int64) (Route, error) {
-	var route Route
-	err := s.db.QueryRowContext(ctx,
-		"SELECT id, service_id, domain, port, tls, path, certificate_id, domain_id, proxy_config, created_at, updated_at FROM routes WHERE id = ?", id).
-		Scan(&route.ID, &route.ServiceID, &route.Domain, &route.Port, &route.TLS, &route.Path, &route.CertificateID, &route.DomainID, &route.ProxyConfig, &route.CreatedAt, &route.UpdatedAt)
+	route, err := scanRoute(s.db.QueryRowContext(ctx,
+		"SELECT "+routeColumns+" FROM routes WHERE id = ?", id))
 
 	if err == sql.ErrNoRows {
 		return Route{}, fmt.Errorf("route not found: %d", id)
@@ -1032,10 +1156,34 @@ func (s *Store) GetRoute(ctx context.Context, id int64) (Route, error) {
 	return route, nil
 }
 
+// ListDomainProjectIDs returns the projects whose services have routes on a domain
+func (s *Store) ListDomainProjectIDs(ctx context.Context, domain string) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT s.project_id FROM routes r
+		JOIN services s ON r.service_id = s.id
+		WHERE r.domain = ?
+		ORDER BY s.project_id`, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projectIDs []int64
+	for rows.Next() {
+		var projectID int64
+		if err := rows.Scan(&projectID); err != nil {
+			return nil, fmt.Errorf("failed to scan domain project: %w", err)
+		}
+		projectIDs = append(projectIDs, projectID)
+	}
+
+	return projectIDs, rows.Err()
+}
+
 // GetAllRoutes retrieves all routes (for nginx config generation)
 func (s *Store) GetAllRoutes(ctx context.Context) ([]Route, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, service_id, domain, port, tls, path, certificate_id, domain_id, proxy_config, created_at, updated_at FROM routes ORDER BY domain")
+		"SELECT "+routeColumns+" FROM routes ORDER BY domain")
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all routes: %w", err)
 	}
@@ -1043,8 +1191,7 @@ func (s *Store) GetAllRoutes(ctx context.Context) ([]Route, error) {
 
 	var routes []Route
 	for rows.Next() {
-		var route Route
-		err := rows.Scan(&route.ID, &route.ServiceID, &route.Domain, &route.Port, &route.TLS, &route.Path, &route.CertificateID, &route.DomainID, &route.ProxyConfig, &route.CreatedAt, &route.UpdatedAt)
+		route, err := scanRoute(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
 		}
@@ -1074,15 +1221,26 @@ func (s *Store) DeleteRoute(ctx context.Context, id int64) error {
 }
 
 // GetAllRoutesWithServices returns all routes joined with service information
+// and the page of any maintenance window serving one for the route's project.
+// Redirect and static routes have no service and no maintenance page.
 func (s *Store) GetAllRoutesWithServices(ctx context.Context) ([]RouteWithService, error) {
+	now := time.Now().UTC().Truncate(time.Second)
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT 
-			r.id, r.service_id, r.domain, r.port, r.tls, r.path, r.certificate_id, r.proxy_config, r.created_at, r.updated_at,
-			s.name as service_name, p.name as project_name
+			r.id, COALESCE(r.service_id, 0), r.kind, r.domain, r.port, r.tls, r.path, r.match_type, r.strip_prefix, r.certificate_id,
+			r.proxy_config, r.access_config, r.redirect_config, r.static_config, r.created_at, r.updated_at,
+			COALESCE(s.name, '') as service_name, COALESCE(p.name, '') as project_name, COALESCE(s.upstream_host, '') as upstream_host,
+			COALESCE(s.replicas, 0), COALESCE(s.load_balancing, ''),
+			(SELECT m.page_html FROM maintenance_windows m
+				WHERE r.service_id IS NOT NULL AND m.serve_page = 1 AND (m.project_id = p.id OR m.project_id IS NULL)
+					AND m.starts_at <= ? AND (m.ends_at IS NULL OR m.ends_at > ?)
+				ORDER BY m.project_id IS NULL, m.starts_at DESC, m.id DESC
+				LIMIT 1) as maintenance_page
 		FROM routes r
-		JOIN services s ON r.service_id = s.id
-		JOIN projects p ON s.project_id = p.id
-		ORDER BY r.domain`)
+		LEFT JOIN services s ON r.service_id = s.id
+		LEFT JOIN projects p ON s.project_id = p.id
+		WHERE r.service_id IS NULL OR p.id IS NOT NULL
+		ORDER BY r.domain`, now, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query routes with services: %w", err)
 	}
@@ -1091,13 +1249,18 @@ func (s *Store) GetAllRoutesWithServices(ctx context.Context) ([]RouteWithServic
 	var routes []RouteWithService
 	for rows.Next() {
 		var route RouteWithService
+		var maintenancePage sql.NullString
 		err := rows.Scan(
-			&route.ID, &route.ServiceID, &route.Domain, &route.Port, &route.TLS, &route.Path,
-			&route.CertificateID, &route.ProxyConfig, &route.CreatedAt, &route.UpdatedAt,
-			&route.ServiceName, &route.ProjectName)
+			&route.ID, &route.ServiceID, &route.Kind, &route.Domain, &route.Port, &route.TLS, &route.Path, &route.MatchType, &route.StripPrefix,
+			&route.CertificateID, &route.ProxyConfig, &route.Access, &route.Redirect, &route.Static, &route.CreatedAt, &route.UpdatedAt,
+			&route.ServiceName, &route.ProjectName, &route.UpstreamHost, &route.Replicas, &route.LoadBalancing,
+			&maintenancePage)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan route: %w", err)
 		}
+		if maintenancePage.Valid {
+			route.MaintenancePage = &maintenancePage.String
+		}
 		routes = append(routes, route)
 	}
 
@@ -1111,24 +1274,27 @@ func (s *Store) GetAllRoutesWithServices(ctx context.Context) ([]RouteWithServic
 // UpdateRoute updates an existing route
 func (s *Store) UpdateRoute(ctx context.Context, id int64, spec RouteSpec) (Route, error) {
 	// Validate domain (basic validation)
-	if spec.Domain == "" {
-		return Route{}, fmt.Errorf("domain cannot be empty")
-	}
-
-	if len(spec.Domain) > 253 { // Max domain name length
-		return Route{}, fmt.Errorf("domain too long: max 253 characters")
+	if err := validateRouteSpec(spec); err != nil {
+		return Route{}, err
 	}
 
 	// Check that route exists
-	_, err := s.GetRoute(ctx, id)
+	existing, err := s.GetRoute(ctx, id)
 	if err != nil {
 		return Route{}, fmt.Errorf("route not found: %w", err)
 	}
+	if spec.Proxies() != existing.Proxies() {
+		return Route{}, fmt.Errorf("%w: proxy routes cannot become redirect or static routes and back", ErrRouteKind)
+	}
+
+	if err := checkRouteConflict(ctx, s.db, spec, id); err != nil {
+		return Route{}, err
+	}
 
 	// Update route
 	result, err := s.db.ExecContext(ctx,
-		"UPDATE routes SET domain = ?, port = ?, tls = ?, path = ?, certificate_id = ?, proxy_config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		spec.Domain, spec.Port, spec.TLS, spec.Path, spec.CertificateID, spec.ProxyConfig, id)
+		"UPDATE routes SET kind = ?, domain = ?, port = ?, tls = ?, path = ?, match_type = ?, strip_prefix = ?, certificate_id = ?, proxy_config = ?, access_config = ?, redirect_config = ?, static_config = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		routeKind(spec.Kind), spec.Domain, spec.Port, spec.TLS, spec.Path, routeMatchType(spec.MatchType), spec.StripPrefix, spec.CertificateID, spec.ProxyConfig, spec.Access, spec.Redirect, spec.Static, id)
 	if err != nil {
 		return Route{}, fmt.Errorf("failed to update route: %w", err)
 	}
@@ -1921,28 +2087,63 @@ func (s *Store) SetActiveNginxConfig(ctx context.Context, configID int64) error
 	return nil
 }
 
-// GetLastUpdatedTimestamp returns the maximum updated_at timestamp from routes and certificates
+// GetLastUpdatedTimestamp returns the latest change to what the nginx config is
+// rendered from: routes, certificates and maintenance windows, including
+// scheduled maintenance pages that went up or came down since.
 func (s *Store) GetLastUpdatedTimestamp(ctx context.Context) (time.Time, error) {
-	var maxTimestamp time.Time
-
-	// Query for the maximum updated_at from both routes and certificates
-	err := s.db.QueryRowContext(ctx, `
-		SELECT MAX(datetime) as max_time FROM (
-			SELECT MAX(updated_at) as datetime FROM routes WHERE updated_at IS NOT NULL
-			UNION ALL
-			SELECT MAX(updated_at) as datetime FROM certificates WHERE updated_at IS NOT NULL
-		)`).Scan(&maxTimestamp)
+	now := time.Now().UTC().Truncate(time.Second)
 
+	// Each table keeps its own timestamp format, so the maximum is taken here
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT MAX(updated_at) FROM routes
+		UNION ALL
+		SELECT MAX(updated_at) FROM certificates
+		UNION ALL
+		SELECT MAX(updated_at) FROM maintenance_windows
+		UNION ALL
+		SELECT MAX(starts_at) FROM maintenance_windows WHERE serve_page = 1 AND starts_at <= ?
+		UNION ALL
+		SELECT MAX(ends_at) FROM maintenance_windows WHERE serve_page = 1 AND ends_at <= ?`, now, now)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return time.Time{}, nil // No updates found
+		return time.Time{}, fmt.Errorf("failed to get last updated timestamp: %w", err)
+	}
+	defer rows.Close()
+
+	var maxTimestamp time.Time
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return time.Time{}, fmt.Errorf("failed to scan last updated timestamp: %w", err)
+		}
+		if !value.Valid {
+			continue
+		}
+		timestamp, err := parseTimestamp(value.String)
+		if err != nil {
+			return time.Time{}, err
 		}
+		if timestamp.After(maxTimestamp) {
+			maxTimestamp = timestamp
+		}
+	}
+
+	if err := rows.Err(); err != nil {
 		return time.Time{}, fmt.Errorf("failed to get last updated timestamp: %w", err)
 	}
 
 	return maxTimestamp, nil
 }
 
+// parseTimestamp parses a timestamp as written by CURRENT_TIMESTAMP or the sqlite driver
+func parseTimestamp(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00"} {
+		if timestamp, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return timestamp.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
 // Legacy certificate management methods (for the existing certs table)
 
 // UpsertCert creates or updates a certificate record
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetTaskConfig retrieves the schedule of a scheduled task service
+func (s *Store) GetTaskConfig(ctx context.Context, serviceID int64) (*TaskConfig, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT service_id, schedule, command, timeout_seconds, suspended, last_scheduled_at, updated_at FROM service_tasks WHERE service_id = ?",
+		serviceID)
+
+	config, err := scanTaskConfig(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task config: %w", err)
+	}
+
+	return config, nil
+}
+
+// SetTaskConfig creates or replaces the schedule of a scheduled task service,
+// keeping the time of its last scheduled run
+func (s *Store) SetTaskConfig(ctx context.Context, config *TaskConfig) error {
+	*config = config.normalized()
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return upsertTaskConfig(ctx, s.db, config)
+}
+
+// upsertTaskConfig writes a validated task config
+func upsertTaskConfig(ctx context.Context, db dbExecutor, config *TaskConfig) error {
+	commandJSON, err := marshalJSON(config.Command)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command: %w", err)
+	}
+	config.UpdatedAt = time.Now().UTC()
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO service_tasks (service_id, schedule, command, timeout_seconds, suspended, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(service_id) DO UPDATE SET
+			schedule = excluded.schedule,
+			command = excluded.command,
+			timeout_seconds = excluded.timeout_seconds,
+			suspended = excluded.suspended,
+			updated_at = excluded.updated_at
+	`, config.ServiceID, config.Schedule, commandJSON, config.TimeoutSeconds, config.Suspended, config.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set task config: %w", err)
+	}
+
+	return nil
+}
+
+// ListTaskConfigs returns the schedules of all scheduled tasks that are not suspended
+func (s *Store) ListTaskConfigs(ctx context.Context) ([]TaskConfig, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.service_id, t.schedule, t.command, t.timeout_seconds, t.suspended, t.last_scheduled_at, t.updated_at
+		FROM service_tasks t
+		JOIN services s ON s.id = t.service_id
+		WHERE s.kind = ? AND t.suspended = 0
+		ORDER BY t.service_id`, ServiceKindTask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task configs: %w", err)
+	}
+	defer rows.Close()
+
+	var configs []TaskConfig
+	for rows.Next() {
+		config, err := scanTaskConfig(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task config: %w", err)
+		}
+		configs = append(configs, *config)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate task configs: %w", err)
+	}
+
+	return configs, nil
+}
+
+// MarkTaskScheduled records when the scheduler last fired a task
+func (s *Store) MarkTaskScheduled(ctx context.Context, serviceID int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE service_tasks SET last_scheduled_at = ? WHERE service_id = ?", at.UTC(), serviceID)
+	if err != nil {
+		return fmt.Errorf("failed to mark task scheduled: %w", err)
+	}
+	return nil
+}
+
+// scanTaskConfig scans a single service_tasks row
+func scanTaskConfig(row rowScanner) (*TaskConfig, error) {
+	var config TaskConfig
+	var commandJSON sql.NullString
+	var lastScheduledAt sql.NullTime
+
+	err := row.Scan(
+		&config.ServiceID,
+		&config.Schedule,
+		&commandJSON,
+		&config.TimeoutSeconds,
+		&config.Suspended,
+		&lastScheduledAt,
+		&config.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unmarshalJSON(commandJSON.String, &config.Command); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal command: %w", err)
+	}
+	if lastScheduledAt.Valid {
+		config.LastScheduledAt = &lastScheduledAt.Time
+	}
+
+	return &config, nil
+}
+
+// CreateTaskRun inserts a queued task run and sets its ID
+func (s *Store) CreateTaskRun(ctx context.Context, run *TaskRun) error {
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now().UTC()
+	}
+	if run.Status == "" {
+		run.Status = TaskRunQueued
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO task_runs (service_id, trigger, status, created_at) VALUES (?, ?, ?, ?)",
+		run.ServiceID, run.Trigger, run.Status, run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create task run: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get task run ID: %w", err)
+	}
+	run.ID = id
+
+	return nil
+}
+
+// StartTaskRun marks a task run as running
+func (s *Store) StartTaskRun(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE task_runs SET status = ?, started_at = ? WHERE id = ?", TaskRunRunning, at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to start task run: %w", err)
+	}
+	return nil
+}
+
+// FinishTaskRun records the outcome of a task run
+func (s *Store) FinishTaskRun(ctx context.Context, run *TaskRun) error {
+	if run.FinishedAt == nil {
+		now := time.Now().UTC()
+		run.FinishedAt = &now
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE task_runs SET status = ?, exit_code = ?, error = ?, logs = ?, duration_ms = ?, started_at = ?, finished_at = ? WHERE id = ?",
+		run.Status, run.ExitCode, run.Error, run.Logs, run.DurationMs, run.StartedAt, run.FinishedAt, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish task run: %w", err)
+	}
+	return nil
+}
+
+// GetTaskRun retrieves a task run with its logs
+func (s *Store) GetTaskRun(ctx context.Context, id int64) (*TaskRun, error) {
+	row := s.db.QueryRowContext(ctx,
+		"SELECT id, service_id, trigger, status, exit_code, error, logs, duration_ms, created_at, started_at, finished_at FROM task_runs WHERE id = ?", id)
+
+	run, err := scanTaskRun(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task run: %w", err)
+	}
+
+	return run, nil
+}
+
+// ListTaskRuns returns up to limit runs of a task, newest first, without their logs
+func (s *Store) ListTaskRuns(ctx context.Context, serviceID int64, limit int) ([]TaskRun, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, service_id, trigger, status, exit_code, error, '', duration_ms, created_at, started_at, finished_at FROM task_runs WHERE service_id = ? ORDER BY created_at DESC, id DESC LIMIT ?",
+		serviceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []TaskRun
+	for rows.Next() {
+		run, err := scanTaskRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate task runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// HasActiveTaskRun reports whether a run of the task is queued or running
+func (s *Store) HasActiveTaskRun(ctx context.Context, serviceID int64) (bool, error) {
+	var active bool
+	err := s.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM task_runs WHERE service_id = ? AND status IN (?, ?))",
+		serviceID, TaskRunQueued, TaskRunRunning).Scan(&active)
+	if err != nil {
+		return false, fmt.Errorf("failed to check active task runs: %w", err)
+	}
+	return active, nil
+}
+
+// FailInterruptedTaskRuns marks runs left running by a previous process as failed
+func (s *Store) FailInterruptedTaskRuns(ctx context.Context, reason string) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE task_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?",
+		TaskRunFailed, reason, time.Now().UTC(), TaskRunRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted task runs: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected, nil
+}
+
+// PruneTaskRuns deletes the finished runs of a task beyond the newest keep runs
+func (s *Store) PruneTaskRuns(ctx context.Context, serviceID int64, keep int) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM task_runs
+		WHERE service_id = ? AND status IN (?, ?) AND id NOT IN (
+			SELECT id FROM task_runs WHERE service_id = ? ORDER BY created_at DESC, id DESC LIMIT ?
+		)`, serviceID, TaskRunSucceeded, TaskRunFailed, serviceID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to prune task runs: %w", err)
+	}
+	return nil
+}
+
+// scanTaskRun scans a single task_runs row
+func scanTaskRun(row rowScanner) (*TaskRun, error) {
+	var run TaskRun
+	var logs sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	err := row.Scan(
+		&run.ID,
+		&run.ServiceID,
+		&run.Trigger,
+		&run.Status,
+		&run.ExitCode,
+		&run.Error,
+		&logs,
+		&run.DurationMs,
+		&run.CreatedAt,
+		&startedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Logs = logs.String
+	if startedAt.Valid {
+		run.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+
+	return &run, nil
+}
@@ -21,6 +21,8 @@ type Config struct {
 	GitHubAppPrivateKeyPath string
 	GitHubAppWebhookSecret  string
 	NginxProxyEnabled       bool
+	NginxControllerAddr     string   // host:port nginx reaches glinrdock at for forward auth checks
+	BindMountRoots          []string // host directories bind mounts must be under, any when empty
 
 	// DNS and domain management configuration
 	DNSVerifyEnabled bool
@@ -55,6 +57,8 @@ func LoadConfig() *Config {
 		GitHubAppPrivateKeyPath: getEnv("GITHUB_APP_PRIVATE_KEY_PATH", ""),
 		GitHubAppWebhookSecret:  getEnv("GITHUB_APP_WEBHOOK_SECRET", ""),
 		NginxProxyEnabled:       getBoolEnv("NGINX_PROXY_ENABLED", false),
+		NginxControllerAddr:     getEnv("NGINX_CONTROLLER_ADDR", "127.0.0.1:8080"),
+		BindMountRoots:          parsePaths(getEnv("GLINRDOCK_BIND_MOUNT_ROOTS", "")),
 
 		// DNS and domain management configuration
 		DNSVerifyEnabled: getBoolEnv("DNS_VERIFY_ENABLED", true),
@@ -103,3 +107,10 @@ func parseResolvers(resolvers string) []string {
 	}
 	return strings.Split(resolvers, ",")
 }
+
+func parsePaths(paths string) []string {
+	if paths == "" {
+		return []string{}
+	}
+	return strings.Split(paths, ",")
+}
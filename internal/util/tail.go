@@ -0,0 +1,41 @@
+package util
+
+import (
+	"bytes"
+	"sync"
+)
+
+// TailBuffer is an io.Writer keeping the last bytes written to it, up to a
+// limit. It is safe for concurrent writes, so stdout and stderr can share one
+// buffer and keep their lines interleaved.
+type TailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   bytes.Buffer
+}
+
+// NewTailBuffer creates a buffer keeping the last limit bytes written to it
+func NewTailBuffer(limit int) *TailBuffer {
+	return &TailBuffer{limit: limit}
+}
+
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	if len(p) > t.limit {
+		p = p[len(p)-t.limit:]
+	}
+	if overflow := t.buf.Len() + len(p) - t.limit; overflow > 0 {
+		t.buf.Next(overflow)
+	}
+	t.buf.Write(p)
+	return n, nil
+}
+
+func (t *TailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailBuffer_KeepsTail(t *testing.T) {
+	tail := NewTailBuffer(8)
+	tail.Write([]byte("abcdef"))
+	tail.Write([]byte("ghij"))
+	assert.Equal(t, "cdefghij", tail.String())
+
+	tail.Write([]byte(strings.Repeat("x", 20) + "12345678"))
+	assert.Equal(t, "12345678", tail.String())
+}
@@ -129,7 +129,7 @@ export interface Service {
   last_exit_code?: number
   restart_count: number
   crash_looping: boolean
-  health_status: 'ok' | 'fail' | 'unknown'
+  health_status: 'ok' | 'fail' | 'unknown' | 'unreachable'
   last_probe_at?: string
   registry_id?: string
 }
@@ -194,7 +194,7 @@ export interface ServiceDetail {
   last_exit_code?: number
   restart_count: number
   crash_looping: boolean
-  health_status: 'ok' | 'fail' | 'unknown'
+  health_status: 'ok' | 'fail' | 'unknown' | 'unreachable'
   last_probe_at?: string
   env_summary_count: number
   last_deploy_at?: string
@@ -204,14 +204,77 @@ export interface ServiceDetail {
   aliases?: string[]
 }
 
+export interface RoutePolicy {
+  connect_timeout?: number
+  read_timeout?: number
+  send_timeout?: number
+  max_body_size?: string
+  buffering?: boolean
+  websocket?: boolean
+  gzip?: boolean
+  request_headers?: Record<string, string>
+  response_headers?: Record<string, string>
+  cors?: {
+    allow_origins: string[]
+    allow_methods?: string[]
+    allow_headers?: string[]
+    expose_headers?: string[]
+    allow_credentials?: boolean
+    max_age?: number
+  }
+  rate_limit?: {
+    requests_per_second?: number
+    burst?: number
+    nodelay?: boolean
+    connections?: number
+    key?: string // "ip" or "header:<name>"
+    status_code?: number
+  }
+}
+
+export interface RouteAccess {
+  allow?: string[]
+  deny?: string[]
+  basic_auth?: { realm?: string }
+  forward_auth?: { role?: 'viewer' | 'deployer' | 'admin' }
+  satisfy?: 'all' | 'any'
+}
+
+export interface RouteCredential {
+  id: number
+  route_id: number
+  username: string
+  created_at: string
+  updated_at: string
+}
+
+export interface RouteRedirect {
+  url: string
+  status_code?: 301 | 302 | 307 | 308
+  keep_path?: boolean
+}
+
+export interface RouteStatic {
+  status_code?: number
+  body?: string
+  content_type?: string
+  file?: string
+}
+
 export interface Route {
   id: number
-  service_id: number
+  service_id: number // 0 for redirect and static routes
+  kind?: 'proxy' | 'redirect' | 'static'
   domain: string
   path?: string
+  match_type?: 'prefix' | 'exact' | 'regex'
+  strip_prefix?: boolean
   port: number
   tls: boolean
-  proxy_config?: string
+  proxy_config?: RoutePolicy
+  access?: RouteAccess
+  redirect?: RouteRedirect
+  static?: RouteStatic
   created_at: string
   updated_at: string
   // Health check fields (optional, populated when available)
@@ -409,6 +472,7 @@ export interface PlanInfo {
     sso: boolean
     audit_logs: boolean
     ci_integrations: boolean
+    web_terminal: boolean
     advanced_dashboards: boolean
   }
 }
@@ -1003,13 +1067,13 @@ class APIClient {
   async runHealthCheck(id: string): Promise<{
     message: string
     service_id: number
-    health_status: 'ok' | 'fail' | 'unknown'
+    health_status: 'ok' | 'fail' | 'unknown' | 'unreachable'
     last_probe_at: string
   }> {
     return this.post<{
       message: string
       service_id: number
-      health_status: 'ok' | 'fail' | 'unknown'
+      health_status: 'ok' | 'fail' | 'unknown' | 'unreachable'
       last_probe_at: string
     }>(`/services/${id}/health-check/run`)
   }
@@ -1135,12 +1199,44 @@ class APIClient {
     return this.delete<void>(`/routes/${id}`)
   }
   
+  // Redirect and static routes have no service
+  async createResponseRoute(data: {
+    kind: 'redirect' | 'static'
+    domain: string
+    path?: string
+    match_type?: Route['match_type']
+    tls?: boolean
+    redirect?: RouteRedirect
+    static?: RouteStatic
+  }): Promise<Route> {
+    const result = await this.post<Route>('/routes', data)
+
+    // Log audit event
+    this.logAuditEvent('route.create', {
+      action: 'route_created',
+      kind: data.kind,
+      domain: data.domain,
+      path: data.path || '/',
+      tls: data.tls || false,
+      route_id: result.id,
+      timestamp: new Date().toISOString()
+    }).catch(console.warn)
+
+    return result
+  }
+
   async updateRoute(id: string, data: {
     domain: string
     path?: string
-    port: number
+    port?: number
     tls: boolean
-    proxy_config?: string
+    match_type?: Route['match_type']
+    strip_prefix?: boolean
+    proxy_config?: RoutePolicy
+    access?: RouteAccess
+    kind?: Route['kind']
+    redirect?: RouteRedirect
+    static?: RouteStatic
   }): Promise<Route> {
     const result = await this.put<Route>(`/routes/${id}`, data)
     
@@ -1159,6 +1255,18 @@ class APIClient {
   async previewRouteNginxConfig(id: string): Promise<{config: string}> {
     return this.get<{config: string}>(`/routes/${id}/config`)
   }
+
+  async listRouteCredentials(id: string): Promise<{route_id: number, basic_auth: boolean, credentials: RouteCredential[]}> {
+    return this.get(`/routes/${id}/credentials`)
+  }
+
+  async setRouteCredential(id: string, username: string, password: string): Promise<RouteCredential> {
+    return this.put<RouteCredential>(`/routes/${id}/credentials/${encodeURIComponent(username)}`, { password })
+  }
+
+  async deleteRouteCredential(id: string, username: string): Promise<void> {
+    await this.delete(`/routes/${id}/credentials/${encodeURIComponent(username)}`)
+  }
   
   // Certificate API methods
   async listCertificates(): Promise<Certificate[]> {
@@ -3,7 +3,7 @@ import { apiClient } from '../api'
 
 interface HealthCheckButtonProps {
   serviceId: string
-  onHealthUpdate?: (status: 'ok' | 'fail' | 'unknown', lastProbeAt: string) => void
+  onHealthUpdate?: (status: 'ok' | 'fail' | 'unknown' | 'unreachable', lastProbeAt: string) => void
   size?: 'sm' | 'md'
   className?: string
 }
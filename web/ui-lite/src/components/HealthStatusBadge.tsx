@@ -2,7 +2,7 @@ import { Badge } from './ui'
 import { formatTimeAgo } from '../utils/timeFormat'
 
 interface HealthStatusBadgeProps {
-  status: 'ok' | 'fail' | 'unknown'
+  status: 'ok' | 'fail' | 'unknown' | 'unreachable'
   lastProbeAt?: string
   className?: string
 }
@@ -14,6 +14,8 @@ export function HealthStatusBadge({ status, lastProbeAt, className = '' }: Healt
         return { variant: 'success' as const, label: 'Healthy', icon: '✓' }
       case 'fail':
         return { variant: 'danger' as const, label: 'Unhealthy', icon: '✗' }
+      case 'unreachable':
+        return { variant: 'warning' as const, label: 'Unreachable', icon: '!' }
       case 'unknown':
       default:
         return { variant: 'secondary' as const, label: 'Unknown', icon: '?' }
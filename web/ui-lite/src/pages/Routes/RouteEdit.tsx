@@ -27,6 +27,8 @@ interface RouteEditProps {
 
 export function RouteEdit({ routeId }: RouteEditProps) {
   const [routeData, setRouteData] = useState<Route | null>(null);
+  // Redirect and static routes have no service and no port
+  const proxies = !routeData?.kind || routeData.kind === "proxy";
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState(false);
   const [previewing, setPreviewing] = useState(false);
@@ -79,7 +81,7 @@ export function RouteEdit({ routeId }: RouteEditProps) {
       setPath(foundRoute.path || "");
       setPort(foundRoute.port);
       setTls(foundRoute.tls);
-      setProxyConfig(foundRoute.proxy_config || "");
+      setProxyConfig(foundRoute.proxy_config ? JSON.stringify(foundRoute.proxy_config, null, 2) : "");
       setSelectedServiceId(foundRoute.service_id);
     } catch (error) {
       console.error("Failed to load route:", error);
@@ -126,12 +128,12 @@ export function RouteEdit({ routeId }: RouteEditProps) {
       return false;
     }
     
-    if (!selectedServiceId) {
+    if (proxies && !selectedServiceId) {
       showToast("Service is required", "error");
       return false;
     }
     
-    if (port < 1 || port > 65535) {
+    if (proxies && (port < 1 || port > 65535)) {
       showToast("Port must be between 1 and 65535", "error");
       return false;
     }
@@ -170,9 +172,16 @@ export function RouteEdit({ routeId }: RouteEditProps) {
       await apiClient.updateRoute(routeId, {
         domain: domain.trim(),
         path: path.trim() || undefined,
-        port,
+        port: proxies ? port : undefined,
         tls,
-        proxy_config: proxyConfig.trim() || undefined,
+        proxy_config: proxies && proxyConfig.trim() ? JSON.parse(proxyConfig) : undefined,
+        // Not editable here, kept as they are
+        match_type: routeData?.match_type,
+        strip_prefix: routeData?.strip_prefix,
+        access: routeData?.access,
+        kind: routeData?.kind,
+        redirect: routeData?.redirect,
+        static: routeData?.static,
       });
 
       showToast("Route updated successfully", "success");
@@ -450,12 +459,12 @@ export function RouteEdit({ routeId }: RouteEditProps) {
               <textarea
                 value={proxyConfig}
                 onInput={(e) => setProxyConfig((e.target as HTMLTextAreaElement).value)}
-                placeholder='{"proxy_read_timeout": "60s", "client_max_body_size": "10m"}'
+                placeholder='{"read_timeout": 60, "max_body_size": "10m", "websocket": true}'
                 rows={4}
                 class="block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm placeholder-gray-400 focus:outline-none focus:ring-blue-500 focus:border-blue-500 dark:bg-gray-800 dark:text-white font-mono text-sm"
               />
               <p class="mt-1 text-xs text-gray-500 dark:text-gray-400">
-                Optional nginx proxy configuration as JSON. Leave empty for defaults.
+                Optional route policy as JSON: timeouts, max_body_size, buffering, websocket, gzip, request_headers, response_headers and cors. Leave empty for defaults.
               </p>
             </div>
           </div>